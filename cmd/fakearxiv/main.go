@@ -0,0 +1,59 @@
+// Command fakearxiv serves a stand-in for the arXiv API, for end-to-end
+// tests and local development without touching the real one. Point the
+// app at it with ARXIV_BASE_URLS and ARXIV_HTML_BASE_URL.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv/arxivtest"
+	"github.com/ngx/arxiv-go-nest/internal/fixtures"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8090", "Address to listen on")
+	papers := flag.Int("papers", 200, "Number of generated papers to answer queries from")
+	feed := flag.String("feed", "", "Atom feed file to serve as-is for every query instead")
+	latency := flag.Duration("latency", 0, "Delay before every response")
+	errorRate := flag.Float64("error-rate", 0, "Fraction of requests to fail, from 0 to 1")
+	errorStatus := flag.Int("error-status", http.StatusServiceUnavailable, "Status code of failed requests")
+	rateLimit := flag.Duration("rate-limit", 0, "Refuse requests arriving sooner than this after the previous one with 429")
+	flag.Parse()
+
+	if *papers < 0 || *papers > fixtures.MaxPapers {
+		log.Fatalf("-papers must be between 0 and %d", fixtures.MaxPapers)
+	}
+	if *errorRate < 0 || *errorRate > 1 {
+		log.Fatalf("-error-rate must be between 0 and 1")
+	}
+
+	opts := arxivtest.Options{
+		Papers:      *papers,
+		Latency:     *latency,
+		ErrorRate:   *errorRate,
+		ErrorStatus: *errorStatus,
+		RateLimit:   *rateLimit,
+	}
+	if *feed != "" {
+		data, err := os.ReadFile(*feed)
+		if err != nil {
+			log.Fatalf("Failed to read feed: %v", err)
+		}
+		opts.Feed = data
+	}
+
+	log.Printf("Serving a fake arXiv API on http://%s/api/query", *addr)
+	log.Printf("Run the app with ARXIV_BASE_URLS=http://%s/api/query ARXIV_HTML_BASE_URL=http://%s/html/", *addr, *addr)
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           arxivtest.New(opts, time.Now()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
@@ -1,36 +1,92 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/ngx/arxiv-go-nest/internal/arxiv"
 	"github.com/ngx/arxiv-go-nest/internal/config"
 	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/diagnostics"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/fetcher"
+	"github.com/ngx/arxiv-go-nest/internal/fixtures"
+	"github.com/ngx/arxiv-go-nest/internal/hooks"
+	"github.com/ngx/arxiv-go-nest/internal/links"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+	"github.com/ngx/arxiv-go-nest/internal/publish"
+	"github.com/ngx/arxiv-go-nest/internal/readlog"
+	"github.com/ngx/arxiv-go-nest/internal/scheduler"
 	"github.com/ngx/arxiv-go-nest/internal/server"
+	"github.com/ngx/arxiv-go-nest/internal/sources"
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+	"github.com/ngx/arxiv-go-nest/internal/venues"
+	"github.com/ngx/arxiv-go-nest/internal/version"
 )
 
 const (
 	defaultConfigPath = "config.yaml"
+
+	// logHistory is how many log lines diagnostics bundles include
+	logHistory = 500
+
+	// statsDays is the window of the stats command's new paper counts
+	statsDays = 7
 )
 
 func main() {
 	// Parse command line flags
 	configPath := flag.String("config", defaultConfigPath, "Path to configuration file")
+	showVersion := flag.Bool("version", false, "Print the version and exit")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(version.Get())
+		return
+	}
+
+	// Keep recent log lines for the diagnostics bundle
+	logs := diagnostics.NewLogBuffer(logHistory)
+	log.SetOutput(io.MultiWriter(os.Stderr, logs))
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Parse command
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"server"} // Default to server command
+	}
+
+	command := args[0]
+
+	// Needs neither the database nor telemetry
+	if command == "hash-password" {
+		runHashPassword()
+		return
+	}
+
+	// The preview runs on generated data, never the configured database
+	if command == "preview" {
+		cfg.Database.Path = ":memory:"
+	}
+
 	// Initialize database
 	database, err := db.New(cfg.Database.Path)
 	if err != nil {
@@ -38,39 +94,116 @@ func main() {
 	}
 	defer database.Close()
 
-	// Parse command
-	args := flag.Args()
-	if len(args) == 0 {
-		args = []string{"server"} // Default to server command
+	// Enable the slow query log if configured
+	if cfg.Database.SlowQueryThreshold > 0 {
+		var w io.Writer = os.Stderr
+		if cfg.Database.SlowQueryLog != "" {
+			f, err := os.OpenFile(cfg.Database.SlowQueryLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				log.Fatalf("Failed to open slow query log: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		database.SetSlowQueryLog(cfg.Database.SlowQueryThreshold, w)
 	}
 
-	command := args[0]
+	// Read paper lists and statistics from the replica if configured
+	if cfg.Database.ReplicaPath != "" && command != "preview" {
+		if err := database.OpenReplica(cfg.Database.ReplicaPath); err != nil {
+			log.Fatalf("Failed to open database replica: %v", err)
+		}
+	}
+
+	// Export traces and metrics if an OTLP endpoint is configured
+	tp, err := telemetry.Setup(cfg.Telemetry, version.Get().Version)
+	if err != nil {
+		log.Fatalf("Failed to configure telemetry: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Printf("Error exporting telemetry: %v", err)
+		}
+	}()
 
 	switch command {
 	case "server":
-		runServer(cfg, database)
+		runServer(*configPath, cfg, database, logs)
 	case "fetch":
 		runFetch(cfg, database)
 	case "migrate":
 		fmt.Println("Database migrations completed successfully")
+	case "diagnostics":
+		runDiagnostics(cfg, database, logs, args[1:])
+	case "publish":
+		runPublish(database, args[1:])
+	case "backfill":
+		runBackfill(cfg, database, args[1:])
+	case "import":
+		runImport(cfg, database, args[1:])
+	case "preview":
+		runPreview(cfg, database, logs, args[1:])
+	case "stats":
+		runStats(database, args[1:])
+	case "verify-index":
+		runVerifyIndex(database, args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
-		fmt.Fprintf(os.Stderr, "Available commands: server, fetch, migrate\n")
+		fmt.Fprintf(os.Stderr, "Available commands: server, fetch, migrate, diagnostics, publish, backfill, import, preview, stats, verify-index, hash-password\n")
 		os.Exit(1)
 	}
 }
 
-// runServer starts the HTTP server with background scheduler
-func runServer(cfg *config.Config, database *db.DB) {
+// runServer starts the HTTP server with background scheduler. SIGHUP
+// reloads the configuration file at configPath.
+func runServer(configPath string, cfg *config.Config, database *db.DB, logs *diagnostics.LogBuffer) {
+	flags := newFeatures(cfg, database)
+	client := newClient(cfg)
+	runner := newHooks(cfg)
+	f := newFetcher(cfg, database, client, flags, runner)
+
+	// Don't lose papers waiting for a notification digest, or hooks still
+	// running. Deferred first, so it runs after the scheduler has stopped
+	// fetching.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		f.FlushNotifications(ctx)
+		runner.Wait()
+	}()
+
+	// Optional check for newer releases
+	var updates *version.Checker
+	if cfg.Updates.Check {
+		updates = version.NewChecker(cfg.Updates.Repository, version.Get().Version)
+	}
+
+	// Background jobs, paused and resumed from /admin/scheduler
+	sched, err := newScheduler(cfg, database, f, flags, updates)
+	if err != nil {
+		log.Fatalf("Failed to create scheduler: %v", err)
+	}
+	sched.Start(10 * time.Second)
+	defer sched.Stop()
+
 	// Create server
-	srv, err := server.New(cfg, database)
+	srv, err := server.New(cfg, database, client, f, flags, sched, logs, updates, runner)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
-	// Start background scheduler
-	stopScheduler := startScheduler(cfg, database)
-	defer stopScheduler()
+	// Reload the configuration on SIGHUP or from the admin page
+	reload := &reloader{path: configPath, started: cfg, database: database, fetcher: f, flags: flags, sched: sched, updates: updates}
+	srv.SetReloader(reload.Reload)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload.Reload()
+		}
+	}()
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -90,112 +223,596 @@ func runServer(cfg *config.Config, database *db.DB) {
 	case sig := <-sigChan:
 		log.Printf("Received signal %v, shutting down gracefully...", sig)
 	}
+
 }
 
-// runFetch manually fetches new papers from arXiv
-func runFetch(cfg *config.Config, database *db.DB) {
-	ctx := context.Background()
-	client := arxiv.NewClient(cfg.ArXiv.RateLimitDelay)
+// runPreview serves the templates against generated fixture data, with
+// pages reloading as templates and static files are edited. Nothing is
+// fetched from arXiv and no background jobs run.
+func runPreview(cfg *config.Config, database *db.DB, logs *diagnostics.LogBuffer, args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	port := fs.Int("port", cfg.Server.Port, "Port to serve the preview on")
+	fs.Parse(args)
+	cfg.Server.Port = *port
+
+	if err := fixtures.Seed(database, time.Now()); err != nil {
+		log.Fatalf("Failed to generate fixtures: %v", err)
+	}
 
-	params := arxiv.FetchParams{
-		Categories: cfg.ArXiv.Categories,
-		Keywords:   cfg.ArXiv.Keywords,
-		MaxResults: cfg.ArXiv.MaxResults,
-		SortBy:     "submittedDate",
-		SortOrder:  "descending",
+	flags := newFeatures(cfg, database)
+	f := fetcher.New(cfg, database, nil, nil, flags)
+	sched, err := scheduler.New(database)
+	if err != nil {
+		log.Fatalf("Failed to create scheduler: %v", err)
 	}
 
+	srv, err := server.New(cfg, database, nil, f, flags, sched, logs, nil, nil)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+	srv.EnablePreview()
+
+	log.Printf("Previewing %d generated papers; edit web/templates and open pages reload", fixtures.PaperCount)
+	if err := srv.Start(); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+// runFetch manually fetches new papers from arXiv
+func runFetch(cfg *config.Config, database *db.DB) {
+	runner := newHooks(cfg)
+	f := newFetcher(cfg, database, newClient(cfg), newFeatures(cfg, database), runner)
+
 	log.Printf("Fetching papers from arXiv...")
-	log.Printf("Categories: %v", params.Categories)
-	log.Printf("Max results: %d", params.MaxResults)
+	log.Printf("Categories: %v", cfg.ArXiv.Categories)
+	log.Printf("Max results: %d", cfg.ArXiv.MaxResults)
 
-	feed, err := client.FetchNew(ctx, params)
+	result, err := f.Run(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to fetch papers: %v", err)
 	}
 
-	papers, err := feed.ToPapers()
+	// A one-off fetch sends its digests now rather than waiting for the window
+	f.FlushNotifications(context.Background())
+	runner.Wait()
+
+	log.Printf("Fetched %d papers, %d new", result.Fetched, len(result.New))
+	log.Printf("Successfully stored %d papers (%d unchanged)", result.Stored, result.Unchanged)
+}
+
+// runDiagnostics writes a diagnostics bundle for bug reports. Logs only
+// cover this process; download the bundle from /admin/diagnostics to
+// include the server's logs.
+func runDiagnostics(cfg *config.Config, database *db.DB, logs *diagnostics.LogBuffer, args []string) {
+	fs := flag.NewFlagSet("diagnostics", flag.ExitOnError)
+	output := fs.String("o", diagnostics.Filename(time.Now()), "Output file")
+	fs.Parse(args)
+
+	f, err := os.Create(*output)
 	if err != nil {
-		log.Fatalf("Failed to parse papers: %v", err)
+		log.Fatalf("Failed to create %s: %v", *output, err)
+	}
+	defer f.Close()
+
+	if err := diagnostics.New(cfg, database, logs).WriteZip(f); err != nil {
+		log.Fatalf("Failed to write diagnostics: %v", err)
 	}
 
-	log.Printf("Fetched %d papers, inserting into database...", len(papers))
+	log.Printf("Wrote diagnostics to %s", *output)
+}
+
+// instanceStats is the summary printed by the stats command
+type instanceStats struct {
+	Papers        int             `json:"papers"`
+	NewPapers     int             `json:"new_papers"`
+	NewByCategory []categoryStats `json:"new_by_category"`
+	Library       int             `json:"library"`
+	Read          int             `json:"read"`
+	Unread        int             `json:"unread"`
+	LastFetch     *fetchStats     `json:"last_fetch"`
+}
+
+type categoryStats struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+type fetchStats struct {
+	Scope      string    `json:"scope"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Stored     int       `json:"stored"`
+	New        int       `json:"new"`
+	Error      string    `json:"error,omitempty"`
+}
 
-	count := 0
-	for _, paper := range papers {
-		if err := database.UpsertPaper(paper); err != nil {
-			log.Printf("Error inserting paper %s: %v", paper.ID, err)
-			continue
+// runHashPassword reads a password from the first line of stdin and prints
+// its hash for the auth.users setting
+func runHashPassword() {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		log.Fatalf("Usage: echo 'password' | arxiv-nest hash-password")
+	}
+
+	hash, err := server.HashPassword(password)
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+	fmt.Println(hash)
+}
+
+// runStats prints a summary of the instance: papers, what arrived in the
+// last week per category, the library and how the last fetch went. With
+// -json the summary is printed as JSON for scripts.
+func runStats(database *db.DB, args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print JSON")
+	fs.Parse(args)
+
+	var stats instanceStats
+	var err error
+	if stats.Papers, err = database.GetPaperCount(); err != nil {
+		log.Fatalf("Failed to count papers: %v", err)
+	}
+	if stats.Library, err = database.GetLibraryCount(); err != nil {
+		log.Fatalf("Failed to count library papers: %v", err)
+	}
+	if stats.Read, err = database.GetReadCount(); err != nil {
+		log.Fatalf("Failed to count read papers: %v", err)
+	}
+	stats.Unread = stats.Library - stats.Read
+
+	// The rollups count papers by the day they were added, including today
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(statsDays - 1))
+	categories, err := database.GetCategoryTotals(since, -1)
+	if err != nil {
+		log.Fatalf("Failed to count new papers: %v", err)
+	}
+	stats.NewByCategory = make([]categoryStats, 0, len(categories))
+	for _, c := range categories {
+		stats.NewPapers += c.Count
+		stats.NewByCategory = append(stats.NewByCategory, categoryStats{Category: c.Category, Count: c.Count})
+	}
+
+	runs, err := database.GetFetchRuns(1)
+	if err != nil {
+		log.Fatalf("Failed to fetch run history: %v", err)
+	}
+	if len(runs) > 0 {
+		run := runs[0]
+		stats.LastFetch = &fetchStats{
+			Scope:      run.Scope,
+			StartedAt:  run.StartedAt,
+			FinishedAt: run.FinishedAt,
+			Stored:     run.Stored,
+			New:        run.New,
+			Error:      run.Error,
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			log.Fatalf("Failed to write stats: %v", err)
 		}
-		count++
+		return
 	}
 
-	log.Printf("Successfully stored %d papers", count)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Papers:\t%d\n", stats.Papers)
+	fmt.Fprintf(w, "New in the last %d days:\t%d\n", statsDays, stats.NewPapers)
+	for _, c := range stats.NewByCategory {
+		fmt.Fprintf(w, "  %s\t%d\n", c.Category, c.Count)
+	}
+	fmt.Fprintf(w, "Library:\t%d (%d read, %d unread)\n", stats.Library, stats.Read, stats.Unread)
+	switch run := stats.LastFetch; {
+	case run == nil:
+		fmt.Fprintf(w, "Last fetch:\tnever\n")
+	case run.Error != "":
+		fmt.Fprintf(w, "Last fetch:\tfailed %s (%s): %s\n", run.StartedAt.Local().Format("2006-01-02 15:04"), run.Scope, run.Error)
+	default:
+		fmt.Fprintf(w, "Last fetch:\tok %s (%s), %d stored, %d new\n", run.StartedAt.Local().Format("2006-01-02 15:04"), run.Scope, run.Stored, run.New)
+	}
+	w.Flush()
 }
 
-// startScheduler starts a background goroutine that fetches papers periodically
-func startScheduler(cfg *config.Config, database *db.DB) func() {
-	ticker := time.NewTicker(cfg.ArXiv.FetchInterval)
-	stopChan := make(chan struct{})
+// runVerifyIndex checks the indexes, counts and rollups derived from the
+// papers against the papers, and with -repair fixes what has drifted. It
+// exits with status 1 while drift is left.
+func runVerifyIndex(database *db.DB, args []string) {
+	fs := flag.NewFlagSet("verify-index", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "Fix the drift found")
+	fs.Parse(args)
 
-	go func() {
-		// Run initial fetch after a short delay
-		time.Sleep(10 * time.Second)
-		fetchPapers(cfg, database)
-
-		// Then run on schedule
-		for {
-			select {
-			case <-ticker.C:
-				fetchPapers(cfg, database)
-			case <-stopChan:
-				ticker.Stop()
-				return
-			}
+	checks, err := database.VerifyIndexes(*repair)
+	if err != nil {
+		log.Fatalf("Failed to verify indexes: %v", err)
+	}
+
+	drift := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range checks {
+		switch {
+		case c.Problems == 0:
+			fmt.Fprintf(w, "%s:\tok\n", c.Name)
+		case c.Repaired:
+			fmt.Fprintf(w, "%s:\t%d repaired\n", c.Name, c.Problems)
+		default:
+			drift = true
+			fmt.Fprintf(w, "%s:\t%d out of step\n", c.Name, c.Problems)
 		}
-	}()
+		for _, e := range c.Examples {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+	}
+	w.Flush()
 
-	// Return stop function
-	return func() {
-		close(stopChan)
+	if drift {
+		if !*repair {
+			fmt.Println("Run verify-index -repair to fix the drift")
+		}
+		os.Exit(1)
 	}
 }
 
-// fetchPapers fetches and stores papers from arXiv
-func fetchPapers(cfg *config.Config, database *db.DB) {
-	ctx := context.Background()
+// newFeatures loads feature flags from configuration and database overrides
+func newFeatures(cfg *config.Config, database *db.DB) *features.Flags {
+	flags, err := features.New(cfg.Features, database)
+	if err != nil {
+		log.Fatalf("Failed to load feature flags: %v", err)
+	}
+	flags.SetLightweight(cfg.Lightweight)
+	return flags
+}
+
+// newClient creates the arXiv client from configuration. The process uses a
+// single client so every request shares one rate limit.
+func newClient(cfg *config.Config) *arxiv.Client {
 	client := arxiv.NewClient(cfg.ArXiv.RateLimitDelay)
+	client.SetBaseURLs(cfg.ArXiv.BaseURLs, cfg.ArXiv.FailoverThreshold)
+	client.SetHTMLBaseURL(cfg.ArXiv.HTMLBaseURL)
+	return client
+}
+
+// newHooks creates the runner for the configured hooks
+func newHooks(cfg *config.Config) *hooks.Runner {
+	runner, err := hooks.New(cfg.Hooks)
+	if err != nil {
+		log.Fatalf("Failed to configure hooks: %v", err)
+	}
+	return runner
+}
+
+// newNotifier creates the notifier for the configured channels, logging
+// deliveries to the database
+func newNotifier(cfg *config.Config, database *db.DB) (*notify.Notifier, error) {
+	notifier, err := notify.New(cfg.Notifications)
+	if err != nil {
+		return nil, err
+	}
+	notifier.SetDeliveryLog(database)
+	return notifier, nil
+}
 
-	params := arxiv.FetchParams{
-		Categories: cfg.ArXiv.Categories,
-		Keywords:   cfg.ArXiv.Keywords,
-		MaxResults: cfg.ArXiv.MaxResults,
-		SortBy:     "submittedDate",
-		SortOrder:  "descending",
+// newFetcher creates the notifier and fetcher from configuration
+func newFetcher(cfg *config.Config, database *db.DB, client *arxiv.Client, flags *features.Flags, runner *hooks.Runner) *fetcher.Fetcher {
+	notifier, err := newNotifier(cfg, database)
+	if err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
 	}
 
+	catalog, err := venues.Load(cfg.Venues.File)
+	if err != nil {
+		log.Fatalf("Failed to load venues: %v", err)
+	}
+
+	f := fetcher.New(cfg, database, client, notifier, flags)
+	f.SetVenues(catalog)
+	f.SetHooks(runner)
+	f.SetSources(sources.New(sources.NewArxiv(client), sources.NewBioRxiv(), sources.NewMedRxiv()))
+	f.AnnounceCollections()
+	return f
+}
+
+// newScheduler creates the scheduler for the configured jobs
+func newScheduler(cfg *config.Config, database *db.DB, f *fetcher.Fetcher, flags *features.Flags, updates *version.Checker) (*scheduler.Scheduler, error) {
+	return scheduler.New(database, schedulerJobs(cfg, database, f, flags, updates)...)
+}
+
+// schedulerJobs returns the periodic fetch and link check and, when
+// configured, the trash purge, the quota prune, the reading log push and
+// the update check
+func schedulerJobs(cfg *config.Config, database *db.DB, f *fetcher.Fetcher, flags *features.Flags, updates *version.Checker) []scheduler.Job {
+	checker := links.New(database)
+	jobs := []scheduler.Job{{
+		Name:        "fetch",
+		Description: "Fetch new papers for all subscriptions",
+		Interval:    cfg.ArXiv.FetchInterval,
+		Run: func(ctx context.Context) error {
+			return fetchPapers(ctx, f)
+		},
+	}, {
+		Name:        "link-check",
+		Description: "Check the links of the next saved papers and repair broken arXiv links",
+		Interval:    time.Hour,
+		Run: func(ctx context.Context) error {
+			if !flags.Enabled(features.LinkCheck) {
+				return nil
+			}
+			result, err := checker.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if result.Broken > 0 || result.Repaired > 0 {
+				log.Printf("Checked the links of %d papers: %d broken, %d repaired", result.Papers, result.Broken, result.Repaired)
+			}
+			return nil
+		},
+	}}
+
+	if retention := cfg.TrashRetention(); retention > 0 {
+		jobs = append(jobs, scheduler.Job{
+			Name:        "trash-purge",
+			Description: "Permanently delete papers trashed longer than the retention period",
+			Interval:    time.Hour,
+			Run: func(ctx context.Context) error {
+				purged, err := database.PurgeTrash(time.Now().Add(-retention))
+				if err != nil {
+					return err
+				}
+				if purged > 0 {
+					log.Printf("Purged %d papers from the trash", purged)
+				}
+				return nil
+			},
+		})
+	}
+
+	quota := db.Quota{MaxPapers: cfg.Database.MaxPapers, MaxBytes: int64(cfg.Database.MaxSizeMB) << 20}
+	if quota.Enabled() {
+		jobs = append(jobs, scheduler.Job{
+			Name:        "prune",
+			Description: "Delete the oldest uncurated papers while the database is over its quota",
+			Interval:    time.Hour,
+			Run: func(ctx context.Context) error {
+				pruned, err := database.PruneToQuota(quota)
+				if err != nil {
+					return err
+				}
+				if len(pruned) > 0 {
+					logPruned(pruned)
+				}
+				return nil
+			},
+		})
+	}
+
+	if readLog := readlog.New(cfg.ReadingLog, database); readLog.Enabled() {
+		jobs = append(jobs, scheduler.Job{
+			Name:        "reading-log",
+			Description: "Push papers marked as read to Readwise or the reading log webhook",
+			Interval:    cfg.ReadingLog.Interval,
+			Run: func(ctx context.Context) error {
+				pushed, err := readLog.Push(ctx)
+				if pushed > 0 {
+					log.Printf("Pushed %d reads to the reading log", pushed)
+				}
+				return err
+			},
+		})
+	}
+
+	if updates != nil {
+		jobs = append(jobs, scheduler.Job{
+			Name:        "update-check",
+			Description: "Check GitHub for a newer release",
+			Interval:    cfg.Updates.Interval,
+			Run:         updates.Check,
+		})
+	}
+
+	return jobs
+}
+
+// prunedLogLimit is how many pruned papers are logged by name
+const prunedLogLimit = 20
+
+// logPruned logs the papers the quota pruned, oldest first
+func logPruned(pruned []models.Paper) {
+	log.Printf("Pruned %d papers to stay within the database quota", len(pruned))
+	for i, p := range pruned {
+		if i == prunedLogLimit {
+			log.Printf("  ... and %d more, up to %s", len(pruned)-i, pruned[len(pruned)-1].ID)
+			break
+		}
+		log.Printf("  %s (%s) %s", p.ID, p.PublishedAt.Format("2006-01-02"), p.Title)
+	}
+}
+
+// fetchPapers fetches and stores papers from arXiv
+func fetchPapers(ctx context.Context, f *fetcher.Fetcher) error {
 	log.Printf("Scheduled fetch: fetching papers from arXiv...")
 
-	feed, err := client.FetchNew(ctx, params)
+	result, err := f.Run(ctx)
 	if err != nil {
-		log.Printf("Error fetching papers: %v", err)
+		return err
+	}
+
+	log.Printf("Scheduled fetch: stored %d papers (%d new, %d unchanged)", result.Stored, len(result.New), result.Unchanged)
+	return nil
+}
+
+// runPublish renders the library, or the library papers with any of the
+// given tags, as a static site
+func runPublish(database *db.DB, args []string) {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	output := fs.String("o", "site", "Output directory")
+	tags := fs.String("tags", "", "Comma-separated tags to publish (default: the whole library)")
+	title := fs.String("title", "Reading List", "Site title")
+	baseURL := fs.String("base-url", "", "URL the site is served from, for feed links")
+	notes := fs.Bool("notes", false, "Include the \"why saved\" notes")
+	fs.Parse(args)
+
+	papers, err := libraryPapers(database, *tags)
+	if err != nil {
+		log.Fatalf("Failed to load library: %v", err)
+	}
+
+	result, err := publish.Write(papers, publish.Options{
+		Dir:     *output,
+		Title:   *title,
+		BaseURL: *baseURL,
+		Notes:   *notes,
+	}, time.Now())
+	if err != nil {
+		log.Fatalf("Failed to publish: %v", err)
+	}
+
+	log.Printf("Published %d papers and %d tags to %s", result.Papers, result.Tags, *output)
+}
+
+// runImport imports papers by arXiv ID or bioRxiv/medRxiv DOI or link
+func runImport(cfg *config.Config, database *db.DB, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	save := fs.Bool("save", false, "Save the imported papers to the library")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatalf("Usage: import [-save] ID|DOI|URL...")
+	}
+
+	runner := newHooks(cfg)
+	f := newFetcher(cfg, database, newClient(cfg), newFeatures(cfg, database), runner)
+
+	papers, err := f.Import(context.Background(), fs.Args())
+	for _, p := range papers {
+		if *save {
+			if err := database.SaveToLibrary(p.ID); err != nil {
+				log.Fatalf("Failed to save %s: %v", p.ID, err)
+			}
+		}
+		fmt.Printf("%s\t%s\n", p.ID, p.Title)
+	}
+	if err != nil {
+		log.Fatalf("Imported %d papers: %v", len(papers), err)
+	}
+	log.Printf("Imported %d papers", len(papers))
+}
+
+// runBackfill harvests a category's papers submitted between two days. It
+// saves a checkpoint after every page, so running the same command again
+// after an interruption, an error or the -for limit resumes the harvest.
+func runBackfill(cfg *config.Config, database *db.DB, args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	category := fs.String("category", "", "Category to harvest, e.g. cs.LG")
+	from := fs.String("from", "", "First submission day (YYYY-MM-DD)")
+	to := fs.String("to", time.Now().UTC().Format("2006-01-02"), "Last submission day (YYYY-MM-DD)")
+	limit := fs.Duration("for", 0, "Stop after this long, e.g. 6h, to spread the harvest over several runs (default: until done)")
+	pageSize := fs.Int("page-size", 200, "Results per request")
+	list := fs.Bool("list", false, "List backfills and their progress")
+	fs.Parse(args)
+
+	if *list {
+		backfills, err := database.GetBackfills()
+		if err != nil {
+			log.Fatalf("Failed to list backfills: %v", err)
+		}
+		for _, b := range backfills {
+			status := "done"
+			if !b.Done() {
+				status = "next " + b.NextDay.Format("2006-01-02")
+			}
+			fmt.Printf("%-12s %s to %s  %-16s %d fetched, %d stored\n", b.Category,
+				b.FromDay.Format("2006-01-02"), b.ToDay.Format("2006-01-02"), status, b.Fetched, b.Stored)
+		}
 		return
 	}
 
-	papers, err := feed.ToPapers()
+	if *category == "" || *from == "" {
+		log.Fatalf("Usage: backfill -category cs.LG -from 2020-01-01 [-to 2023-12-31] [-for 6h]")
+	}
+	fromDay, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		log.Fatalf("Invalid -from day: %v", err)
+	}
+	toDay, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		log.Fatalf("Invalid -to day: %v", err)
+	}
+	if toDay.Before(fromDay) {
+		log.Fatalf("-to is before -from")
+	}
+
+	b, err := database.StartBackfill(*category, fromDay, toDay)
 	if err != nil {
-		log.Printf("Error parsing papers: %v", err)
+		log.Fatalf("Failed to start backfill: %v", err)
+	}
+	if b.Done() {
+		log.Printf("Backfill of %s from %s to %s is already done", b.Category, *from, *to)
 		return
 	}
+	if b.NextDay.After(fromDay) || b.NextStart > 0 {
+		log.Printf("Resuming backfill of %s at %s", b.Category, b.NextDay.Format("2006-01-02"))
+	}
 
-	count := 0
-	for _, paper := range papers {
-		if err := database.UpsertPaper(paper); err != nil {
-			log.Printf("Error inserting paper %s: %v", paper.ID, err)
-			continue
-		}
-		count++
+	// Stop cleanly at the next checkpoint on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := fetcher.BackfillOptions{
+		PageSize: *pageSize,
+		Progress: func(b *models.Backfill) {
+			if b.NextStart == 0 {
+				log.Printf("%s: harvested up to %s, %d fetched, %d stored", b.Category,
+					b.NextDay.AddDate(0, 0, -1).Format("2006-01-02"), b.Fetched, b.Stored)
+			}
+		},
 	}
+	if *limit > 0 {
+		opts.Deadline = time.Now().Add(*limit)
+	}
+
+	f := newFetcher(cfg, database, newClient(cfg), newFeatures(cfg, database), nil)
+	if err := f.Backfill(ctx, b, opts); err != nil {
+		log.Fatalf("Backfill stopped at %s: %v (run the same command to resume)", b.NextDay.Format("2006-01-02"), err)
+	}
+	if !b.Done() {
+		log.Printf("Time limit reached at %s; run the same command to resume", b.NextDay.Format("2006-01-02"))
+		return
+	}
+	log.Printf("Backfill of %s done: %d fetched, %d stored", b.Category, b.Fetched, b.Stored)
+}
 
-	log.Printf("Scheduled fetch: stored %d papers", count)
+// libraryPapers returns every library paper, or those with one of the
+// comma-separated tags
+func libraryPapers(database *db.DB, tags string) ([]models.Paper, error) {
+	names := []string{""}
+	if tags != "" {
+		names = strings.Split(tags, ",")
+	}
+
+	var papers []models.Paper
+	seen := make(map[string]bool)
+	for _, name := range names {
+		found, _, err := database.GetPapers(models.SearchParams{
+			InLibrary: true,
+			Tag:       strings.TrimSpace(name),
+			Page:      1,
+			PageSize:  math.MaxInt32,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range found {
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				papers = append(papers, p)
+			}
+		}
+	}
+	return papers, nil
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/fetcher"
+	"github.com/ngx/arxiv-go-nest/internal/scheduler"
+	"github.com/ngx/arxiv-go-nest/internal/version"
+)
+
+// reloadable are the settings a reload applies, by YAML path; a section
+// name covers all of its settings. Anything else changed in the file is
+// only picked up on restart.
+var reloadable = []string{
+	"arxiv.categories",
+	"arxiv.keywords",
+	"arxiv.subscription_tags",
+	"arxiv.max_results",
+	"arxiv.page_size",
+	"arxiv.fetch_interval",
+	"notifications",
+	"reading_log",
+	"database.trash_retention_days",
+	"database.max_papers",
+	"database.max_size_mb",
+	"updates.interval",
+}
+
+// reloader re-reads the configuration file of a running server and applies
+// the subscriptions, fetch settings, notification channels and job
+// schedules, leaving the HTTP server and its connections alone
+type reloader struct {
+	mu   sync.Mutex
+	path string
+	// started is the configuration the server started with, for telling
+	// which changes still need a restart
+	started *config.Config
+
+	database *db.DB
+	fetcher  *fetcher.Fetcher
+	flags    *features.Flags
+	sched    *scheduler.Scheduler
+	updates  *version.Checker
+}
+
+// Reload applies the configuration file and returns the changed settings
+// that need a restart. An invalid file is rejected as a whole, leaving the
+// running configuration in place.
+func (r *reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		log.Printf("Not reloading the configuration: %v", err)
+		return nil, err
+	}
+	notifier, err := newNotifier(cfg, r.database)
+	if err != nil {
+		err = fmt.Errorf("failed to configure notifications: %w", err)
+		log.Printf("Not reloading the configuration: %v", err)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r.fetcher.Reload(ctx, cfg, notifier)
+	if err := r.sched.SetJobs(schedulerJobs(cfg, r.database, r.fetcher, r.flags, r.updates)...); err != nil {
+		log.Printf("Error rescheduling jobs: %v", err)
+		return nil, err
+	}
+
+	restart := needsRestart(r.started.Changed(cfg))
+	log.Printf("Reloaded the configuration from %s", r.path)
+	if len(restart) > 0 {
+		log.Printf("Restart to apply the changes to %s", strings.Join(restart, ", "))
+	}
+	return restart, nil
+}
+
+// needsRestart returns the changed settings a reload doesn't apply
+func needsRestart(changed []string) []string {
+	var restart []string
+	for _, setting := range changed {
+		applied := false
+		for _, r := range reloadable {
+			if setting == r || strings.HasPrefix(setting, r+".") {
+				applied = true
+				break
+			}
+		}
+		if !applied {
+			restart = append(restart, setting)
+		}
+	}
+	return restart
+}
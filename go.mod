@@ -8,3 +8,5 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.32
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/net v0.34.0
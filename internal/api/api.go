@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/hooks"
+)
+
+// Version is the API version, used in the mount path and the OpenAPI document
+const Version = "v1"
+
+// BasePath is where the API is mounted
+const BasePath = "/api/" + Version
+
+// API serves the versioned JSON API. Every route is declared as an Endpoint,
+// which both registers the handler and describes it in the OpenAPI document,
+// so the published spec cannot drift from what is actually served.
+type API struct {
+	config    *config.Config
+	db        *db.DB
+	hooks     *hooks.Runner
+	endpoints []Endpoint
+}
+
+// Endpoint describes a single API operation
+type Endpoint struct {
+	Method      string
+	Path        string // chi pattern relative to BasePath, e.g. "/papers/{id}"
+	OperationID string
+	Summary     string
+	Params      []Param
+	Request     interface{} // zero value of the JSON request body, if any
+	Response    interface{} // zero value of the success response body
+	Handler     http.HandlerFunc
+}
+
+// Param describes a path or query parameter
+type Param struct {
+	Name        string
+	In          string // "path" or "query"
+	Type        string // "string", "integer" or "boolean"
+	Description string
+	Required    bool
+}
+
+// Error is the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// New creates the API. The hook runner may be nil.
+func New(cfg *config.Config, database *db.DB, hookRunner *hooks.Runner) *API {
+	a := &API{
+		config: cfg,
+		db:     database,
+		hooks:  hookRunner,
+	}
+	a.endpoints = a.routes()
+	return a
+}
+
+// Endpoints returns the declared operations
+func (a *API) Endpoints() []Endpoint {
+	return a.endpoints
+}
+
+// Router returns a router serving every endpoint plus the OpenAPI document
+// and the Swagger UI page
+func (a *API) Router() chi.Router {
+	r := chi.NewRouter()
+	for _, e := range a.endpoints {
+		r.Method(e.Method, e.Path, e.Handler)
+	}
+
+	r.Get("/openapi.json", a.handleSpec)
+	r.Get("/docs", handleDocs)
+
+	return r
+}
+
+// handleSpec serves the generated OpenAPI document
+func (a *API) handleSpec(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.Spec())
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an Error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Error{Error: message})
+}
+
+// writeServerError writes the Error response for a request that failed
+// with err: 503 with Retry-After while the database is busy, else 500
+func writeServerError(w http.ResponseWriter, message string, err error) {
+	if db.IsBusy(err) {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, "database is busy, try again shortly")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func setupTestAPI(t *testing.T) (*API, *db.DB) {
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	paper := &models.Paper{
+		ID:          "2401.00001",
+		Title:       "Test Paper",
+		Abstract:    "An abstract.",
+		Authors:     "Alice Smith, Bob Jones",
+		Categories:  "cs.AI, cs.LG",
+		PublishedAt: time.Now(),
+		UpdatedAt:   time.Now(),
+		PDFUrl:      "https://arxiv.org/pdf/2401.00001",
+		ArxivUrl:    "https://arxiv.org/abs/2401.00001",
+	}
+	if err := testDB.UpsertPaper(paper); err != nil {
+		t.Fatalf("Failed to insert paper: %v", err)
+	}
+
+	cfg := &config.Config{UI: config.UIConfig{PageSize: 10}}
+	return New(cfg, testDB, nil), testDB
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	a, _ := setupTestAPI(t)
+	spec := a.Spec()
+
+	paths := spec["paths"].(map[string]interface{})
+	err := chi.Walk(a.Router(), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route == "/openapi.json" || route == "/docs" {
+			return nil
+		}
+		item, ok := paths[route].(map[string]interface{})
+		if !ok {
+			t.Errorf("Route %s missing from spec", route)
+			return nil
+		}
+		if _, ok := item[strings.ToLower(method)]; !ok {
+			t.Errorf("Operation %s %s missing from spec", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	// The document must be valid JSON with the response types as components
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("Failed to marshal spec: %v", err)
+	}
+	for _, name := range []string{`"Paper"`, `"PaperList"`, `"Error"`, `"date-time"`, `"SearchRequest"`, `"requestBody"`} {
+		if !strings.Contains(string(data), name) {
+			t.Errorf("Expected spec to contain %s", name)
+		}
+	}
+}
+
+func TestListAndSave(t *testing.T) {
+	a, _ := setupTestAPI(t)
+	router := a.Router()
+
+	req := httptest.NewRequest("PUT", "/library/2401.00001", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/library", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var list PaperList
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Total != 1 || len(list.Papers) != 1 {
+		t.Fatalf("Expected 1 library paper, got %+v", list)
+	}
+	if got := list.Papers[0].Authors; len(got) != 2 || got[1] != "Bob Jones" {
+		t.Errorf("Expected split authors, got %v", got)
+	}
+
+	for query, want := range map[string]int{"read_state=unread": 1, "read_state=read": 0, "note=with": 0, "note=without": 1} {
+		req = httptest.NewRequest("GET", "/papers?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		list = PaperList{}
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if list.Total != want {
+			t.Errorf("Expected %d papers for %s, got %d", want, query, list.Total)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/papers/9999.99999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown paper, got %d", w.Code)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	a, _ := setupTestAPI(t)
+	router := a.Router()
+
+	search := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for body, want := range map[string]int{
+		`{}`: 1,
+		`{"filter": {"all": [{"field": "category", "op": "in", "value": ["cs.LG", "cs.CV"]}, {"field": "authors", "op": "contains", "value": "bob"}]}}`: 1,
+		`{"filter": {"not": {"field": "category", "op": "eq", "value": "cs.AI"}}}`:                                                                      0,
+		`{"filter": {"field": "id", "op": "eq", "value": "2401.00001"}, "in_library": true}`:                                                            0,
+	} {
+		w := search(body)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d: %s", body, w.Code, w.Body.String())
+			continue
+		}
+		var list PaperList
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if list.Total != want {
+			t.Errorf("%s: expected %d papers, got %d", body, want, list.Total)
+		}
+	}
+
+	for body, message := range map[string]string{
+		`{"filter": {"field": "colour", "op": "eq", "value": "red"}}`: "filter.colour",
+		`{"query": "transformers"}`:                                   "unknown field",
+		`{"sort": "random"}`:                                          "sort",
+		`{"filter": `:                                                 "invalid request body",
+	} {
+		w := search(body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), message) {
+			t.Errorf("%s: expected 400 mentioning %q, got %d: %s", body, message, w.Code, w.Body.String())
+		}
+	}
+}
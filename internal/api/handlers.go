@@ -0,0 +1,362 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/hooks"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+	"github.com/ngx/arxiv-go-nest/internal/version"
+)
+
+// maxPageSize caps the page_size query parameter
+const maxPageSize = 100
+
+// maxSearchBody caps the size of a structured search request in bytes
+const maxSearchBody = 64 << 10
+
+// Paper is the API representation of a paper
+type Paper struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Abstract    string    `json:"abstract"`
+	Words       int       `json:"abstract_words"`
+	Authors     []string  `json:"authors"`
+	Categories  []string  `json:"categories"`
+	PublishedAt time.Time `json:"published_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	PDFURL      string    `json:"pdf_url"`
+	ArxivURL    string    `json:"arxiv_url"`
+	HTMLURL     string    `json:"html_url,omitempty"`
+	License     string    `json:"license,omitempty"` // license URL
+	InLibrary   bool      `json:"in_library"`
+	IsRead      bool      `json:"is_read"`
+	Priority    int       `json:"priority,omitempty"` // 0 none, 1 low, 2 medium, 3 high
+	Note        string    `json:"note,omitempty"`     // why the paper was saved
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+// PaperList is a page of papers
+type PaperList struct {
+	Papers     []Paper `json:"papers"`
+	Total      int     `json:"total"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+	TotalPages int     `json:"total_pages"`
+}
+
+// Tag is a user-defined tag
+type Tag struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SearchRequest is the body of a structured search. Filter is a tree of
+// conditions: {"all": [...]}, {"any": [...]} and {"not": {...}} combine
+// others, and {"field": ..., "op": ..., "value": ...} compares a field.
+// Text fields (query, title, abstract, authors, comment, journal_ref) take
+// "contains"; id, category, tag, entity and license take "eq" or "in", and
+// tag also "all"; published, updated and saved take "gte", "lte" or
+// "between" with YYYY-MM-DD days; abstract_words and priority take "eq",
+// "gte", "lte" or "between"; in_library, read and has_note take "eq" with
+// a boolean.
+type SearchRequest struct {
+	Filter    search.Filter `json:"filter"`
+	InLibrary bool          `json:"in_library,omitempty"` // only saved papers
+	Sort      string        `json:"sort,omitempty"`       // published (default), title, priority or length
+	Order     string        `json:"order,omitempty"`      // desc (default) or asc
+	Page      int           `json:"page,omitempty"`
+	PageSize  int           `json:"page_size,omitempty"`
+}
+
+// searchSorts are the sort fields a structured search accepts
+var searchSorts = map[string]bool{"published": true, "title": true, "priority": true, "length": true}
+
+// LibraryStatus reports a paper's library state after a change
+type LibraryStatus struct {
+	ID        string `json:"id"`
+	InLibrary bool   `json:"in_library"`
+}
+
+// listParams are the query parameters shared by the list endpoints
+var listParams = []Param{
+	{Name: "q", In: "query", Type: "string", Description: "Search in title, abstract and authors"},
+	{Name: "tag", In: "query", Type: "string", Description: "Only papers with this tag"},
+	{Name: "category", In: "query", Type: "string", Description: "Only papers in this arXiv category"},
+	{Name: "length", In: "query", Type: "string", Description: "Abstract length: short, medium or long"},
+	{Name: "license", In: "query", Type: "string", Description: "License filter: cc-by, cc, cc0, arxiv or unknown"},
+	{Name: "entity", In: "query", Type: "string", Description: "Only papers mentioning this dataset or benchmark, e.g. KITTI"},
+	{Name: "read_state", In: "query", Type: "string", Description: "Only saved papers in this read state: read or unread"},
+	{Name: "note", In: "query", Type: "string", Description: "Only saved papers with or without a note: with or without"},
+	{Name: "min_priority", In: "query", Type: "integer", Description: "Only saved papers with at least this priority, 1 (low) to 3 (high)"},
+	{Name: "saved_from", In: "query", Type: "string", Description: "Only papers saved on or after this day, YYYY-MM-DD"},
+	{Name: "saved_to", In: "query", Type: "string", Description: "Only papers saved on or before this day, YYYY-MM-DD"},
+	{Name: "page", In: "query", Type: "integer", Description: "Page number, starting at 1"},
+	{Name: "page_size", In: "query", Type: "integer", Description: "Results per page (max 100)"},
+}
+
+// idParam is the paper ID path parameter
+var idParam = Param{Name: "id", In: "path", Type: "string", Description: "Paper ID: an arXiv ID, or a namespaced ID such as biorxiv:2024.01.15.575123", Required: true}
+
+// routes declares every API operation
+func (a *API) routes() []Endpoint {
+	return []Endpoint{
+		{
+			Method: http.MethodGet, Path: "/papers", OperationID: "listPapers",
+			Summary: "List and search papers", Params: listParams,
+			Response: PaperList{}, Handler: a.listPapers(false),
+		},
+		{
+			Method: http.MethodPost, Path: "/search", OperationID: "searchPapers",
+			Summary: "Search papers with a structured filter", Request: SearchRequest{},
+			Response: PaperList{}, Handler: a.searchPapers,
+		},
+		{
+			Method: http.MethodGet, Path: "/papers/{id}", OperationID: "getPaper",
+			Summary: "Get a paper with its tags", Params: []Param{idParam},
+			Response: Paper{}, Handler: a.getPaper,
+		},
+		{
+			Method: http.MethodGet, Path: "/library", OperationID: "listLibrary",
+			Summary: "List and search saved papers", Params: listParams,
+			Response: PaperList{}, Handler: a.listPapers(true),
+		},
+		{
+			Method: http.MethodPut, Path: "/library/{id}", OperationID: "savePaper",
+			Summary: "Save a paper to the library", Params: []Param{idParam},
+			Response: LibraryStatus{}, Handler: a.setInLibrary(true),
+		},
+		{
+			Method: http.MethodDelete, Path: "/library/{id}", OperationID: "removePaper",
+			Summary: "Remove a paper from the library", Params: []Param{idParam},
+			Response: LibraryStatus{}, Handler: a.setInLibrary(false),
+		},
+		{
+			Method: http.MethodGet, Path: "/tags", OperationID: "listTags",
+			Summary: "List all tags", Response: []Tag{}, Handler: a.listTags,
+		},
+		{
+			Method: http.MethodGet, Path: "/version", OperationID: "getVersion",
+			Summary: "Get the server's version and build details", Response: version.Info{}, Handler: getVersion,
+		},
+	}
+}
+
+// listPapers returns a handler listing papers, optionally only the library
+func (a *API) listPapers(inLibrary bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pageSize := intParam(r.URL.Query().Get("page_size"), a.config.UI.PageSize)
+		if pageSize > maxPageSize {
+			pageSize = maxPageSize
+		}
+
+		params := search.ParseParams(r.URL.Query())
+		params.InLibrary = inLibrary
+		params.PageSize = pageSize
+		params.SortBy = "published"
+		params.SortOrder = "desc"
+		page := params.Page
+
+		papers, total, err := a.db.GetPapers(params)
+		if err != nil {
+			writeServerError(w, "failed to fetch papers", err)
+			log.Printf("Error fetching papers: %v", err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newPaperList(papers, total, page, pageSize))
+	}
+}
+
+// newPaperList returns a page of papers in their API representation
+func newPaperList(papers []models.Paper, total, page, pageSize int) PaperList {
+	list := PaperList{
+		Papers:     make([]Paper, 0, len(papers)),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	}
+	for i := range papers {
+		list.Papers = append(list.Papers, toPaper(&papers[i]))
+	}
+	return list
+}
+
+// searchPapers lists the papers matching a structured filter posted as JSON
+func (a *API) searchPapers(w http.ResponseWriter, r *http.Request) {
+	var req SearchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSearchBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	pageSize := req.PageSize
+	if pageSize < 1 {
+		pageSize = a.config.UI.PageSize
+	}
+	params := models.SearchParams{
+		InLibrary: req.InLibrary,
+		Page:      max(req.Page, 1),
+		PageSize:  min(pageSize, maxPageSize),
+		SortBy:    "published",
+		SortOrder: "desc",
+	}
+	if req.Sort != "" {
+		if !searchSorts[req.Sort] {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown sort %q", req.Sort))
+			return
+		}
+		params.SortBy = req.Sort
+	}
+	switch req.Order {
+	case "", "desc":
+	case "asc":
+		params.SortOrder = "asc"
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown order %q", req.Order))
+		return
+	}
+
+	papers, total, err := a.db.SearchPapers(req.Filter, params)
+	if errors.Is(err, db.ErrInvalidFilter) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeServerError(w, "failed to search papers", err)
+		log.Printf("Error searching papers: %v", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPaperList(papers, total, params.Page, params.PageSize))
+}
+
+// getPaper returns a single paper
+func (a *API) getPaper(w http.ResponseWriter, r *http.Request) {
+	paper, err := a.db.GetPaperByID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "paper not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toPaper(paper))
+}
+
+// setInLibrary returns a handler adding or removing a paper from the library
+func (a *API) setInLibrary(save bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		exists, err := a.db.PaperExists(id)
+		if err != nil {
+			writeServerError(w, "failed to fetch paper", err)
+			log.Printf("Error checking paper %s: %v", id, err)
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, "paper not found")
+			return
+		}
+
+		if save {
+			err = a.db.SaveToLibrary(id)
+		} else {
+			err = a.db.RemoveFromLibrary(id)
+		}
+		if err != nil {
+			writeServerError(w, "failed to update library", err)
+			log.Printf("Error updating library: %v", err)
+			return
+		}
+		if save && a.hooks != nil {
+			if paper, err := a.db.GetPaperByID(id); err != nil {
+				log.Printf("Error fetching paper %s for hooks: %v", id, err)
+			} else {
+				a.hooks.Fire(hooks.Event{Name: hooks.PaperSaved, Paper: hooks.NewPaper(paper)})
+			}
+		}
+
+		writeJSON(w, http.StatusOK, LibraryStatus{ID: id, InLibrary: save})
+	}
+}
+
+// listTags returns all tags
+func (a *API) listTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := a.db.GetAllTags()
+	if err != nil {
+		writeServerError(w, "failed to fetch tags", err)
+		log.Printf("Error fetching tags: %v", err)
+		return
+	}
+
+	result := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, Tag{ID: tag.ID, Name: tag.Name, Description: tag.Description})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// getVersion returns the build details of the server
+func getVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+// toPaper converts a stored paper to its API representation
+func toPaper(p *models.Paper) Paper {
+	paper := Paper{
+		ID:          p.ID,
+		Title:       p.Title,
+		Abstract:    p.Abstract,
+		Words:       p.AbstractWords,
+		Authors:     splitList(p.Authors),
+		Categories:  splitList(p.Categories),
+		PublishedAt: p.PublishedAt,
+		UpdatedAt:   p.UpdatedAt,
+		PDFURL:      p.PDFUrl,
+		ArxivURL:    p.ArxivUrl,
+		HTMLURL:     p.HTMLURL,
+		License:     p.License,
+		InLibrary:   p.InLibrary,
+		IsRead:      p.IsRead,
+		Priority:    p.Priority,
+		Note:        p.Note,
+	}
+	for _, tag := range p.Tags {
+		paper.Tags = append(paper.Tags, tag.Name)
+	}
+	return paper
+}
+
+// splitList splits a comma-separated column into its values
+func splitList(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// intParam parses a positive integer query parameter
+func intParam(value string, defaultValue int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPIVersion is the OpenAPI specification version the document follows
+const openAPIVersion = "3.0.3"
+
+// Spec generates the OpenAPI document from the declared endpoints.
+// Request and response schemas are derived from the Go types by
+// reflection, using their json tags.
+func (a *API) Spec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	errorSchema := schemaFor(reflect.TypeOf(Error{}), schemas)
+
+	for _, e := range a.endpoints {
+		params := make([]interface{}, 0, len(e.Params))
+		for _, p := range e.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      map[string]interface{}{"type": p.Type},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"operationId": e.OperationID,
+			"summary":     e.Summary,
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": schemaFor(reflect.TypeOf(e.Response), schemas),
+						},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": errorSchema,
+						},
+					},
+				},
+			},
+		}
+
+		if e.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaFor(reflect.TypeOf(e.Request), schemas),
+					},
+				},
+			}
+		}
+
+		item, ok := paths[e.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[e.Path] = item
+		}
+		item[strings.ToLower(e.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "ArXiv Nest API",
+			"description": "JSON API for browsing papers and managing the library",
+			"version":     Version,
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": BasePath}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// timeType is handled as a string rather than a struct
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the JSON schema of t. Named structs are added to
+// schemas and referenced, so shared types appear once in the document.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		schema := schemaFor(t.Elem(), schemas)
+		schema["nullable"] = true
+		return schema
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case t.Kind() == reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+
+		// Reserve the name first so recursive types terminate
+		schemas[t.Name()] = map[string]interface{}{}
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, omitempty := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			properties[name] = schemaFor(field.Type, schemas)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	}
+
+	return map[string]interface{}{}
+}
+
+// jsonName returns a field's JSON name and whether it is omitted when empty
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
@@ -0,0 +1,107 @@
+package arxivtest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// feedTemplate renders papers the way the arXiv API does, extensions and
+// all
+var feedTemplate = template.Must(template.New("feed").Funcs(template.FuncMap{
+	"xml":  xmlEscape,
+	"time": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"list": func(s string) []string {
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	},
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:opensearch="http://a9.com/-/spec/opensearch/1.1/" xmlns:arxiv="http://arxiv.org/schemas/atom">
+  <link href="{{xml .Self}}" rel="self" type="application/atom+xml"/>
+  <title type="html">ArXiv Query: {{xml .Self}}</title>
+  <id>http://arxiv.org/api/arxivtest</id>
+  <updated>{{time .Updated}}</updated>
+  <opensearch:totalResults>{{.Total}}</opensearch:totalResults>
+  <opensearch:startIndex>{{.Start}}</opensearch:startIndex>
+  <opensearch:itemsPerPage>{{len .Papers}}</opensearch:itemsPerPage>
+{{- range .Papers}}
+  <entry>
+    <id>http://arxiv.org/abs/{{.ID}}v1</id>
+    <updated>{{time .UpdatedAt}}</updated>
+    <published>{{time .PublishedAt}}</published>
+    <title>{{xml .Title}}</title>
+    <summary>{{xml .Abstract}}</summary>
+{{- range list .Authors}}
+    <author>
+      <name>{{xml .}}</name>
+    </author>
+{{- end}}
+{{- if .Comment}}
+    <arxiv:comment>{{xml .Comment}}</arxiv:comment>
+{{- end}}
+    <link href="http://arxiv.org/abs/{{.ID}}v1" rel="alternate" type="text/html"/>
+    <link title="pdf" href="http://arxiv.org/pdf/{{.ID}}v1" rel="related" type="application/pdf"/>
+{{- if .License}}
+    <arxiv:license>{{xml .License}}</arxiv:license>
+{{- end}}
+{{- $categories := list .Categories}}
+{{- range $categories}}
+    <category term="{{xml .}}" scheme="http://arxiv.org/schemas/atom"/>
+{{- end}}
+{{- if $categories}}
+    <arxiv:primary_category term="{{xml (index $categories 0)}}" scheme="http://arxiv.org/schemas/atom"/>
+{{- end}}
+  </entry>
+{{- end}}
+</feed>
+`))
+
+// writeFeed writes one page of the papers matching a query as an Atom feed
+func writeFeed(w io.Writer, self string, total, start int, papers []*models.Paper) error {
+	return feedTemplate.Execute(w, struct {
+		Self    string
+		Updated time.Time
+		Total   int
+		Start   int
+		Papers  []*models.Paper
+	}{self, time.Now(), total, start, papers})
+}
+
+// xmlEscape escapes text for XML content and attributes
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// sortPapers sorts query results as arXiv does: by submission or last
+// update, newest first unless sortOrder is "ascending". Relevance keeps
+// the order of the papers.
+func sortPapers(papers []*models.Paper, sortBy, sortOrder string) {
+	var key func(p *models.Paper) time.Time
+	switch sortBy {
+	case "relevance":
+		return
+	case "lastUpdatedDate":
+		key = func(p *models.Paper) time.Time { return p.UpdatedAt }
+	default:
+		key = func(p *models.Paper) time.Time { return p.PublishedAt }
+	}
+	sort.SliceStable(papers, func(i, j int) bool {
+		if sortOrder == "ascending" {
+			return key(papers[i]).Before(key(papers[j]))
+		}
+		return key(papers[i]).After(key(papers[j]))
+	})
+}
@@ -0,0 +1,279 @@
+// Package arxivtest is a stand-in for the arXiv API, for end-to-end tests
+// and local development without touching the real one. It answers
+// queries from generated papers, or with a canned feed, and can be made
+// slow, unreliable or strict about its rate limit.
+package arxivtest
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/fixtures"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+const (
+	// defaultMaxResults is how many results arXiv returns without max_results
+	defaultMaxResults = 10
+
+	// maxResultsLimit is the most results arXiv returns for one request
+	maxResultsLimit = 2000
+
+	// submittedFormat is the format of submittedDate ranges in queries
+	submittedFormat = "200601021504"
+)
+
+// Options configure a Server
+type Options struct {
+	// Papers is how many generated papers queries are answered from, at
+	// most fixtures.MaxPapers
+	Papers int
+
+	// Feed, if set, is an Atom feed served as-is for every query
+	Feed []byte
+
+	// Latency delays every response
+	Latency time.Duration
+
+	// ErrorRate is the fraction of requests, from 0 to 1, failed with
+	// ErrorStatus (503 if unset)
+	ErrorRate   float64
+	ErrorStatus int
+
+	// RateLimit is the least time between requests: requests arriving
+	// sooner after the previous one are refused with 429 as arXiv does
+	RateLimit time.Duration
+}
+
+// Server serves the arXiv API on /api/query and HTML renderings on
+// /html/{id}. Point the client at it with SetBaseURLs and SetHTMLBaseURL.
+// It is safe for concurrent use.
+type Server struct {
+	opts   Options
+	papers []*models.Paper
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	last     time.Time
+	requests int
+}
+
+// New creates a server whose generated papers are dated relative to now.
+// Failed requests are picked at random, the same ones on every run.
+func New(opts Options, now time.Time) *Server {
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = http.StatusServiceUnavailable
+	}
+	s := &Server{
+		opts:   opts,
+		papers: fixtures.Papers(opts.Papers, now),
+		mux:    http.NewServeMux(),
+		rng:    rand.New(rand.NewSource(1)),
+	}
+	s.mux.HandleFunc("GET /api/query", s.handleQuery)
+	s.mux.HandleFunc("GET /html/{id}", s.handleHTML)
+	return s
+}
+
+// Papers returns the generated papers queries are answered from
+func (s *Server) Papers() []*models.Paper {
+	return s.papers
+}
+
+// Requests returns how many requests the server has received, including
+// refused and failed ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := s.admit()
+	if status == http.StatusTooManyRequests {
+		http.Error(w, "Rate exceeded.", status)
+		return
+	}
+
+	if s.opts.Latency > 0 {
+		select {
+		case <-time.After(s.opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// admit counts a request and decides whether it is refused for breaking
+// the rate limit, failed or served
+func (s *Server) admit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	now := time.Now()
+	early := s.opts.RateLimit > 0 && !s.last.IsZero() && now.Sub(s.last) < s.opts.RateLimit
+	s.last = now
+	switch {
+	case early:
+		return http.StatusTooManyRequests
+	case s.opts.ErrorRate > 0 && s.rng.Float64() < s.opts.ErrorRate:
+		return s.opts.ErrorStatus
+	default:
+		return http.StatusOK
+	}
+}
+
+// handleQuery answers an API query: papers matching search_query, or
+// those listed in id_list, sorted and paged as asked
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Feed != nil {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write(s.opts.Feed)
+		return
+	}
+
+	q := r.URL.Query()
+	start, err := queryInt(q.Get("start"), 0)
+	if err != nil || start < 0 {
+		http.Error(w, "start must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	maxResults, err := queryInt(q.Get("max_results"), defaultMaxResults)
+	if err != nil || maxResults < 0 {
+		http.Error(w, "max_results must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	maxResults = min(maxResults, maxResultsLimit)
+
+	var papers []*models.Paper
+	if ids := q.Get("id_list"); ids != "" {
+		papers = s.byID(strings.Split(ids, ","))
+	} else {
+		search := parseQuery(q.Get("search_query"))
+		for _, p := range s.papers {
+			if search.matches(p) {
+				papers = append(papers, p)
+			}
+		}
+		sortPapers(papers, q.Get("sortBy"), q.Get("sortOrder"))
+	}
+
+	page := papers[min(start, len(papers)):min(start+maxResults, len(papers))]
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if err := writeFeed(w, r.URL.String(), len(papers), start, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleHTML answers whether a paper has an HTML rendering, which half the
+// generated papers have
+func (s *Server) handleHTML(w http.ResponseWriter, r *http.Request) {
+	for _, p := range s.byID([]string{r.PathValue("id")}) {
+		if p.HTMLURL != "" {
+			fmt.Fprintf(w, "<html><body><h1>%s</h1></body></html>", template.HTMLEscapeString(p.Title))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// byID returns the papers with the given IDs, in order, ignoring versions
+// and IDs that aren't known
+func (s *Server) byID(ids []string) []*models.Paper {
+	var papers []*models.Paper
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if i := strings.LastIndex(id, "v"); i > 0 {
+			if _, err := strconv.Atoi(id[i+1:]); err == nil {
+				id = id[:i]
+			}
+		}
+		for _, p := range s.papers {
+			if p.ID == id {
+				papers = append(papers, p)
+				break
+			}
+		}
+	}
+	return papers
+}
+
+// queryInt parses an integer query parameter, with a default if missing
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// query is a parsed search_query: terms that must all match, each a list
+// of alternatives such as "cat:cs.LG"
+type query [][]string
+
+// parseQuery splits a search_query into its terms. It understands the
+// queries the client builds: AND of terms, each term a field or a
+// parenthesized OR of fields.
+func parseQuery(s string) query {
+	var q query
+	for _, term := range strings.Split(s, " AND ") {
+		term = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(term), "("), ")")
+		q = append(q, strings.Split(term, " OR "))
+	}
+	return q
+}
+
+// matches reports whether a paper matches every term of the query
+func (q query) matches(p *models.Paper) bool {
+	for _, term := range q {
+		if !slices.ContainsFunc(term, func(field string) bool { return fieldMatches(field, p) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldMatches reports whether a paper matches one field of a query, such
+// as "cat:cs.LG", "all:diffusion" or "submittedDate:[A TO B]". Fields the
+// server doesn't know match nothing.
+func fieldMatches(field string, p *models.Paper) bool {
+	name, value, _ := strings.Cut(strings.TrimSpace(field), ":")
+	value = strings.ToLower(strings.Trim(value, `"`))
+	switch name {
+	case "cat":
+		for _, c := range strings.Split(p.Categories, ",") {
+			if strings.ToLower(strings.TrimSpace(c)) == value {
+				return true
+			}
+		}
+		return false
+	case "all":
+		return value == "*" || strings.Contains(strings.ToLower(p.Title+" "+p.Abstract+" "+p.Authors), value)
+	case "ti":
+		return strings.Contains(strings.ToLower(p.Title), value)
+	case "abs":
+		return strings.Contains(strings.ToLower(p.Abstract), value)
+	case "au":
+		return strings.Contains(strings.ToLower(p.Authors), value)
+	case "submittedDate":
+		from, to, ok := strings.Cut(strings.Trim(value, "[]"), " to ")
+		submitted := p.PublishedAt.UTC().Format(submittedFormat)
+		return ok && submitted >= from && submitted <= to
+	default:
+		return false
+	}
+}
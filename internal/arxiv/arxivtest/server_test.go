@@ -0,0 +1,146 @@
+package arxivtest
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
+)
+
+func TestServer(t *testing.T) {
+	fake := New(Options{Papers: 40}, time.Now())
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := arxiv.NewClient(0)
+	client.SetBaseURLs([]string{server.URL + "/api/query"}, 1)
+	client.SetHTMLBaseURL(server.URL + "/html/")
+	ctx := context.Background()
+
+	want := 0
+	for _, p := range fake.Papers() {
+		if strings.Contains(p.Categories, "cs.CL") {
+			want++
+		}
+	}
+
+	// Pages of a category search add up to every paper in it, newest first
+	seen := make(map[string]bool)
+	var last time.Time
+	for start := 0; ; start += 5 {
+		feed, err := client.FetchNew(ctx, arxiv.FetchParams{Categories: []string{"cs.CL"}, MaxResults: 5, Start: start})
+		if err != nil {
+			t.Fatalf("FetchNew failed: %v", err)
+		}
+		if feed.TotalResults != want {
+			t.Fatalf("Expected %d results, got %d", want, feed.TotalResults)
+		}
+		if len(feed.Entries) == 0 {
+			break
+		}
+		for _, e := range feed.Entries {
+			p, err := e.ToPaper()
+			if err != nil {
+				t.Fatalf("ToPaper failed: %v", err)
+			}
+			if seen[p.ID] {
+				t.Errorf("Paper %s returned twice", p.ID)
+			}
+			seen[p.ID] = true
+			if !last.IsZero() && p.PublishedAt.After(last) {
+				t.Errorf("Paper %s is out of order", p.ID)
+			}
+			last = p.PublishedAt
+			if !strings.Contains(p.Categories, "cs.CL") {
+				t.Errorf("Paper %s isn't in cs.CL: %s", p.ID, p.Categories)
+			}
+		}
+	}
+	if len(seen) != want {
+		t.Errorf("Expected %d papers over all pages, got %d", want, len(seen))
+	}
+
+	// Lookups by ID, with and without versions
+	papers := fake.Papers()
+	feed, err := client.FetchByIDs(ctx, []string{papers[3].ID + "v2", papers[1].ID})
+	if err != nil {
+		t.Fatalf("FetchByIDs failed: %v", err)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("Expected 2 papers, got %d", len(feed.Entries))
+	}
+	if p, _ := feed.Entries[0].ToPaper(); p.ID != papers[3].ID || p.Title != papers[3].Title {
+		t.Errorf("Expected %s first, got %+v", papers[3].ID, p)
+	}
+
+	// HTML renderings
+	for _, p := range papers[:4] {
+		htmlURL, err := client.CheckHTML(ctx, p.ID)
+		if err != nil {
+			t.Fatalf("CheckHTML failed: %v", err)
+		}
+		if (htmlURL != "") != (p.HTMLURL != "") {
+			t.Errorf("Expected HTML for %s: %v, got %q", p.ID, p.HTMLURL != "", htmlURL)
+		}
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	down := New(Options{Papers: 5, ErrorRate: 1}, time.Now())
+	primary := httptest.NewServer(down)
+	defer primary.Close()
+	mirror := httptest.NewServer(New(Options{Papers: 5}, time.Now()))
+	defer mirror.Close()
+
+	// Errors fail over to the mirror
+	client := arxiv.NewClient(0)
+	client.SetBaseURLs([]string{primary.URL + "/api/query", mirror.URL + "/api/query"}, 1)
+	feed, err := client.FetchNew(context.Background(), arxiv.FetchParams{MaxResults: 10})
+	if err != nil {
+		t.Fatalf("FetchNew failed: %v", err)
+	}
+	if len(feed.Entries) != 5 {
+		t.Errorf("Expected 5 papers from the mirror, got %d", len(feed.Entries))
+	}
+	if down.Requests() != 1 {
+		t.Errorf("Expected 1 request to the failing host, got %d", down.Requests())
+	}
+
+	// Requests closer together than the rate limit are refused, and pass
+	// when the client keeps to it
+	strict := httptest.NewServer(New(Options{Papers: 5, RateLimit: 50 * time.Millisecond}, time.Now()))
+	defer strict.Close()
+	for _, delay := range []time.Duration{0, 60 * time.Millisecond} {
+		client := arxiv.NewClient(delay)
+		client.SetBaseURLs([]string{strict.URL + "/api/query"}, 1)
+		time.Sleep(60 * time.Millisecond)
+		var errs int
+		for range 2 {
+			if _, err := client.FetchNew(context.Background(), arxiv.FetchParams{MaxResults: 1}); err != nil {
+				errs++
+				if !strings.Contains(err.Error(), "429") {
+					t.Errorf("Expected a 429, got %v", err)
+				}
+			}
+		}
+		if want := map[bool]int{true: 1, false: 0}[delay == 0]; errs != want {
+			t.Errorf("Delay %v: expected %d refused requests, got %d", delay, want, errs)
+		}
+	}
+
+	// A canned feed is served as-is
+	canned := httptest.NewServer(New(Options{Feed: []byte(`<feed xmlns="http://www.w3.org/2005/Atom"><title>Canned</title></feed>`)}, time.Now()))
+	defer canned.Close()
+	client = arxiv.NewClient(0)
+	client.SetBaseURLs([]string{canned.URL + "/api/query"}, 1)
+	feed, err = client.FetchNew(context.Background(), arxiv.FetchParams{MaxResults: 1})
+	if err != nil {
+		t.Fatalf("FetchNew failed: %v", err)
+	}
+	if feed.Title != "Canned" {
+		t.Errorf("Expected the canned feed, got %q", feed.Title)
+	}
+}
@@ -0,0 +1,126 @@
+package arxiv
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// idBatchSize is how many IDs FetchByIDs puts in one id_list request. The
+// API rejects much longer lists, and long URLs break on some proxies.
+const idBatchSize = 100
+
+// validIDRegex matches new-style IDs (2401.01234, since 2007) and old-style
+// ones (hep-th/9901001, math.GT/0309136), each with an optional version
+var validIDRegex = regexp.MustCompile(`^(\d{4}\.\d{4,5}|[a-z]+(-[a-z]+)*(\.[A-Z]{2})?/\d{7})(v\d+)?$`)
+
+// ValidID reports whether id is a well-formed arXiv identifier
+func ValidID(id string) bool {
+	return validIDRegex.MatchString(id)
+}
+
+// BatchError reports the IDs FetchByIDs could not fetch. The feed returned
+// alongside it still holds every paper from the batches that succeeded.
+type BatchError struct {
+	// Invalid lists malformed IDs, which were never requested
+	Invalid []string
+	// Failed lists the batches whose request failed
+	Failed []FailedBatch
+	// Batches is the number of requests attempted
+	Batches int
+}
+
+// FailedBatch is one id_list request that failed
+type FailedBatch struct {
+	IDs []string
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	var parts []string
+	if len(e.Invalid) > 0 {
+		parts = append(parts, fmt.Sprintf("%d invalid IDs (%s)", len(e.Invalid), strings.Join(e.Invalid, ", ")))
+	}
+	if len(e.Failed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d batches failed: %v", len(e.Failed), e.Batches, e.Failed[0].Err))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap returns the errors of the failed batches
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, b := range e.Failed {
+		errs[i] = b.Err
+	}
+	return errs
+}
+
+// FailedIDs returns every ID that was not fetched, invalid ones first
+func (e *BatchError) FailedIDs() []string {
+	ids := append([]string(nil), e.Invalid...)
+	for _, b := range e.Failed {
+		ids = append(ids, b.IDs...)
+	}
+	return ids
+}
+
+// FetchByIDs fetches specific papers by their arXiv IDs. Duplicates are
+// dropped and the rest are requested in batches of idBatchSize. If some IDs
+// are malformed or some batches fail, the papers that were fetched are
+// returned together with a *BatchError describing the rest.
+func (c *Client) FetchByIDs(ctx context.Context, ids []string) (*Feed, error) {
+	batchErr := &BatchError{}
+	seen := make(map[string]bool)
+	var valid []string
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if !ValidID(id) {
+			batchErr.Invalid = append(batchErr.Invalid, id)
+			continue
+		}
+		valid = append(valid, id)
+	}
+
+	result := &Feed{}
+	for start := 0; start < len(valid); start += idBatchSize {
+		batch := valid[start:min(start+idBatchSize, len(valid))]
+		batchErr.Batches++
+
+		q := url.Values{}
+		q.Set("id_list", strings.Join(batch, ","))
+		q.Set("max_results", fmt.Sprintf("%d", len(batch)))
+
+		feed, err := c.query(ctx, q)
+		if err != nil {
+			batchErr.Failed = append(batchErr.Failed, FailedBatch{IDs: batch, Err: err})
+			if ctx.Err() != nil {
+				// Don't attempt the remaining batches, but report them as failed
+				for rest := start + idBatchSize; rest < len(valid); rest += idBatchSize {
+					batchErr.Batches++
+					batchErr.Failed = append(batchErr.Failed, FailedBatch{IDs: valid[rest:min(rest+idBatchSize, len(valid))], Err: ctx.Err()})
+				}
+				break
+			}
+			continue
+		}
+
+		if result.Title == "" {
+			result.Title, result.ID, result.Updated = feed.Title, feed.ID, feed.Updated
+		}
+		result.Entries = append(result.Entries, feed.Entries...)
+	}
+	result.TotalResults = len(result.Entries)
+
+	if len(batchErr.Invalid) > 0 || len(batchErr.Failed) > 0 {
+		return result, batchErr
+	}
+	return result, nil
+}
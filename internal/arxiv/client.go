@@ -7,89 +7,149 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
 )
 
 const (
 	// ArXiv API base URL
 	apiBaseURL = "http://export.arxiv.org/api/query"
-	
+
+	// Base URL of arXiv's HTML renderings
+	htmlBaseURL = "https://arxiv.org/html/"
+
+	// Base URLs of abstract pages and PDFs
+	absBaseURL = "https://arxiv.org/abs/"
+	pdfBaseURL = "https://arxiv.org/pdf/"
+
 	// Default timeout for HTTP requests
 	defaultTimeout = 30 * time.Second
+
+	// Default number of consecutive failures before switching to the next host
+	defaultFailoverThreshold = 3
 )
 
-// Client handles communication with the arXiv API
+// AbsURL returns the current abstract page URL of a paper
+func AbsURL(id string) string {
+	return absBaseURL + id
+}
+
+// PDFURL returns the current PDF URL of a paper
+func PDFURL(id string) string {
+	return pdfBaseURL + id
+}
+
+// Client handles communication with the arXiv API. It is safe for
+// concurrent use; create one per process so every caller shares its rate
+// limit and mirror failover state.
 type Client struct {
-	httpClient     *http.Client
-	rateLimitDelay time.Duration
+	httpClient  *http.Client
+	limiter     *rateLimiter
+	htmlBaseURL string
+
+	// Mirror failover state, guarded by mu
+	mu                sync.Mutex
+	baseURLs          []string
+	current           int
+	failures          int
+	failoverThreshold int
 }
 
-// NewClient creates a new arXiv API client
+// NewClient creates a new arXiv API client that sends at most one request
+// per rateLimitDelay
 func NewClient(rateLimitDelay time.Duration) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: defaultTimeout,
+			Timeout:   defaultTimeout,
+			Transport: telemetry.Transport(nil),
 		},
-		rateLimitDelay: rateLimitDelay,
+		limiter:           newRateLimiter(rateLimitDelay),
+		htmlBaseURL:       htmlBaseURL,
+		baseURLs:          []string{apiBaseURL},
+		failoverThreshold: defaultFailoverThreshold,
 	}
 }
 
+// SetBaseURLs configures the API hosts (mirrors or caching proxies) tried in order.
+// After threshold consecutive errors on the active host the client fails over to the next one.
+func (c *Client) SetBaseURLs(baseURLs []string, threshold int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var urls []string
+	for _, u := range baseURLs {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		urls = []string{apiBaseURL}
+	}
+	if threshold < 1 {
+		threshold = defaultFailoverThreshold
+	}
+
+	c.baseURLs = urls
+	c.current = 0
+	c.failures = 0
+	c.failoverThreshold = threshold
+}
+
+// SetHTMLBaseURL configures where CheckHTML looks for HTML renderings,
+// which are found by appending the paper ID. An empty URL keeps arXiv's.
+func (c *Client) SetHTMLBaseURL(baseURL string) {
+	if baseURL = strings.TrimSpace(baseURL); baseURL != "" {
+		c.htmlBaseURL = baseURL
+	}
+}
+
+// BaseURL returns the API host currently in use
+func (c *Client) BaseURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.baseURLs[c.current]
+}
+
 // FetchParams holds parameters for fetching papers
 type FetchParams struct {
 	Categories []string
 	Keywords   []string
 	MaxResults int
+	Start      int    // offset of the first result, for paging
 	SortBy     string // "submittedDate", "lastUpdatedDate", "relevance"
 	SortOrder  string // "ascending", "descending"
+
+	// SubmittedFrom and SubmittedTo, if set, restrict results to papers
+	// submitted in that range, inclusive, to the minute
+	SubmittedFrom time.Time
+	SubmittedTo   time.Time
 }
 
 // FetchNew fetches recent papers from arXiv based on the given parameters
 func (c *Client) FetchNew(ctx context.Context, params FetchParams) (*Feed, error) {
-	// Build search query
-	searchQuery := c.buildSearchQuery(params.Categories, params.Keywords)
-	
-	// Build URL with query parameters
-	apiURL, err := c.buildURL(searchQuery, params)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build URL: %w", err)
-	}
-
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set user agent
-	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
-
-	// Execute request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-	}
+	return c.query(ctx, c.buildQuery(c.buildSearchQuery(params), params))
+}
 
-	// Parse response
-	feed, err := ParseFeed(resp.Body)
+// CountResults returns the number of papers arXiv reports for the given
+// categories and keywords, without fetching any entries
+func (c *Client) CountResults(ctx context.Context, params FetchParams) (int, error) {
+	feed, err := c.query(ctx, c.buildQuery(c.SearchQuery(params), FetchParams{MaxResults: 0}))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse feed: %w", err)
+		return 0, err
 	}
+	return feed.TotalResults, nil
+}
 
-	// Respect rate limiting
-	time.Sleep(c.rateLimitDelay)
-
-	return feed, nil
+// SearchQuery returns the arXiv search_query used for the given parameters
+func (c *Client) SearchQuery(params FetchParams) string {
+	return c.buildSearchQuery(params)
 }
 
 // buildSearchQuery constructs the search query string
-func (c *Client) buildSearchQuery(categories []string, keywords []string) string {
+func (c *Client) buildSearchQuery(params FetchParams) string {
+	categories, keywords := params.Categories, params.Keywords
 	var parts []string
 
 	// Add category filters
@@ -118,6 +178,18 @@ func (c *Client) buildSearchQuery(categories []string, keywords []string) string
 		}
 	}
 
+	// Add the submission date range
+	if !params.SubmittedFrom.IsZero() || !params.SubmittedTo.IsZero() {
+		from, to := "000001010000", "999912312359"
+		if !params.SubmittedFrom.IsZero() {
+			from = params.SubmittedFrom.UTC().Format("200601021504")
+		}
+		if !params.SubmittedTo.IsZero() {
+			to = params.SubmittedTo.UTC().Format("200601021504")
+		}
+		parts = append(parts, fmt.Sprintf("submittedDate:[%s TO %s]", from, to))
+	}
+
 	// Default to all if no filters
 	if len(parts) == 0 {
 		return "all:*"
@@ -126,80 +198,158 @@ func (c *Client) buildSearchQuery(categories []string, keywords []string) string
 	return strings.Join(parts, " AND ")
 }
 
-// buildURL constructs the full API URL with parameters
-func (c *Client) buildURL(searchQuery string, params FetchParams) (string, error) {
-	u, err := url.Parse(apiBaseURL)
-	if err != nil {
-		return "", err
-	}
-
-	q := u.Query()
+// buildQuery constructs the API query parameters
+func (c *Client) buildQuery(searchQuery string, params FetchParams) url.Values {
+	q := url.Values{}
 	q.Set("search_query", searchQuery)
 	q.Set("max_results", fmt.Sprintf("%d", params.MaxResults))
-	
+	if params.Start > 0 {
+		q.Set("start", fmt.Sprintf("%d", params.Start))
+	}
+
 	// Set sort parameters
 	sortBy := params.SortBy
 	if sortBy == "" {
 		sortBy = "submittedDate"
 	}
 	q.Set("sortBy", sortBy)
-	
+
 	sortOrder := params.SortOrder
 	if sortOrder == "" {
 		sortOrder = "descending"
 	}
 	q.Set("sortOrder", sortOrder)
 
-	u.RawQuery = q.Encode()
-	return u.String(), nil
+	return q
 }
 
-// FetchByIDs fetches specific papers by their arXiv IDs
-func (c *Client) FetchByIDs(ctx context.Context, ids []string) (*Feed, error) {
-	if len(ids) == 0 {
-		return &Feed{}, nil
+// query executes an API request, trying each configured host in turn
+func (c *Client) query(ctx context.Context, q url.Values) (*Feed, error) {
+	c.mu.Lock()
+	hosts := make([]string, len(c.baseURLs))
+	for i := range c.baseURLs {
+		hosts[i] = c.baseURLs[(c.current+i)%len(c.baseURLs)]
 	}
+	c.mu.Unlock()
 
-	// Build ID list query
-	idList := strings.Join(ids, ",")
-	
-	u, err := url.Parse(apiBaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	var lastErr error
+	for _, host := range hosts {
+		feed, retryable, err := c.queryHost(ctx, host, q)
+		if err == nil {
+			c.recordSuccess(host)
+			return feed, nil
+		}
+		if !retryable || ctx.Err() != nil {
+			return nil, err
+		}
+
+		c.recordFailure(host)
+		lastErr = fmt.Errorf("%s: %w", host, err)
 	}
 
-	q := u.Query()
-	q.Set("id_list", idList)
+	return nil, lastErr
+}
+
+// queryHost executes a single API request against one host.
+// The returned bool reports whether the error is worth retrying on another host.
+func (c *Client) queryHost(ctx context.Context, baseURL string, q url.Values) (*Feed, bool, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to build URL: %w", err)
+	}
 	u.RawQuery = q.Encode()
 
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	// Set user agent
 	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
 
+	// Respect rate limiting
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, false, err
+	}
+
 	// Execute request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, true, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retryable, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
 	}
 
 	// Parse response
 	feed, err := ParseFeed(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse feed: %w", err)
+		return nil, true, fmt.Errorf("failed to parse feed: %w", err)
 	}
 
-	// Respect rate limiting
-	time.Sleep(c.rateLimitDelay)
+	return feed, false, nil
+}
+
+// recordSuccess resets the failure counter if host is the active one
+func (c *Client) recordSuccess(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.baseURLs[c.current] == host {
+		c.failures = 0
+	}
+}
+
+// recordFailure counts a failure against the active host and fails over
+// to the next host once the threshold is reached
+func (c *Client) recordFailure(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.baseURLs[c.current] != host {
+		return
+	}
+
+	c.failures++
+	if c.failures >= c.failoverThreshold && len(c.baseURLs) > 1 {
+		c.current = (c.current + 1) % len(c.baseURLs)
+		c.failures = 0
+	}
+}
+
+// CheckHTML reports the URL of a paper's HTML rendering, or an empty string
+// if arXiv has not produced one (e.g. the LaTeX source failed to convert)
+func (c *Client) CheckHTML(ctx context.Context, id string) (string, error) {
+	htmlURL := c.htmlBaseURL + url.PathEscape(id)
 
-	return feed, nil
+	req, err := http.NewRequestWithContext(ctx, "HEAD", htmlURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return htmlURL, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
 }
@@ -0,0 +1,257 @@
+package arxiv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const emptyFeedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>ArXiv Query</title>
+</feed>`
+
+func TestClientFailover(t *testing.T) {
+	primaryHits := 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	mirrorHits := 0
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits++
+		w.Write([]byte(emptyFeedXML))
+	}))
+	defer mirror.Close()
+
+	client := NewClient(0)
+	client.SetBaseURLs([]string{primary.URL, mirror.URL}, 2)
+
+	// First request falls through to the mirror but keeps the primary active
+	if _, err := client.FetchNew(context.Background(), FetchParams{MaxResults: 1}); err != nil {
+		t.Fatalf("FetchNew failed: %v", err)
+	}
+	if client.BaseURL() != primary.URL {
+		t.Errorf("Expected primary to stay active after one failure, got %s", client.BaseURL())
+	}
+
+	// Second failure reaches the threshold and switches hosts
+	if _, err := client.FetchNew(context.Background(), FetchParams{MaxResults: 1}); err != nil {
+		t.Fatalf("FetchNew failed: %v", err)
+	}
+	if client.BaseURL() != mirror.URL {
+		t.Errorf("Expected failover to mirror, got %s", client.BaseURL())
+	}
+
+	// Subsequent requests go straight to the mirror
+	if _, err := client.FetchNew(context.Background(), FetchParams{MaxResults: 1}); err != nil {
+		t.Fatalf("FetchNew failed: %v", err)
+	}
+	if primaryHits != 2 {
+		t.Errorf("Expected 2 requests to primary, got %d", primaryHits)
+	}
+	if mirrorHits != 3 {
+		t.Errorf("Expected 3 requests to mirror, got %d", mirrorHits)
+	}
+}
+
+func TestClientNoFailoverOnClientError(t *testing.T) {
+	mirrorHits := 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad query", http.StatusBadRequest)
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits++
+		w.Write([]byte(emptyFeedXML))
+	}))
+	defer mirror.Close()
+
+	client := NewClient(0)
+	client.SetBaseURLs([]string{primary.URL, mirror.URL}, 1)
+
+	if _, err := client.FetchByIDs(context.Background(), []string{"2301.12345"}); err == nil {
+		t.Fatal("Expected error for 400 response")
+	}
+	if mirrorHits != 0 {
+		t.Errorf("Expected no mirror requests on client error, got %d", mirrorHits)
+	}
+	if client.BaseURL() != primary.URL {
+		t.Errorf("Expected primary to stay active, got %s", client.BaseURL())
+	}
+}
+
+func TestCountResults(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("search_query")
+		if r.URL.Query().Get("max_results") != "0" {
+			t.Errorf("Expected max_results=0, got %s", r.URL.Query().Get("max_results"))
+		}
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:opensearch="http://a9.com/-/spec/opensearch/1.1/">
+  <opensearch:totalResults>123456</opensearch:totalResults>
+</feed>`))
+	}))
+	defer server.Close()
+
+	client := NewClient(0)
+	client.SetBaseURLs([]string{server.URL}, 1)
+
+	total, err := client.CountResults(context.Background(), FetchParams{Categories: []string{"cs.AI"}})
+	if err != nil {
+		t.Fatalf("CountResults failed: %v", err)
+	}
+	if total != 123456 {
+		t.Errorf("Expected 123456, got %d", total)
+	}
+	if gotQuery != "cat:cs.AI" {
+		t.Errorf("Unexpected query %q", gotQuery)
+	}
+}
+
+func TestRateLimitSpacesRequestsWithoutDelayingResponses(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		w.Write([]byte(emptyFeedXML))
+	}))
+	defer server.Close()
+
+	client := NewClient(delay)
+	client.SetBaseURLs([]string{server.URL}, 1)
+
+	// The first response comes back without waiting out the delay
+	begin := time.Now()
+	if _, err := client.FetchNew(context.Background(), FetchParams{MaxResults: 1}); err != nil {
+		t.Fatalf("FetchNew failed: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed >= delay {
+		t.Errorf("Expected the first request not to be delayed, took %v", elapsed)
+	}
+
+	// Concurrent callers share the limit and are spaced apart
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.FetchNew(context.Background(), FetchParams{MaxResults: 1}); err != nil {
+				t.Errorf("FetchNew failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(starts) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < delay-20*time.Millisecond {
+			t.Errorf("Requests %d and %d only %v apart", i-1, i, gap)
+		}
+	}
+
+	// A cancelled context stops waiting for a slot
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.FetchNew(ctx, FetchParams{MaxResults: 1}); err == nil {
+		t.Error("Expected an error for a cancelled context")
+	}
+}
+
+func TestFetchByIDsBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := strings.Split(r.URL.Query().Get("id_list"), ",")
+		mu.Lock()
+		batchSizes = append(batchSizes, len(ids))
+		mu.Unlock()
+
+		if r.URL.Query().Get("max_results") != fmt.Sprint(len(ids)) {
+			t.Errorf("Expected max_results to match the batch size, got %s", r.URL.Query().Get("max_results"))
+		}
+		for _, id := range ids {
+			if id == "2401.00150" {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><feed xmlns="http://www.w3.org/2005/Atom">`)
+		for _, id := range ids {
+			fmt.Fprintf(&b, `<entry><id>http://arxiv.org/abs/%sv1</id><title>Paper</title></entry>`, id)
+		}
+		b.WriteString(`</feed>`)
+		w.Write([]byte(b.String()))
+	}))
+	defer server.Close()
+
+	client := NewClient(0)
+	client.SetBaseURLs([]string{server.URL}, 100)
+
+	var ids []string
+	for i := 1; i <= 250; i++ {
+		ids = append(ids, fmt.Sprintf("2401.%05d", i))
+	}
+	ids = append(ids, "2401.00001", "not-an-id", "hep-th/9901001v2", "2401.1")
+
+	feed, err := client.FetchByIDs(context.Background(), ids)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a BatchError, got %v", err)
+	}
+
+	if fmt.Sprint(batchSizes) != "[100 100 51]" {
+		t.Errorf("Expected batches of 100, 100 and 51, got %v", batchSizes)
+	}
+	if fmt.Sprint(batchErr.Invalid) != "[not-an-id 2401.1]" {
+		t.Errorf("Expected malformed IDs reported, got %v", batchErr.Invalid)
+	}
+	if len(batchErr.Failed) != 1 || batchErr.Batches != 3 || batchErr.Failed[0].IDs[0] != "2401.00101" {
+		t.Errorf("Expected the second batch to fail, got %+v", batchErr.Failed)
+	}
+	if len(batchErr.FailedIDs()) != 102 {
+		t.Errorf("Expected 102 failed IDs, got %d", len(batchErr.FailedIDs()))
+	}
+	if len(feed.Entries) != 151 {
+		t.Errorf("Expected entries from the batches that succeeded, got %d", len(feed.Entries))
+	}
+
+	if feed, err := client.FetchByIDs(context.Background(), nil); err != nil || len(feed.Entries) != 0 {
+		t.Errorf("Expected empty feed for no IDs, got %v, %v", feed, err)
+	}
+}
+
+func TestValidID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"2401.01234":       true,
+		"2401.01234v3":     true,
+		"0704.0001":        true,
+		"hep-th/9901001":   true,
+		"math.GT/0309136":  true,
+		"2401.1":           false,
+		"2401.01234,other": false,
+		"":                 false,
+	} {
+		if got := ValidID(id); got != want {
+			t.Errorf("ValidID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
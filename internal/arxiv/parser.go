@@ -11,30 +11,56 @@ import (
 	"github.com/ngx/arxiv-go-nest/internal/models"
 )
 
-// Feed represents the Atom feed returned by arXiv API
+// Feed represents the Atom feed returned by arXiv API.
+//
+// Every element is matched by namespace as well as name: Atom elements in
+// http://www.w3.org/2005/Atom, arXiv's extensions in
+// http://arxiv.org/schemas/atom, whatever prefix the feed binds them to. A
+// name alone would also match same-named elements of other namespaces, and
+// whichever came last in the entry would win.
 type Feed struct {
-	XMLName xml.Name `xml:"feed"`
-	Title   string   `xml:"title"`
-	ID      string   `xml:"id"`
-	Updated string   `xml:"updated"`
-	Entries []Entry  `xml:"entry"`
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string   `xml:"http://www.w3.org/2005/Atom title"`
+	ID      string   `xml:"http://www.w3.org/2005/Atom id"`
+	Updated string   `xml:"http://www.w3.org/2005/Atom updated"`
+	Entries []Entry  `xml:"http://www.w3.org/2005/Atom entry"`
+
+	// TotalResults is the number of papers matching the query archive-wide
+	TotalResults int `xml:"http://a9.com/-/spec/opensearch/1.1/ totalResults"`
 }
 
 // Entry represents a single paper in the Atom feed
 type Entry struct {
-	ID        string   `xml:"id"`
-	Title     string   `xml:"title"`
-	Summary   string   `xml:"summary"`
-	Published string   `xml:"published"`
-	Updated   string   `xml:"updated"`
-	Authors   []Author `xml:"author"`
-	Links     []Link   `xml:"link"`
-	Categories []Category `xml:"category"`
+	ID         string     `xml:"http://www.w3.org/2005/Atom id"`
+	Title      string     `xml:"http://www.w3.org/2005/Atom title"`
+	Summary    string     `xml:"http://www.w3.org/2005/Atom summary"`
+	Published  string     `xml:"http://www.w3.org/2005/Atom published"`
+	Updated    string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors    []Author   `xml:"http://www.w3.org/2005/Atom author"`
+	Links      []Link     `xml:"http://www.w3.org/2005/Atom link"`
+	Categories []Category `xml:"http://www.w3.org/2005/Atom category"`
+
+	// PrimaryCategory is arXiv's primary category, which the category
+	// elements don't mark
+	PrimaryCategory Category `xml:"http://arxiv.org/schemas/atom primary_category"`
+
+	// License information, from arXiv's extension element or standard Atom
+	License string `xml:"http://arxiv.org/schemas/atom license"`
+	Rights  string `xml:"http://www.w3.org/2005/Atom rights"`
+
+	// Comment is the authors' free-text comment, e.g. "Accepted at ICML 2024"
+	Comment string `xml:"http://arxiv.org/schemas/atom comment"`
+
+	// DOI and JournalRef describe the published version, when the authors
+	// reported one
+	DOI        string `xml:"http://arxiv.org/schemas/atom doi"`
+	JournalRef string `xml:"http://arxiv.org/schemas/atom journal_ref"`
 }
 
 // Author represents a paper author
 type Author struct {
-	Name string `xml:"name"`
+	Name         string   `xml:"http://www.w3.org/2005/Atom name"`
+	Affiliations []string `xml:"http://arxiv.org/schemas/atom affiliation"`
 }
 
 // Link represents a link to the paper
@@ -96,19 +122,17 @@ func (e *Entry) ToPaper() (*models.Paper, error) {
 		authors[i] = strings.TrimSpace(author.Name)
 	}
 
-	// Extract categories
-	categories := make([]string, len(e.Categories))
-	for i, cat := range e.Categories {
-		categories[i] = cat.Term
-	}
+	categories := e.categories()
 
-	// Find PDF and arXiv URLs
-	var pdfURL, arxivURL string
+	// Find PDF, arXiv and license URLs
+	var pdfURL, arxivURL, licenseURL string
 	for _, link := range e.Links {
 		if link.Title == "pdf" {
 			pdfURL = link.Href
 		} else if link.Rel == "alternate" {
 			arxivURL = link.Href
+		} else if link.Rel == "license" {
+			licenseURL = link.Href
 		}
 	}
 
@@ -126,11 +150,46 @@ func (e *Entry) ToPaper() (*models.Paper, error) {
 		UpdatedAt:   updatedAt,
 		PDFUrl:      pdfURL,
 		ArxivUrl:    arxivURL,
+		License:     e.license(licenseURL),
+		Comment:     cleanText(e.Comment),
+		DOI:         strings.TrimSpace(e.DOI),
+		JournalRef:  cleanText(e.JournalRef),
 	}
 
 	return paper, nil
 }
 
+// categories returns the entry's category terms with the primary category
+// first, where the rest of the app looks for it
+func (e *Entry) categories() []string {
+	primary := strings.TrimSpace(e.PrimaryCategory.Term)
+	var categories []string
+	if primary != "" {
+		categories = append(categories, primary)
+	}
+	for _, cat := range e.Categories {
+		if term := strings.TrimSpace(cat.Term); term != "" && term != primary {
+			categories = append(categories, term)
+		}
+	}
+	return categories
+}
+
+// license returns the entry's license URL: the arxiv:license element, a
+// rel="license" link, or the Atom rights element if it holds a URL
+func (e *Entry) license(linkURL string) string {
+	if license := strings.TrimSpace(e.License); license != "" {
+		return license
+	}
+	if linkURL != "" {
+		return linkURL
+	}
+	if rights := strings.TrimSpace(e.Rights); strings.HasPrefix(rights, "http://") || strings.HasPrefix(rights, "https://") {
+		return rights
+	}
+	return ""
+}
+
 // ToPapers converts all entries in a feed to papers
 func (f *Feed) ToPapers() ([]*models.Paper, error) {
 	papers := make([]*models.Paper, 0, len(f.Entries))
@@ -1,6 +1,8 @@
 package arxiv
 
 import (
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -221,3 +223,127 @@ func TestFeedToPapers(t *testing.T) {
 		t.Errorf("Expected second paper ID '2301.67890', got '%s'", papers[1].ID)
 	}
 }
+
+func TestParseLicense(t *testing.T) {
+	xmlData := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:arxiv="http://arxiv.org/schemas/atom">
+  <entry>
+    <id>http://arxiv.org/abs/2301.00001v1</id>
+    <published>2023-01-25T12:00:00Z</published>
+    <updated>2023-01-25T12:00:00Z</updated>
+    <title>Extension</title>
+    <arxiv:license>http://creativecommons.org/licenses/by/4.0/</arxiv:license>
+  </entry>
+  <entry>
+    <id>http://arxiv.org/abs/2301.00002v1</id>
+    <published>2023-01-25T12:00:00Z</published>
+    <updated>2023-01-25T12:00:00Z</updated>
+    <title>Link</title>
+    <link rel="license" href="http://creativecommons.org/licenses/by-nc-sa/4.0/"/>
+  </entry>
+  <entry>
+    <id>http://arxiv.org/abs/2301.00003v1</id>
+    <published>2023-01-25T12:00:00Z</published>
+    <updated>2023-01-25T12:00:00Z</updated>
+    <title>Rights</title>
+    <rights>http://arxiv.org/licenses/nonexclusive-distrib/1.0/</rights>
+  </entry>
+  <entry>
+    <id>http://arxiv.org/abs/2301.00004v1</id>
+    <published>2023-01-25T12:00:00Z</published>
+    <updated>2023-01-25T12:00:00Z</updated>
+    <title>None</title>
+    <rights>All rights reserved</rights>
+  </entry>
+</feed>`
+
+	feed, err := ParseFeed(strings.NewReader(xmlData))
+	if err != nil {
+		t.Fatalf("ParseFeed failed: %v", err)
+	}
+	papers, _ := feed.ToPapers()
+
+	expected := []string{
+		"http://creativecommons.org/licenses/by/4.0/",
+		"http://creativecommons.org/licenses/by-nc-sa/4.0/",
+		"http://arxiv.org/licenses/nonexclusive-distrib/1.0/",
+		"",
+	}
+	if len(papers) != len(expected) {
+		t.Fatalf("Expected %d papers, got %d", len(expected), len(papers))
+	}
+	for i, want := range expected {
+		if papers[i].License != want {
+			t.Errorf("%s: expected license %q, got %q", papers[i].Title, want, papers[i].License)
+		}
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	f, err := os.Open("testdata/extensions.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	feed, err := ParseFeed(f)
+	if err != nil {
+		t.Fatalf("ParseFeed failed: %v", err)
+	}
+	if feed.TotalResults != 214873 {
+		t.Errorf("Expected 214873 total results, got %d", feed.TotalResults)
+	}
+	if len(feed.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(feed.Entries))
+	}
+
+	authors := feed.Entries[0].Authors
+	if len(authors) != 2 || !reflect.DeepEqual(authors[1].Affiliations, []string{"University of Manchester", "Bletchley Park"}) {
+		t.Errorf("Unexpected authors %+v", authors)
+	}
+
+	papers, _ := feed.ToPapers()
+	tests := []struct {
+		id, title, categories, comment, doi, journalRef string
+	}{
+		{
+			id:         "2403.01234",
+			title:      "Graph Transformers at Scale",
+			categories: "cs.LG, cs.AI, stat.ML",
+			comment:    "12 pages, 4 figures; accepted at KDD 2024",
+			doi:        "10.1145/3580305.3599000",
+			journalRef: "Proceedings of KDD 2024, pp. 100-112",
+		},
+		{
+			// Other namespaces' title, doi and comment are ignored
+			id:         "2403.05678",
+			title:      "Diffusion Models for Tabular Data",
+			categories: "stat.ML, cs.LG",
+			comment:    "Code at https://github.com/example/tabdiff",
+			doi:        "10.48550/arXiv.2403.05678",
+		},
+		{
+			id:         "2403.09999",
+			title:      "A Plain Entry",
+			categories: "cs.PL",
+		},
+	}
+	if len(papers) != len(tests) {
+		t.Fatalf("Expected %d papers, got %d", len(tests), len(papers))
+	}
+	for i, tt := range tests {
+		p := papers[i]
+		if p.ID != tt.id || p.Title != tt.title || p.Categories != tt.categories ||
+			p.Comment != tt.comment || p.DOI != tt.doi || p.JournalRef != tt.journalRef {
+			t.Errorf("Paper %d = %q %q [%s] comment %q doi %q journal %q", i,
+				p.ID, p.Title, p.Categories, p.Comment, p.DOI, p.JournalRef)
+		}
+	}
+}
+
+func TestParseFeedRequiresAtomNamespace(t *testing.T) {
+	xmlData := `<feed><entry><id>http://arxiv.org/abs/2301.00001v1</id></entry></feed>`
+	if _, err := ParseFeed(strings.NewReader(xmlData)); err == nil {
+		t.Error("Expected a feed outside the Atom namespace to be rejected")
+	}
+}
@@ -0,0 +1,53 @@
+package arxiv
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests at least delay apart across every goroutine
+// sharing the client. Callers reserve the next free slot and wait for it
+// before sending, so a response is returned as soon as it arrives and only
+// the following request pays the delay.
+type rateLimiter struct {
+	mu    sync.Mutex
+	delay time.Duration
+	next  time.Time
+}
+
+// newRateLimiter creates a limiter; a delay of zero disables it
+func newRateLimiter(delay time.Duration) *rateLimiter {
+	return &rateLimiter{delay: delay}
+}
+
+// Wait blocks until the caller may send a request, or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l.delay <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.delay)
+	l.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
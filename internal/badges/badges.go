@@ -0,0 +1,160 @@
+// Package badges collects the short labels shown on paper cards ("revised",
+// "code", a license, a venue) from independent providers, so a feature
+// adds its badge by registering a provider instead of editing every card
+// template. Providers only look at data already loaded on the paper.
+package badges
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// Badge kinds, which set the badge's color
+const (
+	KindDefault = ""
+	KindInfo    = "info"
+	KindSuccess = "success"
+	KindWarning = "warning"
+)
+
+// Badge is a label on a paper card. URL, if set, makes it a link, and
+// Title is shown on hover.
+type Badge struct {
+	Label string
+	Title string
+	URL   string
+	Kind  string
+}
+
+// Provider computes the badges of a paper
+type Provider interface {
+	Badges(p *models.Paper) []Badge
+}
+
+// ProviderFunc adapts a function to a Provider
+type ProviderFunc func(p *models.Paper) []Badge
+
+// Badges calls f
+func (f ProviderFunc) Badges(p *models.Paper) []Badge {
+	return f(p)
+}
+
+// Registry holds providers in the order their badges are shown
+type Registry struct {
+	mu        sync.RWMutex
+	providers []Provider
+}
+
+// NewRegistry creates a registry with the given providers
+func NewRegistry(providers ...Provider) *Registry {
+	return &Registry{providers: providers}
+}
+
+// Register adds a provider whose badges follow those already registered
+func (r *Registry) Register(p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers = append(r.providers, p)
+}
+
+// For returns a paper's badges. A label given by an earlier provider
+// isn't repeated.
+func (r *Registry) For(p *models.Paper) []Badge {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var badges []Badge
+	seen := make(map[string]bool)
+	for _, provider := range r.providers {
+		for _, b := range provider.Badges(p) {
+			if b.Label == "" || seen[b.Label] {
+				continue
+			}
+			seen[b.Label] = true
+			badges = append(badges, b)
+		}
+	}
+	return badges
+}
+
+// Default is the registry the templates render. It starts with the
+// built-in providers.
+var Default = NewRegistry(
+	ProviderFunc(Source),
+	ProviderFunc(License),
+	ProviderFunc(Venues),
+	ProviderFunc(Revised),
+	ProviderFunc(Code),
+)
+
+// Register adds a provider to the default registry
+func Register(p Provider) {
+	Default.Register(p)
+}
+
+// For returns a paper's badges from the default registry
+func For(p *models.Paper) []Badge {
+	return Default.For(p)
+}
+
+// Source labels papers that don't come from arXiv with their server
+func Source(p *models.Paper) []Badge {
+	if p.Source() == models.SourceArxiv {
+		return nil
+	}
+	return []Badge{{Label: p.SourceName(), Title: "Preprint from " + p.SourceName(), Kind: KindInfo}}
+}
+
+// License links to the paper's license under its short name
+func License(p *models.Paper) []Badge {
+	label := models.LicenseLabel(p.License)
+	if label == "" {
+		return nil
+	}
+	return []Badge{{Label: label, Title: p.License, URL: p.License}}
+}
+
+// Venues labels the conferences mentioned in the paper's comment, when
+// they were loaded with the paper
+func Venues(p *models.Paper) []Badge {
+	var badges []Badge
+	for _, v := range p.Venues {
+		label := v.Venue
+		if v.Year > 0 {
+			label = fmt.Sprintf("%s %d", v.Venue, v.Year)
+		}
+		badges = append(badges, Badge{Label: label, Title: "Conference mentioned in the comment", Kind: KindInfo})
+	}
+	return badges
+}
+
+// revisedAfter is how long after publication an update counts as a revision
+const revisedAfter = 24 * time.Hour
+
+// Revised marks papers updated after they were first published
+func Revised(p *models.Paper) []Badge {
+	if p.PublishedAt.IsZero() || p.UpdatedAt.Sub(p.PublishedAt) < revisedAfter {
+		return nil
+	}
+	return []Badge{{Label: "revised", Title: "Updated " + p.UpdatedAt.Format("Jan 2, 2006"), Kind: KindWarning}}
+}
+
+// codeURLRegex matches links to code hosts
+var codeURLRegex = regexp.MustCompile(`https?://(?:www\.)?(?:github\.com|gitlab\.com|bitbucket\.org|codeberg\.org)/[^\s)\]}>"']+`)
+
+// Code links to the first code repository mentioned in the comment or
+// abstract
+func Code(p *models.Paper) []Badge {
+	for _, text := range []string{p.Comment, p.Abstract} {
+		if link := codeURLRegex.FindString(text); link != "" {
+			link = strings.TrimRight(link, ".,;:")
+			return []Badge{{Label: "code", Title: link, URL: link, Kind: KindSuccess}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package badges
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func labels(badges []Badge) []string {
+	var labels []string
+	for _, b := range badges {
+		labels = append(labels, b.Label)
+	}
+	return labels
+}
+
+func TestBuiltinBadges(t *testing.T) {
+	published := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	p := &models.Paper{
+		ID:          "biorxiv:2024.01.15.575123",
+		License:     "http://creativecommons.org/licenses/by/4.0/",
+		Comment:     "Accepted at NeurIPS 2024",
+		Abstract:    "Code is available at https://github.com/example/repo.",
+		PublishedAt: published,
+		UpdatedAt:   published.Add(48 * time.Hour),
+		Venues:      []models.VenueMention{{Venue: "NeurIPS", Year: 2024}},
+	}
+
+	got := For(p)
+	if want := []string{"bioRxiv", "CC BY 4.0", "NeurIPS 2024", "revised", "code"}; !reflect.DeepEqual(labels(got), want) {
+		t.Fatalf("Expected %v, got %v", want, labels(got))
+	}
+	if code := got[4]; code.URL != "https://github.com/example/repo" || code.Kind != KindSuccess {
+		t.Errorf("Expected the repository link without the full stop, got %+v", code)
+	}
+
+	plain := &models.Paper{ID: "2401.00001", PublishedAt: published, UpdatedAt: published.Add(time.Hour)}
+	if got := For(plain); len(got) != 0 {
+		t.Errorf("Expected no badges, got %v", labels(got))
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(ProviderFunc(Revised))
+	r.Register(ProviderFunc(func(p *models.Paper) []Badge {
+		return []Badge{{Label: "revised"}, {Label: "cited 500+"}, {Label: ""}}
+	}))
+
+	p := &models.Paper{PublishedAt: time.Now().Add(-72 * time.Hour), UpdatedAt: time.Now()}
+	if got := labels(r.For(p)); !reflect.DeepEqual(got, []string{"revised", "cited 500+"}) {
+		t.Errorf("Expected registered badges in order without repeats, got %v", got)
+	}
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -14,17 +18,64 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	ArXiv    ArXivConfig    `yaml:"arxiv"`
 	UI       UIConfig       `yaml:"ui"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Updates       UpdatesConfig       `yaml:"updates"`
+	Venues        VenuesConfig        `yaml:"venues"`
+	TTS           TTSConfig           `yaml:"tts"`
+	Telemetry     TelemetryConfig     `yaml:"telemetry"`
+	ReadingLog    ReadingLogConfig    `yaml:"reading_log"`
+	Curation      CurationConfig      `yaml:"curation"`
+	Embed         EmbedConfig         `yaml:"embed"`
+	Auth          AuthConfig          `yaml:"auth"`
+
+	// Hooks run local commands on events such as a paper being saved
+	Hooks []HookConfig `yaml:"hooks"`
+
+	// Features switches optional subsystems on or off; values can be
+	// overridden at runtime from the admin page
+	Features map[string]bool `yaml:"features"`
+
+	// Lightweight sizes the app for very constrained servers such as a
+	// Raspberry Pi: fetches use small result pages and every heavy feature
+	// (HTML checks, archive stats, notifications, audio, update checks) stays off
+	Lightweight bool `yaml:"lightweight" env:"LIGHTWEIGHT"`
 }
 
 // ServerConfig holds HTTP server settings
 type ServerConfig struct {
 	Host string `yaml:"host" env:"SERVER_HOST"`
 	Port int    `yaml:"port" env:"SERVER_PORT"`
+
+	// SecurityHeaders overrides the security headers sent with every
+	// response by name, e.g. a Content-Security-Policy allowing the asset
+	// hosts of customized templates; an empty value drops the header
+	SecurityHeaders map[string]string `yaml:"security_headers"`
 }
 
 // DatabaseConfig holds database settings
 type DatabaseConfig struct {
 	Path string `yaml:"path" env:"DB_PATH"`
+
+	// ReplicaPath is a read-only copy of the database kept up to date by
+	// external replication, which paper lists and statistics are read from
+	// (empty reads everything from Path)
+	ReplicaPath string `yaml:"replica_path" env:"DB_REPLICA_PATH"`
+
+	// SlowQueryThreshold logs queries taking at least this long, with their
+	// query plan (0 disables). SlowQueryLog is the log file; empty means stderr.
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD"`
+	SlowQueryLog       string        `yaml:"slow_query_log"`
+
+	// TrashRetentionDays is how long deleted papers stay restorable before
+	// they are purged (0 keeps them until the trash is emptied)
+	TrashRetentionDays int `yaml:"trash_retention_days" env:"DB_TRASH_RETENTION_DAYS"`
+
+	// MaxPapers and MaxSizeMB are soft quotas: past either, the prune job
+	// deletes the oldest papers not in the library, tagged or otherwise
+	// curated (0 disables)
+	MaxPapers int `yaml:"max_papers" env:"DB_MAX_PAPERS"`
+	MaxSizeMB int `yaml:"max_size_mb" env:"DB_MAX_SIZE_MB"`
 }
 
 // ArXivConfig holds arXiv fetching settings
@@ -34,11 +85,190 @@ type ArXivConfig struct {
 	MaxResults     int           `yaml:"max_results" env:"ARXIV_MAX_RESULTS"`
 	FetchInterval  time.Duration `yaml:"fetch_interval" env:"ARXIV_FETCH_INTERVAL"`
 	RateLimitDelay time.Duration `yaml:"rate_limit_delay"`
+
+	// SubscriptionTags lists, by category or keyword, the shared tags
+	// given to each paper new to the database that is listed in the
+	// category or matches the keyword
+	SubscriptionTags map[string][]string `yaml:"subscription_tags"`
+
+	// BaseURLs lists API hosts (mirrors or caching proxies) tried in order
+	BaseURLs          []string `yaml:"base_urls" env:"ARXIV_BASE_URLS"`
+	FailoverThreshold int      `yaml:"failover_threshold"`
+
+	// HTMLBaseURL is where HTML renderings of papers are looked up, by
+	// appending the paper ID
+	HTMLBaseURL string `yaml:"html_base_url" env:"ARXIV_HTML_BASE_URL"`
+
+	// PageSize splits a fetch into requests of this many results, stopping
+	// at the first page without new papers (0 requests max_results at once)
+	PageSize int `yaml:"page_size" env:"ARXIV_PAGE_SIZE"`
 }
 
 // UIConfig holds UI-related settings
 type UIConfig struct {
 	PageSize int `yaml:"page_size" env:"UI_PAGE_SIZE"`
+
+	// PromptSaveNote asks "why are you saving this?" when saving a paper
+	PromptSaveNote bool `yaml:"prompt_save_note"`
+}
+
+// NotificationsConfig holds settings for new-paper notifications
+type NotificationsConfig struct {
+	// Excerpt sends a one-sentence summary instead of the full abstract
+	Excerpt bool `yaml:"excerpt"`
+	// DryRun renders notifications without sending them; the admin
+	// Notifications page shows what would have been sent
+	DryRun   bool                  `yaml:"dry_run"`
+	Channels []NotificationChannel `yaml:"channels"`
+	SMTP     SMTPConfig            `yaml:"smtp"`
+}
+
+// NotificationChannel configures one notification destination
+type NotificationChannel struct {
+	Name string   `yaml:"name"`
+	Type string   `yaml:"type"` // "webhook", "email"
+	URL  string   `yaml:"url"`  // webhook URL
+	To   []string `yaml:"to"`   // email recipients
+
+	// Batch collects papers for this long after the first one and sends
+	// them as one digest; 0 sends a message per paper right away
+	Batch time.Duration `yaml:"batch"`
+}
+
+// UpdatesConfig holds settings for the new release check
+type UpdatesConfig struct {
+	// Check looks up the latest GitHub release of Repository every Interval
+	// and shows a banner when it is newer than the running version
+	Check      bool          `yaml:"check" env:"UPDATES_CHECK"`
+	Repository string        `yaml:"repository"`
+	Interval   time.Duration `yaml:"interval"`
+}
+
+// HookConfig runs a local command on an event, with the event as JSON on
+// its standard input
+type HookConfig struct {
+	Event   string        `yaml:"event"`   // "paper.saved", "fetch.completed"
+	Command []string      `yaml:"command"` // program and arguments
+	Timeout time.Duration `yaml:"timeout"` // 0 means 30s
+}
+
+// VenuesConfig holds settings for conference detection and deadlines
+type VenuesConfig struct {
+	// File is a YAML venue catalog merged over the built-in one, to add
+	// venues or the dates of upcoming editions
+	File string `yaml:"file" env:"VENUES_FILE"`
+}
+
+// TTSConfig holds settings for spoken abstracts
+type TTSConfig struct {
+	// Command reads text on stdin and writes audio in Format ("wav", "mp3"
+	// or "ogg") to stdout, e.g. ["espeak-ng", "--stdout"]; empty disables
+	// audio
+	Command []string `yaml:"command"`
+	Format  string   `yaml:"format"`
+
+	// CacheDir keeps generated audio, one file per abstract
+	CacheDir string        `yaml:"cache_dir" env:"TTS_CACHE_DIR"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// TelemetryConfig holds settings for exporting traces and metrics
+type TelemetryConfig struct {
+	// Endpoint is the base URL of an OTLP/HTTP receiver, such as an
+	// OpenTelemetry Collector at http://localhost:4318; empty disables
+	// telemetry
+	Endpoint    string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+
+	// Headers are sent with every export, e.g. an API key of a hosted
+	// backend
+	Headers map[string]string `yaml:"headers"`
+
+	// Interval is how often spans and metrics are exported
+	Interval time.Duration `yaml:"interval"`
+}
+
+// ReadingLogConfig holds the external reading logs that library papers
+// marked as read are pushed to
+type ReadingLogConfig struct {
+	// ReadwiseToken is a Readwise access token; each read paper is added
+	// as a highlight of its abstract page. Empty disables Readwise.
+	ReadwiseToken string `yaml:"readwise_token" env:"READWISE_TOKEN"`
+
+	// Webhook is a URL the read events are POSTed to as JSON; empty
+	// disables it
+	Webhook string `yaml:"webhook"`
+
+	// Interval is how often new reads are pushed
+	Interval time.Duration `yaml:"interval"`
+}
+
+// CurationConfig holds the review queue of the curation feature
+type CurationConfig struct {
+	// Curators are the names that can approve or reject queued papers
+	Curators []string `yaml:"curators" env:"CURATORS"`
+}
+
+// EmbedConfig holds settings for the paper lists other sites can embed
+type EmbedConfig struct {
+	// Secret signs the tokens in embed links; empty disables embedding.
+	// Changing it revokes every link handed out.
+	Secret string `yaml:"secret" env:"EMBED_SECRET"`
+
+	// FrameAncestors are the sites allowed to frame the lists, e.g.
+	// "https://lab.example.edu"; empty allows any site
+	FrameAncestors []string `yaml:"frame_ancestors"`
+}
+
+// AuthConfig controls who can use the app. Without methods anyone who can
+// reach the server can use it.
+type AuthConfig struct {
+	// Methods are the ways users can sign in, tried in order: "session"
+	// (the login page), "token" (API tokens) and "header" (a user name set
+	// by an SSO proxy such as oauth2-proxy)
+	Methods []string `yaml:"methods"`
+
+	// SessionSecret signs session cookies. Changing it signs everyone out.
+	SessionSecret string        `yaml:"session_secret" env:"AUTH_SESSION_SECRET"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
+
+	// Users maps the names that can sign in on the login page to their
+	// password hashes, as printed by the hash-password command
+	Users map[string]string `yaml:"users"`
+
+	// Tokens maps names to API tokens, sent as "Authorization: Bearer
+	// <token>". Requests with a token act as the user of its name.
+	Tokens map[string]string `yaml:"tokens"`
+
+	// Header carries the signed-in user's name and GroupsHeader their
+	// comma-separated groups. Only requests from TrustedProxies, as IP
+	// addresses or CIDR ranges, may set them.
+	Header         string   `yaml:"header"`
+	GroupsHeader   string   `yaml:"groups_header"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// Admins are the users or groups allowed on the /admin pages; empty
+	// allows every signed-in user
+	Admins []string `yaml:"admins"`
+}
+
+// IsCurator reports whether name is one of the configured curators
+func (c CurationConfig) IsCurator(name string) bool {
+	for _, curator := range c.Curators {
+		if name != "" && curator == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SMTPConfig holds the mail server used by email channels
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+	From     string `yaml:"from"`
 }
 
 // Load reads configuration from YAML file and environment variables
@@ -51,17 +281,48 @@ func Load(configPath string) (*Config, error) {
 			Port: 8080,
 		},
 		Database: DatabaseConfig{
-			Path: "./data/arxiv.db",
+			Path:               "./data/arxiv.db",
+			TrashRetentionDays: 30,
 		},
 		ArXiv: ArXivConfig{
-			Categories:     []string{"cs.AI", "cs.LG", "cs.CL"},
-			Keywords:       []string{},
-			MaxResults:     100,
-			FetchInterval:  24 * time.Hour,
-			RateLimitDelay: 3 * time.Second,
+			Categories:        []string{"cs.AI", "cs.LG", "cs.CL"},
+			Keywords:          []string{},
+			MaxResults:        100,
+			FetchInterval:     24 * time.Hour,
+			RateLimitDelay:    3 * time.Second,
+			BaseURLs:          []string{"http://export.arxiv.org/api/query"},
+			FailoverThreshold: 3,
+			HTMLBaseURL:       "https://arxiv.org/html/",
 		},
 		UI: UIConfig{
-			PageSize: 20,
+			PageSize:       20,
+			PromptSaveNote: true,
+		},
+		Notifications: NotificationsConfig{
+			Excerpt: true,
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+		Updates: UpdatesConfig{
+			Repository: "Nannigalaxy/arxiv-nest-go",
+			Interval:   24 * time.Hour,
+		},
+		TTS: TTSConfig{
+			Format:   "wav",
+			CacheDir: "./data/audio",
+			Timeout:  2 * time.Minute,
+		},
+		Telemetry: TelemetryConfig{
+			ServiceName: "arxiv-nest",
+			Interval:    10 * time.Second,
+		},
+		ReadingLog: ReadingLogConfig{
+			Interval: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			SessionTTL: 30 * 24 * time.Hour,
+			Header:     "X-Remote-User",
 		},
 	}
 
@@ -93,23 +354,296 @@ func Load(configPath string) (*Config, error) {
 	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
 		cfg.Database.Path = dbPath
 	}
+	if replicaPath := os.Getenv("DB_REPLICA_PATH"); replicaPath != "" {
+		cfg.Database.ReplicaPath = replicaPath
+	}
+	if threshold := os.Getenv("DB_SLOW_QUERY_THRESHOLD"); threshold != "" {
+		if d, err := time.ParseDuration(threshold); err == nil {
+			cfg.Database.SlowQueryThreshold = d
+		}
+	}
+	if retention := os.Getenv("DB_TRASH_RETENTION_DAYS"); retention != "" {
+		var days int
+		if _, err := fmt.Sscanf(retention, "%d", &days); err == nil {
+			cfg.Database.TrashRetentionDays = days
+		}
+	}
+	if maxPapers := os.Getenv("DB_MAX_PAPERS"); maxPapers != "" {
+		var m int
+		if _, err := fmt.Sscanf(maxPapers, "%d", &m); err == nil {
+			cfg.Database.MaxPapers = m
+		}
+	}
+	if maxSize := os.Getenv("DB_MAX_SIZE_MB"); maxSize != "" {
+		var m int
+		if _, err := fmt.Sscanf(maxSize, "%d", &m); err == nil {
+			cfg.Database.MaxSizeMB = m
+		}
+	}
 	if maxResults := os.Getenv("ARXIV_MAX_RESULTS"); maxResults != "" {
 		var m int
 		if _, err := fmt.Sscanf(maxResults, "%d", &m); err == nil {
 			cfg.ArXiv.MaxResults = m
 		}
 	}
+	if pageSize := os.Getenv("ARXIV_PAGE_SIZE"); pageSize != "" {
+		var p int
+		if _, err := fmt.Sscanf(pageSize, "%d", &p); err == nil {
+			cfg.ArXiv.PageSize = p
+		}
+	}
+	if baseURLs := os.Getenv("ARXIV_BASE_URLS"); baseURLs != "" {
+		cfg.ArXiv.BaseURLs = strings.Split(baseURLs, ",")
+	}
+	if htmlBaseURL := os.Getenv("ARXIV_HTML_BASE_URL"); htmlBaseURL != "" {
+		cfg.ArXiv.HTMLBaseURL = htmlBaseURL
+	}
+	if curators := os.Getenv("CURATORS"); curators != "" {
+		cfg.Curation.Curators = strings.Split(curators, ",")
+	}
+	if smtpPassword := os.Getenv("SMTP_PASSWORD"); smtpPassword != "" {
+		cfg.Notifications.SMTP.Password = smtpPassword
+	}
+	if token := os.Getenv("READWISE_TOKEN"); token != "" {
+		cfg.ReadingLog.ReadwiseToken = token
+	}
+	if secret := os.Getenv("EMBED_SECRET"); secret != "" {
+		cfg.Embed.Secret = secret
+	}
+	if secret := os.Getenv("AUTH_SESSION_SECRET"); secret != "" {
+		cfg.Auth.SessionSecret = secret
+	}
+	if check := os.Getenv("UPDATES_CHECK"); check != "" {
+		if b, err := strconv.ParseBool(check); err == nil {
+			cfg.Updates.Check = b
+		}
+	}
+	if venuesFile := os.Getenv("VENUES_FILE"); venuesFile != "" {
+		cfg.Venues.File = venuesFile
+	}
+	if cacheDir := os.Getenv("TTS_CACHE_DIR"); cacheDir != "" {
+		cfg.TTS.CacheDir = cacheDir
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		cfg.Telemetry.Endpoint = endpoint
+	}
+	if serviceName := os.Getenv("OTEL_SERVICE_NAME"); serviceName != "" {
+		cfg.Telemetry.ServiceName = serviceName
+	}
 	if pageSize := os.Getenv("UI_PAGE_SIZE"); pageSize != "" {
 		var p int
 		if _, err := fmt.Sscanf(pageSize, "%d", &p); err == nil {
 			cfg.UI.PageSize = p
 		}
 	}
+	if lightweight := os.Getenv("LIGHTWEIGHT"); lightweight != "" {
+		if b, err := strconv.ParseBool(lightweight); err == nil {
+			cfg.Lightweight = b
+		}
+	}
+
+	cfg.applyLightweight()
 
 	return cfg, nil
 }
 
+// lightweightPageSize is the largest fetch page used in lightweight mode
+const lightweightPageSize = 25
+
+// applyLightweight adjusts settings that lightweight mode overrides. Heavy
+// feature flags are switched off by the features package.
+func (c *Config) applyLightweight() {
+	if !c.Lightweight {
+		return
+	}
+	if c.ArXiv.PageSize <= 0 || c.ArXiv.PageSize > lightweightPageSize {
+		c.ArXiv.PageSize = lightweightPageSize
+	}
+	c.Updates.Check = false
+}
+
+// TrashRetention returns how long deleted papers are kept, or 0 to keep them
+// until the trash is emptied
+func (c *Config) TrashRetention() time.Duration {
+	if c.Database.TrashRetentionDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.Database.TrashRetentionDays) * 24 * time.Hour
+}
+
+// Changed lists the settings that differ between c and other by their
+// YAML path: "server.port" for a field of a section, "hooks" for a
+// top-level value
+func (c *Config) Changed(other *Config) []string {
+	var changed []string
+	a, b := reflect.ValueOf(*c), reflect.ValueOf(*other)
+	for i := 0; i < a.NumField(); i++ {
+		section := yamlName(a.Type().Field(i))
+		if a.Field(i).Kind() != reflect.Struct {
+			if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+				changed = append(changed, section)
+			}
+			continue
+		}
+		for j := 0; j < a.Field(i).NumField(); j++ {
+			if !reflect.DeepEqual(a.Field(i).Field(j).Interface(), b.Field(i).Field(j).Interface()) {
+				changed = append(changed, section+"."+yamlName(a.Field(i).Type().Field(j)))
+			}
+		}
+	}
+	return changed
+}
+
+// yamlName returns the name a struct field has in config.yaml
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
 // Address returns the server address in host:port format
 func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
+
+// redacted replaces secret values in a redacted configuration
+const redacted = "[redacted]"
+
+// Redacted returns a copy of the configuration that is safe to share, with
+// passwords, tokens, webhook URLs, credentials in API URLs, email
+// recipients and telemetry headers replaced
+func (c *Config) Redacted() *Config {
+	r := *c
+
+	r.ArXiv.BaseURLs = make([]string, len(c.ArXiv.BaseURLs))
+	for i, u := range c.ArXiv.BaseURLs {
+		r.ArXiv.BaseURLs[i] = redactURL(u, false)
+	}
+
+	r.Notifications.Channels = make([]NotificationChannel, len(c.Notifications.Channels))
+	for i, ch := range c.Notifications.Channels {
+		ch.URL = redactURL(ch.URL, true)
+		to := make([]string, len(ch.To))
+		for j := range ch.To {
+			to[j] = redacted
+		}
+		ch.To = to
+		r.Notifications.Channels[i] = ch
+	}
+
+	if r.Notifications.SMTP.Username != "" {
+		r.Notifications.SMTP.Username = redacted
+	}
+	if r.Notifications.SMTP.Password != "" {
+		r.Notifications.SMTP.Password = redacted
+	}
+
+	if r.ReadingLog.ReadwiseToken != "" {
+		r.ReadingLog.ReadwiseToken = redacted
+	}
+	r.ReadingLog.Webhook = redactURL(c.ReadingLog.Webhook, true)
+
+	if r.Embed.Secret != "" {
+		r.Embed.Secret = redacted
+	}
+
+	if r.Auth.SessionSecret != "" {
+		r.Auth.SessionSecret = redacted
+	}
+	r.Auth.Users = redactValues(c.Auth.Users)
+	r.Auth.Tokens = redactValues(c.Auth.Tokens)
+
+	if len(c.Telemetry.Headers) > 0 {
+		r.Telemetry.Headers = make(map[string]string, len(c.Telemetry.Headers))
+		for name := range c.Telemetry.Headers {
+			r.Telemetry.Headers[name] = redacted
+		}
+	}
+
+	return &r
+}
+
+// Secrets returns the secret values Redacted hides, so they can also be
+// scrubbed from free text such as logs
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, ch := range c.Notifications.Channels {
+		if ch.URL != "" {
+			secrets = append(secrets, ch.URL)
+		}
+		secrets = append(secrets, ch.To...)
+	}
+	for _, u := range c.ArXiv.BaseURLs {
+		if parsed, err := url.Parse(u); err == nil && parsed.User != nil {
+			secrets = append(secrets, parsed.User.String())
+		}
+	}
+	if c.Notifications.SMTP.Username != "" {
+		secrets = append(secrets, c.Notifications.SMTP.Username)
+	}
+	if c.Notifications.SMTP.Password != "" {
+		secrets = append(secrets, c.Notifications.SMTP.Password)
+	}
+	if c.ReadingLog.ReadwiseToken != "" {
+		secrets = append(secrets, c.ReadingLog.ReadwiseToken)
+	}
+	if c.ReadingLog.Webhook != "" {
+		secrets = append(secrets, c.ReadingLog.Webhook)
+	}
+	if c.Embed.Secret != "" {
+		secrets = append(secrets, c.Embed.Secret)
+	}
+	if c.Auth.SessionSecret != "" {
+		secrets = append(secrets, c.Auth.SessionSecret)
+	}
+	for _, hash := range c.Auth.Users {
+		if hash != "" {
+			secrets = append(secrets, hash)
+		}
+	}
+	for _, token := range c.Auth.Tokens {
+		if token != "" {
+			secrets = append(secrets, token)
+		}
+	}
+	for _, value := range c.Telemetry.Headers {
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// redactValues returns a copy of m with every value replaced
+func redactValues(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return m
+	}
+	r := make(map[string]string, len(m))
+	for key := range m {
+		r[key] = redacted
+	}
+	return r
+}
+
+// redactURL removes credentials and the query from a URL and, for URLs
+// whose path is itself a token (webhooks), the path too
+func redactURL(raw string, hidePath bool) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	if hidePath && u.Path != "" && u.Path != "/" {
+		return u.Scheme + "://" + u.Host + "/" + redacted
+	}
+	return u.String()
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -37,6 +38,10 @@ func TestLoadDefaultConfig(t *testing.T) {
 	if cfg.UI.PageSize != 20 {
 		t.Errorf("Expected default page size 20, got %d", cfg.UI.PageSize)
 	}
+
+	if len(cfg.ArXiv.BaseURLs) != 1 || cfg.ArXiv.BaseURLs[0] != "http://export.arxiv.org/api/query" {
+		t.Errorf("Expected default base URL 'http://export.arxiv.org/api/query', got %v", cfg.ArXiv.BaseURLs)
+	}
 }
 
 func TestLoadFromYAML(t *testing.T) {
@@ -162,3 +167,52 @@ func TestAddress(t *testing.T) {
 		t.Errorf("Expected address '%s', got '%s'", expected, addr)
 	}
 }
+
+func TestLightweightMode(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "config-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	yaml := `
+lightweight: true
+arxiv:
+  page_size: 50
+updates:
+  check: true
+`
+	if _, err := tmpFile.WriteString(yaml); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	tmpFile.Close()
+
+	cfg, err := Load(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.ArXiv.PageSize != lightweightPageSize {
+		t.Errorf("Expected page size capped at %d, got %d", lightweightPageSize, cfg.ArXiv.PageSize)
+	}
+	if cfg.Updates.Check {
+		t.Error("Expected update checks off in lightweight mode")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	a := &Config{Server: ServerConfig{Port: 8080}, ArXiv: ArXivConfig{Categories: []string{"cs.AI"}}}
+	b := *a
+	b.Server.Port = 9090
+	b.ArXiv.Categories = []string{"cs.AI", "cs.LG"}
+	b.Hooks = []HookConfig{{Event: "paper.saved"}}
+
+	if changed := a.Changed(a); len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+	changed := a.Changed(&b)
+	expected := []string{"server.port", "arxiv.categories", "hooks"}
+	if strings.Join(changed, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+}
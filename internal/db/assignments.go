@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// CreateAssignment assigns a paper to a group member to present by a due date
+func (db *DB) CreateAssignment(paperID, assignee string, due time.Time) (int, error) {
+	result, err := db.Exec(
+		"INSERT INTO assignments (paper_id, assignee, due_date) VALUES (?, ?, ?)",
+		paperID, assignee, due.Format("2006-01-02"),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create assignment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetAssignments returns assignments with their paper titles. Upcoming
+// (unpresented) assignments come first by due date; presented ones are
+// included only if requested, most recent first.
+func (db *DB) GetAssignments(presented bool) ([]models.Assignment, error) {
+	order := "a.due_date ASC, a.id ASC"
+	if presented {
+		order = "a.due_date DESC, a.id DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT a.id, a.paper_id, a.assignee, a.due_date, a.presented, a.created_at, p.title
+		FROM assignments a
+		JOIN papers p ON p.id = a.paper_id
+		WHERE a.presented = ?
+		ORDER BY %s
+	`, order)
+
+	var assignments []models.Assignment
+	if err := db.Select(&assignments, query, presented); err != nil {
+		return nil, fmt.Errorf("failed to fetch assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// GetPaperAssignments returns all assignments for a paper
+func (db *DB) GetPaperAssignments(paperID string) ([]models.Assignment, error) {
+	query := `
+		SELECT a.id, a.paper_id, a.assignee, a.due_date, a.presented, a.created_at, p.title
+		FROM assignments a
+		JOIN papers p ON p.id = a.paper_id
+		WHERE a.paper_id = ?
+		ORDER BY a.due_date, a.id
+	`
+
+	var assignments []models.Assignment
+	if err := db.Select(&assignments, query, paperID); err != nil {
+		return nil, fmt.Errorf("failed to fetch assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// SetAssignmentPresented marks an assignment as presented or pending
+func (db *DB) SetAssignmentPresented(id int, presented bool) error {
+	_, err := db.Exec("UPDATE assignments SET presented = ? WHERE id = ?", presented, id)
+	return err
+}
+
+// DeleteAssignment removes an assignment
+func (db *DB) DeleteAssignment(id int) error {
+	_, err := db.Exec("DELETE FROM assignments WHERE id = ?", id)
+	return err
+}
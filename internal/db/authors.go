@@ -0,0 +1,79 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrPreviewStale is returned when a bulk author replace would change a
+// different number of papers than its preview showed
+var ErrPreviewStale = errors.New("papers changed since the preview; preview again")
+
+// validateAuthorReplace checks the arguments of a bulk author replace
+func validateAuthorReplace(from, to string) error {
+	if from == "" {
+		return errors.New("the text to replace is required")
+	}
+	if from == to {
+		return errors.New("the replacement is the same as the text to replace")
+	}
+	return nil
+}
+
+// PreviewAuthorReplace returns the papers whose author list contains from
+// (case-sensitive), with the list as it would read after replacing every
+// occurrence with to
+func (db *DB) PreviewAuthorReplace(from, to string) ([]models.AuthorChange, error) {
+	if err := validateAuthorReplace(from, to); err != nil {
+		return nil, err
+	}
+
+	var changes []models.AuthorChange
+	err := db.Select(&changes,
+		"SELECT id, title, authors FROM papers WHERE instr(authors, ?) > 0 ORDER BY published_at DESC, id",
+		from,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find papers by author: %w", err)
+	}
+
+	for i := range changes {
+		changes[i].After = strings.ReplaceAll(changes[i].Before, from, to)
+	}
+	return changes, nil
+}
+
+// ReplaceAuthor replaces every occurrence of from with to in all papers'
+// author lists, e.g. to fix an encoding problem like `G\"unter`. It runs in
+// one transaction and rolls back with ErrPreviewStale unless exactly
+// expected papers change, so the result matches what was previewed.
+func (db *DB) ReplaceAuthor(from, to string, expected int) (int, error) {
+	if err := validateAuthorReplace(from, to); err != nil {
+		return 0, err
+	}
+
+	defer db.invalidateAll()
+
+	var changed int64
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		result, err := tx.Exec("UPDATE papers SET authors = replace(authors, ?, ?) WHERE instr(authors, ?) > 0", from, to, from)
+		if err != nil {
+			return fmt.Errorf("failed to replace author: %w", err)
+		}
+		if changed, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		if changed != int64(expected) {
+			return ErrPreviewStale
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(changed), nil
+}
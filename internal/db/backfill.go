@@ -0,0 +1,58 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// StartBackfill returns the backfill of a category between two days,
+// creating it if this range was not harvested before, so a stopped
+// backfill resumes from its checkpoint
+func (db *DB) StartBackfill(category string, from, to time.Time) (*models.Backfill, error) {
+	_, err := db.Exec(`
+		INSERT INTO backfills (category, from_day, to_day, next_day)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (category, from_day, to_day) DO NOTHING
+	`, category, from.Format("2006-01-02"), to.Format("2006-01-02"), from.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start backfill: %w", err)
+	}
+
+	var b models.Backfill
+	err = db.Get(&b, "SELECT * FROM backfills WHERE category = ? AND from_day = ? AND to_day = ?",
+		category, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch backfill: %w", err)
+	}
+	return &b, nil
+}
+
+// SaveBackfill records a backfill's checkpoint and counts, and finishes it
+// once the checkpoint is past its last day
+func (db *DB) SaveBackfill(b *models.Backfill) error {
+	now := time.Now().UTC()
+	b.UpdatedAt = now
+	if b.NextDay.After(b.ToDay) && b.FinishedAt == nil {
+		b.FinishedAt = &now
+	}
+	_, err := db.Exec(`
+		UPDATE backfills
+		SET next_day = ?, next_start = ?, fetched = ?, stored = ?, updated_at = ?, finished_at = ?
+		WHERE id = ?
+	`, b.NextDay.Format("2006-01-02"), b.NextStart, b.Fetched, b.Stored, b.UpdatedAt, b.FinishedAt, b.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save backfill checkpoint: %w", err)
+	}
+	return nil
+}
+
+// GetBackfills returns every backfill, most recently active first
+func (db *DB) GetBackfills() ([]models.Backfill, error) {
+	var backfills []models.Backfill
+	if err := db.Select(&backfills, "SELECT * FROM backfills ORDER BY updated_at DESC, id DESC"); err != nil {
+		return nil, fmt.Errorf("failed to fetch backfills: %w", err)
+	}
+	return backfills, nil
+}
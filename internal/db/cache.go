@@ -0,0 +1,166 @@
+package db
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// paperCacheSize is how many papers, and separately how many papers' tag
+// lists, are kept in memory
+const paperCacheSize = 512
+
+// paperCache holds recently looked up papers (with their library state) and
+// tag lists, so clicking through a list to detail pages and fragments doesn't
+// hit the database for the same paper again and again. Every method that
+// writes a paper, its library entry or its tags reports a Change, which
+// invalidates it.
+type paperCache struct {
+	papers *lru[models.Paper]
+	// tags holds all of a paper's tags, personal ones included; they are
+	// filtered for the client on the way out
+	tags *lru[[]models.Tag]
+}
+
+func newPaperCache() *paperCache {
+	return &paperCache{
+		papers: newLRU[models.Paper](paperCacheSize),
+		tags:   newLRU[[]models.Tag](paperCacheSize),
+	}
+}
+
+// apply forgets what a change made stale
+func (c *paperCache) apply(change Change) {
+	switch {
+	case change.All:
+		c.papers.clear()
+		c.tags.clear()
+	case change.Tags:
+		c.tags.clear()
+	}
+	c.papers.remove(change.Papers...)
+	c.tags.remove(change.Papers...)
+}
+
+// Keys of the count cache
+const (
+	paperCountKey   = "papers"
+	libraryCountKey = "library"
+)
+
+// countCache holds the paper and library counts shown on every page, which
+// otherwise take a full scan each. Any change to a paper may change them.
+type countCache struct {
+	counts *lru[int]
+}
+
+func newCountCache() *countCache {
+	return &countCache{counts: newLRU[int](2)}
+}
+
+// apply forgets the counts unless only tags changed
+func (c *countCache) apply(change Change) {
+	if change.All || len(change.Papers) > 0 {
+		c.counts.clear()
+	}
+}
+
+// get returns the count under key, running query on a miss
+func (c *countCache) get(key string, query func() (int, error)) (int, error) {
+	n, generation, ok := c.counts.get(key)
+	if ok {
+		return n, nil
+	}
+	n, err := query()
+	if err != nil {
+		return 0, err
+	}
+	c.counts.add(key, n, generation)
+	return n, nil
+}
+
+// lru is a fixed-size least recently used cache, safe for concurrent use.
+// Values are returned as stored, so callers must not modify them.
+type lru[V any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of *lruEntry, most recently used first
+	items map[string]*list.Element
+	// generation counts invalidations. A value read from the database is
+	// only added if nothing was invalidated since the read started, so a
+	// read racing a write can't put the old row back.
+	generation uint64
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRU[V any](size int) *lru[V] {
+	return &lru[V]{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get returns the value for key, and on a miss the generation to pass to
+// add once the value has been loaded
+func (c *lru[V]) get(key string) (V, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, c.generation, true
+	}
+	var zero V
+	return zero, c.generation, false
+}
+
+// add stores a value loaded at the given generation, evicting the least
+// recently used value when full
+func (c *lru[V]) add(key string, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry[V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// remove drops the values for keys
+func (c *lru[V]) remove(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for _, key := range keys {
+		if e, ok := c.items[key]; ok {
+			c.order.Remove(e)
+			delete(c.items, key)
+		}
+	}
+}
+
+// clear drops every value
+func (c *lru[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// len returns the number of cached values
+func (c *lru[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU[int](2)
+	_, gen, _ := c.get("a")
+	c.add("a", 1, gen)
+	c.add("b", 2, gen)
+	c.get("a")
+	c.add("c", 3, gen)
+
+	if _, _, ok := c.get("b"); ok {
+		t.Error("Expected the least recently used value to be evicted")
+	}
+	if v, _, ok := c.get("a"); !ok || v != 1 {
+		t.Errorf("Expected a = 1, got %v, %v", v, ok)
+	}
+
+	// A value read before an invalidation isn't cached
+	_, gen, _ = c.get("d")
+	c.remove("a")
+	c.add("d", 4, gen)
+	if _, _, ok := c.get("d"); ok {
+		t.Error("Expected a value loaded before the invalidation to be dropped")
+	}
+	if c.len() != 1 {
+		t.Errorf("Expected only c to be left, got %d values", c.len())
+	}
+}
+
+func TestPaperCacheInvalidation(t *testing.T) {
+	db := setupTestDB(t)
+	paper := &models.Paper{ID: "2401.00001", Title: "Before", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+
+	if _, err := db.GetPaperByID(paper.ID); err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if db.cache.papers.len() != 1 || db.cache.tags.len() != 1 {
+		t.Fatal("Expected the paper and its tags to be cached")
+	}
+
+	// Fetching the paper again unchanged leaves it cached
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	if db.cache.papers.len() != 1 {
+		t.Error("Expected an unchanged paper to stay cached")
+	}
+
+	paper.Title = "After"
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	if err := db.SaveToLibrary(paper.ID); err != nil {
+		t.Fatalf("SaveToLibrary failed: %v", err)
+	}
+	tagID, _ := db.CreateTag("shared")
+	if err := db.TagPaper(paper.ID, tagID); err != nil {
+		t.Fatalf("TagPaper failed: %v", err)
+	}
+	alice := db.ForClient("alice")
+	personalID, _ := alice.CreatePersonalTag("mine")
+	if err := alice.TagPaper(paper.ID, personalID); err != nil {
+		t.Fatalf("TagPaper failed: %v", err)
+	}
+
+	got, err := db.GetPaperByID(paper.ID)
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if got.Title != "After" || !got.InLibrary || len(got.Tags) != 1 {
+		t.Errorf("Expected the updated paper, got %q, in library %v, tags %+v", got.Title, got.InLibrary, got.Tags)
+	}
+
+	// The cached tags are filtered per client
+	if tags, _ := alice.GetPaperTags(paper.ID); len(tags) != 2 {
+		t.Errorf("Expected alice to see her personal tag, got %+v", tags)
+	}
+	if tags, _ := db.ForClient("bob").GetPaperTags(paper.ID); len(tags) != 1 {
+		t.Errorf("Expected bob to see only the shared tag, got %+v", tags)
+	}
+
+	if _, err := db.TrashPapers([]string{paper.ID}); err != nil {
+		t.Fatalf("TrashPapers failed: %v", err)
+	}
+	if _, err := db.GetPaperByID(paper.ID); err == nil {
+		t.Error("Expected a trashed paper not to be served from the cache")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	db := setupTestDB(t)
+	var changes []Change
+	db.OnChange(func(c Change) { changes = append(changes, c) })
+
+	paper := &models.Paper{ID: "2401.00001", Title: "Counted", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	if n, _ := db.GetPaperCount(); n != 1 {
+		t.Fatalf("Expected 1 paper, got %d", n)
+	}
+	if n, _ := db.GetLibraryCount(); n != 0 {
+		t.Fatalf("Expected an empty library, got %d", n)
+	}
+
+	generation := db.Generation()
+	if err := db.ForClient("alice").SaveToLibrary(paper.ID); err != nil {
+		t.Fatalf("SaveToLibrary failed: %v", err)
+	}
+	if db.Generation() == generation {
+		t.Error("Expected a write through another handle to move the generation")
+	}
+	if n, _ := db.GetLibraryCount(); n != 1 {
+		t.Errorf("Expected the cached library count to be invalidated, got %d", n)
+	}
+	if len(changes) != 2 || len(changes[1].Papers) != 1 || changes[1].Papers[0] != paper.ID {
+		t.Errorf("Expected a change per write naming the paper, got %+v", changes)
+	}
+
+	// Another process writing to the file is noticed too
+	if n, _ := db.GetPaperCount(); n != 1 {
+		t.Fatalf("Expected 1 paper, got %d", n)
+	}
+	var path string
+	if err := db.Get(&path, "SELECT file FROM pragma_database_list WHERE name = 'main'"); err != nil {
+		t.Fatalf("Failed to find the database file: %v", err)
+	}
+	other, err := New(path)
+	if err != nil {
+		t.Fatalf("Failed to open the database again: %v", err)
+	}
+	defer other.Close()
+	if err := other.UpsertPaper(&models.Paper{ID: "2401.00002", Title: "Imported", PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * externalCheckInterval)
+	for {
+		if n, _ := db.GetPaperCount(); n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the other process's paper to be counted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
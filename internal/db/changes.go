@@ -0,0 +1,126 @@
+package db
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// externalCheckInterval is how often the database is checked for writes
+// by other processes, such as an import run from the command line while
+// the server is up
+const externalCheckInterval = time.Second
+
+// Change describes a committed write, so caches in front of the database
+// can drop what it made stale
+type Change struct {
+	// Papers are the papers whose row, library entry or tags changed
+	Papers []string
+	// Tags is set when tags themselves changed (shared, described), which
+	// affects every paper carrying them
+	Tags bool
+	// All is set when anything may have changed: writes touching many
+	// papers at once, and writes by other processes
+	All bool
+
+	// Collected lists the papers the write newly added to a tag or shelf,
+	// for announcing them to the channels subscribed to it
+	Collected []Collected
+}
+
+// Collected is papers newly added to a tag or shelf. Kind is
+// models.CollectionTag or models.CollectionShelf.
+type Collected struct {
+	Kind   string
+	ID     int
+	Papers []string
+}
+
+// changeBus hands every change to the subscribed caches. It is shared with
+// the handles returned by ForClient and WithContext, so a write through any
+// of them reaches every cache.
+type changeBus struct {
+	mu          sync.RWMutex
+	subscribers []func(Change)
+	generation  atomic.Uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newChangeBus() *changeBus {
+	return &changeBus{stop: make(chan struct{})}
+}
+
+// OnChange subscribes fn to every write made through the database or its
+// handles, by handlers, jobs and commands alike, and to writes by other
+// processes once they are noticed. fn runs on the writing goroutine right
+// after the write, so it must be quick and must not write itself.
+func (db *DB) OnChange(fn func(Change)) {
+	db.changes.mu.Lock()
+	defer db.changes.mu.Unlock()
+	db.changes.subscribers = append(db.changes.subscribers, fn)
+}
+
+// Generation counts the changes so far. Anything derived from the database
+// at one generation, such as a rendered page, is current for as long as the
+// generation stays the same.
+func (db *DB) Generation() uint64 {
+	return db.changes.generation.Load()
+}
+
+// changed tells the subscribers about a change
+func (db *DB) changed(c Change) {
+	db.changes.generation.Add(1)
+	db.changes.mu.RLock()
+	defer db.changes.mu.RUnlock()
+	for _, fn := range db.changes.subscribers {
+		fn(c)
+	}
+}
+
+// invalidate reports a change to the given papers
+func (db *DB) invalidate(paperIDs ...string) {
+	db.changed(Change{Papers: paperIDs})
+}
+
+// invalidateAll reports a change that may have touched any paper
+func (db *DB) invalidateAll() {
+	db.changed(Change{All: true})
+}
+
+// dataVersion returns SQLite's data_version of the write connection. Every
+// write of this process goes through that connection, and the version only
+// moves for commits made by other connections, so it tells when another
+// process wrote.
+func (db *DB) dataVersion() (int64, error) {
+	var version int64
+	err := db.DB.Get(&version, "PRAGMA data_version")
+	return version, err
+}
+
+// watchExternalWrites reports a change whenever another process commits to
+// the database after the given data version
+func (db *DB) watchExternalWrites(last int64) {
+	ticker := time.NewTicker(externalCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.changes.stop:
+			return
+		case <-ticker.C:
+		}
+
+		version, err := db.dataVersion()
+		if err != nil {
+			log.Printf("Error checking for database changes: %v", err)
+			continue
+		}
+		if version != last {
+			db.invalidateAll()
+		}
+		last = version
+	}
+}
@@ -1,22 +1,110 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	"github.com/ngx/arxiv-go-nest/internal/models"
 )
 
 //go:embed schema.sql
 var schemaSQL string
 
+// driverName is the SQLite driver with the functions the queries here use
+const driverName = "sqlite3_nest"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fold", fold, true)
+		},
+	})
+}
+
+// fold case-folds text like search.Normalize does queries, for matching
+// them with LIKE, which on its own only ignores the case of ASCII letters.
+// Values that aren't text, such as NULL, fold to empty text.
+func fold(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.ToLower(v)
+	case []byte:
+		return strings.ToLower(string(v))
+	}
+	return ""
+}
+
+// columnMigrations lists columns added after a table was first created.
+// CREATE TABLE IF NOT EXISTS leaves existing tables untouched, so older
+// databases get these columns via ALTER TABLE before the schema runs.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"papers", "html_url", "TEXT DEFAULT ''"},
+	{"papers", "html_checked_at", "DATETIME"},
+	{"library", "priority", "INTEGER DEFAULT 0"},
+	{"library", "note", "TEXT DEFAULT ''"},
+	{"papers", "abstract_words", "INTEGER DEFAULT 0"},
+	{"papers", "license", "TEXT DEFAULT ''"},
+	{"tags", "description", "TEXT DEFAULT ''"},
+	{"papers", "content_hash", "TEXT DEFAULT ''"},
+	{"papers", "last_seen_at", "DATETIME"},
+	{"papers", "comment", "TEXT DEFAULT ''"},
+	{"tags", "owner", "TEXT DEFAULT ''"},
+	{"papers", "links_checked_at", "DATETIME"},
+	{"papers", "doi", "TEXT DEFAULT ''"},
+	{"papers", "journal_ref", "TEXT DEFAULT ''"},
+}
+
+// readConnections is the size of the pool for reads
+const readConnections = 4
+
 // DB wraps sqlx.DB with additional methods
 type DB struct {
 	*sqlx.DB
+
+	// readers is a read-only pool that Get and Select use, so independent
+	// queries (such as those of a page) can run at the same time; nil for
+	// in-memory databases, which can't be shared between connections
+	readers *sqlx.DB
+
+	// replica is the optional read-only copy heavy queries run on (see
+	// OpenReplica), shared with the handles returned by ForClient and
+	// WithContext; fallback is the pool a handle reading the replica
+	// retries failed queries on
+	replica  *atomic.Pointer[sqlx.DB]
+	fallback *sqlx.DB
+
+	// slow is the optional slow query log (see SetSlowQueryLog), shared
+	// with the handles returned by WithContext
+	slow *atomic.Pointer[slowLog]
+
+	// ctx is the context queries are traced under (see WithContext)
+	ctx context.Context
+
+	// client is the browser whose personal tags are visible alongside the
+	// shared ones (see ForClient)
+	client string
+
+	// cache holds hot papers and their tags, and counts the paper and
+	// library counts every page shows; both are shared with the handles
+	// returned by ForClient and WithContext
+	cache  *paperCache
+	counts *countCache
+
+	// changes reaches the caches after every write
+	changes *changeBus
 }
 
 // New creates a new database connection and runs migrations
@@ -27,8 +115,13 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	// Open database
-	sqlxDB, err := sqlx.Open("sqlite3", dbPath)
+	// Open database. In WAL mode readers and the writer don't block each
+	// other, so the read pool below can serve pages while a fetch writes.
+	dsn := dbPath
+	if dbPath != ":memory:" {
+		dsn = fileDSN(dbPath, "_journal_mode=WAL")
+	}
+	sqlxDB, err := sqlx.Open(driverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -37,7 +130,9 @@ func New(dbPath string) (*DB, error) {
 	sqlxDB.SetMaxOpenConns(1) // SQLite works best with single connection
 	sqlxDB.SetMaxIdleConns(1)
 
-	db := &DB{DB: sqlxDB}
+	db := &DB{DB: sqlxDB, slow: new(atomic.Pointer[slowLog]), replica: new(atomic.Pointer[sqlx.DB]), cache: newPaperCache(), counts: newCountCache(), changes: newChangeBus()}
+	db.OnChange(db.cache.apply)
+	db.OnChange(db.counts.apply)
 
 	// Run migrations
 	if err := db.migrate(); err != nil {
@@ -45,25 +140,183 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Make sure the schema matches the models before serving anything
+	if err := db.CheckSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// SQLite lets any number of connections read at once, while writes
+	// keep going through the single connection above
+	if dbPath != ":memory:" {
+		readers, err := sqlx.Open(driverName, fileDSN(dbPath, "mode=ro&_journal_mode=WAL"))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open read pool: %w", err)
+		}
+		readers.SetMaxOpenConns(readConnections)
+		readers.SetMaxIdleConns(readConnections)
+		db.readers = readers
+
+		// An in-memory database can't be written from elsewhere
+		version, err := db.dataVersion()
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to read data version: %w", err)
+		}
+		go db.watchExternalWrites(version)
+	}
+
 	return db, nil
 }
 
+// fileDSN is the URI of the database file at path with the given
+// connection parameters
+func fileDSN(path, params string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?" + params
+}
+
 // migrate runs the schema migrations
 func (db *DB) migrate() error {
+	for _, m := range columnMigrations {
+		if err := db.ensureColumn(m.table, m.column, m.definition); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	if err := db.rebuildTags(); err != nil {
+		return fmt.Errorf("failed to make tag names unique per owner: %w", err)
+	}
+
 	_, err := db.Exec(schemaSQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
+
+	if err := db.backfillAbstractWords(); err != nil {
+		return err
+	}
+	if err := db.backfillEntities(); err != nil {
+		return err
+	}
+	if err := db.backfillMinhash(); err != nil {
+		return err
+	}
+	return db.backfillRollups()
+}
+
+// rebuildTags rebuilds the tags table of databases from before personal
+// tags, where a name was unique across all tags, so that names are unique
+// per owner. SQLite can't drop a column's constraint, so the rows are
+// copied, IDs and all, into a table created the way schema.sql does.
+func (db *DB) rebuildTags() error {
+	var definition string
+	err := db.Get(&definition, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'tags'")
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(definition, "name TEXT UNIQUE") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		for _, stmt := range []string{
+			`CREATE TABLE tags_rebuilt (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				owner TEXT DEFAULT '',
+				UNIQUE (name, owner)
+			)`,
+			`INSERT INTO tags_rebuilt (id, name, description, owner)
+				SELECT id, name, COALESCE(description, ''), COALESCE(owner, '') FROM tags`,
+			`DROP TABLE tags`,
+			`ALTER TABLE tags_rebuilt RENAME TO tags`,
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillAbstractWords computes the abstract word count for papers stored
+// before it was recorded at ingest
+func (db *DB) backfillAbstractWords() error {
+	var papers []struct {
+		ID       string `db:"id"`
+		Abstract string `db:"abstract"`
+	}
+	err := db.Select(&papers, "SELECT id, abstract FROM papers WHERE abstract_words = 0 AND COALESCE(abstract, '') != ''")
+	if err != nil {
+		return fmt.Errorf("failed to find papers without word counts: %w", err)
+	}
+
+	for _, p := range papers {
+		if _, err := db.Exec("UPDATE papers SET abstract_words = ? WHERE id = ?", models.WordCount(p.Abstract), p.ID); err != nil {
+			return fmt.Errorf("failed to backfill word count for %s: %w", p.ID, err)
+		}
+	}
 	return nil
 }
 
+// ensureColumn adds a column to an existing table if it is missing.
+// Tables that don't exist yet are skipped; the schema creates them in full.
+func (db *DB) ensureColumn(table, column, definition string) error {
+	var columns []struct {
+		Name string `db:"name"`
+	}
+	if err := db.Select(&columns, "SELECT name FROM pragma_table_info(?)", table); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	for _, c := range columns {
+		if c.Name == column {
+			return nil
+		}
+	}
+
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
+	db.changes.stopOnce.Do(func() { close(db.changes.stop) })
+	if db.readers != nil {
+		db.readers.Close()
+	}
+	if replica := db.replica.Swap(nil); replica != nil {
+		replica.Close()
+	}
 	return db.DB.Close()
 }
 
-// Transaction executes a function within a database transaction
+// reads returns the pool to run read-only queries on
+func (db *DB) reads() *sqlx.DB {
+	if db.readers != nil {
+		return db.readers
+	}
+	return db.DB
+}
+
+// Transaction executes a function within a database transaction. The
+// whole transaction is run again while the database is busy, so fn must
+// not have effects outside it.
 func (db *DB) Transaction(fn func(*sqlx.Tx) error) error {
+	return retryBusy(func() error {
+		return db.transaction(fn)
+	})
+}
+
+// transaction runs fn within a single database transaction
+func (db *DB) transaction(fn func(*sqlx.Tx) error) error {
 	tx, err := db.Beginx()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
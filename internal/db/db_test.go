@@ -0,0 +1,263 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestMigrateAddsMissingColumns(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	// Create a papers table as it looked before later columns were added
+	legacy, err := sqlx.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = legacy.Exec(`CREATE TABLE papers (
+		id TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		abstract TEXT,
+		authors TEXT,
+		categories TEXT,
+		published_at DATETIME,
+		updated_at DATETIME,
+		pdf_url TEXT,
+		arxiv_url TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	legacy.Exec(`INSERT INTO papers (id, title, abstract, authors, categories, published_at, updated_at, pdf_url, arxiv_url)
+		VALUES ('2301.12345', 'Legacy Paper', 'An older abstract', '', '', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, '', '')`)
+	legacy.Close()
+
+	db, err := New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("New failed on legacy database: %v", err)
+	}
+	defer db.Close()
+
+	for _, m := range columnMigrations {
+		var count int
+		if err := db.Get(&count, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", m.table, m.column); err != nil {
+			t.Fatalf("Failed to inspect table %s: %v", m.table, err)
+		}
+		if count != 1 {
+			t.Errorf("Expected column %s.%s to exist after migration", m.table, m.column)
+		}
+	}
+
+	paper, err := db.GetPaperByID("2301.12345")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed on migrated database: %v", err)
+	}
+	if paper.Title != "Legacy Paper" {
+		t.Errorf("Expected title 'Legacy Paper', got '%s'", paper.Title)
+	}
+	if paper.AbstractWords != 3 {
+		t.Errorf("Expected abstract word count to be backfilled, got %d", paper.AbstractWords)
+	}
+}
+
+func TestMigrateTagNamesPerOwner(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	// Tag names used to be unique across all tags
+	legacy, err := sqlx.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE NOT NULL)`,
+		`CREATE TABLE paper_tags (paper_id TEXT, tag_id INTEGER, PRIMARY KEY (paper_id, tag_id),
+			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE)`,
+		`INSERT INTO tags (id, name) VALUES (7, 'to-read')`,
+		`INSERT INTO paper_tags (paper_id, tag_id) VALUES ('2301.12345', 7)`,
+	} {
+		if _, err := legacy.Exec(stmt); err != nil {
+			t.Fatalf("Failed to set up legacy database: %v", err)
+		}
+	}
+	legacy.Close()
+
+	db, err := New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("New failed on legacy database: %v", err)
+	}
+	defer db.Close()
+
+	if id, err := db.CreateTag("to-read"); err != nil || id != 7 {
+		t.Errorf("Expected the shared tag to keep its ID, got %d, %v", id, err)
+	}
+	var tagged int
+	if err := db.Get(&tagged, "SELECT COUNT(*) FROM paper_tags WHERE tag_id = 7"); err != nil || tagged != 1 {
+		t.Errorf("Expected the tag's papers to be kept, got %d, %v", tagged, err)
+	}
+	mine, err := db.ForClient("alice").CreatePersonalTag("skimmed")
+	if err != nil {
+		t.Fatalf("CreatePersonalTag failed: %v", err)
+	}
+	if id, err := db.CreateTag("skimmed"); err != nil || id == mine {
+		t.Errorf("Expected a shared tag next to the personal one, got %d, %v", id, err)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	database, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer database.Close()
+
+	var buf bytes.Buffer
+	database.SetSlowQueryLog(time.Nanosecond, &buf)
+
+	var count int
+	if err := database.Get(&count, "SELECT COUNT(*) FROM papers WHERE title LIKE ?", "%graph%"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Slow query", "SELECT COUNT(*) FROM papers WHERE title LIKE ?", "args: [%graph%]", "plan:", "SCAN papers"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	database.SetSlowQueryLog(0, &buf)
+	if _, err := database.Exec("DELETE FROM papers"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output when disabled, got %q", buf.String())
+	}
+}
+
+func TestCheckSchemaReportsDrift(t *testing.T) {
+	database, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.CheckSchema(); err != nil {
+		t.Fatalf("Expected fresh schema to match models, got: %v", err)
+	}
+
+	// Simulate a partial upgrade: a column dropped and another retyped
+	for _, stmt := range []string{
+		"ALTER TABLE papers DROP COLUMN license",
+		"ALTER TABLE archive_stats RENAME TO archive_stats_old",
+		"CREATE TABLE archive_stats (id INTEGER PRIMARY KEY, topic TEXT, query TEXT, total_results BLOB, recorded_at DATETIME)",
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("Failed to alter schema: %v", err)
+		}
+	}
+
+	err = database.CheckSchema()
+	if err == nil {
+		t.Fatal("Expected drift to be reported")
+	}
+	for _, want := range []string{"papers.license is missing (Paper.License)", "archive_stats.total_results is BLOB"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestRetryBusy(t *testing.T) {
+	busy := fmt.Errorf("failed to save: %w", sqlite3.Error{Code: sqlite3.ErrBusy})
+	if !IsBusy(busy) {
+		t.Fatal("Expected a wrapped SQLITE_BUSY to be busy")
+	}
+
+	// Busy errors are retried until the operation succeeds
+	calls := 0
+	err := retryBusy(func() error {
+		calls++
+		if calls < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third attempt, got %v after %d", err, calls)
+	}
+
+	// ...but only busyAttempts times
+	calls = 0
+	err = retryBusy(func() error {
+		calls++
+		return busy
+	})
+	if !IsBusy(err) || calls != busyAttempts {
+		t.Errorf("Expected the busy error after %d attempts, got %v after %d", busyAttempts, err, calls)
+	}
+
+	// Other errors are returned right away
+	calls = 0
+	other := errors.New("constraint failed")
+	if err := retryBusy(func() error { calls++; return other }); err != other || calls != 1 {
+		t.Errorf("Expected other errors without retry, got %v after %d", err, calls)
+	}
+}
+
+func TestReadsDuringWrite(t *testing.T) {
+	db, err := New(t.TempDir() + "/arxiv.db")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for name, handle := range map[string]*sqlx.DB{"writer": db.DB, "readers": db.readers} {
+		var mode string
+		if err := handle.Get(&mode, "PRAGMA journal_mode"); err != nil || mode != "wal" {
+			t.Errorf("Expected the %s in WAL mode, got %q, %v", name, mode, err)
+		}
+	}
+
+	// A write in progress, such as a fetch, doesn't hold up reads
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO tags (name) VALUES ('pending')"); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var n int
+		done <- db.readers.Get(&n, "SELECT COUNT(*) FROM tags")
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the read to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the read not to wait for the write")
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// UndeliveredPapers returns the paper IDs, in the given order, that were
+// not yet announced on the channel
+func (db *DB) UndeliveredPapers(channel string, paperIDs []string) ([]string, error) {
+	if len(paperIDs) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(
+		"SELECT paper_id FROM deliveries WHERE channel = ? AND status = ? AND paper_id IN (?)",
+		channel, models.DeliverySent, paperIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var sent []string
+	if err := db.Select(&sent, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to fetch deliveries: %w", err)
+	}
+
+	done := make(map[string]bool, len(sent))
+	for _, id := range sent {
+		done[id] = true
+	}
+	var ids []string
+	for _, id := range paperIDs {
+		if !done[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// RecordDeliveries records the outcome of announcing papers on a channel:
+// sent when sendErr is nil, failed otherwise. A failure never overwrites an
+// earlier successful delivery.
+func (db *DB) RecordDeliveries(channel string, paperIDs []string, digest bool, sendErr error, at time.Time) error {
+	status, message := models.DeliverySent, ""
+	if sendErr != nil {
+		status, message = models.DeliveryFailed, sendErr.Error()
+	}
+
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		for _, id := range paperIDs {
+			if _, err := tx.Exec(`
+				INSERT INTO deliveries (paper_id, channel, status, error, digest, attempted_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT (paper_id, channel) DO UPDATE SET
+					status = excluded.status, error = excluded.error,
+					digest = excluded.digest, attempted_at = excluded.attempted_at
+				WHERE deliveries.status != ?
+			`, id, channel, status, message, digest, at.UTC(), models.DeliverySent); err != nil {
+				return fmt.Errorf("failed to record delivery: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetDeliveries returns the most recent deliveries, newest first, on one
+// channel or, with channel empty, on all of them
+func (db *DB) GetDeliveries(channel string, limit int) ([]models.Delivery, error) {
+	query := `
+		SELECT d.paper_id, COALESCE(p.title, '') AS title, d.channel, d.status, d.error, d.digest, d.attempted_at
+		FROM deliveries d
+		LEFT JOIN papers p ON p.id = d.paper_id
+		WHERE ? = '' OR d.channel = ?
+		ORDER BY d.attempted_at DESC, d.paper_id
+		LIMIT ?
+	`
+	var deliveries []models.Delivery
+	if err := db.Select(&deliveries, query, channel, channel, limit); err != nil {
+		return nil, fmt.Errorf("failed to fetch deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// GetDeliveryChannels returns the names of the channels with deliveries
+func (db *DB) GetDeliveryChannels() ([]string, error) {
+	var channels []string
+	if err := db.Select(&channels, "SELECT DISTINCT channel FROM deliveries ORDER BY channel"); err != nil {
+		return nil, fmt.Errorf("failed to fetch delivery channels: %w", err)
+	}
+	return channels, nil
+}
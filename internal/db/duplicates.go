@@ -0,0 +1,221 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/dupes"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrNotInPair is returned when merging a duplicate pair into a paper that
+// isn't one of the two
+var ErrNotInPair = errors.New("paper is not part of the duplicate pair")
+
+// setPaperMinhash replaces the MinHash bands recorded for a paper with
+// those of its abstract
+func setPaperMinhash(e sqlx.Execer, paperID, abstract string) error {
+	if _, err := e.Exec("DELETE FROM paper_minhash WHERE paper_id = ?", paperID); err != nil {
+		return fmt.Errorf("failed to clear minhash: %w", err)
+	}
+	for band, hash := range dupes.Signature(abstract) {
+		if _, err := e.Exec(
+			"INSERT INTO paper_minhash (paper_id, band, hash) VALUES (?, ?, ?)",
+			paperID, band, hash,
+		); err != nil {
+			return fmt.Errorf("failed to add minhash band: %w", err)
+		}
+	}
+	return nil
+}
+
+// suggestDuplicates compares a stored paper's abstract with those of the
+// papers sharing a MinHash band with it, and suggests merging those that
+// are near-identical. The paper's pending suggestions are replaced, as its
+// abstract may have changed; dismissed pairs stay dismissed.
+func suggestDuplicates(s verifyStore, paperID, abstract string, now time.Time) error {
+	if _, err := s.Exec(
+		"DELETE FROM duplicates WHERE status = ? AND (paper_id = ? OR duplicate_id = ?)",
+		models.DuplicatePending, paperID, paperID,
+	); err != nil {
+		return fmt.Errorf("failed to clear duplicates: %w", err)
+	}
+
+	var candidates []struct {
+		ID       string `db:"id"`
+		Abstract string `db:"abstract"`
+	}
+	if err := s.Select(&candidates, `
+		SELECT id, COALESCE(abstract, '') AS abstract FROM papers
+		WHERE id IN (
+			SELECT o.paper_id FROM paper_minhash m
+			JOIN paper_minhash o ON o.band = m.band AND o.hash = m.hash AND o.paper_id != m.paper_id
+			WHERE m.paper_id = ?
+		)
+	`, paperID); err != nil {
+		return fmt.Errorf("failed to find duplicate candidates: %w", err)
+	}
+
+	for _, c := range candidates {
+		similarity := dupes.Similarity(abstract, c.Abstract)
+		if similarity < dupes.Threshold {
+			continue
+		}
+		first, second := paperID, c.ID
+		if second < first {
+			first, second = second, first
+		}
+		if _, err := s.Exec(`
+			INSERT INTO duplicates (paper_id, duplicate_id, similarity, found_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(paper_id, duplicate_id) DO UPDATE SET similarity = excluded.similarity
+		`, first, second, similarity, now.UTC()); err != nil {
+			return fmt.Errorf("failed to suggest duplicate %s: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// backfillMinhash computes the MinHash bands of every stored paper the
+// first time a database is opened by a version that computes them, and
+// suggests the duplicates among them. Until some abstract is long enough
+// to have bands this runs on every start.
+func (db *DB) backfillMinhash() error {
+	var rows int
+	if err := db.Get(&rows, "SELECT COUNT(*) FROM paper_minhash"); err != nil {
+		return fmt.Errorf("failed to count minhash bands: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var papers []struct {
+		ID       string `db:"id"`
+		Abstract string `db:"abstract"`
+	}
+	if err := db.Select(&papers, "SELECT id, COALESCE(abstract, '') AS abstract FROM papers"); err != nil {
+		return fmt.Errorf("failed to read papers for minhash: %w", err)
+	}
+	if len(papers) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		for _, p := range papers {
+			if err := setPaperMinhash(tx, p.ID, p.Abstract); err != nil {
+				return fmt.Errorf("failed to compute minhash for %s: %w", p.ID, err)
+			}
+		}
+		for _, p := range papers {
+			if err := suggestDuplicates(tx, p.ID, p.Abstract, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDuplicates returns the pending duplicate suggestions with both
+// papers, most recently found first. Pairs whose papers are no longer
+// stored are left out.
+func (db *DB) GetDuplicates() ([]models.Duplicate, error) {
+	var pairs []models.Duplicate
+	err := db.Select(&pairs, `
+		SELECT id, paper_id, duplicate_id, similarity, status, found_at FROM duplicates
+		WHERE status = ?
+		ORDER BY found_at DESC, id DESC
+	`, models.DuplicatePending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch duplicates: %w", err)
+	}
+
+	var ids []string
+	for _, d := range pairs {
+		ids = append(ids, d.PaperID, d.DuplicateID)
+	}
+	papers, err := db.GetPapersByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Paper, len(papers))
+	for _, p := range papers {
+		byID[p.ID] = p
+	}
+
+	list := pairs[:0]
+	for _, d := range pairs {
+		first, ok1 := byID[d.PaperID]
+		second, ok2 := byID[d.DuplicateID]
+		if ok1 && ok2 {
+			d.Papers = []models.Paper{first, second}
+			list = append(list, d)
+		}
+	}
+	return list, nil
+}
+
+// DismissDuplicate marks a suggested pair as not duplicates, so it isn't
+// suggested again. It returns sql.ErrNoRows for an unknown pair.
+func (db *DB) DismissDuplicate(id int) error {
+	result, err := db.Exec("UPDATE duplicates SET status = ? WHERE id = ?", models.DuplicateDismissed, id)
+	if err != nil {
+		return fmt.Errorf("failed to dismiss duplicate: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MergeDuplicate merges a suggested pair into keepID, one of its papers:
+// the other paper's library entry (unless keepID is saved already), tags
+// and shelf entries move to keepID, and the other paper goes to the trash,
+// from where it can be restored as it was. It returns the trashed paper's
+// ID, or sql.ErrNoRows for an unknown or dismissed pair.
+func (db *DB) MergeDuplicate(id int, keepID string) (string, error) {
+	var pair models.Duplicate
+	err := db.Get(&pair, "SELECT id, paper_id, duplicate_id, similarity, status, found_at FROM duplicates WHERE id = ? AND status = ?", id, models.DuplicatePending)
+	if err != nil {
+		return "", err
+	}
+	dropID := pair.PaperID
+	switch keepID {
+	case pair.PaperID:
+		dropID = pair.DuplicateID
+	case pair.DuplicateID:
+	default:
+		return "", ErrNotInPair
+	}
+
+	defer db.invalidate(keepID, dropID)
+	err = db.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO library (paper_id, is_read, saved_at, priority, note)
+			SELECT ?, is_read, saved_at, priority, note FROM library WHERE paper_id = ?
+		`, keepID, dropID); err != nil {
+			return fmt.Errorf("failed to merge library entry: %w", err)
+		}
+		if _, err := tx.Exec(
+			"INSERT OR IGNORE INTO paper_tags (paper_id, tag_id) SELECT ?, tag_id FROM paper_tags WHERE paper_id = ?",
+			keepID, dropID,
+		); err != nil {
+			return fmt.Errorf("failed to merge tags: %w", err)
+		}
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO shelf_papers (shelf_id, paper_id, is_read, priority, added_at)
+			SELECT shelf_id, ?, is_read, priority, added_at FROM shelf_papers WHERE paper_id = ?
+		`, keepID, dropID); err != nil {
+			return fmt.Errorf("failed to merge shelf entries: %w", err)
+		}
+
+		_, err := trashPaper(tx, dropID, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return dropID, nil
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+const duplicateAbstract = `Single-cell sequencing reveals the diversity of cell states in developing tissues,
+but linking these states to lineage remains difficult. We present a computational method that
+reconstructs lineage trees from paired transcriptomic and clonal barcoding data, and apply it to
+the developing mouse cortex.`
+
+func TestDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+	store := func(id, abstract string) {
+		t.Helper()
+		paper := &models.Paper{ID: id, Title: "Lineage trees", Abstract: abstract, PublishedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+	store("2401.00001", duplicateAbstract)
+	store("2401.00002", "We study the convergence of stochastic gradient descent on overparameterized neural networks and show that training reaches a global minimum at a linear rate.")
+	store("biorxiv:2024.01.15.575123", strings.Replace(duplicateAbstract, "difficult.", "challenging.", 1))
+
+	dupes, err := db.GetDuplicates()
+	if err != nil {
+		t.Fatalf("GetDuplicates failed: %v", err)
+	}
+	if len(dupes) != 1 {
+		t.Fatalf("Expected 1 suggested pair, got %+v", dupes)
+	}
+	pair := dupes[0]
+	if pair.PaperID != "2401.00001" || pair.DuplicateID != "biorxiv:2024.01.15.575123" || len(pair.Papers) != 2 {
+		t.Fatalf("Expected the arXiv and bioRxiv papers, got %+v", pair)
+	}
+
+	// The pair survives the database being opened by a version computing
+	// signatures for the first time
+	db.Exec("DELETE FROM paper_minhash")
+	db.Exec("DELETE FROM duplicates")
+	if err := db.backfillMinhash(); err != nil {
+		t.Fatalf("backfillMinhash failed: %v", err)
+	}
+	dupes, _ = db.GetDuplicates()
+	if len(dupes) != 1 {
+		t.Fatalf("Expected the backfill to suggest the pair, got %+v", dupes)
+	}
+	pair = dupes[0]
+
+	// Merging moves the library entry and tags and trashes the other paper
+	db.SaveWithTags("biorxiv:2024.01.15.575123", 2, "from the lab meeting", []string{"lineage"}, false)
+	if _, err := db.MergeDuplicate(pair.ID, "2401.00002"); err != ErrNotInPair {
+		t.Errorf("Expected ErrNotInPair, got %v", err)
+	}
+	dropped, err := db.MergeDuplicate(pair.ID, "2401.00001")
+	if err != nil {
+		t.Fatalf("MergeDuplicate failed: %v", err)
+	}
+	if dropped != "biorxiv:2024.01.15.575123" {
+		t.Errorf("Expected the bioRxiv paper to be dropped, got %s", dropped)
+	}
+	paper, err := db.GetPaperByID("2401.00001")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if !paper.InLibrary || paper.Note != "from the lab meeting" || len(paper.Tags) != 1 {
+		t.Errorf("Expected the library entry and tag to move, got %+v", paper)
+	}
+	if trashed, _ := db.IsTrashed(dropped); !trashed {
+		t.Error("Expected the merged paper in the trash")
+	}
+	if dupes, _ := db.GetDuplicates(); len(dupes) != 0 {
+		t.Errorf("Expected no suggestions after merging, got %+v", dupes)
+	}
+
+	// Restoring doesn't suggest the pair again, storing a new copy does
+	if err := db.RestorePaper(dropped); err != nil {
+		t.Fatalf("RestorePaper failed: %v", err)
+	}
+	if dupes, _ := db.GetDuplicates(); len(dupes) != 0 {
+		t.Errorf("Expected no suggestions after restoring, got %+v", dupes)
+	}
+	store("medrxiv:2024.01.16.000001", duplicateAbstract)
+	dupes, _ = db.GetDuplicates()
+	if len(dupes) != 2 {
+		t.Fatalf("Expected the new copy paired with both papers, got %+v", dupes)
+	}
+
+	// Dismissed pairs aren't suggested again when the paper is refetched
+	for _, d := range dupes {
+		if err := db.DismissDuplicate(d.ID); err != nil {
+			t.Fatalf("DismissDuplicate failed: %v", err)
+		}
+	}
+	store("medrxiv:2024.01.16.000001", duplicateAbstract+" Code is available.")
+	if dupes, _ := db.GetDuplicates(); len(dupes) != 0 {
+		t.Errorf("Expected dismissed pairs to stay dismissed, got %+v", dupes)
+	}
+	if _, err := db.MergeDuplicate(dupes[0].ID, "2401.00001"); err == nil {
+		t.Error("Expected a dismissed pair not to merge")
+	}
+}
+
+func TestDuplicatesWrittenWithPaper(t *testing.T) {
+	db := setupTestDB(t)
+	first := &models.Paper{ID: "2401.00001", Title: "Lineage trees", Abstract: duplicateAbstract, PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(first); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+
+	// Without somewhere to suggest the pair, the copy isn't stored at all,
+	// signature included, so the next fetch doesn't skip it by its hash
+	if _, err := db.Exec("DROP TABLE duplicates"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+	copied := &models.Paper{ID: "biorxiv:2024.01.15.575123", Title: "Lineage trees", Abstract: duplicateAbstract, PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(copied); err == nil {
+		t.Fatal("Expected the upsert to fail")
+	}
+	var stored int
+	db.Get(&stored, "SELECT (SELECT COUNT(*) FROM papers WHERE id = ?) + (SELECT COUNT(*) FROM paper_minhash WHERE paper_id = ?)", copied.ID, copied.ID)
+	if stored != 0 {
+		t.Errorf("Expected the copy and its signature rolled back, got %d rows", stored)
+	}
+
+	if _, err := db.Exec(schemaSQL); err != nil {
+		t.Fatalf("Failed to recreate table: %v", err)
+	}
+	if changed, err := db.UpsertPaperStatus(copied); err != nil || !changed {
+		t.Fatalf("Expected the copy to be written on the next fetch, got %v, %v", changed, err)
+	}
+	if dupes, _ := db.GetDuplicates(); len(dupes) != 1 {
+		t.Errorf("Expected the pair to be suggested, got %+v", dupes)
+	}
+}
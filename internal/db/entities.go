@@ -0,0 +1,89 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/entities"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// setPaperEntities replaces the datasets and benchmarks recorded for a
+// paper with those extracted from its title and abstract
+func setPaperEntities(e sqlx.Execer, paperID, title, abstract string) error {
+	if _, err := e.Exec("DELETE FROM paper_entities WHERE paper_id = ?", paperID); err != nil {
+		return fmt.Errorf("failed to clear entities: %w", err)
+	}
+	for _, entity := range entities.Extract(title, abstract) {
+		if _, err := e.Exec(
+			"INSERT OR IGNORE INTO paper_entities (paper_id, name, kind) VALUES (?, ?, ?)",
+			paperID, entity.Name, entity.Kind,
+		); err != nil {
+			return fmt.Errorf("failed to add entity %q: %w", entity.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetPaperEntities returns the datasets and benchmarks a paper mentions
+func (db *DB) GetPaperEntities(paperID string) ([]models.Entity, error) {
+	var list []models.Entity
+	err := db.Select(&list,
+		"SELECT name, kind FROM paper_entities WHERE paper_id = ? ORDER BY name COLLATE NOCASE",
+		paperID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch paper entities: %w", err)
+	}
+	return list, nil
+}
+
+// GetEntities returns every dataset and benchmark mentioned by a stored
+// paper with the number of papers mentioning it, most mentioned first
+func (db *DB) GetEntities() ([]models.Entity, error) {
+	var list []models.Entity
+	err := db.Select(&list, `
+		SELECT name, kind, COUNT(*) AS papers FROM paper_entities
+		GROUP BY name COLLATE NOCASE
+		ORDER BY papers DESC, name COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch entities: %w", err)
+	}
+	return list, nil
+}
+
+// backfillEntities extracts entities for every stored paper the first
+// time a database is opened by a version that extracts them. Until some
+// paper mentions an entity this runs on every start, which only costs a
+// pass over the abstracts.
+func (db *DB) backfillEntities() error {
+	var rows int
+	if err := db.Get(&rows, "SELECT COUNT(*) FROM paper_entities"); err != nil {
+		return fmt.Errorf("failed to count entities: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var papers []struct {
+		ID       string `db:"id"`
+		Title    string `db:"title"`
+		Abstract string `db:"abstract"`
+	}
+	if err := db.Select(&papers, "SELECT id, title, COALESCE(abstract, '') AS abstract FROM papers"); err != nil {
+		return fmt.Errorf("failed to read papers for entities: %w", err)
+	}
+	if len(papers) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		for _, p := range papers {
+			if err := setPaperEntities(tx, p.ID, p.Title, p.Abstract); err != nil {
+				return fmt.Errorf("failed to extract entities for %s: %w", p.ID, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package db
+
+// GetFeatureOverrides returns feature flags overridden at runtime
+func (db *DB) GetFeatureOverrides() (map[string]bool, error) {
+	var rows []struct {
+		Name    string `db:"name"`
+		Enabled bool   `db:"enabled"`
+	}
+	if err := db.Select(&rows, "SELECT name, enabled FROM feature_flags"); err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		overrides[row.Name] = row.Enabled
+	}
+	return overrides, nil
+}
+
+// SetFeatureOverride stores a runtime override for a feature flag
+func (db *DB) SetFeatureOverride(name string, enabled bool) error {
+	query := `
+		INSERT INTO feature_flags (name, enabled, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET enabled = excluded.enabled, updated_at = excluded.updated_at
+	`
+	_, err := db.Exec(query, name, enabled)
+	return err
+}
+
+// DeleteFeatureOverride removes a runtime override so the config value applies again
+func (db *DB) DeleteFeatureOverride(name string) error {
+	_, err := db.Exec("DELETE FROM feature_flags WHERE name = ?", name)
+	return err
+}
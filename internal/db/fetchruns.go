@@ -0,0 +1,26 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// RecordFetchRun stores the outcome of a fetch
+func (db *DB) RecordFetchRun(run models.FetchRun) error {
+	_, err := db.Exec(`
+		INSERT INTO fetch_runs (scope, started_at, finished_at, fetched, stored, new_papers, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, run.Scope, run.StartedAt.UTC(), run.FinishedAt.UTC(), run.Fetched, run.Stored, run.New, run.Error)
+	return err
+}
+
+// GetFetchRuns returns up to limit of the most recent fetches, newest first
+func (db *DB) GetFetchRuns(limit int) ([]models.FetchRun, error) {
+	var runs []models.FetchRun
+	err := db.Select(&runs, "SELECT * FROM fetch_runs ORDER BY started_at DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch run history: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,312 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+)
+
+// ErrInvalidFilter is wrapped by the errors of structured filters that
+// can't be compiled, such as an unknown field or a malformed value
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// filterColumns are the SQL expressions matched by the filter fields, for
+// the papers p LEFT JOIN library l of paperQuery
+var filterColumns = map[string]string{
+	"title":          "p.title",
+	"abstract":       "p.abstract",
+	"authors":        "p.authors",
+	"comment":        "p.comment",
+	"journal_ref":    "p.journal_ref",
+	"published":      "p.published_at",
+	"updated":        "p.updated_at",
+	"saved":          "l.saved_at",
+	"abstract_words": "p.abstract_words",
+	"priority":       "COALESCE(l.priority, 0)",
+}
+
+// filterCompiler turns a search.Filter into a WHERE condition
+type filterCompiler struct {
+	// client sees its personal tags in tag conditions
+	client     string
+	conditions int
+}
+
+// compileFilter returns the condition matching f and its arguments, or ""
+// for an empty filter
+func compileFilter(f search.Filter, client string) (string, []interface{}, error) {
+	if f.Empty() {
+		return "", nil, nil
+	}
+	c := &filterCompiler{client: client}
+	return c.compile(f, "filter", 1)
+}
+
+// invalid returns an ErrInvalidFilter for the node at path
+func invalid(path, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidFilter, path, fmt.Sprintf(format, args...))
+}
+
+func (c *filterCompiler) compile(f search.Filter, path string, depth int) (string, []interface{}, error) {
+	if depth > search.MaxFilterDepth {
+		return "", nil, invalid(path, "nested more than %d levels deep", search.MaxFilterDepth)
+	}
+
+	kinds := 0
+	for _, set := range []bool{len(f.All) > 0, len(f.Any) > 0, f.Not != nil, f.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return "", nil, invalid(path, `set exactly one of "all", "any", "not" or "field"`)
+	}
+
+	switch {
+	case len(f.All) > 0:
+		return c.combine(f.All, path+".all", depth, " AND ")
+	case len(f.Any) > 0:
+		return c.combine(f.Any, path+".any", depth, " OR ")
+	case f.Not != nil:
+		condition, args, err := c.compile(*f.Not, path+".not", depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + condition + ")", args, nil
+	}
+
+	c.conditions++
+	if c.conditions > search.MaxFilterConditions {
+		return "", nil, invalid(path, "more than %d conditions", search.MaxFilterConditions)
+	}
+	return c.condition(f, path)
+}
+
+// combine joins the conditions of filters with op
+func (c *filterCompiler) combine(filters []search.Filter, path string, depth int, op string) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	for i, sub := range filters {
+		condition, subArgs, err := c.compile(sub, fmt.Sprintf("%s[%d]", path, i), depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, subArgs...)
+	}
+	return "(" + strings.Join(conditions, op) + ")", args, nil
+}
+
+// condition compiles a field comparison
+func (c *filterCompiler) condition(f search.Filter, path string) (string, []interface{}, error) {
+	path += "." + f.Field
+	kind, ok := search.FilterFields[f.Field]
+	if !ok {
+		return "", nil, invalid(path, "unknown field")
+	}
+
+	switch kind {
+	case search.FieldText:
+		if f.Op != "contains" {
+			return "", nil, invalid(path, `unknown op %q, use "contains"`, f.Op)
+		}
+		text, ok := f.Value.(string)
+		if text = search.Normalize(text); !ok || text == "" {
+			return "", nil, invalid(path, "value must be a non-empty string")
+		}
+		pattern := search.Contains(text)
+		if f.Field == "query" {
+			return `(fold(p.title) LIKE ? ESCAPE '\' OR fold(p.abstract) LIKE ? ESCAPE '\' OR fold(p.authors) LIKE ? ESCAPE '\')`,
+				[]interface{}{pattern, pattern, pattern}, nil
+		}
+		return "fold(" + filterColumns[f.Field] + `) LIKE ? ESCAPE '\'`, []interface{}{pattern}, nil
+
+	case search.FieldSet:
+		return c.setCondition(f, path)
+
+	case search.FieldDay:
+		column := filterColumns[f.Field]
+		days, err := filterValues(f, path, parseFilterDay)
+		if err != nil {
+			return "", nil, err
+		}
+		// Timestamps are stored as text starting with the day, so days
+		// compare as text; the upper bound is the start of the next day
+		switch f.Op {
+		case "gte":
+			return column + " >= ?", []interface{}{days[0].Format("2006-01-02")}, nil
+		case "lte":
+			return column + " < ?", []interface{}{days[0].AddDate(0, 0, 1).Format("2006-01-02")}, nil
+		}
+		return "(" + column + " >= ? AND " + column + " < ?)",
+			[]interface{}{days[0].Format("2006-01-02"), days[1].AddDate(0, 0, 1).Format("2006-01-02")}, nil
+
+	case search.FieldNumber:
+		column := filterColumns[f.Field]
+		numbers, err := filterValues(f, path, parseFilterNumber)
+		if err != nil {
+			return "", nil, err
+		}
+		switch f.Op {
+		case "eq":
+			return column + " = ?", []interface{}{numbers[0]}, nil
+		case "gte":
+			return column + " >= ?", []interface{}{numbers[0]}, nil
+		case "lte":
+			return column + " <= ?", []interface{}{numbers[0]}, nil
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{numbers[0], numbers[1]}, nil
+	}
+
+	// search.FieldFlag
+	if f.Op != "eq" {
+		return "", nil, invalid(path, `unknown op %q, use "eq"`, f.Op)
+	}
+	flag, ok := f.Value.(bool)
+	if !ok {
+		return "", nil, invalid(path, "value must be true or false")
+	}
+	var condition string
+	switch f.Field {
+	case "in_library":
+		condition = "l.paper_id IS NOT NULL"
+	case "read":
+		condition = "COALESCE(l.is_read, 0) = 1"
+	case "has_note":
+		condition = "COALESCE(l.note, '') != ''"
+	}
+	if !flag {
+		condition = "NOT (" + condition + ")"
+	}
+	return condition, nil, nil
+}
+
+// setCondition compiles a comparison with one of a paper's values
+func (c *filterCompiler) setCondition(f search.Filter, path string) (string, []interface{}, error) {
+	var values []string
+	switch f.Op {
+	case "eq":
+		value, ok := f.Value.(string)
+		if !ok || value == "" {
+			return "", nil, invalid(path, "value must be a non-empty string")
+		}
+		values = []string{value}
+	case "in", "all":
+		if f.Op == "all" && f.Field != "tag" {
+			return "", nil, invalid(path, `op "all" is only for tags`)
+		}
+		list, ok := f.Value.([]interface{})
+		if !ok || len(list) == 0 || len(list) > search.MaxFilterValues {
+			return "", nil, invalid(path, "value must be a list of 1 to %d strings", search.MaxFilterValues)
+		}
+		for _, v := range list {
+			value, ok := v.(string)
+			if !ok || value == "" {
+				return "", nil, invalid(path, "value must be a list of non-empty strings")
+			}
+			values = append(values, value)
+		}
+	default:
+		return "", nil, invalid(path, `unknown op %q, use "eq", "in" or, for tags, "all"`, f.Op)
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, value := range values {
+		switch f.Field {
+		case "id":
+			conditions = append(conditions, "p.id = ?")
+			args = append(args, value)
+		case "category":
+			conditions = append(conditions, `p.categories LIKE ? ESCAPE '\'`)
+			args = append(args, search.Contains(value))
+		case "tag":
+			conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM paper_tags pt
+			JOIN tags t ON pt.tag_id = t.id
+			WHERE pt.paper_id = p.id AND t.name = ? AND `+visibleTag+`
+		)`)
+			args = append(args, value, c.client)
+		case "entity":
+			conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM paper_entities e
+			WHERE e.paper_id = p.id AND e.name = ? COLLATE NOCASE
+		)`)
+			args = append(args, value)
+		case "license":
+			filter, ok := models.FindLicenseFilter(value)
+			if !ok {
+				return "", nil, invalid(path, "unknown license filter %q", value)
+			}
+			if filter.Pattern == "" {
+				conditions = append(conditions, "COALESCE(p.license, '') = ''")
+			} else {
+				conditions = append(conditions, "p.license LIKE ?")
+				args = append(args, filter.Pattern)
+			}
+		}
+	}
+
+	op := " OR "
+	if f.Op == "all" {
+		op = " AND "
+	}
+	return "(" + strings.Join(conditions, op) + ")", args, nil
+}
+
+// filterValues parses the operand of a comparison: one value for "eq",
+// "gte" and "lte", two for "between"
+func filterValues[T any](f search.Filter, path string, parse func(interface{}) (T, bool)) ([]T, error) {
+	var raw []interface{}
+	switch f.Op {
+	case "gte", "lte":
+		raw = []interface{}{f.Value}
+	case "eq":
+		if search.FilterFields[f.Field] == search.FieldDay {
+			return nil, invalid(path, `unknown op "eq", use "gte", "lte" or "between"`)
+		}
+		raw = []interface{}{f.Value}
+	case "between":
+		list, ok := f.Value.([]interface{})
+		if !ok || len(list) != 2 {
+			return nil, invalid(path, `"between" takes a list of two values`)
+		}
+		raw = list
+	default:
+		return nil, invalid(path, `unknown op %q`, f.Op)
+	}
+
+	values := make([]T, len(raw))
+	for i, v := range raw {
+		value, ok := parse(v)
+		if !ok {
+			return nil, invalid(path, "invalid value %v", v)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// parseFilterDay parses a "YYYY-MM-DD" day
+func parseFilterDay(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("2006-01-02", s)
+	return day, err == nil
+}
+
+// parseFilterNumber parses an integer, which JSON decodes as a float
+func parseFilterNumber(v interface{}) (int, bool) {
+	n, ok := v.(float64)
+	if !ok || n != math.Trunc(n) || math.Abs(n) > math.MaxInt32 {
+		return 0, false
+	}
+	return int(n), true
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+)
+
+func TestSearchPapers(t *testing.T) {
+	db := setupTestDB(t)
+	papers := []models.Paper{
+		{ID: "2401.00001", Title: "Diffusion for Images", Abstract: "We denoise.", Authors: "Alice", Categories: "cs.CV", PublishedAt: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)},
+		{ID: "2401.00002", Title: "Language Models", Abstract: "We scale transformers.", Authors: "Bob", Categories: "cs.CL, cs.LG", PublishedAt: time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)},
+		{ID: "2401.00003", Title: "Graph Learning", Abstract: "We propagate messages.", Authors: "Carol", Categories: "cs.LG", PublishedAt: time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)},
+	}
+	for i := range papers {
+		papers[i].UpdatedAt = papers[i].PublishedAt
+		if err := db.UpsertPaper(&papers[i]); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+	surveys, _ := db.CreateTag("surveys")
+	classics, _ := db.CreateTag("classics")
+	db.TagPaper("2401.00001", surveys)
+	db.TagPaper("2401.00001", classics)
+	db.TagPaper("2401.00002", surveys)
+	db.SaveToLibrary("2401.00002")
+	db.UpdateLibraryEntry("2401.00002", 3, "for the reading group")
+
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`{}`, "2401.00003 2401.00002 2401.00001"},
+		{`{"field": "category", "op": "in", "value": ["cs.CV", "cs.CL"]}`, "2401.00002 2401.00001"},
+		{`{"field": "published", "op": "between", "value": ["2024-01-10", "2024-02-20"]}`, "2401.00002 2401.00001"},
+		{`{"field": "published", "op": "lte", "value": "2024-01-10"}`, "2401.00001"},
+		{`{"field": "tag", "op": "all", "value": ["surveys", "classics"]}`, "2401.00001"},
+		{`{"field": "tag", "op": "in", "value": ["surveys", "classics"]}`, "2401.00002 2401.00001"},
+		{`{"not": {"field": "tag", "op": "eq", "value": "surveys"}}`, "2401.00003"},
+		{`{"any": [{"field": "title", "op": "contains", "value": "GRAPH"}, {"field": "priority", "op": "gte", "value": 3}]}`, "2401.00003 2401.00002"},
+		{`{"all": [{"field": "category", "op": "eq", "value": "cs.LG"}, {"field": "in_library", "op": "eq", "value": false}]}`, "2401.00003"},
+		{`{"field": "has_note", "op": "eq", "value": true}`, "2401.00002"},
+		{`{"field": "query", "op": "contains", "value": "  Transformers "}`, "2401.00002"},
+	}
+	for _, tt := range tests {
+		var filter search.Filter
+		if err := json.Unmarshal([]byte(tt.filter), &filter); err != nil {
+			t.Fatalf("Bad filter %s: %v", tt.filter, err)
+		}
+		got, total, err := db.SearchPapers(filter, models.SearchParams{Page: 1, PageSize: 10})
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		var ids []string
+		for _, p := range got {
+			ids = append(ids, p.ID)
+		}
+		if strings.Join(ids, " ") != tt.want || total != len(ids) {
+			t.Errorf("%s: expected %s, got %v (total %d)", tt.filter, tt.want, ids, total)
+		}
+	}
+}
+
+func TestSearchPapersRejectsInvalidFilters(t *testing.T) {
+	db := setupTestDB(t)
+
+	deep := `{"field": "id", "op": "eq", "value": "x"}`
+	for i := 0; i < search.MaxFilterDepth; i++ {
+		deep = fmt.Sprintf(`{"not": %s}`, deep)
+	}
+
+	for filter, message := range map[string]string{
+		`{"field": "colour", "op": "eq", "value": "red"}`:                     "filter.colour: unknown field",
+		`{"field": "title", "op": "eq", "value": "x"}`:                        `unknown op "eq"`,
+		`{"field": "category", "op": "all", "value": ["cs.LG"]}`:              "only for tags",
+		`{"field": "published", "op": "gte", "value": "last week"}`:           "invalid value",
+		`{"field": "priority", "op": "between", "value": [1]}`:                "two values",
+		`{"field": "read", "op": "eq", "value": "yes"}`:                       "true or false",
+		`{"any": [{"field": "id", "op": "eq", "value": "x"}, {}]}`:            "filter.any[1]: set exactly one",
+		`{"all": [{"field": "id", "op": "eq", "value": "x"}], "field": "id"}`: "set exactly one",
+		deep: "nested more than",
+	} {
+		var f search.Filter
+		if err := json.Unmarshal([]byte(filter), &f); err != nil {
+			t.Fatalf("Bad filter %s: %v", filter, err)
+		}
+		_, _, err := db.SearchPapers(f, models.SearchParams{Page: 1, PageSize: 10})
+		if !errors.Is(err, ErrInvalidFilter) || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: expected an invalid filter error with %q, got %v", filter, message, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Info describes the database for diagnostics
+type Info struct {
+	SQLiteVersion string           `json:"sqlite_version"`
+	SizeBytes     int64            `json:"size_bytes"`
+	Tables        []TableInfo      `json:"tables"`
+	Migrations    []MigrationState `json:"migrations"`
+
+	// SchemaError is the CheckSchema result, empty if the schema matches
+	SchemaError string `json:"schema_error,omitempty"`
+}
+
+// TableInfo is the number of rows in a table
+type TableInfo struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+// MigrationState reports whether a column migration has been applied
+type MigrationState struct {
+	Table   string `json:"table"`
+	Column  string `json:"column"`
+	Applied bool   `json:"applied"`
+}
+
+// Info collects the SQLite version, database size, row counts per table and
+// the state of the column migrations
+func (db *DB) Info() (*Info, error) {
+	info := &Info{}
+
+	if err := db.Get(&info.SQLiteVersion, "SELECT sqlite_version()"); err != nil {
+		return nil, fmt.Errorf("failed to read SQLite version: %w", err)
+	}
+
+	var pageCount, pageSize int64
+	if err := db.Get(&pageCount, "PRAGMA page_count"); err != nil {
+		return nil, fmt.Errorf("failed to read database size: %w", err)
+	}
+	if err := db.Get(&pageSize, "PRAGMA page_size"); err != nil {
+		return nil, fmt.Errorf("failed to read database size: %w", err)
+	}
+	info.SizeBytes = pageCount * pageSize
+
+	var tables []string
+	if err := db.Select(&tables, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		var rows int
+		// Table names come from sqlite_master, not user input
+		if err := db.Get(&rows, fmt.Sprintf("SELECT COUNT(*) FROM %q", table)); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		info.Tables = append(info.Tables, TableInfo{Name: table, Rows: rows})
+	}
+
+	for _, m := range columnMigrations {
+		columns, err := db.columnTypes(m.table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect table %s: %w", m.table, err)
+		}
+		_, applied := columns[m.column]
+		info.Migrations = append(info.Migrations, MigrationState{Table: m.table, Column: m.column, Applied: applied})
+	}
+
+	if err := db.CheckSchema(); err != nil {
+		info.SchemaError = err.Error()
+	}
+
+	return info, nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AddPaperKeywords records subscription keywords a paper matched when it
+// was fetched. Keywords already recorded are kept.
+func (db *DB) AddPaperKeywords(paperID string, keywords []string) error {
+	for _, keyword := range keywords {
+		if _, err := db.Exec(
+			"INSERT OR IGNORE INTO paper_keywords (paper_id, keyword) VALUES (?, ?)",
+			paperID, keyword,
+		); err != nil {
+			return fmt.Errorf("failed to add keyword %q: %w", keyword, err)
+		}
+	}
+	return nil
+}
+
+// GetSuggestedTags returns the keywords a paper matched that are not yet
+// one of its tags (compared case-insensitively)
+func (db *DB) GetSuggestedTags(paperID string) ([]string, error) {
+	suggestions, err := db.getSuggestedTags([]string{paperID})
+	return suggestions[paperID], err
+}
+
+// getSuggestedTags is GetSuggestedTags for a page of papers in one query,
+// keyed by paper ID
+func (db *DB) getSuggestedTags(paperIDs []string) (map[string][]string, error) {
+	suggestions := make(map[string][]string)
+	if len(paperIDs) == 0 {
+		return suggestions, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT k.paper_id, k.keyword FROM paper_keywords k
+		WHERE k.paper_id IN (?) AND NOT EXISTS (
+			SELECT 1 FROM paper_tags pt
+			JOIN tags t ON pt.tag_id = t.id
+			WHERE pt.paper_id = k.paper_id AND lower(t.name) = lower(k.keyword) AND `+visibleTag+`
+		)
+		ORDER BY k.paper_id, k.keyword
+	`, paperIDs, db.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var rows []struct {
+		PaperID string `db:"paper_id"`
+		Keyword string `db:"keyword"`
+	}
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to fetch suggested tags: %w", err)
+	}
+	for _, r := range rows {
+		suggestions[r.PaperID] = append(suggestions[r.PaperID], r.Keyword)
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// GetPapersForLinkCheck returns up to limit library papers whose links
+// were never checked or last checked before the given time, least
+// recently checked first
+func (db *DB) GetPapersForLinkCheck(before time.Time, limit int) ([]models.Paper, error) {
+	query := `
+		SELECT p.id, p.title, COALESCE(p.abstract, '') AS abstract, p.comment,
+			COALESCE(p.pdf_url, '') AS pdf_url, COALESCE(p.arxiv_url, '') AS arxiv_url, p.html_url
+		FROM papers p
+		JOIN library l ON l.paper_id = p.id
+		WHERE p.links_checked_at IS NULL OR p.links_checked_at < ?
+		ORDER BY p.links_checked_at IS NOT NULL, p.links_checked_at
+		LIMIT ?
+	`
+	var papers []models.Paper
+	if err := db.Select(&papers, query, before.UTC(), limit); err != nil {
+		return nil, fmt.Errorf("failed to fetch papers to check: %w", err)
+	}
+	return papers, nil
+}
+
+// SetLinkCheck records the outcome of checking a paper's links: broken
+// replaces the paper's broken links, keeping when each first failed, and
+// the paper is marked checked
+func (db *DB) SetLinkCheck(paperID string, broken []models.BrokenLink, checkedAt time.Time) error {
+	defer db.invalidate(paperID)
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		var previous []models.BrokenLink
+		if err := tx.Select(&previous, "SELECT url, first_failed_at FROM broken_links WHERE paper_id = ?", paperID); err != nil {
+			return fmt.Errorf("failed to fetch broken links: %w", err)
+		}
+		firstFailed := make(map[string]time.Time, len(previous))
+		for _, link := range previous {
+			firstFailed[link.URL] = link.FirstFailedAt
+		}
+
+		if _, err := tx.Exec("DELETE FROM broken_links WHERE paper_id = ?", paperID); err != nil {
+			return fmt.Errorf("failed to clear broken links: %w", err)
+		}
+		for _, link := range broken {
+			first, ok := firstFailed[link.URL]
+			if !ok {
+				first = checkedAt.UTC()
+			}
+			if _, err := tx.Exec(
+				"INSERT OR REPLACE INTO broken_links (paper_id, kind, url, status, first_failed_at, checked_at) VALUES (?, ?, ?, ?, ?, ?)",
+				paperID, link.Kind, link.URL, link.Status, first, checkedAt.UTC(),
+			); err != nil {
+				return fmt.Errorf("failed to record broken link: %w", err)
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE papers SET links_checked_at = ? WHERE id = ?", checkedAt.UTC(), paperID); err != nil {
+			return fmt.Errorf("failed to mark links checked: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetBrokenLinks returns every broken link with its paper's title, most
+// recently broken first
+func (db *DB) GetBrokenLinks() ([]models.BrokenLink, error) {
+	query := `
+		SELECT b.paper_id, p.title, b.kind, b.url, b.status, b.first_failed_at, b.checked_at
+		FROM broken_links b
+		JOIN papers p ON p.id = b.paper_id
+		ORDER BY b.first_failed_at DESC, b.paper_id
+	`
+	var links []models.BrokenLink
+	if err := db.Select(&links, query); err != nil {
+		return nil, fmt.Errorf("failed to fetch broken links: %w", err)
+	}
+	return links, nil
+}
+
+// RepairLink replaces a paper's PDF or abstract link. A broken HTML link
+// is cleared instead, so reader mode detects the rendering again.
+func (db *DB) RepairLink(paperID, kind, url string) error {
+	defer db.invalidate(paperID)
+	var err error
+	switch kind {
+	case models.LinkPDF:
+		_, err = db.Exec("UPDATE papers SET pdf_url = ? WHERE id = ?", url, paperID)
+	case models.LinkAbstract:
+		_, err = db.Exec("UPDATE papers SET arxiv_url = ? WHERE id = ?", url, paperID)
+	case models.LinkHTML:
+		_, err = db.Exec("UPDATE papers SET html_url = '', html_checked_at = NULL WHERE id = ?", paperID)
+	default:
+		return fmt.Errorf("cannot repair %s links", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to repair %s link: %w", kind, err)
+	}
+	return nil
+}
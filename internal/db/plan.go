@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrNotInLibrary is returned when planning a paper that is not saved
+var ErrNotInLibrary = errors.New("paper is not in the library")
+
+// PlanRead schedules a library paper to be read on a day, replacing any
+// day it was planned for before
+func (db *DB) PlanRead(paperID string, day time.Time) error {
+	result, err := db.Exec(`
+		INSERT INTO reading_plan (paper_id, planned_for)
+		SELECT paper_id, ? FROM library WHERE paper_id = ?
+		ON CONFLICT(paper_id) DO UPDATE SET planned_for = excluded.planned_for
+	`, day.Format("2006-01-02"), paperID)
+	if err != nil {
+		return fmt.Errorf("failed to plan paper: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotInLibrary
+	}
+	return nil
+}
+
+// UnplanRead removes a paper from the reading plan
+func (db *DB) UnplanRead(paperID string) error {
+	if _, err := db.Exec("DELETE FROM reading_plan WHERE paper_id = ?", paperID); err != nil {
+		return fmt.Errorf("failed to unplan paper: %w", err)
+	}
+	return nil
+}
+
+// GetPlannedFor returns the day a paper is planned for, or the zero time
+// if it is not planned
+func (db *DB) GetPlannedFor(paperID string) (time.Time, error) {
+	var plans []time.Time
+	if err := db.Select(&plans, "SELECT planned_for FROM reading_plan WHERE paper_id = ?", paperID); err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch reading plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return time.Time{}, nil
+	}
+	return plans[0], nil
+}
+
+// GetReadingPlan returns the papers planned between two days, inclusive,
+// by day and then title
+func (db *DB) GetReadingPlan(from, to time.Time) ([]models.PlannedRead, error) {
+	var plan []models.PlannedRead
+	err := db.Select(&plan, `
+		SELECT rp.paper_id, rp.planned_for, rp.created_at, p.title, COALESCE(p.arxiv_url, '') AS arxiv_url,
+			COALESCE(l.is_read, 0) AS is_read
+		FROM reading_plan rp
+		JOIN papers p ON p.id = rp.paper_id
+		LEFT JOIN library l ON l.paper_id = rp.paper_id
+		WHERE rp.planned_for BETWEEN ? AND ?
+		ORDER BY rp.planned_for, p.title
+	`, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reading plan: %w", err)
+	}
+	return plan, nil
+}
+
+// GetReadingQueue returns unread library papers, those on the reading plan
+// first by planned day, then the rest highest priority first and oldest
+// saved first, at most limit
+func (db *DB) GetReadingQueue(limit int) ([]models.Paper, error) {
+	var papers []models.Paper
+	err := db.Select(&papers, `
+		SELECT p.id, p.title, p.authors, COALESCE(l.priority, 0) AS priority
+		FROM library l
+		JOIN papers p ON p.id = l.paper_id
+		LEFT JOIN reading_plan rp ON rp.paper_id = l.paper_id
+		WHERE l.is_read = 0
+		ORDER BY rp.planned_for IS NULL, rp.planned_for, l.priority DESC, l.saved_at ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reading queue: %w", err)
+	}
+	return papers, nil
+}
+
+// GetUnplannedQueue returns unread library papers not yet on the reading
+// plan, highest priority first and then oldest saved, at most limit
+func (db *DB) GetUnplannedQueue(limit int) ([]models.Paper, error) {
+	var papers []models.Paper
+	err := db.Select(&papers, `
+		SELECT p.id, p.title, p.authors, COALESCE(l.priority, 0) AS priority
+		FROM library l
+		JOIN papers p ON p.id = l.paper_id
+		WHERE l.is_read = 0 AND NOT EXISTS (SELECT 1 FROM reading_plan rp WHERE rp.paper_id = l.paper_id)
+		ORDER BY l.priority DESC, l.saved_at ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reading queue: %w", err)
+	}
+	return papers, nil
+}
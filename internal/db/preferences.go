@@ -0,0 +1,34 @@
+package db
+
+// GetPreferences returns the UI preferences stored for a client (a browser,
+// identified by cookie), keyed by preference name
+func (db *DB) GetPreferences(clientID string) (map[string]string, error) {
+	var rows []struct {
+		Key   string `db:"key"`
+		Value string `db:"value"`
+	}
+	if err := db.Select(&rows, "SELECT key, value FROM preferences WHERE client_id = ?", clientID); err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[string]string, len(rows))
+	for _, row := range rows {
+		prefs[row.Key] = row.Value
+	}
+	return prefs, nil
+}
+
+// SetPreference stores a client's UI preference. An empty value removes it.
+func (db *DB) SetPreference(clientID, key, value string) error {
+	if value == "" {
+		_, err := db.Exec("DELETE FROM preferences WHERE client_id = ? AND key = ?", clientID, key)
+		return err
+	}
+
+	query := `
+		INSERT INTO preferences (client_id, key, value, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(client_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`
+	_, err := db.Exec(query, clientID, key, value)
+	return err
+}
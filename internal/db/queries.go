@@ -2,188 +2,639 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
+	"sort"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
 )
 
 // UpsertPaper inserts or updates a paper in the database
 func (db *DB) UpsertPaper(paper *models.Paper) error {
-	query := `
-		INSERT INTO papers (id, title, abstract, authors, categories, published_at, updated_at, pdf_url, arxiv_url)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			title = excluded.title,
-			abstract = excluded.abstract,
-			authors = excluded.authors,
-			categories = excluded.categories,
-			published_at = excluded.published_at,
-			updated_at = excluded.updated_at,
-			pdf_url = excluded.pdf_url,
-			arxiv_url = excluded.arxiv_url
-	`
-	_, err := db.Exec(query,
-		paper.ID, paper.Title, paper.Abstract, paper.Authors,
-		paper.Categories, paper.PublishedAt, paper.UpdatedAt,
-		paper.PDFUrl, paper.ArxivUrl,
-	)
+	_, err := db.UpsertPaperStatus(paper)
 	return err
 }
 
-// GetPapers retrieves papers with optional filtering, searching, and pagination
-func (db *DB) GetPapers(params models.SearchParams) ([]models.Paper, int, error) {
-	// Build WHERE clause
-	var conditions []string
-	var args []interface{}
+// UpsertPaperStatus inserts or updates a paper and reports whether it was
+// written. A paper whose fetched content hashes the same as last time only
+// has its last_seen_at updated, so repeat fetches barely touch the database.
+func (db *DB) UpsertPaperStatus(paper *models.Paper) (bool, error) {
+	paper.AbstractWords = models.WordCount(paper.Abstract)
+	paper.ContentHash = models.ContentHash(paper)
+	now := time.Now().UTC()
+
+	// The paper and the data derived from it are written together, so a
+	// failed derived write doesn't leave a hash that skips the paper from
+	// then on
+	var written bool
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		written = false
+		result, err := tx.Exec(
+			"UPDATE papers SET last_seen_at = ? WHERE id = ? AND content_hash = ?",
+			now, paper.ID, paper.ContentHash,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			return nil
+		}
+
+		query := `
+			INSERT INTO papers (id, title, abstract, authors, categories, published_at, updated_at, pdf_url, arxiv_url,
+				abstract_words, license, content_hash, last_seen_at, comment, doi, journal_ref)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				title = excluded.title,
+				abstract = excluded.abstract,
+				abstract_words = excluded.abstract_words,
+				authors = excluded.authors,
+				categories = excluded.categories,
+				published_at = excluded.published_at,
+				updated_at = excluded.updated_at,
+				pdf_url = excluded.pdf_url,
+				arxiv_url = excluded.arxiv_url,
+				license = COALESCE(NULLIF(excluded.license, ''), papers.license),
+				content_hash = excluded.content_hash,
+				last_seen_at = excluded.last_seen_at,
+				comment = excluded.comment,
+				doi = excluded.doi,
+				journal_ref = excluded.journal_ref
+		`
+		_, err = tx.Exec(query,
+			paper.ID, paper.Title, paper.Abstract, paper.Authors,
+			paper.Categories, paper.PublishedAt, paper.UpdatedAt,
+			paper.PDFUrl, paper.ArxivUrl, paper.AbstractWords, paper.License,
+			paper.ContentHash, now, paper.Comment, paper.DOI, paper.JournalRef,
+		)
+		if err != nil {
+			return err
+		}
+		if err := setPaperEntities(tx, paper.ID, paper.Title, paper.Abstract); err != nil {
+			return err
+		}
+		if err := setPaperMinhash(tx, paper.ID, paper.Abstract); err != nil {
+			return err
+		}
+		if err := suggestDuplicates(tx, paper.ID, paper.Abstract, now); err != nil {
+			return err
+		}
+		written = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	// Only new or changed papers are written, so only they are stale
+	if written {
+		db.invalidate(paper.ID)
+	}
+	return written, nil
+}
+
+// paperQuery starts a query for papers matching a search, aliasing papers
+// as p and the LEFT JOINed library as l. The tag filter only sees the
+// shared tags and the personal tags of client.
+func paperQuery(params models.SearchParams, client string, columns ...string) *selectQuery {
+	q := newSelect(columns...).
+		From("papers p").
+		Join("LEFT JOIN library l ON p.id = l.paper_id")
 
 	if params.Query != "" {
-		conditions = append(conditions, "(p.title LIKE ? OR p.abstract LIKE ? OR p.authors LIKE ?)")
-		searchTerm := "%" + params.Query + "%"
-		args = append(args, searchTerm, searchTerm, searchTerm)
+		searchTerm := search.Contains(params.Query)
+		q.Where(`(fold(p.title) LIKE ? ESCAPE '\' OR fold(p.abstract) LIKE ? ESCAPE '\' OR fold(p.authors) LIKE ? ESCAPE '\')`,
+			searchTerm, searchTerm, searchTerm)
 	}
 
 	if params.Category != "" {
-		conditions = append(conditions, "p.categories LIKE ?")
-		args = append(args, "%"+params.Category+"%")
+		q.Where(`p.categories LIKE ? ESCAPE '\'`, search.Contains(params.Category))
+	}
+
+	switch params.Length {
+	case "short":
+		q.Where("p.abstract_words < ?", models.ShortAbstractWords)
+	case "medium":
+		q.Where("p.abstract_words BETWEEN ? AND ?", models.ShortAbstractWords, models.LongAbstractWords)
+	case "long":
+		q.Where("p.abstract_words > ?", models.LongAbstractWords)
+	}
+
+	if filter, ok := models.FindLicenseFilter(params.License); ok {
+		if filter.Pattern == "" {
+			q.Where("COALESCE(p.license, '') = ''")
+		} else {
+			q.Where("p.license LIKE ?", filter.Pattern)
+		}
+	}
+
+	if params.Entity != "" {
+		q.Where(`EXISTS (
+			SELECT 1 FROM paper_entities e
+			WHERE e.paper_id = p.id AND e.name = ? COLLATE NOCASE
+		)`, params.Entity)
 	}
 
 	if params.InLibrary {
-		conditions = append(conditions, "l.paper_id IS NOT NULL")
+		q.Where("l.paper_id IS NOT NULL")
 	}
 
-	if params.Tag != "" {
-		conditions = append(conditions, `EXISTS (
-			SELECT 1 FROM paper_tags pt
-			JOIN tags t ON pt.tag_id = t.id
-			WHERE pt.paper_id = p.id AND t.name = ?
-		)`)
-		args = append(args, params.Tag)
+	switch params.Read {
+	case "read":
+		q.Where("l.is_read = 1")
+	case "unread":
+		q.Where("l.is_read = 0")
 	}
 
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	switch params.Note {
+	case "with":
+		q.Where("COALESCE(l.note, '') != ''")
+	case "without":
+		q.Where("l.paper_id IS NOT NULL AND COALESCE(l.note, '') = ''")
 	}
 
-	// Count total results
-	countQuery := fmt.Sprintf(`
-		SELECT COUNT(DISTINCT p.id)
-		FROM papers p
-		LEFT JOIN library l ON p.id = l.paper_id
-		%s
-	`, whereClause)
+	if params.MinPriority > 0 {
+		q.Where("l.priority >= ?", params.MinPriority)
+	}
 
-	var total int
-	if err := db.Get(&total, countQuery, args...); err != nil {
-		return nil, 0, fmt.Errorf("failed to count papers: %w", err)
+	// saved_at is stored as "YYYY-MM-DD HH:MM:SS", so days compare as text
+	if !params.SavedFrom.IsZero() {
+		q.Where("l.saved_at >= ?", params.SavedFrom.Format("2006-01-02"))
+	}
+	if !params.SavedTo.IsZero() {
+		q.Where("l.saved_at < ?", params.SavedTo.AddDate(0, 0, 1).Format("2006-01-02"))
 	}
 
-	// Build ORDER BY clause
-	sortBy := "p.published_at"
-	if params.SortBy == "title" {
-		sortBy = "p.title"
+	if params.Tag != "" {
+		q.Where(`EXISTS (
+			SELECT 1 FROM paper_tags pt
+			JOIN tags t ON pt.tag_id = t.id
+			WHERE pt.paper_id = p.id AND t.name = ? AND `+visibleTag+`
+		)`, params.Tag, client)
 	}
+
+	return q
+}
+
+// paperOrder returns the sort terms for a search. The paper ID always comes
+// last: papers announced together share a publication time to the second,
+// and without a unique key SQLite may order them differently on each query,
+// repeating some papers and skipping others across pages.
+func paperOrder(params models.SearchParams) []string {
 	sortOrder := "DESC"
 	if params.SortOrder == "asc" {
 		sortOrder = "ASC"
 	}
+	switch params.SortBy {
+	case "title":
+		return []string{"p.title " + sortOrder, "p.id " + sortOrder}
+	case "priority":
+		// Highest priority first, newest first within a priority
+		return []string{"COALESCE(l.priority, 0) " + sortOrder, "p.published_at DESC", "p.id DESC"}
+	case "length":
+		return []string{"p.abstract_words " + sortOrder, "p.published_at DESC", "p.id DESC"}
+	}
+	return []string{"p.published_at " + sortOrder, "p.id " + sortOrder}
+}
+
+// paperListColumns are the columns of papers in lists
+var paperListColumns = []string{
+	"p.id", "p.title", "p.abstract", "p.authors", "p.categories",
+	"p.published_at", "p.updated_at", "p.pdf_url", "p.arxiv_url", "p.html_url", "p.abstract_words", "p.license",
+	"l.paper_id IS NOT NULL AS in_library",
+	"COALESCE(l.is_read, 0) AS is_read",
+	"COALESCE(l.priority, 0) AS priority",
+	"COALESCE(l.note, '') AS note",
+}
 
-	// Calculate offset
-	offset := (params.Page - 1) * params.PageSize
-	if offset < 0 {
-		offset = 0
+// GetPapers retrieves papers with optional filtering, searching, and pagination
+func (db *DB) GetPapers(params models.SearchParams) ([]models.Paper, int, error) {
+	return db.listPapers(paperQuery(params, db.client, paperListColumns...).Distinct(), params)
+}
+
+// SearchPapers retrieves the papers matching a structured filter as well as
+// params, which also sets the sort order and page. A filter that can't be
+// compiled gives an error wrapping ErrInvalidFilter.
+func (db *DB) SearchPapers(filter search.Filter, params models.SearchParams) ([]models.Paper, int, error) {
+	condition, args, err := compileFilter(filter, db.client)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// Fetch papers
-	query := fmt.Sprintf(`
-		SELECT DISTINCT
-			p.id, p.title, p.abstract, p.authors, p.categories, 
-			p.published_at, p.updated_at, p.pdf_url, p.arxiv_url,
-			l.paper_id IS NOT NULL AS in_library,
-			COALESCE(l.is_read, 0) AS is_read
-		FROM papers p
-		LEFT JOIN library l ON p.id = l.paper_id
-		%s
-		ORDER BY %s %s
-		LIMIT ? OFFSET ?
-	`, whereClause, sortBy, sortOrder)
+	q := paperQuery(params, db.client, paperListColumns...).Distinct()
+	if condition != "" {
+		q.Where(condition, args...)
+	}
+	return db.listPapers(q, params)
+}
+
+// listPapers runs a query for papers, counting every match and returning
+// the page params asks for with their tags
+func (db *DB) listPapers(q *selectQuery, params models.SearchParams) ([]models.Paper, int, error) {
+	// The matching papers come from the replica; their tags, which change
+	// as the user works through the list, from the primary
+	r := db.fromReplica()
+
+	// Count total results
+	countQuery, countArgs := q.Count("DISTINCT p.id").Build()
+	var total int
+	if err := r.Get(&total, countQuery, countArgs...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count papers: %w", err)
+	}
 
-	args = append(args, params.PageSize, offset)
+	// Fetch papers
+	query, args := q.OrderBy(paperOrder(params)...).
+		Page(params.PageSize, (params.Page-1)*params.PageSize).
+		Build()
 
 	var papers []models.Paper
-	if err := db.Select(&papers, query, args...); err != nil {
+	if err := r.Select(&papers, query, args...); err != nil {
 		return nil, 0, fmt.Errorf("failed to fetch papers: %w", err)
 	}
 
-	// Fetch tags for each paper
+	// Fetch tags for each paper, and the tag suggestions of the whole page
+	ids := make([]string, len(papers))
+	for i := range papers {
+		ids[i] = papers[i].ID
+	}
+	suggestions, err := db.getSuggestedTags(ids)
+	if err != nil {
+		return nil, 0, err
+	}
 	for i := range papers {
 		tags, err := db.GetPaperTags(papers[i].ID)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to fetch tags for paper %s: %w", papers[i].ID, err)
 		}
 		papers[i].Tags = tags
+		papers[i].Suggestions = suggestions[papers[i].ID]
 	}
 
 	return papers, total, nil
 }
 
-// GetPaperByID retrieves a single paper by ID
+// GetFirstPaperIDs returns the IDs of the first limit papers matching the
+// search, in the order GetPapers lists them
+func (db *DB) GetFirstPaperIDs(params models.SearchParams, limit int) ([]string, error) {
+	query, args := paperQuery(params, db.client, "p.id").Distinct().
+		OrderBy(paperOrder(params)...).
+		Page(limit, 0).
+		Build()
+
+	var ids []string
+	if err := db.Select(&ids, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to fetch paper IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// PaperExists reports whether a paper is already stored
+func (db *DB) PaperExists(id string) (bool, error) {
+	var exists bool
+	err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM papers WHERE id = ?)", id)
+	return exists, err
+}
+
+// GetPaperByID retrieves a single paper by ID. The paper row and its tags
+// come from the cache when it was looked up recently.
 func (db *DB) GetPaperByID(id string) (*models.Paper, error) {
+	paper, generation, ok := db.cache.papers.get(id)
+	if !ok {
+		if err := db.getPaper(&paper, id); err != nil {
+			return nil, err
+		}
+		db.cache.papers.add(id, paper, generation)
+	}
+
+	// Fetch tags
+	tags, err := db.GetPaperTags(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	paper.Tags = tags
+
+	paper.Suggestions, err = db.GetSuggestedTags(id)
+	if err != nil {
+		return nil, err
+	}
+
+	paper.Entities, err = db.GetPaperEntities(id)
+	if err != nil {
+		return nil, err
+	}
+
+	paper.Venues, err = db.GetPaperVenues(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &paper, nil
+}
+
+// getPaper reads a paper with its library state
+func (db *DB) getPaper(paper *models.Paper, id string) error {
 	query := `
 		SELECT
 			p.*,
 			CASE WHEN l.paper_id IS NOT NULL THEN 1 ELSE 0 END as in_library,
-			COALESCE(l.is_read, 0) as is_read
+			COALESCE(l.is_read, 0) as is_read,
+			COALESCE(l.priority, 0) as priority,
+			COALESCE(l.note, '') as note
 		FROM papers p
 		LEFT JOIN library l ON p.id = l.paper_id
 		WHERE p.id = ?
 	`
 
-	var paper models.Paper
-	if err := db.Get(&paper, query, id); err != nil {
+	if err := db.Get(paper, query, id); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("paper not found: %s", id)
+			return fmt.Errorf("paper not found: %s", id)
 		}
-		return nil, fmt.Errorf("failed to fetch paper: %w", err)
+		return fmt.Errorf("failed to fetch paper: %w", err)
 	}
+	return nil
+}
 
-	// Fetch tags
-	tags, err := db.GetPaperTags(id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tags: %w", err)
-	}
-	paper.Tags = tags
-
-	return &paper, nil
+// SetHTMLURL records the result of an HTML availability check.
+// An empty url means the paper has no HTML rendering.
+func (db *DB) SetHTMLURL(paperID, url string) error {
+	defer db.invalidate(paperID)
+	query := `UPDATE papers SET html_url = ?, html_checked_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := db.Exec(query, url, paperID)
+	return err
 }
 
 // SaveToLibrary adds a paper to the user's library
 func (db *DB) SaveToLibrary(paperID string) error {
+	defer db.invalidate(paperID)
 	query := `INSERT INTO library (paper_id) VALUES (?) ON CONFLICT(paper_id) DO NOTHING`
 	_, err := db.Exec(query, paperID)
 	return err
 }
 
+// SaveWithTags saves a paper to the library with a priority and "why
+// saved" note and tags it, all or nothing. Tags that don't exist yet are
+// created shared, or personal to the handle's client with personal. A
+// paper already in the library keeps its note and priority unless new
+// ones are given. It returns sql.ErrNoRows if there is no such paper.
+func (db *DB) SaveWithTags(paperID string, priority int, note string, tags []string, personal bool) error {
+	var tagged []Collected
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		tagged = nil
+		var exists int
+		if err := tx.Get(&exists, "SELECT COUNT(*) FROM papers WHERE id = ?", paperID); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO library (paper_id, priority, note) VALUES (?, ?, ?)
+			ON CONFLICT(paper_id) DO UPDATE SET
+				priority = CASE WHEN excluded.priority != 0 THEN excluded.priority ELSE priority END,
+				note = CASE WHEN excluded.note != '' THEN excluded.note ELSE note END
+		`, paperID, priority, note); err != nil {
+			return fmt.Errorf("failed to save to library: %w", err)
+		}
+
+		for _, name := range tags {
+			tagID, err := db.ensureTag(tx, name, personal)
+			if err != nil {
+				return err
+			}
+			result, err := tx.Exec("INSERT INTO paper_tags (paper_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING", paperID, tagID)
+			if err != nil {
+				return fmt.Errorf("failed to tag paper: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				tagged = append(tagged, Collected{Kind: models.CollectionTag, ID: tagID, Papers: []string{paperID}})
+			}
+		}
+		return nil
+	})
+	// Papers are only announced as added to tags once the save commits
+	if err != nil {
+		tagged = nil
+	}
+	db.changed(Change{Papers: []string{paperID}, Collected: tagged})
+	return err
+}
+
 // RemoveFromLibrary removes a paper from the user's library
 func (db *DB) RemoveFromLibrary(paperID string) error {
-	query := `DELETE FROM library WHERE paper_id = ?`
-	_, err := db.Exec(query, paperID)
-	return err
+	defer db.invalidate(paperID)
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM library WHERE paper_id = ?", paperID); err != nil {
+			return err
+		}
+		// Only library papers can be planned
+		_, err := tx.Exec("DELETE FROM reading_plan WHERE paper_id = ?", paperID)
+		return err
+	})
+}
+
+// UpdateLibraryEntry sets the priority and "why saved" note of a library paper
+func (db *DB) UpdateLibraryEntry(paperID string, priority int, note string) error {
+	defer db.invalidate(paperID)
+	query := `UPDATE library SET priority = ?, note = ? WHERE paper_id = ?`
+	result, err := db.Exec(query, priority, note, paperID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("paper not in library: %s", paperID)
+	}
+	return nil
+}
+
+// SetLibraryNote sets the "why saved" note of a library paper, keeping its
+// priority
+func (db *DB) SetLibraryNote(paperID string, note string) error {
+	defer db.invalidate(paperID)
+	result, err := db.Exec(`UPDATE library SET note = ? WHERE paper_id = ?`, note, paperID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("paper not in library: %s", paperID)
+	}
+	return nil
 }
 
 // ToggleRead toggles the read status of a paper in the library
 func (db *DB) ToggleRead(paperID string) error {
-	query := `UPDATE library SET is_read = NOT is_read WHERE paper_id = ?`
-	_, err := db.Exec(query, paperID)
-	return err
+	defer db.invalidate(paperID)
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`UPDATE library SET is_read = NOT is_read WHERE paper_id = ?`, paperID); err != nil {
+			return err
+		}
+
+		var read int64
+		if err := tx.Get(&read, "SELECT COUNT(*) FROM library WHERE paper_id = ? AND is_read", paperID); err != nil {
+			return err
+		}
+		if read == 0 {
+			return nil
+		}
+		if err := recordReadEvents(tx, []string{paperID}, time.Now()); err != nil {
+			return err
+		}
+		return recordReads(tx, read)
+	})
+}
+
+// SetReadStatus marks the given library papers as read or unread and
+// returns the IDs of those it changed. Papers that are not in the library,
+// or already have that status, are ignored.
+func (db *DB) SetReadStatus(paperIDs []string, read bool) ([]string, error) {
+	defer db.invalidate(paperIDs...)
+	if len(paperIDs) == 0 {
+		return nil, nil
+	}
+
+	changingQuery, changingArgs, err := sqlx.In(`SELECT paper_id FROM library WHERE is_read != ? AND paper_id IN (?) ORDER BY paper_id`, read, paperIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var changed []string
+	err = db.Transaction(func(tx *sqlx.Tx) error {
+		changed = nil
+		if err := tx.Select(&changed, changingQuery, changingArgs...); err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+
+		query, args, err := sqlx.In(`UPDATE library SET is_read = ? WHERE paper_id IN (?)`, read, changed)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+
+		// Only papers going from unread to read count towards the reads
+		// rollup and the reading log
+		if !read {
+			return nil
+		}
+		if err := recordReadEvents(tx, changed, time.Now()); err != nil {
+			return err
+		}
+		return recordReads(tx, int64(len(changed)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// GetPaperIDs returns the IDs of all papers matching the search, ignoring pagination
+func (db *DB) GetPaperIDs(params models.SearchParams) ([]string, error) {
+	query, args := paperQuery(params, db.client, "p.id").Build()
+
+	var ids []string
+	if err := db.Select(&ids, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to fetch paper IDs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// maxInIDs is how many IDs a query lists at most, well below the number of
+// variables older SQLite builds allow in a statement
+const maxInIDs = 500
+
+// GetPapersByIDs returns the given papers, newest first. Unknown IDs are skipped.
+func (db *DB) GetPapersByIDs(ids []string) ([]models.Paper, error) {
+	var papers []models.Paper
+	for start := 0; start < len(ids); start += maxInIDs {
+		chunk, err := db.getPapersByIDs(ids[start:min(start+maxInIDs, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+		papers = append(papers, chunk...)
+	}
+	if len(ids) > maxInIDs {
+		sort.SliceStable(papers, func(i, j int) bool {
+			if !papers[i].PublishedAt.Equal(papers[j].PublishedAt) {
+				return papers[i].PublishedAt.After(papers[j].PublishedAt)
+			}
+			return papers[i].ID > papers[j].ID
+		})
+	}
+	return papers, nil
+}
+
+// getPapersByIDs returns up to maxInIDs of the given papers, newest first
+func (db *DB) getPapersByIDs(ids []string) ([]models.Paper, error) {
+	query, args, err := sqlx.In(`
+		SELECT p.id, p.title, p.abstract, p.authors, p.categories,
+			p.published_at, p.updated_at, p.pdf_url, p.arxiv_url, p.html_url, p.abstract_words, p.license,
+			l.paper_id IS NOT NULL AS in_library,
+			COALESCE(l.is_read, 0) AS is_read,
+			COALESCE(l.priority, 0) AS priority,
+			COALESCE(l.note, '') AS note
+		FROM papers p
+		LEFT JOIN library l ON p.id = l.paper_id
+		WHERE p.id IN (?)
+		ORDER BY p.published_at DESC, p.id DESC
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var papers []models.Paper
+	if err := db.Select(&papers, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to fetch papers: %w", err)
+	}
+
+	return papers, nil
 }
 
-// CreateTag creates a new tag or returns existing tag ID
+// CreateTag creates a new shared tag or returns existing tag ID. The
+// handle's own personal tag of that name comes first (see ensureTag).
 func (db *DB) CreateTag(name string) (int, error) {
+	return db.createTag(name, false)
+}
+
+// CreatePersonalTag is CreateTag for a tag only visible to the handle's
+// client (see ForClient); an existing tag keeps its visibility
+func (db *DB) CreatePersonalTag(name string) (int, error) {
+	return db.createTag(name, true)
+}
+
+// createTag returns the ID of the tag with the given name, creating it if
+// there is none
+func (db *DB) createTag(name string, personal bool) (id int, err error) {
+	err = db.Transaction(func(tx *sqlx.Tx) error {
+		id, err = db.ensureTag(tx, name, personal)
+		return err
+	})
+	return id, err
+}
+
+// ensureTag is createTag within a transaction. Only the tags visible to the
+// handle are looked at, its own personal tag before the shared one, so
+// another browser's personal tag neither blocks nor gives away the name.
+func (db *DB) ensureTag(tx *sqlx.Tx, name string, personal bool) (int, error) {
 	// Try to get existing tag
 	var tag models.Tag
-	err := db.Get(&tag, "SELECT * FROM tags WHERE name = ?", name)
+	err := tx.Get(&tag, "SELECT * FROM tags t WHERE t.name = ? AND "+visibleTag+" ORDER BY t.owner = '' LIMIT 1", name, db.client)
 	if err == nil {
 		return tag.ID, nil
 	}
@@ -191,8 +642,16 @@ func (db *DB) CreateTag(name string) (int, error) {
 		return 0, fmt.Errorf("failed to check for existing tag: %w", err)
 	}
 
+	owner := ""
+	if personal {
+		if db.client == "" {
+			return 0, errors.New("personal tags need a client")
+		}
+		owner = db.client
+	}
+
 	// Create new tag
-	result, err := db.Exec("INSERT INTO tags (name) VALUES (?)", name)
+	result, err := tx.Exec("INSERT INTO tags (name, owner) VALUES (?, ?)", name, owner)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create tag: %w", err)
 	}
@@ -207,45 +666,59 @@ func (db *DB) CreateTag(name string) (int, error) {
 
 // TagPaper associates a tag with a paper
 func (db *DB) TagPaper(paperID string, tagID int) error {
+	c := Change{Papers: []string{paperID}}
+	defer func() { db.changed(c) }()
 	query := `INSERT INTO paper_tags (paper_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
-	_, err := db.Exec(query, paperID, tagID)
-	return err
+	result, err := db.Exec(query, paperID, tagID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		c.Collected = []Collected{{Kind: models.CollectionTag, ID: tagID, Papers: []string{paperID}}}
+	}
+	return nil
 }
 
 // UntagPaper removes a tag from a paper
 func (db *DB) UntagPaper(paperID string, tagID int) error {
-	query := `DELETE FROM paper_tags WHERE paper_id = ? AND tag_id = ?`
-	_, err := db.Exec(query, paperID, tagID)
+	defer db.invalidate(paperID)
+	query := `DELETE FROM paper_tags WHERE paper_id = ? AND tag_id IN (SELECT t.id FROM tags t WHERE t.id = ? AND ` + visibleTag + `)`
+	_, err := db.Exec(query, paperID, tagID, db.client)
 	return err
 }
 
-// GetPaperTags retrieves all tags for a paper
+// GetPaperTags retrieves the tags of a paper visible to the handle's client.
+// The paper's tags are cached for every client, and filtered here.
 func (db *DB) GetPaperTags(paperID string) ([]models.Tag, error) {
-	query := `
-		SELECT t.* FROM tags t
-		JOIN paper_tags pt ON t.id = pt.tag_id
-		WHERE pt.paper_id = ?
-		ORDER BY t.name
-	`
-
-	var tags []models.Tag
-	if err := db.Select(&tags, query, paperID); err != nil {
-		return nil, err
+	all, generation, ok := db.cache.tags.get(paperID)
+	if !ok {
+		query := `
+			SELECT t.* FROM tags t
+			JOIN paper_tags pt ON t.id = pt.tag_id
+			WHERE pt.paper_id = ?
+			ORDER BY t.name
+		`
+		if err := db.Select(&all, query, paperID); err != nil {
+			return nil, err
+		}
+		db.cache.tags.add(paperID, all, generation)
 	}
 
-	if tags == nil {
-		tags = []models.Tag{}
+	tags := []models.Tag{}
+	for _, t := range all {
+		if t.Owner == "" || t.Owner == db.client {
+			tags = append(tags, t)
+		}
 	}
-
 	return tags, nil
 }
 
-// GetAllTags retrieves all tags with paper counts
+// GetAllTags retrieves the tags visible to the handle's client
 func (db *DB) GetAllTags() ([]models.Tag, error) {
-	query := `SELECT * FROM tags ORDER BY name`
+	query := `SELECT * FROM tags t WHERE ` + visibleTag + ` ORDER BY name`
 
 	var tags []models.Tag
-	if err := db.Select(&tags, query); err != nil {
+	if err := db.Select(&tags, query, db.client); err != nil {
 		return nil, err
 	}
 
@@ -258,14 +731,23 @@ func (db *DB) GetAllTags() ([]models.Tag, error) {
 
 // GetPaperCount returns the total number of papers
 func (db *DB) GetPaperCount() (int, error) {
-	var count int
-	err := db.Get(&count, "SELECT COUNT(*) FROM papers")
-	return count, err
+	return db.counts.get(paperCountKey, func() (count int, err error) {
+		err = db.Get(&count, "SELECT COUNT(*) FROM papers")
+		return count, err
+	})
 }
 
 // GetLibraryCount returns the number of papers in the library
 func (db *DB) GetLibraryCount() (int, error) {
+	return db.counts.get(libraryCountKey, func() (count int, err error) {
+		err = db.Get(&count, "SELECT COUNT(*) FROM library")
+		return count, err
+	})
+}
+
+// GetReadCount returns the number of library papers marked as read
+func (db *DB) GetReadCount() (int, error) {
 	var count int
-	err := db.Get(&count, "SELECT COUNT(*) FROM library")
+	err := db.Get(&count, "SELECT COUNT(*) FROM library WHERE is_read")
 	return count, err
 }
@@ -1,7 +1,12 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -363,4 +368,1088 @@ func TestGetLibraryCount(t *testing.T) {
 	if count != 2 {
 		t.Errorf("Expected 2 papers in library, got %d", count)
 	}
+
+	db.ToggleRead("1")
+	if read, err := db.GetReadCount(); err != nil || read != 1 {
+		t.Errorf("Expected 1 read paper, got %d, %v", read, err)
+	}
+}
+
+func TestSearchMatchesWildcardsLiterally(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, paper := range []*models.Paper{
+		{ID: "1", Title: "Pruning 90% of weights", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "2", Title: "Pruning 90 layers", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "3", Title: "snake_case identifiers", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "4", Title: "ÉCOLE benchmarks", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+	} {
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("Failed to insert paper: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"90%", 1},
+		{"%", 1},
+		{"e_c", 1},
+		{"_", 1},
+		{"pruning 90", 2},
+		{"école", 1}, // case-folded beyond ASCII, as search.Normalize leaves queries
+	}
+
+	for _, tt := range tests {
+		_, total, err := db.GetPapers(models.SearchParams{Query: tt.query, Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("GetPapers(%q) failed: %v", tt.query, err)
+		}
+		if total != tt.want {
+			t.Errorf("GetPapers(%q) matched %d papers, want %d", tt.query, total, tt.want)
+		}
+	}
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	db := setupTestDB(t)
+
+	paper := &models.Paper{ID: "2401.00001", Title: "Trash Me", Authors: "A", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	db.SaveToLibrary(paper.ID)
+	db.UpdateLibraryEntry(paper.ID, models.PriorityHigh, "for the survey")
+	tagID, _ := db.CreateTag("survey")
+	db.TagPaper(paper.ID, tagID)
+	db.CreateAssignment(paper.ID, "Ana", time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC))
+
+	count, err := db.TrashPapers([]string{paper.ID, "missing"})
+	if err != nil {
+		t.Fatalf("TrashPapers failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 paper trashed, got %d", count)
+	}
+
+	if exists, _ := db.PaperExists(paper.ID); exists {
+		t.Error("Expected paper to be deleted")
+	}
+	if n, _ := db.GetLibraryCount(); n != 0 {
+		t.Errorf("Expected empty library, got %d", n)
+	}
+	if trashed, _ := db.IsTrashed(paper.ID); !trashed {
+		t.Error("Expected paper to be in the trash")
+	}
+
+	trash, err := db.GetTrash()
+	if err != nil {
+		t.Fatalf("GetTrash failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Title != "Trash Me" {
+		t.Fatalf("Unexpected trash contents: %+v", trash)
+	}
+
+	if err := db.RestorePaper(paper.ID); err != nil {
+		t.Fatalf("RestorePaper failed: %v", err)
+	}
+
+	restored, err := db.GetPaperByID(paper.ID)
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if !restored.InLibrary || restored.Priority != models.PriorityHigh || restored.Note != "for the survey" {
+		t.Errorf("Expected library entry to be restored, got %+v", restored)
+	}
+	if len(restored.Tags) != 1 || restored.Tags[0].Name != "survey" {
+		t.Errorf("Expected tag to be restored, got %+v", restored.Tags)
+	}
+	if assignments, _ := db.GetPaperAssignments(paper.ID); len(assignments) != 1 || assignments[0].Assignee != "Ana" {
+		t.Errorf("Expected assignment to be restored, got %+v", assignments)
+	}
+	if trashed, _ := db.IsTrashed(paper.ID); trashed {
+		t.Error("Expected paper to leave the trash")
+	}
+
+	// Purge only removes papers deleted before the cutoff
+	db.TrashPapers([]string{paper.ID})
+	if purged, _ := db.PurgeTrash(time.Now().Add(-time.Hour)); purged != 0 {
+		t.Errorf("Expected nothing purged, got %d", purged)
+	}
+	if purged, _ := db.PurgeTrash(time.Now()); purged != 1 {
+		t.Errorf("Expected 1 paper purged, got %d", purged)
+	}
+	if err := db.RestorePaper(paper.ID); err == nil {
+		t.Error("Expected restoring a purged paper to fail")
+	}
+}
+
+func TestRestorePersonalTags(t *testing.T) {
+	db := setupTestDB(t)
+	alice, bob := db.ForClient("alice"), db.ForClient("bob")
+
+	for _, id := range []string{"2401.00001", "2401.00002"} {
+		paper := &models.Paper{ID: id, Title: "Tagged " + id, Authors: "A", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+	mine, _ := alice.CreatePersonalTag("mine")
+	alice.TagPaper("2401.00001", mine)
+	taken, _ := alice.CreatePersonalTag("taken")
+	alice.TagPaper("2401.00002", taken)
+	db.TrashPapers([]string{"2401.00001", "2401.00002"})
+
+	// Both tags go away; bob uses one of the names for himself
+	if _, err := db.Exec("DELETE FROM tags"); err != nil {
+		t.Fatalf("Failed to delete tags: %v", err)
+	}
+	bobs, _ := bob.CreatePersonalTag("taken")
+
+	for _, id := range []string{"2401.00001", "2401.00002"} {
+		if err := db.RestorePaper(id); err != nil {
+			t.Fatalf("RestorePaper failed: %v", err)
+		}
+	}
+
+	if tags, _ := alice.GetPaperTags("2401.00001"); len(tags) != 1 || tags[0].Name != "mine" || tags[0].Owner != "alice" {
+		t.Errorf("Expected alice's tag to be recreated for her, got %+v", tags)
+	}
+	if tags, _ := bob.GetPaperTags("2401.00001"); len(tags) != 0 {
+		t.Errorf("Expected alice's restored tag to stay hidden from bob, got %+v", tags)
+	}
+	if tags, _ := bob.GetPaperTags("2401.00002"); len(tags) != 0 {
+		t.Errorf("Expected the paper to stay out of bob's tag %d, got %+v", bobs, tags)
+	}
+	if tags, _ := alice.GetPaperTags("2401.00002"); len(tags) != 1 || tags[0].ID == bobs || tags[0].Owner != "alice" {
+		t.Errorf("Expected alice's tag to be recreated next to bob's, got %+v", tags)
+	}
+
+	// Papers trashed before owners were kept list plain tag names
+	var s trashSnapshot
+	if err := json.Unmarshal([]byte(`{"Tags": ["survey"]}`), &s); err != nil {
+		t.Fatalf("Failed to read an old snapshot: %v", err)
+	}
+	if len(s.Tags) != 1 || s.Tags[0] != (trashedTag{Name: "survey"}) {
+		t.Errorf("Expected a shared tag, got %+v", s.Tags)
+	}
+}
+
+func TestAbstractLength(t *testing.T) {
+	db := setupTestDB(t)
+
+	for i, words := range []int{40, 180, 400} {
+		paper := &models.Paper{
+			ID:          fmt.Sprintf("2401.0000%d", i),
+			Title:       fmt.Sprintf("Paper %d", i),
+			Abstract:    strings.TrimSpace(strings.Repeat("word ", words)),
+			PublishedAt: time.Now().Add(time.Duration(i) * time.Hour),
+			UpdatedAt:   time.Now(),
+		}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	paper, err := db.GetPaperByID("2401.00002")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if paper.AbstractWords != 400 || paper.ReadingMinutes() != 2 {
+		t.Errorf("Expected 400 words and 2 minutes, got %d and %d", paper.AbstractWords, paper.ReadingMinutes())
+	}
+
+	for length, want := range map[string]string{"short": "Paper 0", "medium": "Paper 1", "long": "Paper 2"} {
+		papers, total, err := db.GetPapers(models.SearchParams{Length: length, Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("GetPapers failed: %v", err)
+		}
+		if total != 1 || papers[0].Title != want {
+			t.Errorf("Length %s: expected %s, got %d papers", length, want, total)
+		}
+	}
+
+	papers, _, err := db.GetPapers(models.SearchParams{SortBy: "length", SortOrder: "asc", Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("GetPapers failed: %v", err)
+	}
+	if len(papers) != 3 || papers[0].AbstractWords != 40 || papers[2].AbstractWords != 400 {
+		t.Errorf("Expected papers sorted by abstract length, got %+v", papers)
+	}
+}
+
+func TestLicenseFilter(t *testing.T) {
+	db := setupTestDB(t)
+
+	licenses := map[string]string{
+		"2401.00001": "http://creativecommons.org/licenses/by/4.0/",
+		"2401.00002": "http://creativecommons.org/licenses/by-nc-nd/4.0/",
+		"2401.00003": "http://arxiv.org/licenses/nonexclusive-distrib/1.0/",
+		"2401.00004": "",
+	}
+	for id, license := range licenses {
+		paper := &models.Paper{ID: id, Title: id, License: license, PublishedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	// A later fetch without license information keeps the stored license
+	if err := db.UpsertPaper(&models.Paper{ID: "2401.00001", Title: "2401.00001", PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+
+	for filter, want := range map[string]int{"cc-by": 1, "cc": 2, "arxiv": 1, "unknown": 1, "": 4} {
+		papers, total, err := db.GetPapers(models.SearchParams{License: filter, Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("GetPapers failed: %v", err)
+		}
+		if total != want {
+			t.Errorf("License filter %q: expected %d papers, got %d", filter, want, total)
+		}
+		if filter == "cc-by" && len(papers) == 1 && papers[0].ID != "2401.00001" {
+			t.Errorf("Expected the CC BY paper, got %s", papers[0].ID)
+		}
+	}
+}
+
+func TestTagDetails(t *testing.T) {
+	db := setupTestDB(t)
+
+	published := map[string]time.Time{
+		"2401.00001": time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		"2401.00002": time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC),
+		"2403.00003": time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	}
+	tagID, _ := db.CreateTag("graphs")
+	db.CreateTag("unused")
+	for id, at := range published {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: id, PublishedAt: at, UpdatedAt: at}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+		db.TagPaper(id, tagID)
+	}
+
+	if err := db.SetTagDescription(tagID, "Graph learning papers"); err != nil {
+		t.Fatalf("SetTagDescription failed: %v", err)
+	}
+
+	tag, err := db.GetTag("graphs")
+	if err != nil {
+		t.Fatalf("GetTag failed: %v", err)
+	}
+	if tag.Description != "Graph learning papers" || tag.PaperCount != 3 {
+		t.Errorf("Unexpected tag: %+v", tag)
+	}
+
+	cloud, err := db.GetTagCloud()
+	if err != nil {
+		t.Fatalf("GetTagCloud failed: %v", err)
+	}
+	if len(cloud) != 2 || cloud[0].PaperCount != 3 || cloud[1].Name != "unused" || cloud[1].PaperCount != 0 {
+		t.Errorf("Unexpected tag cloud: %+v", cloud)
+	}
+
+	counts, err := db.GetTagMonthlyCounts(tagID)
+	if err != nil {
+		t.Fatalf("GetTagMonthlyCounts failed: %v", err)
+	}
+	want := []models.MonthCount{{Month: "2024-01", Count: 2}, {Month: "2024-03", Count: 1}}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("Expected monthly counts %v, got %v", want, counts)
+	}
+}
+
+func TestUpsertSkipsUnchangedPapers(t *testing.T) {
+	db := setupTestDB(t)
+
+	published := time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)
+	paper := &models.Paper{ID: "2401.00001", Title: "Original", Abstract: "Some words", PublishedAt: published, UpdatedAt: published}
+
+	changed, err := db.UpsertPaperStatus(paper)
+	if err != nil || !changed {
+		t.Fatalf("Expected first upsert to write, got %v, %v", changed, err)
+	}
+
+	// Mark a stored-only field so a rewrite would be visible
+	if _, err := db.Exec("UPDATE papers SET html_url = 'kept' WHERE id = ?", paper.ID); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	refetched := *paper
+	changed, err = db.UpsertPaperStatus(&refetched)
+	if err != nil || changed {
+		t.Fatalf("Expected unchanged paper to be skipped, got %v, %v", changed, err)
+	}
+
+	stored, err := db.GetPaperByID(paper.ID)
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if stored.LastSeenAt == nil || stored.ContentHash == "" || stored.HTMLURL != "kept" {
+		t.Errorf("Expected last_seen_at and hash set and other columns untouched, got %+v", stored)
+	}
+
+	revised := *paper
+	revised.Title = "Revised"
+	changed, err = db.UpsertPaperStatus(&revised)
+	if err != nil || !changed {
+		t.Fatalf("Expected changed paper to be written, got %v, %v", changed, err)
+	}
+	if stored, _ := db.GetPaperByID(paper.ID); stored.Title != "Revised" {
+		t.Errorf("Expected revised title, got %q", stored.Title)
+	}
+
+	// A failed derived write rolls the paper back, so the next fetch
+	// writes it again instead of skipping it by its hash
+	if _, err := db.Exec("DROP TABLE paper_entities"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+	rewritten := *paper
+	rewritten.Title = "Rewritten"
+	if _, err := db.UpsertPaperStatus(&rewritten); err == nil {
+		t.Fatal("Expected the upsert to fail")
+	}
+	var title string
+	if err := db.Get(&title, "SELECT title FROM papers WHERE id = ?", paper.ID); err != nil || title != "Revised" {
+		t.Errorf("Expected the failed write rolled back, got %q, %v", title, err)
+	}
+	if _, err := db.Exec(schemaSQL); err != nil {
+		t.Fatalf("Failed to recreate table: %v", err)
+	}
+	rewritten = *paper
+	rewritten.Title = "Rewritten"
+	if changed, err := db.UpsertPaperStatus(&rewritten); err != nil || !changed {
+		t.Errorf("Expected the paper to be written on the next fetch, got %v, %v", changed, err)
+	}
+}
+
+func TestRelations(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, id := range []string{"2401.00001", "2402.00002"} {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: "Paper " + id, PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	if err := db.AddRelation("2402.00002", "2401.00001", models.RelationSupersedes); err != nil {
+		t.Fatalf("AddRelation failed: %v", err)
+	}
+	// Adding the same link again is a no-op
+	if err := db.AddRelation("2402.00002", "2401.00001", models.RelationSupersedes); err != nil {
+		t.Fatalf("AddRelation failed: %v", err)
+	}
+	if err := db.AddRelation("2401.00001", "2401.00001", models.RelationExtends); err != ErrSelfRelation {
+		t.Errorf("Expected ErrSelfRelation, got %v", err)
+	}
+	if err := db.AddRelation("2401.00001", "2402.00002", "cites"); err == nil {
+		t.Error("Expected unknown kind to be rejected")
+	}
+
+	relations, err := db.GetPaperRelations("2401.00001")
+	if err != nil {
+		t.Fatalf("GetPaperRelations failed: %v", err)
+	}
+	if len(relations) != 1 {
+		t.Fatalf("Expected 1 relation, got %+v", relations)
+	}
+	rel := relations[0]
+	if rel.Label("2401.00001") != "Superseded by" || rel.Other("2401.00001") != "2402.00002" || rel.OtherTitle != "Paper 2402.00002" {
+		t.Errorf("Unexpected relation from the target side: %+v", rel)
+	}
+	if rel.Label("2402.00002") != "Supersedes" {
+		t.Errorf("Expected Supersedes from the source side, got %q", rel.Label("2402.00002"))
+	}
+
+	// Trashing either paper removes the link and restoring brings it back
+	if _, err := db.TrashPapers([]string{"2402.00002"}); err != nil {
+		t.Fatalf("TrashPapers failed: %v", err)
+	}
+	if relations, _ := db.GetPaperRelations("2401.00001"); len(relations) != 0 {
+		t.Errorf("Expected no relations after trashing, got %+v", relations)
+	}
+	if err := db.RestorePaper("2402.00002"); err != nil {
+		t.Fatalf("RestorePaper failed: %v", err)
+	}
+	relations, _ = db.GetPaperRelations("2401.00001")
+	if len(relations) != 1 || relations[0].Kind != models.RelationSupersedes {
+		t.Fatalf("Expected relation restored, got %+v", relations)
+	}
+
+	if err := db.DeleteRelation(relations[0].ID); err != nil {
+		t.Fatalf("DeleteRelation failed: %v", err)
+	}
+	if relations, _ := db.GetPaperRelations("2402.00002"); len(relations) != 0 {
+		t.Errorf("Expected relation deleted, got %+v", relations)
+	}
+}
+
+func TestDailyRollups(t *testing.T) {
+	db := setupTestDB(t)
+
+	papers := []*models.Paper{
+		{ID: "2401.00001", Title: "A", Categories: "cs.LG, stat.ML", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "2401.00002", Title: "B", Categories: "cs.LG", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+	for _, p := range papers {
+		if err := db.UpsertPaper(p); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	if err := db.RecordNewPapers(papers, yesterday); err != nil {
+		t.Fatalf("RecordNewPapers failed: %v", err)
+	}
+	if err := db.RecordNewPapers(papers[1:], time.Now()); err != nil {
+		t.Fatalf("RecordNewPapers failed: %v", err)
+	}
+
+	daily, err := db.GetDailyPapers(yesterday)
+	if err != nil {
+		t.Fatalf("GetDailyPapers failed: %v", err)
+	}
+	if len(daily) != 2 || daily[0].Count != 2 || daily[1].Count != 1 {
+		t.Errorf("Expected 2 papers yesterday and 1 today, got %+v", daily)
+	}
+	totals, err := db.GetCategoryTotals(yesterday, 10)
+	if err != nil {
+		t.Fatalf("GetCategoryTotals failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Category != "cs.LG" || totals[0].Count != 3 {
+		t.Errorf("Expected all papers under their primary category cs.LG, got %+v", totals)
+	}
+
+	// Only papers going from unread to read are counted
+	db.SaveToLibrary("2401.00001")
+	db.SaveToLibrary("2401.00002")
+	db.ToggleRead("2401.00001")
+	db.SetReadStatus([]string{"2401.00001", "2401.00002"}, true)
+	db.SetReadStatus([]string{"2401.00001"}, false)
+
+	reads, err := db.GetDailyReads(yesterday)
+	if err != nil {
+		t.Fatalf("GetDailyReads failed: %v", err)
+	}
+	if len(reads) != 1 || reads[0].Count != 2 {
+		t.Errorf("Expected 2 reads today, got %+v", reads)
+	}
+
+	// A database without rollups is backfilled from the papers table
+	if _, err := db.Exec("DELETE FROM rollup_category_day"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.backfillRollups(); err != nil {
+		t.Fatalf("backfillRollups failed: %v", err)
+	}
+	if daily, _ := db.GetDailyPapers(yesterday); len(daily) != 1 || daily[0].Count != 2 {
+		t.Errorf("Expected backfilled rollup of 2 for today, got %+v", daily)
+	}
+}
+
+func TestReplaceAuthor(t *testing.T) {
+	db := setupTestDB(t)
+
+	for id, authors := range map[string]string{
+		"2401.00001": `Anna G\"unter, Bo Li`,
+		"2401.00002": `G\"unter Schmidt`,
+		"2401.00003": "Carla Diaz",
+	} {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: id, Authors: authors, PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	changes, err := db.PreviewAuthorReplace(`G\"unter`, "Günter")
+	if err != nil {
+		t.Fatalf("PreviewAuthorReplace failed: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 papers to change, got %+v", changes)
+	}
+	for _, c := range changes {
+		if c.PaperID == "2401.00001" && c.After != "Anna Günter, Bo Li" {
+			t.Errorf("Unexpected preview: %+v", c)
+		}
+	}
+
+	if _, err := db.PreviewAuthorReplace("", "x"); err == nil {
+		t.Error("Expected empty search text to be rejected")
+	}
+
+	// A stale preview rolls back
+	if _, err := db.ReplaceAuthor(`G\"unter`, "Günter", 1); err != ErrPreviewStale {
+		t.Fatalf("Expected ErrPreviewStale, got %v", err)
+	}
+	if paper, _ := db.GetPaperByID("2401.00002"); paper.Authors != `G\"unter Schmidt` {
+		t.Errorf("Expected rollback, got %q", paper.Authors)
+	}
+
+	changed, err := db.ReplaceAuthor(`G\"unter`, "Günter", 2)
+	if err != nil || changed != 2 {
+		t.Fatalf("Expected 2 papers changed, got %d, %v", changed, err)
+	}
+	if paper, _ := db.GetPaperByID("2401.00002"); paper.Authors != "Günter Schmidt" {
+		t.Errorf("Expected replaced author, got %q", paper.Authors)
+	}
+}
+
+func TestEntityFilter(t *testing.T) {
+	db := setupTestDB(t)
+
+	papers := []*models.Paper{
+		{ID: "2401.00001", Title: "Stereo depth", Abstract: "We evaluate on KITTI.", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "2401.00002", Title: "Detection", Abstract: "Results on COCO and KITTI.", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "2401.00003", Title: "Language", Abstract: "We evaluate on GLUE.", PublishedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+	for _, p := range papers {
+		if err := db.UpsertPaper(p); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	results, total, err := db.GetPapers(models.SearchParams{Entity: "kitti", Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("GetPapers failed: %v", err)
+	}
+	if total != 2 || len(results) != 2 {
+		t.Errorf("Expected 2 papers mentioning KITTI, got %d", total)
+	}
+
+	entities, err := db.GetEntities()
+	if err != nil {
+		t.Fatalf("GetEntities failed: %v", err)
+	}
+	if len(entities) != 3 || entities[0].Name != "KITTI" || entities[0].Papers != 2 {
+		t.Errorf("Expected KITTI first with 2 papers, got %+v", entities)
+	}
+
+	// A changed abstract replaces the extracted entities
+	papers[0].Abstract = "We evaluate on Cityscapes."
+	if err := db.UpsertPaper(papers[0]); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	paper, err := db.GetPaperByID("2401.00001")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if len(paper.Entities) != 1 || paper.Entities[0].Name != "Cityscapes" {
+		t.Errorf("Expected only Cityscapes after the update, got %+v", paper.Entities)
+	}
+}
+
+func TestReadingPlan(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, id := range []string{"2401.00001", "2401.00002", "2401.00003"} {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: "Paper " + id, PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+	db.SaveToLibrary("2401.00001")
+	db.SaveToLibrary("2401.00002")
+
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	if err := db.PlanRead("2401.00001", day); err != nil {
+		t.Fatalf("PlanRead failed: %v", err)
+	}
+	if err := db.PlanRead("2401.00003", day); err != ErrNotInLibrary {
+		t.Errorf("Expected ErrNotInLibrary for a paper outside the library, got %v", err)
+	}
+
+	// Planning again moves the paper
+	if err := db.PlanRead("2401.00001", day.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("PlanRead failed: %v", err)
+	}
+	plan, err := db.GetReadingPlan(day, day.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("GetReadingPlan failed: %v", err)
+	}
+	if len(plan) != 1 || !plan[0].PlannedFor.Equal(day.AddDate(0, 0, 1)) || plan[0].Title != "Paper 2401.00001" {
+		t.Fatalf("Expected the paper on the next day, got %+v", plan)
+	}
+
+	queue, err := db.GetUnplannedQueue(10)
+	if err != nil {
+		t.Fatalf("GetUnplannedQueue failed: %v", err)
+	}
+	if len(queue) != 1 || queue[0].ID != "2401.00002" {
+		t.Errorf("Expected only the unplanned library paper queued, got %+v", queue)
+	}
+
+	// Removing a paper from the library takes it off the plan
+	if err := db.RemoveFromLibrary("2401.00001"); err != nil {
+		t.Fatalf("RemoveFromLibrary failed: %v", err)
+	}
+	planned, err := db.GetPlannedFor("2401.00001")
+	if err != nil {
+		t.Fatalf("GetPlannedFor failed: %v", err)
+	}
+	if !planned.IsZero() {
+		t.Errorf("Expected no plan after removing from library, got %v", planned)
+	}
+}
+
+func TestShelves(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, id := range []string{"2401.00001", "2401.00002"} {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: "Paper " + id, PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	toRead, err := db.CreateShelf("to-read", "")
+	if err != nil {
+		t.Fatalf("CreateShelf failed: %v", err)
+	}
+	teaching, err := db.CreateShelf("teaching", "Course material")
+	if err != nil {
+		t.Fatalf("CreateShelf failed: %v", err)
+	}
+	if _, err := db.CreateShelf("to-read", ""); err != ErrShelfExists {
+		t.Errorf("Expected ErrShelfExists for a duplicate name, got %v", err)
+	}
+
+	db.AddToShelf(toRead.ID, "2401.00001")
+	db.AddToShelf(toRead.ID, "2401.00002")
+	db.AddToShelf(teaching.ID, "2401.00001")
+
+	// Read state and priority belong to the shelf entry
+	if err := db.UpdateShelfEntry(toRead.ID, "2401.00001", true, models.PriorityHigh); err != nil {
+		t.Fatalf("UpdateShelfEntry failed: %v", err)
+	}
+	entry, err := db.GetShelfEntry(teaching.ID, "2401.00001")
+	if err != nil {
+		t.Fatalf("GetShelfEntry failed: %v", err)
+	}
+	if entry.IsRead || entry.Priority != models.PriorityNone {
+		t.Errorf("Expected the paper unread on the other shelf, got %+v", entry)
+	}
+
+	papers, err := db.GetShelfPapers(toRead.ID, "")
+	if err != nil {
+		t.Fatalf("GetShelfPapers failed: %v", err)
+	}
+	if len(papers) != 2 || papers[0].ID != "2401.00002" || !papers[1].IsRead || papers[1].Priority != models.PriorityHigh {
+		t.Errorf("Expected the unread paper first and the shelf's state, got %+v", papers)
+	}
+
+	shelf, err := db.GetShelf("to-read")
+	if err != nil {
+		t.Fatalf("GetShelf failed: %v", err)
+	}
+	if shelf.Papers != 2 || shelf.Unread != 1 {
+		t.Errorf("Expected 2 papers and 1 unread, got %d and %d", shelf.Papers, shelf.Unread)
+	}
+
+	// Trashing and restoring a paper keeps its shelf entries
+	if _, err := db.TrashPapers([]string{"2401.00001"}); err != nil {
+		t.Fatalf("TrashPapers failed: %v", err)
+	}
+	if shelf, _ := db.GetShelf("to-read"); shelf.Papers != 1 {
+		t.Errorf("Expected the trashed paper off the shelf, got %d papers", shelf.Papers)
+	}
+	if err := db.RestorePaper("2401.00001"); err != nil {
+		t.Fatalf("RestorePaper failed: %v", err)
+	}
+	if entry, err := db.GetShelfEntry(toRead.ID, "2401.00001"); err != nil || !entry.IsRead || entry.Priority != models.PriorityHigh {
+		t.Errorf("Expected the restored shelf entry, got %+v, %v", entry, err)
+	}
+
+	// Deleting a shelf leaves the papers and other shelves alone
+	if err := db.DeleteShelf(toRead.ID); err != nil {
+		t.Fatalf("DeleteShelf failed: %v", err)
+	}
+	entries, err := db.GetPaperShelves("2401.00001")
+	if err != nil {
+		t.Fatalf("GetPaperShelves failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Shelf != "teaching" {
+		t.Errorf("Expected only the teaching shelf left, got %+v", entries)
+	}
+	if exists, _ := db.PaperExists("2401.00002"); !exists {
+		t.Error("Expected the paper to survive its shelf")
+	}
+}
+
+func TestLibraryFilters(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, id := range []string{"2401.00001", "2401.00002", "2401.00003", "2401.00004"} {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: id, PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+	for _, id := range []string{"2401.00001", "2401.00002", "2401.00003"} {
+		if err := db.SaveToLibrary(id); err != nil {
+			t.Fatalf("SaveToLibrary failed: %v", err)
+		}
+	}
+	if _, err := db.SetReadStatus([]string{"2401.00001"}, true); err != nil {
+		t.Fatalf("SetReadStatus failed: %v", err)
+	}
+	if err := db.UpdateLibraryEntry("2401.00002", models.PriorityHigh, "baseline to beat"); err != nil {
+		t.Fatalf("UpdateLibraryEntry failed: %v", err)
+	}
+	if err := db.UpdateLibraryEntry("2401.00003", models.PriorityLow, ""); err != nil {
+		t.Fatalf("UpdateLibraryEntry failed: %v", err)
+	}
+	if _, err := db.Exec("UPDATE library SET saved_at = '2024-03-10 18:30:00' WHERE paper_id = '2401.00003'"); err != nil {
+		t.Fatalf("Failed to backdate paper: %v", err)
+	}
+
+	march := func(day int) time.Time { return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name   string
+		params models.SearchParams
+		want   int
+	}{
+		{"read", models.SearchParams{Read: "read"}, 1},
+		{"unread", models.SearchParams{Read: "unread"}, 2},
+		{"with note", models.SearchParams{Note: "with"}, 1},
+		{"without note", models.SearchParams{Note: "without"}, 2},
+		{"min priority", models.SearchParams{MinPriority: models.PriorityLow}, 2},
+		{"high priority", models.SearchParams{MinPriority: models.PriorityHigh}, 1},
+		{"saved on the day", models.SearchParams{SavedFrom: march(10), SavedTo: march(10)}, 1},
+		{"saved before", models.SearchParams{SavedTo: march(9)}, 0},
+		{"saved since", models.SearchParams{SavedFrom: march(11)}, 2},
+		{"combined", models.SearchParams{Read: "unread", MinPriority: models.PriorityMedium}, 1},
+	}
+	for _, tt := range tests {
+		tt.params.Page, tt.params.PageSize = 1, 10
+		_, total, err := db.GetPapers(tt.params)
+		if err != nil {
+			t.Fatalf("%s: GetPapers failed: %v", tt.name, err)
+		}
+		if total != tt.want {
+			t.Errorf("%s: expected %d papers, got %d", tt.name, tt.want, total)
+		}
+	}
+}
+
+func TestPersonalTags(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.UpsertPaper(&models.Paper{ID: "2401.00001", Title: "Paper", PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+
+	alice, bob := db.ForClient("alice"), db.ForClient("bob")
+	meh, err := alice.CreatePersonalTag("meh")
+	if err != nil {
+		t.Fatalf("CreatePersonalTag failed: %v", err)
+	}
+	group, err := alice.CreateTag("reading-group")
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	for _, id := range []int{meh, group} {
+		if err := alice.TagPaper("2401.00001", id); err != nil {
+			t.Fatalf("TagPaper failed: %v", err)
+		}
+	}
+
+	names := func(d *DB) []string {
+		tags, err := d.GetPaperTags("2401.00001")
+		if err != nil {
+			t.Fatalf("GetPaperTags failed: %v", err)
+		}
+		var out []string
+		for _, tag := range tags {
+			out = append(out, tag.Name)
+		}
+		return out
+	}
+	if got := names(alice); len(got) != 2 {
+		t.Errorf("Expected the owner to see both tags, got %v", got)
+	}
+	for _, d := range []*DB{bob, db} {
+		if got := names(d); len(got) != 1 || got[0] != "reading-group" {
+			t.Errorf("Expected only the shared tag, got %v", got)
+		}
+	}
+	if cloud, _ := bob.GetTagCloud(); len(cloud) != 1 {
+		t.Errorf("Expected the personal tag to be left out of others' tag cloud, got %+v", cloud)
+	}
+	if _, err := bob.GetTag("meh"); err != sql.ErrNoRows {
+		t.Errorf("Expected another browser's personal tag to be hidden, got %v", err)
+	}
+	for _, d := range []*DB{bob, db} {
+		if papers, total, err := d.GetPapers(models.SearchParams{Tag: "meh", Page: 1, PageSize: 10}); err != nil || total != 0 || len(papers) != 0 {
+			t.Errorf("Expected filtering by another browser's personal tag to find nothing, got %d papers, %v", total, err)
+		}
+	}
+	if ids, _ := bob.GetPaperIDs(models.SearchParams{Tag: "meh"}); len(ids) != 0 {
+		t.Errorf("Expected no paper IDs for another browser's personal tag, got %v", ids)
+	}
+	if _, total, _ := alice.GetPapers(models.SearchParams{Tag: "meh", Page: 1, PageSize: 10}); total != 1 {
+		t.Errorf("Expected the owner to filter by the personal tag, got %d papers", total)
+	}
+
+	// Others get a tag of their own under the name, and can neither remove
+	// nor share alice's
+	bobs, err := bob.CreatePersonalTag("meh")
+	if err != nil || bobs == meh {
+		t.Fatalf("Expected bob's own tag, got %d, %v", bobs, err)
+	}
+	if tag, err := bob.GetTag("meh"); err != nil || tag.ID != bobs {
+		t.Errorf("Expected bob to see his own tag, got %+v, %v", tag, err)
+	}
+	if tag, err := alice.GetTag("meh"); err != nil || tag.ID != meh {
+		t.Errorf("Expected alice to still see hers, got %+v, %v", tag, err)
+	}
+	if err := bob.UntagPaper("2401.00001", meh); err != nil {
+		t.Fatalf("UntagPaper failed: %v", err)
+	}
+	if got := names(alice); len(got) != 2 {
+		t.Errorf("Expected the personal tag to survive another browser's removal, got %v", got)
+	}
+	if err := bob.ShareTag(meh); err != ErrPersonalTag {
+		t.Errorf("Expected ErrPersonalTag, got %v", err)
+	}
+
+	if err := alice.ShareTag(meh); err != nil {
+		t.Fatalf("ShareTag failed: %v", err)
+	}
+	if got := names(bob); len(got) != 2 {
+		t.Errorf("Expected a shared tag to be visible to everyone, got %v", got)
+	}
+
+	// Own tags come first, and a name can only be shared once
+	if tag, err := bob.GetTag("meh"); err != nil || tag.ID != bobs {
+		t.Errorf("Expected bob's own tag before the shared one, got %+v, %v", tag, err)
+	}
+	if id, err := bob.CreateTag("meh"); err != nil || id != bobs {
+		t.Errorf("Expected bob's own tag to be reused, got %d, %v", id, err)
+	}
+	if id, err := db.ForClient("carol").CreatePersonalTag("meh"); err != nil || id != meh {
+		t.Errorf("Expected the shared tag to be reused, got %d, %v", id, err)
+	}
+	if err := bob.ShareTag(bobs); err != ErrSharedTagExists {
+		t.Errorf("Expected ErrSharedTagExists, got %v", err)
+	}
+}
+
+func TestDeliveries(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	if err := db.RecordDeliveries("slack", []string{"2401.00001"}, false, nil, now); err != nil {
+		t.Fatalf("RecordDeliveries failed: %v", err)
+	}
+	if err := db.RecordDeliveries("slack", []string{"2401.00002"}, true, errors.New("timeout"), now); err != nil {
+		t.Fatalf("RecordDeliveries failed: %v", err)
+	}
+
+	ids, err := db.UndeliveredPapers("slack", []string{"2401.00003", "2401.00002", "2401.00001"})
+	if err != nil {
+		t.Fatalf("UndeliveredPapers failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "2401.00003" || ids[1] != "2401.00002" {
+		t.Errorf("Expected the unsent and failed papers in order, got %v", ids)
+	}
+	if ids, _ := db.UndeliveredPapers("email", []string{"2401.00001"}); len(ids) != 1 {
+		t.Errorf("Expected deliveries to be per channel, got %v", ids)
+	}
+
+	// A later failure doesn't undo a delivery
+	if err := db.RecordDeliveries("slack", []string{"2401.00001"}, false, errors.New("timeout"), now.Add(time.Minute)); err != nil {
+		t.Fatalf("RecordDeliveries failed: %v", err)
+	}
+	deliveries, err := db.GetDeliveries("slack", 10)
+	if err != nil {
+		t.Fatalf("GetDeliveries failed: %v", err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %+v", deliveries)
+	}
+	for _, d := range deliveries {
+		if d.PaperID == "2401.00001" && d.Status != models.DeliverySent {
+			t.Errorf("Expected the delivery to stay sent, got %+v", d)
+		}
+		if d.PaperID == "2401.00002" && (d.Status != models.DeliveryFailed || d.Error != "timeout" || !d.Digest) {
+			t.Errorf("Expected the failed digest with its error, got %+v", d)
+		}
+	}
+}
+
+func TestSavedViews(t *testing.T) {
+	db := setupTestDB(t)
+
+	view, err := db.CreateView("diffusion", models.ViewBrowse, "q=diffusion", []string{"2401.00001", "2401.00002"})
+	if err != nil {
+		t.Fatalf("CreateView failed: %v", err)
+	}
+	if view.VisitedAt != nil {
+		t.Errorf("Expected a new view not to be visited, got %v", view.VisitedAt)
+	}
+	if _, err := db.CreateView("diffusion", models.ViewLibrary, "", nil); !errors.Is(err, ErrViewExists) {
+		t.Errorf("Expected ErrViewExists, got %v", err)
+	}
+
+	// The papers present when the view was saved aren't new
+	arrived, err := db.VisitView(view.ID, []string{"2401.00003", "2401.00001", "2401.00002"}, time.Now())
+	if err != nil {
+		t.Fatalf("VisitView failed: %v", err)
+	}
+	if len(arrived) != 1 || arrived[0] != "2401.00003" {
+		t.Errorf("Expected one new paper, got %v", arrived)
+	}
+
+	// A paper that drops out and comes back is new again
+	if arrived, _ := db.VisitView(view.ID, []string{"2401.00003", "2401.00002"}, time.Now()); len(arrived) != 0 {
+		t.Errorf("Expected nothing new, got %v", arrived)
+	}
+	if arrived, _ := db.VisitView(view.ID, []string{"2401.00001", "2401.00003"}, time.Now()); len(arrived) != 1 || arrived[0] != "2401.00001" {
+		t.Errorf("Expected the returning paper, got %v", arrived)
+	}
+
+	view, err = db.GetView("diffusion")
+	if err != nil {
+		t.Fatalf("GetView failed: %v", err)
+	}
+	if view.VisitedAt == nil {
+		t.Error("Expected the visit to be recorded")
+	}
+
+	if err := db.DeleteView(view.ID); err != nil {
+		t.Fatalf("DeleteView failed: %v", err)
+	}
+	if views, _ := db.GetViews(); len(views) != 0 {
+		t.Errorf("Expected no views, got %+v", views)
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	db := setupTestDB(t)
+	for _, id := range []string{"2401.00001", "2401.00002"} {
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: "Paper " + id, PublishedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+		if err := db.SaveToLibrary(id); err != nil {
+			t.Fatalf("SaveToLibrary failed: %v", err)
+		}
+	}
+	tagID, _ := db.CreateTag("ml")
+	db.TagPaper("2401.00002", tagID)
+	personalID, _ := db.ForClient("alice").CreatePersonalTag("secret")
+	db.TagPaper("2401.00002", personalID)
+
+	// Marking read papers read again, or papers unread, isn't a read
+	if err := db.ToggleRead("2401.00001"); err != nil {
+		t.Fatalf("ToggleRead failed: %v", err)
+	}
+	if _, err := db.SetReadStatus([]string{"2401.00001", "2401.00002"}, true); err != nil {
+		t.Fatalf("SetReadStatus failed: %v", err)
+	}
+	if _, err := db.SetReadStatus([]string{"2401.00002"}, false); err != nil {
+		t.Fatalf("SetReadStatus failed: %v", err)
+	}
+
+	events, err := db.GetReadEvents(0, 10)
+	if err != nil {
+		t.Fatalf("GetReadEvents failed: %v", err)
+	}
+	if len(events) != 2 || events[0].PaperID != "2401.00001" || events[1].PaperID != "2401.00002" {
+		t.Fatalf("Expected one event per read, got %+v", events)
+	}
+	if events[1].Title != "Paper 2401.00002" || len(events[1].Tags) != 1 || events[1].Tags[0] != "ml" {
+		t.Errorf("Expected the paper with its shared tags, got %+v", events[1])
+	}
+
+	if err := db.SetReadLogCursor("readwise", events[0].ID); err != nil {
+		t.Fatalf("SetReadLogCursor failed: %v", err)
+	}
+	cursor, err := db.GetReadLogCursor("readwise")
+	if err != nil || cursor != events[0].ID {
+		t.Fatalf("Expected cursor %d, got %d, %v", events[0].ID, cursor, err)
+	}
+	if rest, _ := db.GetReadEvents(cursor, 10); len(rest) != 1 {
+		t.Errorf("Expected the event after the cursor, got %+v", rest)
+	}
+	if cursor, _ := db.GetReadLogCursor("webhook"); cursor != 0 {
+		t.Errorf("Expected a new log to start from the beginning, got %d", cursor)
+	}
+}
+
+func TestUpsertPaperPublishedVersion(t *testing.T) {
+	db := setupTestDB(t)
+	paper := &models.Paper{
+		ID: "2401.00001", Title: "Paper", PublishedAt: time.Now(), UpdatedAt: time.Now(),
+		DOI: "10.1145/3580305.3599000", JournalRef: "Proceedings of KDD 2024",
+	}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+
+	stored, err := db.GetPaperByID(paper.ID)
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if stored.DOI != paper.DOI || stored.JournalRef != paper.JournalRef {
+		t.Errorf("Expected the DOI and journal reference to be stored, got %q, %q", stored.DOI, stored.JournalRef)
+	}
+
+	// They survive a trip through the trash
+	db.TrashPapers([]string{paper.ID})
+	if err := db.RestorePaper(paper.ID); err != nil {
+		t.Fatalf("RestorePaper failed: %v", err)
+	}
+	if stored, _ := db.GetPaperByID(paper.ID); stored == nil || stored.DOI != paper.DOI {
+		t.Errorf("Expected the DOI to be restored, got %+v", stored)
+	}
+}
+
+func TestSetLibraryNote(t *testing.T) {
+	db := setupTestDB(t)
+
+	paper := &models.Paper{ID: "2401.00001", Title: "Noted", Authors: "A", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	if err := db.SetLibraryNote(paper.ID, "not saved yet"); err == nil {
+		t.Error("Expected a note on a paper outside the library to fail")
+	}
+
+	db.SaveToLibrary(paper.ID)
+	db.UpdateLibraryEntry(paper.ID, models.PriorityHigh, "")
+	if err := db.SetLibraryNote(paper.ID, "for the survey"); err != nil {
+		t.Fatalf("SetLibraryNote failed: %v", err)
+	}
+
+	got, _ := db.GetPaperByID(paper.ID)
+	if got.Note != "for the survey" || got.Priority != models.PriorityHigh {
+		t.Errorf("Expected the note set and the priority kept, got %q, %d", got.Note, got.Priority)
+	}
+}
+
+func TestGetPapersByManyIDs(t *testing.T) {
+	db := setupTestDB(t)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := []string{"missing"}
+	for i := 0; i < maxInIDs+100; i++ {
+		id := fmt.Sprintf("2401.%05d", i)
+		day := base.AddDate(0, 0, i%40)
+		if err := db.UpsertPaper(&models.Paper{ID: id, Title: id, PublishedAt: day, UpdatedAt: day}); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	papers, err := db.GetPapersByIDs(ids)
+	if err != nil {
+		t.Fatalf("GetPapersByIDs failed: %v", err)
+	}
+	if len(papers) != maxInIDs+100 {
+		t.Fatalf("Expected %d papers, got %d", maxInIDs+100, len(papers))
+	}
+	for i := 1; i < len(papers); i++ {
+		prev, cur := papers[i-1], papers[i]
+		if cur.PublishedAt.After(prev.PublishedAt) || (cur.PublishedAt.Equal(prev.PublishedAt) && cur.ID > prev.ID) {
+			t.Fatalf("Expected newest first across chunks, got %s before %s", prev.ID, cur.ID)
+		}
+	}
 }
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// selectQuery assembles a SELECT statement from parts, keeping each
+// condition next to its arguments so the placeholders and values cannot
+// drift apart as filters are added. Conditions are ANDed.
+type selectQuery struct {
+	distinct   bool
+	columns    []string
+	from       string
+	joins      []string
+	conditions []string
+	args       []interface{}
+	orderBy    []string
+	limit      int
+	offset     int
+}
+
+// newSelect starts a query for the given columns
+func newSelect(columns ...string) *selectQuery {
+	return &selectQuery{columns: columns}
+}
+
+// Distinct makes the query SELECT DISTINCT
+func (q *selectQuery) Distinct() *selectQuery {
+	q.distinct = true
+	return q
+}
+
+// From sets the table, with its alias if any, e.g. "papers p"
+func (q *selectQuery) From(table string) *selectQuery {
+	q.from = table
+	return q
+}
+
+// Join adds a join clause, e.g. "LEFT JOIN library l ON p.id = l.paper_id"
+func (q *selectQuery) Join(clause string) *selectQuery {
+	q.joins = append(q.joins, clause)
+	return q
+}
+
+// Where adds a condition with one argument per ? placeholder. A mismatch is
+// a programming error and panics.
+func (q *selectQuery) Where(condition string, args ...interface{}) *selectQuery {
+	if n := strings.Count(condition, "?"); n != len(args) {
+		panic(fmt.Sprintf("db: condition %q has %d placeholders but %d arguments", condition, n, len(args)))
+	}
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// OrderBy appends sort terms, e.g. "p.published_at DESC"
+func (q *selectQuery) OrderBy(terms ...string) *selectQuery {
+	q.orderBy = append(q.orderBy, terms...)
+	return q
+}
+
+// Page limits the query to limit rows from offset. A limit of zero or less
+// returns every row.
+func (q *selectQuery) Page(limit, offset int) *selectQuery {
+	q.limit = limit
+	q.offset = offset
+	if q.offset < 0 {
+		q.offset = 0
+	}
+	return q
+}
+
+// Count returns a query counting the rows this one matches, or the distinct
+// values of expr, ignoring its columns, order and page
+func (q *selectQuery) Count(expr string) *selectQuery {
+	return &selectQuery{
+		columns:    []string{"COUNT(" + expr + ")"},
+		from:       q.from,
+		joins:      q.joins,
+		conditions: q.conditions,
+		args:       q.args,
+	}
+}
+
+// Build returns the statement and its arguments
+func (q *selectQuery) Build() (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if q.distinct {
+		b.WriteString("DISTINCT ")
+	}
+	b.WriteString(strings.Join(q.columns, ", "))
+	b.WriteString("\nFROM " + q.from)
+	for _, j := range q.joins {
+		b.WriteString("\n" + j)
+	}
+	if len(q.conditions) > 0 {
+		b.WriteString("\nWHERE " + strings.Join(q.conditions, "\nAND "))
+	}
+	if len(q.orderBy) > 0 {
+		b.WriteString("\nORDER BY " + strings.Join(q.orderBy, ", "))
+	}
+
+	args := append([]interface{}(nil), q.args...)
+	if q.limit > 0 {
+		b.WriteString("\nLIMIT ? OFFSET ?")
+		args = append(args, q.limit, q.offset)
+	}
+	return b.String(), args
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestSelectQuery(t *testing.T) {
+	q := newSelect("p.id", "p.title").
+		Distinct().
+		From("papers p").
+		Join("LEFT JOIN library l ON p.id = l.paper_id").
+		Where("p.abstract_words BETWEEN ? AND ?", 100, 200).
+		Where("l.paper_id IS NOT NULL").
+		Where("p.title LIKE ?", "%x%")
+
+	count, countArgs := q.Count("DISTINCT p.id").Build()
+	wantCount := "SELECT COUNT(DISTINCT p.id)\nFROM papers p\nLEFT JOIN library l ON p.id = l.paper_id\n" +
+		"WHERE p.abstract_words BETWEEN ? AND ?\nAND l.paper_id IS NOT NULL\nAND p.title LIKE ?"
+	if count != wantCount {
+		t.Errorf("Count query:\n%s\nwant:\n%s", count, wantCount)
+	}
+	if !reflect.DeepEqual(countArgs, []interface{}{100, 200, "%x%"}) {
+		t.Errorf("Count args = %v", countArgs)
+	}
+
+	query, args := q.OrderBy("p.title ASC", "p.id").Page(20, 40).Build()
+	if !strings.HasPrefix(query, "SELECT DISTINCT p.id, p.title\n") ||
+		!strings.HasSuffix(query, "\nORDER BY p.title ASC, p.id\nLIMIT ? OFFSET ?") {
+		t.Errorf("Unexpected query:\n%s", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{100, 200, "%x%", 20, 40}) {
+		t.Errorf("Args = %v", args)
+	}
+
+	// Building twice doesn't accumulate pagination arguments
+	if _, again := q.Build(); len(again) != len(args) {
+		t.Errorf("Expected the same arguments on a second build, got %v", again)
+	}
+
+	bare, bareArgs := newSelect("id").From("papers").Page(0, -5).Build()
+	if bare != "SELECT id\nFROM papers" || len(bareArgs) != 0 {
+		t.Errorf("Expected an unfiltered, unpaged query, got %q %v", bare, bareArgs)
+	}
+}
+
+func TestSelectQueryPlaceholderMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a condition with missing arguments to panic")
+		}
+	}()
+	newSelect("id").From("papers").Where("id = ? OR title = ?", "x")
+}
+
+func TestPaperQuery(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Every filter together must produce a valid statement
+	params := models.SearchParams{
+		Query: "graph", Category: "cs.LG", Length: "medium", License: "cc-by",
+		Entity: "PyTorch", InLibrary: true, Tag: "gnn", SortBy: "priority",
+		Page: 2, PageSize: 10,
+	}
+	query, args := paperQuery(params, "", "p.id").OrderBy(paperOrder(params)...).Page(10, 10).Build()
+	if n := strings.Count(query, "?"); n != len(args) {
+		t.Fatalf("Expected %d placeholders, got %d in:\n%s", len(args), n, query)
+	}
+	var ids []string
+	if err := db.Select(&ids, query, args...); err != nil {
+		t.Fatalf("Query failed: %v\n%s", err, query)
+	}
+
+	if order := paperOrder(models.SearchParams{SortOrder: "asc"}); !reflect.DeepEqual(order, []string{"p.published_at ASC", "p.id ASC"}) {
+		t.Errorf("Default order = %v", order)
+	}
+}
+
+func TestGetPapersPagesStably(t *testing.T) {
+	db := setupTestDB(t)
+
+	// A bulk announcement: every paper published the same second
+	published := time.Date(2024, 1, 15, 20, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		paper := &models.Paper{ID: fmt.Sprintf("2401.%05d", i), Title: "Same title", PublishedAt: published, UpdatedAt: published}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	for _, sortBy := range []string{"published", "title", "length"} {
+		var ids []string
+		for page := 1; page <= 3; page++ {
+			papers, _, err := db.GetPapers(models.SearchParams{SortBy: sortBy, Page: page, PageSize: 10})
+			if err != nil {
+				t.Fatalf("GetPapers failed: %v", err)
+			}
+			for _, p := range papers {
+				ids = append(ids, p.ID)
+			}
+		}
+
+		if len(ids) != 25 {
+			t.Fatalf("%s: expected 25 papers across the pages, got %d", sortBy, len(ids))
+		}
+		for i := range ids {
+			if want := fmt.Sprintf("2401.%05d", 24-i); ids[i] != want {
+				t.Fatalf("%s: expected %s at position %d, got %s", sortBy, want, i, ids[i])
+			}
+		}
+	}
+}
+
+func TestPaperOrderUsesIndex(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, order := range []string{"desc", "asc"} {
+		params := models.SearchParams{SortOrder: order}
+		query, args := paperQuery(params, "", "p.id").Distinct().OrderBy(paperOrder(params)...).Page(10, 0).Build()
+
+		var plan []struct {
+			ID      int    `db:"id"`
+			Parent  int    `db:"parent"`
+			NotUsed int    `db:"notused"`
+			Detail  string `db:"detail"`
+		}
+		if err := db.Select(&plan, "EXPLAIN QUERY PLAN "+query, args...); err != nil {
+			t.Fatalf("EXPLAIN failed: %v", err)
+		}
+		var details []string
+		for _, step := range plan {
+			details = append(details, step.Detail)
+		}
+		steps := strings.Join(details, "\n")
+		if !strings.Contains(steps, "idx_papers_published_id") || strings.Contains(steps, "FOR ORDER BY") {
+			t.Errorf("Expected the %s sort to read the index in order, got plan:\n%s", order, steps)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// Quota bounds the size of the database. Zero fields are unlimited.
+type Quota struct {
+	MaxPapers int
+	MaxBytes  int64
+}
+
+// Enabled reports whether the quota sets any limit
+func (q Quota) Enabled() bool {
+	return q.MaxPapers > 0 || q.MaxBytes > 0
+}
+
+// prunable matches papers nobody has done anything with: not in the
+// library (which holds notes and read state), untagged, not on a shelf, in
+// an assignment or in a relation, and not approved into the team feed
+const prunable = `
+	NOT EXISTS (SELECT 1 FROM library l WHERE l.paper_id = p.id)
+	AND NOT EXISTS (SELECT 1 FROM paper_tags pt WHERE pt.paper_id = p.id)
+	AND NOT EXISTS (SELECT 1 FROM shelf_papers sp WHERE sp.paper_id = p.id)
+	AND NOT EXISTS (SELECT 1 FROM assignments a WHERE a.paper_id = p.id)
+	AND NOT EXISTS (SELECT 1 FROM relations r WHERE r.from_id = p.id OR r.to_id = p.id)
+	AND NOT EXISTS (SELECT 1 FROM reviews rv WHERE rv.paper_id = p.id AND rv.status = 'approved')`
+
+// Size returns the bytes the database's pages hold, leaving out free pages.
+// Deleting rows lowers it right away, unlike the file size, which only
+// shrinks on VACUUM.
+func (db *DB) Size() (int64, error) {
+	var pages, free, pageSize int64
+	if err := db.Get(&pages, "PRAGMA page_count"); err != nil {
+		return 0, err
+	}
+	if err := db.Get(&free, "PRAGMA freelist_count"); err != nil {
+		return 0, err
+	}
+	if err := db.Get(&pageSize, "PRAGMA page_size"); err != nil {
+		return 0, err
+	}
+	return (pages - free) * pageSize, nil
+}
+
+// PruneToQuota permanently deletes the oldest prunable papers until the
+// database is within the quota, and returns them. Papers in the library,
+// tagged or otherwise curated are never pruned, so a database can stay over
+// its quota. The size limit is met approximately: papers are assumed to
+// take an equal share of the database, and the next run prunes more if
+// that was optimistic.
+func (db *DB) PruneToQuota(q Quota) ([]models.Paper, error) {
+	if !q.Enabled() {
+		return nil, nil
+	}
+
+	count, err := db.GetPaperCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count papers: %w", err)
+	}
+	excess := 0
+	if q.MaxPapers > 0 && count > q.MaxPapers {
+		excess = count - q.MaxPapers
+	}
+	if q.MaxBytes > 0 && count > 0 {
+		size, err := db.Size()
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure the database: %w", err)
+		}
+		if size > q.MaxBytes {
+			keep := int(int64(count) * q.MaxBytes / size)
+			excess = max(excess, count-keep)
+		}
+	}
+	if excess == 0 {
+		return nil, nil
+	}
+
+	var pruned []models.Paper
+	err = db.Transaction(func(tx *sqlx.Tx) error {
+		pruned = nil
+		if err := tx.Select(&pruned, `
+			SELECT p.id, p.title, p.published_at FROM papers p
+			WHERE `+prunable+`
+			ORDER BY p.published_at, p.id
+			LIMIT ?
+		`, excess); err != nil {
+			return fmt.Errorf("failed to find papers to prune: %w", err)
+		}
+		for _, p := range pruned {
+			if err := deletePaper(tx, p.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(pruned))
+	for i, p := range pruned {
+		ids[i] = p.ID
+	}
+	db.invalidate(ids...)
+	return pruned, nil
+}
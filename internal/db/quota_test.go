@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestPruneToQuota(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Ten papers, oldest first
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		day := start.AddDate(0, 0, i)
+		paper := &models.Paper{ID: fmt.Sprintf("2401.%05d", i), Title: "Paper", PublishedAt: day, UpdatedAt: day}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	// The three oldest are curated
+	db.SaveToLibrary("2401.00000")
+	tagID, _ := db.ForClient("alice").CreatePersonalTag("mine")
+	db.TagPaper("2401.00001", tagID)
+	shelf, _ := db.CreateShelf("Later", "")
+	db.AddToShelf(shelf.ID, "2401.00002")
+
+	if pruned, err := db.PruneToQuota(Quota{}); err != nil || pruned != nil {
+		t.Fatalf("Expected no quota to prune nothing, got %v, %v", pruned, err)
+	}
+
+	pruned, err := db.PruneToQuota(Quota{MaxPapers: 6})
+	if err != nil {
+		t.Fatalf("PruneToQuota failed: %v", err)
+	}
+	var ids []string
+	for _, p := range pruned {
+		ids = append(ids, p.ID)
+	}
+	if fmt.Sprint(ids) != "[2401.00003 2401.00004 2401.00005 2401.00006]" {
+		t.Errorf("Expected the four oldest uncurated papers to be pruned, got %v", ids)
+	}
+	if count, _ := db.GetPaperCount(); count != 6 {
+		t.Errorf("Expected 6 papers left, got %d", count)
+	}
+	if _, err := db.GetPaperByID("2401.00003"); err == nil {
+		t.Error("Expected a pruned paper to be gone")
+	}
+
+	// Curated papers are kept even if that leaves the database over quota
+	pruned, _ = db.PruneToQuota(Quota{MaxPapers: 1})
+	if len(pruned) != 3 {
+		t.Errorf("Expected only the 3 remaining uncurated papers to be pruned, got %d", len(pruned))
+	}
+	if count, _ := db.GetPaperCount(); count != 3 {
+		t.Errorf("Expected the 3 curated papers to be kept, got %d", count)
+	}
+
+	// A size quota prunes in proportion to the excess
+	size, err := db.Size()
+	if err != nil || size <= 0 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+	if pruned, _ := db.PruneToQuota(Quota{MaxBytes: size}); len(pruned) != 0 {
+		t.Errorf("Expected nothing pruned within the size quota, got %d", len(pruned))
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// recordReadEvents records library papers being marked as read
+func recordReadEvents(tx *sqlx.Tx, paperIDs []string, at time.Time) error {
+	for _, id := range paperIDs {
+		if _, err := tx.Exec("INSERT INTO read_events (paper_id, read_at) VALUES (?, ?)", id, at.UTC()); err != nil {
+			return fmt.Errorf("failed to record read event: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetReadEvents returns up to limit read events after the given event ID,
+// oldest first, with each paper's shared tags. Events of papers that are
+// no longer stored are left out.
+func (db *DB) GetReadEvents(afterID int64, limit int) ([]models.ReadEvent, error) {
+	query := `
+		SELECT e.id, e.paper_id, e.read_at, p.title, p.authors, p.arxiv_url, COALESCE(l.note, '') AS note
+		FROM read_events e
+		JOIN papers p ON p.id = e.paper_id
+		LEFT JOIN library l ON l.paper_id = e.paper_id
+		WHERE e.id > ?
+		ORDER BY e.id
+		LIMIT ?
+	`
+	var events []models.ReadEvent
+	if err := db.Select(&events, query, afterID, limit); err != nil {
+		return nil, fmt.Errorf("failed to fetch read events: %w", err)
+	}
+
+	// Personal tags stay out of logs outside the app
+	shared := db.ForClient("")
+	for i := range events {
+		tags, err := shared.GetPaperTags(events[i].PaperID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tags: %w", err)
+		}
+		events[i].Tags = make([]string, len(tags))
+		for j, t := range tags {
+			events[i].Tags[j] = t.Name
+		}
+	}
+	return events, nil
+}
+
+// GetReadLogCursor returns the ID of the last read event pushed to a
+// reading log, 0 if none was
+func (db *DB) GetReadLogCursor(sink string) (int64, error) {
+	var id int64
+	err := db.Get(&id, "SELECT event_id FROM readlog_cursors WHERE sink = ?", sink)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SetReadLogCursor records the last read event pushed to a reading log
+func (db *DB) SetReadLogCursor(sink string, eventID int64) error {
+	_, err := db.Exec(`
+		INSERT INTO readlog_cursors (sink, event_id) VALUES (?, ?)
+		ON CONFLICT(sink) DO UPDATE SET event_id = excluded.event_id
+	`, sink, eventID)
+	return err
+}
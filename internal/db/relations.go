@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrSelfRelation is returned when linking a paper to itself
+var ErrSelfRelation = errors.New("a paper cannot be related to itself")
+
+// AddRelation links two papers with a relation of the given kind. Adding a
+// link that already exists is a no-op.
+func (db *DB) AddRelation(fromID, toID, kind string) error {
+	if fromID == toID {
+		return ErrSelfRelation
+	}
+	if !models.ValidRelationKind(kind) {
+		return fmt.Errorf("unknown relation kind %q", kind)
+	}
+
+	_, err := db.Exec(
+		"INSERT OR IGNORE INTO relations (from_id, to_id, kind) VALUES (?, ?, ?)",
+		fromID, toID, kind,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add relation: %w", err)
+	}
+	return nil
+}
+
+// GetPaperRelations returns the relations a paper takes part in, in either
+// direction, with the other paper's title. Links to papers no longer in
+// the database are left out.
+func (db *DB) GetPaperRelations(paperID string) ([]models.Relation, error) {
+	query := `
+		SELECT r.id, r.from_id, r.to_id, r.kind, r.created_at, p.title
+		FROM relations r
+		JOIN papers p ON p.id = CASE WHEN r.from_id = ? THEN r.to_id ELSE r.from_id END
+		WHERE r.from_id = ? OR r.to_id = ?
+		ORDER BY r.kind, r.created_at, r.id
+	`
+
+	var relations []models.Relation
+	if err := db.Select(&relations, query, paperID, paperID, paperID); err != nil {
+		return nil, fmt.Errorf("failed to fetch relations: %w", err)
+	}
+	return relations, nil
+}
+
+// DeleteRelation removes a relation
+func (db *DB) DeleteRelation(id int) error {
+	_, err := db.Exec("DELETE FROM relations WHERE id = ?", id)
+	return err
+}
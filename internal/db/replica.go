@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OpenReplica opens a read-only copy of the database, kept up to date by
+// replication outside the app (such as LiteFS or Litestream), and routes
+// the heavy read-only queries to it: paper lists and searches, and the
+// statistics pages. Everything else, including what the caches are filled
+// from, keeps reading the primary, as the replica may lag behind it. A
+// query failing on the replica, for instance before it has caught up with
+// a schema upgrade, is run again on the primary.
+func (db *DB) OpenReplica(path string) error {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro"
+	replica, err := sqlx.Open(driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open replica: %w", err)
+	}
+	replica.SetMaxOpenConns(readConnections)
+	replica.SetMaxIdleConns(readConnections)
+	if err := replica.Ping(); err != nil {
+		replica.Close()
+		return fmt.Errorf("failed to open replica: %w", err)
+	}
+
+	if old := db.replica.Swap(replica); old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// fromReplica returns a handle whose Get and Select run on the replica if
+// one is open, falling back to the primary
+func (db *DB) fromReplica() *DB {
+	replica := db.replica.Load()
+	if replica == nil {
+		return db
+	}
+	r := *db
+	r.fallback = db.reads()
+	r.readers = replica
+	return &r
+}
+
+// retryOnPrimary runs a read that failed on the replica again on the
+// primary. It returns err unchanged if the handle doesn't read the replica
+// or the read succeeded.
+func (db *DB) retryOnPrimary(err error, read func(*sqlx.DB) error) error {
+	if err == nil || db.fallback == nil || err == sql.ErrNoRows {
+		return err
+	}
+	log.Printf("Error reading from the replica, reading from the primary: %v", err)
+	return read(db.fallback)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestOpenReplica(t *testing.T) {
+	db := setupTestDB(t)
+	upsert := func(id string) {
+		t.Helper()
+		paper := &models.Paper{ID: id, Title: "Paper " + id, PublishedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+	total := func() int {
+		t.Helper()
+		_, total, err := db.GetPapers(models.SearchParams{Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("GetPapers failed: %v", err)
+		}
+		return total
+	}
+
+	upsert("2401.00001")
+	path := filepath.Join(t.TempDir(), "replica.db")
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		t.Fatalf("VACUUM INTO failed: %v", err)
+	}
+	if err := db.OpenReplica(path); err != nil {
+		t.Fatalf("OpenReplica failed: %v", err)
+	}
+
+	// Lists read the replica, which hasn't caught up; single papers the
+	// primary
+	upsert("2401.00002")
+	if got := total(); got != 1 {
+		t.Errorf("Expected 1 paper on the replica, got %d", got)
+	}
+	if _, err := db.GetPaperByID("2401.00002"); err != nil {
+		t.Errorf("Expected the new paper on the primary, got %v", err)
+	}
+
+	// A replica without the schema falls back to the primary
+	empty := filepath.Join(t.TempDir(), "empty.db")
+	other, err := sqlx.Open("sqlite3", empty)
+	if err != nil {
+		t.Fatalf("Failed to create empty database: %v", err)
+	}
+	if _, err := other.Exec("CREATE TABLE other (id INTEGER)"); err != nil {
+		t.Fatalf("Failed to create empty database: %v", err)
+	}
+	other.Close()
+	if err := db.OpenReplica(empty); err != nil {
+		t.Fatalf("OpenReplica failed: %v", err)
+	}
+	if got := total(); got != 2 {
+		t.Errorf("Expected 2 papers from the primary, got %d", got)
+	}
+
+	if err := db.OpenReplica(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("Expected an error opening a missing replica")
+	}
+}
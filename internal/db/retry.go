@@ -0,0 +1,43 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Writes that fail because another connection holds the database lock,
+// such as a fetch run from the CLI while the server is up, are tried
+// busyAttempts times in all, waiting busyBackoff before the first retry
+// and twice as long before each following one. SQLite's own busy timeout
+// already waits for most locks; this covers transactions that cannot wait
+// because upgrading them to a writer would deadlock.
+const (
+	busyAttempts = 4
+	busyBackoff  = 100 * time.Millisecond
+)
+
+// IsBusy reports whether err means the database was locked by another
+// connection, so the operation may succeed if tried again later
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// retryBusy runs fn until it succeeds, fails for another reason than a
+// busy database, or busyAttempts are used up
+func retryBusy(fn func() error) error {
+	wait := busyBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == busyAttempts || !IsBusy(err) {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// QueueForReview adds papers to the curation queue as pending. Papers
+// already queued or decided are left alone. It returns the number queued.
+func (db *DB) QueueForReview(paperIDs []string, at time.Time) (int, error) {
+	queued := 0
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		queued = 0
+		for _, id := range paperIDs {
+			result, err := tx.Exec(
+				"INSERT OR IGNORE INTO reviews (paper_id, status, queued_at) VALUES (?, ?, ?)",
+				id, models.ReviewPending, at.UTC(),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to queue paper %s: %w", id, err)
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				queued += int(n)
+			}
+		}
+		return nil
+	})
+	return queued, err
+}
+
+// GetReviewQueue returns up to limit pending papers, first queued first,
+// and the number pending
+func (db *DB) GetReviewQueue(limit int) ([]models.Paper, int, error) {
+	total, err := db.GetPendingReviewCount()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var papers []models.Paper
+	err = db.Select(&papers, `
+		SELECT p.* FROM reviews r
+		JOIN papers p ON p.id = r.paper_id
+		WHERE r.status = ?
+		ORDER BY r.queued_at, p.published_at DESC, p.id DESC
+		LIMIT ?
+	`, models.ReviewPending, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch the review queue: %w", err)
+	}
+	return papers, total, nil
+}
+
+// GetPendingReviewCount returns the number of papers waiting for review
+func (db *DB) GetPendingReviewCount() (int, error) {
+	var count int
+	if err := db.Get(&count, "SELECT COUNT(*) FROM reviews WHERE status = ?", models.ReviewPending); err != nil {
+		return 0, fmt.Errorf("failed to count pending reviews: %w", err)
+	}
+	return count, nil
+}
+
+// ReviewPapers records a curator's decision, approved or rejected, on
+// queued papers. A decision can be changed later, e.g. to take a paper back
+// out of the team feed. Papers that were never queued are skipped. It
+// returns the number of papers updated.
+func (db *DB) ReviewPapers(paperIDs []string, status, curator string, at time.Time) (int, error) {
+	if status != models.ReviewApproved && status != models.ReviewRejected {
+		return 0, fmt.Errorf("invalid review status %q", status)
+	}
+	if len(paperIDs) == 0 {
+		return 0, nil
+	}
+
+	query, args, err := sqlx.In(
+		"UPDATE reviews SET status = ?, curator = ?, decided_at = ? WHERE paper_id IN (?)",
+		status, curator, at.UTC(), paperIDs,
+	)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record review: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetTeamFeed returns up to limit approved papers, most recently approved
+// first
+func (db *DB) GetTeamFeed(limit int) ([]models.ReviewedPaper, error) {
+	var papers []models.ReviewedPaper
+	err := db.Select(&papers, `
+		SELECT p.*, r.* FROM reviews r
+		JOIN papers p ON p.id = r.paper_id
+		WHERE r.status = ?
+		ORDER BY r.decided_at DESC, p.id DESC
+		LIMIT ?
+	`, models.ReviewApproved, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the team feed: %w", err)
+	}
+	return papers, nil
+}
+
+// GetCuratorStats counts each curator's decisions, busiest first
+func (db *DB) GetCuratorStats() ([]models.CuratorStats, error) {
+	var stats []models.CuratorStats
+	err := db.Select(&stats, `
+		SELECT curator,
+			SUM(status = 'approved') AS approved,
+			SUM(status = 'rejected') AS rejected
+		FROM reviews
+		WHERE status != 'pending'
+		GROUP BY curator
+		ORDER BY COUNT(*) DESC, curator
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch curator stats: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestReviews(t *testing.T) {
+	db := setupTestDB(t)
+	for i := 1; i <= 4; i++ {
+		paper := &models.Paper{ID: fmt.Sprintf("2401.%05d", i), Title: "Paper", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	if n, err := db.QueueForReview([]string{"2401.00001", "2401.00002", "2401.00003"}, start); err != nil || n != 3 {
+		t.Fatalf("QueueForReview = %d, %v", n, err)
+	}
+	// Queueing again keeps the original place
+	if n, _ := db.QueueForReview([]string{"2401.00001", "2401.00004"}, start.Add(time.Hour)); n != 1 {
+		t.Errorf("Expected only the new paper to be queued, got %d", n)
+	}
+
+	queue, total, err := db.GetReviewQueue(2)
+	if err != nil {
+		t.Fatalf("GetReviewQueue failed: %v", err)
+	}
+	if total != 4 || len(queue) != 2 {
+		t.Fatalf("Expected 2 of 4 pending papers, got %d of %d", len(queue), total)
+	}
+
+	if _, err := db.ReviewPapers([]string{"2401.00001"}, models.ReviewPending, "alice", start); err == nil {
+		t.Error("Expected pending to be rejected as a decision")
+	}
+	db.ReviewPapers([]string{"2401.00001", "2401.00002"}, models.ReviewApproved, "alice", start.Add(2*time.Hour))
+	db.ReviewPapers([]string{"2401.00003"}, models.ReviewApproved, "bob", start.Add(3*time.Hour))
+	db.ReviewPapers([]string{"2401.00004"}, models.ReviewRejected, "bob", start.Add(3*time.Hour))
+	// A paper that was never queued is skipped
+	if n, _ := db.ReviewPapers([]string{"2401.99999"}, models.ReviewApproved, "bob", start); n != 0 {
+		t.Errorf("Expected an unqueued paper to be skipped, got %d", n)
+	}
+
+	if count, _ := db.GetPendingReviewCount(); count != 0 {
+		t.Errorf("Expected an empty queue, got %d", count)
+	}
+
+	feed, err := db.GetTeamFeed(10)
+	if err != nil {
+		t.Fatalf("GetTeamFeed failed: %v", err)
+	}
+	if len(feed) != 3 || feed[0].ID != "2401.00003" || feed[0].Curator != "bob" || feed[0].DecidedAt == nil {
+		t.Errorf("Expected bob's approval first, got %+v", feed)
+	}
+
+	stats, err := db.GetCuratorStats()
+	if err != nil {
+		t.Fatalf("GetCuratorStats failed: %v", err)
+	}
+	want := []models.CuratorStats{{Curator: "alice", Approved: 2}, {Curator: "bob", Approved: 1, Rejected: 1}}
+	if fmt.Sprint(stats) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, stats)
+	}
+
+	// An approved paper comes back to the team feed when restored from the trash
+	db.TrashPapers([]string{"2401.00002"})
+	if feed, _ := db.GetTeamFeed(10); len(feed) != 2 {
+		t.Errorf("Expected the trashed paper out of the team feed, got %d papers", len(feed))
+	}
+	if err := db.RestorePaper("2401.00002"); err != nil {
+		t.Fatalf("RestorePaper failed: %v", err)
+	}
+	feed, _ = db.GetTeamFeed(10)
+	restored := false
+	for _, p := range feed {
+		restored = restored || p.ID == "2401.00002" && p.Curator == "alice"
+	}
+	if len(feed) != 3 || !restored {
+		t.Errorf("Expected alice's approval restored, got %+v", feed)
+	}
+
+	// Approved papers are curated and survive pruning
+	pruned, _ := db.PruneToQuota(Quota{MaxPapers: 1})
+	if len(pruned) != 1 || pruned[0].ID != "2401.00004" {
+		t.Errorf("Expected only the rejected paper to be pruned, got %+v", pruned)
+	}
+}
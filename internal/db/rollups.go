@@ -0,0 +1,157 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// dayFormat is how rollup tables store days
+const dayFormat = "2006-01-02"
+
+// primaryCategory returns the first category of a paper's comma-separated
+// list, which arXiv lists first. Each paper is counted under its primary
+// category only, so the rollup's daily totals are paper counts.
+func primaryCategory(categories string) string {
+	primary, _, _ := strings.Cut(categories, ",")
+	if primary = strings.TrimSpace(primary); primary != "" {
+		return primary
+	}
+	return "unknown"
+}
+
+// RecordNewPapers adds newly stored papers to the per-category daily
+// rollup for the given day. The fetcher calls it once per run, so the
+// stats page never has to aggregate over the papers table.
+func (db *DB) RecordNewPapers(papers []*models.Paper, day time.Time) error {
+	counts := make(map[string]int)
+	for _, p := range papers {
+		counts[primaryCategory(p.Categories)]++
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		return addCategoryCounts(tx, day.UTC().Format(dayFormat), counts)
+	})
+}
+
+// addCategoryCounts adds counts to a day's rows of the category rollup
+func addCategoryCounts(tx *sqlx.Tx, day string, counts map[string]int) error {
+	for category, n := range counts {
+		if _, err := tx.Exec(`
+			INSERT INTO rollup_category_day (day, category, papers) VALUES (?, ?, ?)
+			ON CONFLICT(day, category) DO UPDATE SET papers = papers + excluded.papers
+		`, day, category, n); err != nil {
+			return fmt.Errorf("failed to update category rollup: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordReads adds n papers marked read to today's reads rollup
+func recordReads(e sqlx.Execer, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	_, err := e.Exec(`
+		INSERT INTO rollup_reads_day (day, reads) VALUES (?, ?)
+		ON CONFLICT(day) DO UPDATE SET reads = reads + excluded.reads
+	`, time.Now().UTC().Format(dayFormat), n)
+	if err != nil {
+		return fmt.Errorf("failed to update reads rollup: %w", err)
+	}
+	return nil
+}
+
+// GetDailyPapers returns the number of papers added on each day since the
+// given day, oldest first. Days without papers are left out.
+func (db *DB) GetDailyPapers(since time.Time) ([]models.DayCount, error) {
+	var counts []models.DayCount
+	err := db.fromReplica().Select(&counts, `
+		SELECT day, SUM(papers) AS count FROM rollup_category_day
+		WHERE day >= ?
+		GROUP BY day
+		ORDER BY day
+	`, since.UTC().Format(dayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily paper counts: %w", err)
+	}
+	return counts, nil
+}
+
+// GetCategoryTotals returns the papers added per category since the given
+// day, busiest first, at most limit categories
+func (db *DB) GetCategoryTotals(since time.Time, limit int) ([]models.CategoryCount, error) {
+	var counts []models.CategoryCount
+	err := db.fromReplica().Select(&counts, `
+		SELECT category, SUM(papers) AS count FROM rollup_category_day
+		WHERE day >= ?
+		GROUP BY category
+		ORDER BY count DESC, category
+		LIMIT ?
+	`, since.UTC().Format(dayFormat), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch category totals: %w", err)
+	}
+	return counts, nil
+}
+
+// GetDailyReads returns the number of papers marked read on each day since
+// the given day, oldest first. Days without reads are left out.
+func (db *DB) GetDailyReads(since time.Time) ([]models.DayCount, error) {
+	var counts []models.DayCount
+	err := db.fromReplica().Select(&counts,
+		"SELECT day, reads AS count FROM rollup_reads_day WHERE day >= ? ORDER BY day",
+		since.UTC().Format(dayFormat),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily reads: %w", err)
+	}
+	return counts, nil
+}
+
+// backfillRollups fills the category rollup from the papers table the
+// first time a database with papers is opened by a version that keeps
+// rollups. Reads have no history to backfill and start from zero.
+func (db *DB) backfillRollups() error {
+	var rollups int
+	if err := db.Get(&rollups, "SELECT COUNT(*) FROM rollup_category_day"); err != nil {
+		return fmt.Errorf("failed to count rollups: %w", err)
+	}
+	if rollups > 0 {
+		return nil
+	}
+
+	var papers []struct {
+		Day        string `db:"day"`
+		Categories string `db:"categories"`
+	}
+	if err := db.Select(&papers, "SELECT substr(created_at, 1, 10) AS day, COALESCE(categories, '') AS categories FROM papers"); err != nil {
+		return fmt.Errorf("failed to read papers for rollups: %w", err)
+	}
+	if len(papers) == 0 {
+		return nil
+	}
+
+	days := make(map[string]map[string]int)
+	for _, p := range papers {
+		if days[p.Day] == nil {
+			days[p.Day] = make(map[string]int)
+		}
+		days[p.Day][primaryCategory(p.Categories)]++
+	}
+
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		for day, counts := range days {
+			if err := addCategoryCounts(tx, day, counts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,25 @@
+package db
+
+// GetPausedJobs returns the scheduler jobs paused from the admin page
+func (db *DB) GetPausedJobs() (map[string]bool, error) {
+	var names []string
+	if err := db.Select(&names, "SELECT name FROM scheduler_jobs WHERE paused"); err != nil {
+		return nil, err
+	}
+
+	paused := make(map[string]bool, len(names))
+	for _, name := range names {
+		paused[name] = true
+	}
+	return paused, nil
+}
+
+// SetJobPaused stores whether a scheduler job is paused
+func (db *DB) SetJobPaused(name string, paused bool) error {
+	query := `
+		INSERT INTO scheduler_jobs (name, paused, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET paused = excluded.paused, updated_at = excluded.updated_at
+	`
+	_, err := db.Exec(query, name, paused)
+	return err
+}
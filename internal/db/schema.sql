@@ -9,7 +9,17 @@ CREATE TABLE IF NOT EXISTS papers (
     updated_at DATETIME,
     pdf_url TEXT,
     arxiv_url TEXT,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    html_url TEXT DEFAULT '',
+    html_checked_at DATETIME,
+    abstract_words INTEGER DEFAULT 0,
+    license TEXT DEFAULT '',
+    content_hash TEXT DEFAULT '',
+    last_seen_at DATETIME,
+    comment TEXT DEFAULT '',
+    links_checked_at DATETIME,
+    doi TEXT DEFAULT '',
+    journal_ref TEXT DEFAULT ''
 );
 
 -- User's library (saved papers)
@@ -17,13 +27,19 @@ CREATE TABLE IF NOT EXISTS library (
     paper_id TEXT PRIMARY KEY,
     is_read BOOLEAN DEFAULT 0,
     saved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    priority INTEGER DEFAULT 0,
+    note TEXT DEFAULT '',
     FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
 );
 
--- Tags
+-- Tags; a name is unique among the shared tags and among each client's
+-- personal tags, so browsers can't take names from each other
 CREATE TABLE IF NOT EXISTS tags (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
-    name TEXT UNIQUE NOT NULL
+    name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    owner TEXT DEFAULT '',
+    UNIQUE (name, owner)
 );
 
 -- Paper-Tag relationship (many-to-many)
@@ -36,8 +52,311 @@ CREATE TABLE IF NOT EXISTS paper_tags (
 );
 
 -- Indexes for performance
-CREATE INDEX IF NOT EXISTS idx_papers_published ON papers(published_at DESC);
+-- Covers the default sort including its ID tiebreak; replaces the index on
+-- published_at alone
+DROP INDEX IF EXISTS idx_papers_published;
+CREATE INDEX IF NOT EXISTS idx_papers_published_id ON papers(published_at DESC, id DESC);
 CREATE INDEX IF NOT EXISTS idx_papers_categories ON papers(categories);
 CREATE INDEX IF NOT EXISTS idx_library_saved ON library(saved_at DESC);
 CREATE INDEX IF NOT EXISTS idx_paper_tags_paper ON paper_tags(paper_id);
 CREATE INDEX IF NOT EXISTS idx_paper_tags_tag ON paper_tags(tag_id);
+
+-- Runtime feature flag overrides (take precedence over config.yaml)
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name TEXT PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Archive-wide result counts arXiv reports for each subscription topic
+CREATE TABLE IF NOT EXISTS archive_stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL,
+    query TEXT NOT NULL,
+    total_results INTEGER NOT NULL,
+    recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_archive_stats_topic ON archive_stats(topic, recorded_at);
+
+-- Reading group assignments (who presents which paper when)
+CREATE TABLE IF NOT EXISTS assignments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id TEXT NOT NULL,
+    assignee TEXT NOT NULL,
+    due_date DATE NOT NULL,
+    presented BOOLEAN DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_assignments_due ON assignments(presented, due_date);
+CREATE INDEX IF NOT EXISTS idx_assignments_paper ON assignments(paper_id);
+
+-- Recycle bin: deleted papers with their library entry, tags and
+-- assignments, kept as a JSON snapshot until restored or purged
+CREATE TABLE IF NOT EXISTS trash (
+    paper_id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    data TEXT NOT NULL,
+    deleted_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trash_deleted ON trash(deleted_at);
+
+-- Subscription keywords each fetched paper matched, offered as suggested tags
+CREATE TABLE IF NOT EXISTS paper_keywords (
+    paper_id TEXT NOT NULL,
+    keyword TEXT NOT NULL,
+    PRIMARY KEY (paper_id, keyword),
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+-- Background jobs paused from the scheduler admin page
+CREATE TABLE IF NOT EXISTS scheduler_jobs (
+    name TEXT PRIMARY KEY,
+    paused BOOLEAN NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- History of fetch runs, for the diagnostics bundle
+CREATE TABLE IF NOT EXISTS fetch_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    stored INTEGER NOT NULL DEFAULT 0,
+    new_papers INTEGER NOT NULL DEFAULT 0,
+    error TEXT DEFAULT ''
+);
+
+-- Typed manual links between papers (supersedes, extends, rebuts, companion)
+CREATE TABLE IF NOT EXISTS relations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_id TEXT NOT NULL,
+    to_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (from_id, to_id, kind),
+    FOREIGN KEY (from_id) REFERENCES papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_relations_to ON relations(to_id);
+
+-- Daily rollups for the stats page (papers by primary category, reads),
+-- kept up to date as papers are fetched and read so the page never
+-- aggregates over the papers table
+CREATE TABLE IF NOT EXISTS rollup_category_day (
+    day TEXT NOT NULL,
+    category TEXT NOT NULL,
+    papers INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, category)
+);
+
+CREATE TABLE IF NOT EXISTS rollup_reads_day (
+    day TEXT PRIMARY KEY,
+    reads INTEGER NOT NULL DEFAULT 0
+);
+
+-- Opt-in usage statistics: requests per day and route, and how many
+-- browsers made them. Browsers are only counted, never stored.
+CREATE TABLE IF NOT EXISTS usage_day (
+    day TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    visitors INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, kind, name)
+);
+
+-- UI preferences (page size, collapsed panels, last filter) per browser,
+-- identified by a cookie
+CREATE TABLE IF NOT EXISTS preferences (
+    client_id TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (client_id, key)
+);
+
+-- Datasets and benchmarks mentioned in each paper's title and abstract,
+-- extracted when the paper is stored
+CREATE TABLE IF NOT EXISTS paper_entities (
+    paper_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    PRIMARY KEY (paper_id, name),
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_paper_entities_name ON paper_entities(name COLLATE NOCASE);
+
+-- MinHash band hashes of each paper's abstract, computed when the paper is
+-- stored, to look up papers with near-identical abstracts
+CREATE TABLE IF NOT EXISTS paper_minhash (
+    paper_id TEXT NOT NULL,
+    band INTEGER NOT NULL,
+    hash INTEGER NOT NULL,
+    PRIMARY KEY (paper_id, band),
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_paper_minhash_hash ON paper_minhash(band, hash);
+
+-- Pairs of papers with near-identical abstracts, e.g. the same preprint
+-- imported from two servers, waiting to be merged. paper_id sorts before
+-- duplicate_id. Dismissed pairs are kept so they aren't suggested again.
+CREATE TABLE IF NOT EXISTS duplicates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id TEXT NOT NULL,
+    duplicate_id TEXT NOT NULL,
+    similarity REAL NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    found_at DATETIME NOT NULL,
+    UNIQUE (paper_id, duplicate_id),
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (duplicate_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_duplicates_duplicate ON duplicates(duplicate_id);
+
+-- Reading plan: the day each queued library paper is planned to be read
+CREATE TABLE IF NOT EXISTS reading_plan (
+    paper_id TEXT PRIMARY KEY,
+    planned_for DATE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_plan_day ON reading_plan(planned_for);
+
+-- Conferences each paper's arXiv comment mentions ("Accepted at NeurIPS
+-- 2024"), detected when the paper is stored
+CREATE TABLE IF NOT EXISTS paper_venues (
+    paper_id TEXT NOT NULL,
+    venue TEXT NOT NULL,
+    year INTEGER NOT NULL,
+    PRIMARY KEY (paper_id, venue),
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+-- Backfills harvest a category's history between two days, one day at a
+-- time, checkpointed after every page so they can resume after a stop
+CREATE TABLE IF NOT EXISTS backfills (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category TEXT NOT NULL,
+    from_day DATE NOT NULL,
+    to_day DATE NOT NULL,
+    next_day DATE NOT NULL,
+    next_start INTEGER NOT NULL DEFAULT 0,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    stored INTEGER NOT NULL DEFAULT 0,
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    UNIQUE (category, from_day, to_day)
+);
+
+-- Named shelves of papers besides the library; each entry keeps its own
+-- read state and priority
+CREATE TABLE IF NOT EXISTS shelves (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    description TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shelf_papers (
+    shelf_id INTEGER NOT NULL,
+    paper_id TEXT NOT NULL,
+    is_read BOOLEAN DEFAULT 0,
+    priority INTEGER DEFAULT 0,
+    added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (shelf_id, paper_id),
+    FOREIGN KEY (shelf_id) REFERENCES shelves(id) ON DELETE CASCADE,
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_shelf_papers_paper ON shelf_papers(paper_id);
+
+-- Paper links that stopped resolving, found by the link checker
+CREATE TABLE IF NOT EXISTS broken_links (
+    paper_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    url TEXT NOT NULL,
+    status INTEGER DEFAULT 0,
+    first_failed_at DATETIME NOT NULL,
+    checked_at DATETIME NOT NULL,
+    PRIMARY KEY (paper_id, url),
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+-- Notification channels announcing papers added to a shared tag or a shelf
+CREATE TABLE IF NOT EXISTS collection_subscriptions (
+    kind TEXT NOT NULL,
+    collection_id INTEGER NOT NULL,
+    channel TEXT NOT NULL,
+    PRIMARY KEY (kind, collection_id, channel)
+);
+
+-- Notifications sent about each paper on each channel, so a paper is never
+-- announced twice on the same channel. Kept when the paper is purged.
+CREATE TABLE IF NOT EXISTS deliveries (
+    paper_id TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT DEFAULT '',
+    digest BOOLEAN DEFAULT 0,
+    attempted_at DATETIME NOT NULL,
+    PRIMARY KEY (paper_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_attempted ON deliveries(attempted_at);
+
+-- Saved searches of the browse and library pages
+CREATE TABLE IF NOT EXISTS saved_views (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    page TEXT NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    visited_at DATETIME
+);
+
+-- The papers a saved view returned when it was last opened
+CREATE TABLE IF NOT EXISTS view_papers (
+    view_id INTEGER NOT NULL,
+    paper_id TEXT NOT NULL,
+    PRIMARY KEY (view_id, paper_id),
+    FOREIGN KEY (view_id) REFERENCES saved_views(id) ON DELETE CASCADE,
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+-- Library papers being marked as read, in order, for the reading logs
+-- they are pushed to. Kept when the paper is purged.
+CREATE TABLE IF NOT EXISTS read_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id TEXT NOT NULL,
+    read_at DATETIME NOT NULL
+);
+
+-- The last read event pushed to each reading log
+CREATE TABLE IF NOT EXISTS readlog_cursors (
+    sink TEXT PRIMARY KEY,
+    event_id INTEGER NOT NULL
+);
+
+-- The curation queue: fetched papers waiting for a curator, and the
+-- decisions made on them. Approved papers make up the team feed.
+CREATE TABLE IF NOT EXISTS reviews (
+    paper_id TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending',
+    curator TEXT NOT NULL DEFAULT '',
+    queued_at DATETIME NOT NULL,
+    decided_at DATETIME,
+    FOREIGN KEY (paper_id) REFERENCES papers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews(status, queued_at);
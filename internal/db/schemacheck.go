@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// schemaModels lists each table with the model scanned from it. Joined
+// names the model's db fields that are filled from other tables.
+var schemaModels = []struct {
+	table  string
+	model  interface{}
+	joined []string
+}{
+	{"papers", models.Paper{}, []string{"in_library", "is_read", "priority", "note"}},
+	{"library", models.LibraryEntry{}, nil},
+	{"tags", models.Tag{}, []string{"paper_count"}},
+	{"paper_tags", models.PaperTag{}, nil},
+	{"assignments", models.Assignment{}, []string{"title"}},
+	{"archive_stats", models.ArchiveStat{}, nil},
+	{"trash", models.TrashedPaper{}, nil},
+	{"fetch_runs", models.FetchRun{}, nil},
+	{"relations", models.Relation{}, []string{"title"}},
+	{"paper_entities", models.Entity{}, []string{"papers"}},
+	{"reading_plan", models.PlannedRead{}, []string{"title", "arxiv_url", "is_read"}},
+	{"paper_venues", models.VenueMention{}, nil},
+	{"backfills", models.Backfill{}, nil},
+	{"shelves", models.Shelf{}, []string{"papers", "unread"}},
+	{"shelf_papers", models.ShelfEntry{}, []string{"shelf"}},
+	{"broken_links", models.BrokenLink{}, []string{"title"}},
+	{"deliveries", models.Delivery{}, []string{"title"}},
+	{"saved_views", models.SavedView{}, nil},
+	{"read_events", models.ReadEvent{}, []string{"title", "authors", "arxiv_url", "note"}},
+	{"reviews", models.Review{}, nil},
+	{"usage_day", models.UsageCount{}, nil},
+}
+
+// CheckSchema verifies that every column the models expect exists in the
+// live schema with a compatible type. It reports all problems at once, so a
+// partially upgraded database fails at startup instead of with scan errors
+// on some later request.
+func (db *DB) CheckSchema() error {
+	var problems []string
+	for _, m := range schemaModels {
+		columns, err := db.columnTypes(m.table)
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", m.table, err)
+		}
+		if len(columns) == 0 {
+			problems = append(problems, fmt.Sprintf("table %s is missing", m.table))
+			continue
+		}
+		problems = append(problems, checkModel(m.table, columns, m.model, m.joined)...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("database schema does not match this version (run migrate, or restore a matching binary):\n  %s",
+			strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// columnTypes returns the declared type of each column of a table
+func (db *DB) columnTypes(table string) (map[string]string, error) {
+	var rows []struct {
+		Name string `db:"name"`
+		Type string `db:"type"`
+	}
+	if err := db.Select(&rows, "SELECT name, type FROM pragma_table_info(?)", table); err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]string, len(rows))
+	for _, r := range rows {
+		columns[r.Name] = strings.ToUpper(r.Type)
+	}
+	return columns, nil
+}
+
+// checkModel compares a model's db-tagged fields with a table's columns
+func checkModel(table string, columns map[string]string, model interface{}, joined []string) []string {
+	skip := make(map[string]bool, len(joined))
+	for _, name := range joined {
+		skip[name] = true
+	}
+
+	var problems []string
+	t := reflect.TypeOf(model)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		column := field.Tag.Get("db")
+		if column == "" || column == "-" || skip[column] {
+			continue
+		}
+
+		declared, ok := columns[column]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s is missing (%s.%s)", table, column, t.Name(), field.Name))
+			continue
+		}
+		if !compatible(field.Type, declared) {
+			problems = append(problems, fmt.Sprintf("%s.%s is %s, which cannot hold %s.%s (%s)",
+				table, column, declared, t.Name(), field.Name, field.Type))
+		}
+	}
+	return problems
+}
+
+// timeType is the reflected type of time.Time
+var timeType = reflect.TypeOf(time.Time{})
+
+// compatible reports whether a column with SQLite's declared type can be
+// scanned into a field of type t
+func compatible(t reflect.Type, declared string) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	hasAny := func(names ...string) bool {
+		for _, name := range names {
+			if strings.Contains(declared, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case t == timeType:
+		return hasAny("DATE", "TIME")
+	case t.Kind() == reflect.String:
+		return hasAny("TEXT", "CHAR", "CLOB")
+	case t.Kind() == reflect.Bool:
+		return hasAny("BOOL", "INT")
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return hasAny("INT", "BOOL")
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return hasAny("REAL", "FLOA", "DOUB", "NUM", "DEC")
+	}
+	return true
+}
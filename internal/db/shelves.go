@@ -0,0 +1,168 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrShelfExists is returned when creating a shelf with a taken name
+var ErrShelfExists = errors.New("a shelf with this name already exists")
+
+// shelfWithCounts selects shelves with their paper and unread counts
+const shelfWithCounts = `
+	SELECT s.id, s.name, s.description, s.created_at,
+		(SELECT COUNT(*) FROM shelf_papers sp WHERE sp.shelf_id = s.id) AS papers,
+		(SELECT COUNT(*) FROM shelf_papers sp WHERE sp.shelf_id = s.id AND sp.is_read = 0) AS unread
+	FROM shelves s
+`
+
+// CreateShelf creates an empty shelf
+func (db *DB) CreateShelf(name, description string) (*models.Shelf, error) {
+	result, err := db.Exec("INSERT OR IGNORE INTO shelves (name, description) VALUES (?, ?)", name, description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shelf: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, ErrShelfExists
+	}
+	return db.GetShelf(name)
+}
+
+// GetShelf returns a shelf by name with its counts
+func (db *DB) GetShelf(name string) (*models.Shelf, error) {
+	var shelf models.Shelf
+	if err := db.Get(&shelf, shelfWithCounts+" WHERE s.name = ?", name); err != nil {
+		return nil, err
+	}
+	return &shelf, nil
+}
+
+// GetShelves returns every shelf with its counts, ordered by name
+func (db *DB) GetShelves() ([]models.Shelf, error) {
+	var shelves []models.Shelf
+	if err := db.Select(&shelves, shelfWithCounts+" ORDER BY s.name COLLATE NOCASE"); err != nil {
+		return nil, fmt.Errorf("failed to fetch shelves: %w", err)
+	}
+	return shelves, nil
+}
+
+// DeleteShelf deletes a shelf and the notifications of papers added to
+// it. Its papers stay in the database.
+func (db *DB) DeleteShelf(id int) error {
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM shelf_papers WHERE shelf_id = ?", id); err != nil {
+			return fmt.Errorf("failed to empty shelf: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM collection_subscriptions WHERE kind = ? AND collection_id = ?", models.CollectionShelf, id); err != nil {
+			return fmt.Errorf("failed to unsubscribe from shelf: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM shelves WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete shelf: %w", err)
+		}
+		return nil
+	})
+}
+
+// AddToShelf puts a paper on a shelf, unread. A paper already on the shelf
+// keeps its state.
+func (db *DB) AddToShelf(shelfID int, paperID string) error {
+	result, err := db.Exec("INSERT OR IGNORE INTO shelf_papers (shelf_id, paper_id) VALUES (?, ?)", shelfID, paperID)
+	if err != nil {
+		return fmt.Errorf("failed to add paper to shelf: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		db.changed(Change{Collected: []Collected{{Kind: models.CollectionShelf, ID: shelfID, Papers: []string{paperID}}}})
+	}
+	return nil
+}
+
+// RemoveFromShelf takes a paper off a shelf
+func (db *DB) RemoveFromShelf(shelfID int, paperID string) error {
+	_, err := db.Exec("DELETE FROM shelf_papers WHERE shelf_id = ? AND paper_id = ?", shelfID, paperID)
+	if err != nil {
+		return fmt.Errorf("failed to remove paper from shelf: %w", err)
+	}
+	return nil
+}
+
+// UpdateShelfEntry sets the read state and priority of a paper on a shelf
+func (db *DB) UpdateShelfEntry(shelfID int, paperID string, isRead bool, priority int) error {
+	_, err := db.Exec(
+		"UPDATE shelf_papers SET is_read = ?, priority = ? WHERE shelf_id = ? AND paper_id = ?",
+		isRead, priority, shelfID, paperID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update shelf entry: %w", err)
+	}
+	return nil
+}
+
+// GetShelfEntry returns a paper's entry on a shelf
+func (db *DB) GetShelfEntry(shelfID int, paperID string) (*models.ShelfEntry, error) {
+	var entry models.ShelfEntry
+	err := db.Get(&entry, `
+		SELECT sp.shelf_id, sp.paper_id, sp.is_read, sp.priority, sp.added_at, s.name AS shelf
+		FROM shelf_papers sp
+		JOIN shelves s ON s.id = sp.shelf_id
+		WHERE sp.shelf_id = ? AND sp.paper_id = ?
+	`, shelfID, paperID)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetPaperShelves returns the shelves a paper is on, by shelf name
+func (db *DB) GetPaperShelves(paperID string) ([]models.ShelfEntry, error) {
+	var entries []models.ShelfEntry
+	err := db.Select(&entries, `
+		SELECT sp.shelf_id, sp.paper_id, sp.is_read, sp.priority, sp.added_at, s.name AS shelf
+		FROM shelf_papers sp
+		JOIN shelves s ON s.id = sp.shelf_id
+		WHERE sp.paper_id = ?
+		ORDER BY s.name COLLATE NOCASE
+	`, paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch paper shelves: %w", err)
+	}
+	return entries, nil
+}
+
+// GetShelfPapers returns the papers on a shelf with the shelf's read state
+// and priority. Unread papers come first, then by priority or, with sortBy
+// "added", most recently added first.
+func (db *DB) GetShelfPapers(shelfID int, sortBy string) ([]models.Paper, error) {
+	order := "sp.is_read, sp.priority DESC, sp.added_at DESC"
+	if sortBy == "added" {
+		order = "sp.added_at DESC"
+	}
+
+	var papers []models.Paper
+	err := db.Select(&papers, `
+		SELECT p.id, p.title, p.abstract, p.authors, p.categories,
+			p.published_at, p.updated_at, p.pdf_url, p.arxiv_url, p.html_url, p.abstract_words, p.license,
+			l.paper_id IS NOT NULL AS in_library,
+			sp.is_read,
+			sp.priority,
+			COALESCE(l.note, '') AS note
+		FROM shelf_papers sp
+		JOIN papers p ON p.id = sp.paper_id
+		LEFT JOIN library l ON l.paper_id = sp.paper_id
+		WHERE sp.shelf_id = ?
+		ORDER BY `+order, shelfID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch shelf papers: %w", err)
+	}
+
+	for i := range papers {
+		tags, err := db.GetPaperTags(papers[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tags for paper %s: %w", papers[i].ID, err)
+		}
+		papers[i].Tags = tags
+	}
+	return papers, nil
+}
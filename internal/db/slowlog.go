@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// slowLog records queries that take longer than a threshold
+type slowLog struct {
+	threshold time.Duration
+	logger    *log.Logger
+}
+
+// SetSlowQueryLog enables logging of queries slower than threshold to w,
+// together with their arguments and EXPLAIN QUERY PLAN output.
+// A zero threshold disables the log.
+func (db *DB) SetSlowQueryLog(threshold time.Duration, w io.Writer) {
+	if threshold <= 0 {
+		db.slow.Store(nil)
+		return
+	}
+	db.slow.Store(&slowLog{
+		threshold: threshold,
+		logger:    log.New(w, "", log.LstdFlags),
+	})
+}
+
+// Get runs a query returning a single row on the read pool, recording it
+// if slow and tracing it
+func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
+	trace := db.startQuery(query)
+	start := time.Now()
+	err := db.reads().Get(dest, query, args...)
+	err = db.retryOnPrimary(err, func(primary *sqlx.DB) error {
+		return primary.Get(dest, query, args...)
+	})
+	db.observe(query, args, time.Since(start))
+	trace.end(err)
+	return err
+}
+
+// Select runs a query returning rows on the read pool, recording it if
+// slow and tracing it
+func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
+	trace := db.startQuery(query)
+	start := time.Now()
+	err := db.reads().Select(dest, query, args...)
+	err = db.retryOnPrimary(err, func(primary *sqlx.DB) error {
+		return primary.Select(dest, query, args...)
+	})
+	db.observe(query, args, time.Since(start))
+	trace.end(err)
+	return err
+}
+
+// Exec runs a statement, recording it if slow and tracing it. It is
+// retried while the database is busy.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	trace := db.startQuery(query)
+	var result sql.Result
+	err := retryBusy(func() error {
+		start := time.Now()
+		var err error
+		result, err = db.DB.Exec(query, args...)
+		db.observe(query, args, time.Since(start))
+		return err
+	})
+	trace.end(err)
+	return result, err
+}
+
+// observe logs a query that exceeded the slow query threshold
+func (db *DB) observe(query string, args []interface{}, elapsed time.Duration) {
+	slow := db.slow.Load()
+	if slow == nil || elapsed < slow.threshold {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Slow query (%s): %s\n", elapsed.Round(time.Millisecond), strings.Join(strings.Fields(query), " "))
+	fmt.Fprintf(&b, "  args: %v\n", args)
+
+	plan, err := db.explain(query, args)
+	if err != nil {
+		fmt.Fprintf(&b, "  plan: unavailable (%v)\n", err)
+	} else {
+		b.WriteString("  plan:\n")
+		for _, line := range plan {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+
+	slow.logger.Print(b.String())
+}
+
+// explain returns the EXPLAIN QUERY PLAN output for a query, indented to
+// show nesting. EXPLAIN does not execute the statement, so this is safe for
+// writes too.
+func (db *DB) explain(query string, args []interface{}) ([]string, error) {
+	rows, err := db.DB.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depth := map[int]int{}
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		depth[id] = depth[parent] + 1
+		plan = append(plan, strings.Repeat("  ", depth[id]-1)+detail)
+	}
+	return plan, rows.Err()
+}
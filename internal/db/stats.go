@@ -0,0 +1,36 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// RecordArchiveStat stores the total result count arXiv reported for a topic
+func (db *DB) RecordArchiveStat(topic, query string, total int) error {
+	_, err := db.Exec(
+		"INSERT INTO archive_stats (topic, query, total_results) VALUES (?, ?, ?)",
+		topic, query, total,
+	)
+	return err
+}
+
+// GetArchiveStats returns up to limit of the most recent snapshots per topic,
+// ordered by topic and then oldest first
+func (db *DB) GetArchiveStats(limit int) ([]models.ArchiveStat, error) {
+	query := `
+		SELECT topic, query, total_results, recorded_at
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY topic ORDER BY recorded_at DESC, id DESC) AS n
+			FROM archive_stats
+		)
+		WHERE n <= ?
+		ORDER BY topic, recorded_at, id
+	`
+
+	var stats []models.ArchiveStat
+	if err := db.fromReplica().Select(&stats, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to fetch archive stats: %w", err)
+	}
+	return stats, nil
+}
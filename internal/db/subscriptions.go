@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// collectionNames selects the name of a collection of each kind by ID.
+// Personal tags can't be subscribed to, as everyone shares the channels.
+var collectionNames = map[string]string{
+	models.CollectionTag:   "SELECT name FROM tags WHERE id = ? AND owner = ''",
+	models.CollectionShelf: "SELECT name FROM shelves WHERE id = ?",
+}
+
+// GetCollectionSubscription returns the channels announcing papers added
+// to a shared tag or a shelf, with its name. It returns sql.ErrNoRows if
+// there is no such shared tag or shelf.
+func (db *DB) GetCollectionSubscription(kind string, id int) (*models.CollectionSubscription, error) {
+	query, ok := collectionNames[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown collection kind %q", kind)
+	}
+
+	s := &models.CollectionSubscription{Kind: kind, ID: id}
+	if err := db.Get(&s.Name, query, id); err != nil {
+		return nil, err
+	}
+	err := db.Select(&s.Channels, "SELECT channel FROM collection_subscriptions WHERE kind = ? AND collection_id = ? ORDER BY channel", kind, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subscriptions: %w", err)
+	}
+	return s, nil
+}
+
+// SetCollectionSubscription replaces the channels announcing papers added
+// to a tag or shelf
+func (db *DB) SetCollectionSubscription(kind string, id int, channels []string) error {
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM collection_subscriptions WHERE kind = ? AND collection_id = ?", kind, id); err != nil {
+			return fmt.Errorf("failed to clear subscriptions: %w", err)
+		}
+		for _, channel := range channels {
+			if _, err := tx.Exec(
+				"INSERT OR IGNORE INTO collection_subscriptions (kind, collection_id, channel) VALUES (?, ?, ?)",
+				kind, id, channel,
+			); err != nil {
+				return fmt.Errorf("failed to subscribe %s: %w", channel, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestCollectionSubscriptions(t *testing.T) {
+	db := setupTestDB(t)
+	paper := &models.Paper{ID: "2401.00001", Title: "Paper", PublishedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+
+	var collected []Collected
+	db.OnChange(func(c Change) { collected = append(collected, c.Collected...) })
+
+	tagID, _ := db.CreateTag("must-read")
+	if err := db.SetCollectionSubscription(models.CollectionTag, tagID, []string{"slack", "email"}); err != nil {
+		t.Fatalf("SetCollectionSubscription failed: %v", err)
+	}
+	sub, err := db.GetCollectionSubscription(models.CollectionTag, tagID)
+	if err != nil {
+		t.Fatalf("GetCollectionSubscription failed: %v", err)
+	}
+	if sub.Name != "must-read" || len(sub.Channels) != 2 || sub.Channels[0] != "email" {
+		t.Errorf("Expected both channels on must-read, got %+v", sub)
+	}
+
+	// Only a paper new to the tag is reported
+	db.TagPaper(paper.ID, tagID)
+	db.TagPaper(paper.ID, tagID)
+	if len(collected) != 1 || collected[0].Kind != models.CollectionTag || collected[0].ID != tagID {
+		t.Fatalf("Expected one addition to the tag, got %+v", collected)
+	}
+
+	shelf, err := db.CreateShelf("Reading group", "")
+	if err != nil {
+		t.Fatalf("CreateShelf failed: %v", err)
+	}
+	db.AddToShelf(shelf.ID, paper.ID)
+	db.AddToShelf(shelf.ID, paper.ID)
+	if len(collected) != 2 || collected[1].Kind != models.CollectionShelf || collected[1].Papers[0] != paper.ID {
+		t.Fatalf("Expected one addition to the shelf, got %+v", collected)
+	}
+
+	if err := db.SaveWithTags(paper.ID, 0, "", []string{"must-read", "ideas"}, false); err != nil {
+		t.Fatalf("SaveWithTags failed: %v", err)
+	}
+	if len(collected) != 3 || collected[2].ID == tagID {
+		t.Errorf("Expected only the addition to the new tag, got %+v", collected)
+	}
+
+	// Clearing the channels unsubscribes
+	db.SetCollectionSubscription(models.CollectionTag, tagID, nil)
+	if sub, _ := db.GetCollectionSubscription(models.CollectionTag, tagID); len(sub.Channels) != 0 {
+		t.Errorf("Expected no channels left, got %v", sub.Channels)
+	}
+
+	// Personal tags can't be subscribed to
+	personal := db.ForClient("alice")
+	if err := personal.SaveWithTags(paper.ID, 0, "", []string{"mine"}, true); err != nil {
+		t.Fatalf("SaveWithTags failed: %v", err)
+	}
+	tag, _ := personal.GetTag("mine")
+	if _, err := db.GetCollectionSubscription(models.CollectionTag, tag.ID); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for a personal tag, got %v", err)
+	}
+
+	// Deleting a shelf drops its subscriptions
+	db.SetCollectionSubscription(models.CollectionShelf, shelf.ID, []string{"slack"})
+	if err := db.DeleteShelf(shelf.ID); err != nil {
+		t.Fatalf("DeleteShelf failed: %v", err)
+	}
+	var left int
+	db.Get(&left, "SELECT COUNT(*) FROM collection_subscriptions WHERE kind = ?", models.CollectionShelf)
+	if left != 0 {
+		t.Errorf("Expected the shelf's subscriptions to be deleted, got %d", left)
+	}
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrPersonalTag is returned when sharing a tag that isn't one of the
+// client's personal tags
+var ErrPersonalTag = errors.New("this tag is someone else's personal tag")
+
+// ErrSharedTagExists is returned when sharing a personal tag whose name a
+// shared tag already has
+var ErrSharedTagExists = errors.New("a shared tag with this name already exists")
+
+// visibleTag restricts tags t to the shared ones and the personal tags of
+// a client, passed as its argument. Without a client (e.g. in the JSON API,
+// hooks and published sites) only shared tags are visible.
+const visibleTag = "(t.owner = '' OR t.owner = ?)"
+
+// tagWithCount selects tags with the number of papers carrying each
+const tagWithCount = `
+	SELECT t.id, t.name, t.description, t.owner,
+		(SELECT COUNT(*) FROM paper_tags pt WHERE pt.tag_id = t.id) AS paper_count
+	FROM tags t
+`
+
+// ForClient returns a handle on the same database that shows the personal
+// tags of the browser with the given client ID alongside the shared ones,
+// and creates personal tags for it
+func (db *DB) ForClient(id string) *DB {
+	c := *db
+	c.client = id
+	return &c
+}
+
+// GetTag returns a visible tag by name with its paper count, the client's
+// personal tag if it has one of that name
+func (db *DB) GetTag(name string) (*models.Tag, error) {
+	var tag models.Tag
+	if err := db.Get(&tag, tagWithCount+" WHERE t.name = ? AND "+visibleTag+" ORDER BY t.owner = '' LIMIT 1", name, db.client); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetTagCloud returns every visible tag with its paper count, ordered by name
+func (db *DB) GetTagCloud() ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := db.Select(&tags, tagWithCount+" WHERE "+visibleTag+" ORDER BY t.name", db.client); err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	return tags, nil
+}
+
+// ShareTag makes one of the client's personal tags visible to everyone.
+// Shared tags can't be made personal again, since others may be using them.
+// It fails with ErrSharedTagExists if there is a shared tag of that name.
+func (db *DB) ShareTag(id int) error {
+	defer db.changed(Change{Tags: true})
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		var name string
+		err := tx.Get(&name, "SELECT name FROM tags WHERE id = ? AND owner = ? AND owner != ''", id, db.client)
+		if err == sql.ErrNoRows {
+			return ErrPersonalTag
+		}
+		if err != nil {
+			return err
+		}
+
+		var shared int
+		if err := tx.Get(&shared, "SELECT COUNT(*) FROM tags WHERE name = ? AND owner = ''", name); err != nil {
+			return err
+		}
+		if shared > 0 {
+			return ErrSharedTagExists
+		}
+
+		_, err = tx.Exec("UPDATE tags SET owner = '' WHERE id = ?", id)
+		return err
+	})
+}
+
+// SetTagDescription updates a tag's description
+func (db *DB) SetTagDescription(id int, description string) error {
+	defer db.changed(Change{Tags: true})
+	_, err := db.Exec("UPDATE tags SET description = ? WHERE id = ?", description, id)
+	return err
+}
+
+// GetTagMonthlyCounts returns how many of a tag's papers were published in
+// each month, oldest first. Months without papers are omitted.
+func (db *DB) GetTagMonthlyCounts(tagID int) ([]models.MonthCount, error) {
+	query := `
+		SELECT substr(p.published_at, 1, 7) AS month, COUNT(*) AS count
+		FROM papers p
+		JOIN paper_tags pt ON pt.paper_id = p.id
+		WHERE pt.tag_id = ?
+		GROUP BY month
+		ORDER BY month
+	`
+
+	var counts []models.MonthCount
+	if err := db.Select(&counts, query, tagID); err != nil {
+		return nil, fmt.Errorf("failed to count tag papers: %w", err)
+	}
+	return counts, nil
+}
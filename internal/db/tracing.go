@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+)
+
+// maxTracedQuery is the longest query text attached to a span
+const maxTracedQuery = 2000
+
+// WithContext returns a handle on the same database whose queries are
+// traced as children of the span in ctx, e.g. the request being served.
+// Queries through other handles are only counted in the metrics.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	c := *db
+	c.ctx = ctx
+	return &c
+}
+
+// queryTrace times one query for telemetry
+type queryTrace struct {
+	span      *telemetry.Span
+	operation string
+	function  string
+	start     time.Time
+}
+
+// startQuery starts timing a query, named after the function that called
+// Get, Select or Exec (e.g. GetShelfPapers) so a trace shows which method
+// a query belongs to. It must be called directly from those methods, and
+// returns nil while telemetry is off.
+func (db *DB) startQuery(query string) *queryTrace {
+	if !telemetry.Enabled() {
+		return nil
+	}
+
+	t := &queryTrace{operation: queryOperation(query), function: "unknown", start: time.Now()}
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			t.function = shortFuncName(fn.Name())
+		}
+	}
+
+	if db.ctx != nil {
+		text := strings.Join(strings.Fields(query), " ")
+		if len(text) > maxTracedQuery {
+			text = text[:maxTracedQuery] + "..."
+		}
+		t.span = telemetry.Child(db.ctx, t.function, telemetry.KindClient,
+			telemetry.String("db.system", "sqlite"),
+			telemetry.String("db.operation.name", t.operation),
+			telemetry.String("db.query.text", text),
+		)
+	}
+	return t
+}
+
+// end finishes the span and records the query's duration
+func (t *queryTrace) end(err error) {
+	if t == nil {
+		return
+	}
+	if err != sql.ErrNoRows {
+		t.span.SetError(err)
+	}
+	t.span.End()
+	telemetry.Record("db.client.operation.duration", time.Since(t.start),
+		telemetry.String("db.operation.name", t.operation),
+		telemetry.String("code.function", t.function),
+	)
+}
+
+// queryOperation returns the statement keyword of a query, e.g. SELECT
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// shortFuncName trims the package path and receiver from a function name,
+// turning ".../internal/db.(*DB).GetPapers" into "GetPapers"
+func shortFuncName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	if i := strings.Index(name, ")."); i >= 0 {
+		return name[i+2:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,368 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// trashSnapshot is everything removed along with a paper, so a restore
+// brings back the library entry, tags, assignments, matched keywords,
+// relations, reading plan, venues, shelf entries and review too
+type trashSnapshot struct {
+	Paper       models.Paper
+	Library     *models.LibraryEntry
+	Tags        []trashedTag
+	Assignments []models.Assignment
+	Keywords    []string
+	Relations   []models.Relation
+	Plan        *models.PlannedRead   `json:",omitempty"`
+	Venues      []models.VenueMention `json:",omitempty"`
+	Shelves     []models.ShelfEntry   `json:",omitempty"`
+	Review      *models.Review        `json:",omitempty"`
+}
+
+// trashedTag is a tag of a trashed paper with its owner, "" for a shared
+// tag
+type trashedTag struct {
+	Name  string `db:"name"`
+	Owner string `db:"owner"`
+}
+
+// UnmarshalJSON also reads the plain tag names of papers trashed before
+// owners were kept, as shared tags
+func (t *trashedTag) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*t = trashedTag{}
+		return json.Unmarshal(data, &t.Name)
+	}
+	type plain trashedTag
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+// TrashPapers moves papers to the recycle bin, removing them and their
+// library entries, tags and assignments. It returns the number trashed;
+// unknown IDs are skipped.
+func (db *DB) TrashPapers(ids []string) (int, error) {
+	defer db.invalidate(ids...)
+	now := time.Now().UTC()
+	count := 0
+
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		count = 0
+		for _, id := range ids {
+			trashed, err := trashPaper(tx, id, now)
+			if err != nil {
+				return err
+			}
+			if trashed {
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// trashPaper snapshots a paper into the recycle bin and deletes it. It
+// reports false for an unknown ID.
+func trashPaper(tx *sqlx.Tx, id string, now time.Time) (bool, error) {
+	snapshot, err := loadSnapshot(tx, id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load paper %s: %w", id, err)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(
+		"INSERT OR REPLACE INTO trash (paper_id, title, data, deleted_at) VALUES (?, ?, ?, ?)",
+		id, snapshot.Paper.Title, string(data), now,
+	); err != nil {
+		return false, fmt.Errorf("failed to trash paper %s: %w", id, err)
+	}
+
+	if err := deletePaper(tx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// paperTables are the tables whose rows belong to a paper through their
+// paper_id column. Relations and duplicates refer to papers on both ends
+// and are handled apart.
+var paperTables = []string{"paper_tags", "library", "assignments", "paper_keywords", "paper_entities", "paper_minhash", "reading_plan", "paper_venues", "shelf_papers", "broken_links", "view_papers", "reviews"}
+
+// deletePaper deletes a paper and every row that refers to it
+func deletePaper(tx *sqlx.Tx, id string) error {
+	for _, table := range paperTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE paper_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete paper %s from %s: %w", id, table, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM relations WHERE from_id = ? OR to_id = ?", id, id); err != nil {
+		return fmt.Errorf("failed to delete relations of paper %s: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM duplicates WHERE paper_id = ? OR duplicate_id = ?", id, id); err != nil {
+		return fmt.Errorf("failed to delete duplicates of paper %s: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM papers WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete paper %s: %w", id, err)
+	}
+	return nil
+}
+
+// loadSnapshot reads a paper and its related rows
+func loadSnapshot(tx *sqlx.Tx, id string) (*trashSnapshot, error) {
+	var s trashSnapshot
+	if err := tx.Get(&s.Paper, "SELECT * FROM papers WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+
+	var entry models.LibraryEntry
+	err := tx.Get(&entry, "SELECT paper_id, is_read, saved_at, priority, note FROM library WHERE paper_id = ?", id)
+	switch {
+	case err == nil:
+		s.Library = &entry
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+
+	if err := tx.Select(&s.Tags, `
+		SELECT t.name, t.owner FROM tags t
+		JOIN paper_tags pt ON pt.tag_id = t.id
+		WHERE pt.paper_id = ?
+		ORDER BY t.name
+	`, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Select(&s.Assignments,
+		"SELECT id, paper_id, assignee, due_date, presented, created_at FROM assignments WHERE paper_id = ?", id,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Select(&s.Keywords, "SELECT keyword FROM paper_keywords WHERE paper_id = ?", id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Select(&s.Relations,
+		"SELECT id, from_id, to_id, kind, created_at FROM relations WHERE from_id = ? OR to_id = ?", id, id,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Select(&s.Venues, "SELECT venue, year FROM paper_venues WHERE paper_id = ?", id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Select(&s.Shelves, `
+		SELECT sp.shelf_id, sp.paper_id, sp.is_read, sp.priority, sp.added_at, sh.name AS shelf
+		FROM shelf_papers sp
+		JOIN shelves sh ON sh.id = sp.shelf_id
+		WHERE sp.paper_id = ?
+	`, id); err != nil {
+		return nil, err
+	}
+
+	var plan models.PlannedRead
+	err = tx.Get(&plan, "SELECT paper_id, planned_for, created_at FROM reading_plan WHERE paper_id = ?", id)
+	if err == nil {
+		s.Plan = &plan
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var review models.Review
+	err = tx.Get(&review, "SELECT paper_id, status, curator, queued_at, decided_at FROM reviews WHERE paper_id = ?", id)
+	if err == nil {
+		s.Review = &review
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetTrash returns the papers in the recycle bin, most recently deleted first
+func (db *DB) GetTrash() ([]models.TrashedPaper, error) {
+	var papers []models.TrashedPaper
+	err := db.Select(&papers, "SELECT paper_id, title, deleted_at FROM trash ORDER BY deleted_at DESC, paper_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch trash: %w", err)
+	}
+	return papers, nil
+}
+
+// IsTrashed reports whether a paper is in the recycle bin
+func (db *DB) IsTrashed(id string) (bool, error) {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM trash WHERE paper_id = ?", id)
+	return count > 0, err
+}
+
+// RestorePaper moves a paper out of the recycle bin, recreating its library
+// entry, tags (creating any since deleted, under their owners),
+// assignments, relations and
+// review, so an approved paper returns to the team feed
+func (db *DB) RestorePaper(id string) error {
+	defer db.invalidate(id)
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		var data string
+		if err := tx.Get(&data, "SELECT data FROM trash WHERE paper_id = ?", id); err != nil {
+			return err
+		}
+
+		var s trashSnapshot
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return fmt.Errorf("failed to decode trashed paper %s: %w", id, err)
+		}
+
+		p := s.Paper
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO papers (id, title, abstract, authors, categories, published_at, updated_at,
+				pdf_url, arxiv_url, created_at, html_url, html_checked_at, abstract_words, license, content_hash, last_seen_at, comment,
+				doi, journal_ref)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, p.ID, p.Title, p.Abstract, p.Authors, p.Categories, p.PublishedAt, p.UpdatedAt,
+			p.PDFUrl, p.ArxivUrl, p.CreatedAt, p.HTMLURL, p.HTMLCheckedAt, models.WordCount(p.Abstract), p.License,
+			p.ContentHash, p.LastSeenAt, p.Comment, p.DOI, p.JournalRef,
+		); err != nil {
+			return fmt.Errorf("failed to restore paper %s: %w", id, err)
+		}
+		if err := setPaperEntities(tx, id, p.Title, p.Abstract); err != nil {
+			return err
+		}
+		if err := setPaperMinhash(tx, id, p.Abstract); err != nil {
+			return err
+		}
+
+		if e := s.Library; e != nil {
+			if _, err := tx.Exec(
+				"INSERT OR REPLACE INTO library (paper_id, is_read, saved_at, priority, note) VALUES (?, ?, ?, ?, ?)",
+				id, e.IsRead, e.SavedAt, e.Priority, e.Note,
+			); err != nil {
+				return fmt.Errorf("failed to restore library entry: %w", err)
+			}
+		}
+
+		for _, tag := range s.Tags {
+			tagID, err := restoreTag(tx, tag)
+			if err != nil {
+				return fmt.Errorf("failed to restore tag %s: %w", tag.Name, err)
+			}
+			if _, err := tx.Exec("INSERT OR IGNORE INTO paper_tags (paper_id, tag_id) VALUES (?, ?)", id, tagID); err != nil {
+				return fmt.Errorf("failed to restore tag %s: %w", tag.Name, err)
+			}
+		}
+
+		for _, a := range s.Assignments {
+			if _, err := tx.Exec(
+				"INSERT INTO assignments (paper_id, assignee, due_date, presented, created_at) VALUES (?, ?, ?, ?, ?)",
+				id, a.Assignee, a.DueDate.Format("2006-01-02"), a.Presented, a.CreatedAt,
+			); err != nil {
+				return fmt.Errorf("failed to restore assignment: %w", err)
+			}
+		}
+
+		for _, keyword := range s.Keywords {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO paper_keywords (paper_id, keyword) VALUES (?, ?)", id, keyword); err != nil {
+				return fmt.Errorf("failed to restore keyword %s: %w", keyword, err)
+			}
+		}
+
+		for _, rel := range s.Relations {
+			if _, err := tx.Exec(
+				"INSERT OR IGNORE INTO relations (from_id, to_id, kind, created_at) VALUES (?, ?, ?, ?)",
+				rel.FromID, rel.ToID, rel.Kind, rel.CreatedAt,
+			); err != nil {
+				return fmt.Errorf("failed to restore relation: %w", err)
+			}
+		}
+
+		if err := setPaperVenues(tx, id, s.Venues); err != nil {
+			return err
+		}
+
+		if plan := s.Plan; plan != nil {
+			if _, err := tx.Exec(
+				"INSERT OR REPLACE INTO reading_plan (paper_id, planned_for, created_at) VALUES (?, ?, ?)",
+				id, plan.PlannedFor.Format("2006-01-02"), plan.CreatedAt,
+			); err != nil {
+				return fmt.Errorf("failed to restore reading plan: %w", err)
+			}
+		}
+
+		// Shelves are matched by name; entries of since deleted shelves are dropped
+		for _, e := range s.Shelves {
+			if _, err := tx.Exec(`
+				INSERT OR IGNORE INTO shelf_papers (shelf_id, paper_id, is_read, priority, added_at)
+				SELECT id, ?, ?, ?, ? FROM shelves WHERE name = ?
+			`, id, e.IsRead, e.Priority, e.AddedAt, e.Shelf); err != nil {
+				return fmt.Errorf("failed to restore shelf entry: %w", err)
+			}
+		}
+
+		if r := s.Review; r != nil {
+			if _, err := tx.Exec(
+				"INSERT OR REPLACE INTO reviews (paper_id, status, curator, queued_at, decided_at) VALUES (?, ?, ?, ?, ?)",
+				id, r.Status, r.Curator, r.QueuedAt, r.DecidedAt,
+			); err != nil {
+				return fmt.Errorf("failed to restore review: %w", err)
+			}
+		}
+
+		_, err := tx.Exec("DELETE FROM trash WHERE paper_id = ?", id)
+		return err
+	})
+}
+
+// restoreTag returns the ID of a trashed paper's tag, recreating it under
+// its owner if it was deleted since. Like ensureTag it looks at the owner's
+// personal tags before the shared ones, so a personal tag shared since is
+// still the same tag.
+func restoreTag(tx *sqlx.Tx, tag trashedTag) (int, error) {
+	var existing models.Tag
+	err := tx.Get(&existing, "SELECT id, name, owner FROM tags t WHERE t.name = ? AND "+visibleTag+" ORDER BY t.owner = '' LIMIT 1", tag.Name, tag.Owner)
+	if err == sql.ErrNoRows {
+		result, err := tx.Exec("INSERT INTO tags (name, owner) VALUES (?, ?)", tag.Name, tag.Owner)
+		if err != nil {
+			return 0, err
+		}
+		id, err := result.LastInsertId()
+		return int(id), err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return existing.ID, nil
+}
+
+// PurgePaper permanently deletes a paper from the recycle bin
+func (db *DB) PurgePaper(id string) error {
+	_, err := db.Exec("DELETE FROM trash WHERE paper_id = ?", id)
+	return err
+}
+
+// PurgeTrash permanently deletes papers trashed before the given time and
+// returns how many were removed
+func (db *DB) PurgeTrash(before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM trash WHERE deleted_at < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	return result.RowsAffected()
+}
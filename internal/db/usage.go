@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// AddUsage adds counts to the usage statistics, summing with what is
+// already recorded for the same day and route
+func (db *DB) AddUsage(counts []models.UsageCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		for _, c := range counts {
+			if _, err := tx.Exec(`
+				INSERT INTO usage_day (day, kind, name, count, visitors) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(day, kind, name) DO UPDATE SET
+					count = count + excluded.count,
+					visitors = visitors + excluded.visitors
+			`, c.Day, c.Kind, c.Name, c.Count, c.Visitors); err != nil {
+				return fmt.Errorf("failed to record usage: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetUsage returns the usage statistics since the given day, oldest first
+func (db *DB) GetUsage(since time.Time) ([]models.UsageCount, error) {
+	var counts []models.UsageCount
+	err := db.Select(&counts, `
+		SELECT day, kind, name, count, visitors FROM usage_day
+		WHERE day >= ?
+		ORDER BY day, kind, name
+	`, since.UTC().Format(dayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return counts, nil
+}
+
+// ClearUsage deletes all usage statistics
+func (db *DB) ClearUsage() error {
+	if _, err := db.Exec(`DELETE FROM usage_day`); err != nil {
+		return fmt.Errorf("failed to clear usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestUsage(t *testing.T) {
+	db := setupTestDB(t)
+	counts := []models.UsageCount{
+		{Day: "2024-03-01", Kind: models.UsagePage, Name: "GET /tags", Count: 3, Visitors: 2},
+		{Day: "2024-03-02", Kind: models.UsageAction, Name: "POST /tag/add", Count: 1, Visitors: 1},
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.AddUsage(counts); err != nil {
+			t.Fatalf("AddUsage failed: %v", err)
+		}
+	}
+
+	got, err := db.GetUsage(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetUsage failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "POST /tag/add" || got[0].Count != 2 || got[0].Visitors != 2 {
+		t.Errorf("Expected the second day's counts summed, got %+v", got)
+	}
+
+	if err := db.ClearUsage(); err != nil {
+		t.Fatalf("ClearUsage failed: %v", err)
+	}
+	if got, _ := db.GetUsage(time.Time{}); len(got) != 0 {
+		t.Errorf("Expected no usage after clearing, got %+v", got)
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// SetPaperVenues replaces the conferences recorded for a paper
+func (db *DB) SetPaperVenues(paperID string, venues []models.VenueMention) error {
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		return setPaperVenues(tx, paperID, venues)
+	})
+}
+
+// setPaperVenues replaces the conferences recorded for a paper within a
+// transaction
+func setPaperVenues(e sqlx.Execer, paperID string, venues []models.VenueMention) error {
+	if _, err := e.Exec("DELETE FROM paper_venues WHERE paper_id = ?", paperID); err != nil {
+		return fmt.Errorf("failed to clear venues: %w", err)
+	}
+	for _, v := range venues {
+		if _, err := e.Exec(
+			"INSERT OR IGNORE INTO paper_venues (paper_id, venue, year) VALUES (?, ?, ?)",
+			paperID, v.Venue, v.Year,
+		); err != nil {
+			return fmt.Errorf("failed to add venue %q: %w", v.Venue, err)
+		}
+	}
+	return nil
+}
+
+// GetPaperVenues returns the conferences a paper's comment mentions
+func (db *DB) GetPaperVenues(paperID string) ([]models.VenueMention, error) {
+	var venues []models.VenueMention
+	err := db.Select(&venues, "SELECT venue, year FROM paper_venues WHERE paper_id = ? ORDER BY venue", paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch paper venues: %w", err)
+	}
+	return venues, nil
+}
+
+// GetMentionedVenues returns the name of every conference a stored paper
+// mentions
+func (db *DB) GetMentionedVenues() ([]string, error) {
+	var names []string
+	if err := db.Select(&names, "SELECT DISTINCT venue FROM paper_venues ORDER BY venue"); err != nil {
+		return nil, fmt.Errorf("failed to fetch mentioned venues: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,378 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/dupes"
+	"github.com/ngx/arxiv-go-nest/internal/entities"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// verifyExamples is how many of the problems a check finds it names
+const verifyExamples = 5
+
+// IndexCheck is the outcome of one of the checks VerifyIndexes runs
+type IndexCheck struct {
+	Name string
+	// Problems counts what was found out of step: rows, papers or
+	// categories, depending on the check
+	Problems int
+	// Examples name the first few problems
+	Examples []string
+	// Repaired is set when the problems were fixed
+	Repaired bool
+}
+
+// verifyStore is what checks read and repair through: the database while
+// only verifying, a transaction while repairing
+type verifyStore interface {
+	sqlx.Execer
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+}
+
+// indexChecks are the checks VerifyIndexes runs, in order
+var indexChecks = []struct {
+	name  string
+	check func(s verifyStore, repair bool, c *IndexCheck) error
+}{
+	{"sqlite indexes", checkSQLiteIndexes},
+	{"orphaned rows", checkOrphans},
+	{"abstract word counts", checkWordCounts},
+	{"entities", checkEntities},
+	{"duplicate signatures", checkMinhash},
+	{"category rollup", checkCategoryRollup},
+}
+
+// VerifyIndexes cross-checks what is derived from the papers table against
+// the papers themselves: SQLite's own indexes, the abstract word counts,
+// the extracted datasets and benchmarks, the MinHash signatures duplicates
+// are found with, the per-category rollup, and rows
+// left behind by papers that no longer exist. Drift creeps in when a
+// process dies between writes that belong together.
+//
+// With repair, each check fixes what it finds in its own transaction and
+// the caches are dropped afterwards. Venue mentions aren't checked, as
+// they depend on the venue catalog in use when the paper was stored.
+func (db *DB) VerifyIndexes(repair bool) ([]IndexCheck, error) {
+	return db.VerifyIndexesProgress(repair, nil)
+}
+
+// VerifyIndexesProgress is VerifyIndexes calling progress, unless nil,
+// before each check with how many of them are done and the next one's name
+func (db *DB) VerifyIndexesProgress(repair bool, progress func(done, total int, name string)) ([]IndexCheck, error) {
+	checks := make([]IndexCheck, len(indexChecks))
+	repaired := false
+	for i, ic := range indexChecks {
+		if progress != nil {
+			progress(i, len(indexChecks), ic.name)
+		}
+		c := &checks[i]
+		c.Name = ic.name
+		if !repair {
+			if err := ic.check(db, false, c); err != nil {
+				return checks[:i], fmt.Errorf("failed to check %s: %w", ic.name, err)
+			}
+			continue
+		}
+
+		err := db.Transaction(func(tx *sqlx.Tx) error {
+			*c = IndexCheck{Name: ic.name}
+			return ic.check(tx, true, c)
+		})
+		if err != nil {
+			return checks[:i], fmt.Errorf("failed to repair %s: %w", ic.name, err)
+		}
+		repaired = repaired || c.Repaired
+	}
+
+	if repaired {
+		db.invalidateAll()
+	}
+	return checks, nil
+}
+
+// found records a problem, naming it if there aren't enough examples yet
+func (c *IndexCheck) found(format string, args ...interface{}) {
+	c.Problems++
+	if len(c.Examples) < verifyExamples {
+		c.Examples = append(c.Examples, fmt.Sprintf(format, args...))
+	}
+}
+
+// checkSQLiteIndexes runs SQLite's integrity check, which includes
+// comparing every index with its table, and rebuilds the indexes
+func checkSQLiteIndexes(s verifyStore, repair bool, c *IndexCheck) error {
+	var errors []string
+	if err := s.Select(&errors, "PRAGMA integrity_check"); err != nil {
+		return err
+	}
+	for _, e := range errors {
+		if e != "ok" {
+			c.found("%s", e)
+		}
+	}
+	if c.Problems == 0 || !repair {
+		return nil
+	}
+
+	if _, err := s.Exec("REINDEX"); err != nil {
+		return fmt.Errorf("failed to rebuild indexes: %w", err)
+	}
+	// Damage to the tables themselves can't be fixed by rebuilding indexes
+	errors = nil
+	if err := s.Select(&errors, "PRAGMA integrity_check"); err != nil {
+		return err
+	}
+	c.Repaired = len(errors) == 1 && errors[0] == "ok"
+	return nil
+}
+
+// checkOrphans finds rows referring to papers or tags that no longer
+// exist, and deletes them
+func checkOrphans(s verifyStore, repair bool, c *IndexCheck) error {
+	orphans := make(map[string]string, len(paperTables)+2)
+	for _, table := range paperTables {
+		orphans[table] = "paper_id NOT IN (SELECT id FROM papers)"
+	}
+	orphans["relations"] = "from_id NOT IN (SELECT id FROM papers) OR to_id NOT IN (SELECT id FROM papers)"
+	orphans["duplicates"] = "paper_id NOT IN (SELECT id FROM papers) OR duplicate_id NOT IN (SELECT id FROM papers)"
+	tables := append([]string{}, paperTables...)
+	tables = append(tables, "relations", "duplicates")
+
+	for _, table := range tables {
+		var rows int
+		if err := s.Get(&rows, "SELECT COUNT(*) FROM "+table+" WHERE "+orphans[table]); err != nil {
+			return fmt.Errorf("failed to count orphaned rows in %s: %w", table, err)
+		}
+		if rows > 0 {
+			c.found("%s: %d rows", table, rows)
+			c.Problems += rows - 1
+		}
+	}
+
+	// Tags can't be deleted, but a crash mid-import can leave tag rows
+	// behind that never were created
+	var rows int
+	if err := s.Get(&rows, "SELECT COUNT(*) FROM paper_tags WHERE tag_id NOT IN (SELECT id FROM tags)"); err != nil {
+		return fmt.Errorf("failed to count orphaned tag rows: %w", err)
+	}
+	if rows > 0 {
+		c.found("paper_tags: %d rows for missing tags", rows)
+		c.Problems += rows - 1
+	}
+	if c.Problems == 0 || !repair {
+		return nil
+	}
+
+	for _, table := range tables {
+		if _, err := s.Exec("DELETE FROM " + table + " WHERE " + orphans[table]); err != nil {
+			return fmt.Errorf("failed to delete orphaned rows from %s: %w", table, err)
+		}
+	}
+	if _, err := s.Exec("DELETE FROM paper_tags WHERE tag_id NOT IN (SELECT id FROM tags)"); err != nil {
+		return fmt.Errorf("failed to delete orphaned tag rows: %w", err)
+	}
+	c.Repaired = true
+	return nil
+}
+
+// checkWordCounts compares the word counts recorded at ingest with the
+// abstracts, and recounts those that differ
+func checkWordCounts(s verifyStore, repair bool, c *IndexCheck) error {
+	var papers []struct {
+		ID       string `db:"id"`
+		Abstract string `db:"abstract"`
+		Words    int    `db:"abstract_words"`
+	}
+	if err := s.Select(&papers, "SELECT id, COALESCE(abstract, '') AS abstract, COALESCE(abstract_words, 0) AS abstract_words FROM papers ORDER BY id"); err != nil {
+		return fmt.Errorf("failed to read word counts: %w", err)
+	}
+
+	for _, p := range papers {
+		words := models.WordCount(p.Abstract)
+		if words == p.Words {
+			continue
+		}
+		c.found("%s: %d words recorded, %d in the abstract", p.ID, p.Words, words)
+		if repair {
+			if _, err := s.Exec("UPDATE papers SET abstract_words = ? WHERE id = ?", words, p.ID); err != nil {
+				return fmt.Errorf("failed to recount words of %s: %w", p.ID, err)
+			}
+		}
+	}
+	c.Repaired = repair && c.Problems > 0
+	return nil
+}
+
+// checkEntities extracts the datasets and benchmarks of every paper again
+// and compares them with those recorded, replacing them where they differ
+func checkEntities(s verifyStore, repair bool, c *IndexCheck) error {
+	var papers []struct {
+		ID       string `db:"id"`
+		Title    string `db:"title"`
+		Abstract string `db:"abstract"`
+	}
+	if err := s.Select(&papers, "SELECT id, title, COALESCE(abstract, '') AS abstract FROM papers ORDER BY id"); err != nil {
+		return fmt.Errorf("failed to read papers for entities: %w", err)
+	}
+	var rows []struct {
+		PaperID string `db:"paper_id"`
+		Name    string `db:"name"`
+		Kind    string `db:"kind"`
+	}
+	if err := s.Select(&rows, "SELECT paper_id, name, kind FROM paper_entities"); err != nil {
+		return fmt.Errorf("failed to read entities: %w", err)
+	}
+	stored := make(map[string][]string)
+	for _, r := range rows {
+		stored[r.PaperID] = append(stored[r.PaperID], r.Name+"\x00"+r.Kind)
+	}
+
+	for _, p := range papers {
+		// Rows are inserted ignoring repeated names, so the first one wins
+		var want []string
+		seen := make(map[string]bool)
+		for _, e := range entities.Extract(p.Title, p.Abstract) {
+			if !seen[e.Name] {
+				seen[e.Name] = true
+				want = append(want, e.Name+"\x00"+e.Kind)
+			}
+		}
+		have := stored[p.ID]
+		sort.Strings(want)
+		sort.Strings(have)
+		if slices.Equal(want, have) {
+			continue
+		}
+
+		c.found("%s: %d recorded, %d extracted", p.ID, len(have), len(want))
+		if repair {
+			if err := setPaperEntities(s, p.ID, p.Title, p.Abstract); err != nil {
+				return fmt.Errorf("failed to extract entities for %s: %w", p.ID, err)
+			}
+		}
+	}
+	c.Repaired = repair && c.Problems > 0
+	return nil
+}
+
+// checkMinhash compares the MinHash bands recorded for each paper with
+// those of its abstract. Repairs record them again and then look for the
+// repaired papers' duplicates, which a paper without bands was never
+// compared with.
+func checkMinhash(s verifyStore, repair bool, c *IndexCheck) error {
+	var papers []struct {
+		ID       string `db:"id"`
+		Abstract string `db:"abstract"`
+	}
+	if err := s.Select(&papers, "SELECT id, COALESCE(abstract, '') AS abstract FROM papers ORDER BY id"); err != nil {
+		return fmt.Errorf("failed to read papers for minhash: %w", err)
+	}
+	var rows []struct {
+		PaperID string `db:"paper_id"`
+		Hash    int64  `db:"hash"`
+	}
+	if err := s.Select(&rows, "SELECT paper_id, hash FROM paper_minhash ORDER BY paper_id, band"); err != nil {
+		return fmt.Errorf("failed to read minhash bands: %w", err)
+	}
+	stored := make(map[string][]int64)
+	for _, r := range rows {
+		stored[r.PaperID] = append(stored[r.PaperID], r.Hash)
+	}
+
+	stale := papers[:0]
+	for _, p := range papers {
+		if slices.Equal(dupes.Signature(p.Abstract), stored[p.ID]) {
+			continue
+		}
+		c.found("%s: %d bands recorded", p.ID, len(stored[p.ID]))
+		stale = append(stale, p)
+	}
+	if !repair {
+		return nil
+	}
+
+	for _, p := range stale {
+		if err := setPaperMinhash(s, p.ID, p.Abstract); err != nil {
+			return fmt.Errorf("failed to compute minhash for %s: %w", p.ID, err)
+		}
+	}
+	now := time.Now()
+	for _, p := range stale {
+		if err := suggestDuplicates(s, p.ID, p.Abstract, now); err != nil {
+			return err
+		}
+	}
+	c.Repaired = c.Problems > 0
+	return nil
+}
+
+// checkCategoryRollup compares the papers each category's rollup counts
+// with the papers stored in it. The rollup keeps counting papers after
+// they are deleted and its days are the days papers were fetched, so only
+// a category counting fewer papers than are stored has drifted. Repairs
+// raise the category's days to the papers stored on them.
+func checkCategoryRollup(s verifyStore, repair bool, c *IndexCheck) error {
+	var papers []struct {
+		Day        string `db:"day"`
+		Categories string `db:"categories"`
+	}
+	if err := s.Select(&papers, "SELECT substr(created_at, 1, 10) AS day, COALESCE(categories, '') AS categories FROM papers"); err != nil {
+		return fmt.Errorf("failed to read papers for the rollup: %w", err)
+	}
+	var rollup []struct {
+		Category string `db:"category"`
+		Papers   int    `db:"papers"`
+	}
+	if err := s.Select(&rollup, "SELECT category, SUM(papers) AS papers FROM rollup_category_day GROUP BY category"); err != nil {
+		return fmt.Errorf("failed to read the category rollup: %w", err)
+	}
+
+	type dayCategory struct{ day, category string }
+	days := make(map[dayCategory]int)
+	stored := make(map[string]int)
+	for _, p := range papers {
+		category := primaryCategory(p.Categories)
+		days[dayCategory{p.Day, category}]++
+		stored[category]++
+	}
+	counted := make(map[string]int, len(rollup))
+	for _, r := range rollup {
+		counted[r.Category] = r.Papers
+	}
+
+	categories := make([]string, 0, len(stored))
+	for category := range stored {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	drifted := make(map[string]bool)
+	for _, category := range categories {
+		if stored[category] > counted[category] {
+			drifted[category] = true
+			c.found("%s: %d stored, %d counted", category, stored[category], counted[category])
+		}
+	}
+	if c.Problems == 0 || !repair {
+		return nil
+	}
+
+	for k, n := range days {
+		if !drifted[k.category] {
+			continue
+		}
+		if _, err := s.Exec(`
+			INSERT INTO rollup_category_day (day, category, papers) VALUES (?, ?, ?)
+			ON CONFLICT(day, category) DO UPDATE SET papers = MAX(papers, excluded.papers)
+		`, k.day, k.category, n); err != nil {
+			return fmt.Errorf("failed to update category rollup: %w", err)
+		}
+	}
+	c.Repaired = true
+	return nil
+}
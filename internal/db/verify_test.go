@@ -0,0 +1,131 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestVerifyIndexes(t *testing.T) {
+	db := setupTestDB(t)
+	paper := &models.Paper{
+		ID:          "2401.00001",
+		Title:       "Scaling laws on ImageNet",
+		Abstract:    "We train on ImageNet and evaluate on GLUE.",
+		Categories:  "cs.LG",
+		PublishedAt: time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := db.UpsertPaper(paper); err != nil {
+		t.Fatalf("UpsertPaper failed: %v", err)
+	}
+	if err := db.RecordNewPapers([]*models.Paper{paper}, time.Now()); err != nil {
+		t.Fatalf("RecordNewPapers failed: %v", err)
+	}
+
+	problems := func(checks []IndexCheck) map[string]int {
+		found := make(map[string]int)
+		for _, c := range checks {
+			if c.Problems > 0 {
+				found[c.Name] = c.Problems
+			}
+		}
+		return found
+	}
+
+	checks, err := db.VerifyIndexes(false)
+	if err != nil {
+		t.Fatalf("VerifyIndexes failed: %v", err)
+	}
+	if found := problems(checks); len(found) > 0 {
+		t.Fatalf("Expected a consistent database, got %v", found)
+	}
+
+	// Drift as a crash between writes would leave it
+	for _, q := range []string{
+		"UPDATE papers SET abstract_words = 0",
+		"DELETE FROM paper_entities",
+		"DELETE FROM rollup_category_day",
+		"INSERT INTO library (paper_id) VALUES ('2401.99999')",
+		"INSERT INTO relations (from_id, to_id, kind) VALUES ('2401.00001', '2401.99999', 'extends')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	checks, err = db.VerifyIndexes(false)
+	if err != nil {
+		t.Fatalf("VerifyIndexes failed: %v", err)
+	}
+	want := map[string]int{"orphaned rows": 2, "abstract word counts": 1, "entities": 1, "category rollup": 1}
+	found := problems(checks)
+	for name, n := range want {
+		if found[name] != n {
+			t.Errorf("Expected %d problems with %s, got %d", n, name, found[name])
+		}
+	}
+	for _, c := range checks {
+		if c.Repaired {
+			t.Errorf("Expected %s not to be repaired without repair", c.Name)
+		}
+	}
+
+	generation := db.Generation()
+	checks, err = db.VerifyIndexes(true)
+	if err != nil {
+		t.Fatalf("VerifyIndexes(repair) failed: %v", err)
+	}
+	for _, c := range checks {
+		if c.Problems > 0 && !c.Repaired {
+			t.Errorf("Expected %s to be repaired", c.Name)
+		}
+	}
+	if db.Generation() == generation {
+		t.Error("Expected a repair to invalidate the caches")
+	}
+
+	checks, err = db.VerifyIndexes(false)
+	if err != nil {
+		t.Fatalf("VerifyIndexes failed: %v", err)
+	}
+	if found := problems(checks); len(found) > 0 {
+		t.Errorf("Expected the repair to leave a consistent database, got %v", found)
+	}
+	var entities int
+	db.Get(&entities, "SELECT COUNT(*) FROM paper_entities")
+	if entities == 0 {
+		t.Error("Expected the entities to be extracted again")
+	}
+}
+
+func TestVerifyMinhash(t *testing.T) {
+	db := setupTestDB(t)
+	abstract := "We study how the learning rate schedule interacts with batch size when training deep networks, " +
+		"and show that warmup followed by cosine decay matches tuned step schedules across model scales"
+	for i, text := range []string{abstract, abstract + " and tasks"} {
+		paper := &models.Paper{ID: fmt.Sprintf("2401.0000%d", i+1), Title: "Schedules", Abstract: text, PublishedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.UpsertPaper(paper); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+	}
+
+	// The bands went missing before the papers were compared
+	db.Exec("DELETE FROM paper_minhash")
+	db.Exec("DELETE FROM duplicates")
+
+	checks, err := db.VerifyIndexes(true)
+	if err != nil {
+		t.Fatalf("VerifyIndexes(repair) failed: %v", err)
+	}
+	for _, c := range checks {
+		if c.Name == "duplicate signatures" && (c.Problems != 2 || !c.Repaired) {
+			t.Errorf("Expected both papers' signatures repaired, got %+v", c)
+		}
+	}
+	if pairs, _ := db.GetDuplicates(); len(pairs) != 1 {
+		t.Errorf("Expected the pair to be suggested again, got %d", len(pairs))
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrViewExists is returned when saving a view under a taken name
+var ErrViewExists = errors.New("a view with this name already exists")
+
+// CreateView saves a view. paperIDs are the papers it currently returns,
+// which won't be shown as new on the first visit.
+func (db *DB) CreateView(name, page, query string, paperIDs []string) (*models.SavedView, error) {
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		result, err := tx.Exec("INSERT OR IGNORE INTO saved_views (name, page, query) VALUES (?, ?, ?)", name, page, query)
+		if err != nil {
+			return fmt.Errorf("failed to save view: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return ErrViewExists
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return setViewPapers(tx, int(id), paperIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetView(name)
+}
+
+// GetView returns a saved view by name
+func (db *DB) GetView(name string) (*models.SavedView, error) {
+	var view models.SavedView
+	if err := db.Get(&view, "SELECT * FROM saved_views WHERE name = ?", name); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// GetViews returns every saved view, ordered by name
+func (db *DB) GetViews() ([]models.SavedView, error) {
+	var views []models.SavedView
+	if err := db.Select(&views, "SELECT * FROM saved_views ORDER BY name COLLATE NOCASE"); err != nil {
+		return nil, fmt.Errorf("failed to fetch views: %w", err)
+	}
+	return views, nil
+}
+
+// DeleteView deletes a saved view
+func (db *DB) DeleteView(id int) error {
+	return db.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM view_papers WHERE view_id = ?", id); err != nil {
+			return fmt.Errorf("failed to clear view papers: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM saved_views WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete view: %w", err)
+		}
+		return nil
+	})
+}
+
+// VisitView records a visit to a view that returned paperIDs, and returns
+// those that it didn't return on the previous visit, in the given order
+func (db *DB) VisitView(id int, paperIDs []string, at time.Time) ([]string, error) {
+	var arrived []string
+	err := db.Transaction(func(tx *sqlx.Tx) error {
+		arrived = nil
+		var seen []string
+		if err := tx.Select(&seen, "SELECT paper_id FROM view_papers WHERE view_id = ?", id); err != nil {
+			return fmt.Errorf("failed to fetch view papers: %w", err)
+		}
+		known := make(map[string]bool, len(seen))
+		for _, paperID := range seen {
+			known[paperID] = true
+		}
+		for _, paperID := range paperIDs {
+			if !known[paperID] {
+				arrived = append(arrived, paperID)
+			}
+		}
+
+		if err := setViewPapers(tx, id, paperIDs); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE saved_views SET visited_at = ? WHERE id = ?", at.UTC(), id); err != nil {
+			return fmt.Errorf("failed to record visit: %w", err)
+		}
+		return nil
+	})
+	return arrived, err
+}
+
+// setViewPapers replaces the papers a view is known to return
+func setViewPapers(tx *sqlx.Tx, id int, paperIDs []string) error {
+	if _, err := tx.Exec("DELETE FROM view_papers WHERE view_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear view papers: %w", err)
+	}
+	for _, paperID := range paperIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO view_papers (view_id, paper_id) VALUES (?, ?)", id, paperID); err != nil {
+			return fmt.Errorf("failed to record view paper: %w", err)
+		}
+	}
+	return nil
+}
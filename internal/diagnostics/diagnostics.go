@@ -0,0 +1,145 @@
+package diagnostics
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+// fetchHistory is how many recent fetches a bundle includes
+const fetchHistory = 50
+
+// Report is the machine-readable part of a diagnostics bundle
+type Report struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Version     version.Info `json:"version"`
+	Database    *db.Info     `json:"database,omitempty"`
+	FetchRuns   []FetchRun   `json:"fetch_runs"`
+
+	// Errors lists sections that could not be collected
+	Errors []string `json:"errors,omitempty"`
+}
+
+// FetchRun is a recorded fetch
+type FetchRun struct {
+	Scope      string    `json:"scope"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Fetched    int       `json:"fetched"`
+	Stored     int       `json:"stored"`
+	New        int       `json:"new"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Bundle collects diagnostics for a bug report. Logs may be nil.
+type Bundle struct {
+	config *config.Config
+	db     *db.DB
+	logs   *LogBuffer
+}
+
+// New creates a bundle for the given configuration, database and logs
+func New(cfg *config.Config, database *db.DB, logs *LogBuffer) *Bundle {
+	return &Bundle{config: cfg, db: database, logs: logs}
+}
+
+// Filename suggests a name for a bundle written at the given time
+func Filename(now time.Time) string {
+	return "arxiv-nest-diagnostics-" + now.Format("20060102-150405") + ".zip"
+}
+
+// WriteZip writes the bundle as a zip file containing report.json,
+// config.yaml and logs.txt. Secrets from the configuration are removed from
+// every file. Sections that fail to collect are noted in the report rather
+// than failing the bundle.
+func (b *Bundle) WriteZip(w io.Writer) error {
+	report := b.collect()
+	scrub := b.scrubber()
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	configYAML, err := yaml.Marshal(b.config.Redacted())
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	logs := "Logs are not captured by this process.\n"
+	if b.logs != nil {
+		logs = strings.Join(b.logs.Lines(), "\n") + "\n"
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"report.json", string(reportJSON)},
+		{"config.yaml", string(configYAML)},
+		{"logs.txt", logs},
+	}
+	for _, file := range files {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: report.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, scrub.Replace(file.content)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// collect gathers the report sections
+func (b *Bundle) collect() *Report {
+	report := &Report{
+		GeneratedAt: time.Now().UTC(),
+		Version:     version.Get(),
+		FetchRuns:   []FetchRun{},
+	}
+
+	info, err := b.db.Info()
+	if err != nil {
+		report.Errors = append(report.Errors, "database: "+err.Error())
+	}
+	report.Database = info
+
+	runs, err := b.db.GetFetchRuns(fetchHistory)
+	if err != nil {
+		report.Errors = append(report.Errors, "fetch history: "+err.Error())
+	}
+	for _, run := range runs {
+		report.FetchRuns = append(report.FetchRuns, FetchRun{
+			Scope:      run.Scope,
+			StartedAt:  run.StartedAt,
+			DurationMS: run.FinishedAt.Sub(run.StartedAt).Milliseconds(),
+			Fetched:    run.Fetched,
+			Stored:     run.Stored,
+			New:        run.New,
+			Error:      run.Error,
+		})
+	}
+
+	return report
+}
+
+// scrubber replaces configured secrets wherever they appear
+func (b *Bundle) scrubber() *strings.Replacer {
+	var pairs []string
+	for _, secret := range b.config.Secrets() {
+		if secret != "" {
+			pairs = append(pairs, secret, "[redacted]")
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
@@ -0,0 +1,118 @@
+// Package dupes finds papers with near-identical abstracts, such as a
+// preprint imported from both arXiv and bioRxiv. Abstracts are split into
+// overlapping word shingles and summarized by a MinHash signature, whose
+// bands are stored with each paper so candidates can be looked up by
+// index; candidates are then compared on their shingles.
+package dupes
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ShingleSize is the number of consecutive words in a shingle
+	ShingleSize = 3
+
+	// Threshold is the share of shingles two abstracts need in common to
+	// be suggested as duplicates
+	Threshold = 0.8
+
+	// minShingles keeps short abstracts out: a few sentences of boilerplate
+	// would otherwise match every paper using them
+	minShingles = 12
+
+	// numBands and bandRows split a 64 value signature so that abstracts
+	// sharing about half their shingles or more likely share a band
+	numBands = 16
+	bandRows = 4
+)
+
+// Shingles returns the hashed word shingles of a text, ignoring case and
+// punctuation
+func Shingles(text string) map[uint64]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	shingles := make(map[uint64]bool)
+	for i := 0; i+ShingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+ShingleSize], " ")))
+		shingles[h.Sum64()] = true
+	}
+	return shingles
+}
+
+// Similarity returns the Jaccard similarity of the shingles of two texts,
+// from 0 for nothing in common to 1 for the same words in the same order
+func Similarity(a, b string) float64 {
+	return jaccard(Shingles(a), Shingles(b))
+}
+
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for s := range a {
+		if b[s] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// Signature returns the band hashes of an abstract's MinHash signature, one
+// per band. Two abstracts sharing a band hash are duplicate candidates. It
+// returns nil for abstracts too short to compare.
+func Signature(abstract string) []int64 {
+	shingles := Shingles(abstract)
+	if len(shingles) < minShingles {
+		return nil
+	}
+
+	var mins [numBands * bandRows]uint64
+	for i := range mins {
+		mins[i] = ^uint64(0)
+	}
+	for s := range shingles {
+		for i := range mins {
+			if h := mix(s ^ seeds[i]); h < mins[i] {
+				mins[i] = h
+			}
+		}
+	}
+
+	bands := make([]int64, numBands)
+	for b := range bands {
+		h := uint64(b)
+		for _, m := range mins[b*bandRows : (b+1)*bandRows] {
+			h = mix(h ^ m)
+		}
+		// SQLite integers are signed
+		bands[b] = int64(h)
+	}
+	return bands
+}
+
+// seeds derive the signature's hash functions from the shingle hashes
+var seeds = func() [numBands * bandRows]uint64 {
+	var s [numBands * bandRows]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		x = mix(x + uint64(i))
+		s[i] = x
+	}
+	return s
+}()
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
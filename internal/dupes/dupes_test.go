@@ -0,0 +1,50 @@
+package dupes
+
+import (
+	"strings"
+	"testing"
+)
+
+const abstract = `Single-cell sequencing reveals the diversity of cell states in developing tissues,
+but linking these states to lineage remains difficult. We present a computational method that
+reconstructs lineage trees from paired transcriptomic and clonal barcoding data, and apply it to
+the developing mouse cortex. Our approach recovers known progenitor relationships and predicts
+new transitions, which we validate experimentally.`
+
+func TestSignature(t *testing.T) {
+	// The same abstract with a typo fixed and different line breaks, as
+	// another server would render it
+	edited := strings.ReplaceAll(strings.Replace(abstract, "difficult.", "challenging.", 1), "\n", " ")
+	other := `We study the convergence of stochastic gradient descent on overparameterized
+neural networks and show that, under mild assumptions on the data, training reaches a global
+minimum at a linear rate. Experiments on image classification benchmarks confirm the theory.`
+
+	if s := Similarity(abstract, edited); s < Threshold {
+		t.Errorf("Expected the edited abstract to be a duplicate, similarity %.2f", s)
+	}
+	if s := Similarity(abstract, other); s > 0.1 {
+		t.Errorf("Expected unrelated abstracts to differ, similarity %.2f", s)
+	}
+
+	shared := func(a, b []int64) bool {
+		for i := range a {
+			if a[i] == b[i] {
+				return true
+			}
+		}
+		return false
+	}
+	sig := Signature(abstract)
+	if len(sig) != numBands {
+		t.Fatalf("Expected %d bands, got %d", numBands, len(sig))
+	}
+	if !shared(sig, Signature(edited)) {
+		t.Error("Expected the edited abstract to share a band")
+	}
+	if shared(sig, Signature(other)) {
+		t.Error("Expected the unrelated abstract to share no band")
+	}
+	if sig := Signature("Withdrawn: duplicate of an earlier submission."); sig != nil {
+		t.Errorf("Expected no signature for a short abstract, got %v", sig)
+	}
+}
@@ -0,0 +1,200 @@
+// Package entities finds the datasets and benchmarks a paper mentions in
+// its title and abstract, using a curated dictionary of well-known names
+// plus a heuristic for names introduced as "X dataset" or "X benchmark".
+package entities
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// known is a curated dictionary entry: the canonical name, its kind and
+// other spellings that refer to it
+type known struct {
+	name    string
+	kind    string
+	aliases []string
+}
+
+// dictionary lists well-known datasets and benchmarks. Names are matched
+// case-sensitively, since many are also ordinary words in lower case
+// ("glue", "pile").
+var dictionary = []known{
+	// Vision
+	{"ImageNet", models.EntityDataset, []string{"ImageNet-1K", "ImageNet-1k", "ILSVRC"}},
+	{"ImageNet-21K", models.EntityDataset, []string{"ImageNet-21k", "ImageNet-22K", "ImageNet-22k"}},
+	{"CIFAR-10", models.EntityDataset, []string{"CIFAR10"}},
+	{"CIFAR-100", models.EntityDataset, []string{"CIFAR100"}},
+	{"MNIST", models.EntityDataset, nil},
+	{"Fashion-MNIST", models.EntityDataset, []string{"FashionMNIST"}},
+	{"SVHN", models.EntityDataset, nil},
+	{"CelebA", models.EntityDataset, nil},
+	{"COCO", models.EntityDataset, []string{"MS-COCO", "MS COCO", "MSCOCO"}},
+	{"Pascal VOC", models.EntityDataset, []string{"PASCAL VOC"}},
+	{"Cityscapes", models.EntityDataset, nil},
+	{"ADE20K", models.EntityDataset, nil},
+	{"KITTI", models.EntityBenchmark, nil},
+	{"nuScenes", models.EntityDataset, nil},
+	{"Waymo Open Dataset", models.EntityDataset, nil},
+	{"ShapeNet", models.EntityDataset, nil},
+	{"ScanNet", models.EntityDataset, nil},
+	{"Kinetics-400", models.EntityDataset, nil},
+	{"Kinetics-700", models.EntityDataset, nil},
+	{"UCF101", models.EntityDataset, []string{"UCF-101"}},
+	{"Visual Genome", models.EntityDataset, nil},
+	{"Flickr30k", models.EntityDataset, []string{"Flickr30K"}},
+	{"LAION-5B", models.EntityDataset, nil},
+	{"LAION-400M", models.EntityDataset, nil},
+	{"VQA", models.EntityBenchmark, []string{"VQAv2", "VQA v2"}},
+	{"Omniglot", models.EntityDataset, nil},
+	{"miniImageNet", models.EntityDataset, []string{"mini-ImageNet"}},
+
+	// Language
+	{"GLUE", models.EntityBenchmark, nil},
+	{"SuperGLUE", models.EntityBenchmark, nil},
+	{"SQuAD", models.EntityBenchmark, []string{"SQuAD 2.0", "SQuAD2.0", "SQuAD v2"}},
+	{"MMLU", models.EntityBenchmark, nil},
+	{"BIG-bench", models.EntityBenchmark, []string{"BIG-Bench", "BIG-Bench Hard", "BBH"}},
+	{"HellaSwag", models.EntityBenchmark, nil},
+	{"WinoGrande", models.EntityBenchmark, []string{"Winogrande"}},
+	{"TruthfulQA", models.EntityBenchmark, nil},
+	{"GSM8K", models.EntityBenchmark, []string{"GSM8k"}},
+	{"MATH", models.EntityBenchmark, nil},
+	{"HumanEval", models.EntityBenchmark, nil},
+	{"MBPP", models.EntityBenchmark, nil},
+	{"Natural Questions", models.EntityBenchmark, nil},
+	{"TriviaQA", models.EntityBenchmark, nil},
+	{"HotpotQA", models.EntityBenchmark, nil},
+	{"BoolQ", models.EntityBenchmark, nil},
+	{"MS MARCO", models.EntityBenchmark, []string{"MS-MARCO", "MSMARCO"}},
+	{"BEIR", models.EntityBenchmark, nil},
+	{"SNLI", models.EntityDataset, nil},
+	{"MNLI", models.EntityDataset, []string{"MultiNLI"}},
+	{"SST-2", models.EntityDataset, nil},
+	{"CoNLL-2003", models.EntityDataset, nil},
+	{"Penn Treebank", models.EntityDataset, []string{"PTB"}},
+	{"WikiText-103", models.EntityDataset, nil},
+	{"C4", models.EntityDataset, nil},
+	{"The Pile", models.EntityDataset, nil},
+	{"OpenWebText", models.EntityDataset, nil},
+	{"WMT", models.EntityBenchmark, nil},
+
+	// Speech and audio
+	{"LibriSpeech", models.EntityDataset, nil},
+	{"Common Voice", models.EntityDataset, nil},
+	{"AudioSet", models.EntityDataset, nil},
+
+	// Graphs, RL and other domains
+	{"OGB", models.EntityBenchmark, []string{"Open Graph Benchmark"}},
+	{"Cora", models.EntityDataset, nil},
+	{"Citeseer", models.EntityDataset, []string{"CiteSeer"}},
+	{"Atari", models.EntityBenchmark, []string{"Arcade Learning Environment"}},
+	{"MuJoCo", models.EntityBenchmark, nil},
+	{"D4RL", models.EntityBenchmark, nil},
+	{"MIMIC-III", models.EntityDataset, []string{"MIMIC-IV"}},
+}
+
+// pattern matches a dictionary entry in text
+type pattern struct {
+	re   *regexp.Regexp
+	name string
+	kind string
+}
+
+// patterns holds one compiled pattern per dictionary spelling. A name must
+// not be glued to a preceding word or hyphen, so "MNIST" does not match in
+// "Fashion-MNIST", and must end at a word boundary, so "CIFAR-10" does not
+// match in "CIFAR-100".
+var patterns = compile(dictionary)
+
+// compile builds the patterns for a dictionary
+func compile(entries []known) []pattern {
+	var ps []pattern
+	for _, e := range entries {
+		for _, spelling := range append([]string{e.name}, e.aliases...) {
+			re := regexp.MustCompile(`(?:^|[^\w-])` + regexp.QuoteMeta(spelling) + `\b`)
+			ps = append(ps, pattern{re: re, name: e.name, kind: e.kind})
+		}
+	}
+	return ps
+}
+
+// spellings maps every dictionary spelling to its canonical name
+var spellings = func() map[string]string {
+	m := make(map[string]string)
+	for _, e := range dictionary {
+		m[e.name] = e.name
+		for _, alias := range e.aliases {
+			m[alias] = e.name
+		}
+	}
+	return m
+}()
+
+// introducedRegex matches a name directly followed by "dataset",
+// "benchmark", "corpus" or "suite", e.g. "the MedQA benchmark"
+var introducedRegex = regexp.MustCompile(`\b([A-Z][A-Za-z0-9]*(?:-[A-Za-z0-9]+)*)\s+(dataset|benchmark|corpus|suite)s?\b`)
+
+// notNames are capitalized words and field acronyms the heuristic would
+// otherwise take for names, e.g. "LLM benchmark"
+var notNames = map[string]bool{
+	"AI": true, "ML": true, "NLP": true, "CV": true, "RL": true, "QA": true,
+	"LLM": true, "LLMs": true, "VLM": true, "GNN": true, "ASR": true, "MT": true,
+}
+
+// Extract returns the datasets and benchmarks mentioned in a paper's title
+// and abstract, sorted by name. Dictionary names win over the heuristic.
+func Extract(title, abstract string) []models.Entity {
+	text := title + ". " + abstract
+	found := make(map[string]models.Entity)
+
+	for _, p := range patterns {
+		if _, ok := found[p.name]; !ok && p.re.MatchString(text) {
+			found[p.name] = models.Entity{Name: p.name, Kind: p.kind}
+		}
+	}
+
+	for _, m := range introducedRegex.FindAllStringSubmatch(text, -1) {
+		name := m[1]
+		if _, ok := spellings[name]; ok || !looksLikeName(name) {
+			continue
+		}
+		kind := models.EntityDataset
+		if m[2] == "benchmark" || m[2] == "suite" {
+			kind = models.EntityBenchmark
+		}
+		found[name] = models.Entity{Name: name, Kind: kind}
+	}
+
+	result := make([]models.Entity, 0, len(found))
+	for _, e := range found {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// looksLikeName reports whether a word introduced as a dataset or benchmark
+// is a name rather than an ordinary word ("This benchmark", "A dataset"):
+// it needs a second capital letter or a digit, as in "MedQA" or "X3D"
+func looksLikeName(word string) bool {
+	if notNames[word] {
+		return false
+	}
+	upper, digit := 0, false
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper >= 2 || digit
+}
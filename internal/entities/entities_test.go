@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		abstract string
+		want     []models.Entity
+	}{
+		{
+			name:     "dictionary names and aliases",
+			title:    "Depth Estimation for Driving",
+			abstract: "We evaluate on KITTI and nuScenes, and pretrain on ImageNet-1K and MS-COCO.",
+			want: []models.Entity{
+				{Name: "COCO", Kind: models.EntityDataset},
+				{Name: "ImageNet", Kind: models.EntityDataset},
+				{Name: "KITTI", Kind: models.EntityBenchmark},
+				{Name: "nuScenes", Kind: models.EntityDataset},
+			},
+		},
+		{
+			name:     "names inside other names do not match",
+			abstract: "Results on Fashion-MNIST and CIFAR-100 are reported.",
+			want: []models.Entity{
+				{Name: "CIFAR-100", Kind: models.EntityDataset},
+				{Name: "Fashion-MNIST", Kind: models.EntityDataset},
+			},
+		},
+		{
+			name:     "case matters for dictionary names",
+			abstract: "We glue together a pile of modules.",
+			want:     []models.Entity{},
+		},
+		{
+			name:     "introduced names",
+			title:    "MedQA2 and friends",
+			abstract: "We release the ClinBench benchmark and the new MedQA2 dataset. This benchmark and the LLM benchmark are not names.",
+			want: []models.Entity{
+				{Name: "ClinBench", Kind: models.EntityBenchmark},
+				{Name: "MedQA2", Kind: models.EntityDataset},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(tt.title, tt.abstract)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
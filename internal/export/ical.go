@@ -0,0 +1,115 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// icalLineLimit is the maximum length of an iCalendar content line in
+// octets, excluding the line break (RFC 5545, section 3.1)
+const icalLineLimit = 75
+
+// icalEscaper escapes characters with special meaning in iCalendar text
+var icalEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// CalendarEvent is an all-day event in an iCalendar feed. End is the last
+// day of the event (inclusive); a zero End means a single day.
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Start       time.Time
+	End         time.Time
+}
+
+// ICal writes an iCalendar feed named name with the given all-day events.
+// now stamps the events; UIDs should be stable, so calendar apps update
+// moved events instead of duplicating them.
+func ICal(w io.Writer, name string, events []CalendarEvent, now time.Time) error {
+	var b strings.Builder
+	line := func(s string) { writeICalLine(&b, s) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//ArXiv Nest//" + name + "//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + icalEscaper.Replace(name))
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		end := e.End
+		if end.IsZero() {
+			end = e.Start
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + end.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icalEscaper.Replace(e.Summary))
+		if e.URL != "" {
+			line("URL:" + e.URL)
+		}
+		if e.Description != "" {
+			line("DESCRIPTION:" + icalEscaper.Replace(e.Description))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// PlanICal writes the reading plan as an iCalendar feed with an all-day
+// event per planned paper
+func PlanICal(w io.Writer, plan []models.PlannedRead, now time.Time) error {
+	events := make([]CalendarEvent, 0, len(plan))
+	for _, p := range plan {
+		summary := "Read: " + p.Title
+		if p.IsRead {
+			summary = "✓ " + summary
+		}
+		events = append(events, CalendarEvent{
+			UID:         "plan-" + p.PaperID + "@arxiv-nest",
+			Summary:     summary,
+			Description: p.ArxivUrl,
+			URL:         p.ArxivUrl,
+			Start:       p.PlannedFor,
+		})
+	}
+	return ICal(w, "Reading plan", events, now)
+}
+
+// writeICalLine writes a content line, folding it into continuation lines
+// (starting with a space) so no line exceeds icalLineLimit octets. Lines
+// are only folded between UTF-8 characters.
+func writeICalLine(b *strings.Builder, s string) {
+	limit := icalLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		fmt.Fprintf(b, "%s\r\n ", s[:cut])
+		s = s[cut:]
+		// Continuation lines lose one octet to the leading space
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(s + "\r\n")
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestPlanICal(t *testing.T) {
+	plan := []models.PlannedRead{
+		{PaperID: "2401.00001", PlannedFor: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Title: "Scaling, Fast; and Cheap", ArxivUrl: "http://arxiv.org/abs/2401.00001v1"},
+		{PaperID: "2401.00002", PlannedFor: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), Title: strings.Repeat("Très long titre ", 8), IsRead: true},
+	}
+
+	var b strings.Builder
+	if err := PlanICal(&b, plan, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("PlanICal failed: %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:plan-2401.00001@arxiv-nest\r\n",
+		"DTSTAMP:20240301T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20240305\r\nDTEND;VALUE=DATE:20240306\r\n",
+		`SUMMARY:Read: Scaling\, Fast\; and Cheap` + "\r\n",
+		"URL:http://arxiv.org/abs/2401.00001v1\r\n",
+		"SUMMARY:✓ Read: Très",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q\n%s", want, out)
+		}
+	}
+
+	// Long lines are folded at 75 octets without splitting characters
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Line splits a character: %q", line)
+		}
+	}
+	if !strings.Contains(out, "\r\n ") {
+		t.Error("Expected the long summary to be folded")
+	}
+}
@@ -0,0 +1,241 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// LaTeXOptions controls the generated table
+type LaTeXOptions struct {
+	// Longtable uses the longtable environment (breaks across pages)
+	// instead of a floating table with tabular
+	Longtable bool
+	Caption   string
+	Label     string
+
+	// Notes adds a column with each library paper's "why saved" note
+	Notes bool
+}
+
+// latexEscaper escapes characters with special meaning in LaTeX text
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+)
+
+// LaTeX writes papers as a LaTeX table with title, authors, year, venue and
+// citation key columns. Citation keys follow the common lastnameYEARword
+// convention and are made unique within the table.
+func LaTeX(w io.Writer, papers []models.Paper, opts LaTeXOptions) error {
+	table, err := NewLaTeXTable(w, opts)
+	if err != nil {
+		return err
+	}
+	if err := table.WriteRows(papers); err != nil {
+		return err
+	}
+	return table.Close()
+}
+
+// LaTeXTable writes a table like LaTeX does, a batch of rows at a time, so
+// long exports can be streamed instead of held in memory
+type LaTeXTable struct {
+	w    io.Writer
+	opts LaTeXOptions
+	keys map[string]int
+}
+
+// NewLaTeXTable writes the table's preamble and header row to w
+func NewLaTeXTable(w io.Writer, opts LaTeXOptions) (*LaTeXTable, error) {
+	columns := `p{0.34\linewidth}p{0.22\linewidth}lll`
+	header := `\textbf{Title} & \textbf{Authors} & \textbf{Year} & \textbf{Venue} & \textbf{Key}`
+	if opts.Notes {
+		columns = `p{0.26\linewidth}p{0.16\linewidth}lllp{0.2\linewidth}`
+		header += ` & \textbf{Why saved}`
+	}
+	header += ` \\`
+
+	var b strings.Builder
+	if opts.Longtable {
+		b.WriteString("% Requires \\usepackage{longtable} and \\usepackage{booktabs}\n")
+		fmt.Fprintf(&b, "\\begin{longtable}{%s}\n", columns)
+		writeCaption(&b, opts)
+		b.WriteString("\\toprule\n" + header + "\n\\midrule\n\\endhead\n")
+	} else {
+		b.WriteString("% Requires \\usepackage{booktabs}\n")
+		b.WriteString("\\begin{table}[ht]\n\\centering\n\\small\n")
+		writeCaption(&b, opts)
+		fmt.Fprintf(&b, "\\begin{tabular}{%s}\n", columns)
+		b.WriteString("\\toprule\n" + header + "\n\\midrule\n")
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, err
+	}
+	return &LaTeXTable{w: w, opts: opts, keys: make(map[string]int)}, nil
+}
+
+// WriteRows writes a row per paper. Citation keys stay unique across calls.
+func (t *LaTeXTable) WriteRows(papers []models.Paper) error {
+	var b strings.Builder
+	for _, paper := range papers {
+		key := uniqueKey(CitationKey(paper), t.keys)
+		fmt.Fprintf(&b, "%s & %s & %d & %s & \\texttt{%s}",
+			latexEscaper.Replace(paper.Title),
+			latexEscaper.Replace(shortAuthors(paper.Authors)),
+			paper.PublishedAt.Year(),
+			latexEscaper.Replace(venue(paper)),
+			latexEscaper.Replace(key))
+		if t.opts.Notes {
+			b.WriteString(" & " + latexEscaper.Replace(paper.Note))
+		}
+		b.WriteString(" \\\\\n")
+	}
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// Close writes the end of the table
+func (t *LaTeXTable) Close() error {
+	end := "\\bottomrule\n\\end{tabular}\n\\end{table}\n"
+	if t.opts.Longtable {
+		end = "\\bottomrule\n\\end{longtable}\n"
+	}
+	_, err := io.WriteString(t.w, end)
+	return err
+}
+
+// writeCaption writes the caption and label lines if set
+func writeCaption(b *strings.Builder, opts LaTeXOptions) {
+	if opts.Caption != "" {
+		fmt.Fprintf(b, "\\caption{%s}", latexEscaper.Replace(opts.Caption))
+		if opts.Label != "" {
+			fmt.Fprintf(b, "\\label{%s}", opts.Label)
+		}
+		if opts.Longtable {
+			b.WriteString(` \\`)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// CitationKey builds a key like "smith2024attention" from the first
+// author's last name, the year and the first significant title word
+func CitationKey(paper models.Paper) string {
+	author := "anon"
+	if names := splitAuthors(paper.Authors); len(names) > 0 {
+		author = keyWord(lastName(names[0]))
+	}
+
+	word := ""
+	for _, w := range strings.Fields(paper.Title) {
+		w = keyWord(w)
+		if w != "" && !stopWords[w] {
+			word = w
+			break
+		}
+	}
+
+	return fmt.Sprintf("%s%d%s", author, paper.PublishedAt.Year(), word)
+}
+
+// stopWords are skipped when picking the title word of a citation key
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "on": true, "of": true, "in": true,
+	"for": true, "to": true, "and": true, "with": true, "towards": true,
+}
+
+// uniqueKey appends a, b, c... to keys that were already used
+func uniqueKey(key string, used map[string]int) string {
+	n := used[key]
+	used[key] = n + 1
+	if n == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s%c", key, 'a'+rune(n-1)%26)
+}
+
+// accentFolder maps common accented Latin letters to ASCII for citation keys
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n", "ß", "ss", "ł", "l", "ś", "s", "ź", "z", "ż", "z",
+	"č", "c", "š", "s", "ž", "z", "ř", "r", "ć", "c", "ń", "n", "ý", "y",
+)
+
+// keyWord lowercases s, folds accents and strips everything but ASCII
+// letters and digits
+func keyWord(s string) string {
+	var b strings.Builder
+	for _, r := range accentFolder.Replace(strings.ToLower(s)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitAuthors splits the comma-separated authors column
+func splitAuthors(authors string) []string {
+	var names []string
+	for _, name := range strings.Split(authors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lastName returns the final word of a full name
+func lastName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// shortAuthors formats authors as "Smith", "Smith and Jones" or "Smith et al."
+func shortAuthors(authors string) string {
+	names := splitAuthors(authors)
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return lastName(names[0])
+	case 2:
+		return lastName(names[0]) + " and " + lastName(names[1])
+	default:
+		return lastName(names[0]) + " et al."
+	}
+}
+
+// venue describes where the paper appeared; for preprints this is the
+// identifier and primary category
+func venue(paper models.Paper) string {
+	v := "arXiv:" + paper.ID
+	if paper.Source() != models.SourceArxiv {
+		_, id, _ := strings.Cut(paper.ID, ":")
+		v = paper.SourceName() + " " + id
+	}
+	if categories := strings.Split(paper.Categories, ","); categories[0] != "" {
+		v += " [" + strings.TrimSpace(categories[0]) + "]"
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestLaTeX(t *testing.T) {
+	published := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	papers := []models.Paper{
+		{ID: "2401.00001", Title: "The Attention Trick & 100% Gains", Authors: "Alice Smith, Bob Jones, Carol Lee", Categories: "cs.LG, cs.AI", PublishedAt: published},
+		{ID: "2401.00002", Title: "Attention_Is Enough", Authors: "Alice Smith", Categories: "cs.LG", PublishedAt: published},
+	}
+
+	var b strings.Builder
+	papers = append(papers, models.Paper{ID: "2401.00003", Title: "Attention Again", Authors: "Dan Smith", PublishedAt: published})
+
+	if err := LaTeX(&b, papers, LaTeXOptions{Longtable: true, Caption: "Related work"}); err != nil {
+		t.Fatalf("LaTeX failed: %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		`\begin{longtable}`,
+		`\caption{Related work} \\`,
+		`The Attention Trick \& 100\% Gains & Smith et al. & 2024 & arXiv:2401.00001 [cs.LG] & \texttt{smith2024attention} \\`,
+		`Attention\_Is Enough & Smith & 2024`,
+		`\texttt{smith2024attentionis}`,
+		`\texttt{smith2024attentiona}`,
+		`\end{longtable}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q\n%s", want, out)
+		}
+	}
+}
+
+func TestCitationKey(t *testing.T) {
+	paper := models.Paper{
+		Title:       "On the Müller Method",
+		Authors:     "José Müller",
+		PublishedAt: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if got := CitationKey(paper); got != "muller2023muller" {
+		t.Errorf("Unexpected key %q", got)
+	}
+}
+
+func TestLaTeXTable(t *testing.T) {
+	published := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	paper := models.Paper{ID: "2401.00001", Title: "Attention", Authors: "Alice Smith", PublishedAt: published}
+
+	var b strings.Builder
+	table, err := NewLaTeXTable(&b, LaTeXOptions{})
+	if err != nil {
+		t.Fatalf("NewLaTeXTable failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := table.WriteRows([]models.Paper{paper}); err != nil {
+			t.Fatalf("WriteRows failed: %v", err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Keys stay unique across chunks, and the output matches a single call
+	var want strings.Builder
+	if err := LaTeX(&want, []models.Paper{paper, paper}, LaTeXOptions{}); err != nil {
+		t.Fatalf("LaTeX failed: %v", err)
+	}
+	if b.String() != want.String() {
+		t.Errorf("Chunked table differs:\n%s\nwant:\n%s", b.String(), want.String())
+	}
+	if !strings.Contains(b.String(), `\texttt{smith2024attentiona}`) {
+		t.Errorf("Expected a suffixed key in the second chunk\n%s", b.String())
+	}
+}
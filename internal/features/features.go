@@ -0,0 +1,199 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+)
+
+// Flag names for optional subsystems
+const (
+	ReaderMode    = "reader_mode"
+	Notifications = "notifications"
+	ArchiveStats  = "archive_stats"
+	ReadingGroup  = "reading_group"
+	VenueDates    = "venue_dates"
+	Audio         = "audio"
+	LinkCheck     = "link_check"
+	Curation      = "curation"
+	UsageStats    = "usage_stats"
+)
+
+// Definition describes a feature flag and its built-in default
+type Definition struct {
+	Name        string
+	Description string
+	Default     bool
+
+	// Heavy flags cost bandwidth or background work and are kept off in
+	// lightweight mode
+	Heavy bool
+}
+
+// Definitions lists every known flag
+var Definitions = []Definition{
+	{ReaderMode, "Detect arXiv HTML renderings and offer the proxied reader mode", true, true},
+	{Notifications, "Announce newly fetched papers on the configured notification channels", true, true},
+	{ArchiveStats, "Record arXiv-wide result counts for each category and keyword after every fetch", true, true},
+	{ReadingGroup, "Assign papers to reading group members and show the presentations queue", false, false},
+	{VenueDates, "Serve an iCal feed of deadlines and dates of conferences in your categories", false, false},
+	{Audio, "Speak abstracts with the configured text-to-speech command and serve a playlist of the reading queue", true, true},
+	{LinkCheck, "Check the PDF, abstract and code links of saved papers in the background and repair broken arXiv links", true, true},
+	{Curation, "Queue newly fetched papers for curators to approve into the team feed", false, false},
+	{UsageStats, "Count page views and feature use, locally and without identifying anyone, for the Usage page", false, false},
+}
+
+// ErrLightweight is returned when enabling a heavy flag in lightweight mode
+var ErrLightweight = errors.New("feature is unavailable in lightweight mode")
+
+// State is the effective value of a flag and where it comes from
+type State struct {
+	Definition
+	Enabled    bool
+	Overridden bool
+
+	// Locked flags are held off by lightweight mode
+	Locked bool
+}
+
+// Flags resolves feature flags: runtime overrides stored in the database
+// win over config.yaml, which wins over the built-in defaults
+type Flags struct {
+	db *db.DB
+
+	mu          sync.RWMutex
+	config      map[string]bool
+	overrides   map[string]bool
+	lightweight bool
+}
+
+// New loads flags from configuration and database overrides.
+// Unknown names in the configuration are reported as an error.
+func New(configured map[string]bool, database *db.DB) (*Flags, error) {
+	for name := range configured {
+		if _, ok := lookup(name); !ok {
+			return nil, fmt.Errorf("unknown feature flag %q", name)
+		}
+	}
+
+	overrides, err := database.GetFeatureOverrides()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature overrides: %w", err)
+	}
+
+	return &Flags{
+		db:        database,
+		config:    configured,
+		overrides: overrides,
+	}, nil
+}
+
+// Enabled reports whether a feature is on. A nil Flags uses the defaults.
+func (f *Flags) Enabled(name string) bool {
+	def, _ := lookup(name)
+	if f == nil {
+		return def.Default
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.lightweight && def.Heavy {
+		return false
+	}
+	if enabled, ok := f.overrides[name]; ok {
+		return enabled
+	}
+	if enabled, ok := f.config[name]; ok {
+		return enabled
+	}
+	return def.Default
+}
+
+// SetLightweight holds every heavy flag off, whatever the configuration
+// and runtime overrides say
+func (f *Flags) SetLightweight(on bool) {
+	f.mu.Lock()
+	f.lightweight = on
+	f.mu.Unlock()
+}
+
+// Set stores a runtime override for a flag
+func (f *Flags) Set(name string, enabled bool) error {
+	def, ok := lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown feature flag %q", name)
+	}
+	if enabled && def.Heavy && f.isLightweight() {
+		return fmt.Errorf("%s: %w", name, ErrLightweight)
+	}
+	if err := f.db.SetFeatureOverride(name, enabled); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.overrides[name] = enabled
+	f.mu.Unlock()
+	return nil
+}
+
+// Reset removes a runtime override so the configured value applies again
+func (f *Flags) Reset(name string) error {
+	if err := f.db.DeleteFeatureOverride(name); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	delete(f.overrides, name)
+	f.mu.Unlock()
+	return nil
+}
+
+// All returns the state of every known flag sorted by name
+func (f *Flags) All() []State {
+	states := make([]State, 0, len(Definitions))
+	for _, def := range Definitions {
+		state := State{Definition: def, Enabled: f.Enabled(def.Name)}
+		if f != nil {
+			f.mu.RLock()
+			_, state.Overridden = f.overrides[def.Name]
+			state.Locked = f.lightweight && def.Heavy
+			f.mu.RUnlock()
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+// Map returns the effective value of every flag, for use in templates
+func (f *Flags) Map() map[string]bool {
+	m := make(map[string]bool, len(Definitions))
+	for _, def := range Definitions {
+		m[def.Name] = f.Enabled(def.Name)
+	}
+	return m
+}
+
+// isLightweight reports whether lightweight mode is on
+func (f *Flags) isLightweight() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.lightweight
+}
+
+// lookup finds a flag definition by name
+func lookup(name string) (Definition, bool) {
+	for _, def := range Definitions {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
@@ -0,0 +1,108 @@
+package features
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+)
+
+func TestFlagPrecedence(t *testing.T) {
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	flags, err := New(map[string]bool{ReaderMode: false}, testDB)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if flags.Enabled(ReaderMode) {
+		t.Error("Expected config to disable reader_mode")
+	}
+	if !flags.Enabled(Notifications) {
+		t.Error("Expected notifications to fall back to its default")
+	}
+
+	if err := flags.Set(ReaderMode, true); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if !flags.Enabled(ReaderMode) {
+		t.Error("Expected override to enable reader_mode")
+	}
+
+	// Overrides persist across restarts
+	reloaded, err := New(map[string]bool{ReaderMode: false}, testDB)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !reloaded.Enabled(ReaderMode) {
+		t.Error("Expected stored override to survive reload")
+	}
+
+	if err := reloaded.Reset(ReaderMode); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if reloaded.Enabled(ReaderMode) {
+		t.Error("Expected reset to restore the configured value")
+	}
+}
+
+func TestUnknownFlags(t *testing.T) {
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	if _, err := New(map[string]bool{"teleport": true}, testDB); err == nil {
+		t.Error("Expected error for unknown flag in config")
+	}
+
+	flags, err := New(nil, testDB)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := flags.Set("teleport", true); err == nil {
+		t.Error("Expected error when setting unknown flag")
+	}
+
+	var nilFlags *Flags
+	if !nilFlags.Enabled(ReaderMode) {
+		t.Error("Expected nil flags to use defaults")
+	}
+}
+
+func TestLightweightLocksHeavyFlags(t *testing.T) {
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	flags, err := New(map[string]bool{ReadingGroup: true}, testDB)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := flags.Set(ArchiveStats, true); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	flags.SetLightweight(true)
+	if flags.Enabled(ReaderMode) || flags.Enabled(Notifications) || flags.Enabled(ArchiveStats) {
+		t.Error("Expected heavy flags off in lightweight mode, even when overridden")
+	}
+	if !flags.Enabled(ReadingGroup) {
+		t.Error("Expected light flags to keep their configured value")
+	}
+	if err := flags.Set(ReaderMode, true); !errors.Is(err, ErrLightweight) {
+		t.Errorf("Expected ErrLightweight enabling a heavy flag, got %v", err)
+	}
+	for _, state := range flags.All() {
+		if state.Locked != state.Heavy {
+			t.Errorf("Unexpected lock state for %s: %v", state.Name, state.Locked)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// defaultBackfillPageSize is the page size of a backfill without one
+const defaultBackfillPageSize = 200
+
+// BackfillOptions controls a backfill run
+type BackfillOptions struct {
+	// PageSize is the number of results requested at a time
+	PageSize int
+
+	// Deadline stops the run at the first checkpoint after it, so a long
+	// backfill can be spread over several nights; zero runs until done
+	Deadline time.Time
+
+	// Progress is called after every checkpoint, if set
+	Progress func(*models.Backfill)
+}
+
+// Backfill harvests the days of b not yet harvested, oldest first, one day
+// and one page at a time: the API only pages reliably through a limited
+// number of results, so each day is queried on its own. Papers are stored
+// like fetched ones but not announced. The checkpoint is saved after every
+// page, so a run stopped by an error, ctx or the deadline resumes where it
+// stopped; b.Done reports whether the backfill is complete.
+func (f *Fetcher) Backfill(ctx context.Context, b *models.Backfill, opts BackfillOptions) error {
+	if f.client == nil {
+		return errors.New("backfilling is disabled: there is no arXiv client")
+	}
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultBackfillPageSize
+	}
+
+	run := models.FetchRun{Scope: "backfill:" + b.Category, StartedAt: time.Now()}
+	fetched, stored := b.Fetched, b.Stored
+	err := f.backfill(ctx, b, pageSize, opts)
+
+	run.FinishedAt = time.Now()
+	run.Fetched, run.Stored = b.Fetched-fetched, b.Stored-stored
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if err := f.db.RecordFetchRun(run); err != nil {
+		log.Printf("Error recording fetch run: %v", err)
+	}
+	return err
+}
+
+// backfill does the work of Backfill
+func (f *Fetcher) backfill(ctx context.Context, b *models.Backfill, pageSize int, opts BackfillOptions) error {
+	for !b.NextDay.After(b.ToDay) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !opts.Deadline.IsZero() && time.Now().After(opts.Deadline) {
+			return nil
+		}
+
+		day := b.NextDay
+		feed, err := f.client.FetchNew(ctx, arxiv.FetchParams{
+			Categories:    []string{b.Category},
+			MaxResults:    pageSize,
+			Start:         b.NextStart,
+			SortBy:        "submittedDate",
+			SortOrder:     "ascending",
+			SubmittedFrom: day,
+			SubmittedTo:   day.Add(24*time.Hour - time.Minute),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s for %s from offset %d: %w", b.Category, day.Format("2006-01-02"), b.NextStart, err)
+		}
+
+		// The API now and then answers with an empty page in the middle of
+		// the results; stop rather than skip the rest of the day
+		if len(feed.Entries) == 0 && b.NextStart < feed.TotalResults {
+			return fmt.Errorf("arXiv returned an empty page for %s at offset %d of %d; try again later",
+				day.Format("2006-01-02"), b.NextStart, feed.TotalResults)
+		}
+
+		papers, err := feed.ToPapers()
+		if err != nil {
+			return fmt.Errorf("failed to parse papers: %w", err)
+		}
+		result := &Result{}
+		cfg, _ := f.settings()
+		f.store(papers, cfg.ArXiv.Keywords, cfg.ArXiv.SubscriptionTags, result)
+		if err := f.db.RecordNewPapers(result.New, time.Now()); err != nil {
+			log.Printf("Error updating stats rollups: %v", err)
+		}
+
+		b.Fetched += result.Fetched
+		b.Stored += result.Stored
+		b.NextStart += len(feed.Entries)
+		if len(feed.Entries) < pageSize || (feed.TotalResults > 0 && b.NextStart >= feed.TotalResults) {
+			b.NextDay, b.NextStart = day.AddDate(0, 0, 1), 0
+		}
+
+		if err := f.db.SaveBackfill(b); err != nil {
+			return err
+		}
+		if opts.Progress != nil {
+			opts.Progress(b)
+		}
+	}
+	return nil
+}
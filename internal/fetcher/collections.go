@@ -0,0 +1,71 @@
+package fetcher
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// announceTimeout bounds announcing the papers of one write to the tags
+// and shelves they were added to
+const announceTimeout = time.Minute
+
+// AnnounceCollections announces papers newly added to a shared tag or a
+// shelf on the notification channels subscribed to it, whoever added
+// them: someone in the browser, a fetch applying subscription tags, an
+// API client. Additions are announced in the background, as the database
+// reports them, while the notifications feature is on.
+func (f *Fetcher) AnnounceCollections() {
+	f.db.OnChange(func(c db.Change) {
+		if len(c.Collected) == 0 {
+			return
+		}
+		f.announcing.Add(1)
+		go func() {
+			defer f.announcing.Done()
+			f.announceCollected(c.Collected)
+		}()
+	})
+}
+
+// announceCollected sends the announcements of papers added to tags and
+// shelves to the channels subscribed to each
+func (f *Fetcher) announceCollected(collected []db.Collected) {
+	_, notifier := f.settings()
+	if !notifier.Enabled() || !f.features.Enabled(features.Notifications) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), announceTimeout)
+	defer cancel()
+	for _, c := range collected {
+		sub, err := f.db.GetCollectionSubscription(c.Kind, c.ID)
+		if err == sql.ErrNoRows {
+			// A personal tag, or a shelf deleted since
+			continue
+		}
+		if err != nil {
+			log.Printf("Error fetching subscriptions of %s %d: %v", c.Kind, c.ID, err)
+			continue
+		}
+		if len(sub.Channels) == 0 {
+			continue
+		}
+
+		var papers []*models.Paper
+		for _, id := range c.Papers {
+			paper, err := f.db.GetPaperByID(id)
+			if err != nil {
+				log.Printf("Error fetching paper %s to announce: %v", id, err)
+				continue
+			}
+			papers = append(papers, paper)
+		}
+		notifier.NotifyCollected(ctx, sub.Channels, sub.Kind+" "+sub.Name, papers)
+	}
+}
@@ -0,0 +1,483 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/hooks"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+	"github.com/ngx/arxiv-go-nest/internal/sources"
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+	"github.com/ngx/arxiv-go-nest/internal/venues"
+)
+
+// Fetcher fetches papers from arXiv, stores them and announces new ones.
+// It is shared by the CLI fetch command, the scheduler and the refresh endpoint.
+type Fetcher struct {
+	// mu guards config and notifier, which Reload replaces
+	mu       sync.RWMutex
+	config   *config.Config
+	notifier *notify.Notifier
+
+	db       *db.DB
+	client   *arxiv.Client
+	features *features.Flags
+	venues   *venues.Catalog
+	hooks    *hooks.Runner
+	sources  *sources.Registry
+
+	// announcing counts the announcements of papers added to tags and
+	// shelves still being sent
+	announcing sync.WaitGroup
+}
+
+// Result summarizes a fetch run
+type Result struct {
+	Fetched int
+	Stored  int
+	New     []*models.Paper
+
+	// Unchanged counts stored papers whose content had not changed, which
+	// were only marked as seen
+	Unchanged int
+}
+
+// New creates a fetcher. The notifier and flags may be nil.
+func New(cfg *config.Config, database *db.DB, client *arxiv.Client, notifier *notify.Notifier, flags *features.Flags) *Fetcher {
+	return &Fetcher{
+		config:   cfg,
+		db:       database,
+		client:   client,
+		notifier: notifier,
+		features: flags,
+	}
+}
+
+// SetVenues sets the catalog used to detect conferences in the comments
+// of stored papers; without one no venues are recorded
+func (f *Fetcher) SetVenues(c *venues.Catalog) {
+	f.venues = c
+}
+
+// SetHooks sets the runner for fetch.completed hooks
+func (f *Fetcher) SetHooks(r *hooks.Runner) {
+	f.hooks = r
+}
+
+// SetSources sets the preprint servers Import fetches papers from
+func (f *Fetcher) SetSources(r *sources.Registry) {
+	f.sources = r
+}
+
+// Reload switches to the subscriptions and fetch settings of cfg and to a
+// new notifier. A fetch already running finishes with the old ones. The
+// old notifier's pending digests are sent right away rather than lost.
+func (f *Fetcher) Reload(ctx context.Context, cfg *config.Config, notifier *notify.Notifier) {
+	f.mu.Lock()
+	old := f.notifier
+	f.config, f.notifier = cfg, notifier
+	f.mu.Unlock()
+
+	if old != notifier {
+		old.Flush(ctx)
+	}
+}
+
+// settings returns the configuration and notifier in effect
+func (f *Fetcher) settings() (*config.Config, *notify.Notifier) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config, f.notifier
+}
+
+// Venues returns the venue catalog, or nil if none was set
+func (f *Fetcher) Venues() *venues.Catalog {
+	return f.venues
+}
+
+// Subscription is a single configured category or keyword, with the tags
+// applied to the papers it brings in
+type Subscription struct {
+	Kind  string // "category" or "keyword"
+	Value string
+	Tags  []string
+}
+
+// Subscriptions returns the configured categories and keywords
+func (f *Fetcher) Subscriptions() []Subscription {
+	cfg, _ := f.settings()
+	var subs []Subscription
+	for _, cat := range cfg.ArXiv.Categories {
+		subs = append(subs, Subscription{Kind: "category", Value: cat, Tags: cfg.ArXiv.SubscriptionTags[cat]})
+	}
+	for _, kw := range cfg.ArXiv.Keywords {
+		subs = append(subs, Subscription{Kind: "keyword", Value: kw, Tags: cfg.ArXiv.SubscriptionTags[kw]})
+	}
+	return subs
+}
+
+// Notifier returns the notifier papers are announced with, which a
+// reload may replace
+func (f *Fetcher) Notifier() *notify.Notifier {
+	_, notifier := f.settings()
+	return notifier
+}
+
+// Categories returns the subscribed categories
+func (f *Fetcher) Categories() []string {
+	cfg, _ := f.settings()
+	return cfg.ArXiv.Categories
+}
+
+// Run fetches the configured categories and keywords and stores the results
+func (f *Fetcher) Run(ctx context.Context) (*Result, error) {
+	cfg, _ := f.settings()
+	result, err := f.fetch(ctx, "all", cfg.ArXiv.Categories, cfg.ArXiv.Keywords)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.features.Enabled(features.ArchiveStats) {
+		f.recordArchiveStats(ctx, cfg)
+	}
+
+	return result, nil
+}
+
+// RunSubscription fetches and stores the papers for one subscription only
+func (f *Fetcher) RunSubscription(ctx context.Context, sub Subscription) (*Result, error) {
+	switch sub.Kind {
+	case "category":
+		return f.fetch(ctx, sub.Kind+":"+sub.Value, []string{sub.Value}, nil)
+	case "keyword":
+		return f.fetch(ctx, sub.Kind+":"+sub.Value, nil, []string{sub.Value})
+	}
+	return nil, fmt.Errorf("unknown subscription kind %q", sub.Kind)
+}
+
+// Import fetches papers by ID, DOI or link from the preprint servers set
+// with SetSources and stores them like fetched papers, without announcing
+// them. It returns the fetched papers, and an *sources.ImportError for the
+// references it couldn't import. Papers in the trash stay there.
+func (f *Fetcher) Import(ctx context.Context, refs []string) ([]*models.Paper, error) {
+	if f.sources == nil {
+		return nil, errors.New("importing is disabled: there are no sources")
+	}
+
+	papers, err := f.sources.Import(ctx, refs)
+	result := &Result{}
+	cfg, _ := f.settings()
+	f.store(papers, cfg.ArXiv.Keywords, nil, result)
+	if err := f.db.RecordNewPapers(result.New, time.Now()); err != nil {
+		log.Printf("Error updating stats rollups: %v", err)
+	}
+	return papers, err
+}
+
+// fetch fetches papers matching the categories and keywords, stores them
+// and announces the new ones. The run is recorded in the fetch history
+// under scope.
+func (f *Fetcher) fetch(ctx context.Context, scope string, categories, keywords []string) (*Result, error) {
+	if f.client == nil {
+		return nil, errors.New("fetching is disabled: there is no arXiv client")
+	}
+
+	run := models.FetchRun{Scope: scope, StartedAt: time.Now()}
+
+	ctx, span := telemetry.Start(ctx, "fetch "+scope, telemetry.KindInternal)
+	result, err := f.fetchAndStore(ctx, categories, keywords)
+	span.SetError(err)
+	span.End()
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		run.Fetched, run.Stored, run.New = result.Fetched, result.Stored, len(result.New)
+	}
+	if err := f.db.RecordFetchRun(run); err != nil {
+		log.Printf("Error recording fetch run: %v", err)
+	}
+
+	fetch := &hooks.Fetch{Scope: scope, Fetched: run.Fetched, Stored: run.Stored, New: []string{}, Error: run.Error}
+	if result != nil {
+		for _, p := range result.New {
+			fetch.New = append(fetch.New, p.ID)
+		}
+	}
+	f.hooks.Fire(hooks.Event{Name: hooks.FetchCompleted, Fetch: fetch})
+
+	return result, err
+}
+
+// fetchAndStore does the work of fetch. With arxiv.page_size set, results
+// are requested a page at a time, newest first, until a page brings no new
+// papers, so routine runs on small servers only download what is new.
+func (f *Fetcher) fetchAndStore(ctx context.Context, categories, keywords []string) (*Result, error) {
+	cfg, notifier := f.settings()
+	maxResults := cfg.ArXiv.MaxResults
+	pageSize := cfg.ArXiv.PageSize
+	if pageSize <= 0 || pageSize > maxResults {
+		pageSize = maxResults
+	}
+
+	result := &Result{}
+	for start := 0; start < maxResults; start += pageSize {
+		params := arxiv.FetchParams{
+			Categories: categories,
+			Keywords:   keywords,
+			MaxResults: min(pageSize, maxResults-start),
+			Start:      start,
+			SortBy:     "submittedDate",
+			SortOrder:  "descending",
+		}
+
+		feed, err := f.client.FetchNew(ctx, params)
+		if err != nil {
+			if start == 0 {
+				return nil, fmt.Errorf("failed to fetch papers: %w", err)
+			}
+			// Keep what the earlier pages stored; the next run catches up
+			log.Printf("Error fetching papers from offset %d: %v", start, err)
+			break
+		}
+
+		papers, err := feed.ToPapers()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse papers: %w", err)
+		}
+
+		newBefore := len(result.New)
+		f.store(papers, cfg.ArXiv.Keywords, cfg.ArXiv.SubscriptionTags, result)
+
+		if len(feed.Entries) < params.MaxResults || len(result.New) == newBefore {
+			break
+		}
+	}
+
+	if err := f.db.RecordNewPapers(result.New, time.Now()); err != nil {
+		log.Printf("Error updating stats rollups: %v", err)
+	}
+
+	if f.features.Enabled(features.Curation) && len(result.New) > 0 {
+		ids := make([]string, len(result.New))
+		for i, p := range result.New {
+			ids[i] = p.ID
+		}
+		if _, err := f.db.QueueForReview(ids, time.Now()); err != nil {
+			log.Printf("Error queueing papers for review: %v", err)
+		}
+	}
+
+	if f.features.Enabled(features.Notifications) {
+		notifier.NotifyPapers(ctx, result.New)
+	}
+
+	return result, nil
+}
+
+// store saves fetched papers, recording the subscription keywords each
+// matches, and adds them to the result. Papers new to the database get the
+// default tags of the subscriptions they match.
+func (f *Fetcher) store(papers []*models.Paper, keywords []string, tags map[string][]string, result *Result) {
+	result.Fetched += len(papers)
+	tagIDs := make(map[string]int)
+	for _, paper := range papers {
+		// Deleted papers stay deleted until restored from the trash
+		trashed, err := f.db.IsTrashed(paper.ID)
+		if err != nil {
+			log.Printf("Error checking trash for paper %s: %v", paper.ID, err)
+			continue
+		}
+		if trashed {
+			continue
+		}
+
+		exists, err := f.db.PaperExists(paper.ID)
+		if err != nil {
+			log.Printf("Error checking paper %s: %v", paper.ID, err)
+			continue
+		}
+
+		changed, err := f.db.UpsertPaperStatus(paper)
+		if err != nil {
+			log.Printf("Error inserting paper %s: %v", paper.ID, err)
+			continue
+		}
+		if !changed {
+			result.Unchanged++
+		}
+
+		matched := matchKeywords(paper, keywords)
+		if len(matched) > 0 {
+			if err := f.db.AddPaperKeywords(paper.ID, matched); err != nil {
+				log.Printf("Error recording keywords for paper %s: %v", paper.ID, err)
+			}
+		}
+
+		if !exists {
+			f.tagPaper(paper.ID, defaultTags(tags, paper, matched), tagIDs)
+		}
+
+		if f.venues != nil {
+			mentions := f.venues.Detect(paper.Comment, paper.PublishedAt.Year())
+			if err := f.db.SetPaperVenues(paper.ID, mentions); err != nil {
+				log.Printf("Error recording venues for paper %s: %v", paper.ID, err)
+			}
+		}
+
+		result.Stored++
+		if !exists {
+			result.New = append(result.New, paper)
+		}
+	}
+}
+
+// defaultTags returns the tags configured for the subscriptions a paper
+// matches: the categories it is listed in and the keywords it matched
+func defaultTags(tags map[string][]string, paper *models.Paper, keywords []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var subs []string
+	for _, category := range strings.Split(paper.Categories, ",") {
+		subs = append(subs, strings.TrimSpace(category))
+	}
+	subs = append(subs, keywords...)
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, sub := range subs {
+		for _, name := range tags[sub] {
+			name = strings.TrimSpace(name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// tagPaper applies shared tags to a paper, creating the tags as needed.
+// ids caches tag IDs by name across the papers of a run.
+func (f *Fetcher) tagPaper(paperID string, names []string, ids map[string]int) {
+	for _, name := range names {
+		id, ok := ids[name]
+		if !ok {
+			var err error
+			if id, err = f.db.CreateTag(name); err != nil {
+				log.Printf("Error creating default tag %q: %v", name, err)
+				continue
+			}
+			ids[name] = id
+		}
+		if err := f.db.TagPaper(paperID, id); err != nil {
+			log.Printf("Error tagging paper %s with %q: %v", paperID, name, err)
+		}
+	}
+}
+
+// FlushNotifications sends pending notification digests right away, after
+// the announcements of papers added to tags and shelves under way
+func (f *Fetcher) FlushNotifications(ctx context.Context) {
+	f.announcing.Wait()
+	_, notifier := f.settings()
+	notifier.Flush(ctx)
+}
+
+// matchKeywords returns the subscription keywords a paper matches: every
+// word of the keyword appears as a word of its title or abstract, ignoring
+// case, so "AI" matches "explainable AI" but not "said"
+func matchKeywords(paper *models.Paper, keywords []string) []string {
+	text := strings.ToLower(paper.Title + " " + paper.Abstract)
+
+	var matched []string
+	for _, keyword := range keywords {
+		words := strings.Fields(strings.ToLower(keyword))
+		if len(words) == 0 {
+			continue
+		}
+
+		found := true
+		for _, word := range words {
+			if !containsWord(text, word) {
+				found = false
+				break
+			}
+		}
+		if found {
+			matched = append(matched, strings.TrimSpace(keyword))
+		}
+	}
+	return matched
+}
+
+// containsWord reports whether word appears in text with no letter or
+// digit right before or after it
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		start = i + size
+	}
+}
+
+// isWordRune reports whether r is part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// recordArchiveStats records the archive-wide result count for each
+// configured category and keyword. Failures are logged and skipped, since
+// the statistics are informational only.
+func (f *Fetcher) recordArchiveStats(ctx context.Context, cfg *config.Config) {
+	type topic struct {
+		name   string
+		params arxiv.FetchParams
+	}
+
+	var topics []topic
+	for _, cat := range cfg.ArXiv.Categories {
+		topics = append(topics, topic{cat, arxiv.FetchParams{Categories: []string{cat}}})
+	}
+	for _, kw := range cfg.ArXiv.Keywords {
+		topics = append(topics, topic{kw, arxiv.FetchParams{Keywords: []string{kw}}})
+	}
+
+	for _, t := range topics {
+		total, err := f.client.CountResults(ctx, t.params)
+		if err != nil {
+			log.Printf("Error counting results for %s: %v", t.name, err)
+			continue
+		}
+
+		query := f.client.SearchQuery(t.params)
+		if err := f.db.RecordArchiveStat(t.name, query, total); err != nil {
+			log.Printf("Error recording stats for %s: %v", t.name, err)
+		}
+	}
+}
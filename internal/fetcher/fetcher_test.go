@@ -0,0 +1,416 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+	"github.com/ngx/arxiv-go-nest/internal/venues"
+)
+
+const sampleFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <id>http://arxiv.org/abs/2301.12345v1</id>
+    <updated>2023-01-25T12:00:00Z</updated>
+    <published>2023-01-25T12:00:00Z</published>
+    <title>Test Paper Title</title>
+    <summary>We propose a new method for training large language models efficiently. It works well.</summary>
+    <author><name>John Doe</name></author>
+    <arxiv:comment xmlns:arxiv="http://arxiv.org/schemas/atom">Accepted at ICLR 2025; 9 pages</arxiv:comment>
+    <link href="http://arxiv.org/abs/2301.12345v1" rel="alternate" type="text/html"/>
+    <link title="pdf" href="http://arxiv.org/pdf/2301.12345v1" rel="related" type="application/pdf"/>
+    <category term="cs.AI" scheme="http://arxiv.org/schemas/atom"/>
+  </entry>
+</feed>`
+
+func setupTestFetcher(t *testing.T) (*Fetcher, *[]string) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sampleFeed))
+	}))
+	t.Cleanup(api.Close)
+
+	var received []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		received = append(received, payload.Text)
+	}))
+	t.Cleanup(hook.Close)
+
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	cfg := &config.Config{
+		ArXiv: config.ArXivConfig{
+			Categories: []string{"cs.AI"},
+			MaxResults: 10,
+		},
+		Notifications: config.NotificationsConfig{
+			Excerpt: true,
+			Channels: []config.NotificationChannel{
+				{Name: "test", Type: "webhook", URL: hook.URL},
+			},
+		},
+	}
+
+	client := arxiv.NewClient(0)
+	client.SetBaseURLs([]string{api.URL}, 1)
+
+	notifier, err := notify.New(cfg.Notifications)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+
+	return New(cfg, testDB, client, notifier, nil), &received
+}
+
+func TestRunNotifiesNewPapersOnce(t *testing.T) {
+	f, received := setupTestFetcher(t)
+
+	result, err := f.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Fetched != 1 || result.Stored != 1 || len(result.New) != 1 {
+		t.Errorf("Expected 1 fetched, stored and new paper, got %d/%d/%d", result.Fetched, result.Stored, len(result.New))
+	}
+
+	if len(*received) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(*received))
+	}
+	text := (*received)[0]
+	if !strings.Contains(text, "We propose a new method for training large language models efficiently.") {
+		t.Errorf("Expected excerpt in notification, got %q", text)
+	}
+	if strings.Contains(text, "It works well.") {
+		t.Errorf("Expected only the lead sentence in notification, got %q", text)
+	}
+
+	// A second run sees the same paper again, which is no longer new
+	result, err = f.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.New) != 0 {
+		t.Errorf("Expected no new papers on second run, got %d", len(result.New))
+	}
+	if result.Unchanged != 1 {
+		t.Errorf("Expected the refetched paper to be unchanged, got %d", result.Unchanged)
+	}
+	if len(*received) != 1 {
+		t.Errorf("Expected no further notifications, got %d", len(*received))
+	}
+}
+
+func TestRunSuggestsMatchedKeywords(t *testing.T) {
+	f, _ := setupTestFetcher(t)
+	f.config.ArXiv.Keywords = []string{"Large Language Models", "diffusion"}
+
+	if _, err := f.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	suggestions, err := f.db.GetSuggestedTags("2301.12345")
+	if err != nil {
+		t.Fatalf("GetSuggestedTags failed: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0] != "Large Language Models" {
+		t.Fatalf("Expected the matched keyword as suggestion, got %v", suggestions)
+	}
+
+	// Accepting the suggestion as a tag (in any case) removes it
+	tagID, _ := f.db.CreateTag("large language models")
+	f.db.TagPaper("2301.12345", tagID)
+
+	paper, err := f.db.GetPaperByID("2301.12345")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if len(paper.Suggestions) != 0 {
+		t.Errorf("Expected no suggestions once tagged, got %v", paper.Suggestions)
+	}
+}
+
+func TestRunAppliesSubscriptionTags(t *testing.T) {
+	f, _ := setupTestFetcher(t)
+	f.config.ArXiv.Keywords = []string{"Large Language Models", "diffusion"}
+	f.config.ArXiv.SubscriptionTags = map[string][]string{
+		"cs.AI":                 {"ai", "feed"},
+		"Large Language Models": {"llm", " ai "},
+		"diffusion":             {"generative"},
+	}
+
+	if _, err := f.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	tags, err := f.db.GetPaperTags("2301.12345")
+	if err != nil {
+		t.Fatalf("GetPaperTags failed: %v", err)
+	}
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	if strings.Join(names, ",") != "ai,feed,llm" {
+		t.Fatalf("Expected the tags of the matching subscriptions, got %v", names)
+	}
+
+	// Only new papers are tagged, so a removed tag stays removed
+	f.db.UntagPaper("2301.12345", tags[1].ID)
+	if _, err := f.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if tags, _ := f.db.GetPaperTags("2301.12345"); len(tags) != 2 {
+		t.Errorf("Expected the removed tag not to come back, got %+v", tags)
+	}
+}
+
+func TestRunRecordsVenues(t *testing.T) {
+	f, _ := setupTestFetcher(t)
+	catalog, err := venues.Load("")
+	if err != nil {
+		t.Fatalf("venues.Load failed: %v", err)
+	}
+	f.SetVenues(catalog)
+
+	if _, err := f.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	paper, err := f.db.GetPaperByID("2301.12345")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if paper.Comment != "Accepted at ICLR 2025; 9 pages" {
+		t.Errorf("Expected the arXiv comment to be stored, got %q", paper.Comment)
+	}
+	if len(paper.Venues) != 1 || paper.Venues[0].Venue != "ICLR" || paper.Venues[0].Year != 2025 {
+		t.Errorf("Expected ICLR 2025 as venue, got %v", paper.Venues)
+	}
+
+	mentioned, err := f.db.GetMentionedVenues()
+	if err != nil || len(mentioned) != 1 || mentioned[0] != "ICLR" {
+		t.Errorf("Expected ICLR among mentioned venues, got %v (%v)", mentioned, err)
+	}
+}
+
+func TestPagedFetchStopsAtKnownPapers(t *testing.T) {
+	var starts []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		starts = append(starts, r.URL.Query().Get("start"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("max_results"))
+
+		// Five papers in total, newest first
+		var entries strings.Builder
+		for i := start; i < start+size && i < 5; i++ {
+			fmt.Fprintf(&entries, `<entry><id>http://arxiv.org/abs/2301.0000%dv1</id><published>2023-01-25T12:00:00Z</published><updated>2023-01-25T12:00:00Z</updated><title>Paper %d</title><summary>Abstract %d.</summary><author><name>A</name></author><category term="cs.AI"/></entry>`, i, i, i)
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><feed xmlns="http://www.w3.org/2005/Atom">%s</feed>`, entries.String())
+	}))
+	defer api.Close()
+
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	client := arxiv.NewClient(0)
+	client.SetBaseURLs([]string{api.URL}, 1)
+	cfg := &config.Config{ArXiv: config.ArXivConfig{MaxResults: 10, PageSize: 2}}
+	f := New(cfg, testDB, client, nil, nil)
+	sub := Subscription{Kind: "category", Value: "cs.AI"}
+
+	result, err := f.RunSubscription(context.Background(), sub)
+	if err != nil {
+		t.Fatalf("RunSubscription failed: %v", err)
+	}
+	if len(result.New) != 5 || result.Fetched != 5 {
+		t.Errorf("Expected 5 new papers, got %d of %d fetched", len(result.New), result.Fetched)
+	}
+	if strings.Join(starts, ",") != ",2,4" {
+		t.Errorf("Expected pages at offsets 0, 2 and 4, got %q", starts)
+	}
+
+	// Nothing new on the first page, so the next run stops there
+	starts = nil
+	result, err = f.RunSubscription(context.Background(), sub)
+	if err != nil {
+		t.Fatalf("RunSubscription failed: %v", err)
+	}
+	if len(starts) != 1 || len(result.New) != 0 {
+		t.Errorf("Expected a single page and no new papers, got %d pages and %d new", len(starts), len(result.New))
+	}
+}
+
+func TestBackfillResumesFromCheckpoint(t *testing.T) {
+	// Papers per submission day; the third day fails until failing is cleared
+	perDay := map[string]int{"20230101": 3, "20230102": 0, "20230103": 2}
+	failing := true
+	var requests []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("search_query")
+		day := query[strings.Index(query, "[")+1:][:8]
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("max_results"))
+		requests = append(requests, fmt.Sprintf("%s+%d", day, start))
+		if day == "20230103" && failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var entries strings.Builder
+		for i := start; i < start+size && i < perDay[day]; i++ {
+			fmt.Fprintf(&entries, `<entry><id>http://arxiv.org/abs/%s.%05dv1</id><published>2023-01-01T12:00:00Z</published><updated>2023-01-01T12:00:00Z</updated><title>Paper</title><summary>Abstract.</summary><author><name>A</name></author><category term="cs.AI"/></entry>`, day[2:6], i+100*int(day[7]-'0'))
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><feed xmlns="http://www.w3.org/2005/Atom" xmlns:opensearch="http://a9.com/-/spec/opensearch/1.1/"><opensearch:totalResults>%d</opensearch:totalResults>%s</feed>`, perDay[day], entries.String())
+	}))
+	defer api.Close()
+
+	testDB, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	client := arxiv.NewClient(0)
+	client.SetBaseURLs([]string{api.URL}, 1)
+	f := New(&config.Config{}, testDB, client, nil, nil)
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+	b, err := testDB.StartBackfill("cs.AI", from, to)
+	if err != nil {
+		t.Fatalf("StartBackfill failed: %v", err)
+	}
+	if err := f.Backfill(context.Background(), b, BackfillOptions{PageSize: 2}); err == nil {
+		t.Fatal("Expected the failing day to stop the backfill")
+	}
+	if got := strings.Join(requests, ","); got != "20230101+0,20230101+2,20230102+0,20230103+0" {
+		t.Errorf("Unexpected requests %s", got)
+	}
+
+	// Running again starts from the saved checkpoint
+	failing, requests = false, nil
+	b, err = testDB.StartBackfill("cs.AI", from, to)
+	if err != nil {
+		t.Fatalf("StartBackfill failed: %v", err)
+	}
+	if !b.NextDay.Equal(to) || b.Stored != 3 {
+		t.Errorf("Expected the checkpoint at the third day with 3 papers stored, got %s and %d", b.NextDay, b.Stored)
+	}
+	if err := f.Backfill(context.Background(), b, BackfillOptions{PageSize: 2}); err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+	if got := strings.Join(requests, ","); got != "20230103+0" {
+		t.Errorf("Expected only the remaining day to be requested, got %s", got)
+	}
+	if count, _ := testDB.GetPaperCount(); !b.Done() || b.Stored != 5 || count != 5 {
+		t.Errorf("Expected a finished backfill of 5 papers, got done=%v stored=%d count=%d", b.Done(), b.Stored, count)
+	}
+}
+
+func TestReload(t *testing.T) {
+	f, received := setupTestFetcher(t)
+
+	// Hold the announcement back in a digest
+	cfg := *f.config
+	cfg.Notifications.Channels = []config.NotificationChannel{{Name: "test", Type: "webhook", URL: cfg.Notifications.Channels[0].URL, Batch: time.Hour}}
+	batching, err := notify.New(cfg.Notifications)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+	f.Reload(context.Background(), &cfg, batching)
+	if _, err := f.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(*received) != 0 {
+		t.Fatalf("Expected the paper to wait for the digest, got %d notifications", len(*received))
+	}
+
+	// Reloading sends the pending digest and switches subscriptions
+	reloaded := cfg
+	reloaded.ArXiv.Categories = []string{"cs.LG"}
+	reloaded.ArXiv.Keywords = []string{"transformers"}
+	f.Reload(context.Background(), &reloaded, nil)
+	if len(*received) != 1 {
+		t.Errorf("Expected the digest to be sent on reload, got %d notifications", len(*received))
+	}
+	subs := f.Subscriptions()
+	if len(subs) != 2 || subs[0].Value != "cs.LG" || subs[1].Value != "transformers" {
+		t.Errorf("Expected the reloaded subscriptions, got %+v", subs)
+	}
+}
+
+func TestAnnounceCollections(t *testing.T) {
+	f, received := setupTestFetcher(t)
+	f.AnnounceCollections()
+	if _, err := f.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	*received = nil
+
+	tagID, _ := f.db.CreateTag("must-read")
+	otherID, _ := f.db.CreateTag("later")
+	if err := f.db.SetCollectionSubscription(models.CollectionTag, tagID, []string{"test"}); err != nil {
+		t.Fatalf("SetCollectionSubscription failed: %v", err)
+	}
+
+	f.db.TagPaper("2301.12345", otherID)
+	f.db.TagPaper("2301.12345", tagID)
+	f.db.TagPaper("2301.12345", tagID)
+	f.FlushNotifications(context.Background())
+
+	if len(*received) != 1 {
+		t.Fatalf("Expected one announcement, got %q", *received)
+	}
+	if !strings.HasPrefix((*received)[0], "Added to tag must-read\n") || !strings.Contains((*received)[0], "Test Paper Title") {
+		t.Errorf("Expected the paper announced as added to must-read, got %q", (*received)[0])
+	}
+}
+
+func TestMatchKeywords(t *testing.T) {
+	paper := &models.Paper{
+		Title:    "Explainable AI for GPT-4 agents",
+		Abstract: "As the authors said, large language models plan poorly.",
+	}
+	tests := []struct {
+		keyword string
+		want    bool
+	}{
+		{"AI", true},
+		{"explainable ai", true},
+		{"GPT-4", true},
+		{"large language models", true},
+		{"language model", false},
+		{"aid", false},
+		{"plan", true},
+		{"GPT", true},
+		{"agent", false},
+	}
+
+	for _, tt := range tests {
+		if got := len(matchKeywords(paper, []string{tt.keyword})) == 1; got != tt.want {
+			t.Errorf("matchKeywords(%q) = %v, want %v", tt.keyword, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package fixtures generates a plausible library for the preview server:
+// papers across a few categories and abstract lengths, saved papers with
+// priorities and notes, tags, shelves, a reading plan, reading group
+// assignments, relations, notification deliveries and a trashed paper. The
+// data is the same on every run, so a page looks the same after each reload.
+package fixtures
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/venues"
+)
+
+const (
+	// PaperCount is the number of papers Seed stores
+	PaperCount = 60
+
+	// MaxPapers is the most papers Papers generates, past which their IDs
+	// would no longer be arXiv IDs
+	MaxPapers = 650
+)
+
+var (
+	categories = []string{"cs.LG", "cs.CL", "cs.CV", "cs.AI", "stat.ML"}
+
+	adjectives = []string{"Efficient", "Scalable", "Robust", "Sparse", "Contrastive", "Self-Supervised", "Federated", "Causal", "Equivariant", "Hierarchical"}
+	methods    = []string{"Transformers", "Diffusion Models", "Graph Networks", "State Space Models", "Mixture of Experts", "Retrieval", "Distillation", "Prompt Tuning"}
+	tasks      = []string{"Long-Context Reasoning", "Image Segmentation", "Machine Translation", "Depth Estimation", "Code Generation", "Time Series Forecasting", "Protein Folding", "Speech Recognition"}
+
+	firstNames = []string{"Alice", "Bo", "Chiara", "Dmitri", "Elena", "Farid", "Grace", "Hiroshi", "Ines", "Jonas", "Kwame", "Lucía", "Mei", "Nikhil", "Olga", "Pierre"}
+	lastNames  = []string{"Smith", "Zhang", "Rossi", "Ivanov", "García", "Haddad", "Okafor", "Tanaka", "Müller", "Kowalski", "Nguyen", "Patel", "Silva", "Dubois"}
+
+	datasets = []string{"ImageNet", "KITTI", "GLUE", "COCO", "SQuAD", "CIFAR-10", "LibriSpeech"}
+
+	sentences = []string{
+		"We study %s for %s and show that existing approaches leave substantial headroom.",
+		"Our method combines a lightweight encoder with a learned routing scheme, reducing compute by up to 40%%.",
+		"Experiments on %s demonstrate consistent improvements over strong baselines.",
+		"We further analyze the failure modes of prior work and propose a simple fix that requires no additional data.",
+		"Theoretical results establish convergence under mild assumptions, with a rate of $O(1/\\sqrt{T})$.",
+		"Ablations reveal that the gains come primarily from the improved training objective rather than model size.",
+		"We release a benchmark suite covering twelve tasks to encourage reproducible comparisons.",
+		"Finally, we discuss limitations and directions for future work, including multilingual and low-resource settings.",
+	}
+
+	licenses = []string{
+		"http://creativecommons.org/licenses/by/4.0/",
+		"http://arxiv.org/licenses/nonexclusive-distrib/1.0/",
+		"http://creativecommons.org/publicdomain/zero/1.0/",
+		"",
+	}
+
+	comments = []string{"", "", "Accepted at NeurIPS 2024", "12 pages, 5 figures", "Code: https://github.com/example/nest-fixture", "ICML 2024 camera-ready"}
+
+	notes = []string{"", "Strong baseline to compare against", "Check the ablation in Section 5", "Related to the thesis chapter on routing", ""}
+)
+
+// Seed fills an empty database with generated papers and library state,
+// dated relative to now
+func Seed(d *db.DB, now time.Time) error {
+	rng := rand.New(rand.NewSource(1))
+	catalog, err := venues.Load("")
+	if err != nil {
+		return err
+	}
+
+	papers := make([]*models.Paper, PaperCount)
+	for i := range papers {
+		papers[i] = paper(rng, i, now)
+		if err := d.UpsertPaper(papers[i]); err != nil {
+			return fmt.Errorf("failed to store paper %s: %w", papers[i].ID, err)
+		}
+		if mentions := catalog.Detect(papers[i].Comment, papers[i].PublishedAt.Year()); len(mentions) > 0 {
+			if err := d.SetPaperVenues(papers[i].ID, mentions); err != nil {
+				return err
+			}
+		}
+	}
+	for day := 0; day <= 30; day++ {
+		var published []*models.Paper
+		for _, p := range papers {
+			if p.PublishedAt.YearDay() == now.AddDate(0, 0, -day).YearDay() {
+				published = append(published, p)
+			}
+		}
+		if err := d.RecordNewPapers(published, now.AddDate(0, 0, -day)); err != nil {
+			return err
+		}
+	}
+
+	return seedLibrary(d, rng, papers, now)
+}
+
+// Papers generates n papers like the ones Seed stores, dated relative to
+// now, for the stand-in arXiv API. n is capped at MaxPapers.
+func Papers(n int, now time.Time) []*models.Paper {
+	rng := rand.New(rand.NewSource(1))
+	papers := make([]*models.Paper, min(n, MaxPapers))
+	for i := range papers {
+		papers[i] = paper(rng, i, now)
+	}
+	return papers
+}
+
+// paper generates the i-th paper, published within the last month
+func paper(rng *rand.Rand, i int, now time.Time) *models.Paper {
+	id := fmt.Sprintf("%s.%05d", now.Format("0601"), 10000+i*137)
+	method, task := methods[rng.Intn(len(methods))], tasks[rng.Intn(len(tasks))]
+
+	authors := make([]string, 1+rng.Intn(5))
+	for j := range authors {
+		authors[j] = firstNames[rng.Intn(len(firstNames))] + " " + lastNames[rng.Intn(len(lastNames))]
+	}
+
+	// Vary the abstract from a couple of sentences to all of them, so every
+	// length filter has papers
+	abstract := make([]string, 2+rng.Intn(len(sentences)-1))
+	for j := range abstract {
+		s := sentences[j]
+		switch strings.Count(s, "%s") {
+		case 1:
+			s = fmt.Sprintf(s, datasets[rng.Intn(len(datasets))])
+		case 2:
+			s = fmt.Sprintf(s, strings.ToLower(method), strings.ToLower(task))
+		default:
+			s = strings.ReplaceAll(s, "%%", "%")
+		}
+		abstract[j] = s
+	}
+
+	primary := categories[rng.Intn(len(categories))]
+	cats := []string{primary}
+	if secondary := categories[rng.Intn(len(categories))]; secondary != primary {
+		cats = append(cats, secondary)
+	}
+
+	published := now.Add(-time.Duration(rng.Intn(30*24)) * time.Hour)
+	checked := published
+	p := &models.Paper{
+		ID:            id,
+		Title:         fmt.Sprintf("%s %s for %s", adjectives[rng.Intn(len(adjectives))], method, task),
+		Abstract:      strings.Join(abstract, " "),
+		Authors:       strings.Join(authors, ", "),
+		Categories:    strings.Join(cats, ", "),
+		PublishedAt:   published,
+		UpdatedAt:     published,
+		PDFUrl:        "https://arxiv.org/pdf/" + id,
+		ArxivUrl:      "https://arxiv.org/abs/" + id,
+		License:       licenses[rng.Intn(len(licenses))],
+		Comment:       comments[rng.Intn(len(comments))],
+		HTMLCheckedAt: &checked,
+	}
+	if rng.Intn(2) == 0 {
+		p.HTMLURL = "https://arxiv.org/html/" + id
+	}
+	return p
+}
+
+// seedLibrary saves a third of the papers and organizes them
+func seedLibrary(d *db.DB, rng *rand.Rand, papers []*models.Paper, now time.Time) error {
+	tags := []struct{ name, description string }{
+		{"to-read", "Papers to get to this week"},
+		{"reading-group", "Candidates for the Friday reading group"},
+		{"surveys", ""},
+		{"baselines", "Methods we compare against"},
+	}
+	tagIDs := make([]int, len(tags))
+	for i, tag := range tags {
+		id, err := d.CreateTag(tag.name)
+		if err != nil {
+			return err
+		}
+		if err := d.SetTagDescription(id, tag.description); err != nil {
+			return err
+		}
+		tagIDs[i] = id
+	}
+
+	var saved []string
+	for i, p := range papers {
+		if i%3 != 0 {
+			continue
+		}
+		saved = append(saved, p.ID)
+		if err := d.SaveToLibrary(p.ID); err != nil {
+			return err
+		}
+		if err := d.UpdateLibraryEntry(p.ID, rng.Intn(models.PriorityHigh+1), notes[rng.Intn(len(notes))]); err != nil {
+			return err
+		}
+		for _, id := range tagIDs[:rng.Intn(3)] {
+			if err := d.TagPaper(p.ID, id); err != nil {
+				return err
+			}
+		}
+		if err := d.AddPaperKeywords(p.ID, []string{strings.ToLower(strings.Fields(p.Title)[1])}); err != nil {
+			return err
+		}
+	}
+	if _, err := d.SetReadStatus(saved[:len(saved)/3], true); err != nil {
+		return err
+	}
+
+	for i, id := range saved[len(saved)/3 : len(saved)/3+4] {
+		if err := d.PlanRead(id, now.AddDate(0, 0, i)); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range []struct{ name, description string }{
+		{"teaching", "Papers for the spring seminar"},
+		{"thesis", "Related work for chapter 3"},
+	} {
+		shelf, err := d.CreateShelf(s.name, s.description)
+		if err != nil {
+			return err
+		}
+		for _, id := range saved[rng.Intn(4):][:5] {
+			if err := d.AddToShelf(shelf.ID, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, assignee := range []string{"Grace", "Nikhil"} {
+		if _, err := d.CreateAssignment(saved[i], assignee, now.AddDate(0, 0, 7*(i+1))); err != nil {
+			return err
+		}
+	}
+
+	if err := d.AddRelation(papers[1].ID, papers[0].ID, models.RelationKinds[0]); err != nil {
+		return err
+	}
+
+	if err := d.RecordDeliveries("slack", saved[:5], false, nil, now.Add(-time.Hour)); err != nil {
+		return err
+	}
+	if err := d.RecordDeliveries("email", saved[:3], true, errors.New("dial tcp: connection refused"), now.Add(-time.Hour)); err != nil {
+		return err
+	}
+
+	_, err := d.TrashPapers([]string{papers[len(papers)-1].ID})
+	return err
+}
@@ -0,0 +1,44 @@
+package fixtures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+)
+
+func TestSeed(t *testing.T) {
+	d, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer d.Close()
+
+	if err := Seed(d, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+
+	count, err := d.GetPaperCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != PaperCount-1 {
+		t.Errorf("Expected %d papers outside the trash, got %d", PaperCount-1, count)
+	}
+
+	saved, err := d.GetLibraryCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved == 0 {
+		t.Error("Expected papers in the library")
+	}
+
+	tags, err := d.GetAllTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 4 {
+		t.Errorf("Expected 4 tags, got %d", len(tags))
+	}
+}
@@ -0,0 +1,184 @@
+// Package hooks runs local commands on application events, passing the
+// event as JSON on standard input, so users can wire their own automations
+// (e.g. indexing saved papers elsewhere) without changing the code.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// Events that hooks can subscribe to
+const (
+	PaperSaved     = "paper.saved"
+	FetchCompleted = "fetch.completed"
+)
+
+// events lists every known event
+var events = []string{PaperSaved, FetchCompleted}
+
+// defaultTimeout bounds a hook without a configured timeout
+const defaultTimeout = 30 * time.Second
+
+// maxOutput is how much of a failed hook's output is logged
+const maxOutput = 1024
+
+// Paper is a paper in an event payload
+type Paper struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Abstract    string    `json:"abstract"`
+	Authors     string    `json:"authors"`
+	Categories  string    `json:"categories"`
+	PublishedAt time.Time `json:"published_at"`
+	ArxivURL    string    `json:"arxiv_url"`
+	PDFURL      string    `json:"pdf_url"`
+	Tags        []string  `json:"tags"`
+	Note        string    `json:"note,omitempty"`
+}
+
+// Fetch summarizes a fetch run in an event payload. Error is set when the
+// fetch failed.
+type Fetch struct {
+	Scope   string   `json:"scope"`
+	Fetched int      `json:"fetched"`
+	Stored  int      `json:"stored"`
+	New     []string `json:"new"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Event is the JSON payload written to a hook's standard input
+type Event struct {
+	Name  string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Paper *Paper    `json:"paper,omitempty"`
+	Fetch *Fetch    `json:"fetch,omitempty"`
+}
+
+// NewPaper converts a stored paper to its payload representation
+func NewPaper(p *models.Paper) *Paper {
+	paper := &Paper{
+		ID:          p.ID,
+		Title:       p.Title,
+		Abstract:    p.Abstract,
+		Authors:     p.Authors,
+		Categories:  p.Categories,
+		PublishedAt: p.PublishedAt,
+		ArxivURL:    p.ArxivUrl,
+		PDFURL:      p.PDFUrl,
+		Tags:        []string{},
+		Note:        p.Note,
+	}
+	for _, tag := range p.Tags {
+		paper.Tags = append(paper.Tags, tag.Name)
+	}
+	return paper
+}
+
+// Runner runs the configured hooks. A nil Runner runs nothing.
+type Runner struct {
+	hooks []config.HookConfig
+	wg    sync.WaitGroup
+}
+
+// New creates a runner for the configured hooks, reporting hooks with an
+// unknown event or without a command
+func New(hooks []config.HookConfig) (*Runner, error) {
+	for _, h := range hooks {
+		known := false
+		for _, e := range events {
+			known = known || h.Event == e
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown hook event %q (known: %s)", h.Event, strings.Join(events, ", "))
+		}
+		if len(h.Command) == 0 {
+			return nil, fmt.Errorf("hook for %s has no command", h.Event)
+		}
+	}
+	return &Runner{hooks: hooks}, nil
+}
+
+// Fire runs the hooks for the event in the background, one after another
+// in configuration order. Failures are logged.
+func (r *Runner) Fire(event Event) {
+	if r == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	var matching []config.HookConfig
+	for _, h := range r.hooks {
+		if h.Event == event.Name {
+			matching = append(matching, h)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding %s hook payload: %v", event.Name, err)
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for _, h := range matching {
+			if err := run(h, event.Name, payload); err != nil {
+				log.Printf("Hook %s (%s) failed: %v", event.Name, h.Command[0], err)
+			}
+		}
+	}()
+}
+
+// Wait blocks until every fired hook has finished, so short-lived commands
+// don't exit before their hooks ran
+func (r *Runner) Wait() {
+	if r == nil {
+		return
+	}
+	r.wg.Wait()
+}
+
+// run executes one hook with the payload on standard input and the event
+// name in ARXIV_NEST_EVENT
+func run(h config.HookConfig, event string, payload []byte) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "ARXIV_NEST_EVENT="+event)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		if len(output) > maxOutput {
+			output = output[:maxOutput]
+		}
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestFire(t *testing.T) {
+	dir := t.TempDir()
+	payload := filepath.Join(dir, "payload.json")
+	env := filepath.Join(dir, "event")
+
+	r, err := New([]config.HookConfig{
+		{Event: PaperSaved, Command: []string{"sh", "-c", `cat > "$1"; printf %s "$ARXIV_NEST_EVENT" > "$2"`, "hook", payload, env}},
+		{Event: FetchCompleted, Command: []string{"sh", "-c", "touch " + filepath.Join(dir, "fetched")}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	paper := &models.Paper{ID: "2301.00001", Title: "Hooked", Tags: []models.Tag{{Name: "graphs"}}}
+	r.Fire(Event{Name: PaperSaved, Paper: NewPaper(paper)})
+	r.Wait()
+
+	data, err := os.ReadFile(payload)
+	if err != nil {
+		t.Fatalf("Expected the hook to run: %v", err)
+	}
+	var got Event
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Expected a JSON payload, got %q: %v", data, err)
+	}
+	if got.Name != PaperSaved || got.Time.IsZero() || got.Paper == nil || got.Paper.ID != "2301.00001" || got.Paper.Tags[0] != "graphs" {
+		t.Errorf("Unexpected payload %s", data)
+	}
+	if got.Fetch != nil {
+		t.Error("Expected no fetch in a paper.saved payload")
+	}
+	if name, _ := os.ReadFile(env); string(name) != PaperSaved {
+		t.Errorf("Expected ARXIV_NEST_EVENT=%s, got %q", PaperSaved, name)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fetched")); !os.IsNotExist(err) {
+		t.Error("Expected hooks of other events not to run")
+	}
+
+	// A nil runner is a no-op
+	var none *Runner
+	none.Fire(Event{Name: PaperSaved})
+	none.Wait()
+}
+
+func TestNewRejectsInvalidHooks(t *testing.T) {
+	if _, err := New([]config.HookConfig{{Event: "paper.deleted", Command: []string{"true"}}}); err == nil {
+		t.Error("Expected an unknown event to be rejected")
+	}
+	if _, err := New([]config.HookConfig{{Event: FetchCompleted}}); err == nil {
+		t.Error("Expected a hook without a command to be rejected")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	err := run(config.HookConfig{Command: []string{"sh", "-c", "echo broken; exit 3"}}, PaperSaved, nil)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the failure with the hook's output, got %v", err)
+	}
+
+	start := time.Now()
+	err = run(config.HookConfig{Command: []string{"sleep", "5"}, Timeout: 100 * time.Millisecond}, PaperSaved, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the hook to be killed at the timeout")
+	}
+}
@@ -0,0 +1,262 @@
+// Package links checks that the links stored for saved papers (PDF,
+// abstract page, HTML rendering and code repositories named in the
+// abstract or comment) still resolve. Broken arXiv links are repaired by
+// regenerating them from the paper's ID; the rest are recorded for the
+// dead links report.
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+)
+
+const (
+	// BatchSize is the number of papers checked per run
+	BatchSize = 20
+
+	// RecheckAfter is how long a paper's links are trusted after a check
+	RecheckAfter = 30 * 24 * time.Hour
+
+	// requestDelay spaces out requests, most of which go to arxiv.org
+	requestDelay = 3 * time.Second
+
+	// requestTimeout bounds a single check
+	requestTimeout = 30 * time.Second
+)
+
+// codeLink matches repository URLs on the common code hosts
+var codeLink = regexp.MustCompile(`https?://(?:www\.)?(?:github\.com|gitlab\.com|bitbucket\.org|huggingface\.co)/[^\s)\]}>"',;]+`)
+
+// CodeLinks returns the code repository URLs in a text, without duplicates
+func CodeLinks(text string) []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, u := range codeLink.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".")
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Result summarizes a run of the checker
+type Result struct {
+	Papers   int // papers whose links were checked
+	Broken   int // links found broken and left for the report
+	Repaired int // links that were broken and have been repaired
+}
+
+// Checker checks and repairs paper links
+type Checker struct {
+	db     *db.DB
+	client *http.Client
+	delay  time.Duration
+
+	// pdfURL and absURL give a paper's current arXiv links
+	pdfURL func(id string) string
+	absURL func(id string) string
+}
+
+// New creates a checker storing its findings in the database
+func New(database *db.DB) *Checker {
+	return &Checker{
+		db: database,
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: telemetry.Transport(nil),
+		},
+		delay:  requestDelay,
+		pdfURL: arxiv.PDFURL,
+		absURL: arxiv.AbsURL,
+	}
+}
+
+// errTransient marks failures that say nothing about the link, such as a
+// timeout or a 503, so the paper is checked again on the next run
+var errTransient = errors.New("temporary failure")
+
+// Run checks the links of the next batch of saved papers
+func (c *Checker) Run(ctx context.Context) (Result, error) {
+	var result Result
+	papers, err := c.db.GetPapersForLinkCheck(time.Now().Add(-RecheckAfter), BatchSize)
+	if err != nil {
+		return result, err
+	}
+
+	for _, p := range papers {
+		broken, repaired, err := c.checkPaper(ctx, p)
+		if errors.Is(err, errTransient) {
+			log.Printf("Link check of %s postponed: %v", p.ID, err)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if err := c.db.SetLinkCheck(p.ID, broken, time.Now()); err != nil {
+			return result, err
+		}
+		result.Papers++
+		result.Broken += len(broken)
+		result.Repaired += repaired
+	}
+	return result, nil
+}
+
+// link is one URL of a paper to check
+type link struct {
+	kind string
+	url  string
+}
+
+// paperLinks lists the links of a paper
+func paperLinks(p models.Paper) []link {
+	var links []link
+	if p.PDFUrl != "" {
+		links = append(links, link{models.LinkPDF, p.PDFUrl})
+	}
+	if p.ArxivUrl != "" {
+		links = append(links, link{models.LinkAbstract, p.ArxivUrl})
+	}
+	if p.HTMLURL != "" {
+		links = append(links, link{models.LinkHTML, p.HTMLURL})
+	}
+	for _, u := range CodeLinks(p.Abstract + " " + p.Comment) {
+		links = append(links, link{models.LinkCode, u})
+	}
+	return links
+}
+
+// checkPaper checks every link of a paper, repairing what it can, and
+// returns the links left broken and the number repaired
+func (c *Checker) checkPaper(ctx context.Context, p models.Paper) ([]models.BrokenLink, int, error) {
+	var broken []models.BrokenLink
+	repaired := 0
+	for _, l := range paperLinks(p) {
+		status, err := c.check(ctx, l.url)
+		if err != nil {
+			return nil, 0, err
+		}
+		if works(status) {
+			continue
+		}
+
+		ok, err := c.repair(ctx, p, l)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			repaired++
+			continue
+		}
+		broken = append(broken, models.BrokenLink{PaperID: p.ID, Kind: l.kind, URL: l.url, Status: status})
+	}
+	return broken, repaired, nil
+}
+
+// repair fixes a broken arXiv link, if possible: PDF and abstract links
+// are regenerated from the paper's ID when the current URL works, and a
+// dead HTML rendering is forgotten so reader mode detects it again. Links
+// of papers from other sources are only reported.
+func (c *Checker) repair(ctx context.Context, p models.Paper, l link) (bool, error) {
+	if l.kind != models.LinkHTML && p.Source() != models.SourceArxiv {
+		return false, nil
+	}
+
+	var current string
+	switch l.kind {
+	case models.LinkHTML:
+		if err := c.db.RepairLink(p.ID, l.kind, ""); err != nil {
+			return false, err
+		}
+		log.Printf("Cleared the dead HTML link of %s", p.ID)
+		return true, nil
+	case models.LinkPDF:
+		current = c.pdfURL(p.ID)
+	case models.LinkAbstract:
+		current = c.absURL(p.ID)
+	default:
+		return false, nil
+	}
+	if current == l.url {
+		return false, nil
+	}
+
+	status, err := c.check(ctx, current)
+	if err != nil || !works(status) {
+		return false, err
+	}
+	if err := c.db.RepairLink(p.ID, l.kind, current); err != nil {
+		return false, err
+	}
+	log.Printf("Repaired the %s link of %s: %s -> %s", l.kind, p.ID, l.url, current)
+	return true, nil
+}
+
+// check requests a URL after the pause between requests and returns its
+// status code: 0 if the host doesn't exist, below 400 if the link works.
+// Failures that don't mean the link is broken return errTransient.
+func (c *Checker) check(ctx context.Context, url string) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(c.delay):
+	}
+
+	status, err := c.request(ctx, http.MethodHead, url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		// Some hosts don't answer HEAD requests
+		status, err = c.request(ctx, http.MethodGet, url)
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return 0, nil
+	case err != nil:
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, fmt.Errorf("%w: %v", errTransient, err)
+	case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+		return 0, fmt.Errorf("%w: %s returned status code %d", errTransient, url, status)
+	}
+	return status, nil
+}
+
+// works reports whether a status code returned by check means the link works
+func works(status int) bool {
+	return status != 0 && status < http.StatusBadRequest
+}
+
+// request sends a request and returns the status code, following redirects
+func (c *Checker) request(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
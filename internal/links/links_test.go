@@ -0,0 +1,120 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestCodeLinks(t *testing.T) {
+	text := "Code: https://github.com/org/repo. Weights at https://huggingface.co/org/model (see https://github.com/org/repo)."
+	got := CodeLinks(text)
+	if len(got) != 2 || got[0] != "https://github.com/org/repo" || got[1] != "https://huggingface.co/org/model" {
+		t.Errorf("Unexpected code links: %v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	busy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pdf/2401.00001", "/abs/2401.00001", "/github.com/org/live":
+			if r.Method == http.MethodHead && r.URL.Path == "/github.com/org/live" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/busy":
+			if busy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	database, err := db.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer database.Close()
+
+	papers := []*models.Paper{{
+		ID: "2401.00001", Title: "Moved",
+		Abstract: "Code at https://github.com/org/dead and " + srv.URL + "/github.com/org/live",
+		PDFUrl:   srv.URL + "/old/pdf/2401.00001", ArxivUrl: srv.URL + "/abs/2401.00001",
+	}, {
+		ID: "2401.00002", Title: "Busy", PDFUrl: srv.URL + "/busy",
+	}}
+	for _, p := range papers {
+		p.PublishedAt, p.UpdatedAt = time.Now(), time.Now()
+		if err := database.UpsertPaper(p); err != nil {
+			t.Fatalf("UpsertPaper failed: %v", err)
+		}
+		if err := database.SaveToLibrary(p.ID); err != nil {
+			t.Fatalf("SaveToLibrary failed: %v", err)
+		}
+	}
+
+	c := New(database)
+	c.delay = 0
+	c.pdfURL = func(id string) string { return srv.URL + "/pdf/" + id }
+	c.absURL = func(id string) string { return srv.URL + "/abs/" + id }
+	// The code link outside the test server points at a real host, so send
+	// every request to the test server
+	c.client.Transport = rewriteHost{srv.URL}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Papers != 1 || result.Repaired != 1 || result.Broken != 1 {
+		t.Errorf("Expected 1 paper checked with 1 repaired and 1 broken link, got %+v", result)
+	}
+
+	paper, err := database.GetPaperByID("2401.00001")
+	if err != nil {
+		t.Fatalf("GetPaperByID failed: %v", err)
+	}
+	if paper.PDFUrl != srv.URL+"/pdf/2401.00001" {
+		t.Errorf("Expected the PDF link to be regenerated, got %s", paper.PDFUrl)
+	}
+
+	broken, err := database.GetBrokenLinks()
+	if err != nil {
+		t.Fatalf("GetBrokenLinks failed: %v", err)
+	}
+	if len(broken) != 1 || broken[0].Kind != models.LinkCode || broken[0].Status != http.StatusNotFound || broken[0].Title != "Moved" {
+		t.Errorf("Expected the dead code link to be reported, got %+v", broken)
+	}
+
+	// The paper whose host was busy is retried on the next run; the
+	// checked one isn't due again
+	busy = false
+	result, err = c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Papers != 1 || result.Broken != 0 {
+		t.Errorf("Expected only the postponed paper to be checked, got %+v", result)
+	}
+}
+
+// rewriteHost sends every request to a test server, keeping the host of
+// the original URL as the first path segment for external hosts
+type rewriteHost struct{ base string }
+
+func (t rewriteHost) RoundTrip(r *http.Request) (*http.Response, error) {
+	u := *r.URL
+	if base := t.base[len("http://"):]; u.Host != base {
+		u.Path = "/" + u.Host + u.Path
+		u.Scheme, u.Host = "http", base
+	}
+	r = r.Clone(r.Context())
+	r.URL = &u
+	return http.DefaultTransport.RoundTrip(r)
+}
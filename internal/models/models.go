@@ -1,8 +1,16 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
 
-// Paper represents an arXiv paper with all metadata
+// Paper represents a preprint with all metadata. Most come from arXiv;
+// papers imported from other servers have namespaced IDs (see PaperSource).
 type Paper struct {
 	ID          string    `db:"id"`
 	Title       string    `db:"title"`
@@ -12,26 +20,403 @@ type Paper struct {
 	PublishedAt time.Time `db:"published_at"`
 	UpdatedAt   time.Time `db:"updated_at"`
 	PDFUrl      string    `db:"pdf_url"`
-	ArxivUrl    string    `db:"arxiv_url"`
+	ArxivUrl    string    `db:"arxiv_url"` // abstract page, also for other sources
 	CreatedAt   time.Time `db:"created_at"`
 
+	// AbstractWords is the abstract's word count, computed at ingest
+	AbstractWords int `db:"abstract_words"`
+
+	// License is the URL of the paper's license, if arXiv reported one
+	License string `db:"license"`
+
+	// Comment is the authors' arXiv comment, which often names the venue
+	// ("Accepted at NeurIPS 2024")
+	Comment string `db:"comment"`
+
+	// DOI and JournalRef identify the published version, if the authors
+	// reported one to arXiv
+	DOI        string `db:"doi"`
+	JournalRef string `db:"journal_ref"`
+
+	// HTML rendering (arxiv.org/html) if one is available
+	HTMLURL       string     `db:"html_url"`
+	HTMLCheckedAt *time.Time `db:"html_checked_at"`
+
+	// ContentHash identifies the fetched content (see ContentHash), so
+	// unchanged papers are not rewritten; LastSeenAt is when a fetch last
+	// returned the paper
+	ContentHash string     `db:"content_hash"`
+	LastSeenAt  *time.Time `db:"last_seen_at"`
+
+	// LinksCheckedAt is when the link checker last visited the paper's links
+	LinksCheckedAt *time.Time `db:"links_checked_at"`
+
 	// Fields populated via joins (not in papers table)
-	InLibrary bool  `db:"in_library"`
-	IsRead    bool  `db:"is_read"`
-	Tags      []Tag `db:"-"`
+	InLibrary bool   `db:"in_library"`
+	IsRead    bool   `db:"is_read"`
+	Priority  int    `db:"priority"`
+	Note      string `db:"note"`
+	Tags      []Tag  `db:"-"`
+
+	// Suggestions are fetch keywords the paper matched that aren't tags yet
+	Suggestions []string `db:"-"`
+
+	// Entities are the datasets and benchmarks its abstract mentions
+	Entities []Entity       `db:"-"`
+	Venues   []VenueMention `db:"-"`
+}
+
+// SourceArxiv is the source of papers stored under plain arXiv IDs
+const SourceArxiv = "arxiv"
+
+// sourceNames are the display names of the sources papers come from
+var sourceNames = map[string]string{
+	SourceArxiv: "arXiv",
+	"biorxiv":   "bioRxiv",
+	"medrxiv":   "medRxiv",
+}
+
+// PaperSource returns the preprint server a paper ID belongs to: the
+// namespace of IDs such as "biorxiv:2024.01.15.575123", or SourceArxiv for
+// arXiv IDs, which are stored without one
+func PaperSource(id string) string {
+	if source, _, ok := strings.Cut(id, ":"); ok {
+		return source
+	}
+	return SourceArxiv
+}
+
+// Source returns the preprint server the paper comes from
+func (p Paper) Source() string {
+	return PaperSource(p.ID)
+}
+
+// SourceName returns the display name of the paper's source, e.g. "bioRxiv"
+func (p Paper) SourceName() string {
+	if name, ok := sourceNames[p.Source()]; ok {
+		return name
+	}
+	return p.Source()
+}
+
+// ContentHash returns a hash of the paper's fetched fields. Two fetches of
+// an unchanged paper hash the same; stored-only fields such as the HTML
+// rendering and library state are not included.
+func ContentHash(p *Paper) string {
+	h := sha256.New()
+	for _, field := range []string{
+		p.Title, p.Abstract, p.Authors, p.Categories,
+		p.PublishedAt.UTC().Format(time.RFC3339Nano), p.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		p.PDFUrl, p.ArxivUrl, p.License, p.Comment, p.DOI, p.JournalRef,
+	} {
+		// Length prefixes keep field boundaries unambiguous
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ReadingWordsPerMinute is the reading speed assumed for abstracts
+const ReadingWordsPerMinute = 200
+
+// Abstract length buckets by word count: short abstracts have fewer than
+// ShortAbstractWords, long ones more than LongAbstractWords
+const (
+	ShortAbstractWords = 120
+	LongAbstractWords  = 250
+)
+
+// WordCount returns the number of whitespace-separated words in s
+func WordCount(s string) int {
+	return len(strings.Fields(s))
+}
+
+// ReadingMinutes estimates how long the abstract takes to read, rounded up
+func (p Paper) ReadingMinutes() int {
+	if p.AbstractWords == 0 {
+		return 0
+	}
+	return (p.AbstractWords + ReadingWordsPerMinute - 1) / ReadingWordsPerMinute
+}
+
+// LicenseFilter selects papers by license. Pattern is a LIKE pattern for
+// the license URL; an empty pattern matches papers without license info.
+type LicenseFilter struct {
+	Value   string
+	Label   string
+	Pattern string
+}
+
+// LicenseFilters lists the license filters offered in search
+var LicenseFilters = []LicenseFilter{
+	{Value: "cc-by", Label: "CC BY only", Pattern: "%creativecommons.org/licenses/by/%"},
+	{Value: "cc", Label: "Any Creative Commons", Pattern: "%creativecommons.org/%"},
+	{Value: "cc0", Label: "Public domain (CC0)", Pattern: "%creativecommons.org/publicdomain/zero/%"},
+	{Value: "arxiv", Label: "arXiv distribution only", Pattern: "%arxiv.org/licenses/%"},
+	{Value: "unknown", Label: "Unknown license", Pattern: ""},
+}
+
+// FindLicenseFilter returns the license filter with the given value
+func FindLicenseFilter(value string) (LicenseFilter, bool) {
+	for _, f := range LicenseFilters {
+		if f.Value == value {
+			return f, true
+		}
+	}
+	return LicenseFilter{}, false
+}
+
+// LicenseLabel returns a short name for a license URL, such as
+// "CC BY-NC-SA 4.0", or "" if the license is unknown
+func LicenseLabel(licenseURL string) string {
+	u := strings.ToLower(strings.TrimSpace(licenseURL))
+	if u == "" {
+		return ""
+	}
+
+	// Path segments after the host, e.g. [licenses by-nc-sa 4.0]
+	u = strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+	parts := strings.Split(strings.Trim(u, "/"), "/")
+	host, path := parts[0], parts[1:]
+
+	switch {
+	case strings.HasSuffix(host, "creativecommons.org") && len(path) >= 2 && path[0] == "licenses":
+		label := "CC " + strings.ToUpper(path[1])
+		if len(path) >= 3 {
+			label += " " + path[2]
+		}
+		return label
+	case strings.HasSuffix(host, "creativecommons.org") && len(path) >= 2 && path[0] == "publicdomain":
+		if path[1] == "zero" {
+			label := "CC0"
+			if len(path) >= 3 {
+				label += " " + path[2]
+			}
+			return label
+		}
+		return "Public domain"
+	case strings.HasSuffix(host, "arxiv.org"):
+		return "arXiv"
+	}
+	return host
 }
 
 // Tag represents a user-defined tag
 type Tag struct {
-	ID   int    `db:"id"`
-	Name string `db:"name"`
+	ID          int    `db:"id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+
+	// Owner is the client ID of the browser a personal tag belongs to;
+	// empty for tags shared with everyone
+	Owner string `db:"owner"`
+
+	// PaperCount is the number of tagged papers, filled by queries that count them
+	PaperCount int `db:"paper_count"`
+}
+
+// MonthCount is a number of papers published in a month ("2006-01")
+type MonthCount struct {
+	Month string `db:"month"`
+	Count int    `db:"count"`
+}
+
+// DayCount is a daily total from a stats rollup, by day ("2006-01-02")
+type DayCount struct {
+	Day   string `db:"day"`
+	Count int    `db:"count"`
+}
+
+// Usage kinds: a page loaded in the browser, or anything else done in the
+// interface (an HTMX request, a form post)
+const (
+	UsagePage   = "page"
+	UsageAction = "action"
+)
+
+// UsageCount is how often a route was used on a day ("2006-01-02") and by
+// how many browsers. Name is the method and route pattern, e.g.
+// "POST /tag/add".
+type UsageCount struct {
+	Day      string `db:"day"`
+	Kind     string `db:"kind"`
+	Name     string `db:"name"`
+	Count    int    `db:"count"`
+	Visitors int    `db:"visitors"`
+}
+
+// CategoryCount is a number of papers in an arXiv category
+type CategoryCount struct {
+	Category string `db:"category"`
+	Count    int    `db:"count"`
 }
 
 // LibraryEntry represents a paper saved to the user's library
 type LibraryEntry struct {
-	PaperID string    `db:"paper_id"`
-	IsRead  bool      `db:"is_read"`
-	SavedAt time.Time `db:"saved_at"`
+	PaperID  string    `db:"paper_id"`
+	IsRead   bool      `db:"is_read"`
+	SavedAt  time.Time `db:"saved_at"`
+	Priority int       `db:"priority"`
+	Note     string    `db:"note"` // why the paper was saved
+}
+
+// Link kinds checked by the link checker
+const (
+	LinkPDF      = "pdf"
+	LinkAbstract = "abstract"
+	LinkHTML     = "html"
+	LinkCode     = "code" // a code repository named in the abstract or comment
+)
+
+// BrokenLink is a paper link that stopped resolving. Status is the HTTP
+// status code it returned. Title is populated via join.
+type BrokenLink struct {
+	PaperID       string    `db:"paper_id"`
+	Title         string    `db:"title"`
+	Kind          string    `db:"kind"`
+	URL           string    `db:"url"`
+	Status        int       `db:"status"`
+	FirstFailedAt time.Time `db:"first_failed_at"`
+	CheckedAt     time.Time `db:"checked_at"`
+}
+
+// Delivery statuses
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// Delivery records the announcement of a paper on a notification channel.
+// Status is DeliverySent or DeliveryFailed, with the error in Error. Title
+// is populated via join, and empty once the paper has been purged.
+type Delivery struct {
+	PaperID     string    `db:"paper_id"`
+	Title       string    `db:"title"`
+	Channel     string    `db:"channel"`
+	Status      string    `db:"status"`
+	Error       string    `db:"error"`
+	Digest      bool      `db:"digest"`
+	AttemptedAt time.Time `db:"attempted_at"`
+}
+
+// Kinds of collections notification channels can subscribe to
+const (
+	CollectionTag   = "tag"
+	CollectionShelf = "shelf"
+)
+
+// CollectionSubscription lists the notification channels announcing
+// papers added to a shared tag or a shelf. Kind is CollectionTag or
+// CollectionShelf.
+type CollectionSubscription struct {
+	Kind     string
+	ID       int
+	Name     string
+	Channels []string
+}
+
+// Shelf is a named collection of papers alongside the library, such as
+// "to-read" or "teaching". Papers and Unread are populated via join.
+type Shelf struct {
+	ID          int       `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+	Papers      int       `db:"papers"`
+	Unread      int       `db:"unread"`
+}
+
+// View pages: the list page a saved view searches
+const (
+	ViewBrowse  = "browse"
+	ViewLibrary = "library"
+)
+
+// SavedView is a named search of the browse or library page. Query is the
+// page's filter query string. Opening the view shows which papers arrived
+// since it was last opened, at VisitedAt.
+type SavedView struct {
+	ID        int        `db:"id"`
+	Name      string     `db:"name"`
+	Page      string     `db:"page"`
+	Query     string     `db:"query"`
+	CreatedAt time.Time  `db:"created_at"`
+	VisitedAt *time.Time `db:"visited_at"`
+}
+
+// ReadEvent is a library paper being marked as read, as pushed to external
+// reading logs. Title, Authors, ArxivUrl and Note are populated via join,
+// Tags (shared tags only) separately.
+type ReadEvent struct {
+	ID       int64     `db:"id"`
+	PaperID  string    `db:"paper_id"`
+	ReadAt   time.Time `db:"read_at"`
+	Title    string    `db:"title"`
+	Authors  string    `db:"authors"`
+	ArxivUrl string    `db:"arxiv_url"`
+	Note     string    `db:"note"`
+	Tags     []string  `db:"-"`
+}
+
+// Review states of a paper in the curation queue
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a fetched paper's place in the curation queue: pending until a
+// curator approves it into the team feed or rejects it
+type Review struct {
+	PaperID   string     `db:"paper_id"`
+	Status    string     `db:"status"`
+	Curator   string     `db:"curator"`
+	QueuedAt  time.Time  `db:"queued_at"`
+	DecidedAt *time.Time `db:"decided_at"`
+}
+
+// ReviewedPaper is a paper with its review
+type ReviewedPaper struct {
+	Paper
+	Review
+}
+
+// CuratorStats counts a curator's decisions
+type CuratorStats struct {
+	Curator  string `db:"curator"`
+	Approved int    `db:"approved"`
+	Rejected int    `db:"rejected"`
+}
+
+// ShelfEntry is a paper on a shelf. Each shelf keeps its own read state
+// and priority for the paper, independent of the library's.
+type ShelfEntry struct {
+	ShelfID  int       `db:"shelf_id"`
+	PaperID  string    `db:"paper_id"`
+	IsRead   bool      `db:"is_read"`
+	Priority int       `db:"priority"`
+	AddedAt  time.Time `db:"added_at"`
+
+	// Populated via join
+	Shelf string `db:"shelf"`
+}
+
+// Library and shelf entry priorities
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// PriorityLabels names each priority level, indexed by value
+var PriorityLabels = []string{"None", "Low", "Medium", "High"}
+
+// PriorityLabel returns the display name of a priority level
+func PriorityLabel(priority int) string {
+	if priority < 0 || priority >= len(PriorityLabels) {
+		return PriorityLabels[PriorityNone]
+	}
+	return PriorityLabels[priority]
 }
 
 // PaperTag represents the many-to-many relationship between papers and tags
@@ -40,14 +425,228 @@ type PaperTag struct {
 	TagID   int    `db:"tag_id"`
 }
 
+// Assignment is a paper a reading group member presents by a due date
+type Assignment struct {
+	ID        int       `db:"id"`
+	PaperID   string    `db:"paper_id"`
+	Assignee  string    `db:"assignee"`
+	DueDate   time.Time `db:"due_date"`
+	Presented bool      `db:"presented"`
+	CreatedAt time.Time `db:"created_at"`
+
+	// Populated via join
+	PaperTitle string `db:"title"`
+}
+
+// PlannedRead is a library paper scheduled to be read on a day
+type PlannedRead struct {
+	PaperID    string    `db:"paper_id"`
+	PlannedFor time.Time `db:"planned_for"`
+	CreatedAt  time.Time `db:"created_at"`
+
+	// Populated via join
+	Title    string `db:"title"`
+	ArxivUrl string `db:"arxiv_url"`
+	IsRead   bool   `db:"is_read"`
+}
+
+// AuthorChange is a paper's author list before and after a bulk author
+// replace
+type AuthorChange struct {
+	PaperID string `db:"id"`
+	Title   string `db:"title"`
+	Before  string `db:"authors"`
+	After   string `db:"-"`
+}
+
+// ArchiveStat is a snapshot of the total number of papers arXiv reports
+// for a subscription topic (a category or keyword)
+type ArchiveStat struct {
+	Topic        string    `db:"topic"`
+	Query        string    `db:"query"`
+	TotalResults int       `db:"total_results"`
+	RecordedAt   time.Time `db:"recorded_at"`
+}
+
+// TrashedPaper is a deleted paper waiting in the recycle bin
+type TrashedPaper struct {
+	PaperID   string    `db:"paper_id"`
+	Title     string    `db:"title"`
+	DeletedAt time.Time `db:"deleted_at"`
+
+	// PurgeAt is when the paper is permanently deleted (zero if kept forever)
+	PurgeAt time.Time `db:"-"`
+}
+
 // SearchParams holds parameters for searching and filtering papers
 type SearchParams struct {
-	Query      string
-	Tag        string
-	Category   string
-	InLibrary  bool
-	Page       int
-	PageSize   int
-	SortBy     string // "published", "title"
-	SortOrder  string // "asc", "desc"
+	Query     string // case-folded, see search.Normalize
+	QueryText string // Query as typed, for display
+	Tag       string
+	Category  string
+	Length    string // abstract length: "short", "medium", "long"
+	License   string // a LicenseFilters value, e.g. "cc-by"
+	Entity    string // a dataset or benchmark name, e.g. "KITTI"
+	InLibrary bool
+	Page      int
+	PageSize  int
+	SortBy    string // "published", "title", "priority", "length"
+	SortOrder string // "asc", "desc"
+
+	// Filters on the library entry; setting any of them limits the
+	// results to saved papers
+	Read        string    // "read", "unread"
+	Note        string    // "with", "without"
+	MinPriority int       // lowest priority, PriorityLow to PriorityHigh
+	SavedFrom   time.Time // first day saved, inclusive
+	SavedTo     time.Time // last day saved, inclusive
+}
+
+// Entity kinds extracted from abstracts
+const (
+	EntityDataset   = "dataset"
+	EntityBenchmark = "benchmark"
+)
+
+// Entity is a dataset or benchmark mentioned in a paper's abstract. Papers
+// is the number of papers mentioning it, when listing known entities.
+type Entity struct {
+	Name   string `db:"name"`
+	Kind   string `db:"kind"`
+	Papers int    `db:"papers"`
+}
+
+// VenueMention is a conference a paper's arXiv comment says it appeared
+// at, e.g. "Accepted at NeurIPS 2024"
+type VenueMention struct {
+	Venue string `db:"venue"`
+	Year  int    `db:"year"`
+}
+
+// FetchRun records one fetch from arXiv. Scope is "all" for a full fetch,
+// or the single category or keyword fetched.
+type FetchRun struct {
+	ID         int       `db:"id"`
+	Scope      string    `db:"scope"`
+	StartedAt  time.Time `db:"started_at"`
+	FinishedAt time.Time `db:"finished_at"`
+	Fetched    int       `db:"fetched"`
+	Stored     int       `db:"stored"`
+	New        int       `db:"new_papers"`
+	Error      string    `db:"error"`
+}
+
+// Backfill is a harvest of a category's papers submitted between two days.
+// NextDay and NextStart are the checkpoint: the first day not fully
+// harvested and the offset into its results.
+type Backfill struct {
+	ID         int        `db:"id"`
+	Category   string     `db:"category"`
+	FromDay    time.Time  `db:"from_day"`
+	ToDay      time.Time  `db:"to_day"`
+	NextDay    time.Time  `db:"next_day"`
+	NextStart  int        `db:"next_start"`
+	Fetched    int        `db:"fetched"`
+	Stored     int        `db:"stored"`
+	StartedAt  time.Time  `db:"started_at"`
+	UpdatedAt  time.Time  `db:"updated_at"`
+	FinishedAt *time.Time `db:"finished_at"`
+}
+
+// Done reports whether every day of the backfill has been harvested
+func (b *Backfill) Done() bool {
+	return b.FinishedAt != nil
+}
+
+// Relation kinds for manual links between papers
+const (
+	RelationSupersedes = "supersedes"
+	RelationExtends    = "extends"
+	RelationRebuts     = "rebuts"
+	RelationCompanion  = "companion"
+)
+
+// RelationKinds lists the relation kinds in display order
+var RelationKinds = []string{RelationSupersedes, RelationExtends, RelationRebuts, RelationCompanion}
+
+// relationLabels names each relation kind as read from its source paper and
+// from its target paper
+var relationLabels = map[string][2]string{
+	RelationSupersedes: {"Supersedes", "Superseded by"},
+	RelationExtends:    {"Extends", "Extended by"},
+	RelationRebuts:     {"Rebuts", "Rebutted by"},
+	RelationCompanion:  {"Companion of", "Companion of"},
+}
+
+// ValidRelationKind reports whether kind is a known relation kind
+func ValidRelationKind(kind string) bool {
+	_, ok := relationLabels[kind]
+	return ok
+}
+
+// RelationLabel names a relation kind as read from its source paper, e.g.
+// "Supersedes"
+func RelationLabel(kind string) string {
+	if labels, ok := relationLabels[kind]; ok {
+		return labels[0]
+	}
+	return kind
+}
+
+// Relation is a typed manual link from one paper to another, e.g. a paper
+// that supersedes an earlier one
+type Relation struct {
+	ID        int       `db:"id"`
+	FromID    string    `db:"from_id"`
+	ToID      string    `db:"to_id"`
+	Kind      string    `db:"kind"`
+	CreatedAt time.Time `db:"created_at"`
+
+	// Populated via join: the title of the paper at the other end
+	OtherTitle string `db:"title"`
+}
+
+// Other returns the ID of the paper at the other end from paperID
+func (r Relation) Other(paperID string) string {
+	if r.FromID == paperID {
+		return r.ToID
+	}
+	return r.FromID
+}
+
+// Duplicate suggestion states
+const (
+	DuplicatePending   = "pending"
+	DuplicateDismissed = "dismissed"
+)
+
+// Duplicate is a pair of papers with near-identical abstracts, suggested
+// for merging into one of them
+type Duplicate struct {
+	ID          int       `db:"id"`
+	PaperID     string    `db:"paper_id"`
+	DuplicateID string    `db:"duplicate_id"`
+	Similarity  float64   `db:"similarity"`
+	Status      string    `db:"status"`
+	FoundAt     time.Time `db:"found_at"`
+
+	// Papers are the two papers, in ID order
+	Papers []Paper `db:"-"`
+}
+
+// Percent returns the similarity of the abstracts as a whole percentage
+func (d Duplicate) Percent() int {
+	return int(math.Round(d.Similarity * 100))
+}
+
+// Label describes the relation as seen from paperID, e.g. "Superseded by"
+func (r Relation) Label(paperID string) string {
+	labels, ok := relationLabels[r.Kind]
+	if !ok {
+		return r.Kind
+	}
+	if r.FromID == paperID {
+		return labels[0]
+	}
+	return labels[1]
 }
@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestLicenseLabel(t *testing.T) {
+	tests := map[string]string{
+		"http://creativecommons.org/licenses/by/4.0/":         "CC BY 4.0",
+		"https://creativecommons.org/licenses/by-nc-sa/4.0/":  "CC BY-NC-SA 4.0",
+		"http://creativecommons.org/publicdomain/zero/1.0/":   "CC0 1.0",
+		"http://arxiv.org/licenses/nonexclusive-distrib/1.0/": "arXiv",
+		"https://example.org/some-license":                    "example.org",
+		"":                                                    "",
+	}
+
+	for url, want := range tests {
+		if got := LicenseLabel(url); got != want {
+			t.Errorf("LicenseLabel(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	tests := map[int]int{0: 0, 1: 1, 200: 1, 201: 2, 650: 4}
+
+	for words, want := range tests {
+		if got := (Paper{AbstractWords: words}).ReadingMinutes(); got != want {
+			t.Errorf("ReadingMinutes with %d words = %d, want %d", words, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// digestSendTimeout bounds sending a digest when its window closes, since
+// the fetch that queued the papers has long finished by then
+const digestSendTimeout = time.Minute
+
+// batcher collects messages for a channel and sends them as one digest
+// once the channel's batching window has passed since the first was queued
+type batcher struct {
+	channel Channel
+	window  time.Duration
+	// record logs the outcome of sending a digest
+	record func(channel string, messages []Message, digest bool, sendErr error)
+
+	mu      sync.Mutex
+	pending []Message
+	timer   *time.Timer
+}
+
+// newBatcher creates a batcher sending to channel every window
+func newBatcher(channel Channel, window time.Duration, record func(string, []Message, bool, error)) *batcher {
+	return &batcher{channel: channel, window: window, record: record}
+}
+
+// add queues messages, opening a window if none is open. With unique set,
+// papers already queued are not queued again.
+func (b *batcher) add(messages []Message, unique bool) {
+	if len(messages) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queued := make(map[string]bool, len(b.pending))
+	for _, msg := range b.pending {
+		queued[msg.PaperID] = true
+	}
+	for _, msg := range messages {
+		if !unique || !queued[msg.PaperID] {
+			queued[msg.PaperID] = true
+			b.pending = append(b.pending, msg)
+		}
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), digestSendTimeout)
+			defer cancel()
+			if err := b.flush(ctx); err != nil {
+				log.Printf("Error sending digest to %s: %v", b.channel.Name(), err)
+			}
+		})
+	}
+}
+
+// flush sends everything queued as one digest and closes the window
+func (b *batcher) flush(ctx context.Context) error {
+	b.mu.Lock()
+	messages := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	if len(messages) == 0 {
+		return nil
+	}
+	err := b.channel.Send(ctx, messages)
+	b.record(b.channel.Name(), messages, true, err)
+	return err
+}
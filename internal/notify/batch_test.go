@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// recordingChannel remembers the size of every send
+type recordingChannel struct {
+	mu    sync.Mutex
+	sends []int
+}
+
+func (c *recordingChannel) Name() string { return "recording" }
+
+func (c *recordingChannel) Send(ctx context.Context, messages []Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sends = append(c.sends, len(messages))
+	return nil
+}
+
+func (c *recordingChannel) Sends() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int(nil), c.sends...)
+}
+
+func TestBatchedChannelSendsOneDigest(t *testing.T) {
+	n, err := New(config.NotificationsConfig{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	immediate, batched := &recordingChannel{}, &recordingChannel{}
+	n.AddChannel(immediate, 0)
+	n.AddChannel(batched, 50*time.Millisecond)
+
+	papers := []*models.Paper{{ID: "1", Title: "One"}, {ID: "2", Title: "Two"}}
+	n.NotifyPapers(context.Background(), papers)
+	n.NotifyPapers(context.Background(), papers)
+
+	if got := immediate.Sends(); len(got) != 4 {
+		t.Errorf("Expected a message per paper on the immediate channel, got %v", got)
+	}
+	if got := batched.Sends(); len(got) != 0 {
+		t.Errorf("Expected nothing sent before the window closes, got %v", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(batched.Sends()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := batched.Sends(); len(got) != 1 || got[0] != 4 {
+		t.Errorf("Expected one digest of 4 papers, got %v", got)
+	}
+
+	// Flush sends a pending digest without waiting for the window
+	slow := &recordingChannel{}
+	n.AddChannel(slow, time.Hour)
+	n.NotifyPapers(context.Background(), papers[:1])
+	n.Flush(context.Background())
+	if got := slow.Sends(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected flush to send the digest, got %v", got)
+	}
+}
+
+// memoryLog is a delivery log in memory
+type memoryLog struct {
+	mu   sync.Mutex
+	sent map[string]bool
+}
+
+func (l *memoryLog) UndeliveredPapers(channel string, paperIDs []string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ids []string
+	for _, id := range paperIDs {
+		if !l.sent[channel+"/"+id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (l *memoryLog) RecordDeliveries(channel string, paperIDs []string, digest bool, sendErr error, at time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range paperIDs {
+		if sendErr == nil {
+			l.sent[channel+"/"+id] = true
+		}
+	}
+	return nil
+}
+
+func TestDeliveryLogSkipsDeliveredPapers(t *testing.T) {
+	n, err := New(config.NotificationsConfig{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	dl := &memoryLog{sent: map[string]bool{"recording/1": true}}
+	n.SetDeliveryLog(dl)
+	immediate, batched := &recordingChannel{}, &batchedChannel{}
+	n.AddChannel(immediate, 0)
+	n.AddChannel(batched, time.Hour)
+
+	papers := []*models.Paper{{ID: "1", Title: "One"}, {ID: "2", Title: "Two"}}
+	n.NotifyPapers(context.Background(), papers)
+	n.NotifyPapers(context.Background(), papers)
+
+	if got := immediate.Sends(); len(got) != 1 {
+		t.Errorf("Expected only paper 2 sent once on the immediate channel, got %v", got)
+	}
+
+	// Fetches within the window queue each paper once
+	n.Flush(context.Background())
+	if got := batched.Sends(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Expected one digest of 2 papers, got %v", got)
+	}
+	n.NotifyPapers(context.Background(), papers)
+	n.Flush(context.Background())
+	if got := batched.Sends(); len(got) != 1 {
+		t.Errorf("Expected delivered papers not to be sent again, got %v", got)
+	}
+}
+
+// batchedChannel is a recording channel with its own name
+type batchedChannel struct{ recordingChannel }
+
+func (c *batchedChannel) Name() string { return "batched" }
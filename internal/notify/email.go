@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+)
+
+// Email sends messages as a plain-text email over SMTP
+type Email struct {
+	name      string
+	to        []string
+	from      string
+	transport Transport
+}
+
+// NewEmail creates an email channel
+func NewEmail(name string, to []string, smtpCfg config.SMTPConfig) *Email {
+	return &Email{
+		name:      name,
+		to:        to,
+		from:      smtpCfg.From,
+		transport: &smtpTransport{smtp: smtpCfg},
+	}
+}
+
+// Name returns the channel name
+func (e *Email) Name() string {
+	return e.name
+}
+
+// SetTransport replaces the mail server the channel sends through
+func (e *Email) SetTransport(t Transport) {
+	e.transport = t
+}
+
+// Render builds the email announcing all messages in one mail
+func (e *Email) Render(messages []Message) (Delivery, error) {
+	if len(e.to) == 0 {
+		return Delivery{}, fmt.Errorf("no recipients configured")
+	}
+	if len(messages) == 0 {
+		return Delivery{}, fmt.Errorf("no messages to send")
+	}
+
+	subject := fmt.Sprintf("[ArXiv Nest] %s", messages[0].Title)
+	if messages[0].Collection != "" {
+		subject = fmt.Sprintf("[ArXiv Nest] Added to %s: %s", messages[0].Collection, messages[0].Title)
+	}
+	if len(messages) > 1 {
+		subject = fmt.Sprintf("[ArXiv Nest] %d new papers", len(messages))
+	}
+
+	texts := make([]string, len(messages))
+	for i, m := range messages {
+		texts[i] = m.Text()
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", e.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(e.to, ", "))
+	// Titles may hold non-ASCII letters or line breaks
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	// Quoted-printable keeps lines short and 7-bit, and ends them with CRLF
+	// whichever line breaks the texts use
+	body.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&body)
+	if _, err := qp.Write([]byte(strings.Join(texts, "\n\n"))); err != nil {
+		return Delivery{}, fmt.Errorf("failed to encode email: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return Delivery{}, fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	return Delivery{
+		Channel:     e.name,
+		Type:        DeliveryEmail,
+		From:        e.from,
+		To:          e.to,
+		Subject:     subject,
+		ContentType: "message/rfc822",
+		Body:        body.String(),
+		At:          time.Now(),
+	}, nil
+}
+
+// Send emails all messages in one mail
+func (e *Email) Send(ctx context.Context, messages []Message) error {
+	d, err := e.Render(messages)
+	if err != nil {
+		return err
+	}
+	return e.transport.Deliver(ctx, d)
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// minExcerptWords is the shortest lead sentence used on its own; shorter
+	// ones ("We study X.") are joined with the following sentence
+	minExcerptWords = 8
+
+	// maxExcerptLength caps the excerpt in characters
+	maxExcerptLength = 280
+)
+
+// abbreviations that end with a period but don't end a sentence
+var abbreviations = map[string]bool{
+	"e.g.": true, "i.e.": true, "al.": true, "etc.": true, "vs.": true,
+	"cf.": true, "fig.": true, "figs.": true, "eq.": true, "eqs.": true,
+	"sec.": true, "approx.": true, "resp.": true, "no.": true, "ref.": true,
+}
+
+// Excerpt returns a short extractive summary of an abstract: its lead
+// sentence (plus the next one if the lead is very short), truncated at a
+// word boundary to keep chat notifications compact
+func Excerpt(abstract string) string {
+	sentences := splitSentences(strings.TrimSpace(abstract))
+	if len(sentences) == 0 {
+		return ""
+	}
+
+	excerpt := sentences[0]
+	if len(strings.Fields(excerpt)) < minExcerptWords && len(sentences) > 1 {
+		excerpt += " " + sentences[1]
+	}
+
+	return truncate(excerpt, maxExcerptLength)
+}
+
+// splitSentences splits text on sentence-ending punctuation followed by
+// whitespace and an uppercase letter, digit or LaTeX math
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '.' && c != '!' && c != '?' {
+			continue
+		}
+
+		// Must be followed by whitespace and the start of a new sentence
+		j := i + 1
+		if j >= len(text) || text[j] != ' ' {
+			continue
+		}
+		for j < len(text) && text[j] == ' ' {
+			j++
+		}
+		if j >= len(text) {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(text[j:])
+		if !unicode.IsUpper(next) && !unicode.IsDigit(next) && next != '$' {
+			continue
+		}
+
+		if c == '.' && isAbbreviation(text[start:i+1]) {
+			continue
+		}
+
+		sentences = append(sentences, strings.TrimSpace(text[start:i+1]))
+		start = j
+		i = j - 1
+	}
+
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+
+	return sentences
+}
+
+// isAbbreviation reports whether the text ends in a known abbreviation or
+// an initial such as the "J." in "J. Smith"
+func isAbbreviation(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+	last := strings.ToLower(fields[len(fields)-1])
+	last = strings.TrimLeft(last, "(")
+
+	if abbreviations[last] {
+		return true
+	}
+
+	// Single-letter initials
+	return utf8.RuneCountInString(last) == 2
+}
+
+// truncate shortens text to at most max characters at a word boundary
+func truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:max-1])
+	if i := strings.LastIndex(cut, " "); i > max/2 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " ,;:") + "…"
+}
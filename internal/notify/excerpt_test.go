@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		abstract string
+		expected string
+	}{
+		{
+			name:     "lead sentence",
+			abstract: "We propose a new method for training large language models efficiently. It works well. Experiments confirm this.",
+			expected: "We propose a new method for training large language models efficiently.",
+		},
+		{
+			name:     "short lead joined with next sentence",
+			abstract: "We study transformers. Our analysis shows that attention heads specialize during training. More follows.",
+			expected: "We study transformers. Our analysis shows that attention heads specialize during training.",
+		},
+		{
+			name:     "abbreviations do not split",
+			abstract: "Prior work, e.g. Smith et al. 2020, relies on labels that are expensive to collect at scale. We remove them.",
+			expected: "Prior work, e.g. Smith et al. 2020, relies on labels that are expensive to collect at scale.",
+		},
+		{
+			name:     "decimals and initials do not split",
+			abstract: "Following J. Doe we reach 93.5% accuracy on the benchmark with a much smaller model. Code is released.",
+			expected: "Following J. Doe we reach 93.5% accuracy on the benchmark with a much smaller model.",
+		},
+		{
+			name:     "single sentence without period",
+			abstract: "A short abstract without punctuation",
+			expected: "A short abstract without punctuation",
+		},
+		{
+			name:     "empty",
+			abstract: "   ",
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		result := Excerpt(test.abstract)
+		if result != test.expected {
+			t.Errorf("%s: Excerpt() = %q, expected %q", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestExcerptTruncatesLongSentences(t *testing.T) {
+	abstract := strings.Repeat("word ", 100) + "end."
+
+	result := Excerpt(abstract)
+	if utf8.RuneCountInString(result) > maxExcerptLength {
+		t.Errorf("Expected excerpt of at most %d characters, got %d", maxExcerptLength, utf8.RuneCountInString(result))
+	}
+	if !strings.HasSuffix(result, "…") {
+		t.Errorf("Expected truncated excerpt to end with an ellipsis, got %q", result)
+	}
+}
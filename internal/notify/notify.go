@@ -0,0 +1,321 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// Message is a single paper announcement sent to a channel
+type Message struct {
+	PaperID string `json:"id"`
+	Title   string `json:"title"`
+	Authors string `json:"authors"`
+	URL     string `json:"url"`
+	Summary string `json:"summary"`
+
+	// Collection names the tag or shelf the paper was added to, for
+	// announcements of additions rather than of new papers
+	Collection string `json:"collection,omitempty"`
+}
+
+// Channel delivers messages to one destination (a webhook, a mailbox)
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, messages []Message) error
+}
+
+// DeliveryLog remembers which papers were announced on which channel, so
+// a paper is announced at most once per channel however often it is
+// fetched. Channels are identified by name.
+type DeliveryLog interface {
+	// UndeliveredPapers returns the paper IDs not yet sent to the channel
+	UndeliveredPapers(channel string, paperIDs []string) ([]string, error)
+	// RecordDeliveries records that sending the papers succeeded, or
+	// failed with sendErr
+	RecordDeliveries(channel string, paperIDs []string, digest bool, sendErr error, at time.Time) error
+}
+
+// Notifier announces newly fetched papers on the configured channels
+type Notifier struct {
+	channels []Channel
+	// batchers holds each channel's digest batcher, nil for channels that
+	// get one message per paper
+	batchers []*batcher
+	excerpt  bool
+	log      DeliveryLog
+	// recorder keeps what would have been sent in dry-run mode
+	recorder *Recorder
+}
+
+// dryRunHistory is how many deliveries a dry run keeps
+const dryRunHistory = 50
+
+// ErrUnknownChannel is returned for a test of a channel that isn't
+// configured
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+// New creates a notifier from configuration. Channels with an unknown type
+// are reported as an error. In dry-run mode the channels render their
+// messages as usual but hand them to a Recorder instead of sending them.
+func New(cfg config.NotificationsConfig) (*Notifier, error) {
+	n := &Notifier{excerpt: cfg.Excerpt}
+	if cfg.DryRun {
+		n.recorder = NewRecorder(dryRunHistory)
+	}
+
+	for _, ch := range cfg.Channels {
+		var channel TransportChannel
+		switch ch.Type {
+		case "webhook":
+			channel = NewWebhook(ch.Name, ch.URL)
+		case "email":
+			channel = NewEmail(ch.Name, ch.To, cfg.SMTP)
+		default:
+			return nil, fmt.Errorf("unknown notification channel type %q for %q", ch.Type, ch.Name)
+		}
+		if n.recorder != nil {
+			channel.SetTransport(n.recorder)
+		}
+		n.AddChannel(channel, ch.Batch)
+	}
+
+	return n, nil
+}
+
+// AddChannel adds a channel. With a positive batch window, papers are
+// collected for that long after the first one and sent as one digest.
+func (n *Notifier) AddChannel(channel Channel, batch time.Duration) {
+	var b *batcher
+	if batch > 0 {
+		b = newBatcher(channel, batch, n.record)
+	}
+	n.channels = append(n.channels, channel)
+	n.batchers = append(n.batchers, b)
+}
+
+// SetDeliveryLog makes the notifier skip papers already announced on a
+// channel and record each delivery. Without one every paper passed to
+// NotifyPapers is sent.
+func (n *Notifier) SetDeliveryLog(l DeliveryLog) {
+	n.log = l
+}
+
+// Enabled reports whether any channel is configured
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.channels) > 0
+}
+
+// DryRun reports whether deliveries are recorded instead of sent
+func (n *Notifier) DryRun() bool {
+	return n != nil && n.recorder != nil
+}
+
+// Recorded returns the deliveries a dry run kept, most recent first
+func (n *Notifier) Recorded() []Delivery {
+	if !n.DryRun() {
+		return nil
+	}
+	return n.recorder.Deliveries()
+}
+
+// Channels returns the names of the channels, in configuration order
+func (n *Notifier) Channels() []string {
+	if n == nil {
+		return nil
+	}
+	names := make([]string, len(n.channels))
+	for i, ch := range n.channels {
+		names[i] = ch.Name()
+	}
+	return names
+}
+
+// Test renders the message the named channel would send, and with send
+// set sends it right away, bypassing digests and the delivery log. In
+// dry-run mode "sending" records it like any other delivery. The rendered
+// delivery is returned even if sending fails.
+func (n *Notifier) Test(ctx context.Context, channel string, msg Message, send bool) (Delivery, error) {
+	if n != nil {
+		for _, ch := range n.channels {
+			if ch.Name() != channel {
+				continue
+			}
+			tc, ok := ch.(TransportChannel)
+			if !ok {
+				return Delivery{}, fmt.Errorf("channel %q can't render test messages", channel)
+			}
+			d, err := tc.Render([]Message{msg})
+			if err != nil || !send {
+				return d, err
+			}
+			return d, ch.Send(ctx, []Message{msg})
+		}
+	}
+	return Delivery{}, fmt.Errorf("%w %q", ErrUnknownChannel, channel)
+}
+
+// NotifyPapers sends one message per paper to every channel, or queues
+// the papers for the next digest on batched channels. Papers the delivery
+// log has already seen sent to a channel are skipped there. Delivery
+// failures are logged and don't stop other channels.
+func (n *Notifier) NotifyPapers(ctx context.Context, papers []*models.Paper) {
+	if !n.Enabled() || len(papers) == 0 {
+		return
+	}
+
+	messages := make([]Message, len(papers))
+	for i, paper := range papers {
+		messages[i] = n.MessageFor(paper)
+	}
+
+	for i, ch := range n.channels {
+		pending := n.undelivered(ch.Name(), messages)
+		if b := n.batchers[i]; b != nil {
+			// Two fetches within the window may both announce a paper
+			b.add(pending, n.log != nil)
+			continue
+		}
+		for _, msg := range pending {
+			err := ch.Send(ctx, []Message{msg})
+			if err != nil {
+				log.Printf("Error notifying %s about %s: %v", ch.Name(), msg.PaperID, err)
+			}
+			n.record(ch.Name(), []Message{msg}, false, err)
+		}
+	}
+}
+
+// NotifyCollected announces papers added to a tag or shelf, described by
+// collection (e.g. "tag must-read"), on the named channels subscribed to
+// it: one message per paper, or queued for the next digest on batched
+// channels. Unlike new papers, additions aren't checked against or
+// recorded in the delivery log, as a paper may be added to any number of
+// tags and shelves. Channels that aren't configured are skipped.
+func (n *Notifier) NotifyCollected(ctx context.Context, channels []string, collection string, papers []*models.Paper) {
+	if !n.Enabled() || len(papers) == 0 {
+		return
+	}
+
+	messages := make([]Message, len(papers))
+	for i, paper := range papers {
+		messages[i] = n.MessageFor(paper)
+		messages[i].Collection = collection
+	}
+
+	for i, ch := range n.channels {
+		if !slices.Contains(channels, ch.Name()) {
+			continue
+		}
+		if b := n.batchers[i]; b != nil {
+			b.add(messages, false)
+			continue
+		}
+		for _, msg := range messages {
+			if err := ch.Send(ctx, []Message{msg}); err != nil {
+				log.Printf("Error notifying %s about %s added to %s: %v", ch.Name(), msg.PaperID, collection, err)
+			}
+		}
+	}
+}
+
+// undelivered drops the messages about papers already sent to the channel.
+// If the log can't be read, everything is sent: a repeated announcement is
+// better than a missing one.
+func (n *Notifier) undelivered(channel string, messages []Message) []Message {
+	if n.log == nil {
+		return messages
+	}
+
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.PaperID
+	}
+	pending, err := n.log.UndeliveredPapers(channel, ids)
+	if err != nil {
+		log.Printf("Error reading delivery log for %s: %v", channel, err)
+		return messages
+	}
+
+	keep := make(map[string]bool, len(pending))
+	for _, id := range pending {
+		keep[id] = true
+	}
+	var out []Message
+	for _, msg := range messages {
+		if keep[msg.PaperID] {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// record logs the outcome of sending messages to a channel, leaving out
+// announcements of additions to tags and shelves. Nothing is logged in
+// dry-run mode, so the papers are still announced once the dry run is
+// turned off.
+func (n *Notifier) record(channel string, messages []Message, digest bool, sendErr error) {
+	if n.log == nil || n.recorder != nil {
+		return
+	}
+	var ids []string
+	for _, msg := range messages {
+		if msg.Collection == "" {
+			ids = append(ids, msg.PaperID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := n.log.RecordDeliveries(channel, ids, digest, sendErr, time.Now()); err != nil {
+		log.Printf("Error recording deliveries to %s: %v", channel, err)
+	}
+}
+
+// Flush sends the digests of batched channels now instead of when their
+// windows close, e.g. before the process exits
+func (n *Notifier) Flush(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	for _, b := range n.batchers {
+		if b == nil {
+			continue
+		}
+		if err := b.flush(ctx); err != nil {
+			log.Printf("Error sending digest to %s: %v", b.channel.Name(), err)
+		}
+	}
+}
+
+// MessageFor builds the message announcing a paper. With excerpts enabled
+// the summary is the abstract's lead sentence rather than the full text.
+func (n *Notifier) MessageFor(paper *models.Paper) Message {
+	summary := paper.Abstract
+	if n.excerpt {
+		summary = Excerpt(paper.Abstract)
+	}
+
+	return Message{
+		PaperID: paper.ID,
+		Title:   paper.Title,
+		Authors: paper.Authors,
+		URL:     paper.ArxivUrl,
+		Summary: summary,
+	}
+}
+
+// Text renders a message as plain text (used for chat and email bodies)
+func (m Message) Text() string {
+	text := fmt.Sprintf("%s\n%s\n%s\n%s", m.Title, m.Authors, m.Summary, m.URL)
+	if m.Collection != "" {
+		text = "Added to " + m.Collection + "\n" + text
+	}
+	return text
+}
@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+)
+
+// Delivery types
+const (
+	DeliveryEmail   = "email"
+	DeliveryWebhook = "webhook"
+)
+
+// Delivery is a rendered notification, exactly as a channel hands it to
+// its transport
+type Delivery struct {
+	Channel string
+	Type    string
+	// URL is the address a webhook posts to
+	URL string
+	// From, To and Subject are set on emails
+	From    string
+	To      []string
+	Subject string
+	// ContentType is the type of Body
+	ContentType string
+	// Body is the whole email, headers included, or the webhook's payload
+	Body string
+	At   time.Time
+}
+
+// Destination describes where the delivery goes: the email recipients, or
+// the webhook's host. Webhook paths often embed a token, so the rest of
+// the URL is left out.
+func (d Delivery) Destination() string {
+	if d.Type == DeliveryEmail {
+		return strings.Join(d.To, ", ")
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
+
+// Transport carries rendered deliveries to their destination
+type Transport interface {
+	Deliver(ctx context.Context, d Delivery) error
+}
+
+// TransportChannel is a channel that renders messages into a Delivery and
+// hands it to a replaceable transport. Dry runs and test notifications
+// need one; the built-in channels all are.
+type TransportChannel interface {
+	Channel
+	Render(messages []Message) (Delivery, error)
+	SetTransport(t Transport)
+}
+
+// smtpTransport sends emails through the configured mail server
+type smtpTransport struct {
+	smtp config.SMTPConfig
+}
+
+// Deliver sends the rendered email to its recipients
+func (t *smtpTransport) Deliver(ctx context.Context, d Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(t.smtp.Host, strconv.Itoa(t.smtp.Port))
+
+	var auth smtp.Auth
+	if t.smtp.Username != "" {
+		auth = smtp.PlainAuth("", t.smtp.Username, t.smtp.Password, t.smtp.Host)
+	}
+
+	if err := smtp.SendMail(addr, auth, d.From, d.To, []byte(d.Body)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// httpTransport posts webhook payloads
+type httpTransport struct {
+	client *http.Client
+}
+
+// Deliver posts the payload to the delivery's URL
+func (t *httpTransport) Deliver(ctx context.Context, d Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", d.URL, bytes.NewReader([]byte(d.Body)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", d.ContentType)
+	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// Recorder is a transport that keeps deliveries instead of sending them,
+// for dry runs and tests. Only the most recent ones are kept.
+type Recorder struct {
+	mu         sync.Mutex
+	size       int
+	deliveries []Delivery
+}
+
+// NewRecorder creates a recorder keeping the last size deliveries
+func NewRecorder(size int) *Recorder {
+	return &Recorder{size: size}
+}
+
+// Deliver records the delivery
+func (r *Recorder) Deliver(ctx context.Context, d Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, d)
+	if len(r.deliveries) > r.size {
+		r.deliveries = r.deliveries[len(r.deliveries)-r.size:]
+	}
+	return nil
+}
+
+// Deliveries returns the recorded deliveries, most recent first
+func (r *Recorder) Deliveries() []Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Delivery, len(r.deliveries))
+	for i, d := range r.deliveries {
+		out[len(out)-1-i] = d
+	}
+	return out
+}
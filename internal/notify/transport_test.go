@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestDryRunRecordsDeliveries(t *testing.T) {
+	n, err := New(config.NotificationsConfig{
+		DryRun: true,
+		Channels: []config.NotificationChannel{
+			{Name: "slack", Type: "webhook", URL: "https://hooks.example.com/services/T000/SECRET"},
+			{Name: "me", Type: "email", To: []string{"me@example.com"}},
+		},
+		SMTP: config.SMTPConfig{Host: "smtp.invalid", Port: 587, From: "nest@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	dl := &memoryLog{sent: map[string]bool{}}
+	n.SetDeliveryLog(dl)
+
+	n.NotifyPapers(context.Background(), []*models.Paper{{ID: "1", Title: "One", ArxivUrl: "https://arxiv.org/abs/1"}})
+
+	recorded := n.Recorded()
+	if len(recorded) != 2 {
+		t.Fatalf("Expected a delivery per channel to be recorded, got %+v", recorded)
+	}
+	email, webhook := recorded[0], recorded[1]
+	if email.Type != DeliveryEmail || email.Subject != "[ArXiv Nest] One" || !strings.Contains(email.Body, "To: me@example.com\r\n") {
+		t.Errorf("Expected the rendered email, got %+v", email)
+	}
+	if webhook.Type != DeliveryWebhook || !strings.Contains(webhook.Body, `"title":"One"`) {
+		t.Errorf("Expected the rendered payload, got %+v", webhook)
+	}
+	if got := webhook.Destination(); got != "https://hooks.example.com/…" {
+		t.Errorf("Expected the webhook's token left out, got %q", got)
+	}
+	if len(dl.sent) != 0 {
+		t.Errorf("Expected nothing logged as delivered in a dry run, got %v", dl.sent)
+	}
+
+	// A test sends through the same transport, and a preview doesn't
+	d, err := n.Test(context.Background(), "me", Message{PaperID: "2", Title: "Two"}, false)
+	if err != nil || d.Subject != "[ArXiv Nest] Two" {
+		t.Errorf("Expected the test email rendered, got %+v, %v", d, err)
+	}
+	if len(n.Recorded()) != 2 {
+		t.Error("Expected a preview not to be recorded")
+	}
+	if _, err := n.Test(context.Background(), "me", Message{PaperID: "2", Title: "Two"}, true); err != nil {
+		t.Errorf("Test failed: %v", err)
+	}
+	if got := n.Recorded(); len(got) != 3 || got[0].Subject != "[ArXiv Nest] Two" {
+		t.Errorf("Expected the test recorded first, got %+v", got)
+	}
+	if _, err := n.Test(context.Background(), "nope", Message{}, false); !errors.Is(err, ErrUnknownChannel) {
+		t.Errorf("Expected ErrUnknownChannel, got %v", err)
+	}
+}
+
+func TestRecorderKeepsTheLatest(t *testing.T) {
+	r := NewRecorder(2)
+	for _, channel := range []string{"a", "b", "c"} {
+		r.Deliver(context.Background(), Delivery{Channel: channel})
+	}
+	got := r.Deliveries()
+	if len(got) != 2 || got[0].Channel != "c" || got[1].Channel != "b" {
+		t.Errorf("Expected c and b, got %+v", got)
+	}
+}
+
+func TestEmailSubjectEncoding(t *testing.T) {
+	e := NewEmail("me", []string{"me@example.com"}, config.SMTPConfig{From: "nest@example.com"})
+	d, err := e.Render([]Message{{PaperID: "1", Title: "Schrödinger bridges\n for diffusion"}})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	header, _, _ := strings.Cut(d.Body, "\r\n\r\n")
+	subject := header[strings.Index(header, "Subject: "):]
+	subject, _, _ = strings.Cut(subject, "\r\n")
+	if !strings.HasPrefix(subject, "Subject: =?utf-8?q?") || strings.ContainsAny(subject, "ö\n") {
+		t.Errorf("Expected an encoded subject header, got %q", subject)
+	}
+	if d.Subject != "[ArXiv Nest] Schrödinger bridges\n for diffusion" {
+		t.Errorf("Expected the recorded subject as written, got %q", d.Subject)
+	}
+}
+
+func TestEmailBodyEncoding(t *testing.T) {
+	e := NewEmail("me", []string{"me@example.com"}, config.SMTPConfig{From: "nest@example.com"})
+	d, err := e.Render([]Message{
+		{PaperID: "1", Title: "Schrödinger bridges\n for diffusion"},
+		{PaperID: "2", Title: "Second paper"},
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	header, body, _ := strings.Cut(d.Body, "\r\n\r\n")
+	if !strings.Contains(header, "\r\nContent-Transfer-Encoding: quoted-printable") {
+		t.Errorf("Expected a quoted-printable body, got headers %q", header)
+	}
+	if strings.ContainsAny(body, "ö") || strings.Contains(strings.ReplaceAll(body, "\r\n", ""), "\n") {
+		t.Errorf("Expected a 7-bit body with CRLF line endings only, got %q", body)
+	}
+
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if !strings.Contains(string(decoded), "Schrödinger bridges") || !strings.Contains(string(decoded), "Second paper") {
+		t.Errorf("Expected both papers in the decoded body, got %q", decoded)
+	}
+}
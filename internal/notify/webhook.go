@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Webhook posts messages as JSON. The top-level "text" field makes the
+// payload directly usable with Slack, Mattermost and Discord-style hooks.
+type Webhook struct {
+	name      string
+	url       string
+	transport Transport
+}
+
+// webhookPayload is the JSON body posted to webhooks
+type webhookPayload struct {
+	Text   string    `json:"text"`
+	Papers []Message `json:"papers"`
+}
+
+// NewWebhook creates a webhook channel
+func NewWebhook(name, url string) *Webhook {
+	return &Webhook{
+		name: name,
+		url:  url,
+		transport: &httpTransport{client: &http.Client{
+			Timeout: 15 * time.Second,
+		}},
+	}
+}
+
+// Name returns the channel name
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// SetTransport replaces the HTTP client the channel posts with
+func (w *Webhook) SetTransport(t Transport) {
+	w.transport = t
+}
+
+// Render builds the payload announcing the messages in a single request
+func (w *Webhook) Render(messages []Message) (Delivery, error) {
+	texts := make([]string, len(messages))
+	for i, m := range messages {
+		texts[i] = m.Text()
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Text:   strings.Join(texts, "\n\n"),
+		Papers: messages,
+	})
+	if err != nil {
+		return Delivery{}, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	return Delivery{
+		Channel:     w.name,
+		Type:        DeliveryWebhook,
+		URL:         w.url,
+		ContentType: "application/json",
+		Body:        string(body),
+		At:          time.Now(),
+	}, nil
+}
+
+// Send posts the messages in a single request
+func (w *Webhook) Send(ctx context.Context, messages []Message) error {
+	d, err := w.Render(messages)
+	if err != nil {
+		return err
+	}
+	return w.transport.Deliver(ctx, d)
+}
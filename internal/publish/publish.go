@@ -0,0 +1,313 @@
+// Package publish renders library papers as a static HTML site with an
+// index, a page per paper and per tag, and an Atom feed, suitable for
+// GitHub Pages or any static file host.
+package publish
+
+import (
+	"bytes"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"paperFile": paperFile,
+}).ParseFS(templateFS, "templates/*.html"))
+
+// Options controls the generated site
+type Options struct {
+	// Dir is the output directory, created if missing. The papers and tags
+	// subdirectories belong to the site: pages there that are no longer
+	// generated are removed.
+	Dir   string
+	Title string
+
+	// BaseURL is where the site will be served, e.g.
+	// "https://me.github.io/papers/". The feed needs it for links to the
+	// site's pages; without it entries link to arXiv.
+	BaseURL string
+
+	// Notes includes each paper's "why saved" note, which is otherwise
+	// kept private
+	Notes bool
+}
+
+// Result summarizes a published site
+type Result struct {
+	Papers int
+	Tags   int
+}
+
+// tagPage is a tag with its page and papers
+type tagPage struct {
+	Name   string
+	File   string
+	Papers []models.Paper
+}
+
+// pageData is passed to the page templates. Root is the relative path from
+// the page to the site root.
+type pageData struct {
+	Site      string
+	Title     string
+	Root      string
+	Papers    []models.Paper
+	Paper     *models.Paper
+	Tags      []*tagPage
+	TagFiles  map[string]string
+	Notes     bool
+	Generated time.Time
+}
+
+// Write generates the site for papers, newest first, in opts.Dir
+func Write(papers []models.Paper, opts Options, now time.Time) (*Result, error) {
+	if opts.Title == "" {
+		opts.Title = "Reading List"
+	}
+	papers = append([]models.Paper(nil), papers...)
+	sort.SliceStable(papers, func(i, j int) bool {
+		return papers[i].PublishedAt.After(papers[j].PublishedAt)
+	})
+
+	tags := collectTags(papers)
+	tagFiles := make(map[string]string, len(tags))
+	for _, t := range tags {
+		tagFiles[t.Name] = t.File
+	}
+
+	base := pageData{Site: opts.Title, Tags: tags, TagFiles: tagFiles, Notes: opts.Notes, Generated: now}
+	written := make(map[string]bool)
+	render := func(file, tmpl string, data pageData) error {
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, tmpl, data); err != nil {
+			return fmt.Errorf("failed to render %s: %w", file, err)
+		}
+		written[filepath.FromSlash(file)] = true
+		return writeFile(opts.Dir, file, buf.Bytes())
+	}
+
+	index := base
+	index.Title, index.Papers = opts.Title, papers
+	if err := render("index.html", "index.html", index); err != nil {
+		return nil, err
+	}
+
+	for i := range papers {
+		page := base
+		page.Title, page.Root, page.Paper = papers[i].Title, "../", &papers[i]
+		if err := render(paperFile(papers[i].ID), "paper.html", page); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range tags {
+		page := base
+		page.Title, page.Root, page.Papers = t.Name, "../", t.Papers
+		if err := render(t.File, "tag.html", page); err != nil {
+			return nil, err
+		}
+	}
+
+	feed, err := AtomFeed(papers, opts, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(opts.Dir, "feed.xml", feed); err != nil {
+		return nil, err
+	}
+
+	// GitHub Pages would otherwise run the files through Jekyll
+	if err := writeFile(opts.Dir, ".nojekyll", nil); err != nil {
+		return nil, err
+	}
+
+	for _, sub := range []string{"papers", "tags"} {
+		if err := removeStale(opts.Dir, sub, written); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{Papers: len(papers), Tags: len(tags)}, nil
+}
+
+// collectTags groups papers by tag, by tag name. Tags are given their
+// files in that order, so the file names are stable between runs.
+func collectTags(papers []models.Paper) []*tagPage {
+	byName := make(map[string]*tagPage)
+	var tags []*tagPage
+	for _, p := range papers {
+		for _, tag := range p.Tags {
+			t, ok := byName[tag.Name]
+			if !ok {
+				t = &tagPage{Name: tag.Name}
+				byName[tag.Name] = t
+				tags = append(tags, t)
+			}
+			t.Papers = append(t.Papers, p)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+
+	used := make(map[string]bool)
+	for _, t := range tags {
+		t.File = uniqueFile("tags/"+slug(t.Name), used)
+	}
+	return tags
+}
+
+// paperFile is the page of a paper, relative to the site root. Old-style
+// arXiv IDs such as "hep-th/9901001" contain a slash, and other sources'
+// IDs ("biorxiv:2024.01.15.575123") a colon, which a relative link would
+// take for a URL scheme.
+func paperFile(id string) string {
+	return "papers/" + strings.NewReplacer("/", "_", ":", "_").Replace(id) + ".html"
+}
+
+// slug makes a file name from a tag name
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+	if s == "" {
+		s = "tag"
+	}
+	return s
+}
+
+// uniqueFile returns name.html, or name-2.html and so on if taken
+func uniqueFile(name string, used map[string]bool) string {
+	file := name + ".html"
+	for n := 2; used[file]; n++ {
+		file = fmt.Sprintf("%s-%d.html", name, n)
+	}
+	used[file] = true
+	return file
+}
+
+// writeFile writes a file below dir, creating directories as needed
+func writeFile(dir, name string, data []byte) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeStale deletes pages in a site subdirectory that were not written
+// this time, e.g. of papers removed from the library
+func removeStale(dir, sub string, written map[string]bool) error {
+	entries, err := os.ReadDir(filepath.Join(dir, sub))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		name := filepath.Join(sub, e.Name())
+		if e.IsDir() || filepath.Ext(name) != ".html" || written[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to remove stale page %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// atomLink, atomEntry and atomFeedXML are the parts of an Atom feed
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published"`
+	Author    string   `xml:"author>name"`
+	Summary   string   `xml:"summary"`
+}
+
+type atomFeedXML struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// AtomFeed renders papers as an Atom feed. Entries are identified by their
+// arXiv URL, so they stay the same if the site moves. Only the title,
+// base URL and notes options apply; without a base URL entries link to
+// arXiv, so the feed can be served outside a published site too.
+func AtomFeed(papers []models.Paper, opts Options, now time.Time) ([]byte, error) {
+	feed := atomFeedXML{
+		ID:      "urn:arxiv-nest:" + slug(opts.Title),
+		Title:   opts.Title,
+		Updated: now.UTC().Format(time.RFC3339),
+	}
+	base := opts.BaseURL
+	if base != "" {
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		feed.ID = base
+		feed.Links = []atomLink{{Href: base}, {Href: base + "feed.xml", Rel: "self"}}
+	}
+
+	for _, p := range papers {
+		link := p.ArxivUrl
+		if base != "" {
+			link = base + paperFile(p.ID)
+		}
+		summary := p.Abstract
+		if opts.Notes && p.Note != "" {
+			summary = p.Note + "\n\n" + summary
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        "https://arxiv.org/abs/" + p.ID,
+			Title:     p.Title,
+			Link:      atomLink{Href: link},
+			Updated:   p.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: p.PublishedAt.UTC().Format(time.RFC3339),
+			Author:    p.Authors,
+			Summary:   summary,
+		})
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render feed: %w", err)
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
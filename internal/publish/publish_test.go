@@ -0,0 +1,84 @@
+package publish
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	papers := []models.Paper{
+		{
+			ID: "2301.00001", Title: "Older <Paper>", Authors: "Ada Lovelace", Abstract: "About engines.",
+			ArxivUrl: "http://arxiv.org/abs/2301.00001", PublishedAt: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+			Note: "private thoughts", Tags: []models.Tag{{Name: "Graph Neural Nets"}},
+		},
+		{
+			ID: "hep-th/9901001", Title: "Newer Paper", Authors: "Alan Turing",
+			ArxivUrl: "http://arxiv.org/abs/hep-th/9901001", PublishedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			Tags: []models.Tag{{Name: "graph neural nets!"}},
+		},
+	}
+
+	// A page left over from an earlier run, and a file the site doesn't own
+	os.MkdirAll(filepath.Join(dir, "papers"), 0755)
+	os.WriteFile(filepath.Join(dir, "papers", "gone.html"), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(dir, "CNAME"), []byte("papers.example.com"), 0644)
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	result, err := Write(papers, Options{Dir: dir, Title: "My Papers", BaseURL: "https://me.github.io/papers"}, now)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if result.Papers != 2 || result.Tags != 2 {
+		t.Errorf("Expected 2 papers and 2 tags, got %+v", result)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Expected %s: %v", name, err)
+		}
+		return string(data)
+	}
+
+	index := read("index.html")
+	if strings.Index(index, "Newer Paper") > strings.Index(index, "Older &lt;Paper&gt;") {
+		t.Error("Expected newest paper first, with titles escaped")
+	}
+	if !strings.Contains(index, `href="papers/hep-th_9901001.html"`) || !strings.Contains(index, `href="tags/graph-neural-nets-2.html"`) {
+		t.Errorf("Expected links to paper and tag pages, got:\n%s", index)
+	}
+	if strings.Contains(index, "private thoughts") {
+		t.Error("Expected notes to stay private unless requested")
+	}
+
+	if page := read("papers/2301.00001.html"); !strings.Contains(page, "About engines.") || !strings.Contains(page, `href="../tags/graph-neural-nets.html"`) {
+		t.Errorf("Expected the paper page with its abstract and tag links, got:\n%s", page)
+	}
+	if tag := read("tags/graph-neural-nets.html"); !strings.Contains(tag, "Older &lt;Paper&gt;") || strings.Contains(tag, "Newer Paper") {
+		t.Errorf("Expected the tag page to list only its papers, got:\n%s", tag)
+	}
+
+	var feed atomFeedXML
+	if err := xml.Unmarshal([]byte(read("feed.xml")), &feed); err != nil {
+		t.Fatalf("Expected a valid feed: %v", err)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[0].Link.Href != "https://me.github.io/papers/papers/hep-th_9901001.html" {
+		t.Errorf("Expected entries linking to the site, got %+v", feed.Entries)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "papers", "gone.html")); !os.IsNotExist(err) {
+		t.Error("Expected the stale paper page to be removed")
+	}
+	if read("CNAME") != "papers.example.com" {
+		t.Error("Expected files outside the site's directories to be kept")
+	}
+	read(".nojekyll")
+}
@@ -0,0 +1,222 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxPageSize caps how much of a proxied page is read (HTML papers with
+// inline MathML can be large, but anything beyond this is not an article)
+const maxPageSize = 20 << 20
+
+// droppedElements are removed together with their content
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "iframe": true,
+	"object": true, "embed": true, "form": true, "button": true,
+	"nav": true, "header": true, "footer": true, "link": true, "meta": true,
+	"input": true, "select": true, "textarea": true, "svg": true,
+}
+
+// allowedElements are kept in the output; anything else is unwrapped
+// (its children are rendered without the element itself)
+var allowedElements = map[string]bool{
+	"article": true, "section": true, "div": true, "span": true, "p": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"a": true, "em": true, "strong": true, "b": true, "i": true, "u": true,
+	"sub": true, "sup": true, "small": true, "cite": true, "q": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true,
+	"td": true, "th": true, "caption": true, "colgroup": true, "col": true,
+	"figure": true, "figcaption": true, "img": true, "br": true, "hr": true,
+	"blockquote": true, "pre": true, "code": true,
+
+	// MathML, used by arXiv's LaTeXML renderings for all equations
+	"math": true, "semantics": true, "annotation": true, "annotation-xml": true,
+	"mrow": true, "mi": true, "mo": true, "mn": true, "mtext": true, "ms": true,
+	"mspace": true, "msub": true, "msup": true, "msubsup": true, "mfrac": true,
+	"msqrt": true, "mroot": true, "mover": true, "munder": true,
+	"munderover": true, "mtable": true, "mtr": true, "mtd": true,
+	"mstyle": true, "mpadded": true, "mphantom": true, "menclose": true,
+	"mmultiscripts": true, "mprescripts": true, "none": true,
+}
+
+// voidElements have no closing tag
+var voidElements = map[string]bool{
+	"img": true, "br": true, "hr": true, "col": true,
+}
+
+// Fetch downloads an HTML rendering and returns its sanitized article content
+func Fetch(ctx context.Context, client *http.Client, pageURL string) (template.HTML, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid page URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	// Relative links resolve against the final URL after redirects
+	if resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL
+	}
+
+	return Extract(io.LimitReader(resp.Body, maxPageSize), base)
+}
+
+// Extract parses an HTML document and returns the sanitized content of its
+// <article> element (or <body> if there is none), with relative URLs made
+// absolute against base
+func Extract(r io.Reader, base *url.URL) (template.HTML, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	// Honour <base href>, which arXiv uses to anchor figure paths
+	if b := findElement(doc, "base"); b != nil && base != nil {
+		for _, attr := range b.Attr {
+			if attr.Key == "href" {
+				if u, err := url.Parse(attr.Val); err == nil {
+					base = base.ResolveReference(u)
+				}
+			}
+		}
+	}
+
+	root := findElement(doc, "article")
+	if root == nil {
+		root = findElement(doc, "body")
+	}
+	if root == nil {
+		return "", fmt.Errorf("no article content found")
+	}
+
+	var b strings.Builder
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		render(&b, c, base)
+	}
+
+	return template.HTML(b.String()), nil
+}
+
+// findElement returns the first element with the given tag in document order
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// render writes a sanitized copy of n to b
+func render(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+		// handled below
+	default:
+		return
+	}
+
+	tag := n.Data
+	if droppedElements[tag] {
+		return
+	}
+
+	if !allowedElements[tag] {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(b, c, base)
+		}
+		return
+	}
+
+	b.WriteString("<" + tag)
+	for _, attr := range n.Attr {
+		value, ok := sanitizeAttr(tag, attr, base)
+		if !ok {
+			continue
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+	}
+	if tag == "a" {
+		b.WriteString(` target="_blank" rel="noopener noreferrer"`)
+	}
+	b.WriteString(">")
+
+	if voidElements[tag] {
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(b, c, base)
+	}
+	b.WriteString("</" + tag + ">")
+}
+
+// sanitizeAttr decides whether an attribute is kept and returns its value
+func sanitizeAttr(tag string, attr html.Attribute, base *url.URL) (string, bool) {
+	key := strings.ToLower(attr.Key)
+
+	switch {
+	case attr.Namespace != "", strings.Trim(key, "abcdefghijklmnopqrstuvwxyz0123456789-_:") != "":
+		return "", false
+	case strings.HasPrefix(key, "on"), key == "style", key == "target":
+		return "", false
+	case key == "href" && tag == "a":
+		return resolveURL(attr.Val, base, true)
+	case key == "src" && tag == "img":
+		return resolveURL(attr.Val, base, false)
+	case key == "href", key == "src", key == "srcset", key == "xlink:href":
+		return "", false
+	}
+
+	return attr.Val, true
+}
+
+// resolveURL makes a URL absolute and rejects anything that isn't http(s).
+// In-page fragment links are kept as-is so footnotes and references work.
+func resolveURL(raw string, base *url.URL, allowFragment bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if allowFragment && strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+
+	return u.String(), true
+}
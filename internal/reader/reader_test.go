@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+  <base href="/html/2301.12345v1/">
+  <script>alert("head")</script>
+</head>
+<body>
+  <nav>Navigation</nav>
+  <article class="ltx_document">
+    <h1 class="ltx_title">Test Paper</h1>
+    <script>alert("xss")</script>
+    <p onclick="steal()" style="color:red">Body with <a href="javascript:alert(1)">bad link</a>,
+      <a href="#bib.bib1">a citation</a> and <a href="https://example.com/code">code</a>.</p>
+    <figure><img src="x1.png" alt="Figure 1"></figure>
+    <math display="inline"><mi>x</mi><mo>=</mo><mn>1</mn></math>
+    <custom-widget><span>kept text</span></custom-widget>
+  </article>
+</body>
+</html>`
+
+	base, _ := url.Parse("https://arxiv.org/html/2301.12345")
+	content, err := Extract(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	out := string(content)
+
+	for _, unwanted := range []string{"<script", "alert", "Navigation", "onclick", "style=", "javascript:", "custom-widget"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Expected output not to contain %q, got: %s", unwanted, out)
+		}
+	}
+
+	for _, wanted := range []string{
+		`<h1 class="ltx_title">Test Paper</h1>`,
+		`src="https://arxiv.org/html/2301.12345v1/x1.png"`,
+		`href="#bib.bib1"`,
+		`href="https://example.com/code"`,
+		`<math display="inline"><mi>x</mi>`,
+		`<span>kept text</span>`,
+	} {
+		if !strings.Contains(out, wanted) {
+			t.Errorf("Expected output to contain %q, got: %s", wanted, out)
+		}
+	}
+}
+
+func TestExtractFallsBackToBody(t *testing.T) {
+	content, err := Extract(strings.NewReader(`<html><body><p>Only body</p></body></html>`), nil)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if string(content) != "<p>Only body</p>" {
+		t.Errorf("Expected body content, got %q", content)
+	}
+}
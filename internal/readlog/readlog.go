@@ -0,0 +1,106 @@
+// Package readlog pushes library papers marked as read to external reading
+// logs, such as Readwise or a webhook, so a reading history kept in other
+// tools includes what was read here. Reads are recorded in the database
+// when they happen and pushed in the background; each log remembers the
+// last event it received, so one being down delays only its own pushes.
+package readlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// batchSize is how many events are pushed in one request
+const batchSize = 100
+
+// Sink is an external reading log
+type Sink interface {
+	// Name identifies the log, e.g. "readwise"; its position in the event
+	// history is stored under this name
+	Name() string
+	Push(ctx context.Context, events []models.ReadEvent) error
+}
+
+// Store holds the read events and how far each log has received them
+type Store interface {
+	GetReadEvents(afterID int64, limit int) ([]models.ReadEvent, error)
+	GetReadLogCursor(sink string) (int64, error)
+	SetReadLogCursor(sink string, eventID int64) error
+}
+
+// Pusher pushes new read events to the configured logs
+type Pusher struct {
+	store Store
+	sinks []Sink
+}
+
+// New creates a pusher for the logs in the configuration
+func New(cfg config.ReadingLogConfig, store Store) *Pusher {
+	p := &Pusher{store: store}
+	if cfg.ReadwiseToken != "" {
+		p.AddSink(NewReadwise(cfg.ReadwiseToken))
+	}
+	if cfg.Webhook != "" {
+		p.AddSink(NewWebhook(cfg.Webhook))
+	}
+	return p
+}
+
+// AddSink adds a reading log. A log added later receives the reads
+// recorded before it too.
+func (p *Pusher) AddSink(s Sink) {
+	p.sinks = append(p.sinks, s)
+}
+
+// Enabled reports whether any log is configured
+func (p *Pusher) Enabled() bool {
+	return p != nil && len(p.sinks) > 0
+}
+
+// Push sends every log the read events it hasn't received yet, in order,
+// and returns how many events were pushed in total. A failing log is
+// retried from the same event on the next push.
+func (p *Pusher) Push(ctx context.Context) (int, error) {
+	pushed := 0
+	var errs []error
+	for _, s := range p.sinks {
+		n, err := p.push(ctx, s)
+		pushed += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return pushed, errors.Join(errs...)
+}
+
+// push sends one log its new events batch by batch
+func (p *Pusher) push(ctx context.Context, s Sink) (int, error) {
+	cursor, err := p.store.GetReadLogCursor(s.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read position: %w", err)
+	}
+
+	pushed := 0
+	for {
+		events, err := p.store.GetReadEvents(cursor, batchSize)
+		if err != nil {
+			return pushed, err
+		}
+		if len(events) == 0 {
+			return pushed, nil
+		}
+		if err := s.Push(ctx, events); err != nil {
+			return pushed, err
+		}
+
+		cursor = events[len(events)-1].ID
+		if err := p.store.SetReadLogCursor(s.Name(), cursor); err != nil {
+			return pushed, fmt.Errorf("failed to record position: %w", err)
+		}
+		pushed += len(events)
+	}
+}
@@ -0,0 +1,147 @@
+package readlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// memoryStore keeps read events and cursors in memory
+type memoryStore struct {
+	events  []models.ReadEvent
+	cursors map[string]int64
+}
+
+func (m *memoryStore) GetReadEvents(afterID int64, limit int) ([]models.ReadEvent, error) {
+	var events []models.ReadEvent
+	for _, e := range m.events {
+		if e.ID > afterID && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryStore) GetReadLogCursor(sink string) (int64, error) {
+	return m.cursors[sink], nil
+}
+
+func (m *memoryStore) SetReadLogCursor(sink string, eventID int64) error {
+	m.cursors[sink] = eventID
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	store := &memoryStore{cursors: map[string]int64{}}
+	for i := int64(1); i <= batchSize+1; i++ {
+		store.events = append(store.events, models.ReadEvent{ID: i, PaperID: "2401.00001", Title: "Paper"})
+	}
+
+	var webhookEvents int
+	up := true
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		var body struct {
+			Events []webhookEvent `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		webhookEvents += len(body.Events)
+	}))
+	defer webhook.Close()
+
+	var highlights []readwiseHighlight
+	readwise := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Highlights []readwiseHighlight `json:"highlights"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		highlights = append(highlights, body.Highlights...)
+	}))
+	defer readwise.Close()
+
+	rw := NewReadwise("secret")
+	rw.SetURL(readwise.URL)
+	p := &Pusher{store: store}
+	p.AddSink(rw)
+	p.AddSink(NewWebhook(webhook.URL))
+
+	pushed, err := p.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if pushed != 2*(batchSize+1) || len(highlights) != batchSize+1 || webhookEvents != batchSize+1 {
+		t.Fatalf("Expected every event pushed to both logs in batches, got %d (%d highlights, %d webhook events)",
+			pushed, len(highlights), webhookEvents)
+	}
+
+	// A log that is down keeps its place without holding up the other
+	up = false
+	store.events = append(store.events, models.ReadEvent{ID: batchSize + 2, PaperID: "2401.00002", Title: "Another"})
+	if _, err := p.Push(context.Background()); err == nil {
+		t.Error("Expected the webhook failure to be reported")
+	}
+	if store.cursors["readwise"] != batchSize+2 || store.cursors["webhook"] != batchSize+1 {
+		t.Errorf("Unexpected positions: %v", store.cursors)
+	}
+
+	up = true
+	if pushed, err := p.Push(context.Background()); err != nil || pushed != 1 {
+		t.Errorf("Expected the webhook to catch up with 1 event, got %d, %v", pushed, err)
+	}
+}
+
+func TestReadwiseHighlight(t *testing.T) {
+	var got readwiseHighlight
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Highlights []readwiseHighlight `json:"highlights"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		got = body.Highlights[0]
+	}))
+	defer srv.Close()
+
+	rw := NewReadwise("secret")
+	rw.SetURL(srv.URL)
+	err := rw.Push(context.Background(), []models.ReadEvent{{
+		ID: 1, PaperID: "2401.00001", Title: "Attention", Authors: "A. Author", ArxivUrl: "http://arxiv.org/abs/2401.00001",
+		Note: "Baseline for the thesis", Tags: []string{"transformers", "to cite"}, ReadAt: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+	}})
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	want := readwiseHighlight{
+		Text: "Baseline for the thesis", Title: "Attention", Author: "A. Author", SourceURL: "http://arxiv.org/abs/2401.00001",
+		SourceType: "arxiv_nest", Category: "articles", Note: ".transformers .to-cite", HighlightedAt: "2024-03-01T09:00:00Z",
+	}
+	if got != want {
+		t.Errorf("Unexpected highlight:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestWebhookErrorHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	hook := NewWebhook(srv.URL + "/hooks/secret-path")
+	srv.Close()
+
+	err := hook.Push(context.Background(), []models.ReadEvent{{ID: 1, PaperID: "2401.00001"}})
+	if err == nil {
+		t.Fatal("Expected the push to a closed server to fail")
+	}
+	if strings.Contains(err.Error(), "secret-path") {
+		t.Errorf("Expected the error to leave out the webhook URL, got %v", err)
+	}
+}
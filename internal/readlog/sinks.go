@@ -0,0 +1,185 @@
+package readlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// readwiseURL is the Readwise endpoint creating highlights
+const readwiseURL = "https://readwise.io/api/v2/highlights/"
+
+// requestTimeout bounds one push request
+const requestTimeout = 30 * time.Second
+
+// Readwise adds each read paper to Readwise as a highlight of its abstract
+// page: the "why saved" note, or the title without one, with the paper's
+// tags as Readwise tags
+type Readwise struct {
+	token      string
+	url        string
+	httpClient *http.Client
+}
+
+// NewReadwise creates a Readwise log using an access token from
+// readwise.io/access_token
+func NewReadwise(token string) *Readwise {
+	return &Readwise{
+		token:      token,
+		url:        readwiseURL,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// SetURL points the log at another endpoint, e.g. a test server
+func (r *Readwise) SetURL(url string) {
+	r.url = url
+}
+
+// Name returns "readwise"
+func (r *Readwise) Name() string {
+	return "readwise"
+}
+
+// readwiseHighlight is a highlight in a Readwise create request
+type readwiseHighlight struct {
+	Text          string `json:"text"`
+	Title         string `json:"title"`
+	Author        string `json:"author,omitempty"`
+	SourceURL     string `json:"source_url,omitempty"`
+	SourceType    string `json:"source_type"`
+	Category      string `json:"category"`
+	Note          string `json:"note,omitempty"`
+	HighlightedAt string `json:"highlighted_at"`
+}
+
+// Push creates one highlight per event. Readwise merges highlights with
+// the same text and source, so pushing an event again is harmless.
+func (r *Readwise) Push(ctx context.Context, events []models.ReadEvent) error {
+	highlights := make([]readwiseHighlight, len(events))
+	for i, e := range events {
+		text := e.Note
+		if text == "" {
+			text = e.Title
+		}
+		// Readwise turns ".tag" at the start of a note into a tag
+		tags := make([]string, len(e.Tags))
+		for j, tag := range e.Tags {
+			tags[j] = "." + strings.ReplaceAll(tag, " ", "-")
+		}
+		highlights[i] = readwiseHighlight{
+			Text:          text,
+			Title:         e.Title,
+			Author:        e.Authors,
+			SourceURL:     e.ArxivUrl,
+			SourceType:    "arxiv_nest",
+			Category:      "articles",
+			Note:          strings.Join(tags, " "),
+			HighlightedAt: e.ReadAt.UTC().Format(time.RFC3339),
+		}
+	}
+
+	return post(ctx, r.httpClient, r.url, map[string]string{"Authorization": "Token " + r.token},
+		map[string]interface{}{"highlights": highlights})
+}
+
+// Webhook posts the events as JSON to a URL, for reading logs without a
+// built-in integration
+type Webhook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhook creates a webhook log
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, httpClient: &http.Client{Timeout: requestTimeout}}
+}
+
+// Name returns "webhook"
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// webhookEvent is an event in the webhook payload
+type webhookEvent struct {
+	ID      int64     `json:"id"`
+	PaperID string    `json:"paper_id"`
+	Title   string    `json:"title"`
+	Authors string    `json:"authors"`
+	URL     string    `json:"url"`
+	Note    string    `json:"note,omitempty"`
+	Tags    []string  `json:"tags"`
+	ReadAt  time.Time `json:"read_at"`
+}
+
+// Push posts the events in one request as {"events": [...]}. Event IDs
+// increase, so a receiver can ignore events it has already seen.
+func (w *Webhook) Push(ctx context.Context, events []models.ReadEvent) error {
+	payload := make([]webhookEvent, len(events))
+	for i, e := range events {
+		tags := e.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		payload[i] = webhookEvent{
+			ID:      e.ID,
+			PaperID: e.PaperID,
+			Title:   e.Title,
+			Authors: e.Authors,
+			URL:     e.ArxivUrl,
+			Note:    e.Note,
+			Tags:    tags,
+			ReadAt:  e.ReadAt.UTC(),
+		}
+	}
+	return post(ctx, w.httpClient, w.url, nil, map[string]interface{}{"events": payload})
+}
+
+// post sends body as JSON, failing on a non-2xx response. Errors leave the
+// URL out, since a webhook URL is usually a secret.
+func post(ctx context.Context, client *http.Client, target string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ArXiv-Go-Nest/1.0")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// withoutURL strips the URL the net/http and net/url errors quote
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,342 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownJob is returned for a job name the scheduler does not have
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrRunning is returned when asking to run a job that is already running
+var ErrRunning = errors.New("job is already running")
+
+// Job is a task run periodically in the background
+type Job struct {
+	Name        string
+	Description string
+	Interval    time.Duration
+	Run         func(ctx context.Context) error
+}
+
+// Store persists which jobs are paused, so pausing survives restarts
+type Store interface {
+	GetPausedJobs() (map[string]bool, error)
+	SetJobPaused(name string, paused bool) error
+}
+
+// Status is a snapshot of a job's schedule and last run
+type Status struct {
+	Name        string
+	Description string
+	Interval    time.Duration
+	Paused      bool
+	Running     bool
+	LastRun     time.Time
+	LastError   string
+	NextRun     time.Time
+}
+
+// job is a Job with its scheduling state
+type job struct {
+	Job
+	paused  bool
+	running bool
+	lastRun time.Time
+	lastErr error
+	next    time.Time
+}
+
+// Scheduler runs jobs on their intervals. Jobs can be paused, resumed and
+// run on demand while it is running.
+type Scheduler struct {
+	mu    sync.Mutex
+	store Store
+	jobs  map[string]*job
+	// once holds the names of the one-off runs in progress (see RunOnce)
+	once map[string]bool
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a scheduler for the given jobs, restoring their paused state
+// from the store
+func New(store Store, jobs ...Job) (*Scheduler, error) {
+	paused, err := store.GetPausedJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load paused jobs: %w", err)
+	}
+
+	s := &Scheduler{
+		store: store,
+		jobs:  make(map[string]*job, len(jobs)),
+		once:  make(map[string]bool),
+		wake:  make(chan struct{}, 1),
+	}
+	for _, j := range jobs {
+		s.jobs[j.Name] = &job{Job: j, paused: paused[j.Name]}
+	}
+	return s, nil
+}
+
+// Start runs each job first after the delay and then on its interval,
+// until Stop is called
+func (s *Scheduler) Start(delay time.Duration) {
+	s.mu.Lock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	first := time.Now().Add(delay)
+	for _, j := range s.jobs {
+		j.next = first
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop stops scheduling and waits for running jobs to return
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+// loop starts due jobs and sleeps until the next one is due
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		var next time.Time
+		for _, j := range s.jobs {
+			if j.paused || j.running {
+				continue
+			}
+			if !j.next.After(now) {
+				s.startLocked(j)
+				continue
+			}
+			if next.IsZero() || j.next.Before(next) {
+				next = j.next
+			}
+		}
+		s.mu.Unlock()
+
+		// With nothing scheduled, wait for a resume or run request
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// startLocked runs a job in the background. The caller holds s.mu.
+func (s *Scheduler) startLocked(j *job) {
+	j.running = true
+	s.wg.Add(1)
+
+	// SetJobs may replace the job while it runs
+	name, run := j.Name, j.Run
+	go func() {
+		defer s.wg.Done()
+
+		started := time.Now()
+		err := run(s.ctx)
+		if err != nil {
+			log.Printf("Scheduled job %s failed: %v", name, err)
+		}
+
+		s.mu.Lock()
+		j.running = false
+		j.lastRun = started
+		j.lastErr = err
+		j.next = time.Now().Add(j.Interval)
+		s.mu.Unlock()
+
+		s.notify()
+	}()
+}
+
+// notify wakes the loop to recompute the next due job
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SetJobs replaces the scheduled jobs, e.g. after the configuration was
+// reloaded, without stopping the scheduler. A job that is kept keeps its
+// state: its next run moves by the change in interval, and if it is running
+// the run finishes with the old job. A new job is due right away, unless
+// it was paused before; a removed job that is running finishes its run.
+func (s *Scheduler) SetJobs(jobs ...Job) error {
+	paused, err := s.store.GetPausedJobs()
+	if err != nil {
+		return fmt.Errorf("failed to load paused jobs: %w", err)
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	updated := make(map[string]*job, len(jobs))
+	for _, j := range jobs {
+		if old, ok := s.jobs[j.Name]; ok {
+			old.next = old.next.Add(j.Interval - old.Interval)
+			old.Job = j
+			updated[j.Name] = old
+			continue
+		}
+		updated[j.Name] = &job{Job: j, paused: paused[j.Name], next: now}
+	}
+	s.jobs = updated
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// Pause stops a job from running on schedule until it is resumed
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume schedules a paused job again. A job that fell due while paused
+// runs right away.
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+// setPaused persists and applies a job's paused state
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	if err := s.store.SetJobPaused(name, paused); err != nil {
+		return fmt.Errorf("failed to save job state: %w", err)
+	}
+
+	s.mu.Lock()
+	j.paused = paused
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// RunNow starts a job immediately, paused or not. Its next scheduled run
+// is one interval after this run finishes.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if j.running {
+		return ErrRunning
+	}
+	if s.ctx == nil {
+		return errors.New("scheduler is not running")
+	}
+
+	s.startLocked(j)
+	return nil
+}
+
+// RunOnce runs a task outside the schedule in the background, such as
+// fetching a single subscription, under the name given. Like a job it is
+// stopped with the scheduler, and it can't be started again under the
+// same name until it returns. Its error is logged.
+func (s *Scheduler) RunOnce(name string, run func(ctx context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j, ok := s.jobs[name]; s.once[name] || ok && j.running {
+		return ErrRunning
+	}
+	if s.ctx == nil {
+		return errors.New("scheduler is not running")
+	}
+
+	s.once[name] = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := run(s.ctx); err != nil {
+			log.Printf("Task %s failed: %v", name, err)
+		}
+
+		s.mu.Lock()
+		delete(s.once, name)
+		s.mu.Unlock()
+	}()
+	return nil
+}
+
+// Status returns the state of every job, ordered by name
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := Status{
+			Name:        j.Name,
+			Description: j.Description,
+			Interval:    j.Interval,
+			Paused:      j.paused,
+			Running:     j.running,
+			LastRun:     j.lastRun,
+			NextRun:     j.next,
+		}
+		if j.lastErr != nil {
+			status.LastError = j.lastErr.Error()
+		}
+		if j.paused || j.running {
+			status.NextRun = time.Time{}
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses
+}
+
+// Get returns the state of one job
+func (s *Scheduler) Get(name string) (Status, bool) {
+	for _, status := range s.Status() {
+		if status.Name == name {
+			return status, true
+		}
+	}
+	return Status{}, false
+}
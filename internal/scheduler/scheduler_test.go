@@ -0,0 +1,231 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps paused jobs in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	paused map[string]bool
+}
+
+func (m *memoryStore) GetPausedJobs() (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	paused := make(map[string]bool, len(m.paused))
+	for name, p := range m.paused {
+		paused[name] = p
+	}
+	return paused, nil
+}
+
+func (m *memoryStore) SetJobPaused(name string, paused bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused[name] = paused
+	return nil
+}
+
+// counter is a job body that counts its runs
+type counter struct {
+	mu   sync.Mutex
+	runs int
+	ran  chan struct{}
+}
+
+func newCounter() *counter {
+	return &counter{ran: make(chan struct{}, 10)}
+}
+
+func (c *counter) run(ctx context.Context) error {
+	c.mu.Lock()
+	c.runs++
+	c.mu.Unlock()
+	c.ran <- struct{}{}
+	return nil
+}
+
+func (c *counter) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for job to run")
+	}
+}
+
+func TestPauseResumeAndRunNow(t *testing.T) {
+	store := &memoryStore{paused: map[string]bool{}}
+	fetch := newCounter()
+
+	s, err := New(store, Job{Name: "fetch", Interval: time.Hour, Run: fetch.run})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s.Start(0)
+	defer s.Stop()
+
+	// Runs right away, then waits an interval
+	fetch.wait(t)
+	time.Sleep(10 * time.Millisecond)
+	status, _ := s.Get("fetch")
+	if status.LastRun.IsZero() || time.Until(status.NextRun) < 59*time.Minute {
+		t.Errorf("Expected a last run and the next run an hour out, got %+v", status)
+	}
+
+	if err := s.Pause("fetch"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if !store.paused["fetch"] {
+		t.Error("Expected pause to be persisted")
+	}
+	if status, _ := s.Get("fetch"); !status.Paused || !status.NextRun.IsZero() {
+		t.Errorf("Expected paused job without a next run, got %+v", status)
+	}
+
+	// Running on demand works while paused
+	if err := s.RunNow("fetch"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	fetch.wait(t)
+
+	if err := s.RunNow("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+
+	// A new scheduler restores the paused state and does not run the job
+	restarted := newCounter()
+	s2, err := New(store, Job{Name: "fetch", Interval: time.Hour, Run: restarted.run})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s2.Start(0)
+	defer s2.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	if restarted.runs != 0 {
+		t.Fatalf("Expected paused job not to run after restart, ran %d times", restarted.runs)
+	}
+
+	// Resuming runs the overdue job
+	if err := s2.Resume("fetch"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	restarted.wait(t)
+	if store.paused["fetch"] {
+		t.Error("Expected resume to be persisted")
+	}
+}
+
+func TestSetJobs(t *testing.T) {
+	store := &memoryStore{paused: map[string]bool{"purge": true}}
+	fetch, check := newCounter(), newCounter()
+
+	s, err := New(store,
+		Job{Name: "fetch", Interval: time.Hour, Run: fetch.run},
+		Job{Name: "check", Interval: time.Hour, Run: check.run})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s.Start(0)
+	defer s.Stop()
+	fetch.wait(t)
+	check.wait(t)
+	time.Sleep(10 * time.Millisecond)
+	before, _ := s.Get("fetch")
+
+	// The fetch is kept with a shorter interval, the check dropped, the
+	// purge added paused and the digest added due
+	refetch, digest := newCounter(), newCounter()
+	err = s.SetJobs(
+		Job{Name: "fetch", Interval: 30 * time.Minute, Run: refetch.run},
+		Job{Name: "purge", Interval: time.Hour, Run: newCounter().run},
+		Job{Name: "digest", Interval: time.Hour, Run: digest.run})
+	if err != nil {
+		t.Fatalf("SetJobs failed: %v", err)
+	}
+	digest.wait(t)
+
+	status, _ := s.Get("fetch")
+	if status.Interval != 30*time.Minute || status.LastRun != before.LastRun || !status.NextRun.Equal(before.NextRun.Add(-30*time.Minute)) {
+		t.Errorf("Expected the fetch to keep its last run and be due 30 minutes earlier, got %+v", status)
+	}
+	if _, ok := s.Get("check"); ok {
+		t.Error("Expected the check to be removed")
+	}
+	if status, _ := s.Get("purge"); !status.Paused {
+		t.Error("Expected the purge to be restored paused")
+	}
+
+	// The replacement runs in place of the old job
+	if err := s.RunNow("fetch"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	refetch.wait(t)
+	if fetch.runs != 1 {
+		t.Errorf("Expected the old fetch not to run again, ran %d times", fetch.runs)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	s, err := New(&memoryStore{paused: map[string]bool{}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := s.RunOnce("fetch:category:cs.LG", func(ctx context.Context) error { return nil }); err == nil {
+		t.Error("Expected RunOnce to fail before the scheduler starts")
+	}
+	s.Start(time.Hour)
+
+	release := make(chan struct{})
+	stopped := make(chan struct{})
+	err = s.RunOnce("fetch:category:cs.LG", func(ctx context.Context) error {
+		defer close(stopped)
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if err := s.RunOnce("fetch:category:cs.LG", nil); !errors.Is(err, ErrRunning) {
+		t.Errorf("Expected ErrRunning while the run is in progress, got %v", err)
+	}
+
+	close(release)
+	<-stopped
+	again := newCounter()
+	for {
+		err := s.RunOnce("fetch:category:cs.LG", again.run)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrRunning) {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	again.wait(t)
+
+	// Stopping the scheduler stops one-off runs too
+	stopped = make(chan struct{})
+	s.RunOnce("fetch:keyword:diffusion", func(ctx context.Context) error {
+		defer close(stopped)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.Stop()
+	select {
+	case <-stopped:
+	default:
+		t.Error("Expected Stop to wait for the one-off run")
+	}
+}
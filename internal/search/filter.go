@@ -0,0 +1,75 @@
+package search
+
+// Filter is a structured search condition, for filters a query string
+// can't express. A node either combines other filters with All (AND), Any
+// (OR) or Not, or compares one Field using Op against Value, e.g.
+//
+//	{"all": [
+//	  {"field": "category", "op": "in", "value": ["cs.LG", "stat.ML"]},
+//	  {"field": "published", "op": "between", "value": ["2024-01-01", "2024-06-30"]},
+//	  {"not": {"field": "tag", "op": "in", "value": ["reviewed"]}}
+//	]}
+//
+// The fields and the operators each allows are listed in FilterFields.
+type Filter struct {
+	All []Filter `json:"all,omitempty"`
+	Any []Filter `json:"any,omitempty"`
+	Not *Filter  `json:"not,omitempty"`
+
+	Field string      `json:"field,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Empty reports whether the filter sets nothing, which matches every paper
+func (f Filter) Empty() bool {
+	return len(f.All) == 0 && len(f.Any) == 0 && f.Not == nil && f.Field == "" && f.Op == "" && f.Value == nil
+}
+
+// Limits on a filter, so a request can't build an arbitrarily large query
+const (
+	MaxFilterDepth      = 8
+	MaxFilterConditions = 64
+	MaxFilterValues     = 100
+)
+
+// Kinds of filter fields, which decide the operators and values allowed
+const (
+	// FieldText matches a word or phrase anywhere in the text, ignoring
+	// case: op "contains" with a string
+	FieldText = "text"
+	// FieldSet matches one of a paper's values: op "eq" with a string, or
+	// "in" (any of) and, for tags, "all" (every one of) with a list
+	FieldSet = "set"
+	// FieldDay compares a day: ops "gte", "lte" with "YYYY-MM-DD", or
+	// "between" with two days, inclusive
+	FieldDay = "day"
+	// FieldNumber compares an integer: ops "eq", "gte", "lte", or
+	// "between" with two integers, inclusive
+	FieldNumber = "number"
+	// FieldFlag is true or false: op "eq" with a boolean
+	FieldFlag = "flag"
+)
+
+// FilterFields maps each filter field to its kind
+var FilterFields = map[string]string{
+	"query":          FieldText, // title, abstract or authors, like q
+	"title":          FieldText,
+	"abstract":       FieldText,
+	"authors":        FieldText,
+	"comment":        FieldText,
+	"journal_ref":    FieldText,
+	"id":             FieldSet,
+	"category":       FieldSet,
+	"tag":            FieldSet,
+	"entity":         FieldSet,
+	"license":        FieldSet, // a license filter such as "cc-by"
+	"published":      FieldDay,
+	"updated":        FieldDay,
+	"saved":          FieldDay,
+	"abstract_words": FieldNumber,
+	"priority":       FieldNumber,
+	"in_library":     FieldFlag,
+	"read":           FieldFlag,
+	"has_note":       FieldFlag,
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// MaxQueryLength caps the length of a search query in characters
+const MaxQueryLength = 256
+
+// likeEscaper escapes the LIKE wildcards so user input matches literally.
+// Queries using it must declare ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Normalize canonicalizes a search query: control characters are dropped,
+// whitespace is trimmed and collapsed, and the text is case-folded, so
+// equivalent queries give identical results and URLs
+func Normalize(raw string) string {
+	return strings.ToLower(Clean(raw))
+}
+
+// Clean is Normalize keeping the case, for showing a query as it was typed
+func Clean(raw string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, raw)
+
+	query := strings.Join(strings.Fields(cleaned), " ")
+
+	if runes := []rune(query); len(runes) > MaxQueryLength {
+		query = strings.TrimSpace(string(runes[:MaxQueryLength]))
+	}
+	return query
+}
+
+// Contains returns a LIKE pattern matching s anywhere in a value, with any
+// wildcards in s escaped. Use with ESCAPE '\'.
+func Contains(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// ParseParams reads the q, tag, category, entity and page parameters
+// shared by the HTML pages and the JSON API, along with the library
+// filters read_state, note, min_priority, saved_from and saved_to. Invalid
+// values are ignored. Pagination size and sorting are left to the caller.
+func ParseParams(values url.Values) models.SearchParams {
+	page, err := strconv.Atoi(values.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	length := values.Get("length")
+	switch length {
+	case "short", "medium", "long":
+	default:
+		length = ""
+	}
+
+	license := values.Get("license")
+	if _, ok := models.FindLicenseFilter(license); !ok {
+		license = ""
+	}
+
+	read := values.Get("read_state")
+	switch read {
+	case "read", "unread":
+	default:
+		read = ""
+	}
+
+	note := values.Get("note")
+	switch note {
+	case "with", "without":
+	default:
+		note = ""
+	}
+
+	minPriority, err := strconv.Atoi(values.Get("min_priority"))
+	if err != nil || minPriority < models.PriorityLow || minPriority > models.PriorityHigh {
+		minPriority = 0
+	}
+
+	return models.SearchParams{
+		Query:       Normalize(values.Get("q")),
+		QueryText:   Clean(values.Get("q")),
+		Tag:         strings.TrimSpace(values.Get("tag")),
+		Category:    strings.TrimSpace(values.Get("category")),
+		Length:      length,
+		License:     license,
+		Entity:      strings.TrimSpace(values.Get("entity")),
+		Page:        page,
+		Read:        read,
+		Note:        note,
+		MinPriority: minPriority,
+		SavedFrom:   parseDay(values.Get("saved_from")),
+		SavedTo:     parseDay(values.Get("saved_to")),
+	}
+}
+
+// parseDay parses a YYYY-MM-DD date, returning the zero time if it's
+// missing or invalid
+func parseDay(value string) time.Time {
+	day, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return day
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"  Graph   Neural\tNetworks ", "graph neural networks"},
+		{"GRAPH neural networks", "graph neural networks"},
+		{"ÉCOLE Normale", "école normale"},
+		{"line\nbreak", "line break"},
+		{"100%", "100%"},
+		{"   ", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("é", MaxQueryLength+10)
+	if got := []rune(Normalize(long)); len(got) != MaxQueryLength {
+		t.Errorf("Expected query truncated to %d characters, got %d", MaxQueryLength, len(got))
+	}
+}
+
+func TestContains(t *testing.T) {
+	if got := Contains(`50%_off\`); got != `%50\%\_off\\%` {
+		t.Errorf("Unexpected pattern %q", got)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	values := url.Values{"q": {"  Attention  "}, "tag": {" to-read "}, "page": {"-3"}}
+	params := ParseParams(values)
+
+	if params.Query != "attention" || params.QueryText != "Attention" || params.Tag != "to-read" || params.Page != 1 {
+		t.Errorf("Unexpected params: %+v", params)
+	}
+
+	if got := ParseParams(url.Values{"length": {"long"}}).Length; got != "long" {
+		t.Errorf("Expected length filter long, got %q", got)
+	}
+	if got := ParseParams(url.Values{"length": {"huge"}}).Length; got != "" {
+		t.Errorf("Expected unknown length to be ignored, got %q", got)
+	}
+
+	params = ParseParams(url.Values{
+		"read_state": {"unread"}, "note": {"with"}, "min_priority": {"2"},
+		"saved_from": {"2024-03-01"}, "saved_to": {"2024-03-31"},
+	})
+	if params.Read != "unread" || params.Note != "with" || params.MinPriority != 2 ||
+		params.SavedFrom.Format("2006-01-02") != "2024-03-01" || params.SavedTo.Day() != 31 {
+		t.Errorf("Unexpected library filters: %+v", params)
+	}
+
+	params = ParseParams(url.Values{
+		"read_state": {"yes"}, "note": {"maybe"}, "min_priority": {"7"}, "saved_from": {"March"},
+	})
+	if params.Read != "" || params.Note != "" || params.MinPriority != 0 || !params.SavedFrom.IsZero() {
+		t.Errorf("Expected invalid library filters to be ignored, got %+v", params)
+	}
+}
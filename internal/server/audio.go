@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+)
+
+// playlistSize is how many papers of the reading queue the playlist lists
+const playlistSize = 50
+
+// audioEnabled reports whether abstracts can be listened to: a
+// text-to-speech command is configured and the audio flag is on
+func (h *Handler) audioEnabled() bool {
+	return h.tts != nil && h.features.Enabled(features.Audio)
+}
+
+// requireAudio answers 404 unless audio is enabled
+func (h *Handler) requireAudio(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.audioEnabled() {
+			http.Error(w, "Audio is not enabled", http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandlePaperAudio serves the spoken abstract of a paper, generating it on
+// the first request. Later requests are served from the cache, with range
+// support so players can seek.
+func (h *Handler) HandlePaperAudio(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	paper, err := h.db.GetPaperByID(id)
+	if err != nil {
+		http.Error(w, "Paper not found", http.StatusNotFound)
+		log.Printf("Error fetching paper %s: %v", id, err)
+		return
+	}
+
+	path, err := h.tts.Audio(r.Context(), paper)
+	if err != nil {
+		http.Error(w, "Failed to generate audio", http.StatusInternalServerError)
+		log.Printf("Error generating audio for %s: %v", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", h.tts.ContentType())
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, path)
+}
+
+// HandlePlaylist serves the reading queue as an M3U playlist of spoken
+// abstracts. Entries are absolute URLs so the playlist also works once
+// downloaded to a phone.
+func (h *Handler) HandlePlaylist(w http.ResponseWriter, r *http.Request) {
+	papers, err := h.db.GetReadingQueue(playlistSize)
+	if err != nil {
+		serverError(w, "Failed to fetch reading queue", err)
+		log.Printf("Error fetching reading queue: %v", err)
+		return
+	}
+
+	base := baseURL(r)
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#PLAYLIST:Reading queue\n")
+	for _, p := range papers {
+		title := strings.Join(strings.Fields(p.Authors+" - "+p.Title), " ")
+		fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s/paper/%s/audio\n", title, base, p.ID)
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="reading-queue.m3u"`)
+	w.Write([]byte(b.String()))
+}
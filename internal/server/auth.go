@@ -0,0 +1,532 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/api"
+	"github.com/ngx/arxiv-go-nest/internal/config"
+)
+
+// Authentication methods, as named in the auth.methods setting
+const (
+	AuthSession = "session"
+	AuthToken   = "token"
+	AuthHeader  = "header"
+)
+
+const (
+	// loginPath is the login page of session authentication
+	loginPath = "/login"
+
+	// sessionCookie holds a signed-in user's session
+	sessionCookie = "nest_session"
+
+	// passwordIterations is the PBKDF2 work factor of new password hashes
+	passwordIterations = 600000
+)
+
+// ErrInvalidCredentials is returned for a wrong user name, password or
+// token
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Identity is the user a request was made by
+type Identity struct {
+	User   string
+	Groups []string
+	// Method is the authentication method that recognized the user
+	Method string
+}
+
+// Authenticator recognizes the user making a request. A request without
+// credentials of its kind gets a nil identity and no error, so the next
+// authenticator can try; an error means credentials were given but are
+// wrong.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Authorizer decides whether an identified user may make a request
+type Authorizer interface {
+	Authorize(id *Identity, r *http.Request) bool
+}
+
+// Auth is the authentication of a server: its authenticators, tried in
+// order, and the authorizer checking whoever they recognize. Without
+// authenticators everyone is let in, as they are without configuration.
+type Auth struct {
+	Authenticators []Authenticator
+	Authorizer     Authorizer
+}
+
+// NewAuth creates the authenticators and authorizer cfg configures
+func NewAuth(cfg config.AuthConfig) (*Auth, error) {
+	a := &Auth{Authorizer: AdminAuthorizer{Admins: cfg.Admins}}
+	for _, method := range cfg.Methods {
+		switch method {
+		case AuthSession:
+			if cfg.SessionSecret == "" || len(cfg.Users) == 0 {
+				return nil, fmt.Errorf("session authentication needs auth.session_secret and auth.users")
+			}
+			a.Authenticators = append(a.Authenticators, &SessionAuth{
+				Secret: []byte(cfg.SessionSecret),
+				TTL:    cfg.SessionTTL,
+				Users:  cfg.Users,
+			})
+		case AuthToken:
+			if len(cfg.Tokens) == 0 {
+				return nil, fmt.Errorf("token authentication needs auth.tokens")
+			}
+			a.Authenticators = append(a.Authenticators, TokenAuth{Tokens: cfg.Tokens})
+		case AuthHeader:
+			proxies, err := parseNetworks(cfg.TrustedProxies)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.Header == "" || len(proxies) == 0 {
+				return nil, fmt.Errorf("header authentication needs auth.header and auth.trusted_proxies")
+			}
+			a.Authenticators = append(a.Authenticators, HeaderAuth{
+				Header:         cfg.Header,
+				GroupsHeader:   cfg.GroupsHeader,
+				TrustedProxies: proxies,
+			})
+		default:
+			return nil, fmt.Errorf("unknown authentication method %q", method)
+		}
+	}
+	return a, nil
+}
+
+// Enabled reports whether requests need to be authenticated
+func (a *Auth) Enabled() bool {
+	return a != nil && len(a.Authenticators) > 0
+}
+
+// Session returns the session authenticator, nil if sessions are off
+func (a *Auth) Session() *SessionAuth {
+	if a == nil {
+		return nil
+	}
+	for _, authenticator := range a.Authenticators {
+		if session, ok := authenticator.(*SessionAuth); ok {
+			return session
+		}
+	}
+	return nil
+}
+
+// authenticate asks each authenticator in turn who made the request
+func (a *Auth) authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range a.Authenticators {
+		id, err := authenticator.Authenticate(r)
+		if err != nil || id != nil {
+			return id, err
+		}
+	}
+	return nil, nil
+}
+
+type identityKey struct{}
+
+// identity returns the user the request was made by, nil if
+// authentication is off
+func identity(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey{}).(*Identity)
+	return id
+}
+
+// publicPaths are reachable without signing in: assets, the login page
+// itself and embeds, which carry their own signed tokens
+var publicPaths = []string{"/static/", loginPath, "/logout", "/embed/"}
+
+// authenticate lets requests through once the server's authenticators
+// recognize their user and the authorizer allows it, with the user
+// available to handlers via identity. Unrecognized page requests are sent
+// to the login page when sessions are on; API requests, and everything
+// without sessions, get a 401.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	toLogin := requireLogin(func(r *http.Request) bool { return identity(r) != nil }, loginPath)(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := s.handler.auth
+		if !auth.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range publicPaths {
+			if r.URL.Path == prefix || strings.HasSuffix(prefix, "/") && strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		isAPI := strings.HasPrefix(r.URL.Path, api.BasePath+"/")
+		id, err := auth.authenticate(r)
+		if err != nil || id == nil && (isAPI || auth.Session() == nil) {
+			message := "Authentication required"
+			if err != nil {
+				message = "Invalid credentials"
+			}
+			if isAPI {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="arxiv-nest"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprintf(w, `{"error": %q}`, strings.ToLower(message))
+				return
+			}
+			http.Error(w, message, http.StatusUnauthorized)
+			return
+		}
+		if id == nil {
+			toLogin.ServeHTTP(w, r)
+			return
+		}
+
+		if !auth.Authorizer.Authorize(id, r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// AdminAuthorizer lets every user in, except that only Admins, by user or
+// group name, may open the /admin pages. Without Admins everyone may.
+type AdminAuthorizer struct {
+	Admins []string
+}
+
+// Authorize reports whether id may make the request
+func (a AdminAuthorizer) Authorize(id *Identity, r *http.Request) bool {
+	if len(a.Admins) == 0 || !strings.HasPrefix(r.URL.Path, "/admin/") {
+		return true
+	}
+	for _, admin := range a.Admins {
+		if admin == id.User {
+			return true
+		}
+		for _, group := range id.Groups {
+			if admin == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SessionAuth recognizes users signed in on the login page by a cookie
+// carrying their name and expiry, signed with Secret. The signature
+// covers the user's password hash too, so changing a password or removing
+// the user ends their sessions.
+type SessionAuth struct {
+	Secret []byte
+	TTL    time.Duration
+	// Users maps user names to password hashes from HashPassword
+	Users map[string]string
+}
+
+// Authenticate returns the user of a valid, unexpired session. Anything
+// else counts as no session, so an expired one leads back to the login
+// page.
+func (a *SessionAuth) Authenticate(r *http.Request) (*Identity, error) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	parts := strings.Split(c.Value, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	name, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, nil
+	}
+	user := string(name)
+	if _, ok := a.Users[user]; !ok {
+		return nil, nil
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(a.sign(user, expires))) {
+		return nil, nil
+	}
+	return &Identity{User: user, Method: AuthSession}, nil
+}
+
+// Login checks the user's password and sets their session cookie
+func (a *SessionAuth) Login(w http.ResponseWriter, r *http.Request, user, password string) error {
+	hash, ok := a.Users[user]
+	if !ok {
+		// Take as long as a wrong password would
+		checkPassword(password, dummyPasswordHash())
+		return ErrInvalidCredentials
+	}
+	if !checkPassword(password, hash) {
+		return ErrInvalidCredentials
+	}
+
+	expires := time.Now().Add(a.TTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + strconv.FormatInt(expires.Unix(), 10) + "." + a.sign(user, expires.Unix()),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Logout clears the session cookie
+func (a *SessionAuth) Logout(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign returns the signature of a session
+func (a *SessionAuth) sign(user string, expires int64) string {
+	mac := hmac.New(sha256.New, a.Secret)
+	fmt.Fprintf(mac, "%s\x00%d\x00%s", user, expires, a.Users[user])
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TokenAuth recognizes API clients by a bearer token. Requests act as the
+// user the token is named after.
+type TokenAuth struct {
+	// Tokens maps names to tokens
+	Tokens map[string]string
+}
+
+// Authenticate returns the user of the request's bearer token
+func (a TokenAuth) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+	token = strings.TrimSpace(token)
+	for name, t := range a.Tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return &Identity{User: name, Method: AuthToken}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// HeaderAuth trusts a single sign-on proxy in front of the app, such as
+// oauth2-proxy, to name the signed-in user in a request header. Anyone
+// could send the header, so it is only read on requests coming straight
+// from one of TrustedProxies.
+type HeaderAuth struct {
+	Header         string
+	GroupsHeader   string
+	TrustedProxies []*net.IPNet
+}
+
+// Authenticate returns the user named by a trusted proxy
+func (a HeaderAuth) Authenticate(r *http.Request) (*Identity, error) {
+	user := strings.TrimSpace(r.Header.Get(a.Header))
+	if user == "" || !a.trusted(peerAddr(r)) {
+		return nil, nil
+	}
+
+	id := &Identity{User: user, Method: AuthHeader}
+	if a.GroupsHeader != "" {
+		for _, group := range strings.Split(r.Header.Get(a.GroupsHeader), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				id.Groups = append(id.Groups, group)
+			}
+		}
+	}
+	return id, nil
+}
+
+// trusted reports whether addr, a host:port, is one of the trusted proxies
+func (a HeaderAuth) trusted(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses IP addresses and CIDR ranges
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", v)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			v = fmt.Sprintf("%s/%d", v, bits)
+		}
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", v)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+type peerAddrKey struct{}
+
+// peerMiddleware remembers the address a request came from before RealIP
+// replaces it with one taken from request headers, which clients control
+func peerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)))
+	})
+}
+
+// peerAddr returns the address of the connection a request came over
+func peerAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(peerAddrKey{}).(string); ok {
+		return addr
+	}
+	return r.RemoteAddr
+}
+
+// HashPassword hashes a password for the auth.users setting with
+// PBKDF2-HMAC-SHA256, as "pbkdf2-sha256$iterations$salt$hash"
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hashPassword(password, salt, passwordIterations), nil
+}
+
+// hashPassword hashes password with the given salt and work factor
+func hashPassword(password string, salt []byte, iterations int) string {
+	key := pbkdf2([]byte(password), salt, iterations, sha256.Size)
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// dummyPasswordHash is checked against for unknown users
+var dummyPasswordHash = sync.OnceValue(func() string {
+	return hashPassword("", make([]byte, 16), passwordIterations)
+})
+
+// checkPassword reports whether password matches a hash from HashPassword
+func checkPassword(password, hash string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashPassword(password, salt, iterations)), []byte(hash)) == 1
+}
+
+// pbkdf2 derives a key of keyLen bytes from password as RFC 8018
+// describes, with HMAC-SHA256
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// requireLogin returns middleware sending requests without a session, as
+// reported by signedIn, to the login page with the page to come back to in
+// its "next" parameter. A full page load gets a 302. An HTMX request gets
+// HX-Redirect instead, because HTMX would follow a 302 itself and swap the
+// login page into whatever fragment the button targets; an expired session
+// then shows as a login page rather than a half-rendered one.
+func requireLogin(signedIn func(*http.Request) bool, loginURL string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if signedIn(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target := loginURL
+			if back := returnTo(r); back != "" {
+				target += "?" + url.Values{"next": {back}}.Encode()
+			}
+			if r.Header.Get("HX-Request") != "" {
+				w.Header().Set("HX-Redirect", target)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, target, http.StatusFound)
+		})
+	}
+}
+
+// returnTo is the local page to return to after logging in: the page an
+// HTMX request was made from, or the page requested. Form posts have no
+// page of their own, so they return nowhere in particular.
+func returnTo(r *http.Request) string {
+	if r.Header.Get("HX-Request") != "" {
+		current, err := url.Parse(r.Header.Get("HX-Current-URL"))
+		if err != nil || current.Path == "" || current.Host != "" && current.Host != r.Host {
+			return ""
+		}
+		return current.RequestURI()
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return r.URL.RequestURI()
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+)
+
+// deliveryHistory is how many deliveries the history page shows
+const deliveryHistory = 200
+
+// HandleDeliveries renders the history of notifications sent, on all
+// channels or the one named by the "channel" parameter, with the
+// configured channels to test and what a dry run has recorded
+func (h *Handler) HandleDeliveries(w http.ResponseWriter, r *http.Request) {
+	notifier := h.notifier()
+	data := PageData{
+		Title:            "Notifications",
+		Features:         h.features.Map(),
+		SelectedChannel:  r.URL.Query().Get("channel"),
+		TestChannels:     notifier.Channels(),
+		DryRun:           notifier.DryRun(),
+		DryRunDeliveries: notifier.Recorded(),
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.Deliveries, err = h.db.GetDeliveries(data.SelectedChannel, deliveryHistory)
+		return err
+	})
+	l.Go("delivery channels", func() (err error) {
+		data.Channels, err = h.db.GetDeliveryChannels()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch deliveries", err)
+		log.Printf("Error fetching deliveries: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "deliveries.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleTestNotification shows the notification the channel in "channel"
+// would send about the most recently published paper, and sends it when
+// "send" is set (HTMX endpoint). Test notifications skip digests and
+// aren't logged as deliveries.
+func (h *Handler) HandleTestNotification(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	notifier := h.notifier()
+	if !notifier.Enabled() {
+		http.Error(w, "No notification channels are configured", http.StatusNotFound)
+		return
+	}
+	papers, _, err := h.db.GetPapers(models.SearchParams{Page: 1, PageSize: 1})
+	if err != nil {
+		serverError(w, "Failed to fetch a paper to announce", err)
+		log.Printf("Error fetching a paper for a test notification: %v", err)
+		return
+	}
+	paper := &testNotificationPaper
+	if len(papers) > 0 {
+		paper = &papers[0]
+	}
+
+	send := r.FormValue("send") != ""
+	d, err := notifier.Test(r.Context(), r.FormValue("channel"), notifier.MessageFor(paper), send)
+	if errors.Is(err, notify.ErrUnknownChannel) {
+		http.Error(w, "Unknown channel", http.StatusNotFound)
+		return
+	}
+	if d.Type == "" {
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "%s", "type": "error"}}`, template.JSEscapeString("Can't render the notification: "+err.Error())))
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var status string
+	switch {
+	case !send:
+		status = "Preview only; nothing was sent."
+	case err != nil:
+		log.Printf("Error sending a test notification to %s: %v", d.Channel, err)
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "%s", "type": "error"}}`, template.JSEscapeString("Test notification failed: "+err.Error())))
+		status = "Sending failed: " + err.Error()
+	case notifier.DryRun():
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "Test notification recorded (dry run)", "type": "success"}}`)
+		status = "Recorded as a dry run; nothing was sent."
+	default:
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "Test notification sent", "type": "success"}}`)
+		status = "Sent at " + time.Now().Format("15:04:05") + "."
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<p class="mb-3 text-sm text-gray-700 dark:text-gray-300">%s</p>`, template.HTMLEscapeString(status))
+	writeDelivery(w, d)
+}
+
+// testNotificationPaper is announced by test notifications while the
+// database is still empty
+var testNotificationPaper = models.Paper{
+	ID:       "0000.00000",
+	Title:    "A Test Notification from ArXiv Nest",
+	Authors:  "ArXiv Nest",
+	Abstract: "This is what an announcement of a newly fetched paper looks like. Real announcements carry the paper's abstract here.",
+	ArxivUrl: "https://arxiv.org/",
+}
+
+// writeDelivery writes a rendered notification as HTML: where it goes and
+// its body verbatim
+func writeDelivery(w http.ResponseWriter, d notify.Delivery) {
+	fmt.Fprint(w, `<dl class="grid grid-cols-[auto_1fr] gap-x-4 gap-y-1 text-sm text-gray-900 dark:text-gray-100 mb-3">`)
+	fmt.Fprintf(w, `<dt class="text-gray-500 dark:text-gray-400">Channel</dt><dd>%s (%s)</dd>`, template.HTMLEscapeString(d.Channel), template.HTMLEscapeString(d.Type))
+	fmt.Fprintf(w, `<dt class="text-gray-500 dark:text-gray-400">To</dt><dd class="break-all">%s</dd>`, template.HTMLEscapeString(d.Destination()))
+	if d.Subject != "" {
+		fmt.Fprintf(w, `<dt class="text-gray-500 dark:text-gray-400">Subject</dt><dd>%s</dd>`, template.HTMLEscapeString(d.Subject))
+	}
+	fmt.Fprintf(w, `<dt class="text-gray-500 dark:text-gray-400">Content-Type</dt><dd>%s</dd></dl>`, template.HTMLEscapeString(d.ContentType))
+	fmt.Fprintf(w, `<pre class="p-3 rounded bg-gray-100 dark:bg-gray-900 text-xs text-gray-800 dark:text-gray-200 whitespace-pre-wrap break-all">%s</pre>`, template.HTMLEscapeString(d.Body))
+}
+
+// notifier returns the notifier in use, nil when there is no fetcher
+func (h *Handler) notifier() *notify.Notifier {
+	if h.fetcher == nil {
+		return nil
+	}
+	return h.fetcher.Notifier()
+}
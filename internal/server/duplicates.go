@@ -0,0 +1,89 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+)
+
+// HandleDuplicates renders the queue of papers with near-identical
+// abstracts, suggested for merging
+func (h *Handler) HandleDuplicates(w http.ResponseWriter, r *http.Request) {
+	data := PageData{
+		Title:    "Duplicates",
+		Features: h.features.Map(),
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.Duplicates, err = h.db.GetDuplicates()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch duplicates", err)
+		log.Printf("Error fetching duplicates: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "duplicates.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleMergeDuplicate merges a suggested pair into the paper in "keep",
+// trashing the other one (HTMX endpoint)
+func (h *Handler) HandleMergeDuplicate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid duplicate ID", http.StatusBadRequest)
+		return
+	}
+
+	dropped, err := h.db.MergeDuplicate(id, r.FormValue("keep"))
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Duplicate not found", http.StatusNotFound)
+		return
+	case err == db.ErrNotInPair:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		serverError(w, "Failed to merge papers", err)
+		log.Printf("Error merging duplicate %d: %v", id, err)
+		return
+	}
+
+	message := fmt.Sprintf("Merged; %s moved to the trash", dropped)
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"libraryUpdated": true, "showToast": {"message": %q, "type": "success"}}`, message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDismissDuplicate marks a suggested pair as different papers
+// (HTMX endpoint)
+func (h *Handler) HandleDismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid duplicate ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DismissDuplicate(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Duplicate not found", http.StatusNotFound)
+			return
+		}
+		serverError(w, "Failed to dismiss duplicate", err)
+		log.Printf("Error dismissing duplicate %d: %v", id, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Kept both papers", "type": "info"}}`)
+	w.WriteHeader(http.StatusOK)
+}
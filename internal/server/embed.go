@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+)
+
+const (
+	// embedSize is how many papers an embedded list shows by default, and
+	// embedMaxSize the most its "limit" parameter allows
+	embedSize    = 20
+	embedMaxSize = 100
+
+	// embedMaxAge is how long browsers may cache an embedded list
+	embedMaxAge = "300"
+)
+
+// EmbedData is passed to the embed.html template
+type EmbedData struct {
+	Tag    *models.Tag
+	Papers []models.Paper
+	// More is how many tagged papers didn't fit
+	More int
+}
+
+// embedToken signs an embedded list's kind and name with the embed secret,
+// so a link works for the list it was made for and nothing else
+func embedToken(secret, kind, name string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(kind + "\x00" + name))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// tagEmbedURL returns the signed path of a tag's embedded list, or "" if
+// embedding is off
+func (h *Handler) tagEmbedURL(name string) string {
+	if h.config.Embed.Secret == "" {
+		return ""
+	}
+	return "/embed/tag/" + url.PathEscape(name) + "?" + url.Values{"token": {embedToken(h.config.Embed.Secret, "tag", name)}}.Encode()
+}
+
+// HandleEmbedTag renders a shared tag's papers, newest first, as a
+// standalone page other sites can show in an iframe. The "token" parameter
+// must carry the tag's signature from the tag page; "limit" sets how many
+// papers are listed.
+func (h *Handler) HandleEmbedTag(w http.ResponseWriter, r *http.Request) {
+	secret := h.config.Embed.Secret
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if !hmac.Equal([]byte(token), []byte(embedToken(secret, "tag", name))) {
+		http.Error(w, "Invalid or revoked embed token", http.StatusForbidden)
+		return
+	}
+
+	limit := embedSize
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, embedMaxSize)
+	}
+
+	// Personal tags are never embedded, whoever's browser asks
+	database := h.db.ForClient("")
+	tag, err := database.GetTag(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch tag", err)
+		log.Printf("Error fetching tag %s: %v", name, err)
+		return
+	}
+
+	params := search.ParseParams(url.Values{})
+	params.Tag = tag.Name
+	params.PageSize = limit
+	papers, total, err := database.GetPapers(params)
+	if err != nil {
+		serverError(w, "Failed to fetch papers", err)
+		log.Printf("Error fetching papers: %v", err)
+		return
+	}
+
+	allowFraming(w.Header(), h.config.Embed.FrameAncestors)
+	w.Header().Set("Cache-Control", "private, max-age="+embedMaxAge)
+	data := EmbedData{Tag: tag, Papers: papers, More: total - len(papers)}
+	if err := h.templates.ExecuteTemplate(w, "embed.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/export"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+)
+
+// exportChunk is how many papers exports load and write at a time. Each
+// chunk is flushed to the client, so long exports start arriving at once
+// and proxies don't give up waiting for the first byte.
+const exportChunk = 200
+
+// PrintData is passed to the print.html template. The papers arrive in
+// chunks while the page is being written; call Flush after each chunk.
+type PrintData struct {
+	Title     string
+	Total     int
+	Generated time.Time
+	Abstracts bool
+	Notes     bool
+	Chunks    <-chan []models.Paper
+	Flush     func() string
+	// Failed reports whether loading stopped early; it's only meaningful
+	// once Chunks is drained
+	Failed func() bool
+}
+
+// HandleExportLaTeX downloads papers as a LaTeX table. The papers are the
+// repeated "ids" values, the papers on the "shelf" named, or otherwise
+// everything matching the q/tag/category filter (with library=true, only
+// saved papers).
+func (h *Handler) HandleExportLaTeX(w http.ResponseWriter, r *http.Request) {
+	ids, ok := h.exportIDs(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	opts := export.LaTeXOptions{
+		Longtable: parseBool(query.Get("longtable"), false),
+		Caption:   query.Get("caption"),
+		Label:     query.Get("label"),
+		Notes:     parseBool(query.Get("notes"), false),
+	}
+
+	w.Header().Set("Content-Type", "application/x-tex; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="papers.tex"`)
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	table, err := export.NewLaTeXTable(w, opts)
+	if err != nil {
+		log.Printf("Error writing LaTeX export: %v", err)
+		return
+	}
+	rc := http.NewResponseController(w)
+	err = h.exportChunks(r.Context(), ids, func(papers []models.Paper) error {
+		if err := table.WriteRows(papers); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		// The status is long gone; leave the table unterminated so the
+		// file doesn't compile as if it were complete
+		log.Printf("Error writing LaTeX export: %v", err)
+		return
+	}
+	if err := table.Close(); err != nil {
+		log.Printf("Error writing LaTeX export: %v", err)
+	}
+}
+
+// HandlePrintExport renders the papers a LaTeX export would contain as a
+// plain page meant for printing or saving as PDF, with their abstracts
+// unless "abstracts" is false and library notes with notes=true. The page
+// is streamed a chunk of papers at a time.
+func (h *Handler) HandlePrintExport(w http.ResponseWriter, r *http.Request) {
+	ids, ok := h.exportIDs(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	title := query.Get("title")
+	if title == "" {
+		title = "Papers"
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	chunks := make(chan []models.Paper)
+	var failed bool
+	go func() {
+		defer close(chunks)
+		err := h.exportChunks(ctx, ids, func(papers []models.Paper) error {
+			select {
+			case chunks <- papers:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error fetching papers to print: %v", err)
+			failed = true
+		}
+	}()
+
+	rc := http.NewResponseController(w)
+	data := PrintData{
+		Title:     title,
+		Total:     len(ids),
+		Generated: time.Now(),
+		Abstracts: parseBool(query.Get("abstracts"), true),
+		Notes:     parseBool(query.Get("notes"), false),
+		Chunks:    chunks,
+		Flush: func() string {
+			rc.Flush()
+			return ""
+		},
+		// Read after the channel is closed, which orders it after the write
+		Failed: func() bool { return failed },
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if err := h.templates.ExecuteTemplate(w, "print.html", data); err != nil {
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// exportIDs returns the IDs of the papers an export asks for, chosen as
+// described on HandleExportLaTeX. On failure it writes the error response
+// and returns false.
+func (h *Handler) exportIDs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	query := r.URL.Query()
+
+	ids := query["ids"]
+	if name := query.Get("shelf"); len(ids) == 0 && name != "" {
+		shelf, err := h.db.GetShelf(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Shelf not found", http.StatusNotFound)
+			return nil, false
+		}
+		if err != nil {
+			serverError(w, "Failed to fetch shelf", err)
+			log.Printf("Error fetching shelf %s: %v", name, err)
+			return nil, false
+		}
+		papers, err := h.db.GetShelfPapers(shelf.ID, "")
+		if err != nil {
+			serverError(w, "Failed to fetch papers", err)
+			log.Printf("Error fetching papers of shelf %s: %v", name, err)
+			return nil, false
+		}
+		for _, p := range papers {
+			ids = append(ids, p.ID)
+		}
+		if len(ids) == 0 {
+			http.Error(w, "Shelf is empty", http.StatusNotFound)
+			return nil, false
+		}
+	}
+	if len(ids) == 0 {
+		var err error
+		params := search.ParseParams(query)
+		params.InLibrary = parseBool(query.Get("library"), false)
+		ids, err = h.db.GetPaperIDs(params)
+		if err != nil {
+			serverError(w, "Failed to fetch papers", err)
+			log.Printf("Error fetching paper IDs: %v", err)
+			return nil, false
+		}
+	}
+	return ids, true
+}
+
+// exportChunks loads the papers exportChunk at a time and passes each
+// chunk to fn, keeping the order of ids. It stops at the first error or
+// when ctx is done.
+func (h *Handler) exportChunks(ctx context.Context, ids []string, fn func([]models.Paper) error) error {
+	for start := 0; start < len(ids); start += exportChunk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk := ids[start:min(start+exportChunk, len(ids))]
+		papers, err := h.db.GetPapersByIDs(chunk)
+		if err != nil {
+			return err
+		}
+
+		// GetPapersByIDs sorts by date; put them back in export order
+		byID := make(map[string]models.Paper, len(papers))
+		for _, p := range papers {
+			byID[p.ID] = p
+		}
+		papers = papers[:0]
+		for _, id := range chunk {
+			if p, ok := byID[id]; ok {
+				papers = append(papers, p)
+			}
+		}
+
+		if err := fn(papers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
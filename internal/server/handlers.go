@@ -1,45 +1,144 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ngx/arxiv-go-nest/internal/arxiv"
 	"github.com/ngx/arxiv-go-nest/internal/config"
 	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/diagnostics"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/fetcher"
+	"github.com/ngx/arxiv-go-nest/internal/hooks"
 	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+	"github.com/ngx/arxiv-go-nest/internal/reader"
+	"github.com/ngx/arxiv-go-nest/internal/scheduler"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+	"github.com/ngx/arxiv-go-nest/internal/tasks"
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+	"github.com/ngx/arxiv-go-nest/internal/tts"
+	"github.com/ngx/arxiv-go-nest/internal/usage"
+	"github.com/ngx/arxiv-go-nest/internal/version"
 )
 
+// savePrompt is asked when saving a paper if ui.prompt_save_note is on
+const savePrompt = "Why are you saving this? (optional)"
+
+// statsHistory is how many snapshots per topic the trends page charts
+const statsHistory = 30
+
+// tagHistoryMonths is how many months the tag page charts
+const tagHistoryMonths = 24
+
+// rollupDays is how many days of collection activity the trends page charts
+const rollupDays = 30
+
+// topCategories is how many categories the trends page lists
+const topCategories = 10
+
+// authorPreviewRows is how many changed papers the author replace preview lists
+const authorPreviewRows = 50
+
+// htmlRecheckInterval controls how often papers without an HTML rendering
+// are re-checked; arXiv converts some papers after they are announced
+const htmlRecheckInterval = 7 * 24 * time.Hour
+
 // Handler handles HTTP requests
 type Handler struct {
 	config    *config.Config
 	db        *db.DB
-	templates *template.Template
+	templates Renderer
 	arxiv     *arxiv.Client
+	fetcher   *fetcher.Fetcher
+	features  *features.Flags
+	scheduler *scheduler.Scheduler
+
+	// diagnostics builds the bundle downloaded from /admin/diagnostics
+	diagnostics *diagnostics.Bundle
+
+	// updates reports newer releases; nil when the check is disabled
+	updates *version.Checker
+
+	// hooks runs the paper.saved hooks; nil when none are configured
+	hooks *hooks.Runner
+
+	// tts speaks abstracts; nil when no command is configured
+	tts *tts.Synthesizer
+
+	// httpClient is used to proxy HTML renderings for reader mode
+	httpClient *http.Client
+
+	// usage counts page views and actions for the usage page
+	usage *usage.Recorder
+
+	// tasks runs imports, backfills and index repairs started from the
+	// web UI and tracks their progress
+	tasks *tasks.Manager
+
+	// auth identifies users; nil or without authenticators, everyone is
+	// let in
+	auth *Auth
+
+	// reload re-reads the configuration file and returns the changed
+	// settings that need a restart; nil when the process can't reload
+	reload func() ([]string, error)
 }
 
-// NewHandler creates a new handler
-func NewHandler(cfg *config.Config, database *db.DB) (*Handler, error) {
+// NewHandler creates a new handler. The arXiv client is shared with the
+// fetcher so both respect the same rate limit.
+func NewHandler(cfg *config.Config, database *db.DB, client *arxiv.Client, f *fetcher.Fetcher, flags *features.Flags, sched *scheduler.Scheduler, logs *diagnostics.LogBuffer, updates *version.Checker, hookRunner *hooks.Runner) (*Handler, error) {
 	// Parse templates with helper functions
 	tmpl, err := NewTemplates()
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse templates: %w", err)
 	}
 
-	// Create arXiv client
-	arxivClient := arxiv.NewClient(cfg.ArXiv.RateLimitDelay)
+	synth, err := tts.New(cfg.TTS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure text-to-speech: %w", err)
+	}
+
+	auth, err := NewAuth(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure authentication: %w", err)
+	}
 
 	return &Handler{
-		config:    cfg,
-		db:        database,
-		templates: tmpl,
-		arxiv:     arxivClient,
+		config:      cfg,
+		db:          database,
+		templates:   tmpl,
+		arxiv:       client,
+		fetcher:     f,
+		features:    flags,
+		scheduler:   sched,
+		diagnostics: diagnostics.New(cfg, database, logs),
+		updates:     updates,
+		hooks:       hookRunner,
+		tts:         synth,
+		usage:       usage.New(database),
+		tasks:       tasks.New(),
+		auth:        auth,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: telemetry.Transport(nil),
+		},
 	}, nil
 }
 
@@ -55,158 +154,438 @@ type PageData struct {
 	Query            string
 	SelectedTag      string
 	SelectedCategory string
+	SelectedLength   string
+	SelectedLicense  string
+	SelectedEntity   string
+	SelectedRead     string
+	SelectedNote     string
+	MinPriority      int
+	SavedFrom        string // YYYY-MM-DD
+	SavedTo          string // YYYY-MM-DD
+	Entities         []models.Entity
 	InLibrary        bool
 	PaperCount       int
 	LibraryCount     int
+	ReaderContent    template.HTML
+	Features         map[string]bool
+	FeatureStates    []features.State
+	Trends           []TopicTrend
+	SavePrompt       string
+	SortBy           string
+	Assignments      []models.Assignment
+	PastAssignments  []models.Assignment
+	Today            time.Time
+	Trash            []models.TrashedPaper
+	BrokenLinks      []models.BrokenLink
+	Duplicates       []models.Duplicate
+	Deliveries       []models.Delivery
+	Channels         []string
+	SelectedChannel  string
+	TestChannels     []string
+	DryRun           bool
+	DryRunDeliveries []notify.Delivery
+	NotifyChannels   []ChannelSubscription
+	Jobs             []scheduler.Status
+	Tasks            []tasks.Status
+	TagCloud         []CloudTag
+	Tag              *models.Tag
+	TagMonths        []TagMonth
+	EmbedURL         string
+	Subscriptions    []fetcher.Subscription
+	Relations        []models.Relation
+	DailyPapers      []DayBar
+	Prefs            Prefs
+	PageSize         int
+	DailyReads       []DayBar
+	DailyViews       []DayBar
+	FeatureUsage     []FeatureUsage
+	PageUsage        []PageUsage
+	TopCategories    []models.CategoryCount
+	Calendar         [][]CalendarDay
+	Month            time.Time
+	Queue            []models.Paper
+	PlannedFor       time.Time
+	Audio            bool
+	Shelves          []models.Shelf
+	Views            []models.SavedView
+	View             *models.SavedView
+	ViewLink         string
+	NewPapers        []models.Paper
+	Shelf            *models.Shelf
+	ShelfOptions     []ShelfOption
+	TeamFeed         []models.ReviewedPaper
+	CuratorStats     []models.CuratorStats
+	Curators         []string
+	Curator          string
+	PaperMeta        *PaperMeta
+	PaperGroups      []PaperGroup
+
+	// CurrentURL is the request URL, used by the template URL helpers
+	CurrentURL *url.URL
+}
+
+// CloudTag is a tag in the tag cloud. Size runs from 0 for unused tags to
+// 5 for the most used.
+type CloudTag struct {
+	models.Tag
+	Size int
+}
+
+// DayBar is a day's count in a daily chart. Height is the bar height in
+// percent of the busiest day shown.
+type DayBar struct {
+	Day    time.Time
+	Count  int
+	Height int
+}
+
+// TagMonth is the number of a tag's papers published in a month. Height is
+// the bar height in percent of the busiest month shown.
+type TagMonth struct {
+	Month  time.Time
+	Count  int
+	Height int
+}
+
+// TopicTrend summarizes archive-wide submission activity for one topic
+type TopicTrend struct {
+	Topic  string
+	Query  string
+	Latest int
+	Points []TrendPoint
+}
+
+// TrendPoint is the growth of a topic between two snapshots. Height is the
+// bar height in percent of the largest growth shown for the topic.
+type TrendPoint struct {
+	Date   time.Time
+	Total  int
+	Delta  int
+	Height int
+}
+
+// sortOptions maps the sort query parameter to a sort column and order
+var sortOptions = map[string][2]string{
+	"published": {"published", "desc"},
+	"priority":  {"priority", "desc"},
+	"shortest":  {"length", "asc"},
+	"longest":   {"length", "desc"},
+}
+
+// applySort sets the sort column and order from the sort query parameter
+// and returns the chosen option, defaulting to newest first
+func applySort(params *models.SearchParams, value string) string {
+	option, ok := sortOptions[value]
+	if !ok {
+		value, option = "published", sortOptions["published"]
+	}
+	params.SortBy, params.SortOrder = option[0], option[1]
+	return value
 }
 
 // HandleIndex renders the main paper list page
 func (h *Handler) HandleIndex(w http.ResponseWriter, r *http.Request) {
-	page := getIntParam(r, "page", 1)
-	query := r.URL.Query().Get("q")
-	tag := r.URL.Query().Get("tag")
-	category := r.URL.Query().Get("category")
-
-	params := models.SearchParams{
-		Query:     query,
-		Tag:       tag,
-		Category:  category,
-		InLibrary: false,
-		Page:      page,
-		PageSize:  h.config.UI.PageSize,
-		SortBy:    "published",
-		SortOrder: "desc",
+	prefs := h.loadPrefs(r)
+	if h.restoreFilter(w, r, "browse", prefs) {
+		return
 	}
 
-	papers, total, err := h.db.GetPapers(params)
-	if err != nil {
-		http.Error(w, "Failed to fetch papers", http.StatusInternalServerError)
+	params := search.ParseParams(r.URL.Query())
+	params.PageSize = h.pageSize(prefs)
+	sortBy := applySort(&params, r.URL.Query().Get("sort"))
+
+	data := PageData{
+		Title:            "ArXiv Nest",
+		CurrentPage:      params.Page,
+		Query:            params.QueryText,
+		SelectedTag:      params.Tag,
+		SelectedCategory: params.Category,
+		SelectedLength:   params.Length,
+		SelectedLicense:  params.License,
+		SelectedEntity:   params.Entity,
+		SortBy:           sortBy,
+		Features:         h.features.Map(),
+		SavePrompt:       h.savePromptText(),
+		CurrentURL:       r.URL,
+		Prefs:            prefs,
+		PageSize:         params.PageSize,
+	}
+
+	// The queries are independent, so they run concurrently
+	var l loader
+	l.Require(func() (err error) {
+		data.Papers, data.TotalResults, err = h.db.GetPapers(params)
+		return err
+	})
+	l.Go("tags", func() (err error) {
+		data.Tags, err = h.db.GetAllTags()
+		return err
+	})
+	l.Go("entities", func() (err error) {
+		data.Entities, err = h.db.GetEntities()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch papers", err)
 		log.Printf("Error fetching papers: %v", err)
 		return
 	}
+	data.TotalPages = (data.TotalResults + params.PageSize - 1) / params.PageSize
+	// Only a list sorted by date can be read as a digest
+	if sortBy == "published" {
+		data.PaperGroups = groupByAge(data.Papers, time.Now())
+	} else if len(data.Papers) > 0 {
+		data.PaperGroups = []PaperGroup{{Papers: data.Papers}}
+	}
 
-	tags, err := h.db.GetAllTags()
-	if err != nil {
-		log.Printf("Error fetching tags: %v", err)
-		tags = []models.Tag{}
+	if err := h.templates.ExecuteTemplate(w, "list.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
 	}
+}
 
-	paperCount, _ := h.db.GetPaperCount()
-	libraryCount, _ := h.db.GetLibraryCount()
+// Age groups of the paper list, newest first
+const (
+	ageToday    = "Today"
+	ageThisWeek = "This week"
+	ageEarlier  = "Earlier"
+)
+
+// PaperGroup is a run of papers in the list under a common heading. The
+// heading is empty when the list isn't grouped.
+type PaperGroup struct {
+	Label  string
+	Papers []models.Paper
+}
 
-	totalPages := (total + h.config.UI.PageSize - 1) / h.config.UI.PageSize
+// groupByAge splits papers sorted newest first into those published
+// today, in the six days before and earlier, by the calendar of now's
+// location. The order of the papers is kept and empty groups are left out.
+func groupByAge(papers []models.Paper, now time.Time) []PaperGroup {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	weekAgo := today.AddDate(0, 0, -6)
+
+	var groups []PaperGroup
+	for _, p := range papers {
+		label := ageEarlier
+		switch published := p.PublishedAt.In(now.Location()); {
+		case !published.Before(today):
+			label = ageToday
+		case !published.Before(weekAgo):
+			label = ageThisWeek
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Label != label {
+			groups = append(groups, PaperGroup{Label: label})
+		}
+		groups[len(groups)-1].Papers = append(groups[len(groups)-1].Papers, p)
+	}
+	return groups
+}
+
+// HandlePaperDetail renders the paper detail page
+func (h *Handler) HandlePaperDetail(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
 
 	data := PageData{
-		Title:            "ArXiv Nest",
-		Papers:           papers,
-		Tags:             tags,
-		CurrentPage:      page,
-		TotalPages:       totalPages,
-		TotalResults:     total,
-		Query:            query,
-		SelectedTag:      tag,
-		SelectedCategory: category,
-		PaperCount:       paperCount,
-		LibraryCount:     libraryCount,
+		Features:   h.features.Map(),
+		SavePrompt: h.savePromptText(),
+		Audio:      h.audioEnabled(),
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "list.html", data); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	// Everything is loaded concurrently; the paper's own details are only
+	// shown if the paper exists
+	var l loader
+	l.Go("paper "+id, func() (err error) {
+		// On error the template shows a "Paper not found" message
+		data.Paper, err = h.db.GetPaperByID(id)
+		return err
+	})
+	l.Go("tags", func() (err error) {
+		data.Tags, err = h.db.GetAllTags()
+		return err
+	})
+	if h.features.Enabled(features.ReadingGroup) {
+		l.Go("assignments", func() (err error) {
+			data.Assignments, err = h.db.GetPaperAssignments(id)
+			return err
+		})
+	}
+	l.Go("relations", func() (err error) {
+		data.Relations, err = h.db.GetPaperRelations(id)
+		return err
+	})
+	l.Go("reading plan", func() (err error) {
+		data.PlannedFor, err = h.db.GetPlannedFor(id)
+		return err
+	})
+	l.Go("shelves", func() (err error) {
+		data.ShelfOptions, err = h.shelfOptions(id)
+		return err
+	})
+	h.loadCounts(&l, &data)
+	l.Wait()
+
+	if data.Paper != nil {
+		data.Title = data.Paper.Title
+		data.PaperMeta = paperMeta(r, data.Paper)
+	} else {
+		data.Title = "Paper Not Found"
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "detail.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
 		log.Printf("Error rendering template: %v", err)
 	}
 }
 
-// HandlePaperDetail renders the paper detail page
-func (h *Handler) HandlePaperDetail(w http.ResponseWriter, r *http.Request) {
+// HandleHTMLStatus checks whether a paper has an HTML rendering and returns
+// reader mode links if so (HTMX endpoint, loaded lazily by the detail page)
+func (h *Handler) HandleHTMLStatus(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	paper, err := h.db.GetPaperByID(id)
+	if err != nil {
+		http.Error(w, "Paper not found", http.StatusNotFound)
+		return
+	}
+
+	htmlURL, err := h.ensureHTMLURL(r.Context(), paper)
+	if err != nil {
+		log.Printf("Error checking HTML version of %s: %v", id, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if htmlURL != "" {
+		fmt.Fprintf(w, `<a href="/paper/%s/read" class="btn btn-primary">📖 Reader Mode</a> <a href="%s" target="_blank" class="btn btn-outline">🌐 HTML</a>`, template.HTMLEscapeString(id), template.HTMLEscapeString(htmlURL))
+	}
+}
+
+// HandleReader renders a paper's HTML version in a clean reader layout
+func (h *Handler) HandleReader(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	paper, err := h.db.GetPaperByID(id)
 	if err != nil {
+		http.Error(w, "Paper not found", http.StatusNotFound)
 		log.Printf("Error fetching paper %s: %v", id, err)
-		// Don't return error - render template with nil paper
-		// Template will show "Paper not found" message
+		return
 	}
 
-	tags, err := h.db.GetAllTags()
+	htmlURL, err := h.ensureHTMLURL(r.Context(), paper)
 	if err != nil {
-		log.Printf("Error fetching tags: %v", err)
-		tags = []models.Tag{}
+		log.Printf("Error checking HTML version of %s: %v", id, err)
+	}
+	if htmlURL == "" {
+		http.Redirect(w, r, "/paper/"+id, http.StatusSeeOther)
+		return
+	}
+
+	content, err := reader.Fetch(r.Context(), h.httpClient, htmlURL)
+	if err != nil {
+		http.Error(w, "Failed to load HTML version", http.StatusBadGateway)
+		log.Printf("Error loading HTML version of %s: %v", id, err)
+		return
 	}
 
 	paperCount, _ := h.db.GetPaperCount()
 	libraryCount, _ := h.db.GetLibraryCount()
 
-	var title string
-	if paper != nil {
-		title = paper.Title
-	} else {
-		title = "Paper Not Found"
-	}
-
 	data := PageData{
-		Title:        title,
-		Paper:        paper,
-		Tags:         tags,
-		PaperCount:   paperCount,
-		LibraryCount: libraryCount,
+		Title:         paper.Title,
+		Paper:         paper,
+		PaperCount:    paperCount,
+		LibraryCount:  libraryCount,
+		ReaderContent: content,
+		Features:      h.features.Map(),
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "detail.html", data); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	if err := h.templates.ExecuteTemplate(w, "reader.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
 		log.Printf("Error rendering template: %v", err)
 	}
 }
 
-// HandleLibrary renders the user's library page
-func (h *Handler) HandleLibrary(w http.ResponseWriter, r *http.Request) {
-	page := getIntParam(r, "page", 1)
-	query := r.URL.Query().Get("q")
-	tag := r.URL.Query().Get("tag")
-
-	params := models.SearchParams{
-		Query:     query,
-		Tag:       tag,
-		InLibrary: true,
-		Page:      page,
-		PageSize:  h.config.UI.PageSize,
-		SortBy:    "published",
-		SortOrder: "desc",
+// ensureHTMLURL returns the paper's HTML URL, checking arXiv if the stored
+// result is missing or stale. Only arXiv has HTML renderings.
+func (h *Handler) ensureHTMLURL(ctx context.Context, paper *models.Paper) (string, error) {
+	if paper.HTMLURL != "" {
+		return paper.HTMLURL, nil
+	}
+	if paper.Source() != models.SourceArxiv {
+		return "", nil
+	}
+	if paper.HTMLCheckedAt != nil && time.Since(*paper.HTMLCheckedAt) < htmlRecheckInterval {
+		return "", nil
 	}
 
-	papers, total, err := h.db.GetPapers(params)
+	htmlURL, err := h.arxiv.CheckHTML(ctx, paper.ID)
 	if err != nil {
-		http.Error(w, "Failed to fetch library", http.StatusInternalServerError)
-		log.Printf("Error fetching library: %v", err)
-		return
+		return "", err
 	}
 
-	tags, err := h.db.GetAllTags()
-	if err != nil {
-		log.Printf("Error fetching tags: %v", err)
-		tags = []models.Tag{}
+	if err := h.db.SetHTMLURL(paper.ID, htmlURL); err != nil {
+		return htmlURL, fmt.Errorf("failed to store HTML URL: %w", err)
 	}
 
-	paperCount, _ := h.db.GetPaperCount()
-	libraryCount, _ := h.db.GetLibraryCount()
+	return htmlURL, nil
+}
+
+// HandleLibrary renders the user's library page
+func (h *Handler) HandleLibrary(w http.ResponseWriter, r *http.Request) {
+	prefs := h.loadPrefs(r)
+	if h.restoreFilter(w, r, "library", prefs) {
+		return
+	}
 
-	totalPages := (total + h.config.UI.PageSize - 1) / h.config.UI.PageSize
+	params := search.ParseParams(r.URL.Query())
+	params.Category = ""
+	params.InLibrary = true
+	params.PageSize = h.pageSize(prefs)
+	sortBy := applySort(&params, r.URL.Query().Get("sort"))
 
 	data := PageData{
-		Title:        "My Library",
-		Papers:       papers,
-		Tags:         tags,
-		CurrentPage:  page,
-		TotalPages:   totalPages,
-		TotalResults: total,
-		Query:        query,
-		SelectedTag:  tag,
-		InLibrary:    true,
-		PaperCount:   paperCount,
-		LibraryCount: libraryCount,
+		Title:           "My Library",
+		CurrentPage:     params.Page,
+		Query:           params.QueryText,
+		SelectedTag:     params.Tag,
+		InLibrary:       true,
+		Features:        h.features.Map(),
+		SortBy:          sortBy,
+		CurrentURL:      r.URL,
+		SelectedLength:  params.Length,
+		SelectedLicense: params.License,
+		Audio:           h.audioEnabled(),
+		SelectedEntity:  params.Entity,
+		SelectedRead:    params.Read,
+		SelectedNote:    params.Note,
+		MinPriority:     params.MinPriority,
+		SavedFrom:       formatDay(params.SavedFrom),
+		SavedTo:         formatDay(params.SavedTo),
+		Prefs:           prefs,
+		PageSize:        params.PageSize,
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.Papers, data.TotalResults, err = h.db.GetPapers(params)
+		return err
+	})
+	l.Go("tags", func() (err error) {
+		data.Tags, err = h.db.GetAllTags()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch library", err)
+		log.Printf("Error fetching library: %v", err)
+		return
 	}
+	data.TotalPages = (data.TotalResults + params.PageSize - 1) / params.PageSize
 
 	if err := h.templates.ExecuteTemplate(w, "library.html", data); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		serverError(w, "Failed to render template", err)
 		log.Printf("Error rendering template: %v", err)
 	}
 }
@@ -221,14 +600,43 @@ func (h *Handler) HandleAddToLibrary(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	if err := h.db.SaveToLibrary(id); err != nil {
-		http.Error(w, "Failed to add to library", http.StatusInternalServerError)
+		serverError(w, "Failed to add to library", err)
 		log.Printf("Error adding to library: %v", err)
 		return
 	}
 
+	// The answer to the optional "why are you saving this?" prompt
+	if note := strings.TrimSpace(r.Header.Get("HX-Prompt")); note != "" {
+		if err := h.db.SetLibraryNote(id, note); err != nil {
+			log.Printf("Error saving library note: %v", err)
+		}
+	}
+	h.firePaperSaved(id)
+
 	w.Header().Set("HX-Trigger", `{"libraryUpdated": true, "showToast": {"message": "Saved to library", "type": "success"}}`)
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `<button hx-post="/library/remove/%s" hx-swap="outerHTML" class="btn btn-success flex-1 md:flex-none md:w-full" title="Saved to Library (Click to Remove)"><i data-lucide="check" class="w-4 h-4"></i></button><script>lucide.createIcons();</script>`, id)
+	writeSavedButton(w, id)
+}
+
+// HandleImport imports papers by arXiv ID or other preprint servers' IDs,
+// DOIs and links and saves them to the library, in the background. It
+// answers with the task's progress bar, which reports the result when the
+// import is done (HTMX endpoint).
+func (h *Handler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	refs := strings.FieldsFunc(r.FormValue("refs"), func(c rune) bool {
+		return unicode.IsSpace(c) || c == ','
+	})
+	if len(refs) == 0 {
+		http.Error(w, "Enter an ID, DOI or link", http.StatusBadRequest)
+		return
+	}
+
+	h.startImport(w, refs)
 }
 
 // HandleRemoveFromLibrary removes a paper from the library (HTMX endpoint)
@@ -236,14 +644,77 @@ func (h *Handler) HandleRemoveFromLibrary(w http.ResponseWriter, r *http.Request
 	id := chi.URLParam(r, "id")
 
 	if err := h.db.RemoveFromLibrary(id); err != nil {
-		http.Error(w, "Failed to remove from library", http.StatusInternalServerError)
+		serverError(w, "Failed to remove from library", err)
 		log.Printf("Error removing from library: %v", err)
 		return
 	}
 
 	w.Header().Set("HX-Trigger", `{"libraryUpdated": true, "showToast": {"message": "Removed from library", "type": "info"}}`)
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `<button hx-post="/library/add/%s" hx-swap="outerHTML" class="btn btn-outline flex-1 md:flex-none md:w-full" title="Save to Library"><i data-lucide="bookmark" class="w-4 h-4"></i></button><script>lucide.createIcons();</script>`, id)
+	fmt.Fprintf(w, `<button data-action="save"%s class="btn btn-outline flex-1 md:flex-none md:w-full" title="Save to Library"><i data-lucide="bookmark" class="w-4 h-4"></i></button><script>lucide.createIcons();</script>`, h.saveButtonAttrs(id))
+}
+
+// firePaperSaved runs the paper.saved hooks for a paper
+func (h *Handler) firePaperSaved(id string) {
+	if h.hooks == nil {
+		return
+	}
+	paper, err := h.db.GetPaperByID(id)
+	if err != nil {
+		log.Printf("Error fetching paper %s for hooks: %v", id, err)
+		return
+	}
+	h.hooks.Fire(hooks.Event{Name: hooks.PaperSaved, Paper: hooks.NewPaper(paper)})
+}
+
+// savePromptText returns the question asked when saving a paper, or ""
+// if the prompt is disabled
+func (h *Handler) savePromptText() string {
+	if !h.config.UI.PromptSaveNote {
+		return ""
+	}
+	return savePrompt
+}
+
+// HandleUpdateLibraryEntry sets a library paper's priority and "why saved"
+// note (HTMX endpoint)
+func (h *Handler) HandleUpdateLibraryEntry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	priority, err := strconv.Atoi(r.FormValue("priority"))
+	if err != nil || priority < models.PriorityNone || priority > models.PriorityHigh {
+		http.Error(w, "Invalid priority", http.StatusBadRequest)
+		return
+	}
+	note := strings.TrimSpace(r.FormValue("note"))
+
+	if err := h.db.UpdateLibraryEntry(id, priority, note); err != nil {
+		serverError(w, "Failed to update library entry", err)
+		log.Printf("Error updating library entry: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Library entry updated", "type": "success"}}`)
+	w.WriteHeader(http.StatusOK)
+	writeEntryMeta(w, id, priority, note)
+}
+
+// writeEntryMeta writes the priority badge and "why saved" note shown on
+// library cards
+func writeEntryMeta(w io.Writer, id string, priority int, note string) {
+	fmt.Fprintf(w, `<div id="entry-%s" class="entry-meta">`, id)
+	if priority != models.PriorityNone {
+		fmt.Fprintf(w, `<span class="priority priority-%d">%s priority</span> `, priority, models.PriorityLabel(priority))
+	}
+	if note != "" {
+		fmt.Fprintf(w, `<span class="entry-note">Why: %s</span>`, template.HTMLEscapeString(note))
+	}
+	fmt.Fprint(w, `</div>`)
 }
 
 // HandleToggleRead toggles the read status (HTMX endpoint)
@@ -251,7 +722,7 @@ func (h *Handler) HandleToggleRead(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	if err := h.db.ToggleRead(id); err != nil {
-		http.Error(w, "Failed to toggle read status", http.StatusInternalServerError)
+		serverError(w, "Failed to toggle read status", err)
 		log.Printf("Error toggling read status: %v", err)
 		return
 	}
@@ -259,19 +730,106 @@ func (h *Handler) HandleToggleRead(w http.ResponseWriter, r *http.Request) {
 	// Fetch updated paper to get current read status
 	paper, err := h.db.GetPaperByID(id)
 	if err != nil {
-		http.Error(w, "Failed to fetch paper", http.StatusInternalServerError)
+		serverError(w, "Failed to fetch paper", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	writeReadButton(w, id, paper.IsRead, false)
+}
+
+// HandleSetRead explicitly marks a single library paper as read or unread.
+// Unlike toggle-read it is idempotent, so keyboard shortcuts can fire it
+// repeatedly without flipping state back (HTMX endpoint).
+func (h *Handler) HandleSetRead(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	read := parseBool(r.FormValue("read"), true)
+
+	if _, err := h.db.SetReadStatus([]string{id}, read); err != nil {
+		serverError(w, "Failed to update read status", err)
+		log.Printf("Error updating read status: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	writeReadButton(w, id, read, false)
+}
+
+// HandleBulkRead marks many library papers as read or unread (HTMX endpoint).
+// The papers are the repeated "ids" values (typically the current page), or
+// with scope=filter every library paper matching the q/tag filter. Read
+// buttons for the listed ids are returned as out-of-band swaps.
+func (h *Handler) HandleBulkRead(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	read := parseBool(r.FormValue("read"), true)
+	visible := r.Form["ids"]
+
+	ids := visible
+	if r.FormValue("scope") == "filter" {
+		var err error
+		params := search.ParseParams(r.Form)
+		params.Category = ""
+		params.InLibrary = true
+		ids, err = h.db.GetPaperIDs(params)
+		if err != nil {
+			serverError(w, "Failed to fetch papers", err)
+			log.Printf("Error fetching paper IDs: %v", err)
+			return
+		}
+	}
+
+	if len(ids) == 0 {
+		http.Error(w, "No papers selected", http.StatusBadRequest)
+		return
+	}
+
+	changed, err := h.db.SetReadStatus(ids, read)
+	if err != nil {
+		serverError(w, "Failed to update read status", err)
+		log.Printf("Error updating read status: %v", err)
 		return
 	}
 
+	status := "read"
+	if !read {
+		status = "unread"
+	}
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"libraryUpdated": true, "showToast": {"message": "Marked %d papers as %s", "type": "success"}}`, len(changed), status))
 	w.WriteHeader(http.StatusOK)
-	if paper.IsRead {
-		fmt.Fprintf(w, `<button hx-post="/library/toggle-read/%s" hx-swap="outerHTML" class="btn btn-sm btn-success">✓ Read</button>`, id)
+	for _, id := range visible {
+		if slices.Contains(changed, id) {
+			writeReadButton(w, id, read, true)
+		}
+	}
+}
+
+// writeReadButton writes the read toggle button fragment for a paper.
+// With oob set the button replaces its existing copy via hx-swap-oob.
+func writeReadButton(w io.Writer, id string, isRead bool, oob bool) {
+	oobAttr := ""
+	if oob {
+		oobAttr = ` hx-swap-oob="true"`
+	}
+
+	attr, path := template.HTMLEscapeString(id), template.HTMLEscapeString(url.PathEscape(id))
+	if isRead {
+		fmt.Fprintf(w, `<button id="read-%s" data-action="read" hx-post="/library/toggle-read/%s" hx-swap="outerHTML" class="btn btn-sm btn-success"%s>✓ Read</button>`, attr, path, oobAttr)
 	} else {
-		fmt.Fprintf(w, `<button hx-post="/library/toggle-read/%s" hx-swap="outerHTML" class="btn btn-sm btn-outline">Mark as Read</button>`, id)
+		fmt.Fprintf(w, `<button id="read-%s" data-action="read" hx-post="/library/toggle-read/%s" hx-swap="outerHTML" class="btn btn-sm btn-outline"%s>Mark as Read</button>`, attr, path, oobAttr)
 	}
 }
 
-// HandleAddTag adds a tag to a paper (HTMX endpoint)
+// HandleAddTag adds a tag to a paper (HTMX endpoint). It returns the
+// paper's tag list, or with suggested=true just the new tag.
 func (h *Handler) HandleAddTag(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
@@ -286,32 +844,41 @@ func (h *Handler) HandleAddTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create or get tag
-	tagID, err := h.db.CreateTag(tagName)
+	// Create or get tag; personal tags are only seen by this browser
+	create := h.db.CreateTag
+	if parseBool(r.FormValue("personal"), false) && clientID(r) != "" {
+		create = h.db.CreatePersonalTag
+	}
+	tagID, err := create(tagName)
 	if err != nil {
-		http.Error(w, "Failed to create tag", http.StatusInternalServerError)
+		serverError(w, "Failed to create tag", err)
 		log.Printf("Error creating tag: %v", err)
 		return
 	}
 
 	// Associate tag with paper
 	if err := h.db.TagPaper(paperID, tagID); err != nil {
-		http.Error(w, "Failed to tag paper", http.StatusInternalServerError)
+		serverError(w, "Failed to tag paper", err)
 		log.Printf("Error tagging paper: %v", err)
 		return
 	}
 
+	// An accepted suggestion on a paper card becomes a plain tag
+	if parseBool(r.FormValue("suggested"), false) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `<a href="/?tag=%s" class="tag" title="Filter by tag">%s</a>`, url.QueryEscape(tagName), template.HTMLEscapeString(tagName))
+		return
+	}
+
 	// Return updated tag list
 	tags, err := h.db.GetPaperTags(paperID)
 	if err != nil {
-		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		serverError(w, "Failed to fetch tags", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	for _, tag := range tags {
-		fmt.Fprintf(w, `<span class="tag">%s <button hx-post="/tag/remove" hx-vals='{"paper_id":"%s","tag_id":%d}' hx-target="#tags-%s" hx-swap="innerHTML" class="tag-remove">×</button></span> `, tag.Name, paperID, tag.ID, paperID)
-	}
+	writePaperTags(w, paperID, tags)
 }
 
 // HandleRemoveTag removes a tag from a paper (HTMX endpoint)
@@ -331,7 +898,7 @@ func (h *Handler) HandleRemoveTag(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.db.UntagPaper(paperID, tagID); err != nil {
-		http.Error(w, "Failed to remove tag", http.StatusInternalServerError)
+		serverError(w, "Failed to remove tag", err)
 		log.Printf("Error removing tag: %v", err)
 		return
 	}
@@ -339,71 +906,1199 @@ func (h *Handler) HandleRemoveTag(w http.ResponseWriter, r *http.Request) {
 	// Return updated tag list
 	tags, err := h.db.GetPaperTags(paperID)
 	if err != nil {
-		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		serverError(w, "Failed to fetch tags", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
+	writePaperTags(w, paperID, tags)
+}
+
+// writePaperTags writes a paper's tag list with remove buttons, as on the
+// detail page
+func writePaperTags(w io.Writer, paperID string, tags []models.Tag) {
 	for _, tag := range tags {
-		fmt.Fprintf(w, `<span class="tag">%s <button hx-post="/tag/remove" hx-vals='{"paper_id":"%s","tag_id":%d}' hx-target="#tags-%s" hx-swap="innerHTML" class="tag-remove">×</button></span> `, tag.Name, paperID, tag.ID, paperID)
+		class, title := "tag", "Tag page"
+		if tag.Owner != "" {
+			class, title = "tag tag-personal", "Personal tag, only visible to you"
+		}
+		fmt.Fprintf(w, `<span class="%s"><a href="/tags/%s" title="%s">%s</a> <button hx-post="/tag/remove" hx-vals='{"paper_id":"%s","tag_id":%d}' hx-target="#tags-%s" hx-swap="innerHTML" class="tag-remove">×</button></span> `,
+			class, url.PathEscape(tag.Name), title, template.HTMLEscapeString(tag.Name), paperID, tag.ID, paperID)
 	}
 }
 
 // HandleRefresh manually triggers a fetch of new papers
 func (h *Handler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
-
-	params := arxiv.FetchParams{
-		Categories: h.config.ArXiv.Categories,
-		Keywords:   h.config.ArXiv.Keywords,
-		MaxResults: h.config.ArXiv.MaxResults,
-		SortBy:     "submittedDate",
-		SortOrder:  "descending",
-	}
-
-	feed, err := h.arxiv.FetchNew(ctx, params)
+	result, err := h.fetcher.Run(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to fetch papers", http.StatusInternalServerError)
+		serverError(w, "Failed to fetch papers", err)
 		log.Printf("Error fetching papers: %v", err)
 		return
 	}
 
-	papers, err := feed.ToPapers()
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<span class="text-green-600 dark:text-green-400">✓ Successfully fetched and stored %d papers</span>`, result.Stored)
+}
+
+// HandleStats renders archive-wide submission trends per subscription topic
+func (h *Handler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	// One extra snapshot so the oldest charted point has a delta
+	stats, err := h.db.GetArchiveStats(statsHistory + 1)
 	if err != nil {
-		http.Error(w, "Failed to parse papers", http.StatusInternalServerError)
-		log.Printf("Error parsing papers: %v", err)
+		serverError(w, "Failed to fetch statistics", err)
+		log.Printf("Error fetching archive stats: %v", err)
 		return
 	}
 
-	// Insert papers into database
-	count := 0
-	for _, paper := range papers {
-		if err := h.db.UpsertPaper(paper); err != nil {
-			log.Printf("Error inserting paper %s: %v", paper.ID, err)
-			continue
-		}
-		count++
+	// Collection activity comes from the daily rollups, not the papers table
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(rollupDays - 1))
+	dailyPapers, err := h.db.GetDailyPapers(since)
+	if err != nil {
+		log.Printf("Error fetching daily paper counts: %v", err)
+	}
+	dailyReads, err := h.db.GetDailyReads(since)
+	if err != nil {
+		log.Printf("Error fetching daily reads: %v", err)
+	}
+	categories, err := h.db.GetCategoryTotals(since, topCategories)
+	if err != nil {
+		log.Printf("Error fetching category totals: %v", err)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `<span class="text-green-600 dark:text-green-400">✓ Successfully fetched and stored %d papers</span>`, count)
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:         "Trends",
+		PaperCount:    paperCount,
+		LibraryCount:  libraryCount,
+		Features:      h.features.Map(),
+		Trends:        buildTrends(stats),
+		DailyPapers:   buildDayBars(dailyPapers, since, today),
+		DailyReads:    buildDayBars(dailyReads, since, today),
+		TopCategories: categories,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "stats.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
 }
 
-// getIntParam extracts an integer parameter from the URL query string
-func getIntParam(r *http.Request, key string, defaultValue int) int {
-	valueStr := r.URL.Query().Get(key)
-	if valueStr == "" {
-		return defaultValue
+// buildDayBars turns rollup counts into one bar per day from first to last,
+// filling in days without activity. It returns nil if there was none.
+func buildDayBars(counts []models.DayCount, first, last time.Time) []DayBar {
+	byDay := make(map[string]int, len(counts))
+	maxCount := 0
+	for _, c := range counts {
+		byDay[c.Day] = c.Count
+		if c.Count > maxCount {
+			maxCount = c.Count
+		}
+	}
+	if maxCount == 0 {
+		return nil
 	}
 
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
+	var bars []DayBar
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		count := byDay[d.Format("2006-01-02")]
+		bars = append(bars, DayBar{Day: d, Count: count, Height: count * 100 / maxCount})
 	}
+	return bars
+}
 
-	if value < 1 {
-		return defaultValue
+// buildTrends groups snapshots (ordered by topic, then time) into per-topic
+// series of growth between consecutive snapshots
+func buildTrends(stats []models.ArchiveStat) []TopicTrend {
+	var trends []TopicTrend
+	for i, stat := range stats {
+		if i == 0 || stats[i-1].Topic != stat.Topic {
+			trends = append(trends, TopicTrend{Topic: stat.Topic})
+		}
+		trend := &trends[len(trends)-1]
+		trend.Query = stat.Query
+		trend.Latest = stat.TotalResults
+
+		if i > 0 && stats[i-1].Topic == stat.Topic {
+			delta := stat.TotalResults - stats[i-1].TotalResults
+			if delta < 0 {
+				delta = 0 // withdrawn papers or an index hiccup
+			}
+			trend.Points = append(trend.Points, TrendPoint{
+				Date:  stat.RecordedAt,
+				Total: stat.TotalResults,
+				Delta: delta,
+			})
+		}
 	}
 
-	return value
+	for i := range trends {
+		maxDelta := 0
+		for _, p := range trends[i].Points {
+			if p.Delta > maxDelta {
+				maxDelta = p.Delta
+			}
+		}
+		if maxDelta == 0 {
+			continue
+		}
+		for j := range trends[i].Points {
+			trends[i].Points[j].Height = trends[i].Points[j].Delta * 100 / maxDelta
+		}
+	}
+
+	return trends
+}
+
+// HandlePresentations renders the reading group's presentation queue
+func (h *Handler) HandlePresentations(w http.ResponseWriter, r *http.Request) {
+	upcoming, err := h.db.GetAssignments(false)
+	if err != nil {
+		serverError(w, "Failed to fetch assignments", err)
+		log.Printf("Error fetching assignments: %v", err)
+		return
+	}
+
+	past, err := h.db.GetAssignments(true)
+	if err != nil {
+		log.Printf("Error fetching presented assignments: %v", err)
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:           "Presentations",
+		PaperCount:      paperCount,
+		LibraryCount:    libraryCount,
+		Features:        h.features.Map(),
+		Assignments:     upcoming,
+		PastAssignments: past,
+		Today:           today(),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "presentations.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleCreateAssignment assigns a paper to a group member and returns the
+// paper's updated assignment list (HTMX endpoint)
+func (h *Handler) HandleCreateAssignment(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	paperID := r.FormValue("paper_id")
+	assignee := strings.TrimSpace(r.FormValue("assignee"))
+	due, err := time.Parse("2006-01-02", r.FormValue("due_date"))
+	if paperID == "" || assignee == "" || err != nil {
+		http.Error(w, "Paper, assignee and due date are required", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.db.PaperExists(paperID)
+	if err != nil {
+		serverError(w, "Failed to fetch paper", err)
+		log.Printf("Error fetching paper %s: %v", paperID, err)
+		return
+	}
+	if !exists {
+		http.Error(w, "Paper not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := h.db.CreateAssignment(paperID, assignee, due); err != nil {
+		serverError(w, "Failed to create assignment", err)
+		log.Printf("Error creating assignment: %v", err)
+		return
+	}
+
+	assignments, err := h.db.GetPaperAssignments(paperID)
+	if err != nil {
+		serverError(w, "Failed to fetch assignments", err)
+		log.Printf("Error fetching assignments: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "Assigned to %s", "type": "success"}}`, template.JSEscapeString(assignee)))
+	w.WriteHeader(http.StatusOK)
+	for _, a := range assignments {
+		writeAssignment(w, a)
+	}
+}
+
+// HandleSetPresented marks an assignment as presented (or pending again with
+// presented=false). The row is removed from the queue (HTMX endpoint).
+func (h *Handler) HandleSetPresented(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid assignment ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.SetAssignmentPresented(id, parseBool(r.FormValue("presented"), true)); err != nil {
+		serverError(w, "Failed to update assignment", err)
+		log.Printf("Error updating assignment: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Presentation updated", "type": "success"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDeleteAssignment removes an assignment (HTMX endpoint)
+func (h *Handler) HandleDeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid assignment ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteAssignment(id); err != nil {
+		serverError(w, "Failed to delete assignment", err)
+		log.Printf("Error deleting assignment: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Assignment removed", "type": "info"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeAssignment writes an assignment list item for the paper detail page
+func writeAssignment(w io.Writer, a models.Assignment) {
+	status := ""
+	if a.Presented {
+		status = " ✓"
+	}
+	fmt.Fprintf(w, `<li id="assignment-%d" class="flex items-center gap-2"><span>%s — %s%s</span><button hx-post="/assignments/%d/delete" hx-target="#assignment-%d" hx-swap="outerHTML" class="tag-remove" title="Remove">×</button></li>`,
+		a.ID, template.HTMLEscapeString(a.Assignee), a.DueDate.Format("Jan 2, 2006"), status, a.ID, a.ID)
+}
+
+// HandleAddRelation links the paper to another paper by arXiv ID or abs URL
+// and returns the updated relations list (HTMX endpoint)
+func (h *Handler) HandleAddRelation(w http.ResponseWriter, r *http.Request) {
+	paperID := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	kind := r.FormValue("kind")
+	otherID := relatedPaperID(r.FormValue("other"))
+	if otherID == "" || !models.ValidRelationKind(kind) {
+		http.Error(w, "Relation kind and paper are required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetPaperByID(otherID); err != nil {
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "Paper %s is not in the database", "type": "error"}}`, template.JSEscapeString(otherID)))
+		http.Error(w, "Related paper not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.AddRelation(paperID, otherID, kind); err != nil {
+		if errors.Is(err, db.ErrSelfRelation) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serverError(w, "Failed to add relation", err)
+		log.Printf("Error adding relation: %v", err)
+		return
+	}
+
+	relations, err := h.db.GetPaperRelations(paperID)
+	if err != nil {
+		serverError(w, "Failed to fetch relations", err)
+		log.Printf("Error fetching relations: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Relation added", "type": "success"}}`)
+	w.WriteHeader(http.StatusOK)
+	for _, rel := range relations {
+		writeRelation(w, rel, paperID)
+	}
+}
+
+// HandleDeleteRelation removes a relation (HTMX endpoint)
+func (h *Handler) HandleDeleteRelation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid relation ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteRelation(id); err != nil {
+		serverError(w, "Failed to delete relation", err)
+		log.Printf("Error deleting relation: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Relation removed", "type": "info"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// relatedPaperID accepts an arXiv ID or abs/pdf URL, with or without a
+// version suffix, and returns the bare ID papers are stored under
+func relatedPaperID(input string) string {
+	id := strings.TrimSpace(input)
+	for _, marker := range []string{"/abs/", "/pdf/"} {
+		if i := strings.LastIndex(id, marker); i >= 0 {
+			id = id[i+len(marker):]
+		}
+	}
+	id = strings.TrimSuffix(id, ".pdf")
+	if i := strings.LastIndex(id, "v"); i > 0 {
+		if _, err := strconv.Atoi(id[i+1:]); err == nil {
+			id = id[:i]
+		}
+	}
+	return id
+}
+
+// writeRelation writes a relation list item for the paper detail page
+func writeRelation(w io.Writer, rel models.Relation, paperID string) {
+	other := rel.Other(paperID)
+	fmt.Fprintf(w, `<li id="relation-%d" class="flex items-center gap-2"><span class="text-sm text-gray-500 dark:text-gray-400">%s</span><a href="/paper/%s" class="text-blue-600 dark:text-blue-400 hover:underline">%s</a><button hx-post="/relations/%d/delete" hx-target="#relation-%d" hx-swap="outerHTML" class="tag-remove" title="Remove">×</button></li>`,
+		rel.ID, template.HTMLEscapeString(rel.Label(paperID)), url.PathEscape(other), template.HTMLEscapeString(rel.OtherTitle), rel.ID, rel.ID)
+}
+
+// HandleFeatures renders the feature flag admin page
+func (h *Handler) HandleFeatures(w http.ResponseWriter, r *http.Request) {
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:         "Features",
+		PaperCount:    paperCount,
+		LibraryCount:  libraryCount,
+		Features:      h.features.Map(),
+		FeatureStates: h.features.All(),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "features.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleSetFeature overrides a feature flag at runtime, or with reset=true
+// removes the override so config.yaml applies again (HTMX endpoint)
+func (h *Handler) HandleSetFeature(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if parseBool(r.FormValue("reset"), false) {
+		err = h.features.Reset(name)
+	} else {
+		err = h.features.Set(name, parseBool(r.FormValue("enabled"), true))
+	}
+	if errors.Is(err, features.ErrLightweight) {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "Unavailable in lightweight mode", "type": "error"}}`)
+		http.Error(w, "Feature is unavailable in lightweight mode", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update feature", http.StatusBadRequest)
+		log.Printf("Error updating feature %s: %v", name, err)
+		return
+	}
+
+	for _, state := range h.features.All() {
+		if state.Name == name {
+			w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "Updated %s", "type": "success"}}`, name))
+			w.WriteHeader(http.StatusOK)
+			writeFeatureRow(w, state)
+			return
+		}
+	}
+	http.Error(w, "Feature not found", http.StatusNotFound)
+}
+
+// writeFeatureRow writes the admin table row for a feature flag
+func writeFeatureRow(w io.Writer, state features.State) {
+	status, toggleLabel, toggleValue := "Off", "Enable", "true"
+	if state.Enabled {
+		status, toggleLabel, toggleValue = "On", "Disable", "false"
+	}
+
+	if state.Locked {
+		fmt.Fprintf(w, `<tr id="feature-%s"><td class="py-2 pr-4 font-mono">%s</td><td class="py-2 pr-4">%s</td><td class="py-2 pr-4">Off (lightweight mode)</td><td class="py-2 text-right"></td></tr>`,
+			state.Name, state.Name, template.HTMLEscapeString(state.Description))
+		return
+	}
+
+	reset := ""
+	if state.Overridden {
+		reset = fmt.Sprintf(` <button hx-post="/admin/features/%s" hx-vals='{"reset":"true"}' hx-target="#feature-%s" hx-swap="outerHTML" class="btn btn-sm btn-outline">Reset</button>`, state.Name, state.Name)
+	}
+
+	fmt.Fprintf(w, `<tr id="feature-%s"><td class="py-2 pr-4 font-mono">%s</td><td class="py-2 pr-4">%s</td><td class="py-2 pr-4">%s</td><td class="py-2 text-right"><button hx-post="/admin/features/%s" hx-vals='{"enabled":"%s"}' hx-target="#feature-%s" hx-swap="outerHTML" class="btn btn-sm btn-primary">%s</button>%s</td></tr>`,
+		state.Name, state.Name, template.HTMLEscapeString(state.Description), status, state.Name, toggleValue, state.Name, toggleLabel, reset)
+}
+
+// HandleDiagnostics downloads a diagnostics bundle to attach to bug reports
+func (h *Handler) HandleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := h.diagnostics.WriteZip(&buf); err != nil {
+		serverError(w, "Failed to build diagnostics", err)
+		log.Printf("Error building diagnostics: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, diagnostics.Filename(time.Now())))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// HandleUpdateBanner writes the "update available" banner when a newer
+// release exists and the user has not dismissed it (HTMX endpoint)
+func (h *Handler) HandleUpdateBanner(w http.ResponseWriter, r *http.Request) {
+	if h.updates == nil {
+		return
+	}
+	release := h.updates.Available()
+	if release == nil || release.Tag == r.URL.Query().Get("dismissed") {
+		return
+	}
+
+	notes := ""
+	if strings.HasPrefix(release.URL, "https://") {
+		notes = fmt.Sprintf(` <a href="%s" target="_blank" rel="noopener">Release notes</a>`, template.HTMLEscapeString(release.URL))
+	}
+
+	fmt.Fprintf(w, `<div class="update-banner flex items-center justify-between gap-4 px-4 py-2 mb-6 rounded-lg text-sm"><span>ArXiv Nest %s is available (running %s).%s</span><button type="button" class="tag-remove" title="Dismiss" onclick="localStorage.setItem('dismissedUpdate', '%s'); this.parentElement.remove()">×</button></div>`,
+		template.HTMLEscapeString(release.Tag), template.HTMLEscapeString(version.Get().Version),
+		notes, template.JSEscapeString(release.Tag))
+}
+
+// HandleTags renders the tag cloud, with tags sized by how many papers carry them
+func (h *Handler) HandleTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.db.GetTagCloud()
+	if err != nil {
+		serverError(w, "Failed to fetch tags", err)
+		log.Printf("Error fetching tags: %v", err)
+		return
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:        "Tags",
+		PaperCount:   paperCount,
+		LibraryCount: libraryCount,
+		Features:     h.features.Map(),
+		TagCloud:     buildTagCloud(tags),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "tags.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// buildTagCloud sizes tags on a logarithmic scale of their paper counts, so
+// a few heavily used tags don't shrink everything else to the minimum
+func buildTagCloud(tags []models.Tag) []CloudTag {
+	maxCount := 0
+	for _, tag := range tags {
+		if tag.PaperCount > maxCount {
+			maxCount = tag.PaperCount
+		}
+	}
+
+	cloud := make([]CloudTag, len(tags))
+	for i, tag := range tags {
+		cloud[i].Tag = tag
+		switch {
+		case tag.PaperCount == 0:
+			cloud[i].Size = 0
+		case maxCount == 1:
+			cloud[i].Size = 1
+		default:
+			cloud[i].Size = 1 + int(math.Round(4*math.Log(float64(tag.PaperCount))/math.Log(float64(maxCount))))
+		}
+	}
+	return cloud
+}
+
+// HandleTagDetail renders a tag's landing page: its description, papers
+// published per month and the tagged papers
+func (h *Handler) HandleTagDetail(w http.ResponseWriter, r *http.Request) {
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.GetTag(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch tag", err)
+		log.Printf("Error fetching tag %s: %v", name, err)
+		return
+	}
+
+	counts, err := h.db.GetTagMonthlyCounts(tag.ID)
+	if err != nil {
+		log.Printf("Error counting papers for tag %s: %v", name, err)
+	}
+
+	params := search.ParseParams(r.URL.Query())
+	params.Tag = tag.Name
+	params.PageSize = h.pageSize(h.loadPrefs(r))
+	papers, total, err := h.db.GetPapers(params)
+	if err != nil {
+		serverError(w, "Failed to fetch papers", err)
+		log.Printf("Error fetching papers: %v", err)
+		return
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:        tag.Name,
+		Tag:          tag,
+		TagMonths:    buildTagMonths(counts, tagHistoryMonths),
+		Papers:       papers,
+		CurrentPage:  params.Page,
+		TotalPages:   (total + params.PageSize - 1) / params.PageSize,
+		TotalResults: total,
+		PaperCount:   paperCount,
+		LibraryCount: libraryCount,
+		Features:     h.features.Map(),
+		CurrentURL:   r.URL,
+	}
+	if embed := h.tagEmbedURL(tag.Name); embed != "" && tag.Owner == "" {
+		data.EmbedURL = baseURL(r) + embed
+	}
+	if tag.Owner == "" {
+		data.NotifyChannels = h.collectionChannels(models.CollectionTag, tag.ID)
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "tag.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// buildTagMonths fills in months without papers between the first and
+// last month, keeping at most the latest limit months
+func buildTagMonths(counts []models.MonthCount, limit int) []TagMonth {
+	byMonth := make(map[time.Time]int, len(counts))
+	var first, last time.Time
+	for _, c := range counts {
+		month, err := time.Parse("2006-01", c.Month)
+		if err != nil {
+			continue
+		}
+		byMonth[month] = c.Count
+		if first.IsZero() || month.Before(first) {
+			first = month
+		}
+		if month.After(last) {
+			last = month
+		}
+	}
+	if len(byMonth) == 0 {
+		return nil
+	}
+
+	if earliest := last.AddDate(0, -(limit - 1), 0); first.Before(earliest) {
+		first = earliest
+	}
+
+	var months []TagMonth
+	maxCount := 0
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		months = append(months, TagMonth{Month: m, Count: byMonth[m]})
+		if byMonth[m] > maxCount {
+			maxCount = byMonth[m]
+		}
+	}
+	for i := range months {
+		months[i].Height = months[i].Count * 100 / maxCount
+	}
+	return months
+}
+
+// HandleSetTagDescription updates a tag's description (HTMX endpoint)
+func (h *Handler) HandleSetTagDescription(w http.ResponseWriter, r *http.Request) {
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.GetTag(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch tag", err)
+		log.Printf("Error fetching tag %s: %v", name, err)
+		return
+	}
+
+	description := strings.TrimSpace(r.FormValue("description"))
+	if err := h.db.SetTagDescription(tag.ID, description); err != nil {
+		serverError(w, "Failed to update tag", err)
+		log.Printf("Error updating tag %s: %v", name, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Description saved", "type": "success"}}`)
+	w.WriteHeader(http.StatusOK)
+	writeTagDescription(w, description)
+}
+
+// HandleShareTag makes one of the browser's personal tags visible to
+// everyone and reloads the tag's page
+func (h *Handler) HandleShareTag(w http.ResponseWriter, r *http.Request) {
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.GetTag(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch tag", err)
+		log.Printf("Error fetching tag %s: %v", name, err)
+		return
+	}
+
+	if err := h.db.ShareTag(tag.ID); err == db.ErrPersonalTag {
+		http.Error(w, "Only the tag's owner can share it", http.StatusForbidden)
+		return
+	} else if err == db.ErrSharedTagExists {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "There is already a shared tag with this name", "type": "error"}}`)
+		http.Error(w, "A shared tag with this name exists", http.StatusConflict)
+		return
+	} else if err != nil {
+		serverError(w, "Failed to share tag", err)
+		log.Printf("Error sharing tag %s: %v", name, err)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeTagDescription writes the description paragraph on a tag's page
+func writeTagDescription(w io.Writer, description string) {
+	if description == "" {
+		fmt.Fprint(w, `<p id="tag-description" class="text-gray-500 dark:text-gray-400 italic">No description yet.</p>`)
+		return
+	}
+	fmt.Fprintf(w, `<p id="tag-description" class="text-gray-700 dark:text-gray-300 whitespace-pre-line">%s</p>`, template.HTMLEscapeString(description))
+}
+
+// HandleScheduler renders the background job admin page
+func (h *Handler) HandleScheduler(w http.ResponseWriter, r *http.Request) {
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:         "Scheduler",
+		PaperCount:    paperCount,
+		LibraryCount:  libraryCount,
+		Features:      h.features.Map(),
+		Subscriptions: h.fetcher.Subscriptions(),
+	}
+	if h.scheduler != nil {
+		data.Jobs = h.scheduler.Status()
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "scheduler.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleSchedulerAction pauses, resumes or immediately runs a job (HTMX endpoint)
+func (h *Handler) HandleSchedulerAction(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		http.Error(w, "Scheduler is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	name, action := chi.URLParam(r, "job"), chi.URLParam(r, "action")
+
+	var err error
+	var message string
+	switch action {
+	case "pause":
+		err, message = h.scheduler.Pause(name), "Paused "+name
+	case "resume":
+		err, message = h.scheduler.Resume(name), "Resumed "+name
+	case "run":
+		err, message = h.scheduler.RunNow(name), "Started "+name
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, scheduler.ErrRunning):
+		http.Error(w, "Job is already running", http.StatusConflict)
+		return
+	case err != nil:
+		serverError(w, "Failed to update job", err)
+		log.Printf("Error updating job %s: %v", name, err)
+		return
+	}
+
+	status, _ := h.scheduler.Get(name)
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": %q, "type": "success"}}`, message))
+	w.WriteHeader(http.StatusOK)
+	writeJobRow(w, status)
+}
+
+// HandleReload re-reads the configuration file and applies the
+// subscriptions, notification channels and job schedules (HTMX endpoint).
+// It answers with a note on what still needs a restart.
+func (h *Handler) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if h.reload == nil {
+		http.Error(w, "Reloading is not available in this process", http.StatusServiceUnavailable)
+		return
+	}
+
+	restart, err := h.reload()
+	if err != nil {
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": %q, "type": "error"}}`, "Configuration not reloaded: "+err.Error()))
+		http.Error(w, "Failed to reload the configuration", http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Configuration reloaded", "type": "success"}}`)
+	fmt.Fprintf(w, `<span>Reloaded at %s; <a href="/admin/scheduler" class="text-blue-600 hover:text-blue-800 dark:text-blue-400">refresh</a> to see the new jobs and subscriptions.`, time.Now().Format("15:04:05"))
+	if len(restart) > 0 {
+		fmt.Fprintf(w, ` Restart the server to apply the changes to %s.`, template.HTMLEscapeString(strings.Join(restart, ", ")))
+	}
+	fmt.Fprint(w, `</span>`)
+}
+
+// HandleRunSubscription starts fetching one configured category or keyword
+// in the background through the scheduler, which stops it on shutdown and
+// runs it once at a time (HTMX endpoint). The outcome is logged.
+func (h *Handler) HandleRunSubscription(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		http.Error(w, "Scheduler is not running", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	sub := fetcher.Subscription{Kind: r.FormValue("kind"), Value: r.FormValue("value")}
+	known := false
+	for _, s := range h.fetcher.Subscriptions() {
+		known = known || s.Kind == sub.Kind && s.Value == sub.Value
+	}
+	if !known {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	if status, _ := h.scheduler.Get("fetch"); status.Running {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "A fetch of all subscriptions is running", "type": "error"}}`)
+		http.Error(w, "A fetch of all subscriptions is running", http.StatusConflict)
+		return
+	}
+	err := h.scheduler.RunOnce("fetch:"+sub.Kind+":"+sub.Value, func(ctx context.Context) error {
+		result, err := h.fetcher.RunSubscription(ctx, sub)
+		if err != nil {
+			return err
+		}
+		log.Printf("Fetched %s %s: %d papers, %d new", sub.Kind, sub.Value, result.Stored, len(result.New))
+		return nil
+	})
+	if errors.Is(err, scheduler.ErrRunning) {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "This subscription is already being fetched", "type": "error"}}`)
+		http.Error(w, "Already fetching", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to start fetch", err)
+		log.Printf("Error starting fetch of %s %s: %v", sub.Kind, sub.Value, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprint(w, `<span class="text-gray-600 dark:text-gray-400">Fetching in the background…</span>`)
+}
+
+// writeJobRow writes the scheduler admin table row for a job
+func writeJobRow(w io.Writer, job scheduler.Status) {
+	status, action, label := "Scheduled", "pause", "Pause"
+	if job.Paused {
+		status, action, label = "Paused", "resume", "Resume"
+	}
+	if job.Running {
+		status = "Running"
+	}
+	if job.LastError != "" {
+		status += fmt.Sprintf(` <span class="text-red-600 dark:text-red-400" title="%s">(last run failed)</span>`, template.HTMLEscapeString(job.LastError))
+	}
+
+	fmt.Fprintf(w, `<tr id="job-%s"><td class="py-2 pr-4"><div class="font-mono">%s</div><div class="text-gray-500 dark:text-gray-400">%s</div></td><td class="py-2 pr-4">%s</td><td class="py-2 pr-4">%s</td><td class="py-2 pr-4">%s</td><td class="py-2 pr-4">%s</td><td class="py-2 text-right whitespace-nowrap"><button hx-post="/admin/scheduler/%s/%s" hx-target="#job-%s" hx-swap="outerHTML" class="btn btn-sm btn-outline">%s</button> <button hx-post="/admin/scheduler/%s/run" hx-target="#job-%s" hx-swap="outerHTML" class="btn btn-sm btn-primary">Run now</button></td></tr>`,
+		job.Name, job.Name, template.HTMLEscapeString(job.Description), job.Interval, formatJobTime(job.LastRun), formatJobTime(job.NextRun), status,
+		job.Name, action, job.Name, label, job.Name, job.Name)
+}
+
+// formatJobTime formats a job's last or next run, or a dash if there is none
+func formatJobTime(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Local().Format("Jan 2 15:04:05")
+}
+
+// requireFeature responds 404 to requests for a disabled feature
+func (h *Handler) requireFeature(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.features.Enabled(name) {
+				http.Error(w, "Feature disabled", http.StatusNotFound)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// busyRetryAfter is the Retry-After hint, in seconds, sent while the
+// database is busy
+const busyRetryAfter = "5"
+
+// serverError answers a request that failed with err. A database held by
+// another writer (usually a fetch) gets a 503 with a "try again" toast
+// rather than a bare 500, since the request will likely work shortly.
+func serverError(w http.ResponseWriter, message string, err error) {
+	if db.IsBusy(err) {
+		w.Header().Set("Retry-After", busyRetryAfter)
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "The database is busy, probably with a fetch. Try again in a moment.", "type": "error"}}`)
+		http.Error(w, "Database is busy, try again in a moment", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// baseURL is the scheme and host the request was made to, for links that
+// must work outside the app, e.g. in a downloaded playlist
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// parseBool interprets a form value such as "true", "1" or "false",
+// returning defaultValue if it is empty or unrecognized
+func parseBool(value string, defaultValue bool) bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+// formatDay formats a date filter for a date input, empty if it's unset
+func formatDay(day time.Time) string {
+	if day.IsZero() {
+		return ""
+	}
+	return day.Format("2006-01-02")
+}
+
+// getIntParam extracts an integer parameter from the URL query string
+func getIntParam(r *http.Request, key string, defaultValue int) int {
+	valueStr := r.URL.Query().Get(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	if value < 1 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// pathParam returns a URL path parameter, such as a tag name. chi has
+// already decoded it unless the path holds escapes decoding would lose,
+// such as an escaped "/", in which case it matched the raw path.
+func pathParam(r *http.Request, key string) (string, error) {
+	value := chi.URLParam(r, key)
+	if r.URL.RawPath == "" {
+		return value, nil
+	}
+	return url.PathUnescape(value)
+}
+
+// HandleDeletePaper moves a paper to the trash (HTMX endpoint). The card is
+// removed, or with a local redirect form value the browser navigates there.
+func (h *Handler) HandleDeletePaper(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	count, err := h.db.TrashPapers([]string{id})
+	if err != nil {
+		serverError(w, "Failed to delete paper", err)
+		log.Printf("Error trashing paper %s: %v", id, err)
+		return
+	}
+	if count == 0 {
+		http.Error(w, "Paper not found", http.StatusNotFound)
+		return
+	}
+
+	if redirect := r.FormValue("redirect"); strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") {
+		w.Header().Set("HX-Redirect", redirect)
+	}
+	w.Header().Set("HX-Trigger", `{"libraryUpdated": true, "showToast": {"message": "Moved to trash", "type": "info"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleBulkDelete moves the given papers, or with scope=filter every paper
+// matching the search filters, to the trash and reloads the page
+func (h *Handler) HandleBulkDelete(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	ids := r.Form["ids"]
+	if r.FormValue("scope") == "filter" {
+		var err error
+		params := search.ParseParams(r.Form)
+		params.InLibrary = parseBool(r.FormValue("library"), false)
+		ids, err = h.db.GetPaperIDs(params)
+		if err != nil {
+			serverError(w, "Failed to fetch papers", err)
+			log.Printf("Error fetching paper IDs: %v", err)
+			return
+		}
+	}
+
+	if len(ids) == 0 {
+		http.Error(w, "No papers selected", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.db.TrashPapers(ids)
+	if err != nil {
+		serverError(w, "Failed to delete papers", err)
+		log.Printf("Error trashing papers: %v", err)
+		return
+	}
+
+	log.Printf("Moved %d papers to the trash", count)
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleTrash renders the recycle bin
+func (h *Handler) HandleTrash(w http.ResponseWriter, r *http.Request) {
+	trash, err := h.db.GetTrash()
+	if err != nil {
+		serverError(w, "Failed to fetch trash", err)
+		log.Printf("Error fetching trash: %v", err)
+		return
+	}
+
+	if retention := h.config.TrashRetention(); retention > 0 {
+		for i := range trash {
+			trash[i].PurgeAt = trash[i].DeletedAt.Add(retention)
+		}
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:        "Trash",
+		PaperCount:   paperCount,
+		LibraryCount: libraryCount,
+		Features:     h.features.Map(),
+		Trash:        trash,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "trash.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleRestorePaper moves a paper out of the trash (HTMX endpoint)
+func (h *Handler) HandleRestorePaper(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.db.RestorePaper(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Paper not in trash", http.StatusNotFound)
+			return
+		}
+		serverError(w, "Failed to restore paper", err)
+		log.Printf("Error restoring paper %s: %v", id, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"libraryUpdated": true, "showToast": {"message": "Paper restored", "type": "success"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandlePurgePaper permanently deletes a paper from the trash (HTMX endpoint)
+func (h *Handler) HandlePurgePaper(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.db.PurgePaper(id); err != nil {
+		serverError(w, "Failed to delete paper", err)
+		log.Printf("Error purging paper %s: %v", id, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Deleted permanently", "type": "info"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleEmptyTrash permanently deletes everything in the trash
+func (h *Handler) HandleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	count, err := h.db.PurgeTrash(time.Now())
+	if err != nil {
+		serverError(w, "Failed to empty trash", err)
+		log.Printf("Error emptying trash: %v", err)
+		return
+	}
+
+	log.Printf("Emptied trash (%d papers)", count)
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleAuthors renders the bulk author replace admin page
+func (h *Handler) HandleAuthors(w http.ResponseWriter, r *http.Request) {
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:        "Authors",
+		PaperCount:   paperCount,
+		LibraryCount: libraryCount,
+		Features:     h.features.Map(),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "authors.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleAuthorPreview shows which papers a bulk author replace would
+// change, with a button to apply it (HTMX endpoint)
+func (h *Handler) HandleAuthorPreview(w http.ResponseWriter, r *http.Request) {
+	from, to := r.FormValue("from"), r.FormValue("to")
+
+	changes, err := h.db.PreviewAuthorReplace(from, to)
+	if err != nil {
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "%s", "type": "error"}}`, template.JSEscapeString(err.Error())))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if len(changes) == 0 {
+		fmt.Fprintf(w, `<p class="text-gray-500 dark:text-gray-400">No author lists contain “%s”.</p>`, template.HTMLEscapeString(from))
+		return
+	}
+
+	fmt.Fprintf(w, `<p class="mb-4 text-gray-700 dark:text-gray-300">%d papers will change.`, len(changes))
+	if len(changes) > authorPreviewRows {
+		fmt.Fprintf(w, ` Showing the first %d.`, authorPreviewRows)
+	}
+	fmt.Fprint(w, `</p><table class="w-full text-sm text-left text-gray-900 dark:text-gray-100 mb-4"><thead class="text-gray-500 dark:text-gray-400 border-b border-gray-200 dark:border-gray-700"><tr><th class="py-2 pr-4">Paper</th><th class="py-2 pr-4">Before</th><th class="py-2">After</th></tr></thead><tbody>`)
+	for i, c := range changes {
+		if i == authorPreviewRows {
+			break
+		}
+		fmt.Fprintf(w, `<tr><td class="py-2 pr-4"><a href="/paper/%s" class="text-blue-600 dark:text-blue-400 hover:underline">%s</a></td><td class="py-2 pr-4">%s</td><td class="py-2">%s</td></tr>`,
+			url.PathEscape(c.PaperID), template.HTMLEscapeString(c.Title), template.HTMLEscapeString(c.Before), template.HTMLEscapeString(c.After))
+	}
+	fmt.Fprint(w, `</tbody></table>`)
+
+	fmt.Fprintf(w, `<form hx-post="/admin/authors/replace" hx-target="#author-preview" hx-confirm="Replace in %d papers?"><input type="hidden" name="from" value="%s"><input type="hidden" name="to" value="%s"><input type="hidden" name="expected" value="%d"><button type="submit" class="btn btn-primary">Replace in %d papers</button></form>`,
+		len(changes), template.HTMLEscapeString(from), template.HTMLEscapeString(to), len(changes), len(changes))
+}
+
+// HandleAuthorReplace applies a previewed bulk author replace (HTMX endpoint)
+func (h *Handler) HandleAuthorReplace(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	expected, err := strconv.Atoi(r.FormValue("expected"))
+	if err != nil {
+		http.Error(w, "Preview the replacement first", http.StatusBadRequest)
+		return
+	}
+
+	changed, err := h.db.ReplaceAuthor(r.FormValue("from"), r.FormValue("to"), expected)
+	switch {
+	case errors.Is(err, db.ErrPreviewStale):
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "%s", "type": "error"}}`, template.JSEscapeString(err.Error())))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		serverError(w, "Failed to replace author", err)
+		log.Printf("Error replacing author: %v", err)
+		return
+	}
+
+	log.Printf("Replaced author %q with %q in %d papers", r.FormValue("from"), r.FormValue("to"), changed)
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "Updated %d papers", "type": "success"}}`, changed))
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<p class="text-gray-700 dark:text-gray-300">Replaced in %d papers.</p>`, changed)
 }
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -11,10 +15,16 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/mattn/go-sqlite3"
 	"github.com/ngx/arxiv-go-nest/internal/arxiv"
 	"github.com/ngx/arxiv-go-nest/internal/config"
 	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/fetcher"
 	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+	"github.com/ngx/arxiv-go-nest/internal/tasks"
+	"github.com/ngx/arxiv-go-nest/internal/usage"
 )
 
 func setupTestHandler(t *testing.T) (*Handler, *db.DB) {
@@ -402,3 +412,1269 @@ func TestGetIntParam(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleBulkRead(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	insertTestPapers(t, testDB, 3)
+	testDB.SaveToLibrary("1")
+	testDB.SaveToLibrary("2")
+	testDB.SaveToLibrary("3")
+
+	// Mark the visible page as read
+	form := url.Values{}
+	form.Add("ids", "1")
+	form.Add("ids", "2")
+	form.Add("read", "true")
+
+	req := httptest.NewRequest("POST", "/library/bulk-read", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	handler.HandleBulkRead(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `id="read-1" data-action="read"`) || !strings.Contains(w.Body.String(), `hx-swap-oob="true"`) {
+		t.Errorf("Expected out-of-band read buttons, got %s", w.Body.String())
+	}
+
+	for id, expected := range map[string]bool{"1": true, "2": true, "3": false} {
+		paper, _ := testDB.GetPaperByID(id)
+		if paper.IsRead != expected {
+			t.Errorf("Expected paper %s read=%v, got %v", id, expected, paper.IsRead)
+		}
+	}
+
+	// Mark everything matching the filter as unread
+	form = url.Values{}
+	form.Add("scope", "filter")
+	form.Add("read", "false")
+
+	req = httptest.NewRequest("POST", "/library/bulk-read", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+
+	handler.HandleBulkRead(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		paper, _ := testDB.GetPaperByID(id)
+		if paper.IsRead {
+			t.Errorf("Expected paper %s to be unread", id)
+		}
+	}
+
+	// Only the buttons of papers that changed come back, so posted IDs
+	// that aren't papers are never echoed
+	hostile := `"><script>alert(1)</script>`
+	testDB.SetReadStatus([]string{"2"}, true)
+	form = url.Values{"ids": {"1", "2", hostile}, "read": {"true"}}
+	req = httptest.NewRequest("POST", "/library/bulk-read", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+
+	handler.HandleBulkRead(w, req)
+
+	if body := w.Body.String(); !strings.Contains(body, `id="read-1"`) || strings.Contains(body, `id="read-2"`) || strings.Contains(body, "<script>") {
+		t.Errorf("Expected only paper 1's button, got %s", body)
+	}
+
+	var buf strings.Builder
+	writeReadButton(&buf, hostile, true, true)
+	if strings.Contains(buf.String(), "<script>") || !strings.Contains(buf.String(), `hx-post="/library/toggle-read/%22%3E%3Cscript%3Ealert%281%29%3C%2Fscript%3E"`) {
+		t.Errorf("Expected the ID to be escaped, got %s", buf.String())
+	}
+}
+
+func TestHandleSetRead(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	insertTestPapers(t, testDB, 1)
+	testDB.SaveToLibrary("1")
+
+	// Setting read twice must not toggle it back
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/library/read/1", strings.NewReader("read=true"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		handler.HandleSetRead(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	paper, _ := testDB.GetPaperByID("1")
+	if !paper.IsRead {
+		t.Error("Expected paper to be marked as read")
+	}
+}
+
+func TestBuildTrends(t *testing.T) {
+	_, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	for _, total := range []int{100, 110, 130} {
+		if err := testDB.RecordArchiveStat("cs.AI", "cat:cs.AI", total); err != nil {
+			t.Fatalf("RecordArchiveStat failed: %v", err)
+		}
+	}
+	if err := testDB.RecordArchiveStat("graph", "all:graph", 50); err != nil {
+		t.Fatalf("RecordArchiveStat failed: %v", err)
+	}
+
+	stats, err := testDB.GetArchiveStats(statsHistory + 1)
+	if err != nil {
+		t.Fatalf("GetArchiveStats failed: %v", err)
+	}
+
+	trends := buildTrends(stats)
+	if len(trends) != 2 {
+		t.Fatalf("Expected 2 topics, got %d", len(trends))
+	}
+
+	ai := trends[0]
+	if ai.Topic != "cs.AI" || ai.Latest != 130 || len(ai.Points) != 2 {
+		t.Fatalf("Unexpected trend: %+v", ai)
+	}
+	if ai.Points[0].Delta != 10 || ai.Points[1].Delta != 20 || ai.Points[1].Height != 100 || ai.Points[0].Height != 50 {
+		t.Errorf("Unexpected points: %+v", ai.Points)
+	}
+
+	if len(trends[1].Points) != 0 {
+		t.Errorf("Expected no points for a single snapshot, got %+v", trends[1].Points)
+	}
+}
+
+func TestLibraryEntryMetadata(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	insertTestPapers(t, testDB, 2)
+
+	// The answer to the save prompt becomes the note
+	req := httptest.NewRequest("POST", "/library/add/1", nil)
+	req.Header.Set("HX-Prompt", "  baseline for chapter 3 ")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	handler.HandleAddToLibrary(w, req)
+
+	paper, _ := testDB.GetPaperByID("1")
+	if paper.Note != "baseline for chapter 3" {
+		t.Errorf("Expected note from prompt, got %q", paper.Note)
+	}
+
+	testDB.SaveToLibrary("2")
+	req = httptest.NewRequest("POST", "/library/entry/2", strings.NewReader("priority=3&note=must+read"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rctx = chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w = httptest.NewRecorder()
+	handler.HandleUpdateLibraryEntry(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "High priority") || !strings.Contains(w.Body.String(), "must read") {
+		t.Errorf("Unexpected fragment: %s", w.Body.String())
+	}
+
+	// Priority sort puts the high-priority paper first
+	papers, _, err := testDB.GetPapers(models.SearchParams{InLibrary: true, Page: 1, PageSize: 10, SortBy: "priority", SortOrder: "desc"})
+	if err != nil {
+		t.Fatalf("GetPapers failed: %v", err)
+	}
+	if len(papers) != 2 || papers[0].ID != "2" || papers[0].Priority != models.PriorityHigh {
+		t.Errorf("Expected paper 2 first with high priority, got %+v", papers)
+	}
+
+	// Invalid priorities are rejected
+	req = httptest.NewRequest("POST", "/library/entry/2", strings.NewReader("priority=9"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w = httptest.NewRecorder()
+	handler.HandleUpdateLibraryEntry(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	insertTestPapers(t, testDB, 2)
+
+	for _, form := range []string{"paper_id=1&assignee=Ana&due_date=2024-03-14", "paper_id=2&assignee=Raj&due_date=2024-03-07"} {
+		req := httptest.NewRequest("POST", "/assignments", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.HandleCreateAssignment(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	upcoming, err := testDB.GetAssignments(false)
+	if err != nil {
+		t.Fatalf("GetAssignments failed: %v", err)
+	}
+	if len(upcoming) != 2 || upcoming[0].Assignee != "Raj" || upcoming[0].DueDate.Format("2006-01-02") != "2024-03-07" {
+		t.Fatalf("Expected Raj's earlier presentation first, got %+v", upcoming)
+	}
+	if upcoming[0].PaperTitle == "" {
+		t.Error("Expected paper title to be joined")
+	}
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/assignments/%d/presented", upcoming[0].ID), nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", fmt.Sprint(upcoming[0].ID))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	handler.HandleSetPresented(w, req)
+
+	past, _ := testDB.GetAssignments(true)
+	if len(past) != 1 || past[0].Assignee != "Raj" {
+		t.Errorf("Expected Raj's assignment to be presented, got %+v", past)
+	}
+
+	// Missing fields are rejected
+	req = httptest.NewRequest("POST", "/assignments", strings.NewReader("paper_id=1&assignee=&due_date=soon"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.HandleCreateAssignment(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	// ...and so are papers that aren't stored
+	req = httptest.NewRequest("POST", "/assignments", strings.NewReader("paper_id=9999.99999&assignee=Ana&due_date=2024-03-14"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.HandleCreateAssignment(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing paper, got %d", w.Code)
+	}
+}
+
+func TestURLHelpers(t *testing.T) {
+	u, _ := url.Parse("/search?q=" + url.QueryEscape("graphs & trees") + "&tag=" + url.QueryEscape("ménage") + "&page=3")
+
+	if got := pageURL(u, 4); got != "/search?page=4&q=graphs+%26+trees&tag=m%C3%A9nage" {
+		t.Errorf("pageURL = %q", got)
+	}
+	if got := pageURL(u, 1); got != "/search?q=graphs+%26+trees&tag=m%C3%A9nage" {
+		t.Errorf("pageURL(1) = %q", got)
+	}
+	if got := setParam(u, "category", "cs.AI"); got != "/search?category=cs.AI&q=graphs+%26+trees&tag=m%C3%A9nage" {
+		t.Errorf("setParam = %q", got)
+	}
+	if got := toggleTag(u, "ménage"); got != "/search?q=graphs+%26+trees" {
+		t.Errorf("toggleTag off = %q", got)
+	}
+	if got := toggleTag(u, "nlp"); got != "/search?q=graphs+%26+trees&tag=nlp" {
+		t.Errorf("toggleTag on = %q", got)
+	}
+	if got := linkTo("/export/latex", u, "longtable", "true"); got != "/export/latex?longtable=true&q=graphs+%26+trees&tag=m%C3%A9nage" {
+		t.Errorf("linkTo = %q", got)
+	}
+	if got := pageURL(nil, 2); got != "?page=2" {
+		t.Errorf("pageURL(nil) = %q", got)
+	}
+
+	// Links carry the normalized query, however it was typed
+	u, _ = url.Parse("/search?q=" + url.QueryEscape("  Graphs   ÉTUDE "))
+	if got := pageURL(u, 2); got != "/search?page=2&q=graphs+%C3%A9tude" {
+		t.Errorf("pageURL with a typed query = %q", got)
+	}
+}
+
+func TestBuildTagCloudAndMonths(t *testing.T) {
+	cloud := buildTagCloud([]models.Tag{
+		{Name: "a", PaperCount: 0},
+		{Name: "b", PaperCount: 1},
+		{Name: "c", PaperCount: 10},
+		{Name: "d", PaperCount: 100},
+	})
+	for i, want := range []int{0, 1, 3, 5} {
+		if cloud[i].Size != want {
+			t.Errorf("Tag %s: expected size %d, got %d", cloud[i].Name, want, cloud[i].Size)
+		}
+	}
+
+	months := buildTagMonths([]models.MonthCount{{Month: "2023-11", Count: 4}, {Month: "2024-02", Count: 2}}, 3)
+	if len(months) != 3 {
+		t.Fatalf("Expected 3 months, got %d", len(months))
+	}
+	if months[0].Month.Format("2006-01") != "2023-12" || months[0].Count != 0 || months[2].Count != 2 {
+		t.Errorf("Unexpected months: %+v", months)
+	}
+	if months[2].Height != 100 {
+		t.Errorf("Expected the busiest month shown at full height, got %d", months[2].Height)
+	}
+}
+
+func TestHandleAddRelation(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	insertTestPapers(t, testDB, 2)
+
+	post := func(form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/paper/1/relations", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.HandleAddRelation(w, req)
+		return w
+	}
+
+	w := post("kind=extends&other=https://arxiv.org/abs/2v3")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "Extends") || !strings.Contains(w.Body.String(), "Test Paper 2") {
+		t.Errorf("Expected relation in response, got %s", w.Body.String())
+	}
+
+	if w := post("kind=extends&other=9999.99999"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown paper to give 404, got %d", w.Code)
+	}
+	if w := post("kind=extends&other=1"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected self link to give 400, got %d", w.Code)
+	}
+	if w := post("kind=cites&other=2"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown kind to give 400, got %d", w.Code)
+	}
+}
+
+func TestBuildDayBars(t *testing.T) {
+	first := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 0, 3)
+
+	bars := buildDayBars([]models.DayCount{{Day: "2024-03-02", Count: 4}, {Day: "2024-03-04", Count: 2}}, first, last)
+	if len(bars) != 4 {
+		t.Fatalf("Expected a bar per day, got %+v", bars)
+	}
+	if bars[0].Count != 0 || bars[1].Height != 100 || bars[3].Height != 50 {
+		t.Errorf("Unexpected bars: %+v", bars)
+	}
+
+	if bars := buildDayBars(nil, first, last); bars != nil {
+		t.Errorf("Expected no bars without activity, got %+v", bars)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	cookie := &http.Cookie{Name: clientCookie, Value: "0123456789abcdef0123456789abcdef"}
+	index := clientMiddleware(http.HandlerFunc(handler.HandleIndex))
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		index.ServeHTTP(w, req)
+		return w
+	}
+
+	// A filtered visit is remembered and restored on the next bare visit
+	if w := get("/?category=cs.LG&page=2"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	w := get("/")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/?category=cs.LG" {
+		t.Fatalf("Expected redirect to the last filter, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	// Clearing the filters forgets it
+	get("/?reset=1")
+	if w := get("/"); w.Code != http.StatusOK {
+		t.Errorf("Expected no redirect after reset, got %d", w.Code)
+	}
+
+	// A browser without a cookie gets one
+	w = httptest.NewRecorder()
+	index.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Header().Get("Set-Cookie"), clientCookie+"=") {
+		t.Errorf("Expected client cookie to be set, got %q", w.Header().Get("Set-Cookie"))
+	}
+
+	set := clientMiddleware(http.HandlerFunc(handler.HandleSetPreferences))
+	post := func(form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/preferences", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		set.ServeHTTP(w, req)
+		return w
+	}
+
+	w = post("page_size=50&filters_collapsed=true")
+	if w.Code != http.StatusNoContent || w.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("Expected 204 with a refresh, got %d", w.Code)
+	}
+	if w := post("page_size=7"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid page size to be rejected, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), clientIDKey{}, cookie.Value))
+	prefs := handler.loadPrefs(req)
+	if prefs.PageSize != 50 || !prefs.FiltersCollapsed || handler.pageSize(prefs) != 50 {
+		t.Errorf("Expected stored preferences, got %+v", prefs)
+	}
+}
+
+func TestAuthorReplace(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	insertTestPapers(t, testDB, 3)
+
+	req := httptest.NewRequest("GET", "/admin/authors/preview?from=Author+2&to=Author+Two", nil)
+	w := httptest.NewRecorder()
+	handler.HandleAuthorPreview(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "1 papers will change") || !strings.Contains(w.Body.String(), `name="expected" value="1"`) {
+		t.Fatalf("Unexpected preview: %d %s", w.Code, w.Body.String())
+	}
+
+	replace := func(expected string) *httptest.ResponseRecorder {
+		form := url.Values{"from": {"Author 2"}, "to": {"Author Two"}, "expected": {expected}}
+		req := httptest.NewRequest("POST", "/admin/authors/replace", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.HandleAuthorReplace(w, req)
+		return w
+	}
+
+	if w := replace("3"); w.Code != http.StatusConflict {
+		t.Errorf("Expected a stale preview to give 409, got %d", w.Code)
+	}
+	if w := replace("1"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if paper, _ := testDB.GetPaperByID("2"); paper.Authors != "Author Two" {
+		t.Errorf("Expected replaced author, got %q", paper.Authors)
+	}
+}
+
+func TestBuildCalendar(t *testing.T) {
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	plan := []models.PlannedRead{{PaperID: "2401.00001", PlannedFor: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)}}
+
+	weeks := buildCalendar(month, plan, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+
+	// March 2024 starts on a Friday and ends on a Sunday
+	if len(weeks) != 5 {
+		t.Fatalf("Expected 5 weeks, got %d", len(weeks))
+	}
+	if first := weeks[0][0]; first.Date.Day() != 26 || first.InMonth {
+		t.Errorf("Expected the grid to start on Monday Feb 26, got %+v", first)
+	}
+	if last := weeks[4][6]; last.Date.Day() != 31 || !last.InMonth {
+		t.Errorf("Expected the grid to end on Sunday Mar 31, got %+v", last)
+	}
+	if day := weeks[1][1]; !day.Today || len(day.Reads) != 1 {
+		t.Errorf("Expected Mar 5 to be today with one planned read, got %+v", day)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	securityHeaders(nil)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for name, want := range defaultSecurityHeaders {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("Expected default %s %q, got %q", name, want, got)
+		}
+	}
+
+	// Overrides replace headers by name in any case, and empty values drop them
+	rec = httptest.NewRecorder()
+	securityHeaders(map[string]string{
+		"content-security-policy": "default-src 'self' https://cdn.example.com",
+		"X-Frame-Options":         "",
+	})(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Content-Security-Policy"); got != "default-src 'self' https://cdn.example.com" {
+		t.Errorf("Expected overridden CSP, got %q", got)
+	}
+	if _, set := rec.Header()["X-Frame-Options"]; set {
+		t.Error("Expected X-Frame-Options to be dropped")
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Expected headers without override to keep their default")
+	}
+}
+
+func TestServerErrorWhenBusy(t *testing.T) {
+	rec := httptest.NewRecorder()
+	serverError(rec, "Failed to add to library", fmt.Errorf("failed: %w", sqlite3.Error{Code: sqlite3.ErrLocked}))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After while busy, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Header().Get("HX-Trigger"), "Try again") {
+		t.Errorf("Expected a try again toast, got %q", rec.Header().Get("HX-Trigger"))
+	}
+
+	rec = httptest.NewRecorder()
+	serverError(rec, "Failed to add to library", fmt.Errorf("disk I/O error"))
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("HX-Trigger") != "" {
+		t.Errorf("Expected a plain 500 for other errors, got %d", rec.Code)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	var l loader
+	var tags, count int
+	l.Go("tags", func() error {
+		tags = 3
+		return nil
+	})
+	l.Go("count", func() error {
+		return fmt.Errorf("database is locked")
+	})
+	l.Go("entities", func() error {
+		panic("nil map")
+	})
+	l.Require(func() error {
+		count = 1
+		return nil
+	})
+	if err := l.Wait(); err != nil {
+		t.Fatalf("Expected optional failures to be tolerated, got %v", err)
+	}
+	if tags != 3 || count != 1 {
+		t.Errorf("Expected every query to run, got tags=%d count=%d", tags, count)
+	}
+
+	var required loader
+	required.Go("tags", func() error { return nil })
+	required.Require(func() error { return fmt.Errorf("no such table: papers") })
+	if err := required.Wait(); err == nil || err.Error() != "no such table: papers" {
+		t.Errorf("Expected the required query's error, got %v", err)
+	}
+}
+
+func TestRequireLogin(t *testing.T) {
+	signedIn := false
+	handler := requireLogin(func(*http.Request) bool { return signedIn }, "/login")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+
+	// A full page load is redirected, coming back to the page
+	req := httptest.NewRequest("GET", "/library?tag=ml", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login?next=%2Flibrary%3Ftag%3Dml" {
+		t.Errorf("Expected a 302 to the login page, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// An HTMX request redirects the whole page rather than swapping in the login page
+	req = httptest.NewRequest("POST", "/library/add/2401.00001", nil)
+	req.Host = "nest.example"
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Current-URL", "http://nest.example/paper/2401.00001")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Location") != "" {
+		t.Errorf("Expected a 401 without Location, got %d", w.Code)
+	}
+	if got := w.Header().Get("HX-Redirect"); got != "/login?next=%2Fpaper%2F2401.00001" {
+		t.Errorf("Expected HX-Redirect back to the paper, got %q", got)
+	}
+
+	// Another site's page isn't returned to
+	req.Header.Set("HX-Current-URL", "http://evil.example/")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("HX-Redirect"); got != "/login" {
+		t.Errorf("Expected HX-Redirect to the login page alone, got %q", got)
+	}
+
+	signedIn = true
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected a signed in request through, got %d", w.Code)
+	}
+}
+
+func TestReviewPapers(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+	handler.config.Curation.Curators = []string{"alice"}
+
+	insertTestPapers(t, testDB, 3)
+	testDB.QueueForReview([]string{"1", "2", "3"}, time.Now())
+
+	review := func(user string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/review", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if user != "" {
+			req = req.WithContext(context.WithValue(req.Context(), identityKey{}, &Identity{User: user}))
+		}
+		w := httptest.NewRecorder()
+		handler.HandleReviewPapers(w, req)
+		return w
+	}
+
+	// Only a signed-in configured curator can review
+	if w := review("", url.Values{"decision": {"approve"}, "ids": {"1"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an anonymous request to get 403, got %d", w.Code)
+	}
+	if w := review("mallory", url.Values{"decision": {"approve"}, "ids": {"1"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a non-curator to get 403, got %d", w.Code)
+	}
+
+	if w := review("alice", url.Values{"decision": {"maybe"}, "ids": {"1"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown decision to get 400, got %d", w.Code)
+	}
+	if w := review("alice", url.Values{"decision": {"approve"}, "ids": {"1"}}); w.Code != http.StatusOK || w.Header().Get("HX-Refresh") != "" {
+		t.Errorf("Expected the card to be swapped out, got %d", w.Code)
+	}
+	w := review("alice", url.Values{"decision": {"reject"}, "ids": {"2", "3"}, "bulk": {"1"}})
+	if w.Code != http.StatusOK || w.Header().Get("HX-Refresh") != "true" {
+		t.Errorf("Expected a bulk review to refresh the page, got %d", w.Code)
+	}
+
+	feed, _ := testDB.GetTeamFeed(10)
+	if len(feed) != 1 || feed[0].ID != "1" || feed[0].Curator != "alice" {
+		t.Errorf("Expected alice's approval in the team feed, got %+v", feed)
+	}
+	if count, _ := testDB.GetPendingReviewCount(); count != 0 {
+		t.Errorf("Expected an empty queue, got %d", count)
+	}
+}
+
+func TestEmbedTag(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+	handler.templates = template.Must(template.New("test").Parse(`{{define "embed.html"}}{{range .Papers}}{{.ID}} {{end}}+{{.More}}{{end}}`))
+
+	insertTestPapers(t, testDB, 3)
+	tagID, _ := testDB.CreateTag("lab reading")
+	for _, id := range []string{"1", "2", "3"} {
+		testDB.TagPaper(id, tagID)
+	}
+	personalID, _ := testDB.ForClient("alice").CreatePersonalTag("mine")
+	testDB.ForClient("alice").TagPaper("1", personalID)
+
+	router := chi.NewRouter()
+	router.Use(securityHeaders(nil))
+	router.Get("/embed/tag/{name}", handler.HandleEmbedTag)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	if w := get("/embed/tag/lab%20reading?token=x"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected embedding to be off without a secret, got %d", w.Code)
+	}
+
+	handler.config.Embed.Secret = "s3cret"
+	handler.config.Embed.FrameAncestors = []string{"https://lab.example.edu"}
+	link := handler.tagEmbedURL("lab reading")
+	w := get(link + "&limit=2")
+	if w.Code != http.StatusOK || w.Body.String() != "3 2 +1" {
+		t.Fatalf("Expected the two newest papers, got %d %q", w.Code, w.Body.String())
+	}
+	if _, set := w.Header()["X-Frame-Options"]; set {
+		t.Error("Expected X-Frame-Options to be dropped")
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.HasSuffix(csp, "; frame-ancestors https://lab.example.edu") || strings.Contains(csp, "frame-ancestors 'none'") {
+		t.Errorf("Expected framing by the lab site only, got %q", csp)
+	}
+
+	// A token is only good for its own tag, and only under the secret it was made with
+	if w := get(strings.Replace(link, "lab%20reading", "mine", 1)); w.Code != http.StatusForbidden {
+		t.Errorf("Expected another tag's token to be refused, got %d", w.Code)
+	}
+	if w := get(handler.tagEmbedURL("mine")); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a personal tag not to be embedded, got %d", w.Code)
+	}
+	handler.config.Embed.Secret = "rotated"
+	if w := get(link); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a changed secret to revoke the link, got %d", w.Code)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	reload := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.HandleReload(w, httptest.NewRequest("POST", "/admin/reload", nil))
+		return w
+	}
+
+	if w := reload(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a reloader, got %d", w.Code)
+	}
+
+	handler.reload = func() ([]string, error) { return nil, fmt.Errorf("yaml: line 3: bad indentation") }
+	if w := reload(); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Header().Get("HX-Trigger"), "bad indentation") {
+		t.Errorf("Expected the error in a toast, got %d %q", w.Code, w.Header().Get("HX-Trigger"))
+	}
+
+	handler.reload = func() ([]string, error) { return []string{"server.port", "ui.page_size"}, nil }
+	w := reload()
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "apply the changes to server.port, ui.page_size") {
+		t.Errorf("Expected the settings needing a restart, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleTestNotification(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+	insertTestPapers(t, testDB, 1)
+
+	notifier, err := notify.New(config.NotificationsConfig{
+		DryRun:   true,
+		Channels: []config.NotificationChannel{{Name: "team", Type: "webhook", URL: "https://hooks.example.com/services/SECRET"}},
+	})
+	if err != nil {
+		t.Fatalf("notify.New failed: %v", err)
+	}
+	handler.fetcher = fetcher.New(handler.config, testDB, handler.arxiv, notifier, nil)
+
+	test := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/deliveries/test", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.HandleTestNotification(w, req)
+		return w
+	}
+
+	w := test(url.Values{"channel": {"team"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Preview only") || !strings.Contains(w.Body.String(), "Test Paper 1") {
+		t.Errorf("Expected a preview announcing the paper, got %d %q", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "SECRET") {
+		t.Error("Expected the webhook URL not to be shown in full")
+	}
+
+	w = test(url.Values{"channel": {"team"}, "send": {"1"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "dry run") {
+		t.Errorf("Expected the test recorded as a dry run, got %d %q", w.Code, w.Body.String())
+	}
+	if got := notifier.Recorded(); len(got) != 1 || got[0].Channel != "team" {
+		t.Errorf("Expected the test in the dry run's record, got %+v", got)
+	}
+
+	if w := test(url.Values{"channel": {"other"}}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown channel, got %d", w.Code)
+	}
+}
+
+func TestTrackUsage(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+	insertTestPapers(t, testDB, 1)
+
+	flags, err := features.New(map[string]bool{features.UsageStats: true}, testDB)
+	if err != nil {
+		t.Fatalf("features.New failed: %v", err)
+	}
+	handler.features = flags
+	handler.usage = usage.New(testDB)
+	s := &Server{config: handler.config, db: testDB, router: chi.NewRouter(), handler: handler}
+	s.setupMiddleware()
+	s.setupRoutes()
+
+	// The usage page's features must name real routes
+	routes := make(map[string]bool)
+	chi.Walk(s.router, func(method, route string, h http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		routes[method+" "+route] = true
+		return nil
+	})
+	for _, f := range usageFeatures {
+		for _, route := range f.Routes {
+			if !routes[route] {
+				t.Errorf("Feature %s counts %s, which isn't a route", f.Name, route)
+			}
+		}
+	}
+
+	get := func(path, client string, htmx bool) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(&http.Cookie{Name: clientCookie, Value: client})
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		s.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	alice, bob := strings.Repeat("a", 32), strings.Repeat("b", 32)
+	get("/library", alice, false)
+	get("/library", alice, false)
+	get("/library", bob, false)
+	get("/search?q=test", alice, true)
+	get("/static/css/app.css", alice, false)
+	get("/api/v1/papers", alice, false)
+
+	handler.templates = template.Must(template.New("test").Parse(`{{define "usage.html"}}{{range .FeatureUsage}}{{.Name}}={{.Uses}}/{{.Visitors}};{{end}}{{range .PageUsage}}[{{.Name}}: {{.Views}}]{{end}}{{end}}`))
+	w := httptest.NewRecorder()
+	handler.HandleUsage(w, httptest.NewRequest("GET", "/admin/usage", nil))
+	body := w.Body.String()
+	for _, want := range []string{"Library=3/2;", "Search=1/1;", "Tags=0/0;", "[GET /library: 3]"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q on the usage page, got %q", want, body)
+		}
+	}
+	if strings.Contains(body, "static") || strings.Contains(body, "api") || strings.Contains(body, "GET /search:") {
+		t.Errorf("Expected static files, the API and HTMX requests not counted as pages, got %q", body)
+	}
+
+	// Nothing is counted with the feature off
+	flags.Set(features.UsageStats, false)
+	get("/library", alice, false)
+	w = httptest.NewRecorder()
+	handler.HandleUsage(w, httptest.NewRequest("GET", "/admin/usage", nil))
+	if !strings.Contains(w.Body.String(), "Library=3/2;") {
+		t.Errorf("Expected no usage counted with the feature off, got %q", w.Body.String())
+	}
+}
+
+func TestHandlePrintExport(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	published := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < exportChunk+5; i++ {
+		paper := &models.Paper{
+			ID:          fmt.Sprintf("2401.%05d", i),
+			Title:       fmt.Sprintf("Paper %d", i),
+			PublishedAt: published.Add(time.Duration(i) * time.Hour),
+			UpdatedAt:   published,
+		}
+		if err := testDB.UpsertPaper(paper); err != nil {
+			t.Fatalf("Failed to insert paper: %v", err)
+		}
+	}
+	handler.templates = template.Must(template.New("test").Parse(`{{define "print.html"}}{{.Total}}:{{range .Chunks}}[{{range .}}{{.ID}} {{end}}]{{call $.Flush}}{{end}}{{if call .Failed}}failed{{end}}{{end}}`))
+
+	// Everything, in chunks, newest first
+	req := httptest.NewRequest("GET", "/export/print", nil)
+	w := httptest.NewRecorder()
+	handler.HandlePrintExport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !w.Flushed {
+		t.Error("Expected the page to be flushed while rendering")
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, fmt.Sprintf("%d:[2401.%05d ", exportChunk+5, exportChunk+4)) {
+		t.Errorf("Expected the newest paper first, got %.40q", body)
+	}
+	if got := strings.Count(body, "["); got != 2 {
+		t.Errorf("Expected 2 chunks, got %d", got)
+	}
+	if !strings.HasSuffix(body, "2401.00000 ]") {
+		t.Errorf("Expected the oldest paper last, got %q", body[len(body)-40:])
+	}
+
+	// Hand-picked papers keep their order
+	req = httptest.NewRequest("GET", "/export/print?ids=2401.00001&ids=2401.00003&ids=2401.00002", nil)
+	w = httptest.NewRecorder()
+	handler.HandlePrintExport(w, req)
+
+	if body := w.Body.String(); body != "3:[2401.00001 2401.00003 2401.00002 ]" {
+		t.Errorf("Unexpected print export %q", body)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+
+	// One iteration keeps the test fast; the format is the same
+	auth, err := NewAuth(config.AuthConfig{
+		Methods:        []string{AuthSession, AuthToken, AuthHeader},
+		SessionSecret:  "session secret",
+		SessionTTL:     time.Hour,
+		Users:          map[string]string{"alice": hashPassword("wonderland", []byte("salt"), 1)},
+		Tokens:         map[string]string{"ci": "ci-token"},
+		Header:         "X-Remote-User",
+		GroupsHeader:   "X-Remote-Groups",
+		TrustedProxies: []string{"10.0.0.1"},
+		Admins:         []string{"staff"},
+	})
+	if err != nil {
+		t.Fatalf("NewAuth failed: %v", err)
+	}
+	handler.auth = auth
+	s := &Server{config: handler.config, db: testDB, router: chi.NewRouter(), handler: handler}
+	s.setupMiddleware()
+	s.setupRoutes()
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Pages send strangers to the login page, the API answers 401
+	w := serve(httptest.NewRequest("GET", "/library", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login?next=%2Flibrary" {
+		t.Errorf("Expected a redirect to the login page, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	w = serve(httptest.NewRequest("GET", "/api/v1/papers", nil))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("Expected a JSON 401 from the API, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// API tokens
+	req := httptest.NewRequest("GET", "/api/v1/papers", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	if w := serve(req); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token refused, got %d", w.Code)
+	}
+	req.Header.Set("Authorization", "Bearer ci-token")
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Errorf("Expected a valid token accepted, got %d", w.Code)
+	}
+
+	// Sessions
+	form := url.Values{"user": {"alice"}, "password": {"nope"}, "next": {"/library"}}
+	req = httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := serve(req); w.Code != http.StatusUnauthorized || strings.Contains(strings.Join(w.Header().Values("Set-Cookie"), ";"), sessionCookie) {
+		t.Errorf("Expected a wrong password refused, got %d", w.Code)
+	}
+	form.Set("password", "wonderland")
+	req = httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/library" {
+		t.Fatalf("Expected a redirect back to the library, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("Expected a session cookie")
+	}
+
+	req = httptest.NewRequest("GET", "/account", nil)
+	req.AddCookie(session)
+	if w := serve(req); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "alice") || !strings.Contains(w.Body.String(), "/logout") {
+		t.Errorf("Expected alice signed in with a logout button, got %d: %s", w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest("GET", "/admin/usage", nil)
+	req.AddCookie(session)
+	if w := serve(req); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a non-admin kept out of admin pages, got %d", w.Code)
+	}
+
+	// A signed-in user's preferences follow the user rather than the browser
+	req = httptest.NewRequest("POST", "/preferences", strings.NewReader("page_size=50"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	if w := serve(req); w.Code != http.StatusNoContent || strings.Contains(strings.Join(w.Header().Values("Set-Cookie"), ";"), clientCookie) {
+		t.Errorf("Expected the preference saved without a client cookie, got %d", w.Code)
+	}
+	if prefs, _ := testDB.GetPreferences("user:alice"); prefs[prefPageSize] != "50" {
+		t.Errorf("Expected alice's page size kept under her user, got %v", prefs)
+	}
+
+	tampered := *session
+	tampered.Value = strings.Replace(session.Value, session.Value[:8], "Ym9iCg", 1)
+	req = httptest.NewRequest("GET", "/account", nil)
+	req.AddCookie(&tampered)
+	if w := serve(req); w.Code != http.StatusFound {
+		t.Errorf("Expected a tampered session refused, got %d", w.Code)
+	}
+
+	// SSO headers count only from the trusted proxy, whatever
+	// X-Forwarded-For claims
+	req = httptest.NewRequest("GET", "/account", nil)
+	req.Header.Set("X-Remote-User", "bob")
+	req.Header.Set("X-Remote-Groups", "staff, lab")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+	if w := serve(req); w.Code != http.StatusFound {
+		t.Errorf("Expected headers from an untrusted address ignored, got %d", w.Code)
+	}
+	req.RemoteAddr = "10.0.0.1:40000"
+	if w := serve(req); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "bob") || strings.Contains(w.Body.String(), "/logout") {
+		t.Errorf("Expected bob signed in by the proxy, got %d: %s", w.Code, w.Body.String())
+	}
+	req.URL.Path = "/admin/usage"
+	if w := serve(req); w.Code == http.StatusForbidden {
+		t.Error("Expected a member of an admin group let into admin pages")
+	}
+
+	// Assets and embeds need no session
+	if w := serve(httptest.NewRequest("GET", "/embed/tag/ml", nil)); w.Code == http.StatusFound {
+		t.Error("Expected embeds to skip authentication")
+	}
+
+	if _, err := NewAuth(config.AuthConfig{Methods: []string{AuthHeader}, Header: "X-Remote-User"}); err == nil {
+		t.Error("Expected header authentication without trusted proxies rejected")
+	}
+}
+
+func TestPaperMeta(t *testing.T) {
+	published := time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC)
+	paper := &models.Paper{
+		ID:          "2401.01234",
+		Title:       "Breaking </script> tags",
+		Abstract:    "We show that escaping titles in structured metadata matters a great deal. Everything else follows.",
+		Authors:     "Jane Doe, John Smith",
+		Categories:  "cs.LG, stat.ML",
+		PublishedAt: published,
+		UpdatedAt:   published.AddDate(0, 0, 3),
+		PDFUrl:      "https://arxiv.org/pdf/2401.01234",
+		ArxivUrl:    "https://arxiv.org/abs/2401.01234",
+		DOI:         "10.1000/xyz",
+		JournalRef:  "J. Testing 1 (2024)",
+	}
+	r := httptest.NewRequest("GET", "/paper/2401.01234", nil)
+	r.Host = "nest.example.org"
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	meta := paperMeta(r, paper)
+	if meta.URL != "https://nest.example.org/paper/2401.01234" {
+		t.Errorf("Expected an absolute page URL, got %q", meta.URL)
+	}
+	if meta.Description != "We show that escaping titles in structured metadata matters a great deal." {
+		t.Errorf("Expected the lead sentence as description, got %q", meta.Description)
+	}
+	if len(meta.Authors) != 2 || meta.Published != "2024-01-15" || meta.Modified != "2024-01-18" || meta.ArxivID != paper.ID {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if rxiv := paperMeta(r, &models.Paper{ID: "biorxiv:2024.01.15.575123"}); rxiv.ArxivID != "" || rxiv.JSONLD.Publisher != nil {
+		t.Error("Expected no arXiv identifier for a bioRxiv paper")
+	}
+
+	// The JSON-LD must survive the page's escaping as valid JSON
+	tmpl := template.Must(template.New("head").Parse(`<script type="application/ld+json">{{.JSONLD}}</script>`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, meta); err != nil {
+		t.Fatalf("Failed to render JSON-LD: %v", err)
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(b.String(), `<script type="application/ld+json">`), `</script>`)
+	if strings.Contains(body, "</script>") {
+		t.Fatal("Expected the title not to close the script")
+	}
+	var ld map[string]any
+	if err := json.Unmarshal([]byte(body), &ld); err != nil {
+		t.Fatalf("Expected valid JSON-LD, got %v: %s", err, body)
+	}
+	if ld["@type"] != "ScholarlyArticle" || ld["headline"] != paper.Title || ld["datePublished"] != "2024-01-15T18:00:00Z" {
+		t.Errorf("Unexpected JSON-LD: %s", body)
+	}
+	if ids, _ := ld["identifier"].([]any); len(ids) != 2 {
+		t.Errorf("Expected arXiv and DOI identifiers, got %v", ld["identifier"])
+	}
+	if authors, _ := ld["author"].([]any); len(authors) != 2 {
+		t.Errorf("Expected two authors, got %v", ld["author"])
+	}
+}
+
+func TestGroupByAge(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2024, 3, 15, 9, 0, 0, 0, loc)
+	papers := []models.Paper{
+		{ID: "today", PublishedAt: time.Date(2024, 3, 14, 23, 30, 0, 0, time.UTC)}, // 01:30 local
+		{ID: "yesterday", PublishedAt: time.Date(2024, 3, 14, 12, 0, 0, 0, loc)},
+		{ID: "sixdays", PublishedAt: time.Date(2024, 3, 9, 0, 0, 0, 0, loc)},
+		{ID: "earlier", PublishedAt: time.Date(2024, 3, 8, 23, 59, 0, 0, loc)},
+		{ID: "older", PublishedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, loc)},
+	}
+
+	groups := groupByAge(papers, now)
+	want := []struct {
+		label string
+		ids   []string
+	}{
+		{ageToday, []string{"today"}},
+		{ageThisWeek, []string{"yesterday", "sixdays"}},
+		{ageEarlier, []string{"earlier", "older"}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("Expected %d groups, got %+v", len(want), groups)
+	}
+	for i, g := range groups {
+		var ids []string
+		for _, p := range g.Papers {
+			ids = append(ids, p.ID)
+		}
+		if g.Label != want[i].label || strings.Join(ids, ",") != strings.Join(want[i].ids, ",") {
+			t.Errorf("Group %d: expected %s %v, got %s %v", i, want[i].label, want[i].ids, g.Label, ids)
+		}
+	}
+
+	// Groups without papers are left out
+	if groups := groupByAge(papers[3:], now); len(groups) != 1 || groups[0].Label != ageEarlier {
+		t.Errorf("Expected only earlier papers, got %+v", groups)
+	}
+	if groups := groupByAge(nil, now); len(groups) != 0 {
+		t.Errorf("Expected no groups for no papers, got %+v", groups)
+	}
+}
+
+func TestHandleSave(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+	insertTestPapers(t, testDB, 2)
+	if _, err := testDB.ForClient("someone-else").CreatePersonalTag("theirs"); err != nil {
+		t.Fatalf("CreatePersonalTag failed: %v", err)
+	}
+
+	save := func(id, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/library/save/"+id, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.HandleSave(w, req)
+		return w
+	}
+
+	w := save("1", "note=+for+the+survey+&priority=2&tag=methods&tags=survey,+methods,+")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("HX-Trigger"), "closeModal") || !strings.Contains(w.Body.String(), "/library/remove/1") {
+		t.Errorf("Expected the saved button and the dialog closed, got %q: %s", w.Header().Get("HX-Trigger"), w.Body.String())
+	}
+	paper, _ := testDB.GetPaperByID("1")
+	if !paper.InLibrary || paper.Note != "for the survey" || paper.Priority != models.PriorityMedium {
+		t.Errorf("Expected the paper saved with its note and priority, got %+v", paper)
+	}
+	var tags []string
+	for _, tag := range paper.Tags {
+		tags = append(tags, tag.Name)
+	}
+	if strings.Join(tags, ",") != "methods,survey" {
+		t.Errorf("Expected tags methods and survey, got %v", tags)
+	}
+
+	// Saving again without a note keeps the first one
+	if w := save("1", "tags=more"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if paper, _ := testDB.GetPaperByID("1"); paper.Note != "for the survey" || len(paper.Tags) != 3 {
+		t.Errorf("Expected the note kept and a tag added, got %q and %d tags", paper.Note, len(paper.Tags))
+	}
+
+	// Another browser's personal tag doesn't take the name from anyone
+	w = save("2", "note=half+done&tags=fresh,theirs")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	paper, _ = testDB.GetPaperByID("2")
+	if !paper.InLibrary || len(paper.Tags) != 2 {
+		t.Errorf("Expected the paper saved with both tags, got in library %v with tags %v", paper.InLibrary, paper.Tags)
+	}
+	if tag, err := testDB.ForClient("someone-else").GetTag("theirs"); err != nil || tag.Owner != "someone-else" || tag.PaperCount != 0 {
+		t.Errorf("Expected the personal tag left alone, got %+v, %v", tag, err)
+	}
+
+	if w := save("missing", "note=x"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing paper, got %d", w.Code)
+	}
+	if w := save("2", "priority=9"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a bad priority, got %d", w.Code)
+	}
+}
+
+func TestTaskEvents(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+	handler.tasks = tasks.New()
+	r := chi.NewRouter()
+	r.Get("/tasks/{id}/events", handler.HandleTaskEvents)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	step := make(chan struct{})
+	id, err := handler.tasks.Start("Import", func(ctx context.Context, p *tasks.Progress) (string, error) {
+		p.Update(2, 5, "2401.00003, 2401.00004")
+		<-step
+		return "Imported 5 papers", nil
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/tasks/" + id + "/events")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", ct)
+	}
+
+	// The progress bar comes first, then the finished row ends the stream
+	body := bufio.NewReader(resp.Body)
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended before the progress: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") && strings.Contains(line, "40% · 2 of 5") {
+			if !strings.Contains(line, "2401.00003, 2401.00004") || !strings.Contains(line, `hx-post="/tasks/`+id+`/cancel"`) {
+				t.Errorf("Expected the current references and a cancel button, got %s", line)
+			}
+			break
+		}
+	}
+	close(step)
+	rest, _ := io.ReadAll(body)
+	if !strings.Contains(string(rest), "event: done\ndata: <div id=\"task-"+id+"\"") || !strings.Contains(string(rest), "✓ Imported 5 papers") {
+		t.Errorf("Expected the finished row, got %s", rest)
+	}
+
+	resp, err = http.Get(srv.URL + "/tasks/99/events")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown task, got %d", resp.StatusCode)
+	}
+}
+
+func TestTagDetailNames(t *testing.T) {
+	handler, testDB := setupTestHandler(t)
+	defer testDB.Close()
+	handler.templates = template.Must(template.New("test").Parse(`{{define "tag.html"}}{{.Tag.Name}}{{end}}`))
+
+	router := chi.NewRouter()
+	router.Get("/tags/{name}", handler.HandleTagDetail)
+	for _, name := range []string{"100%", "a/b", "lab reading"} {
+		testDB.CreateTag(name)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/tags/"+url.PathEscape(name), nil))
+		if w.Code != http.StatusOK || w.Body.String() != name {
+			t.Errorf("Expected tag %q, got %d %q", name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log"
+	"net/http"
+)
+
+// HandleLinks renders the report of paper links the link checker found
+// broken and couldn't repair
+func (h *Handler) HandleLinks(w http.ResponseWriter, r *http.Request) {
+	data := PageData{
+		Title:    "Dead Links",
+		Features: h.features.Map(),
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.BrokenLinks, err = h.db.GetBrokenLinks()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch broken links", err)
+		log.Printf("Error fetching broken links: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "links.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
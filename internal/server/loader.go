@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// loader runs the independent queries of a page concurrently. Queries
+// started with Go are optional: a failure is logged and leaves the result
+// at its zero value, so the page still renders without, say, its tag list.
+// Queries started with Require are the page's content; Wait returns the
+// first of their errors.
+type loader struct {
+	wg sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// Go runs an optional query; what names it in the log
+func (l *loader) Go(what string, fn func() error) {
+	l.run(what, fn, false)
+}
+
+// Require runs a query the page can't be rendered without
+func (l *loader) Require(fn func() error) {
+	l.run("", fn, true)
+}
+
+// Wait waits for every query and returns the first required one's error
+func (l *loader) Wait() error {
+	l.wg.Wait()
+	return l.err
+}
+
+// run runs fn in its own goroutine, turning a panic into an error since
+// the router's recoverer only covers the request's goroutine
+func (l *loader) run(what string, fn func() error, required bool) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		err := func() (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("panic: %v", p)
+				}
+			}()
+			return fn()
+		}()
+		if err == nil {
+			return
+		}
+
+		if !required {
+			log.Printf("Error fetching %s: %v", what, err)
+			return
+		}
+		l.mu.Lock()
+		if l.err == nil {
+			l.err = err
+		}
+		l.mu.Unlock()
+	}()
+}
+
+// loadCounts loads the paper and library counts shown in the navigation
+func (h *Handler) loadCounts(l *loader, data *PageData) {
+	l.Go("paper count", func() (err error) {
+		data.PaperCount, err = h.db.GetPaperCount()
+		return err
+	})
+	l.Go("library count", func() (err error) {
+		data.LibraryCount, err = h.db.GetLibraryCount()
+		return err
+	})
+}
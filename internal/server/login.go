@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// LoginData is passed to the login.html template
+type LoginData struct {
+	// Next is the page to go to after signing in
+	Next  string
+	User  string
+	Error string
+}
+
+// HandleLoginPage renders the login form of session authentication
+func (h *Handler) HandleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if h.auth.Session() == nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.renderLogin(w, http.StatusOK, LoginData{Next: localPath(r.URL.Query().Get("next"))})
+}
+
+// HandleLogin signs a user in with the "user" and "password" form values
+// and sends them on to the local page in "next"
+func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	session := h.auth.Session()
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user := strings.TrimSpace(r.PostForm.Get("user"))
+	next := localPath(r.PostForm.Get("next"))
+	if err := session.Login(w, r, user, r.PostForm.Get("password")); err != nil {
+		log.Printf("Failed login for %q from %s", user, r.RemoteAddr)
+		h.renderLogin(w, http.StatusUnauthorized, LoginData{Next: next, User: user, Error: "Wrong user name or password"})
+		return
+	}
+
+	if next == "" {
+		next = "/"
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// HandleLogout ends the session and returns to the login page
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	session := h.auth.Session()
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+	session.Logout(w)
+	http.Redirect(w, r, loginPath, http.StatusSeeOther)
+}
+
+// HandleAccount returns the signed-in user for the navigation bar, with a
+// logout button for sessions (HTMX endpoint). Without authentication it
+// returns nothing.
+func (h *Handler) HandleAccount(w http.ResponseWriter, r *http.Request) {
+	id := identity(r)
+	if id == nil {
+		return
+	}
+
+	fmt.Fprintf(w, `<div class="flex items-center gap-2 text-sm text-gray-500 dark:text-gray-400"><span title="Signed in">%s</span>`, template.HTMLEscapeString(id.User))
+	if id.Method == AuthSession {
+		fmt.Fprint(w, `<form method="post" action="/logout"><button type="submit" class="btn btn-sm btn-outline">Log out</button></form>`)
+	}
+	fmt.Fprint(w, `</div>`)
+}
+
+// renderLogin renders the login form with the given status
+func (h *Handler) renderLogin(w http.ResponseWriter, status int, data LoginData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.templates.ExecuteTemplate(w, "login.html", data); err != nil {
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// localPath returns path if it is a page on this site, "" otherwise, so
+// the login form can't be used to send users elsewhere
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return ""
+	}
+	return path
+}
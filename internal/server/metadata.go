@@ -0,0 +1,132 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/notify"
+)
+
+// PaperMeta is what a paper's detail page tells crawlers and link
+// unfurlers about the paper in its head: OpenGraph and Highwire
+// ("citation_") tags, and schema.org JSON-LD
+type PaperMeta struct {
+	URL         string
+	Description string
+	Authors     []string
+	Categories  []string
+	Published   string
+	Modified    string
+	ArxivID     string
+	JSONLD      ScholarlyArticle
+}
+
+// ScholarlyArticle is a paper as a schema.org ScholarlyArticle
+type ScholarlyArticle struct {
+	Context       string        `json:"@context"`
+	Type          string        `json:"@type"`
+	ID            string        `json:"@id"`
+	URL           string        `json:"url"`
+	Headline      string        `json:"headline"`
+	Name          string        `json:"name"`
+	Abstract      string        `json:"abstract,omitempty"`
+	Author        []schemaThing `json:"author,omitempty"`
+	DatePublished string        `json:"datePublished"`
+	DateModified  string        `json:"dateModified,omitempty"`
+	Keywords      string        `json:"keywords,omitempty"`
+	License       string        `json:"license,omitempty"`
+	SameAs        []string      `json:"sameAs,omitempty"`
+	Identifier    []schemaValue `json:"identifier,omitempty"`
+	Encoding      []schemaMedia `json:"encoding,omitempty"`
+	IsPartOf      *schemaThing  `json:"isPartOf,omitempty"`
+	Publisher     *schemaThing  `json:"publisher,omitempty"`
+}
+
+// schemaThing is a schema.org item known only by its type and name
+type schemaThing struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// schemaValue is a schema.org PropertyValue, used for identifiers
+type schemaValue struct {
+	Type       string `json:"@type"`
+	PropertyID string `json:"propertyID"`
+	Value      string `json:"value"`
+}
+
+// schemaMedia is a schema.org MediaObject, used for the PDF
+type schemaMedia struct {
+	Type           string `json:"@type"`
+	EncodingFormat string `json:"encodingFormat"`
+	ContentURL     string `json:"contentUrl"`
+}
+
+// paperMeta builds the metadata of a paper's detail page from the stored
+// paper. Links are absolute, so they work wherever the page is unfurled.
+func paperMeta(r *http.Request, p *models.Paper) *PaperMeta {
+	pageURL := baseURL(r) + "/paper/" + p.ID
+	m := &PaperMeta{
+		URL:         pageURL,
+		Description: notify.Excerpt(p.Abstract),
+		Published:   p.PublishedAt.UTC().Format(time.DateOnly),
+	}
+	if !p.UpdatedAt.IsZero() && !p.UpdatedAt.Equal(p.PublishedAt) {
+		m.Modified = p.UpdatedAt.UTC().Format(time.DateOnly)
+	}
+	for _, name := range strings.Split(p.Authors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+	for _, category := range strings.Split(p.Categories, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			m.Categories = append(m.Categories, category)
+		}
+	}
+	// Papers imported from bioRxiv and medRxiv have IDs such as
+	// "biorxiv:2024.01.15.575123"
+	if !strings.Contains(p.ID, ":") {
+		m.ArxivID = p.ID
+	}
+
+	a := ScholarlyArticle{
+		Context:       "https://schema.org",
+		Type:          "ScholarlyArticle",
+		ID:            pageURL,
+		URL:           pageURL,
+		Headline:      p.Title,
+		Name:          p.Title,
+		Abstract:      p.Abstract,
+		DatePublished: p.PublishedAt.UTC().Format(time.RFC3339),
+		Keywords:      strings.Join(m.Categories, ", "),
+		License:       p.License,
+	}
+	if m.Modified != "" {
+		a.DateModified = p.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, name := range m.Authors {
+		a.Author = append(a.Author, schemaThing{Type: "Person", Name: name})
+	}
+	if p.ArxivUrl != "" {
+		a.SameAs = append(a.SameAs, p.ArxivUrl)
+	}
+	if m.ArxivID != "" {
+		a.Identifier = append(a.Identifier, schemaValue{Type: "PropertyValue", PropertyID: "arXiv", Value: m.ArxivID})
+		a.Publisher = &schemaThing{Type: "Organization", Name: "arXiv"}
+	}
+	if p.DOI != "" {
+		a.Identifier = append(a.Identifier, schemaValue{Type: "PropertyValue", PropertyID: "DOI", Value: p.DOI})
+		a.SameAs = append(a.SameAs, "https://doi.org/"+p.DOI)
+	}
+	if p.PDFUrl != "" {
+		a.Encoding = append(a.Encoding, schemaMedia{Type: "MediaObject", EncodingFormat: "application/pdf", ContentURL: p.PDFUrl})
+	}
+	if p.JournalRef != "" {
+		a.IsPartOf = &schemaThing{Type: "Periodical", Name: p.JournalRef}
+	}
+	m.JSONLD = a
+	return m
+}
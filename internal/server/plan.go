@@ -0,0 +1,218 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/export"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// planQueueSize is how many unplanned library papers the calendar offers
+const planQueueSize = 50
+
+// icalPastDays is how far back the iCal feed includes planned reads; the
+// feed covers everything planned after that
+const icalPastDays = 90
+
+// CalendarDay is one cell of the reading plan calendar
+type CalendarDay struct {
+	Date    time.Time
+	InMonth bool
+	Today   bool
+	Reads   []models.PlannedRead
+}
+
+// buildCalendar lays out the weeks (Monday to Sunday) covering month, with
+// each day's planned reads. Dates are midnight UTC, as stored.
+func buildCalendar(month time.Time, plan []models.PlannedRead, today time.Time) [][]CalendarDay {
+	byDay := make(map[string][]models.PlannedRead)
+	for _, p := range plan {
+		key := p.PlannedFor.Format("2006-01-02")
+		byDay[key] = append(byDay[key], p)
+	}
+
+	first, last := monthBounds(month)
+	start := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+
+	var weeks [][]CalendarDay
+	for day := start; !day.After(last); {
+		week := make([]CalendarDay, 7)
+		for i := range week {
+			week[i] = CalendarDay{
+				Date:    day,
+				InMonth: day.Month() == first.Month(),
+				Today:   day.Equal(today),
+				Reads:   byDay[day.Format("2006-01-02")],
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
+
+// monthBounds returns the first and last day of the month containing t
+func monthBounds(t time.Time) (time.Time, time.Time) {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return first, first.AddDate(0, 1, -1)
+}
+
+// today returns the current local date as midnight UTC, matching how
+// dates come back from the database
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// HandlePlan renders the reading plan calendar for ?month=2006-01 (the
+// current month by default) next to the queue of unplanned papers
+func (h *Handler) HandlePlan(w http.ResponseWriter, r *http.Request) {
+	now := today()
+	month := now
+	if m, err := time.Parse("2006-01", r.URL.Query().Get("month")); err == nil {
+		month = m
+	}
+
+	weeks := buildCalendar(month, nil, now)
+	first, last := weeks[0][0].Date, weeks[len(weeks)-1][6].Date
+	plan, err := h.db.GetReadingPlan(first, last)
+	if err != nil {
+		serverError(w, "Failed to fetch reading plan", err)
+		log.Printf("Error fetching reading plan: %v", err)
+		return
+	}
+
+	queue, err := h.db.GetUnplannedQueue(planQueueSize)
+	if err != nil {
+		log.Printf("Error fetching reading queue: %v", err)
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:        "Reading Plan",
+		PaperCount:   paperCount,
+		LibraryCount: libraryCount,
+		Features:     h.features.Map(),
+		Calendar:     buildCalendar(month, plan, now),
+		Month:        time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC),
+		Queue:        queue,
+		Today:        now,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "plan.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandlePlanPaper schedules a library paper for the posted day, or takes
+// it off the plan when day is empty (HTMX endpoint). Pages showing the
+// plan reload it on the planUpdated event.
+func (h *Handler) HandlePlanPaper(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	value := strings.TrimSpace(r.FormValue("day"))
+	if value == "" {
+		if err := h.db.UnplanRead(id); err != nil {
+			serverError(w, "Failed to update reading plan", err)
+			log.Printf("Error unplanning paper %s: %v", id, err)
+			return
+		}
+		w.Header().Set("HX-Trigger", `{"planUpdated": true, "showToast": {"message": "Removed from reading plan", "type": "info"}}`)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		http.Error(w, "Invalid day", http.StatusBadRequest)
+		return
+	}
+
+	err = h.db.PlanRead(id, day)
+	if errors.Is(err, db.ErrNotInLibrary) {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "Save the paper to your library first", "type": "error"}}`)
+		http.Error(w, "Paper is not in the library", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to update reading plan", err)
+		log.Printf("Error planning paper %s: %v", id, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"planUpdated": true, "showToast": {"message": "Planned for %s", "type": "success"}}`, day.Format("Mon, Jan 2")))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePlanICal serves the reading plan as an iCalendar feed to subscribe
+// to from a calendar app
+func (h *Handler) HandlePlanICal(w http.ResponseWriter, r *http.Request) {
+	now := today()
+	plan, err := h.db.GetReadingPlan(now.AddDate(0, 0, -icalPastDays), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		serverError(w, "Failed to fetch reading plan", err)
+		log.Printf("Error fetching reading plan: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="reading-plan.ics"`)
+	if err := export.PlanICal(w, plan, time.Now()); err != nil {
+		log.Printf("Error writing iCal feed: %v", err)
+	}
+}
+
+// HandleVenuesICal serves the upcoming deadlines and dates of conferences
+// covering the subscribed categories, or mentioned by stored papers, as an
+// iCalendar feed
+func (h *Handler) HandleVenuesICal(w http.ResponseWriter, r *http.Request) {
+	catalog := h.fetcher.Venues()
+	if catalog == nil {
+		http.Error(w, "No venue catalog loaded", http.StatusNotFound)
+		return
+	}
+
+	mentioned, err := h.db.GetMentionedVenues()
+	if err != nil {
+		serverError(w, "Failed to fetch venues", err)
+		log.Printf("Error fetching mentioned venues: %v", err)
+		return
+	}
+
+	var events []export.CalendarEvent
+	for _, e := range catalog.Upcoming(h.fetcher.Categories(), mentioned, today()) {
+		var details []string
+		for _, d := range []string{e.FullName, e.Location} {
+			if d != "" {
+				details = append(details, d)
+			}
+		}
+		events = append(events, export.CalendarEvent{
+			UID:         fmt.Sprintf("venue-%s-%d-%s@arxiv-nest", strings.ToLower(e.Venue), e.Year, strings.ReplaceAll(e.Kind, " ", "-")),
+			Summary:     e.Title(),
+			Description: strings.Join(details, ", "),
+			Start:       e.Start,
+			End:         e.End,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="conference-dates.ics"`)
+	if err := export.ICal(w, "Conference dates", events, time.Now()); err != nil {
+		log.Printf("Error writing iCal feed: %v", err)
+	}
+}
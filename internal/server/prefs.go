@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientCookie identifies a browser so its UI preferences can be kept on
+// the server across sessions
+const clientCookie = "nest_client"
+
+// clientCookieMaxAge is how long the client cookie lasts
+const clientCookieMaxAge = 365 * 24 * time.Hour
+
+// Preference keys stored in the preferences table. Last filters are kept
+// per list page under filterPrefix + the page name.
+const (
+	prefPageSize         = "page_size"
+	prefFiltersCollapsed = "filters_collapsed"
+	filterPrefix         = "filter:"
+)
+
+// pageSizes are the page sizes offered on the list pages
+var pageSizes = []int{10, 20, 50, 100}
+
+// Prefs are one browser's UI preferences
+type Prefs struct {
+	// PageSize is the number of papers per page, 0 for ui.page_size
+	PageSize int
+	// FiltersCollapsed hides the search and filter panel
+	FiltersCollapsed bool
+	// LastFilter is the last filter query string used on each list page
+	LastFilter map[string]string
+}
+
+type clientIDKey struct{}
+
+// userClientPrefix starts the client ID of a signed-in user
+const userClientPrefix = "user:"
+
+// clientMiddleware makes the ID that preferences and personal tags are
+// kept under available to handlers via clientID: the signed-in user's
+// when authentication is on, so they follow the user across browsers, or
+// else the browser's client cookie, which it sets if missing
+func clientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := identity(r); user != nil && user.User != "" {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIDKey{}, userClientPrefix+user.User)))
+			return
+		}
+
+		id := ""
+		if c, err := r.Cookie(clientCookie); err == nil && len(c.Value) == 32 {
+			id = c.Value
+		} else {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id = hex.EncodeToString(b)
+			http.SetCookie(w, &http.Cookie{
+				Name:     clientCookie,
+				Value:    id,
+				Path:     "/",
+				MaxAge:   int(clientCookieMaxAge.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIDKey{}, id)))
+	})
+}
+
+// clientID returns the requesting user's or browser's ID, or "" if it has
+// none (e.g. in tests that call handlers directly)
+func clientID(r *http.Request) string {
+	id, _ := r.Context().Value(clientIDKey{}).(string)
+	return id
+}
+
+// loadPrefs reads the requesting browser's preferences. Errors are logged
+// and yield the defaults, since preferences are a convenience.
+func (h *Handler) loadPrefs(r *http.Request) Prefs {
+	prefs := Prefs{LastFilter: map[string]string{}}
+	id := clientID(r)
+	if id == "" {
+		return prefs
+	}
+
+	stored, err := h.db.GetPreferences(id)
+	if err != nil {
+		log.Printf("Error loading preferences: %v", err)
+		return prefs
+	}
+	for key, value := range stored {
+		switch {
+		case key == prefPageSize:
+			prefs.PageSize, _ = strconv.Atoi(value)
+		case key == prefFiltersCollapsed:
+			prefs.FiltersCollapsed = parseBool(value, false)
+		case strings.HasPrefix(key, filterPrefix):
+			prefs.LastFilter[strings.TrimPrefix(key, filterPrefix)] = value
+		}
+	}
+	return prefs
+}
+
+// pageSize returns the page size to use for the browser's list pages
+func (h *Handler) pageSize(prefs Prefs) int {
+	if prefs.PageSize > 0 {
+		return prefs.PageSize
+	}
+	return h.config.UI.PageSize
+}
+
+// restoreFilter remembers the filter a list page was opened with, or, when
+// the page is opened bare, redirects to the last filter used on it.
+// "?reset=1" clears the remembered filter. It reports whether it redirected.
+func (h *Handler) restoreFilter(w http.ResponseWriter, r *http.Request, page string, prefs Prefs) bool {
+	id := clientID(r)
+	if id == "" || r.Header.Get("HX-Request") != "" {
+		return false
+	}
+
+	query := r.URL.Query()
+	if len(query) == 0 {
+		if last := prefs.LastFilter[page]; last != "" {
+			http.Redirect(w, r, r.URL.Path+"?"+last, http.StatusFound)
+			return true
+		}
+		return false
+	}
+
+	// Pagination is not part of the filter
+	query.Del("page")
+	filter := ""
+	if query.Get("reset") == "" {
+		filter = query.Encode()
+	}
+	if filter != prefs.LastFilter[page] {
+		if err := h.db.SetPreference(id, filterPrefix+page, filter); err != nil {
+			log.Printf("Error saving filter preference: %v", err)
+		}
+	}
+	return false
+}
+
+// HandleSetPreferences stores UI preferences posted by the list pages,
+// e.g. page_size=50 or filters_collapsed=true (HTMX endpoint)
+func (h *Handler) HandleSetPreferences(w http.ResponseWriter, r *http.Request) {
+	id := clientID(r)
+	if id == "" {
+		http.Error(w, "Missing client cookie", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	updates := url.Values{}
+	if value := r.PostForm.Get(prefPageSize); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || !validPageSize(size) {
+			http.Error(w, "Invalid page size", http.StatusBadRequest)
+			return
+		}
+		updates.Set(prefPageSize, value)
+	}
+	if value := r.PostForm.Get(prefFiltersCollapsed); value != "" {
+		updates.Set(prefFiltersCollapsed, strconv.FormatBool(parseBool(value, false)))
+	}
+	if len(updates) == 0 {
+		http.Error(w, "No known preference given", http.StatusBadRequest)
+		return
+	}
+
+	for key := range updates {
+		if err := h.db.SetPreference(id, key, updates.Get(key)); err != nil {
+			serverError(w, "Failed to save preference", err)
+			log.Printf("Error saving preference %s: %v", key, err)
+			return
+		}
+	}
+
+	// A new page size changes the current page's contents
+	if updates.Has(prefPageSize) {
+		w.Header().Set("HX-Refresh", "true")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validPageSize reports whether size is one of the offered page sizes
+func validPageSize(size int) bool {
+	for _, s := range pageSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
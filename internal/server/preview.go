@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// previewDirs are watched for changes in preview mode
+var previewDirs = []string{filepath.Join("web", "templates"), filepath.Join("web", "static")}
+
+// previewScript reloads the page when the preview version changes
+const previewScript = `<script>
+(function poll(version) {
+    fetch('/preview/version').then(r => r.text()).then(current => {
+        if (current !== version) { location.reload(); } else { setTimeout(() => poll(version), 1000); }
+    }).catch(() => setTimeout(() => poll(version), 1000));
+})(%q);
+</script>
+`
+
+// EnablePreview puts the server in preview mode for working on the
+// templates: pages are rendered from the templates as they are on disk
+// when requested, static files aren't cached, and open pages reload when
+// a template or static file changes. Call it before serving requests.
+func (s *Server) EnablePreview() {
+	s.preview = true
+	s.handler.templates = &previewRenderer{}
+	s.router.Get("/preview/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, previewVersion())
+	})
+}
+
+// previewVersion fingerprints the watched files by name, size and
+// modification time, so any edit, new file or deletion changes it
+func previewVersion() string {
+	var sum uint64
+	for _, dir := range previewDirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			h := uint64(info.ModTime().UnixNano()) ^ uint64(info.Size())<<32
+			for _, c := range path {
+				h = h*31 + uint64(c)
+			}
+			sum += h
+			return nil
+		})
+	}
+	return strconv.FormatUint(sum, 36)
+}
+
+// previewRenderer parses the templates again whenever the watched files
+// change. A parse error is rendered in place of the page, and full pages
+// get the live reload script.
+type previewRenderer struct {
+	mu        sync.Mutex
+	version   string
+	templates *Templates
+	err       error
+}
+
+// ExecuteTemplate renders a page from the current templates
+func (p *previewRenderer) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	version := previewVersion()
+
+	p.mu.Lock()
+	if version != p.version || p.templates == nil && p.err == nil {
+		p.templates, p.err = NewTemplates()
+		p.version = version
+	}
+	templates, err := p.templates, p.err
+	p.mu.Unlock()
+
+	script := []byte(fmt.Sprintf(previewScript, version))
+	if err != nil {
+		fmt.Fprintf(w, "<!DOCTYPE html><title>Template error</title><pre>%s</pre>%s",
+			template.HTMLEscapeString(err.Error()), script)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	page := buf.Bytes()
+	if i := bytes.LastIndex(page, []byte("</body>")); i >= 0 {
+		page = append(page[:i:i], append(script, page[i:]...)...)
+	}
+	_, err = w.Write(page)
+	return err
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/publish"
+)
+
+const (
+	// reviewPageSize is how many queued papers the review page shows
+	reviewPageSize = 50
+
+	// teamFeedSize is how many approved papers the team feed shows
+	teamFeedSize = 100
+)
+
+// reviewDecisions maps the decisions posted to /review to review states
+var reviewDecisions = map[string]string{
+	"approve": models.ReviewApproved,
+	"reject":  models.ReviewRejected,
+}
+
+// curator returns the signed-in user if they are one of the configured
+// curators, or "". Without authentication nobody is a curator.
+func (h *Handler) curator(r *http.Request) string {
+	user := identity(r)
+	if user == nil || !h.config.Curation.IsCurator(user.User) {
+		return ""
+	}
+	return user.User
+}
+
+// HandleReview shows the papers waiting for a curator, first queued first,
+// with each curator's decisions so far
+func (h *Handler) HandleReview(w http.ResponseWriter, r *http.Request) {
+	data := PageData{
+		Title:    "Review Queue",
+		Features: h.features.Map(),
+		Curators: h.config.Curation.Curators,
+		Curator:  h.curator(r),
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.Papers, data.TotalResults, err = h.db.GetReviewQueue(reviewPageSize)
+		return err
+	})
+	l.Require(func() (err error) {
+		data.CuratorStats, err = h.db.GetCuratorStats()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch the review queue", err)
+		log.Printf("Error fetching the review queue: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "review.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleReviewPapers records the signed-in curator approving or rejecting
+// the papers in "ids" (HTMX endpoint). A single paper's buttons swap its
+// card out with the empty response; the bulk buttons send "bulk" and get
+// the page refreshed.
+func (h *Handler) HandleReviewPapers(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	status, ok := reviewDecisions[r.FormValue("decision")]
+	if !ok {
+		http.Error(w, "Invalid decision", http.StatusBadRequest)
+		return
+	}
+	curator := h.curator(r)
+	if curator == "" {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "Sign in as a curator to review papers", "type": "error"}}`)
+		http.Error(w, "Only curators can review papers", http.StatusForbidden)
+		return
+	}
+	ids := r.Form["ids"]
+	if len(ids) == 0 {
+		http.Error(w, "No papers selected", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.db.ReviewPapers(ids, status, curator, time.Now())
+	if err != nil {
+		serverError(w, "Failed to record review", err)
+		log.Printf("Error reviewing papers: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": "Marked %d papers as %s", "type": "success"}}`, count, status))
+	if r.FormValue("bulk") != "" {
+		w.Header().Set("HX-Refresh", "true")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleTeamFeed lists the papers curators approved, most recent first
+func (h *Handler) HandleTeamFeed(w http.ResponseWriter, r *http.Request) {
+	data := PageData{
+		Title:    "Team Feed",
+		Features: h.features.Map(),
+		Curator:  h.curator(r),
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.TeamFeed, err = h.db.GetTeamFeed(teamFeedSize)
+		return err
+	})
+	l.Require(func() (err error) {
+		data.TotalResults, err = h.db.GetPendingReviewCount()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch the team feed", err)
+		log.Printf("Error fetching the team feed: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "team.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleTeamFeedAtom serves the team feed as Atom, for feed readers and
+// digest mailers
+func (h *Handler) HandleTeamFeedAtom(w http.ResponseWriter, r *http.Request) {
+	approved, err := h.db.GetTeamFeed(teamFeedSize)
+	if err != nil {
+		serverError(w, "Failed to fetch the team feed", err)
+		log.Printf("Error fetching the team feed: %v", err)
+		return
+	}
+
+	papers := make([]models.Paper, len(approved))
+	for i, p := range approved {
+		papers[i] = p.Paper
+	}
+	feed, err := publish.AtomFeed(papers, publish.Options{Title: "Team Feed"}, time.Now())
+	if err != nil {
+		serverError(w, "Failed to render the feed", err)
+		log.Printf("Error rendering the team feed: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write(feed)
+}
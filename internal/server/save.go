@@ -0,0 +1,131 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// SaveDialogData is the data of the dialog saving a paper with a note,
+// priority and tags
+type SaveDialogData struct {
+	Paper  *models.Paper
+	Prompt string
+	// Tags are the tags the paper doesn't have yet
+	Tags []models.Tag
+}
+
+// HandleSaveDialog renders the dialog saving a paper to the library with a
+// note, priority and tags in one go (HTMX endpoint). Save buttons open it
+// while ui.prompt_save_note is on.
+func (h *Handler) HandleSaveDialog(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	paper, err := h.db.GetPaperByID(id)
+	if err != nil {
+		http.Error(w, "Paper not found", http.StatusNotFound)
+		log.Printf("Error fetching paper %s: %v", id, err)
+		return
+	}
+	all, err := h.db.GetAllTags()
+	if err != nil {
+		serverError(w, "Failed to fetch tags", err)
+		log.Printf("Error fetching tags: %v", err)
+		return
+	}
+
+	data := SaveDialogData{Paper: paper, Prompt: savePrompt}
+	tagged := make(map[int]bool, len(paper.Tags))
+	for _, t := range paper.Tags {
+		tagged[t.ID] = true
+	}
+	for _, t := range all {
+		if !tagged[t.ID] {
+			data.Tags = append(data.Tags, t)
+		}
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "save.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleSave saves a paper to the library with the note, priority and tags
+// of the save dialog in a single transaction, so nothing is saved if any
+// of it fails (HTMX endpoint). Tags come from "tag" checkboxes and the
+// comma-separated "tags" field.
+func (h *Handler) HandleSave(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	priority := models.PriorityNone
+	if value := r.FormValue("priority"); value != "" {
+		var err error
+		priority, err = strconv.Atoi(value)
+		if err != nil || priority < models.PriorityNone || priority > models.PriorityHigh {
+			http.Error(w, "Invalid priority", http.StatusBadRequest)
+			return
+		}
+	}
+	note := strings.TrimSpace(r.FormValue("note"))
+	tags := saveTags(r.Form["tag"], r.FormValue("tags"))
+	personal := parseBool(r.FormValue("personal"), false) && clientID(r) != ""
+
+	err := h.db.SaveWithTags(id, priority, note, tags, personal)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Paper not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to save to library", err)
+		log.Printf("Error saving to library: %v", err)
+		return
+	}
+	h.firePaperSaved(id)
+
+	w.Header().Set("HX-Trigger", `{"libraryUpdated": true, "closeModal": true, "showToast": {"message": "Saved to library", "type": "success"}}`)
+	w.WriteHeader(http.StatusOK)
+	writeSavedButton(w, id)
+}
+
+// saveTags merges checked tags with a comma-separated list of tags, in
+// order and without blanks or repeats
+func saveTags(checked []string, list string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, name := range slices.Concat(checked, strings.Split(list, ",")) {
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// writeSavedButton writes the save button of a paper card for a paper in
+// the library
+func writeSavedButton(w io.Writer, id string) {
+	fmt.Fprintf(w, `<button data-action="save" hx-post="/library/remove/%s" hx-swap="outerHTML" class="btn btn-success flex-1 md:flex-none md:w-full" title="Saved to Library (Click to Remove)"><i data-lucide="check" class="w-4 h-4"></i></button><script>lucide.createIcons();</script>`, id)
+}
+
+// saveButtonAttrs returns the attributes making a button save a paper:
+// opening the save dialog, or saving in one click if the prompt is off
+func (h *Handler) saveButtonAttrs(id string) string {
+	if h.savePromptText() != "" {
+		return fmt.Sprintf(` data-save-id="%s" hx-get="/library/save/%s" hx-target="#modal"`, id, id)
+	}
+	return fmt.Sprintf(` hx-post="/library/add/%s" hx-swap="outerHTML"`, id)
+}
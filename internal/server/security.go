@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// contentSecurityPolicy allows the assets the templates load: Tailwind,
+// HTMX, Lucide, MathJax and NProgress from their CDNs, Google Fonts, and
+// arXiv images in reader mode. Inline scripts and styles are used
+// throughout, and HTMX evaluates hx-on handlers and js: values, hence
+// 'unsafe-inline' and 'unsafe-eval'.
+var contentSecurityPolicy = strings.Join([]string{
+	"default-src 'self'",
+	"script-src 'self' 'unsafe-inline' 'unsafe-eval' https://cdn.tailwindcss.com https://unpkg.com https://cdn.jsdelivr.net",
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://unpkg.com",
+	"font-src 'self' data: https://fonts.gstatic.com https://cdn.jsdelivr.net",
+	"img-src 'self' data: https://arxiv.org",
+	"connect-src 'self'",
+	"object-src 'none'",
+	"base-uri 'self'",
+	"form-action 'self'",
+	"frame-ancestors 'none'",
+}, "; ")
+
+// defaultSecurityHeaders are sent with every response unless overridden
+// by server.security_headers
+var defaultSecurityHeaders = map[string]string{
+	"Content-Security-Policy": contentSecurityPolicy,
+	"X-Content-Type-Options":  "nosniff",
+	"Referrer-Policy":         "strict-origin-when-cross-origin",
+	"X-Frame-Options":         "DENY",
+}
+
+// securityHeaders returns middleware setting the default security headers
+// merged with overrides; an override with an empty value drops the header
+func securityHeaders(overrides map[string]string) func(http.Handler) http.Handler {
+	headers := make(map[string]string, len(defaultSecurityHeaders))
+	for name, value := range defaultSecurityHeaders {
+		headers[name] = value
+	}
+	for name, value := range overrides {
+		name = http.CanonicalHeaderKey(name)
+		if value == "" {
+			delete(headers, name)
+		} else {
+			headers[name] = value
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowFraming relaxes the security headers already set on a response so
+// the given origins, or any site if there are none, may show it in a
+// frame: X-Frame-Options is dropped and the policy's frame-ancestors
+// directive replaced
+func allowFraming(header http.Header, ancestors []string) {
+	header.Del("X-Frame-Options")
+
+	policy := header.Get("Content-Security-Policy")
+	if policy == "" {
+		return
+	}
+	allowed := "*"
+	if len(ancestors) > 0 {
+		allowed = strings.Join(ancestors, " ")
+	}
+	var directives []string
+	for _, directive := range strings.Split(policy, ";") {
+		directive = strings.TrimSpace(directive)
+		if directive != "" && !strings.HasPrefix(directive, "frame-ancestors") {
+			directives = append(directives, directive)
+		}
+	}
+	directives = append(directives, "frame-ancestors "+allowed)
+	header.Set("Content-Security-Policy", strings.Join(directives, "; "))
+}
@@ -8,8 +8,17 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ngx/arxiv-go-nest/internal/api"
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
 	"github.com/ngx/arxiv-go-nest/internal/config"
 	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/diagnostics"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/fetcher"
+	"github.com/ngx/arxiv-go-nest/internal/hooks"
+	"github.com/ngx/arxiv-go-nest/internal/scheduler"
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+	"github.com/ngx/arxiv-go-nest/internal/version"
 )
 
 // Server represents the HTTP server
@@ -18,10 +27,14 @@ type Server struct {
 	db      *db.DB
 	router  *chi.Mux
 	handler *Handler
+
+	// preview is set by EnablePreview
+	preview bool
 }
 
-// New creates a new HTTP server
-func New(cfg *config.Config, database *db.DB) (*Server, error) {
+// New creates a new HTTP server. The scheduler, log buffer, update checker
+// and hook runner may be nil, e.g. in tests.
+func New(cfg *config.Config, database *db.DB, client *arxiv.Client, f *fetcher.Fetcher, flags *features.Flags, sched *scheduler.Scheduler, logs *diagnostics.LogBuffer, updates *version.Checker, hookRunner *hooks.Runner) (*Server, error) {
 	s := &Server{
 		config: cfg,
 		db:     database,
@@ -29,7 +42,7 @@ func New(cfg *config.Config, database *db.DB) (*Server, error) {
 	}
 
 	// Initialize handler
-	handler, err := NewHandler(cfg, database)
+	handler, err := NewHandler(cfg, database, client, f, flags, sched, logs, updates, hookRunner)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create handler: %w", err)
 	}
@@ -47,9 +60,15 @@ func New(cfg *config.Config, database *db.DB) (*Server, error) {
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
 	s.router.Use(middleware.Logger)
+	s.router.Use(traceRequests)
 	s.router.Use(middleware.Recoverer)
+	s.router.Use(peerMiddleware)
 	s.router.Use(middleware.RealIP)
 	s.router.Use(middleware.Compress(5))
+	s.router.Use(securityHeaders(s.config.Server.SecurityHeaders))
+	s.router.Use(s.authenticate)
+	s.router.Use(clientMiddleware)
+	s.router.Use(s.trackUsage)
 }
 
 // setupRoutes configures all routes
@@ -57,29 +76,140 @@ func (s *Server) setupRoutes() {
 	// Serve static files with caching
 	staticPath := filepath.Join("web", "static")
 	fileServer := http.FileServer(http.Dir(staticPath))
-	
+
 	// Wrap file server to add Cache-Control headers
 	s.router.Handle("/static/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set long cache duration (1 year) for static assets
-		w.Header().Set("Cache-Control", "public, max-age=31536000")
+		// Set long cache duration (1 year) for static assets, except
+		// while previewing edits to them
+		if s.preview {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=31536000")
+		}
 		http.StripPrefix("/static/", fileServer).ServeHTTP(w, r)
 	}))
 
 	// HTML routes
-	s.router.Get("/", s.handler.HandleIndex)
-	s.router.Get("/paper/{id}", s.handler.HandlePaperDetail)
-	s.router.Get("/library", s.handler.HandleLibrary)
-	s.router.Get("/search", s.handler.HandleSearch)
+	s.router.Get("/", s.scoped((*Handler).HandleIndex))
+	s.router.Get("/paper/{id}", s.scoped((*Handler).HandlePaperDetail))
+	s.router.With(s.handler.requireFeature(features.ReaderMode)).Get("/paper/{id}/read", s.scoped((*Handler).HandleReader))
+	s.router.Get("/library", s.scoped((*Handler).HandleLibrary))
+	s.router.Get("/search", s.scoped((*Handler).HandleSearch))
+	s.router.Get("/tags", s.scoped((*Handler).HandleTags))
+	s.router.Get("/plan", s.scoped((*Handler).HandlePlan))
+	s.router.Get("/plan.ics", s.scoped((*Handler).HandlePlanICal))
+	s.router.With(s.handler.requireFeature(features.VenueDates)).Get("/venues.ics", s.scoped((*Handler).HandleVenuesICal))
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.handler.requireAudio)
+		r.Get("/paper/{id}/audio", s.scoped((*Handler).HandlePaperAudio))
+		r.Get("/playlist.m3u", s.scoped((*Handler).HandlePlaylist))
+	})
+	s.router.Get("/update-banner", s.scoped((*Handler).HandleUpdateBanner))
+	s.router.Get("/login", s.scoped((*Handler).HandleLoginPage))
+	s.router.Post("/login", s.scoped((*Handler).HandleLogin))
+	s.router.Post("/logout", s.scoped((*Handler).HandleLogout))
+	s.router.Get("/account", s.scoped((*Handler).HandleAccount))
+	s.router.Get("/tags/{name}", s.scoped((*Handler).HandleTagDetail))
+	s.router.Get("/embed/tag/{name}", s.scoped((*Handler).HandleEmbedTag))
+	s.router.Get("/export/latex", s.scoped((*Handler).HandleExportLaTeX))
+	s.router.Get("/export/print", s.scoped((*Handler).HandlePrintExport))
+	s.router.With(s.handler.requireFeature(features.ArchiveStats)).Get("/stats", s.scoped((*Handler).HandleStats))
 
 	// API routes (HTMX endpoints)
-	s.router.Post("/library/add/{id}", s.handler.HandleAddToLibrary)
-	s.router.Post("/library/remove/{id}", s.handler.HandleRemoveFromLibrary)
-	s.router.Post("/library/toggle-read/{id}", s.handler.HandleToggleRead)
-	s.router.Post("/tag/add", s.handler.HandleAddTag)
-	s.router.Post("/tag/remove", s.handler.HandleRemoveTag)
-	
+	s.router.Post("/library/add/{id}", s.scoped((*Handler).HandleAddToLibrary))
+	s.router.Get("/library/save/{id}", s.scoped((*Handler).HandleSaveDialog))
+	s.router.Post("/library/save/{id}", s.scoped((*Handler).HandleSave))
+	s.router.Post("/library/remove/{id}", s.scoped((*Handler).HandleRemoveFromLibrary))
+	s.router.Post("/library/toggle-read/{id}", s.scoped((*Handler).HandleToggleRead))
+	s.router.Post("/library/read/{id}", s.scoped((*Handler).HandleSetRead))
+	s.router.Post("/library/import", s.scoped((*Handler).HandleImport))
+	s.router.Post("/library/bulk-read", s.scoped((*Handler).HandleBulkRead))
+	s.router.Post("/library/entry/{id}", s.scoped((*Handler).HandleUpdateLibraryEntry))
+	s.router.Post("/paper/{id}/delete", s.scoped((*Handler).HandleDeletePaper))
+	s.router.Post("/papers/bulk-delete", s.scoped((*Handler).HandleBulkDelete))
+	s.router.Post("/tag/add", s.scoped((*Handler).HandleAddTag))
+	s.router.Post("/tag/remove", s.scoped((*Handler).HandleRemoveTag))
+	s.router.Post("/tags/{name}/description", s.scoped((*Handler).HandleSetTagDescription))
+	s.router.Post("/tags/{name}/share", s.scoped((*Handler).HandleShareTag))
+	s.router.Post("/tags/{name}/notify", s.scoped((*Handler).HandleTagNotify))
+	s.router.Post("/paper/{id}/relations", s.scoped((*Handler).HandleAddRelation))
+	s.router.Post("/relations/{id}/delete", s.scoped((*Handler).HandleDeleteRelation))
+	s.router.Post("/preferences", s.scoped((*Handler).HandleSetPreferences))
+	s.router.Post("/plan/{id}", s.scoped((*Handler).HandlePlanPaper))
+	s.router.With(s.handler.requireFeature(features.ReaderMode)).Get("/paper/{id}/html", s.scoped((*Handler).HandleHTMLStatus))
+
+	// Shelves
+	s.router.Get("/shelves", s.scoped((*Handler).HandleShelves))
+	s.router.Post("/shelves", s.scoped((*Handler).HandleCreateShelf))
+	s.router.Get("/shelves/{name}", s.scoped((*Handler).HandleShelf))
+	s.router.Post("/shelves/{name}/delete", s.scoped((*Handler).HandleDeleteShelf))
+	s.router.Post("/shelves/{name}/notify", s.scoped((*Handler).HandleShelfNotify))
+	s.router.Post("/shelves/{name}/papers/{id}", s.scoped((*Handler).HandleShelvePaper))
+	s.router.Post("/shelves/{name}/entry/{id}", s.scoped((*Handler).HandleUpdateShelfEntry))
+
+	// Saved views
+	s.router.Get("/views", s.scoped((*Handler).HandleViews))
+	s.router.Post("/views", s.scoped((*Handler).HandleCreateView))
+	s.router.Get("/views/{name}", s.scoped((*Handler).HandleView))
+	s.router.Post("/views/{name}/delete", s.scoped((*Handler).HandleDeleteView))
+
+	// Recycle bin
+	s.router.Get("/trash", s.scoped((*Handler).HandleTrash))
+	s.router.Post("/trash/empty", s.scoped((*Handler).HandleEmptyTrash))
+	s.router.Post("/trash/{id}/restore", s.scoped((*Handler).HandleRestorePaper))
+	s.router.Post("/trash/{id}/purge", s.scoped((*Handler).HandlePurgePaper))
+
+	// Duplicate suggestions
+	s.router.Get("/duplicates", s.scoped((*Handler).HandleDuplicates))
+	s.router.Post("/duplicates/{id}/merge", s.scoped((*Handler).HandleMergeDuplicate))
+	s.router.Post("/duplicates/{id}/dismiss", s.scoped((*Handler).HandleDismissDuplicate))
+
+	// Running tasks are followed and canceled outside /admin, as library
+	// imports run as tasks too
+	s.router.Get("/tasks/{id}/events", s.scoped((*Handler).HandleTaskEvents))
+	s.router.Post("/tasks/{id}/cancel", s.scoped((*Handler).HandleCancelTask))
+
+	// Reading group assignments
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.handler.requireFeature(features.ReadingGroup))
+		r.Get("/presentations", s.scoped((*Handler).HandlePresentations))
+		r.Post("/assignments", s.scoped((*Handler).HandleCreateAssignment))
+		r.Post("/assignments/{id}/presented", s.scoped((*Handler).HandleSetPresented))
+		r.Post("/assignments/{id}/delete", s.scoped((*Handler).HandleDeleteAssignment))
+	})
+
+	// Curation queue and the team feed
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.handler.requireFeature(features.Curation))
+		r.Get("/review", s.scoped((*Handler).HandleReview))
+		r.Post("/review", s.scoped((*Handler).HandleReviewPapers))
+		r.Get("/team", s.scoped((*Handler).HandleTeamFeed))
+		r.Get("/team.atom", s.scoped((*Handler).HandleTeamFeedAtom))
+	})
+
+	// JSON API with its OpenAPI document and Swagger UI
+	s.router.Mount(api.BasePath, api.New(s.config, s.db, s.handler.hooks).Router())
+
 	// Admin routes
-	s.router.Post("/admin/refresh", s.handler.HandleRefresh)
+	s.router.Post("/admin/refresh", s.scoped((*Handler).HandleRefresh))
+	s.router.Post("/admin/reload", s.scoped((*Handler).HandleReload))
+	s.router.Get("/admin/features", s.scoped((*Handler).HandleFeatures))
+	s.router.Post("/admin/features/{name}", s.scoped((*Handler).HandleSetFeature))
+	s.router.Get("/admin/scheduler", s.scoped((*Handler).HandleScheduler))
+	s.router.Get("/admin/diagnostics", s.scoped((*Handler).HandleDiagnostics))
+	s.router.Get("/admin/links", s.scoped((*Handler).HandleLinks))
+	s.router.Get("/admin/deliveries", s.scoped((*Handler).HandleDeliveries))
+	s.router.Post("/admin/deliveries/test", s.scoped((*Handler).HandleTestNotification))
+	s.router.Get("/admin/usage", s.scoped((*Handler).HandleUsage))
+	s.router.Post("/admin/usage/clear", s.scoped((*Handler).HandleClearUsage))
+	s.router.Get("/admin/authors", s.scoped((*Handler).HandleAuthors))
+	s.router.Get("/admin/authors/preview", s.scoped((*Handler).HandleAuthorPreview))
+	s.router.Post("/admin/authors/replace", s.scoped((*Handler).HandleAuthorReplace))
+	s.router.Post("/admin/scheduler/subscriptions/run", s.scoped((*Handler).HandleRunSubscription))
+	s.router.Post("/admin/scheduler/{job}/{action}", s.scoped((*Handler).HandleSchedulerAction))
+	s.router.Get("/admin/tasks", s.scoped((*Handler).HandleTasks))
+	s.router.Post("/admin/tasks/backfill", s.scoped((*Handler).HandleStartBackfill))
+	s.router.Post("/admin/tasks/reindex", s.scoped((*Handler).HandleStartReindex))
 }
 
 // Start starts the HTTP server
@@ -89,6 +219,35 @@ func (s *Server) Start() error {
 	return http.ListenAndServe(addr, s.router)
 }
 
+// scoped adapts a handler method into a route handler. The method runs on
+// a copy of the handler scoped to the request: its database handle shows
+// the requesting browser's personal tags, and while telemetry is on, its
+// queries and template rendering are traced as children of the request's
+// span, since the database methods don't take a context.
+func (s *Server) scoped(method func(*Handler, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := *s.handler
+		h.db = h.db.ForClient(clientID(r))
+		if telemetry.Enabled() {
+			h.db = h.db.WithContext(r.Context())
+			h.templates = tracedRenderer{Renderer: h.templates, ctx: r.Context()}
+		}
+		method(&h, w, r)
+	}
+}
+
+// SetReloader sets the function /admin/reload calls to reload the
+// configuration, returning the changed settings that need a restart
+func (s *Server) SetReloader(reload func() ([]string, error)) {
+	s.handler.reload = reload
+}
+
+// SetAuth replaces the configured authentication, e.g. with
+// authenticators for an identity provider of an institution's own
+func (s *Server) SetAuth(auth *Auth) {
+	s.handler.auth = auth
+}
+
 // Router returns the chi router (useful for testing)
 func (s *Server) Router() *chi.Mux {
 	return s.router
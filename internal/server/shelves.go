@@ -0,0 +1,255 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// maxShelfName is the longest shelf name accepted
+const maxShelfName = 100
+
+// ShelfOption is a shelf offered on a paper's detail page, with whether
+// the paper is on it
+type ShelfOption struct {
+	models.Shelf
+	On bool
+}
+
+// shelfURL is the page of a shelf
+func shelfURL(name string) string {
+	return "/shelves/" + url.PathEscape(name)
+}
+
+// loadShelf returns the shelf named in the URL, answering 404 or 500 and
+// returning nil if it can't be loaded
+func (h *Handler) loadShelf(w http.ResponseWriter, r *http.Request) *models.Shelf {
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid shelf", http.StatusBadRequest)
+		return nil
+	}
+
+	shelf, err := h.db.GetShelf(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Shelf not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch shelf", err)
+		log.Printf("Error fetching shelf %s: %v", name, err)
+		return nil
+	}
+	return shelf
+}
+
+// HandleShelves lists the shelves with their counts
+func (h *Handler) HandleShelves(w http.ResponseWriter, r *http.Request) {
+	shelves, err := h.db.GetShelves()
+	if err != nil {
+		serverError(w, "Failed to fetch shelves", err)
+		log.Printf("Error fetching shelves: %v", err)
+		return
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:        "Shelves",
+		PaperCount:   paperCount,
+		LibraryCount: libraryCount,
+		Features:     h.features.Map(),
+		Shelves:      shelves,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "shelves.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleCreateShelf creates a shelf and opens it (HTMX endpoint)
+func (h *Handler) HandleCreateShelf(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || strings.Contains(name, "/") || len(name) > maxShelfName {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "Shelf names must be 1-100 characters without a slash", "type": "error"}}`)
+		http.Error(w, "Invalid shelf name", http.StatusBadRequest)
+		return
+	}
+
+	shelf, err := h.db.CreateShelf(name, strings.TrimSpace(r.FormValue("description")))
+	if errors.Is(err, db.ErrShelfExists) {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "A shelf with this name already exists", "type": "error"}}`)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to create shelf", err)
+		log.Printf("Error creating shelf: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", shelfURL(shelf.Name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleShelf renders a shelf's papers with their read state and priority
+// on the shelf. ?sort=added lists the most recently added first.
+func (h *Handler) HandleShelf(w http.ResponseWriter, r *http.Request) {
+	shelf := h.loadShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	papers, err := h.db.GetShelfPapers(shelf.ID, sortBy)
+	if err != nil {
+		serverError(w, "Failed to fetch papers", err)
+		log.Printf("Error fetching papers of shelf %s: %v", shelf.Name, err)
+		return
+	}
+
+	paperCount, _ := h.db.GetPaperCount()
+	libraryCount, _ := h.db.GetLibraryCount()
+
+	data := PageData{
+		Title:          shelf.Name,
+		Papers:         papers,
+		PaperCount:     paperCount,
+		LibraryCount:   libraryCount,
+		Features:       h.features.Map(),
+		Shelf:          shelf,
+		SortBy:         sortBy,
+		NotifyChannels: h.collectionChannels(models.CollectionShelf, shelf.ID),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "shelf.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleDeleteShelf deletes a shelf, leaving its papers alone, and returns
+// to the shelf list (HTMX endpoint)
+func (h *Handler) HandleDeleteShelf(w http.ResponseWriter, r *http.Request) {
+	shelf := h.loadShelf(w, r)
+	if shelf == nil {
+		return
+	}
+
+	if err := h.db.DeleteShelf(shelf.ID); err != nil {
+		serverError(w, "Failed to delete shelf", err)
+		log.Printf("Error deleting shelf %s: %v", shelf.Name, err)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/shelves")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleShelvePaper puts a paper on a shelf, or takes it off with
+// on=false (HTMX endpoint). The empty response replaces the paper's row on
+// the shelf page.
+func (h *Handler) HandleShelvePaper(w http.ResponseWriter, r *http.Request) {
+	shelf := h.loadShelf(w, r)
+	if shelf == nil {
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	on := parseBool(r.FormValue("on"), true)
+
+	var err error
+	message := "Added to " + shelf.Name
+	if on {
+		var exists bool
+		if exists, err = h.db.PaperExists(id); err == nil && !exists {
+			http.Error(w, "Paper not found", http.StatusNotFound)
+			return
+		}
+		if err == nil {
+			err = h.db.AddToShelf(shelf.ID, id)
+		}
+	} else {
+		message = "Removed from " + shelf.Name
+		err = h.db.RemoveFromShelf(shelf.ID, id)
+	}
+	if err != nil {
+		serverError(w, "Failed to update shelf", err)
+		log.Printf("Error updating shelf %s: %v", shelf.Name, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": %q, "type": "success"}}`, message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleUpdateShelfEntry sets the read state and priority of a paper on a
+// shelf (HTMX endpoint)
+func (h *Handler) HandleUpdateShelfEntry(w http.ResponseWriter, r *http.Request) {
+	shelf := h.loadShelf(w, r)
+	if shelf == nil {
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	priority, err := strconv.Atoi(r.FormValue("priority"))
+	if err != nil || priority < models.PriorityNone || priority > models.PriorityHigh {
+		http.Error(w, "Invalid priority", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdateShelfEntry(shelf.ID, id, parseBool(r.FormValue("is_read"), false), priority); err != nil {
+		serverError(w, "Failed to update shelf entry", err)
+		log.Printf("Error updating shelf entry: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Shelf entry updated", "type": "success"}}`)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// shelfOptions returns every shelf, marking those the paper is on
+func (h *Handler) shelfOptions(paperID string) ([]ShelfOption, error) {
+	shelves, err := h.db.GetShelves()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := h.db.GetPaperShelves(paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	on := make(map[int]bool, len(entries))
+	for _, e := range entries {
+		on[e.ShelfID] = true
+	}
+	options := make([]ShelfOption, len(shelves))
+	for i, s := range shelves {
+		options[i] = ShelfOption{Shelf: s, On: on[s.ID]}
+	}
+	return options, nil
+}
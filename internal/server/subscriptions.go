@@ -0,0 +1,109 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ChannelSubscription is a notification channel and whether it announces
+// papers added to the tag or shelf of the page
+type ChannelSubscription struct {
+	Name string
+	On   bool
+}
+
+// collectionChannels lists the configured notification channels for the
+// page of a shared tag or a shelf, marking those announcing its additions.
+// It is empty while notifications are off or no channel is configured.
+func (h *Handler) collectionChannels(kind string, id int) []ChannelSubscription {
+	notifier := h.notifier()
+	if !notifier.Enabled() || !h.features.Enabled(features.Notifications) {
+		return nil
+	}
+
+	sub, err := h.db.GetCollectionSubscription(kind, id)
+	if err != nil {
+		log.Printf("Error fetching subscriptions of %s %d: %v", kind, id, err)
+		return nil
+	}
+	var channels []ChannelSubscription
+	for _, name := range notifier.Channels() {
+		channels = append(channels, ChannelSubscription{Name: name, On: slices.Contains(sub.Channels, name)})
+	}
+	return channels
+}
+
+// HandleTagNotify sets the channels announcing papers added to a shared tag
+// to the "channel" values (HTMX endpoint)
+func (h *Handler) HandleTagNotify(w http.ResponseWriter, r *http.Request) {
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.GetTag(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch tag", err)
+		log.Printf("Error fetching tag %s: %v", name, err)
+		return
+	}
+	if tag.Owner != "" {
+		http.Error(w, "Only shared tags announce their papers", http.StatusForbidden)
+		return
+	}
+
+	h.setCollectionChannels(w, r, models.CollectionTag, tag.ID, tag.Name)
+}
+
+// HandleShelfNotify sets the channels announcing papers added to a shelf to
+// the "channel" values (HTMX endpoint)
+func (h *Handler) HandleShelfNotify(w http.ResponseWriter, r *http.Request) {
+	shelf := h.loadShelf(w, r)
+	if shelf == nil {
+		return
+	}
+	h.setCollectionChannels(w, r, models.CollectionShelf, shelf.ID, shelf.Name)
+}
+
+// setCollectionChannels stores the channels of the request's form that
+// announce papers added to a tag or shelf. Channels that aren't configured
+// are rejected.
+func (h *Handler) setCollectionChannels(w http.ResponseWriter, r *http.Request, kind string, id int, name string) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	configured := h.notifier().Channels()
+	channels := r.Form["channel"]
+	for _, channel := range channels {
+		if !slices.Contains(configured, channel) {
+			http.Error(w, "Unknown channel", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.db.SetCollectionSubscription(kind, id, channels); err != nil {
+		serverError(w, "Failed to update notifications", err)
+		log.Printf("Error updating notifications of %s %s: %v", kind, name, err)
+		return
+	}
+
+	message := "Papers added to " + name + " are no longer announced"
+	if len(channels) > 0 {
+		message = "Papers added to " + name + " will be announced"
+	}
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": %q, "type": "success"}}`, message))
+	w.WriteHeader(http.StatusOK)
+}
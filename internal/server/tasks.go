@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ngx/arxiv-go-nest/internal/fetcher"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/sources"
+	"github.com/ngx/arxiv-go-nest/internal/tasks"
+)
+
+// importChunk is how many references an import task fetches at a time,
+// reporting its progress in between
+const importChunk = 10
+
+// taskEventInterval is the least time between two progress events sent to
+// a page following a task
+const taskEventInterval = 250 * time.Millisecond
+
+// HandleTasks renders the background tasks page, where backfills and
+// index repairs are started and running tasks followed
+func (h *Handler) HandleTasks(w http.ResponseWriter, r *http.Request) {
+	data := PageData{
+		Title:    "Background Tasks",
+		Features: h.features.Map(),
+		Tasks:    h.tasks.List(),
+	}
+
+	var l loader
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch counts", err)
+		log.Printf("Error fetching counts: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "tasks.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleStartBackfill starts harvesting a category's papers submitted
+// between two days as a background task, resuming an earlier backfill of
+// the same days from its checkpoint (HTMX endpoint)
+func (h *Handler) HandleStartBackfill(w http.ResponseWriter, r *http.Request) {
+	category := strings.TrimSpace(r.FormValue("category"))
+	if category == "" {
+		http.Error(w, "Enter a category, e.g. cs.LG", http.StatusBadRequest)
+		return
+	}
+	from, err := time.Parse("2006-01-02", r.FormValue("from"))
+	if err != nil {
+		http.Error(w, "Invalid first day", http.StatusBadRequest)
+		return
+	}
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if r.FormValue("to") != "" {
+		if to, err = time.Parse("2006-01-02", r.FormValue("to")); err != nil {
+			http.Error(w, "Invalid last day", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "The last day is before the first", http.StatusBadRequest)
+		return
+	}
+
+	b, err := h.db.StartBackfill(category, from, to)
+	if err != nil {
+		serverError(w, "Failed to start backfill", err)
+		log.Printf("Error starting backfill of %s: %v", category, err)
+		return
+	}
+
+	days := int(b.ToDay.Sub(b.FromDay).Hours()/24) + 1
+	h.startTask(w, "Backfill "+category, func(ctx context.Context, p *tasks.Progress) (string, error) {
+		opts := fetcher.BackfillOptions{
+			Progress: func(b *models.Backfill) {
+				day := b.NextDay.Format("2006-01-02")
+				if b.Done() {
+					day = ""
+				}
+				p.Update(int(b.NextDay.Sub(b.FromDay).Hours()/24), days, day)
+			},
+		}
+		opts.Progress(b)
+		err := h.fetcher.Backfill(ctx, b, opts)
+		summary := fmt.Sprintf("%d fetched, %d stored", b.Fetched, b.Stored)
+		if err != nil {
+			summary += fmt.Sprintf("; stopped at %s, start it again to resume", b.NextDay.Format("2006-01-02"))
+		}
+		return summary, err
+	})
+}
+
+// HandleStartReindex checks, and with "repair" fixes, the indexes and
+// derived data as a background task (HTMX endpoint)
+func (h *Handler) HandleStartReindex(w http.ResponseWriter, r *http.Request) {
+	repair := r.FormValue("repair") != ""
+	name := "Verify indexes"
+	if repair {
+		name = "Repair indexes"
+	}
+
+	h.startTask(w, name, func(ctx context.Context, p *tasks.Progress) (string, error) {
+		checks, err := h.db.VerifyIndexesProgress(repair, p.Update)
+		var found []string
+		for _, c := range checks {
+			if c.Problems == 0 {
+				continue
+			}
+			problem := fmt.Sprintf("%s: %d problems", c.Name, c.Problems)
+			if c.Repaired {
+				problem += " repaired"
+			}
+			found = append(found, problem)
+		}
+		if len(found) == 0 {
+			return fmt.Sprintf("%d checks, no problems found", len(checks)), err
+		}
+		return strings.Join(found, "; "), err
+	})
+}
+
+// startImport imports papers by reference as a background task, a chunk
+// at a time, saving them to the library
+func (h *Handler) startImport(w http.ResponseWriter, refs []string) {
+	h.startTask(w, "Import", func(ctx context.Context, p *tasks.Progress) (string, error) {
+		saved := 0
+		var missing []string
+		summary := func() string {
+			s := fmt.Sprintf("Imported %d papers", saved)
+			if len(missing) > 0 {
+				s += "; not found or not recognized: " + strings.Join(missing, ", ")
+			}
+			return s
+		}
+
+		for start := 0; start < len(refs); start += importChunk {
+			chunk := refs[start:min(start+importChunk, len(refs))]
+			p.Update(start, len(refs), strings.Join(chunk, ", "))
+
+			papers, err := h.fetcher.Import(ctx, chunk)
+			var importErr *sources.ImportError
+			if err != nil && !errors.As(err, &importErr) {
+				return summary(), err
+			}
+			if importErr != nil {
+				log.Printf("Error importing papers: %v", importErr)
+				missing = append(missing, importErr.Refs()...)
+			}
+
+			for _, paper := range papers {
+				if err := h.db.SaveToLibrary(paper.ID); err != nil {
+					log.Printf("Error adding %s to library: %v", paper.ID, err)
+					continue
+				}
+				h.firePaperSaved(paper.ID)
+				saved++
+			}
+		}
+		return summary(), nil
+	})
+}
+
+// startTask starts a background task and answers with its row, which
+// follows the task's progress
+func (h *Handler) startTask(w http.ResponseWriter, name string, run tasks.Func) {
+	id, err := h.tasks.Start(name, run)
+	if err == tasks.ErrRunning {
+		w.Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast": {"message": %q, "type": "error"}}`, name+" is already running"))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, "Failed to start task", err)
+		log.Printf("Error starting task %s: %v", name, err)
+		return
+	}
+
+	status, _ := h.tasks.Get(id)
+	w.WriteHeader(http.StatusOK)
+	writeTaskRow(w, status)
+}
+
+// HandleCancelTask asks a running task to stop (HTMX endpoint)
+func (h *Handler) HandleCancelTask(w http.ResponseWriter, r *http.Request) {
+	if err := h.tasks.Cancel(chi.URLParam(r, "id")); err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "Stopping the task", "type": "info"}}`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleTaskEvents streams a task's progress as server-sent events: a
+// "progress" event with the progress bar whenever it changes, at most
+// every taskEventInterval, and a "done" event with the finished row
+func (h *Handler) HandleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	updates, stop, err := h.tasks.Follow(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+
+	var buf bytes.Buffer
+	for {
+		var status tasks.Status
+		var ok bool
+		select {
+		case status, ok = <-updates:
+			if !ok {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		buf.Reset()
+		event := "progress"
+		if status.Running() {
+			writeTaskProgress(&buf, status)
+		} else {
+			event = "done"
+			writeTaskRow(&buf, status)
+		}
+		if err := writeEvent(w, event, buf.String()); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			log.Printf("Error streaming task events: %v", err)
+			return
+		}
+		if event == "done" {
+			return
+		}
+
+		select {
+		case <-time.After(taskEventInterval):
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a server-sent event, one data line per line of data
+func writeEvent(w io.Writer, event, data string) error {
+	if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if _, err := fmt.Fprintf(w, "data: %s\n", line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "\n")
+	return err
+}
+
+// writeTaskRow writes the row for a task. A running task's row follows
+// its events, swapping in the progress bar as it changes and the finished
+// row when it is done.
+func writeTaskRow(w io.Writer, task tasks.Status) {
+	if task.Running() {
+		fmt.Fprintf(w, `<div id="task-%s" class="py-3" hx-sse="connect:/tasks/%s/events"><div hx-sse="swap:progress" hx-target="this" hx-swap="innerHTML">`, task.ID, task.ID)
+		writeTaskProgress(w, task)
+		fmt.Fprintf(w, `</div><div hx-sse="swap:done" hx-target="#task-%s" hx-swap="outerHTML"></div></div>`, task.ID)
+		return
+	}
+
+	outcome := fmt.Sprintf(`<span class="text-green-600 dark:text-green-400">✓ %s</span>`, template.HTMLEscapeString(task.Result))
+	switch {
+	case task.Canceled:
+		outcome = `<span class="text-gray-500 dark:text-gray-400">Canceled</span>`
+		if task.Result != "" {
+			outcome += ` <span class="text-gray-500 dark:text-gray-400">· ` + template.HTMLEscapeString(task.Result) + `</span>`
+		}
+	case task.Err != "":
+		outcome = fmt.Sprintf(`<span class="text-red-600 dark:text-red-400">✗ %s</span>`, template.HTMLEscapeString(task.Err))
+		if task.Result != "" {
+			outcome += ` <span class="text-gray-500 dark:text-gray-400">· ` + template.HTMLEscapeString(task.Result) + `</span>`
+		}
+	}
+	fmt.Fprintf(w, `<div id="task-%s" class="py-3 text-sm"><div class="flex items-center justify-between gap-2"><span class="font-medium">%s</span><span class="text-gray-500 dark:text-gray-400">%s · took %s</span></div><div class="mt-1">%s</div></div>`,
+		task.ID, template.HTMLEscapeString(task.Name), task.Finished.Local().Format("Jan 2 15:04:05"),
+		task.Finished.Sub(task.Started).Round(time.Second), outcome)
+}
+
+// writeTaskProgress writes a running task's progress bar, with the item
+// it is working on and the time it has left
+func writeTaskProgress(w io.Writer, task tasks.Status) {
+	progress := "Starting…"
+	if task.Total > 0 {
+		progress = fmt.Sprintf("%d%% · %d of %d", task.Percent(), task.Done, task.Total)
+		if eta := task.ETA.Round(time.Second); eta > 0 {
+			progress += fmt.Sprintf(" · about %s left", eta)
+		}
+	}
+	fmt.Fprintf(w, `<div class="flex items-center justify-between gap-2 text-sm"><span class="font-medium">%s</span><span class="flex items-center gap-2 text-gray-500 dark:text-gray-400">%s <button type="button" hx-post="/tasks/%s/cancel" hx-swap="none" class="btn btn-sm btn-outline">Cancel</button></span></div><div class="w-full h-2 mt-1 bg-gray-200 dark:bg-gray-700 rounded-full"><div class="h-2 bg-blue-600 rounded-full" style="width: %d%%;"></div></div><div class="mt-1 text-xs text-gray-500 dark:text-gray-400 truncate">%s</div>`,
+		template.HTMLEscapeString(task.Name), progress, task.ID, task.Percent(), template.HTMLEscapeString(task.Current))
+}
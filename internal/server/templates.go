@@ -1,12 +1,42 @@
 package server
 
 import (
+	"fmt"
 	"html/template"
+	"io"
+	"net/url"
 	"path/filepath"
+	"strings"
+
+	"github.com/ngx/arxiv-go-nest/internal/badges"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/scheduler"
+	"github.com/ngx/arxiv-go-nest/internal/tasks"
+	"github.com/ngx/arxiv-go-nest/internal/version"
 )
 
+// Renderer executes a named page template
+type Renderer interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// Templates holds one template set per page. Every page defines its own
+// "content" block, so each is parsed into a separate clone of base.html.
+type Templates struct {
+	pages map[string]*template.Template
+}
+
+// ExecuteTemplate renders the page with the given file name
+func (t *Templates) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	page, ok := t.pages[name]
+	if !ok {
+		return fmt.Errorf("template %q not found", name)
+	}
+	return page.ExecuteTemplate(w, name, data)
+}
+
 // NewTemplates creates a new template set with helper functions
-func NewTemplates() (*template.Template, error) {
+func NewTemplates() (*Templates, error) {
 	// Define helper functions
 	funcMap := template.FuncMap{
 		"add": func(a, b int) int {
@@ -42,6 +72,44 @@ func NewTemplates() (*template.Template, error) {
 		"ge": func(a, b int) bool {
 			return a >= b
 		},
+		"pageURL":       pageURL,
+		"setParam":      setParam,
+		"toggleTag":     toggleTag,
+		"linkTo":        linkTo,
+		"priorityLabel": models.PriorityLabel,
+		"licenseLabel":  models.LicenseLabel,
+		"badges":        badges.For,
+		"version": func() string {
+			return version.Get().String()
+		},
+		"tagURL": func(name string) string {
+			return "/tags/" + url.PathEscape(name)
+		},
+		"shelfURL": shelfURL,
+		"viewURL":  viewURL,
+		"jobRow": func(job scheduler.Status) template.HTML {
+			var b strings.Builder
+			writeJobRow(&b, job)
+			return template.HTML(b.String())
+		},
+		"taskRow": func(task tasks.Status) template.HTML {
+			var b strings.Builder
+			writeTaskRow(&b, task)
+			return template.HTML(b.String())
+		},
+		"licenseFilters": func() []models.LicenseFilter {
+			return models.LicenseFilters
+		},
+		"relationKinds": func() []string {
+			return models.RelationKinds
+		},
+		"relationLabel": models.RelationLabel,
+		"pageSizes": func() []int {
+			return pageSizes
+		},
+		"priorities": func() []string {
+			return models.PriorityLabels
+		},
 		"until": func(n int) []int {
 			result := make([]int, n)
 			for i := 0; i < n; i++ {
@@ -51,7 +119,35 @@ func NewTemplates() (*template.Template, error) {
 		},
 	}
 
-	// Parse templates with helper functions
-	templatesPath := filepath.Join("web", "templates", "*.html")
-	return template.New("").Funcs(funcMap).ParseGlob(templatesPath)
+	// Parse the shared layout, then each page on top of its own copy
+	templatesDir := filepath.Join("web", "templates")
+	basePath := filepath.Join(templatesDir, "base.html")
+
+	base, err := template.New("").Funcs(funcMap).ParseFiles(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(templatesDir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{pages: make(map[string]*template.Template)}
+	for _, file := range files {
+		if file == basePath {
+			continue
+		}
+
+		page, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := page.ParseFiles(file); err != nil {
+			return nil, err
+		}
+		t.pages[filepath.Base(file)] = page
+	}
+
+	return t, nil
 }
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ngx/arxiv-go-nest/internal/telemetry"
+)
+
+// traceRequests records a server span and the duration of every request.
+// Spans are named after the matched route (e.g. "GET /paper/{id}") so all
+// requests for a page group together.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !telemetry.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ctx := telemetry.Extract(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := telemetry.Start(ctx, r.Method, telemetry.KindServer,
+			telemetry.String("http.request.method", r.Method),
+			telemetry.String("url.path", r.URL.Path),
+		)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= 500 {
+			span.SetError(fmt.Errorf("status code %d", status))
+		}
+		attrs := []telemetry.Attr{
+			telemetry.String("http.request.method", r.Method),
+			telemetry.Int("http.response.status_code", status),
+		}
+		if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			attrs = append(attrs, telemetry.String("http.route", route))
+		}
+		span.SetAttributes(attrs[1:]...)
+		span.End()
+		telemetry.Record("http.server.request.duration", time.Since(start), attrs...)
+	})
+}
+
+// tracedRenderer traces the rendering of each page
+type tracedRenderer struct {
+	Renderer
+	ctx context.Context
+}
+
+// ExecuteTemplate renders a template in a span named after it
+func (t tracedRenderer) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	span := telemetry.Child(t.ctx, "render "+name, telemetry.KindInternal)
+	err := t.Renderer.ExecuteTemplate(w, name, data)
+	span.SetError(err)
+	span.End()
+	return err
+}
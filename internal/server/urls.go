@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/ngx/arxiv-go-nest/internal/search"
+)
+
+// URL helpers for templates. They rebuild links from the current request URL
+// so existing filters are kept and values are always encoded properly, instead
+// of concatenating query strings by hand.
+
+// pageURL returns the current URL switched to the given page
+func pageURL(u *url.URL, page int) string {
+	q := query(u)
+	if page <= 1 {
+		q.Del("page")
+	} else {
+		q.Set("page", strconv.Itoa(page))
+	}
+	return build(u, "", q)
+}
+
+// setParam returns the current URL with key set to value (or removed if value
+// is empty). Pagination restarts because the result set changes.
+func setParam(u *url.URL, key, value string) string {
+	q := query(u)
+	if value == "" {
+		q.Del(key)
+	} else {
+		q.Set(key, value)
+	}
+	q.Del("page")
+	return build(u, "", q)
+}
+
+// toggleTag returns the current URL filtered by tag, or with the tag filter
+// removed if it is already active
+func toggleTag(u *url.URL, tag string) string {
+	if query(u).Get("tag") == tag {
+		return setParam(u, "tag", "")
+	}
+	return setParam(u, "tag", tag)
+}
+
+// linkTo returns path with the current URL's filters (without pagination) and
+// the given key/value pairs added, e.g. linkTo "/export/latex" .CurrentURL "longtable" "true"
+func linkTo(path string, u *url.URL, pairs ...string) string {
+	q := query(u)
+	q.Del("page")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		q.Set(pairs[i], pairs[i+1])
+	}
+	return build(u, path, q)
+}
+
+// query returns a copy of u's query parameters without empty values. The
+// search query is normalized, so equivalent searches link to one URL.
+func query(u *url.URL) url.Values {
+	q := url.Values{}
+	if u == nil {
+		return q
+	}
+	for key, values := range u.Query() {
+		for _, v := range values {
+			if key == "q" {
+				v = search.Normalize(v)
+			}
+			if v != "" {
+				q.Add(key, v)
+			}
+		}
+	}
+	return q
+}
+
+// build joins a path and query parameters; the path falls back to u's
+func build(u *url.URL, path string, q url.Values) string {
+	if path == "" && u != nil {
+		path = u.Path
+	}
+	if encoded := q.Encode(); encoded != "" {
+		return path + "?" + encoded
+	}
+	return path
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ngx/arxiv-go-nest/internal/api"
+	"github.com/ngx/arxiv-go-nest/internal/features"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+const (
+	// usageDays is how many days the usage page covers
+	usageDays = 30
+
+	// usagePages is how many of the most viewed pages the usage page lists
+	usagePages = 15
+)
+
+// usageFeatures groups routes into the features the usage page reports
+// on, by method and route pattern. Features nobody used are listed too;
+// they are the point of the page.
+var usageFeatures = []struct {
+	Name   string
+	Routes []string
+}{
+	{"Search", []string{"GET /search"}},
+	{"Library", []string{"GET /library", "POST /library/add/{id}", "POST /library/save/{id}", "POST /library/remove/{id}", "POST /library/toggle-read/{id}", "POST /library/read/{id}", "POST /library/bulk-read", "POST /library/import"}},
+	{"Notes and priorities", []string{"POST /library/entry/{id}"}},
+	{"Tags", []string{"GET /tags", "GET /tags/{name}", "POST /tag/add", "POST /tag/remove", "POST /tags/{name}/description", "POST /tags/{name}/share"}},
+	{"Collection notifications", []string{"POST /tags/{name}/notify", "POST /shelves/{name}/notify"}},
+	{"Related papers", []string{"POST /paper/{id}/relations", "POST /relations/{id}/delete"}},
+	{"Shelves", []string{"GET /shelves", "POST /shelves", "GET /shelves/{name}", "POST /shelves/{name}/delete", "POST /shelves/{name}/papers/{id}", "POST /shelves/{name}/entry/{id}"}},
+	{"Saved views", []string{"GET /views", "POST /views", "GET /views/{name}", "POST /views/{name}/delete"}},
+	{"Reading plan", []string{"GET /plan", "POST /plan/{id}", "GET /plan.ics"}},
+	{"Reader mode", []string{"GET /paper/{id}/read"}},
+	{"Audio", []string{"GET /paper/{id}/audio", "GET /playlist.m3u"}},
+	{"LaTeX export", []string{"GET /export/latex"}},
+	{"Print export", []string{"GET /export/print"}},
+	{"Reading group", []string{"GET /presentations", "POST /assignments", "POST /assignments/{id}/presented", "POST /assignments/{id}/delete"}},
+	{"Curation", []string{"GET /review", "POST /review", "GET /team", "GET /team.atom"}},
+	{"Trash", []string{"GET /trash", "POST /trash/empty", "POST /trash/{id}/restore", "POST /trash/{id}/purge"}},
+	{"Duplicates", []string{"GET /duplicates", "POST /duplicates/{id}/merge", "POST /duplicates/{id}/dismiss"}},
+}
+
+// FeatureUsage is how much a feature was used over the usage page's
+// period. Visitors is the most browsers that used it on a single day.
+type FeatureUsage struct {
+	Name     string
+	Uses     int
+	Days     int
+	Visitors int
+}
+
+// PageUsage is how often a page was loaded over the usage page's period
+type PageUsage struct {
+	Name     string
+	Views    int
+	Days     int
+	Visitors int
+}
+
+// trackUsage records each page view and interface action while the
+// usage_stats feature is on. Static files, the JSON API, embeds, task
+// progress and the admin pages aren't counted, nor are requests that failed.
+func (s *Server) trackUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.handler.features.Enabled(features.UsageStats) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" || ww.Status() >= 400 || r.Method == http.MethodHead {
+			return
+		}
+		for _, prefix := range []string{"/static/", api.BasePath + "/", "/embed/", "/tasks/", "/admin/"} {
+			if strings.HasPrefix(route, prefix) {
+				return
+			}
+		}
+
+		kind := models.UsageAction
+		if r.Method == http.MethodGet && r.Header.Get("HX-Request") == "" {
+			kind = models.UsagePage
+		}
+		s.handler.usage.Record(kind, r.Method+" "+route, clientID(r), time.Now())
+	})
+}
+
+// HandleUsage renders the usage statistics of the last usageDays days: how
+// much each feature was used, the most viewed pages and page views per day
+func (h *Handler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	// Show what happened up to now, not up to the last flush
+	if err := h.usage.Flush(); err != nil {
+		log.Printf("Error recording usage: %v", err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(usageDays - 1))
+	counts, err := h.db.GetUsage(since)
+	if err != nil {
+		serverError(w, "Failed to fetch usage statistics", err)
+		log.Printf("Error fetching usage: %v", err)
+		return
+	}
+
+	data := PageData{
+		Title:        "Usage",
+		Features:     h.features.Map(),
+		FeatureUsage: buildFeatureUsage(counts),
+		PageUsage:    buildPageUsage(counts, usagePages),
+		DailyViews:   buildDayBars(dailyViews(counts), since, today),
+	}
+
+	var l loader
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch usage statistics", err)
+		log.Printf("Error fetching usage: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "usage.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleClearUsage deletes the recorded usage statistics
+func (h *Handler) HandleClearUsage(w http.ResponseWriter, r *http.Request) {
+	if err := h.usage.Flush(); err != nil {
+		log.Printf("Error recording usage: %v", err)
+	}
+	if err := h.db.ClearUsage(); err != nil {
+		serverError(w, "Failed to clear usage statistics", err)
+		log.Printf("Error clearing usage: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/admin/usage", http.StatusSeeOther)
+}
+
+// buildFeatureUsage totals the counts of each feature's routes, in the
+// order of usageFeatures
+func buildFeatureUsage(counts []models.UsageCount) []FeatureUsage {
+	feature := make(map[string]int)
+	for i, f := range usageFeatures {
+		for _, route := range f.Routes {
+			feature[route] = i
+		}
+	}
+
+	usage := make([]FeatureUsage, len(usageFeatures))
+	days := make([]map[string]bool, len(usageFeatures))
+	visitors := make([]map[string]int, len(usageFeatures))
+	for i, f := range usageFeatures {
+		usage[i].Name = f.Name
+		days[i] = make(map[string]bool)
+		visitors[i] = make(map[string]int)
+	}
+	for _, c := range counts {
+		i, ok := feature[c.Name]
+		if !ok {
+			continue
+		}
+		usage[i].Uses += c.Count
+		days[i][c.Day] = true
+		// Counts per route can't be added up without counting a browser
+		// using several of them twice, so take the busiest route
+		visitors[i][c.Day] = max(visitors[i][c.Day], c.Visitors)
+	}
+	for i := range usage {
+		usage[i].Days = len(days[i])
+		for _, n := range visitors[i] {
+			usage[i].Visitors = max(usage[i].Visitors, n)
+		}
+	}
+	return usage
+}
+
+// buildPageUsage returns the limit most viewed pages
+func buildPageUsage(counts []models.UsageCount, limit int) []PageUsage {
+	byName := make(map[string]*PageUsage)
+	var pages []*PageUsage
+	for _, c := range counts {
+		if c.Kind != models.UsagePage {
+			continue
+		}
+		p, ok := byName[c.Name]
+		if !ok {
+			p = &PageUsage{Name: c.Name}
+			byName[c.Name] = p
+			pages = append(pages, p)
+		}
+		p.Views += c.Count
+		p.Days++
+		p.Visitors = max(p.Visitors, c.Visitors)
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Views > pages[j].Views })
+	var out []PageUsage
+	for i, p := range pages {
+		if i == limit {
+			break
+		}
+		out = append(out, *p)
+	}
+	return out
+}
+
+// dailyViews totals page views per day
+func dailyViews(counts []models.UsageCount) []models.DayCount {
+	var days []models.DayCount
+	for _, c := range counts {
+		if c.Kind != models.UsagePage {
+			continue
+		}
+		if len(days) == 0 || days[len(days)-1].Day != c.Day {
+			days = append(days, models.DayCount{Day: c.Day})
+		}
+		days[len(days)-1].Count += c.Count
+	}
+	return days
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/db"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"github.com/ngx/arxiv-go-nest/internal/search"
+)
+
+const (
+	// maxViewName is the longest view name accepted
+	maxViewName = 100
+
+	// viewPaperLimit is how many of a view's papers, in its sort order, are
+	// remembered between visits. Papers further down the list that move up
+	// past it show as new.
+	viewPaperLimit = 500
+)
+
+// viewURL is the page of a saved view
+func viewURL(name string) string {
+	return "/views/" + url.PathEscape(name)
+}
+
+// viewParams returns the search a view runs, as its list page would
+func viewParams(view *models.SavedView) (models.SearchParams, error) {
+	query, err := url.ParseQuery(view.Query)
+	if err != nil {
+		return models.SearchParams{}, err
+	}
+	params := search.ParseParams(query)
+	if view.Page == models.ViewLibrary {
+		params.Category = ""
+		params.InLibrary = true
+	}
+	applySort(&params, query.Get("sort"))
+	return params, nil
+}
+
+// viewListURL is the list page showing everything the view matches
+func viewListURL(view *models.SavedView) string {
+	path := "/"
+	if view.Page == models.ViewLibrary {
+		path = "/library"
+	}
+	if view.Query == "" {
+		return path + "?reset=1"
+	}
+	return path + "?" + view.Query
+}
+
+// loadView returns the view named in the URL, answering 404 or 500 and
+// returning nil if it can't be loaded
+func (h *Handler) loadView(w http.ResponseWriter, r *http.Request) *models.SavedView {
+	name, err := pathParam(r, "name")
+	if err != nil {
+		http.Error(w, "Invalid view", http.StatusBadRequest)
+		return nil
+	}
+
+	view, err := h.db.GetView(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "View not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		serverError(w, "Failed to fetch view", err)
+		log.Printf("Error fetching view %s: %v", name, err)
+		return nil
+	}
+	return view
+}
+
+// HandleViews lists the saved views
+func (h *Handler) HandleViews(w http.ResponseWriter, r *http.Request) {
+	data := PageData{
+		Title:    "Saved Views",
+		Features: h.features.Map(),
+	}
+
+	var l loader
+	l.Require(func() (err error) {
+		data.Views, err = h.db.GetViews()
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch views", err)
+		log.Printf("Error fetching views: %v", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "views.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleCreateView saves the filter of the browse or library page as a
+// view (HTMX endpoint). The name comes from the "name" field or the answer
+// to an hx-prompt; "page" and "query" are the list page and its query
+// string. The papers the view returns now won't count as new on the first
+// visit.
+func (h *Handler) HandleCreateView(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = strings.TrimSpace(r.Header.Get("HX-Prompt"))
+	}
+	if name == "" || strings.Contains(name, "/") || len(name) > maxViewName {
+		w.Header().Set("HX-Trigger", `{"showToast": {"message": "View names must be 1-100 characters without a slash", "type": "error"}}`)
+		http.Error(w, "Invalid view name", http.StatusBadRequest)
+		return
+	}
+	page := r.FormValue("page")
+	if page != models.ViewBrowse && page != models.ViewLibrary {
+		http.Error(w, "Invalid page", http.StatusBadRequest)
+		return
+	}
+	query, err := url.ParseQuery(r.FormValue("query"))
+	if err != nil {
+		http.Error(w, "Invalid query", http.StatusBadRequest)
+		return
+	}
+	// Pagination is not part of the view
+	query.Del("page")
+	query.Del("reset")
+
+	view := &models.SavedView{Name: name, Page: page, Query: query.Encode()}
+	params, _ := viewParams(view)
+	ids, err := h.db.GetFirstPaperIDs(params, viewPaperLimit)
+	if err != nil {
+		serverError(w, "Failed to fetch papers", err)
+		log.Printf("Error fetching papers for view %s: %v", name, err)
+		return
+	}
+
+	if _, err := h.db.CreateView(view.Name, view.Page, view.Query, ids); err != nil {
+		if errors.Is(err, db.ErrViewExists) {
+			w.Header().Set("HX-Trigger", `{"showToast": {"message": "A view with this name already exists", "type": "error"}}`)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		serverError(w, "Failed to save view", err)
+		log.Printf("Error saving view: %v", err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", `{"showToast": {"message": "View saved; find it under Views", "type": "success"}}`)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleView runs a saved view: the papers that arrived since the last
+// visit come first, then the first page of everything it matches
+func (h *Handler) HandleView(w http.ResponseWriter, r *http.Request) {
+	view := h.loadView(w, r)
+	if view == nil {
+		return
+	}
+
+	params, err := viewParams(view)
+	if err != nil {
+		serverError(w, "Invalid view query", err)
+		log.Printf("Error parsing view %s: %v", view.Name, err)
+		return
+	}
+	params.Page = 1
+	params.PageSize = h.pageSize(h.loadPrefs(r))
+
+	data := PageData{
+		Title:    view.Name,
+		View:     view,
+		ViewLink: viewListURL(view),
+		Features: h.features.Map(),
+	}
+
+	var ids []string
+	var l loader
+	l.Require(func() (err error) {
+		ids, err = h.db.GetFirstPaperIDs(params, viewPaperLimit)
+		return err
+	})
+	l.Require(func() (err error) {
+		data.Papers, data.TotalResults, err = h.db.GetPapers(params)
+		return err
+	})
+	h.loadCounts(&l, &data)
+	if err := l.Wait(); err != nil {
+		serverError(w, "Failed to fetch papers", err)
+		log.Printf("Error fetching papers for view %s: %v", view.Name, err)
+		return
+	}
+
+	// The visit is only recorded once the page can be shown, so no new
+	// paper goes unseen
+	arrived, err := h.db.VisitView(view.ID, ids, time.Now())
+	if err == nil {
+		data.NewPapers, err = h.db.GetPapersByIDs(arrived)
+	}
+	if err != nil {
+		serverError(w, "Failed to record visit", err)
+		log.Printf("Error recording visit to view %s: %v", view.Name, err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "view.html", data); err != nil {
+		serverError(w, "Failed to render template", err)
+		log.Printf("Error rendering template: %v", err)
+	}
+}
+
+// HandleDeleteView deletes a saved view and returns to the list of views
+func (h *Handler) HandleDeleteView(w http.ResponseWriter, r *http.Request) {
+	view := h.loadView(w, r)
+	if view == nil {
+		return
+	}
+
+	if err := h.db.DeleteView(view.ID); err != nil {
+		serverError(w, "Failed to delete view", err)
+		log.Printf("Error deleting view %s: %v", view.Name, err)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/views")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/ngx/arxiv-go-nest/internal/arxiv"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// arxivRefRegex matches an arXiv ID in a reference: a bare ID, with an
+// "arXiv:" prefix, or in an abs or pdf link, with an optional version
+var arxivRefRegex = regexp.MustCompile(`^(?i:arxiv:)?(?:https?://(?:www\.|export\.)?arxiv\.org/(?:abs|pdf)/)?(\d{4}\.\d{4,5}|[a-z]+(?:-[a-z]+)*(?:\.[A-Z]{2})?/\d{7})(?:v\d+)?(?:\.pdf)?$`)
+
+// Arxiv imports papers from arXiv through the shared API client
+type Arxiv struct {
+	client *arxiv.Client
+}
+
+// NewArxiv creates the arXiv source
+func NewArxiv(client *arxiv.Client) *Arxiv {
+	return &Arxiv{client: client}
+}
+
+// Name returns the source's namespace; arXiv IDs are stored without it
+func (a *Arxiv) Name() string {
+	return models.SourceArxiv
+}
+
+// Parse accepts arXiv IDs and abs or pdf links, returning the ID without
+// its version
+func (a *Arxiv) Parse(ref string) (string, bool) {
+	m := arxivRefRegex.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// Fetch fetches papers with the API's id_list query
+func (a *Arxiv) Fetch(ctx context.Context, ids []string) ([]*models.Paper, error) {
+	feed, err := a.client.FetchByIDs(ctx, ids)
+	var batchErr *arxiv.BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return nil, err
+	}
+
+	papers, parseErr := feed.ToPapers()
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return papers, err
+}
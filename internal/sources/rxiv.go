@@ -0,0 +1,198 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+const (
+	// rxivAPIBaseURL serves both bioRxiv and medRxiv
+	rxivAPIBaseURL = "https://api.biorxiv.org"
+
+	// rxivDOIPrefix is the DOI prefix of bioRxiv and medRxiv preprints
+	rxivDOIPrefix = "10.1101/"
+
+	rxivTimeout = 30 * time.Second
+)
+
+// rxivSuffixPattern matches the part of a preprint DOI after the prefix:
+// "2024.01.15.575123" since December 2019, six digits before that
+const rxivSuffixPattern = `(\d{4}\.\d{2}\.\d{2}\.\d+|\d{6})`
+
+var (
+	rxivDOIRegex = regexp.MustCompile(`^(?i:doi:|https?://doi\.org/)?10\.1101/` + rxivSuffixPattern + `(?:v\d+)?$`)
+	rxivURLRegex = regexp.MustCompile(`^https?://(?:www\.)?(biorxiv|medrxiv)\.org/content/10\.1101/` + rxivSuffixPattern + `(?:v\d+)?(?:[./].*)?$`)
+)
+
+// rxivLicenses maps the API's license codes to license URLs
+var rxivLicenses = map[string]string{
+	"cc_by":       "http://creativecommons.org/licenses/by/4.0/",
+	"cc_by_nc":    "http://creativecommons.org/licenses/by-nc/4.0/",
+	"cc_by_nd":    "http://creativecommons.org/licenses/by-nd/4.0/",
+	"cc_by_nc_nd": "http://creativecommons.org/licenses/by-nc-nd/4.0/",
+	"cc0":         "http://creativecommons.org/publicdomain/zero/1.0/",
+}
+
+// Rxiv imports preprints from bioRxiv or medRxiv, which share an API and
+// the 10.1101 DOI prefix. Papers are stored as "<server>:<DOI suffix>".
+type Rxiv struct {
+	server     string
+	site       string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewBioRxiv creates the bioRxiv source
+func NewBioRxiv() *Rxiv {
+	return newRxiv("biorxiv")
+}
+
+// NewMedRxiv creates the medRxiv source
+func NewMedRxiv() *Rxiv {
+	return newRxiv("medrxiv")
+}
+
+func newRxiv(server string) *Rxiv {
+	return &Rxiv{
+		server:     server,
+		site:       "https://www." + server + ".org",
+		baseURL:    rxivAPIBaseURL,
+		httpClient: &http.Client{Timeout: rxivTimeout},
+	}
+}
+
+// SetBaseURL points the source at another API host, e.g. a test server
+func (x *Rxiv) SetBaseURL(baseURL string) {
+	x.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// Name returns the server's namespace, "biorxiv" or "medrxiv"
+func (x *Rxiv) Name() string {
+	return x.server
+}
+
+// Parse accepts namespaced IDs ("biorxiv:2024.01.15.575123"), 10.1101
+// DOIs, which either server may have, and links to the server's site
+func (x *Rxiv) Parse(ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if suffix, ok := strings.CutPrefix(ref, x.server+":"); ok {
+		ref = rxivDOIPrefix + suffix
+	}
+	if m := rxivDOIRegex.FindStringSubmatch(ref); m != nil {
+		return x.server + ":" + m[1], true
+	}
+	if m := rxivURLRegex.FindStringSubmatch(ref); m != nil && m[1] == x.server {
+		return x.server + ":" + m[2], true
+	}
+	return "", false
+}
+
+// rxivResponse is the API's details response; collection has one item
+// per version, oldest first
+type rxivResponse struct {
+	Collection []struct {
+		DOI       string `json:"doi"`
+		Title     string `json:"title"`
+		Authors   string `json:"authors"`
+		Date      string `json:"date"`
+		Version   string `json:"version"`
+		License   string `json:"license"`
+		Category  string `json:"category"`
+		Abstract  string `json:"abstract"`
+		Published string `json:"published"`
+	} `json:"collection"`
+}
+
+// Fetch fetches the papers one at a time from the details endpoint
+func (x *Rxiv) Fetch(ctx context.Context, ids []string) ([]*models.Paper, error) {
+	var papers []*models.Paper
+	for _, id := range ids {
+		p, err := x.fetch(ctx, strings.TrimPrefix(id, x.server+":"))
+		if err != nil {
+			return papers, fmt.Errorf("failed to fetch %s: %w", id, err)
+		}
+		if p != nil {
+			papers = append(papers, p)
+		}
+	}
+	return papers, nil
+}
+
+// fetch fetches one preprint by its DOI suffix, returning nil if the
+// server doesn't have it
+func (x *Rxiv) fetch(ctx context.Context, suffix string) (*models.Paper, error) {
+	u := fmt.Sprintf("%s/details/%s/%s%s/na/json", x.baseURL, x.server, rxivDOIPrefix, url.PathEscape(suffix))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := x.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var body rxivResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(body.Collection) == 0 {
+		return nil, nil
+	}
+
+	first, latest := body.Collection[0], body.Collection[len(body.Collection)-1]
+	published, err := time.Parse("2006-01-02", first.Date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date: %w", err)
+	}
+	updated, err := time.Parse("2006-01-02", latest.Date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date: %w", err)
+	}
+
+	page := fmt.Sprintf("%s/content/%s%sv%s", x.site, rxivDOIPrefix, suffix, latest.Version)
+	p := &models.Paper{
+		ID:          x.server + ":" + suffix,
+		Title:       strings.Join(strings.Fields(latest.Title), " "),
+		Abstract:    strings.Join(strings.Fields(latest.Abstract), " "),
+		Authors:     rxivAuthors(latest.Authors),
+		Categories:  strings.TrimSpace(latest.Category),
+		PublishedAt: published,
+		UpdatedAt:   updated,
+		PDFUrl:      page + ".full.pdf",
+		ArxivUrl:    page,
+		License:     rxivLicenses[latest.License],
+	}
+	if latest.Published != "" && latest.Published != "NA" {
+		p.Comment = "Published as doi:" + latest.Published
+	}
+	return p, nil
+}
+
+// rxivAuthors turns the API's "Smith, J. A.; Doe, B." into the
+// "J. A. Smith, B. Doe" form arXiv papers are stored with
+func rxivAuthors(authors string) string {
+	var names []string
+	for _, author := range strings.Split(authors, ";") {
+		author = strings.TrimSpace(author)
+		if author == "" {
+			continue
+		}
+		if last, first, ok := strings.Cut(author, ","); ok {
+			author = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+		}
+		names = append(names, author)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,156 @@
+// Package sources imports papers by ID from preprint servers. Each source
+// owns an ID namespace: arXiv papers keep their plain IDs (2401.01234),
+// while other servers' papers are stored as "<source>:<id>", e.g.
+// "biorxiv:2024.01.15.575123", so IDs from different servers never collide.
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// ErrNotFound is reported for a reference none of the sources has
+var ErrNotFound = errors.New("paper not found")
+
+// Source is a preprint server papers can be imported from
+type Source interface {
+	// Name is the source's ID namespace, e.g. "biorxiv"
+	Name() string
+
+	// Parse returns the paper ID for a reference the source recognizes:
+	// one of its IDs, a DOI or a link to the paper
+	Parse(ref string) (string, bool)
+
+	// Fetch fetches papers by the IDs Parse returned. IDs the server
+	// doesn't have are left out of the result; an error means the papers
+	// could not be requested.
+	Fetch(ctx context.Context, ids []string) ([]*models.Paper, error)
+}
+
+// Registry imports papers from the sources it was created with
+type Registry struct {
+	sources []Source
+}
+
+// New creates a registry. References are offered to the sources in order.
+func New(sources ...Source) *Registry {
+	return &Registry{sources: sources}
+}
+
+// Names returns the names of the registered sources
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.sources))
+	for i, s := range r.sources {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// ImportError lists the references that could not be imported
+type ImportError struct {
+	// Unrecognized are references no source could parse
+	Unrecognized []string
+	// Failed maps references to why fetching them failed
+	Failed map[string]error
+}
+
+func (e *ImportError) Error() string {
+	var parts []string
+	if len(e.Unrecognized) > 0 {
+		parts = append(parts, "unrecognized: "+strings.Join(e.Unrecognized, ", "))
+	}
+	for _, ref := range e.Refs()[len(e.Unrecognized):] {
+		parts = append(parts, fmt.Sprintf("%s: %v", ref, e.Failed[ref]))
+	}
+	return "failed to import " + strings.Join(parts, "; ")
+}
+
+// Refs returns every reference that was not imported, unrecognized ones first
+func (e *ImportError) Refs() []string {
+	refs := append([]string(nil), e.Unrecognized...)
+	failed := make([]string, 0, len(e.Failed))
+	for ref := range e.Failed {
+		failed = append(failed, ref)
+	}
+	sort.Strings(failed)
+	return append(refs, failed...)
+}
+
+// Import fetches the papers for a list of references, one request batch
+// per source. A reference several sources recognize, such as a bare
+// 10.1101 DOI shared by bioRxiv and medRxiv, is tried on the next of them
+// when a source doesn't have it. The papers that were fetched are returned
+// together with an *ImportError for the rest.
+func (r *Registry) Import(ctx context.Context, refs []string) ([]*models.Paper, error) {
+	importErr := &ImportError{Failed: map[string]error{}}
+	var papers []*models.Paper
+
+	var pending []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		pending = append(pending, ref)
+	}
+
+	recognized := make(map[string]bool)
+	for _, s := range r.sources {
+		ids := make(map[string]string) // paper ID -> reference
+		var rest []string
+		for _, ref := range pending {
+			if id, ok := s.Parse(ref); ok {
+				recognized[ref] = true
+				if _, dup := ids[id]; !dup {
+					ids[id] = ref
+					continue
+				}
+			}
+			rest = append(rest, ref)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		list := make([]string, 0, len(ids))
+		for id := range ids {
+			list = append(list, id)
+		}
+		sort.Strings(list)
+		fetched, err := s.Fetch(ctx, list)
+		for _, p := range fetched {
+			if ref, ok := ids[p.ID]; ok {
+				papers = append(papers, p)
+				delete(importErr.Failed, ref)
+				delete(ids, p.ID)
+			}
+		}
+		for _, ref := range ids {
+			if err != nil {
+				importErr.Failed[ref] = err
+				continue
+			}
+			// Not on this server; another source may have it
+			importErr.Failed[ref] = ErrNotFound
+			rest = append(rest, ref)
+		}
+		pending = rest
+	}
+
+	for _, ref := range pending {
+		if !recognized[ref] {
+			importErr.Unrecognized = append(importErr.Unrecognized, ref)
+		}
+	}
+	if len(importErr.Unrecognized) > 0 || len(importErr.Failed) > 0 {
+		return papers, importErr
+	}
+	return papers, nil
+}
@@ -0,0 +1,158 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	a, bio, med := NewArxiv(nil), NewBioRxiv(), NewMedRxiv()
+	tests := []struct {
+		source Source
+		ref    string
+		want   string
+	}{
+		{a, "2401.01234", "2401.01234"},
+		{a, "arXiv:2401.01234v2", "2401.01234"},
+		{a, "https://arxiv.org/abs/hep-th/9901001v1", "hep-th/9901001"},
+		{a, "https://arxiv.org/pdf/2401.01234v3.pdf", "2401.01234"},
+		{a, "10.1101/2024.01.15.575123", ""},
+		{bio, "biorxiv:2024.01.15.575123", "biorxiv:2024.01.15.575123"},
+		{bio, "10.1101/2024.01.15.575123v2", "biorxiv:2024.01.15.575123"},
+		{bio, "doi:10.1101/123456", "biorxiv:123456"},
+		{bio, "https://www.biorxiv.org/content/10.1101/2024.01.15.575123v1.full.pdf", "biorxiv:2024.01.15.575123"},
+		{bio, "https://www.medrxiv.org/content/10.1101/2024.01.15.24301234v1", ""},
+		{bio, "medrxiv:2024.01.15.24301234", ""},
+		{med, "https://doi.org/10.1101/2024.01.15.24301234", "medrxiv:2024.01.15.24301234"},
+		{med, "2401.01234", ""},
+	}
+	for _, tt := range tests {
+		got, ok := tt.source.Parse(tt.ref)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s.Parse(%q) = %q, %v; want %q", tt.source.Name(), tt.ref, got, ok, tt.want)
+		}
+	}
+}
+
+func TestRxivFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/details/biorxiv/10.1101/2024.01.15.575123/na/json" {
+			fmt.Fprint(w, `{"messages":[{"status":"no posts found"}],"collection":[]}`)
+			return
+		}
+		fmt.Fprint(w, `{"collection":[
+			{"doi":"10.1101/2024.01.15.575123","title":"Old  title","authors":"Smith, J. A.","date":"2024-01-15","version":"1","license":"cc_no","category":"neuroscience","abstract":"Old.","published":"NA"},
+			{"doi":"10.1101/2024.01.15.575123","title":"Cortical\n maps","authors":"Smith, J. A.; Doe, B.","date":"2024-03-02","version":"2","license":"cc_by","category":"neuroscience","abstract":" We map\n cortex. ","published":"10.1038/s41586-024-00001-1"}
+		]}`)
+	}))
+	defer srv.Close()
+
+	bio := NewBioRxiv()
+	bio.SetBaseURL(srv.URL)
+	papers, err := bio.Fetch(context.Background(), []string{"biorxiv:2024.01.15.575123", "biorxiv:2024.02.01.000001"})
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(papers) != 1 {
+		t.Fatalf("Expected the missing paper to be left out, got %d papers", len(papers))
+	}
+
+	p := papers[0]
+	if p.ID != "biorxiv:2024.01.15.575123" || p.Title != "Cortical maps" || p.Abstract != "We map cortex." {
+		t.Errorf("Unexpected paper: %+v", p)
+	}
+	if p.Authors != "J. A. Smith, B. Doe" {
+		t.Errorf("Expected authors in arXiv order, got %q", p.Authors)
+	}
+	if p.PublishedAt.Format("2006-01-02") != "2024-01-15" || p.UpdatedAt.Format("2006-01-02") != "2024-03-02" {
+		t.Errorf("Expected the first and latest version dates, got %v and %v", p.PublishedAt, p.UpdatedAt)
+	}
+	if p.ArxivUrl != "https://www.biorxiv.org/content/10.1101/2024.01.15.575123v2" || !strings.HasSuffix(p.PDFUrl, "v2.full.pdf") {
+		t.Errorf("Expected links to the latest version, got %q and %q", p.ArxivUrl, p.PDFUrl)
+	}
+	if p.License != "http://creativecommons.org/licenses/by/4.0/" || p.Comment != "Published as doi:10.1038/s41586-024-00001-1" {
+		t.Errorf("Unexpected license or comment: %q, %q", p.License, p.Comment)
+	}
+	if p.Source() != "biorxiv" || p.SourceName() != "bioRxiv" {
+		t.Errorf("Expected a bioRxiv paper, got %q", p.Source())
+	}
+}
+
+// fakeSource serves papers from a fixed set of IDs
+type fakeSource struct {
+	name  string
+	have  map[string]bool
+	err   error
+	calls [][]string
+}
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) Parse(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "10.1101/") {
+		return f.name + ":" + strings.TrimPrefix(ref, "10.1101/"), true
+	}
+	return "", false
+}
+
+func (f *fakeSource) Fetch(ctx context.Context, ids []string) ([]*models.Paper, error) {
+	f.calls = append(f.calls, ids)
+	if f.err != nil {
+		return nil, f.err
+	}
+	var papers []*models.Paper
+	for _, id := range ids {
+		if f.have[id] {
+			papers = append(papers, &models.Paper{ID: id})
+		}
+	}
+	return papers, nil
+}
+
+func TestImport(t *testing.T) {
+	bio := &fakeSource{name: "biorxiv", have: map[string]bool{"biorxiv:1": true}}
+	med := &fakeSource{name: "medrxiv", have: map[string]bool{"medrxiv:2": true}}
+	r := New(bio, med)
+
+	papers, err := r.Import(context.Background(), []string{"10.1101/1", "10.1101/2", " 10.1101/1 ", "10.1101/3", "nonsense", ""})
+	var ids []string
+	for _, p := range papers {
+		ids = append(ids, p.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"biorxiv:1", "medrxiv:2"}) {
+		t.Errorf("Expected the DOI medRxiv has to fall through to it, got %v", ids)
+	}
+	if !reflect.DeepEqual(bio.calls, [][]string{{"biorxiv:1", "biorxiv:2", "biorxiv:3"}}) {
+		t.Errorf("Expected one batch per source, got %v", bio.calls)
+	}
+
+	var importErr *ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("Expected an ImportError, got %v", err)
+	}
+	if !reflect.DeepEqual(importErr.Refs(), []string{"nonsense", "10.1101/3"}) {
+		t.Errorf("Expected the unrecognized and missing references, got %v", importErr.Refs())
+	}
+	if !errors.Is(importErr.Failed["10.1101/3"], ErrNotFound) {
+		t.Errorf("Expected 10.1101/3 not to be found, got %v", importErr.Failed["10.1101/3"])
+	}
+
+	// A failing request isn't retried on the next source
+	down := errors.New("connection refused")
+	bio.err = down
+	med.calls = nil
+	if _, err := r.Import(context.Background(), []string{"10.1101/2"}); !errors.As(err, &importErr) || importErr.Failed["10.1101/2"] != down {
+		t.Errorf("Expected the request error, got %v", err)
+	}
+	if med.calls != nil {
+		t.Errorf("Expected medRxiv not to be asked, got %v", med.calls)
+	}
+}
@@ -0,0 +1,270 @@
+// Package tasks runs long operations started from the web UI, such as
+// imports, backfills and index repairs, in the background and tracks
+// their progress, so pages can follow them live rather than wait on a
+// blocking request. Unlike scheduler jobs, tasks run once.
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for a task ID the manager does not have
+var ErrNotFound = errors.New("task not found")
+
+// ErrRunning is returned when starting a task while another of the same
+// name is running
+var ErrRunning = errors.New("task is already running")
+
+// keepFinished is how many finished tasks are kept to be listed
+const keepFinished = 20
+
+// Func does the work of a task, reporting how far it got on p, and
+// returns a summary of what it did. It should stop when ctx is done.
+type Func func(ctx context.Context, p *Progress) (string, error)
+
+// Status is a snapshot of a task
+type Status struct {
+	ID   string
+	Name string
+
+	// Done of Total items are finished; Current names the item being
+	// worked on
+	Done    int
+	Total   int
+	Current string
+
+	// ETA estimates the time left from the pace so far; zero while it
+	// can't be estimated
+	ETA time.Duration
+
+	Started  time.Time
+	Finished time.Time
+	Result   string
+	Err      string
+	Canceled bool
+}
+
+// Running reports whether the task hasn't finished yet
+func (s Status) Running() bool {
+	return s.Finished.IsZero()
+}
+
+// Percent returns how far the task got, from 0 to 100
+func (s Status) Percent() int {
+	if s.Total <= 0 {
+		return 0
+	}
+	return min(100, s.Done*100/s.Total)
+}
+
+// task is a running or finished task with its followers
+type task struct {
+	status Status
+	cancel context.CancelFunc
+	subs   map[chan Status]bool
+
+	// base is the progress at the first update and baseAt its time: a
+	// task resuming earlier work, like a backfill, starts part way, and
+	// only the progress since counts towards its pace
+	base   int
+	baseAt time.Time
+}
+
+// Manager runs tasks and keeps the most recently finished ones
+type Manager struct {
+	mu     sync.Mutex
+	tasks  map[string]*task
+	nextID int
+	now    func() time.Time
+}
+
+// New creates a task manager
+func New() *Manager {
+	return &Manager{tasks: make(map[string]*task), now: time.Now}
+}
+
+// Start runs fn in the background as a task and returns its ID. Only one
+// task of a name runs at a time.
+func (m *Manager) Start(name string, fn Func) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tasks {
+		if t.status.Name == name && t.status.Running() {
+			return "", ErrRunning
+		}
+	}
+
+	m.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &task{
+		status: Status{ID: strconv.Itoa(m.nextID), Name: name, Started: m.now()},
+		cancel: cancel,
+		subs:   make(map[chan Status]bool),
+	}
+	m.tasks[t.status.ID] = t
+	m.prune()
+
+	go func() {
+		defer cancel()
+		result, err := fn(ctx, &Progress{m: m, t: t})
+		m.finish(t, result, err, err != nil && ctx.Err() != nil)
+	}()
+	return t.status.ID, nil
+}
+
+// Get returns the status of a task
+func (m *Manager) Get(id string) (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return Status{}, false
+	}
+	return m.snapshot(t), true
+}
+
+// List returns the running and recently finished tasks, newest first
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Status, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		list = append(list, m.snapshot(t))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].ID, list[j].ID)
+	})
+	return list
+}
+
+// Cancel asks a running task to stop by canceling its context
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	t.cancel()
+	return nil
+}
+
+// Follow returns a channel receiving the task's status now and whenever
+// it changes, closed once the task has finished and its final status was
+// sent. A slow reader only misses intermediate updates. Stop following
+// with the returned function.
+func (m *Manager) Follow(id string) (<-chan Status, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	ch := make(chan Status, 1)
+	ch <- m.snapshot(t)
+	if !t.status.Running() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	t.subs[ch] = true
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(t.subs, ch)
+	}, nil
+}
+
+// Progress is how a running task reports how far it got
+type Progress struct {
+	m *Manager
+	t *task
+}
+
+// Update records that done of total items are finished and the task is
+// working on current
+func (p *Progress) Update(done, total int, current string) {
+	m := p.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := p.t
+	if t.baseAt.IsZero() {
+		t.base, t.baseAt = done, m.now()
+	}
+	t.status.Done, t.status.Total, t.status.Current = done, total, current
+	m.publish(t)
+}
+
+// finish records the outcome of a task and lets its followers go. A task
+// counts as canceled if it stopped with an error after being canceled.
+func (m *Manager) finish(t *task, result string, err error, canceled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t.status.Finished = m.now()
+	t.status.Result = result
+	t.status.Current = ""
+	t.status.Canceled = canceled
+	if err != nil && !canceled {
+		t.status.Err = err.Error()
+	}
+	m.publish(t)
+	for ch := range t.subs {
+		close(ch)
+	}
+	t.subs = nil
+	m.prune()
+}
+
+// publish sends a task's status to its followers, replacing any update
+// they haven't read yet
+func (m *Manager) publish(t *task) {
+	s := m.snapshot(t)
+	for ch := range t.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- s
+	}
+}
+
+// snapshot returns a task's status with its ETA
+func (m *Manager) snapshot(t *task) Status {
+	s := t.status
+	if s.Running() && s.Done > t.base && s.Total > s.Done {
+		elapsed := m.now().Sub(t.baseAt)
+		s.ETA = elapsed * time.Duration(s.Total-s.Done) / time.Duration(s.Done-t.base)
+	}
+	return s
+}
+
+// prune drops the oldest finished tasks beyond keepFinished
+func (m *Manager) prune() {
+	var finished []string
+	for id, t := range m.tasks {
+		if !t.status.Running() {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= keepFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return newer(finished[i], finished[j])
+	})
+	for _, id := range finished[keepFinished:] {
+		delete(m.tasks, id)
+	}
+}
+
+// newer reports whether task ID a was started after b
+func newer(a, b string) bool {
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	return x > y
+}
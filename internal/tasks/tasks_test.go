@@ -0,0 +1,120 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// next waits for the next status sent to a follower
+func next(t *testing.T, updates <-chan Status) (Status, bool) {
+	t.Helper()
+	select {
+	case s, ok := <-updates:
+		return s, ok
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a status")
+		return Status{}, false
+	}
+}
+
+func TestProgressAndFollow(t *testing.T) {
+	m := New()
+	clock := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+
+	step := make(chan struct{})
+	id, err := m.Start("import", func(ctx context.Context, p *Progress) (string, error) {
+		// Resumes at 10 of 50, so the pace counts from there
+		p.Update(10, 50, "first")
+		<-step
+		p.Update(20, 50, "second")
+		<-step
+		return "imported 50", nil
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := m.Start("import", nil); err != ErrRunning {
+		t.Errorf("Expected ErrRunning for a second import, got %v", err)
+	}
+
+	updates, stop, err := m.Follow(id)
+	if err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+	defer stop()
+	s, _ := next(t, updates)
+	for s.Done != 10 {
+		s, _ = next(t, updates)
+	}
+
+	m.mu.Lock()
+	clock = clock.Add(10 * time.Second)
+	m.mu.Unlock()
+	step <- struct{}{}
+	s, _ = next(t, updates)
+	if s.Done != 20 || s.Current != "second" || s.Percent() != 40 {
+		t.Errorf("Expected 20 of 50 at the second item, got %+v", s)
+	}
+	if s.ETA != 30*time.Second {
+		t.Errorf("Expected 30s left at 10 items per 10s, got %s", s.ETA)
+	}
+
+	step <- struct{}{}
+	for s.Running() {
+		s, _ = next(t, updates)
+	}
+	if s.Result != "imported 50" || s.Err != "" || s.Canceled {
+		t.Errorf("Expected a finished import, got %+v", s)
+	}
+	if _, ok := next(t, updates); ok {
+		t.Error("Expected the updates to end with the task")
+	}
+
+	// Following a finished task sends its final status
+	updates, _, _ = m.Follow(id)
+	if s, _ := next(t, updates); s.Result != "imported 50" {
+		t.Errorf("Expected the final status, got %+v", s)
+	}
+	if _, _, err := m.Follow("nope"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCancelAndPrune(t *testing.T) {
+	m := New()
+	id, _ := m.Start("backfill", func(ctx context.Context, p *Progress) (string, error) {
+		<-ctx.Done()
+		return "stopped at 2020-01-05", ctx.Err()
+	})
+	updates, stop, _ := m.Follow(id)
+	defer stop()
+	if err := m.Cancel(id); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	s, _ := next(t, updates)
+	for s.Running() {
+		s, _ = next(t, updates)
+	}
+	if !s.Canceled || s.Err != "" || s.Result != "stopped at 2020-01-05" {
+		t.Errorf("Expected a canceled task, got %+v", s)
+	}
+
+	for i := 0; i < keepFinished+5; i++ {
+		id, _ := m.Start("verify", func(ctx context.Context, p *Progress) (string, error) {
+			return "", errors.New("broken")
+		})
+		updates, _, _ := m.Follow(id)
+		for range updates {
+		}
+	}
+	list := m.List()
+	if len(list) != keepFinished {
+		t.Fatalf("Expected %d tasks kept, got %d", keepFinished, len(list))
+	}
+	if list[0].Err != "broken" || !newer(list[0].ID, list[1].ID) {
+		t.Errorf("Expected the newest failed task first, got %+v", list[0])
+	}
+}
@@ -0,0 +1,326 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+)
+
+// defaultInterval is how often telemetry is exported without a configured
+// interval
+const defaultInterval = 10 * time.Second
+
+// maxQueuedSpans bounds the spans kept between exports; later spans are
+// dropped until the next export
+const maxQueuedSpans = 4096
+
+// exportTimeout bounds a single export request
+const exportTimeout = 10 * time.Second
+
+// scopeName is the instrumentation scope of every span and metric
+const scopeName = "github.com/ngx/arxiv-go-nest"
+
+// buckets are the histogram bucket bounds in seconds, the defaults
+// recommended for OpenTelemetry duration metrics
+var buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Provider batches spans and aggregates histograms, exporting them to an
+// OTLP/HTTP endpoint at an interval
+type Provider struct {
+	tracesURL  string
+	metricsURL string
+	headers    map[string]string
+	resource   []Attr
+	client     *http.Client
+	started    time.Time
+
+	// Queued spans and histograms, guarded by mu
+	mu         sync.Mutex
+	spans      []*Span
+	dropped    int
+	histograms map[string]*histogram
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// histogram is a cumulative histogram of one metric and attribute set
+type histogram struct {
+	name   string
+	attrs  []Attr
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Setup starts exporting to cfg.Endpoint, the base URL of an OTLP/HTTP
+// receiver such as http://localhost:4318, and makes the provider the one
+// used by Start and Record. Without an endpoint it returns nil and
+// telemetry stays off.
+func Setup(cfg config.TelemetryConfig, version string) (*Provider, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid telemetry endpoint %q: expected an http(s) URL", cfg.Endpoint)
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "arxiv-nest"
+	}
+
+	base := strings.TrimRight(cfg.Endpoint, "/")
+	p := &Provider{
+		tracesURL:  base + "/v1/traces",
+		metricsURL: base + "/v1/metrics",
+		headers:    cfg.Headers,
+		resource:   []Attr{String("service.name", serviceName), String("service.version", version)},
+		client:     &http.Client{Timeout: exportTimeout},
+		started:    time.Now(),
+		histograms: make(map[string]*histogram),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go p.run(interval)
+
+	provider.Store(p)
+	return p, nil
+}
+
+// Shutdown stops recording and exports what is left. It is safe to call
+// on a nil provider.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	provider.CompareAndSwap(p, nil)
+	close(p.stop)
+	<-p.done
+	return p.export(ctx)
+}
+
+// run exports at every interval until Shutdown
+func (p *Provider) run(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			if err := p.export(ctx); err != nil {
+				log.Printf("Error exporting telemetry: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// enqueue queues a finished span for the next export
+func (p *Provider) enqueue(s *Span) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.spans) >= maxQueuedSpans {
+		p.dropped++
+		return
+	}
+	p.spans = append(p.spans, s)
+}
+
+// record adds a value to a histogram
+func (p *Provider) record(metric string, value float64, attrs []Attr) {
+	var key strings.Builder
+	key.WriteString(metric)
+	for _, a := range attrs {
+		fmt.Fprintf(&key, "\x00%s=%v", a.Key, a.Value)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.histograms[key.String()]
+	if h == nil {
+		h = &histogram{name: metric, attrs: attrs, counts: make([]uint64, len(buckets)+1)}
+		p.histograms[key.String()] = h
+	}
+	h.counts[sort.SearchFloat64s(buckets, value)]++
+	h.count++
+	h.sum += value
+}
+
+// export sends the queued spans and the current histograms
+func (p *Provider) export(ctx context.Context) error {
+	p.mu.Lock()
+	spans, dropped := p.spans, p.dropped
+	p.spans, p.dropped = nil, 0
+	histograms := make([]histogram, 0, len(p.histograms))
+	for _, h := range p.histograms {
+		c := *h
+		c.counts = append([]uint64(nil), h.counts...)
+		histograms = append(histograms, c)
+	}
+	p.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Telemetry export queue full, dropped %d spans", dropped)
+	}
+
+	var errs []error
+	if len(spans) > 0 {
+		if err := p.post(ctx, p.tracesURL, p.traces(spans)); err != nil {
+			errs = append(errs, fmt.Errorf("traces: %w", err))
+		}
+	}
+	if len(histograms) > 0 {
+		if err := p.post(ctx, p.metricsURL, p.metrics(histograms)); err != nil {
+			errs = append(errs, fmt.Errorf("metrics: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends an OTLP JSON request
+func (p *Provider) post(ctx context.Context, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// unixNano formats a time as the string form of a fixed64 field
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// traces builds an ExportTraceServiceRequest
+func (p *Provider) traces(spans []*Span) interface{} {
+	out := make([]map[string]interface{}, len(spans))
+	for i, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.context.traceID[:]),
+			"spanId":            hex.EncodeToString(s.context.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": unixNano(s.start),
+			"endTimeUnixNano":   unixNano(s.end),
+			"attributes":        nonNil(s.attrs),
+		}
+		if s.parentID != ([8]byte{}) {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.err}
+		}
+		out[i] = span
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": p.resource},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": scopeName},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+// metrics builds an ExportMetricsServiceRequest of cumulative histograms
+func (p *Provider) metrics(histograms []histogram) interface{} {
+	now := unixNano(time.Now())
+	points := make(map[string][]interface{})
+	var names []string
+	for _, h := range histograms {
+		counts := make([]string, len(h.counts))
+		for i, c := range h.counts {
+			counts[i] = strconv.FormatUint(c, 10)
+		}
+		if _, ok := points[h.name]; !ok {
+			names = append(names, h.name)
+		}
+		points[h.name] = append(points[h.name], map[string]interface{}{
+			"attributes":        nonNil(h.attrs),
+			"startTimeUnixNano": unixNano(p.started),
+			"timeUnixNano":      now,
+			"count":             strconv.FormatUint(h.count, 10),
+			"sum":               h.sum,
+			"bucketCounts":      counts,
+			"explicitBounds":    buckets,
+		})
+	}
+	sort.Strings(names)
+
+	metrics := make([]interface{}, len(names))
+	for i, name := range names {
+		metrics[i] = map[string]interface{}{
+			"name": name,
+			"unit": "s",
+			"histogram": map[string]interface{}{
+				"aggregationTemporality": 2, // cumulative
+				"dataPoints":             points[name],
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": p.resource},
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   map[string]interface{}{"name": scopeName},
+				"metrics": metrics,
+			}},
+		}},
+	}
+}
+
+// nonNil returns attrs, or an empty list so it encodes as [] not null
+func nonNil(attrs []Attr) []Attr {
+	if attrs == nil {
+		return []Attr{}
+	}
+	return attrs
+}
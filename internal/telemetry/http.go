@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transport wraps an HTTP transport (nil for http.DefaultTransport) to
+// trace outgoing requests as client spans and record their duration. The
+// span ends when the response body is closed, so it includes reading the
+// response.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// transport is the RoundTripper returned by Transport
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	host := req.URL.Host
+	_, span := Start(req.Context(), req.Method+" "+host, KindClient,
+		String("http.request.method", req.Method),
+		String("server.address", host),
+		String("url.full", req.URL.Redacted()),
+	)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		Record("http.client.request.duration", time.Since(start),
+			String("http.request.method", req.Method), String("server.address", host))
+		return nil, err
+	}
+
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("unexpected status code %d", resp.StatusCode))
+	}
+	end := func() {
+		span.End()
+		Record("http.client.request.duration", time.Since(start),
+			String("http.request.method", req.Method), String("server.address", host),
+			Int("http.response.status_code", resp.StatusCode))
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, end: end}
+	return resp, nil
+}
+
+// tracedBody ends a client span when the response body is closed
+type tracedBody struct {
+	io.ReadCloser
+	once sync.Once
+	end  func()
+}
+
+// Close closes the body and ends the span
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.end)
+	return err
+}
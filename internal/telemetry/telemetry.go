@@ -0,0 +1,204 @@
+// Package telemetry traces HTTP requests, database queries and arXiv API
+// calls and keeps duration histograms of them, exporting both to an
+// OpenTelemetry collector over OTLP/HTTP in its JSON encoding. Until Setup
+// is called with an endpoint every function is a cheap no-op, so callers
+// instrument unconditionally.
+package telemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// provider is the exporter installed by Setup, nil while telemetry is off
+var provider atomic.Pointer[Provider]
+
+// Enabled reports whether telemetry is being recorded
+func Enabled() bool {
+	return provider.Load() != nil
+}
+
+// Kind is the OTLP kind of a span
+type Kind int
+
+// Span kinds, numbered as in OTLP
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attr is an attribute of a span or metric data point
+type Attr struct {
+	Key   string
+	Value interface{} // string, int64, float64 or bool
+}
+
+// String returns a string attribute
+func String(key, value string) Attr {
+	return Attr{key, value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attr {
+	return Attr{key, int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr {
+	return Attr{key, value}
+}
+
+// MarshalJSON encodes the attribute as an OTLP KeyValue
+func (a Attr) MarshalJSON() ([]byte, error) {
+	var value map[string]interface{}
+	switch v := a.Value.(type) {
+	case int64:
+		// 64-bit integers are strings in the JSON encoding
+		value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		value = map[string]interface{}{"doubleValue": v}
+	case bool:
+		value = map[string]interface{}{"boolValue": v}
+	default:
+		value = map[string]interface{}{"stringValue": v}
+	}
+	return json.Marshal(map[string]interface{}{"key": a.Key, "value": value})
+}
+
+// spanContext identifies a span within its trace
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// contextKey is the context key of the current spanContext
+type contextKey struct{}
+
+// current returns the span context carried by ctx, if any
+func current(ctx context.Context) (spanContext, bool) {
+	sc, ok := ctx.Value(contextKey{}).(spanContext)
+	return sc, ok
+}
+
+// Span is a timed operation. The nil span, returned while telemetry is
+// off, ignores every call.
+type Span struct {
+	p        *Provider
+	context  spanContext
+	parentID [8]byte
+	name     string
+	kind     Kind
+	start    time.Time
+	end      time.Time
+	attrs    []Attr
+	err      string
+}
+
+// Start starts a span, as a child of the span in ctx if there is one or
+// else as the root of a new trace, and returns a context carrying it
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	p := provider.Load()
+	if p == nil {
+		return ctx, nil
+	}
+
+	s := &Span{p: p, name: name, kind: kind, start: time.Now(), attrs: attrs}
+	if parent, ok := current(ctx); ok {
+		s.context.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		putRandom(s.context.traceID[:])
+	}
+	putRandom(s.context.spanID[:])
+	return context.WithValue(ctx, contextKey{}, s.context), s
+}
+
+// Child starts a span only when ctx is part of a trace, so frequent
+// operations outside a traced request (such as background queries) don't
+// each become a trace of their own
+func Child(ctx context.Context, name string, kind Kind, attrs ...Attr) *Span {
+	if _, ok := current(ctx); !ok {
+		return nil
+	}
+	_, s := Start(ctx, name, kind, attrs...)
+	return s
+}
+
+// Extract returns ctx continuing the trace of a W3C traceparent header,
+// so requests passing through a traced proxy join its trace. Malformed
+// headers are ignored.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return ctx
+	}
+
+	var sc spanContext
+	if n, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || n != len(sc.traceID) {
+		return ctx
+	}
+	if n, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || n != len(sc.spanID) {
+		return ctx
+	}
+	if sc.traceID == ([16]byte{}) || sc.spanID == ([8]byte{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SetName renames the span, e.g. once a request's route is known
+func (s *Span) SetName(name string) {
+	if s != nil {
+		s.name = name
+	}
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s != nil {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// SetError marks the span as failed with err
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.err = err.Error()
+	}
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil || !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	s.p.enqueue(s)
+}
+
+// Record adds a duration, in seconds, to the histogram named metric for
+// the given attributes. Attributes should take few distinct values: every
+// combination is exported as its own series.
+func Record(metric string, d time.Duration, attrs ...Attr) {
+	if p := provider.Load(); p != nil {
+		p.record(metric, d.Seconds(), attrs)
+	}
+}
+
+// putRandom fills b with random bytes for trace and span IDs
+func putRandom(b []byte) {
+	for i := 0; i < len(b); i += 8 {
+		v := rand.Uint64()
+		for j := i; j < i+8 && j < len(b); j++ {
+			b[j] = byte(v)
+			v >>= 8
+		}
+	}
+}
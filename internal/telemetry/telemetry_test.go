@@ -0,0 +1,156 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+)
+
+// collector records the OTLP requests it receives by path
+type collector struct {
+	mu       sync.Mutex
+	requests map[string][]map[string]interface{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests[r.URL.Path] = append(c.requests[r.URL.Path], body)
+	c.mu.Unlock()
+}
+
+// items digs the spans or metrics out of an export request
+func items(t *testing.T, body map[string]interface{}, resource, scope, list string) []map[string]interface{} {
+	t.Helper()
+	r := body[resource].([]interface{})[0].(map[string]interface{})
+	s := r[scope].([]interface{})[0].(map[string]interface{})
+	var out []map[string]interface{}
+	for _, item := range s[list].([]interface{}) {
+		out = append(out, item.(map[string]interface{}))
+	}
+	return out
+}
+
+func TestExport(t *testing.T) {
+	c := &collector{requests: map[string][]map[string]interface{}{}}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	p, err := Setup(config.TelemetryConfig{
+		Endpoint: srv.URL + "/",
+		Interval: time.Hour, // export only on shutdown
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	}, "1.0.0")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if !Enabled() {
+		t.Fatal("Expected telemetry to be enabled")
+	}
+
+	// A request continuing a proxy's trace, with a failing query in it
+	ctx := Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := Start(ctx, "GET /paper/{id}", KindServer, String("http.route", "/paper/{id}"))
+	child := Child(ctx, "GetPaperByID", KindClient, Int("rows", 1))
+	child.SetError(errors.New("database is locked"))
+	child.End()
+	root.End()
+	root.End() // ending twice exports once
+
+	// Outside a trace, Child records nothing
+	if span := Child(context.Background(), "GetPapers", KindClient); span != nil {
+		t.Error("Expected no span outside a trace")
+	}
+
+	Record("http.server.request.duration", 20*time.Millisecond, String("http.route", "/"))
+	Record("http.server.request.duration", 3*time.Second, String("http.route", "/"))
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if Enabled() {
+		t.Error("Expected telemetry to be off after shutdown")
+	}
+
+	traces := c.requests["/v1/traces"]
+	if len(traces) != 1 {
+		t.Fatalf("Expected one trace export, got %d", len(traces))
+	}
+	spans := items(t, traces[0], "resourceSpans", "scopeSpans", "spans")
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d: %v", len(spans), spans)
+	}
+	query, request := spans[0], spans[1]
+	if request["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" || request["parentSpanId"] != "00f067aa0ba902b7" {
+		t.Errorf("Expected the request to continue the incoming trace, got %v", request)
+	}
+	if query["traceId"] != request["traceId"] || query["parentSpanId"] != request["spanId"] {
+		t.Errorf("Expected the query to be a child of the request, got %v", query)
+	}
+	if status, _ := query["status"].(map[string]interface{}); status["message"] != "database is locked" {
+		t.Errorf("Expected the query's error status, got %v", query["status"])
+	}
+	attr := query["attributes"].([]interface{})[0].(map[string]interface{})
+	if attr["key"] != "rows" || attr["value"].(map[string]interface{})["intValue"] != "1" {
+		t.Errorf("Expected an integer attribute, got %v", attr)
+	}
+
+	metrics := c.requests["/v1/metrics"]
+	if len(metrics) != 1 {
+		t.Fatalf("Expected one metrics export, got %d", len(metrics))
+	}
+	m := items(t, metrics[0], "resourceMetrics", "scopeMetrics", "metrics")[0]
+	point := m["histogram"].(map[string]interface{})["dataPoints"].([]interface{})[0].(map[string]interface{})
+	if m["name"] != "http.server.request.duration" || point["count"] != "2" || point["sum"].(float64) != 3.02 {
+		t.Errorf("Expected a histogram of both requests, got %v", m)
+	}
+	counts := point["bucketCounts"].([]interface{})
+	if counts[2] != "1" || counts[11] != "1" {
+		t.Errorf("Expected the requests in the 25ms and 5s buckets, got %v", counts)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	p, err := Setup(config.TelemetryConfig{}, "")
+	if p != nil || err != nil {
+		t.Fatalf("Expected no provider without an endpoint, got %v, %v", p, err)
+	}
+	ctx, span := Start(context.Background(), "GET /", KindServer)
+	span.SetAttributes(String("k", "v"))
+	span.End()
+	if _, ok := current(ctx); ok || span != nil {
+		t.Error("Expected no span while telemetry is off")
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown of a nil provider failed: %v", err)
+	}
+
+	if _, err := Setup(config.TelemetryConfig{Endpoint: "localhost:4318"}, ""); err == nil {
+		t.Error("Expected an endpoint without a scheme to be rejected")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzzzzzzzzzzzzzzz-01",
+	} {
+		if _, ok := current(Extract(context.Background(), header)); ok {
+			t.Errorf("Expected %q to be ignored", header)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package tts turns paper abstracts into audio with a local text-to-speech
+// command, caching the files so each abstract is only spoken once.
+package tts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// contentTypes maps the supported audio formats to their MIME types
+var contentTypes = map[string]string{
+	"wav": "audio/wav",
+	"mp3": "audio/mpeg",
+	"ogg": "audio/ogg",
+}
+
+// defaultTimeout bounds a synthesis without a configured timeout
+const defaultTimeout = 2 * time.Minute
+
+// Synthesizer runs the text-to-speech command and caches its output
+type Synthesizer struct {
+	command []string
+	format  string
+	dir     string
+	timeout time.Duration
+
+	// mu serializes synthesis: speech engines are CPU-bound, and two
+	// requests for the same paper should not both run the command
+	mu sync.Mutex
+}
+
+// New creates a synthesizer from configuration, or returns nil if no
+// command is configured
+func New(cfg config.TTSConfig) (*Synthesizer, error) {
+	if len(cfg.Command) == 0 {
+		return nil, nil
+	}
+	format := strings.ToLower(cfg.Format)
+	if format == "" {
+		format = "wav"
+	}
+	if _, ok := contentTypes[format]; !ok {
+		return nil, fmt.Errorf("unsupported audio format %q (supported: wav, mp3, ogg)", cfg.Format)
+	}
+	if cfg.CacheDir == "" {
+		return nil, fmt.Errorf("tts.cache_dir must be set")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Synthesizer{command: cfg.Command, format: format, dir: cfg.CacheDir, timeout: timeout}, nil
+}
+
+// ContentType returns the MIME type of the generated audio
+func (s *Synthesizer) ContentType() string {
+	return contentTypes[s.format]
+}
+
+// Extension returns the file extension of the generated audio, e.g. ".wav"
+func (s *Synthesizer) Extension() string {
+	return "." + s.format
+}
+
+// Audio returns the path of the audio file for a paper, running the command
+// if it is not cached yet. The file is named after the spoken text, so a
+// revised abstract is spoken again; the audio of the old one is removed.
+func (s *Synthesizer) Audio(ctx context.Context, p *models.Paper) (string, error) {
+	text := Text(p)
+	sum := sha256.Sum256([]byte(text))
+	prefix := strings.ReplaceAll(p.ID, "/", "_") + "-"
+	path := filepath.Join(s.dir, prefix+hex.EncodeToString(sum[:6])+s.Extension())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create audio cache: %w", err)
+	}
+
+	audio, err := s.synthesize(ctx, text)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tts-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(audio); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+
+	old, _ := filepath.Glob(filepath.Join(s.dir, prefix+"*"+s.Extension()))
+	for _, o := range old {
+		os.Remove(o)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+	return path, nil
+}
+
+// synthesize runs the command with text on standard input and returns what
+// it wrote to standard output
+func (s *Synthesizer) synthesize(ctx context.Context, text string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("text-to-speech timed out after %s", s.timeout)
+		}
+		return nil, fmt.Errorf("text-to-speech failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("text-to-speech produced no audio")
+	}
+	return stdout.Bytes(), nil
+}
+
+var (
+	latexCommand = regexp.MustCompile(`\\([A-Za-z]+)`)
+	latexMarkup  = strings.NewReplacer("$", "", "{", "", "}", "", "\\", "", "^", " ", "_", " ", "~", " ")
+)
+
+// silentCommands only format their argument and are not read out
+var silentCommands = map[string]bool{
+	"emph": true, "textbf": true, "textit": true, "texttt": true, "text": true,
+	"mathrm": true, "mathbf": true, "mathit": true, "mathcal": true, "mathbb": true,
+	"left": true, "right": true,
+}
+
+// Text is what is spoken for a paper: its title, authors and abstract, with
+// LaTeX markup reduced to words, so "$\alpha$" is read as "alpha"
+func Text(p *models.Paper) string {
+	text := fmt.Sprintf("%s. By %s. %s", p.Title, p.Authors, p.Abstract)
+	text = latexCommand.ReplaceAllStringFunc(text, func(cmd string) string {
+		if silentCommands[cmd[1:]] {
+			return " "
+		}
+		return " " + cmd[1:] + " "
+	})
+	text = latexMarkup.Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,87 @@
+package tts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ngx/arxiv-go-nest/internal/config"
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestAudio(t *testing.T) {
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+
+	// A fake engine that "speaks" by echoing the text, counting its runs
+	s, err := New(config.TTSConfig{
+		Command:  []string{"sh", "-c", `echo run >> "$1"; cat`, "tts", runs},
+		Format:   "MP3",
+		CacheDir: filepath.Join(dir, "audio"),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if s.ContentType() != "audio/mpeg" {
+		t.Errorf("Expected audio/mpeg, got %s", s.ContentType())
+	}
+
+	paper := &models.Paper{ID: "hep-th/9901001", Title: "Strings", Authors: "A. Author", Abstract: "First version."}
+	path, err := s.Audio(context.Background(), paper)
+	if err != nil {
+		t.Fatalf("Audio failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != Text(paper) {
+		t.Errorf("Expected the command's output in %s, got %q", path, data)
+	}
+	if again, err := s.Audio(context.Background(), paper); err != nil || again != path {
+		t.Errorf("Expected the cached file, got %s, %v", again, err)
+	}
+	if data, _ := os.ReadFile(runs); strings.Count(string(data), "run") != 1 {
+		t.Errorf("Expected one run for a cached abstract, got %q", data)
+	}
+
+	// A revised abstract is spoken again and replaces the old audio
+	paper.Abstract = "Second version."
+	revised, err := s.Audio(context.Background(), paper)
+	if err != nil {
+		t.Fatalf("Audio failed: %v", err)
+	}
+	if revised == path {
+		t.Error("Expected a new file for a revised abstract")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the old audio to be removed")
+	}
+}
+
+func TestAudioErrors(t *testing.T) {
+	s, err := New(config.TTSConfig{Command: []string{"sh", "-c", "echo no voice >&2; exit 1"}, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := s.Audio(context.Background(), &models.Paper{ID: "1"}); err == nil || !strings.Contains(err.Error(), "no voice") {
+		t.Errorf("Expected the command's error, got %v", err)
+	}
+
+	if s, err := New(config.TTSConfig{}); s != nil || err != nil {
+		t.Errorf("Expected no synthesizer without a command, got %v, %v", s, err)
+	}
+	if _, err := New(config.TTSConfig{Command: []string{"say"}, Format: "flac", CacheDir: "x"}); err == nil {
+		t.Error("Expected an unsupported format to be rejected")
+	}
+}
+
+func TestText(t *testing.T) {
+	p := &models.Paper{
+		Title:    "On $\\alpha$-Divergences",
+		Authors:  "Ada Lovelace, Alan Turing",
+		Abstract: "We bound $x_{i}^2$\n  by \\emph{much} less.",
+	}
+	want := "On alpha -Divergences. By Ada Lovelace, Alan Turing. We bound x i 2 by much less."
+	if got := Text(p); got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
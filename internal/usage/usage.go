@@ -0,0 +1,140 @@
+// Package usage counts how the web interface is used, for instance admins
+// deciding which features are worth their upkeep. Nothing leaves the
+// server: counts are kept per day and route in the database. Browsers are
+// told apart only to count them, by a hash that changes every day and
+// with every restart, and the hashes stay in memory.
+package usage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// flushInterval is how long counts are collected in memory before they
+// are written out
+const flushInterval = time.Minute
+
+// Store persists usage counts
+type Store interface {
+	AddUsage(counts []models.UsageCount) error
+}
+
+// key identifies a route's counts on a day
+type key struct {
+	day, kind, name string
+}
+
+// Recorder collects usage counts and writes them to its store every
+// flushInterval. Counts not written yet are lost if the process stops. A
+// nil Recorder records nothing.
+type Recorder struct {
+	store Store
+	salt  []byte
+
+	mu        sync.Mutex
+	pending   map[key]*models.UsageCount
+	day       string
+	seen      map[string]bool // visitor hashes per route, for day
+	lastFlush time.Time
+	flushing  bool
+}
+
+// New creates a recorder writing to store
+func New(store Store) *Recorder {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	return &Recorder{
+		store:     store,
+		salt:      salt,
+		pending:   make(map[key]*models.UsageCount),
+		seen:      make(map[string]bool),
+		lastFlush: time.Now(),
+	}
+}
+
+// Record counts a use of the named route by a browser at the given time.
+// The first use of a route by a browser on a day also counts a visitor.
+func (r *Recorder) Record(kind, name, client string, at time.Time) {
+	if r == nil {
+		return
+	}
+	day := at.UTC().Format("2006-01-02")
+	visitor := r.visitor(day, client)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if day != r.day {
+		r.day = day
+		r.seen = make(map[string]bool)
+	}
+
+	k := key{day, kind, name}
+	c, ok := r.pending[k]
+	if !ok {
+		c = &models.UsageCount{Day: day, Kind: kind, Name: name}
+		r.pending[k] = c
+	}
+	c.Count++
+	if id := kind + " " + name + " " + visitor; !r.seen[id] {
+		r.seen[id] = true
+		c.Visitors++
+	}
+
+	if !r.flushing && at.Sub(r.lastFlush) >= flushInterval {
+		r.flushing = true
+		go func() {
+			if err := r.Flush(); err != nil {
+				log.Printf("Error recording usage: %v", err)
+			}
+		}()
+	}
+}
+
+// visitor hashes a browser's ID for the day, so days and restarts can't be
+// linked
+func (r *Recorder) visitor(day, client string) string {
+	h := sha256.New()
+	h.Write(r.salt)
+	h.Write([]byte(day + "\x00" + client))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Flush writes the collected counts now. Counts that fail to write are
+// kept for the next attempt.
+func (r *Recorder) Flush() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[key]*models.UsageCount)
+	r.lastFlush = time.Now()
+	r.mu.Unlock()
+
+	counts := make([]models.UsageCount, 0, len(pending))
+	for _, c := range pending {
+		counts = append(counts, *c)
+	}
+	err := r.store.AddUsage(counts)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushing = false
+	if err != nil {
+		for k, c := range pending {
+			if newer, ok := r.pending[k]; ok {
+				newer.Count += c.Count
+				newer.Visitors += c.Visitors
+			} else {
+				r.pending[k] = c
+			}
+		}
+	}
+	return err
+}
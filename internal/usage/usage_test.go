@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+// memoryStore keeps usage counts in memory, failing while err is set
+type memoryStore struct {
+	mu     sync.Mutex
+	err    error
+	counts []models.UsageCount
+}
+
+func (s *memoryStore) AddUsage(counts []models.UsageCount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.counts = append(s.counts, counts...)
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	store := &memoryStore{err: errors.New("database is locked")}
+	r := New(store)
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	r.Record(models.UsagePage, "GET /tags", "alice", day)
+	r.Record(models.UsagePage, "GET /tags", "alice", day.Add(time.Second))
+	r.Record(models.UsagePage, "GET /tags", "bob", day.Add(2*time.Second))
+	r.Record(models.UsageAction, "POST /tag/add", "alice", day.Add(3*time.Second))
+
+	// Counts survive a failed write
+	if err := r.Flush(); err == nil {
+		t.Fatal("Expected the store's error")
+	}
+	r.Record(models.UsagePage, "GET /tags", "alice", day.Add(24*time.Hour))
+	store.err = nil
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	sort.Slice(store.counts, func(i, j int) bool {
+		a, b := store.counts[i], store.counts[j]
+		return a.Day+a.Name < b.Day+b.Name
+	})
+	want := []models.UsageCount{
+		{Day: "2024-03-01", Kind: models.UsagePage, Name: "GET /tags", Count: 3, Visitors: 2},
+		{Day: "2024-03-01", Kind: models.UsageAction, Name: "POST /tag/add", Count: 1, Visitors: 1},
+		{Day: "2024-03-02", Kind: models.UsagePage, Name: "GET /tags", Count: 1, Visitors: 1},
+	}
+	if len(store.counts) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, store.counts)
+	}
+	for i := range want {
+		if store.counts[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], store.counts[i])
+		}
+	}
+
+	// Hashes change with the day and with the process
+	if r.visitor("2024-03-01", "alice") == r.visitor("2024-03-02", "alice") {
+		t.Error("Expected a browser's hash to change every day")
+	}
+	if r.visitor("2024-03-01", "alice") == New(store).visitor("2024-03-01", "alice") {
+		t.Error("Expected a browser's hash to change with the salt")
+	}
+}
@@ -0,0 +1,245 @@
+// Package venues detects the conferences papers mention in their arXiv
+// comments ("Accepted at NeurIPS 2024") and knows the deadlines and dates
+// of their editions, from a built-in catalog optionally extended by a file.
+package venues
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed venues.yaml
+var builtin []byte
+
+// Edition is one year of a venue. Dates that are not known are zero.
+type Edition struct {
+	Year             int       `yaml:"year"`
+	Location         string    `yaml:"location"`
+	AbstractDeadline time.Time `yaml:"abstract_deadline"`
+	PaperDeadline    time.Time `yaml:"paper_deadline"`
+	Notification     time.Time `yaml:"notification"`
+	Start            time.Time `yaml:"start"`
+	End              time.Time `yaml:"end"`
+}
+
+// Venue is a conference with the arXiv categories it covers
+type Venue struct {
+	Name     string    `yaml:"name"`
+	FullName string    `yaml:"full_name"`
+	Aliases  []string  `yaml:"aliases"`
+	Areas    []string  `yaml:"areas"`
+	Editions []Edition `yaml:"editions"`
+}
+
+// Event kinds in the deadline feed
+const (
+	KindAbstract     = "abstract deadline"
+	KindPaper        = "paper deadline"
+	KindNotification = "notification"
+	KindConference   = "conference"
+)
+
+// Event is a dated milestone of a venue edition. End is the last day of a
+// conference and equals Start for deadlines.
+type Event struct {
+	Venue    string
+	FullName string
+	Year     int
+	Kind     string
+	Location string
+	Start    time.Time
+	End      time.Time
+}
+
+// Title describes the event, e.g. "NeurIPS 2025 paper deadline"
+func (e Event) Title() string {
+	if e.Kind == KindConference {
+		return fmt.Sprintf("%s %d", e.Venue, e.Year)
+	}
+	return fmt.Sprintf("%s %d %s", e.Venue, e.Year, e.Kind)
+}
+
+// pattern matches one spelling of a venue in a comment. The optional
+// year is "2024", "2024" glued to the name, or "'24".
+type pattern struct {
+	re    *regexp.Regexp
+	venue string
+}
+
+// Catalog holds the known venues
+type Catalog struct {
+	venues   []Venue
+	patterns []pattern
+}
+
+// Load returns the built-in catalog merged with the venues in path, if
+// path is not empty. A venue in the file adds to the built-in venue of
+// the same name; its editions replace built-in editions of the same year.
+func Load(path string) (*Catalog, error) {
+	var venues []Venue
+	if err := yaml.Unmarshal(builtin, &venues); err != nil {
+		return nil, fmt.Errorf("failed to parse built-in venues: %w", err)
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read venues file: %w", err)
+		}
+		var extra []Venue
+		if err := yaml.Unmarshal(data, &extra); err != nil {
+			return nil, fmt.Errorf("failed to parse venues file %s: %w", path, err)
+		}
+		venues = merge(venues, extra)
+	}
+
+	c := &Catalog{venues: venues}
+	for _, v := range venues {
+		if v.Name == "" {
+			return nil, errors.New("venue without a name")
+		}
+		for _, spelling := range append([]string{v.Name}, v.Aliases...) {
+			re := regexp.MustCompile(`(?:^|[^\w-])` + regexp.QuoteMeta(spelling) + `(?:\s*(\d{4})|\s*['’](\d{2}))?\b`)
+			c.patterns = append(c.patterns, pattern{re: re, venue: v.Name})
+		}
+	}
+	return c, nil
+}
+
+// merge adds the venues of extra to base
+func merge(base, extra []Venue) []Venue {
+	for _, e := range extra {
+		i := 0
+		for i < len(base) && base[i].Name != e.Name {
+			i++
+		}
+		if i == len(base) {
+			base = append(base, e)
+			continue
+		}
+
+		v := &base[i]
+		if e.FullName != "" {
+			v.FullName = e.FullName
+		}
+		v.Aliases = append(v.Aliases, e.Aliases...)
+		v.Areas = append(v.Areas, e.Areas...)
+		for _, ed := range e.Editions {
+			j := 0
+			for j < len(v.Editions) && v.Editions[j].Year != ed.Year {
+				j++
+			}
+			if j == len(v.Editions) {
+				v.Editions = append(v.Editions, ed)
+			} else {
+				v.Editions[j] = ed
+			}
+		}
+	}
+	return base
+}
+
+// Venues returns the known venues
+func (c *Catalog) Venues() []Venue {
+	return c.venues
+}
+
+// Detect returns the venues mentioned in an arXiv comment, one per venue.
+// Names are matched case-sensitively; a mention without a year, as in
+// "Submitted to ICML", is taken to be of defaultYear.
+func (c *Catalog) Detect(comment string, defaultYear int) []models.VenueMention {
+	var found []models.VenueMention
+	seen := make(map[string]bool)
+	for _, p := range c.patterns {
+		if seen[p.venue] {
+			continue
+		}
+		m := p.re.FindStringSubmatch(comment)
+		if m == nil {
+			continue
+		}
+		seen[p.venue] = true
+
+		year := defaultYear
+		if m[1] != "" {
+			year, _ = strconv.Atoi(m[1])
+		} else if m[2] != "" {
+			yy, _ := strconv.Atoi(m[2])
+			year = 2000 + yy
+		}
+		found = append(found, models.VenueMention{Venue: p.venue, Year: year})
+	}
+	return found
+}
+
+// Upcoming returns the events not yet over of venues that cover one of
+// areas (arXiv categories) or are named in mentioned, by date
+func (c *Catalog) Upcoming(areas, mentioned []string, now time.Time) []Event {
+	wanted := make(map[string]bool)
+	for _, a := range areas {
+		wanted[a] = true
+	}
+	named := make(map[string]bool)
+	for _, n := range mentioned {
+		named[n] = true
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var events []Event
+	for _, v := range c.venues {
+		if !named[v.Name] && !covers(v, wanted) {
+			continue
+		}
+		for _, ed := range v.Editions {
+			add := func(kind string, start, end time.Time) {
+				if start.IsZero() || end.Before(today) {
+					return
+				}
+				events = append(events, Event{
+					Venue:    v.Name,
+					FullName: v.FullName,
+					Year:     ed.Year,
+					Kind:     kind,
+					Location: ed.Location,
+					Start:    start,
+					End:      end,
+				})
+			}
+			add(KindAbstract, ed.AbstractDeadline, ed.AbstractDeadline)
+			add(KindPaper, ed.PaperDeadline, ed.PaperDeadline)
+			add(KindNotification, ed.Notification, ed.Notification)
+			end := ed.End
+			if end.IsZero() {
+				end = ed.Start
+			}
+			add(KindConference, ed.Start, end)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].Venue < events[j].Venue
+	})
+	return events
+}
+
+// covers reports whether a venue covers one of the wanted categories
+func covers(v Venue, wanted map[string]bool) bool {
+	for _, a := range v.Areas {
+		if wanted[a] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package venues
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ngx/arxiv-go-nest/internal/models"
+)
+
+func TestDetect(t *testing.T) {
+	c, err := Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		comment string
+		want    []models.VenueMention
+	}{
+		{
+			name:    "name and year",
+			comment: "Accepted at NeurIPS 2024. Code: https://github.com/x/y",
+			want:    []models.VenueMention{{Venue: "NeurIPS", Year: 2024}},
+		},
+		{
+			name:    "alias, short year and glued year",
+			comment: "NIPS'23 workshop; extended version under review at CVPR2025",
+			want: []models.VenueMention{
+				{Venue: "NeurIPS", Year: 2023},
+				{Venue: "CVPR", Year: 2025},
+			},
+		},
+		{
+			name:    "no year falls back to the default",
+			comment: "Submitted to ICML",
+			want:    []models.VenueMention{{Venue: "ICML", Year: 2021}},
+		},
+		{
+			name:    "names inside other names do not match",
+			comment: "Accepted to NAACL 2024 and eccv-style formatting",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Detect(tt.comment, 2021)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect(%q) = %v, want %v", tt.comment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "venues.yaml")
+	extra := `
+- name: ICLR
+  editions:
+    - year: 2026
+      location: Rio de Janeiro
+      paper_deadline: 2025-09-25
+      start: 2026-04-23
+      end: 2026-04-27
+- name: MLSys
+  areas: [cs.DC]
+  editions:
+    - year: 2026
+      paper_deadline: 2025-10-30
+`
+	if err := os.WriteFile(path, []byte(extra), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	now := time.Date(2025, 9, 20, 15, 0, 0, 0, time.UTC)
+	var got []string
+	for _, e := range c.Upcoming([]string{"cs.DC"}, []string{"ICLR"}, now) {
+		got = append(got, e.Start.Format("2006-01-02")+" "+e.Title())
+	}
+
+	// ICLR is in by mention, with the deadline moved and the abstract
+	// deadline dropped by the file; MLSys covers the wanted area
+	want := []string{
+		"2025-09-25 ICLR 2026 paper deadline",
+		"2025-10-30 MLSys 2026 paper deadline",
+		"2026-04-23 ICLR 2026",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Upcoming = %q, want %q", got, want)
+	}
+
+	// An event is listed until its last day
+	ongoing := c.Upcoming(nil, []string{"ICCV"}, time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC))
+	if len(ongoing) != 1 || ongoing[0].Title() != "ICCV 2025" {
+		t.Errorf("expected ICCV 2025 on its last day, got %v", ongoing)
+	}
+}
@@ -0,0 +1,156 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultReleasesURL is GitHub's latest release endpoint; %s is "owner/repo"
+const defaultReleasesURL = "https://api.github.com/repos/%s/releases/latest"
+
+// Release is a published release
+type Release struct {
+	Tag         string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	URL         string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Checker looks up the latest GitHub release of a repository and reports
+// whether it is newer than the running version
+type Checker struct {
+	repository string
+	current    string
+	client     *http.Client
+
+	// releasesURL is the release endpoint format, replaceable in tests
+	releasesURL string
+
+	mu     sync.Mutex
+	latest *Release
+}
+
+// NewChecker creates a checker for the "owner/repo" repository
+func NewChecker(repository, current string) *Checker {
+	return &Checker{
+		repository:  repository,
+		current:     current,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		releasesURL: defaultReleasesURL,
+	}
+}
+
+// Check fetches the latest release and remembers it
+func (c *Checker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(c.releasesURL, c.repository), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to check for updates: %s", resp.Status)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return fmt.Errorf("failed to decode release: %w", err)
+	}
+
+	c.mu.Lock()
+	c.latest = &release
+	c.mu.Unlock()
+	return nil
+}
+
+// Available returns the latest release if it is newer than the running
+// version, or nil. Development builds never report updates.
+func (c *Checker) Available() *Release {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.latest == nil || !Newer(c.latest.Tag, c.current) {
+		return nil
+	}
+	release := *c.latest
+	return &release
+}
+
+// Newer reports whether version a is newer than b. Both are semantic
+// versions with an optional "v" prefix; anything else (such as "dev") is
+// never newer or older. A build "git describe" names after a tag, such as
+// v1.2.0-3-gabc1234 or v1.2.0-dirty, is newer than the tag itself.
+func Newer(a, b string) bool {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	if !okA || !okB {
+		return false
+	}
+
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			return va.parts[i] > vb.parts[i]
+		}
+	}
+	// A release is newer than a pre-release of the same version, and
+	// builds after a tag are newer than the tag
+	if va.pre != vb.pre {
+		return va.pre == "" && vb.pre != ""
+	}
+	return va.post && !vb.post
+}
+
+// describeSuffix matches what "git describe --dirty" appends to the tag of
+// a build that isn't the tagged commit: the commits since and the commit's
+// abbreviated hash, and whether the tree had uncommitted changes
+var describeSuffix = regexp.MustCompile(`(-[0-9]+-g[0-9a-f]+)?(-dirty)?$`)
+
+// semver is a parsed major.minor.patch version with its pre-release suffix
+type semver struct {
+	parts [3]int
+	pre   string
+	// post is set for builds after the tagged version
+	post bool
+}
+
+// parseSemver parses "v1.2.3", "1.2.3-rc.1" or "v1.2.3-4-gabc1234"; missing
+// minor or patch numbers count as 0
+func parseSemver(s string) (semver, bool) {
+	var v semver
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if suffix := describeSuffix.FindString(s); suffix != "" {
+		s, v.post = strings.TrimSuffix(s, suffix), true
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.pre = s[:i], s[i+1:]
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return v, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,75 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"1.2", "v1.2.0", false},
+		{"v1.2.0", "v1.2.0-rc.1", true},
+		{"v1.2.0-rc.1", "v1.2.0", false},
+		{"v2.0.0", "dev", false},
+		{"v1.0.0", "v1.0.1", false},
+		{"v1.2.0", "v1.2.0-3-gabc1234", false},
+		{"v1.2.0-3-gabc1234", "v1.2.0", true},
+		{"v1.2.0", "v1.2.0-3-gabc1234-dirty", false},
+		{"v1.2.0", "v1.2.0-dirty", false},
+		{"v1.2.1", "v1.2.0-3-gabc1234", true},
+		{"v1.2.0", "v1.2.0-rc.1-3-gabc1234", true},
+	}
+
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckerReportsNewerRelease(t *testing.T) {
+	tag := "v1.3.0"
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/releases/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "` + tag + `", "html_url": "https://github.com/owner/repo/releases/tag/` + tag + `"}`))
+	}))
+	defer api.Close()
+
+	c := NewChecker("owner/repo", "v1.2.0")
+	c.releasesURL = api.URL + "/repos/%s/releases/latest"
+
+	if c.Available() != nil {
+		t.Fatal("Expected no release before the first check")
+	}
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if release := c.Available(); release == nil || release.Tag != "v1.3.0" {
+		t.Errorf("Expected v1.3.0 to be available, got %+v", release)
+	}
+
+	tag = "v1.2.0"
+	c.Check(context.Background())
+	if release := c.Available(); release != nil {
+		t.Errorf("Expected no update when up to date, got %+v", release)
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	if got := (Info{Version: "v1.2.0", Commit: "3f2a9c1d8e"}).String(); got != "v1.2.0 (3f2a9c1)" {
+		t.Errorf("Unexpected version string %q", got)
+	}
+	if got := (Info{Version: "3f2a9c1-dirty", Commit: "3f2a9c1d8e", Modified: true}).String(); got != "3f2a9c1-dirty" {
+		t.Errorf("Unexpected version string %q", got)
+	}
+}